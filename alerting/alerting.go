@@ -0,0 +1,143 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+)
+
+// publishTimeout bounds the SNS publish of an alert, made on the request path
+const publishTimeout = 5 * time.Second
+
+// Conditions an alert is raised for
+const (
+	ConditionErrorRate  = "error_rate" // 5xx responses
+	ConditionThrottling = "throttling" // 429 responses, e.g. Bedrock throttling or session limits
+)
+
+// Thresholds of the alerts. A rate of 0 disables its alert.
+type Thresholds struct {
+	Window       time.Duration // Responses are counted per window
+	MinRequests  int           // Windows with fewer responses never alert, so one failed request at night does not page
+	ErrorRate    float64       // Share of 5xx responses in the window, 0 to 1
+	ThrottleRate float64       // Share of 429 responses in the window, 0 to 1
+	Cooldown     time.Duration // An alert is not raised again for its condition within this time
+}
+
+// Monitor counts the responses of the instance and publishes an alert to an SNS topic when
+// the share of errors or throttled requests in a window crosses its threshold. Windows and
+// cooldowns are per instance, so every Lambda instance seeing the condition pages at most
+// once per cooldown.
+type Monitor struct {
+	notifier   aws.NotificationClient
+	topicArn   string
+	name       string // Names the API in alert subjects, e.g. the environment
+	thresholds Thresholds
+	now        func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	errors      int
+	throttles   int
+	alertedAt   map[string]time.Time
+}
+
+func New(notifier aws.NotificationClient, topicArn string, name string, thresholds Thresholds) *Monitor {
+	return &Monitor{
+		notifier:   notifier,
+		topicArn:   topicArn,
+		name:       name,
+		thresholds: thresholds,
+		now:        time.Now,
+		alertedAt:  map[string]time.Time{},
+	}
+}
+
+type alert struct {
+	condition string
+	subject   string
+	message   string
+}
+
+// Record counts a response and publishes the alerts whose threshold it crosses
+func (m *Monitor) Record(ctx context.Context, status int) {
+	alerts := m.record(status)
+	if len(alerts) == 0 {
+		return
+	}
+
+	// The request may have been cancelled by the client, the alert must still be sent
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+	defer cancel()
+	log := logger.WithContext(ctx)
+	for _, a := range alerts {
+		log.Warn("Publishing alert", map[string]interface{}{
+			"condition": a.condition,
+			"message":   a.message,
+		})
+		if err := m.notifier.PublishToTopic(ctx, m.topicArn, a.subject, a.message); err != nil {
+			log.Error("Failed to publish alert", map[string]interface{}{
+				"condition": a.condition,
+				"error":     err.Error(),
+			})
+		}
+	}
+}
+
+func (m *Monitor) record(status int) []alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.windowStart) >= m.thresholds.Window {
+		m.windowStart = now
+		m.requests, m.errors, m.throttles = 0, 0, 0
+	}
+	m.requests++
+	switch {
+	case status >= http.StatusInternalServerError:
+		m.errors++
+	case status == http.StatusTooManyRequests:
+		m.throttles++
+	}
+	if m.requests < m.thresholds.MinRequests {
+		return nil
+	}
+
+	var alerts []alert
+	if a, ok := m.check(now, ConditionErrorRate, "error rate", m.errors, m.thresholds.ErrorRate); ok {
+		alerts = append(alerts, a)
+	}
+	if a, ok := m.check(now, ConditionThrottling, "throttling rate", m.throttles, m.thresholds.ThrottleRate); ok {
+		alerts = append(alerts, a)
+	}
+	return alerts
+}
+
+// check returns the alert of a condition over its threshold and outside its cooldown
+func (m *Monitor) check(now time.Time, condition string, label string, count int, threshold float64) (alert, bool) {
+	rate := float64(count) / float64(m.requests)
+	if threshold <= 0 || rate < threshold {
+		return alert{}, false
+	}
+	if alertedAt, ok := m.alertedAt[condition]; ok && now.Sub(alertedAt) < m.thresholds.Cooldown {
+		return alert{}, false
+	}
+	m.alertedAt[condition] = now
+
+	return alert{
+		condition: condition,
+		subject:   fmt.Sprintf("[%s] High %s", m.name, label),
+		message: fmt.Sprintf(
+			"%s: %d of the last %d requests (%.0f%%) in a %s window, over the %.0f%% threshold. Started %s. No further %s alerts from this instance for %s.",
+			m.name, count, m.requests, rate*100, m.thresholds.Window, threshold*100,
+			m.windowStart.UTC().Format(time.RFC3339), condition, m.thresholds.Cooldown,
+		),
+	}, true
+}
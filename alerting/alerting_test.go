@@ -0,0 +1,90 @@
+package alerting
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	subjects []string
+}
+
+func (r *recordingNotifier) SendEmail(ctx context.Context, from string, to []string, subject string, body string) error {
+	return nil
+}
+
+func (r *recordingNotifier) PublishToTopic(ctx context.Context, topicArn string, subject string, message string) error {
+	r.subjects = append(r.subjects, subject)
+	return nil
+}
+
+func TestMonitor_AlertsOncePerCooldown(t *testing.T) {
+	notifier := &recordingNotifier{}
+	monitor := New(notifier, "arn:aws:sns:ap-southeast-1:123456789012:alerts", "prod", Thresholds{
+		Window:       time.Minute,
+		MinRequests:  4,
+		ErrorRate:    0.5,
+		ThrottleRate: 0.5,
+		Cooldown:     time.Hour,
+	})
+	now := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	monitor.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Below MinRequests nothing is published, however bad the rate
+	for _, status := range []int{500, 500, 200} {
+		monitor.Record(ctx, status)
+	}
+	if len(notifier.subjects) != 0 {
+		t.Fatalf("expected no alert below the minimum requests, got %v", notifier.subjects)
+	}
+	monitor.Record(ctx, 500)
+	if len(notifier.subjects) != 1 || !strings.Contains(notifier.subjects[0], "error rate") {
+		t.Fatalf("expected an error rate alert, got %v", notifier.subjects)
+	}
+
+	// Still failing, but within the cooldown
+	monitor.Record(ctx, 503)
+	now = now.Add(2 * time.Minute)
+	for _, status := range []int{500, 500, 500, 500} {
+		monitor.Record(ctx, status)
+	}
+	if len(notifier.subjects) != 1 {
+		t.Fatalf("expected the alert to be deduplicated, got %v", notifier.subjects)
+	}
+
+	// Throttling is a separate condition with its own cooldown
+	now = now.Add(2 * time.Minute)
+	for _, status := range []int{429, 429, 429, 200} {
+		monitor.Record(ctx, status)
+	}
+	if len(notifier.subjects) != 2 || !strings.Contains(notifier.subjects[1], "throttling") {
+		t.Fatalf("expected a throttling alert, got %v", notifier.subjects)
+	}
+
+	now = now.Add(2 * time.Hour)
+	for _, status := range []int{500, 500, 500, 500} {
+		monitor.Record(ctx, status)
+	}
+	if len(notifier.subjects) != 3 {
+		t.Errorf("expected the error alert again after the cooldown, got %v", notifier.subjects)
+	}
+}
+
+func TestMonitor_IgnoresHealthyWindows(t *testing.T) {
+	notifier := &recordingNotifier{}
+	monitor := New(notifier, "arn:aws:sns:ap-southeast-1:123456789012:alerts", "prod", Thresholds{
+		Window:      time.Minute,
+		MinRequests: 1,
+		ErrorRate:   0.5,
+		Cooldown:    time.Hour,
+	})
+	for _, status := range []int{200, 404, 500, 200, 429, 429, 429} {
+		monitor.Record(context.Background(), status)
+	}
+	if len(notifier.subjects) != 0 {
+		t.Errorf("expected no alert under the error threshold and with throttling alerts off, got %v", notifier.subjects)
+	}
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+)
+
+// AccessRules restrict documents by metadata attribute: for each attribute, the values only
+// the listed roles may see, e.g. {"confidentiality": {"restricted": ["compliance"]}}.
+// Values that are not listed are visible to everyone.
+type AccessRules map[string]map[string][]string
+
+// ParseAccessRules parses the ACCESS_CONTROL_RULES value. An empty value has no rules.
+func ParseAccessRules(value string) (AccessRules, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var rules AccessRules
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("invalid access control rules: %w", err)
+	}
+	for attribute := range rules {
+		if strings.TrimSpace(attribute) == "" {
+			return nil, fmt.Errorf("access control rules must name a metadata attribute")
+		}
+	}
+	return rules, nil
+}
+
+// AccessFor returns the document access of a caller with the roles: every restricted value
+// none of the roles is allowed to see is denied
+func (r AccessRules) AccessFor(roles []string) *aws.DocumentAccess {
+	held := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		held[role] = true
+	}
+
+	access := &aws.DocumentAccess{Denied: map[string][]string{}}
+	for attribute, values := range r {
+		for value, allowed := range values {
+			entitled := false
+			for _, role := range allowed {
+				if held[role] {
+					entitled = true
+					break
+				}
+			}
+			if !entitled {
+				access.Denied[attribute] = append(access.Denied[attribute], value)
+			}
+		}
+		sort.Strings(access.Denied[attribute])
+	}
+	return access
+}
+
+// AccessControl maps callers to the documents they may retrieve, by the role claim of their
+// bearer token. Callers without a token get no roles and only see unrestricted documents.
+type AccessControl struct {
+	rules     AccessRules
+	verifier  *Verifier // Nil when AUTH_JWKS_URL is not set, tokens are then ignored
+	roleClaim string
+}
+
+// NewAccessControl builds the access control from the configuration, nil when no access
+// control rules are configured
+func NewAccessControl(cfg *config.Config) (*AccessControl, error) {
+	rules, err := ParseAccessRules(cfg.AccessControlRules)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	var verifier *Verifier
+	if cfg.AuthJwksUrl != "" {
+		verifier = NewVerifier(cfg.AuthJwksUrl, cfg.AuthIssuer, cfg.AuthAudience)
+	}
+	roleClaim := cfg.AccessControlRoleClaim
+	if roleClaim == "" {
+		roleClaim = "roles"
+	}
+	return &AccessControl{
+		rules:     rules,
+		verifier:  verifier,
+		roleClaim: roleClaim,
+	}, nil
+}
+
+// AccessForClaims returns the document access of a caller whose token was already verified
+func (a *AccessControl) AccessForClaims(claims Claims) *aws.DocumentAccess {
+	return a.rules.AccessFor(claims.Roles(a.roleClaim))
+}
+
+// Access returns the document access of the caller presenting the bearer token, which may
+// be empty. An invalid token is an error rather than anonymous access, so clients notice
+// expired sessions.
+func (a *AccessControl) Access(ctx context.Context, token string) (*aws.DocumentAccess, error) {
+	if token == "" || a.verifier == nil {
+		return a.rules.AccessFor(nil), nil
+	}
+	claims, err := a.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return a.AccessForClaims(claims), nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"teletubpax-api/config"
+)
+
+func TestAccessRules_AccessFor(t *testing.T) {
+	rules, err := ParseAccessRules(`{
+		"confidentiality": {"restricted": ["compliance", "board"], "secret": ["board"]},
+		"department": {"treasury": ["treasury"]}
+	}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	anonymous := rules.AccessFor(nil)
+	if got := anonymous.Key(); got != "confidentiality=restricted,secret;department=treasury" {
+		t.Errorf("expected every restricted value denied without roles, got %q", got)
+	}
+
+	compliance := rules.AccessFor([]string{"compliance"})
+	if got := compliance.Key(); got != "confidentiality=secret;department=treasury" {
+		t.Errorf("expected compliance to see restricted documents, got %q", got)
+	}
+
+	if rules.AccessFor([]string{"board", "treasury"}).Restricted() {
+		t.Error("expected board and treasury to see every document")
+	}
+}
+
+func TestParseAccessRules_Invalid(t *testing.T) {
+	if rules, err := ParseAccessRules(""); err != nil || rules != nil {
+		t.Errorf("expected no rules for an empty value, got %v %v", rules, err)
+	}
+	for _, value := range []string{`{"confidentiality": ["restricted"]}`, `{"": {"restricted": ["hr"]}}`, `not json`} {
+		if _, err := ParseAccessRules(value); err == nil {
+			t.Errorf("expected %s to be rejected", value)
+		}
+	}
+}
+
+func TestAccessControl_WithoutVerifierIgnoresTokens(t *testing.T) {
+	accessControl, err := NewAccessControl(&config.Config{AccessControlRules: `{"confidentiality": {"restricted": ["compliance"]}}`})
+	if err != nil || accessControl == nil {
+		t.Fatalf("expected access control, got %v", err)
+	}
+	access, err := accessControl.Access(context.Background(), "unverifiable-token")
+	if err != nil || !access.Restricted() {
+		t.Errorf("expected anonymous access without AUTH_JWKS_URL, got %+v %v", access, err)
+	}
+
+	if accessControl, err := NewAccessControl(&config.Config{}); err != nil || accessControl != nil {
+		t.Errorf("expected no access control without rules, got %v %v", accessControl, err)
+	}
+}
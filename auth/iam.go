@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	// Headers carrying the caller's signed sts:GetCallerIdentity request
+	IamAuthorizationHeader = "X-Iam-Authorization"
+	IamDateHeader          = "X-Iam-Date"
+	IamSecurityTokenHeader = "X-Iam-Security-Token"
+
+	// IamServerIdHeader must be signed with the configured server ID, so a request signed
+	// for this API cannot be replayed against another service that authenticates the same way
+	IamServerIdHeader = "X-Teletubpax-Server-Id"
+
+	getCallerIdentityBody = "Action=GetCallerIdentity&Version=2011-06-15"
+	getCallerIdentityType = "application/x-www-form-urlencoded; charset=utf-8"
+
+	// iamCacheTTL bounds how long a verified signature is accepted without asking STS again
+	iamCacheTTL = time.Minute
+)
+
+type cachedPrincipal struct {
+	arn       string
+	expiresAt time.Time
+}
+
+// IamVerifier authenticates callers by IAM principal. A service cannot check SigV4 signatures
+// itself, so callers sign an sts:GetCallerIdentity request with their credentials and send
+// its signature; the verifier replays the request to STS, which answers with the caller's
+// ARN only when the signature is valid, and matches the ARN against the allowed principals.
+type IamVerifier struct {
+	stsEndpoint string
+	serverId    string
+	allowed     []string // ARNs, a trailing * matches any suffix
+	httpClient  *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedPrincipal // By signature, date and security token
+}
+
+// NewIamVerifier builds the verifier from the configuration, nil when no principals are allowed
+func NewIamVerifier(cfg *config.Config) *IamVerifier {
+	if len(cfg.IamAuthAllowedPrincipals) == 0 {
+		return nil
+	}
+	region := cfg.IamAuthStsRegion
+	if region == "" {
+		region = cfg.AWSRegion
+	}
+	return &IamVerifier{
+		stsEndpoint: "https://sts." + region + ".amazonaws.com/",
+		serverId:    cfg.IamAuthServerId,
+		allowed:     cfg.IamAuthAllowedPrincipals,
+		httpClient:  &http.Client{Timeout: 5 * time.Second},
+		cache:       map[string]cachedPrincipal{},
+	}
+}
+
+// Verify returns the identity of the principal that signed the request in the headers. The
+// user ID is the principal's ARN, with assumed-role sessions mapped to their role.
+func (v *IamVerifier) Verify(ctx context.Context, header http.Header) (*Identity, error) {
+	authorization := header.Get(IamAuthorizationHeader)
+	if !containsSignedHeader(authorization, strings.ToLower(IamServerIdHeader)) {
+		return nil, fmt.Errorf("signature must include the %s header", IamServerIdHeader)
+	}
+
+	arn, err := v.callerArn(ctx, authorization, header.Get(IamDateHeader), header.Get(IamSecurityTokenHeader))
+	if err != nil {
+		return nil, err
+	}
+	principal := principalArn(arn)
+	if !v.isAllowed(arn) && !v.isAllowed(principal) {
+		return nil, fmt.Errorf("principal %s is not allowed", arn)
+	}
+	return &Identity{
+		UserId:   principal,
+		Username: arn,
+		Claims:   Claims{"arn": arn},
+	}, nil
+}
+
+// callerArn asks STS who signed the request, using the cached answer for a signature seen
+// within iamCacheTTL
+func (v *IamVerifier) callerArn(ctx context.Context, authorization string, date string, securityToken string) (string, error) {
+	cacheKey := authorization + "\n" + date + "\n" + securityToken
+	v.mu.Lock()
+	cached, ok := v.cache[cacheKey]
+	v.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.arn, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.stsEndpoint, strings.NewReader(getCallerIdentityBody))
+	if err != nil {
+		return "", fmt.Errorf("invalid STS endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", getCallerIdentityType)
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set(IamServerIdHeader, v.serverId)
+	if securityToken != "" {
+		req.Header.Set("X-Amz-Security-Token", securityToken)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify signature with STS: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("invalid signature: STS answered %d", resp.StatusCode)
+	}
+
+	var identity struct {
+		Arn string `xml:"GetCallerIdentityResult>Arn"`
+	}
+	if err := xml.Unmarshal(body, &identity); err != nil || identity.Arn == "" {
+		return "", fmt.Errorf("failed to parse the STS caller identity")
+	}
+
+	v.mu.Lock()
+	now := time.Now()
+	for key, entry := range v.cache {
+		if !now.Before(entry.expiresAt) {
+			delete(v.cache, key)
+		}
+	}
+	v.cache[cacheKey] = cachedPrincipal{arn: identity.Arn, expiresAt: now.Add(iamCacheTTL)}
+	v.mu.Unlock()
+	return identity.Arn, nil
+}
+
+func (v *IamVerifier) isAllowed(arn string) bool {
+	for _, allowed := range v.allowed {
+		if prefix, wildcard := strings.CutSuffix(allowed, "*"); wildcard && strings.HasPrefix(arn, prefix) {
+			return true
+		}
+		if allowed == arn {
+			return true
+		}
+	}
+	return false
+}
+
+// principalArn maps an assumed-role session ARN, e.g. the role of an ECS task or Lambda,
+// to the ARN of its role: arn:aws:sts::123456789012:assumed-role/Name/session becomes
+// arn:aws:iam::123456789012:role/Name. Other ARNs are returned as they are.
+func principalArn(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" || !strings.HasPrefix(parts[5], "assumed-role/") {
+		return arn
+	}
+	role := strings.Split(strings.TrimPrefix(parts[5], "assumed-role/"), "/")[0]
+	return strings.Join([]string{parts[0], parts[1], "iam", "", parts[4], "role/" + role}, ":")
+}
+
+// containsSignedHeader reports whether the SigV4 Authorization value lists the header
+func containsSignedHeader(authorization string, name string) bool {
+	for _, part := range strings.Split(authorization, ",") {
+		part = strings.TrimSpace(part)
+		if key, value, ok := strings.Cut(part, "="); ok && strings.EqualFold(key, "SignedHeaders") {
+			for _, signed := range strings.Split(value, ";") {
+				if signed == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// IamAuthHeaders returns the headers authenticating a request to this API as the principal
+// of the credentials, for Go callers. serverId and region must match the API's
+// IAM_AUTH_SERVER_ID and STS region.
+func IamAuthHeaders(ctx context.Context, credentials aws.CredentialsProvider, region string, serverId string) (http.Header, error) {
+	creds, err := credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS credentials: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://sts."+region+".amazonaws.com/", strings.NewReader(getCallerIdentityBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", getCallerIdentityType)
+	req.Header.Set(IamServerIdHeader, serverId)
+	payloadHash := sha256.Sum256([]byte(getCallerIdentityBody))
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(payloadHash[:]), "sts", region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign the caller identity request: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set(IamAuthorizationHeader, req.Header.Get("Authorization"))
+	header.Set(IamDateHeader, req.Header.Get("X-Amz-Date"))
+	if token := req.Header.Get("X-Amz-Security-Token"); token != "" {
+		header.Set(IamSecurityTokenHeader, token)
+	}
+	return header, nil
+}
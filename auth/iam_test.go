@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"teletubpax-api/config"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// fakeSTS answers GetCallerIdentity with the ARN when the forwarded request is signed
+type fakeSTS struct {
+	arn   string
+	calls int
+}
+
+func (f *fakeSTS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.calls++
+	body, _ := io.ReadAll(r.Body)
+	if string(body) != getCallerIdentityBody || r.Header.Get(IamServerIdHeader) != "teletubpax-api" ||
+		!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") || r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.Write([]byte(`<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>` + f.arn + `</Arn>
+    <UserId>AROAEXAMPLE:task</UserId>
+    <Account>123456789012</Account>
+  </GetCallerIdentityResult>
+</GetCallerIdentityResponse>`))
+}
+
+func newTestIamVerifier(t *testing.T, sts *fakeSTS, allowed ...string) *IamVerifier {
+	t.Helper()
+	server := httptest.NewServer(sts)
+	t.Cleanup(server.Close)
+	return &IamVerifier{
+		stsEndpoint: server.URL,
+		serverId:    "teletubpax-api",
+		allowed:     allowed,
+		httpClient:  server.Client(),
+		cache:       map[string]cachedPrincipal{},
+	}
+}
+
+func signedHeaders(t *testing.T, serverId string) http.Header {
+	t.Helper()
+	header, err := IamAuthHeaders(context.Background(), credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "session-token"), "us-east-1", serverId)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return header
+}
+
+func TestIamVerifier_Verify(t *testing.T) {
+	sts := &fakeSTS{arn: "arn:aws:sts::123456789012:assumed-role/reporting-task/1f2e3d"}
+	verifier := newTestIamVerifier(t, sts, "arn:aws:iam::123456789012:role/reporting-*")
+
+	header := signedHeaders(t, "teletubpax-api")
+	if header.Get(IamSecurityTokenHeader) != "session-token" {
+		t.Errorf("expected the session token to be sent, got %v", header)
+	}
+	identity, err := verifier.Verify(context.Background(), header)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity.UserId != "arn:aws:iam::123456789012:role/reporting-task" || identity.Username != sts.arn {
+		t.Errorf("expected the role of the session, got %+v", identity)
+	}
+
+	// A verified signature is not sent to STS again
+	verifier.Verify(context.Background(), header)
+	if sts.calls != 1 {
+		t.Errorf("expected one STS call, got %d", sts.calls)
+	}
+}
+
+func TestIamVerifier_Rejects(t *testing.T) {
+	sts := &fakeSTS{arn: "arn:aws:iam::123456789012:user/someone"}
+	verifier := newTestIamVerifier(t, sts, "arn:aws:iam::123456789012:role/reporting-task")
+
+	if _, err := verifier.Verify(context.Background(), signedHeaders(t, "teletubpax-api")); err == nil {
+		t.Error("expected a principal that is not allowed to be rejected")
+	}
+
+	unsigned := http.Header{}
+	unsigned.Set(IamAuthorizationHeader, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20250601/us-east-1/sts/aws4_request, SignedHeaders=host;x-amz-date, Signature=abc")
+	calls := sts.calls
+	if _, err := verifier.Verify(context.Background(), unsigned); err == nil || sts.calls != calls {
+		t.Errorf("expected a signature without the server ID to be rejected before STS, got %v", err)
+	}
+
+	sts.arn = ""
+	if _, err := verifier.Verify(context.Background(), signedHeaders(t, "other-service")); err == nil {
+		t.Error("expected a failed STS call to be rejected")
+	}
+}
+
+func TestPrincipalArn(t *testing.T) {
+	tests := map[string]string{
+		"arn:aws:sts::123456789012:assumed-role/reporting-task/1f2e3d": "arn:aws:iam::123456789012:role/reporting-task",
+		"arn:aws:iam::123456789012:user/someone":                       "arn:aws:iam::123456789012:user/someone",
+		"arn:aws:sts::123456789012:federated-user/someone":             "arn:aws:sts::123456789012:federated-user/someone",
+	}
+	for arn, want := range tests {
+		if got := principalArn(arn); got != want {
+			t.Errorf("principalArn(%s) = %s, want %s", arn, got, want)
+		}
+	}
+}
+
+func TestNewIamVerifier(t *testing.T) {
+	if NewIamVerifier(&config.Config{}) != nil {
+		t.Error("expected no verifier without allowed principals")
+	}
+	verifier := NewIamVerifier(&config.Config{AWSRegion: "ap-southeast-1", IamAuthAllowedPrincipals: []string{"arn:aws:iam::123456789012:role/x"}})
+	if verifier == nil || verifier.stsEndpoint != "https://sts.ap-southeast-1.amazonaws.com/" {
+		t.Errorf("expected the STS endpoint of the region, got %+v", verifier)
+	}
+}
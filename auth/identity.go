@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"teletubpax-api/config"
+)
+
+// Identity is the caller of a request with a verified bearer token
+type Identity struct {
+	UserId   string // The AUTH_USER_CLAIM claim, "sub" by default
+	Username string // cognito:username of ID tokens or username of access tokens, may be empty
+	Email    string // May be empty
+	Claims   Claims
+}
+
+type identityKey struct{}
+
+// WithIdentity attaches the caller's identity to the request context
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the caller's identity, nil for anonymous callers
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// UserIdFromContext returns the caller's user ID, empty for anonymous callers
+func UserIdFromContext(ctx context.Context) string {
+	if identity := IdentityFromContext(ctx); identity != nil {
+		return identity.UserId
+	}
+	return ""
+}
+
+// Authenticator maps verified bearer tokens to identities
+type Authenticator struct {
+	verifier  *Verifier
+	userClaim string
+	required  bool
+}
+
+// NewAuthenticator builds the authenticator from the configuration, nil when AUTH_JWKS_URL
+// is not set
+func NewAuthenticator(cfg *config.Config) *Authenticator {
+	if cfg.AuthJwksUrl == "" {
+		return nil
+	}
+	userClaim := cfg.AuthUserClaim
+	if userClaim == "" {
+		userClaim = "sub"
+	}
+	return &Authenticator{
+		verifier:  NewVerifier(cfg.AuthJwksUrl, cfg.AuthIssuer, cfg.AuthAudience),
+		userClaim: userClaim,
+		required:  cfg.AuthRequired,
+	}
+}
+
+// Required reports whether requests without a token are rejected
+func (a *Authenticator) Required() bool {
+	return a.required
+}
+
+// Authenticate verifies the token and returns the identity in its claims. Tokens without
+// the user claim are rejected, the user ID keys audit logs and per-user limits.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	claims, err := a.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	userId, _ := claims[a.userClaim].(string)
+	if userId == "" {
+		return nil, fmt.Errorf("token has no %s claim", a.userClaim)
+	}
+	username, _ := claims["cognito:username"].(string)
+	if username == "" {
+		username, _ = claims["username"].(string)
+	}
+	email, _ := claims["email"].(string)
+	return &Identity{
+		UserId:   userId,
+		Username: username,
+		Email:    email,
+		Claims:   claims,
+	}, nil
+}
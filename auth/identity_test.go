@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+)
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	issuer := newTestIssuer(t)
+	authenticator := NewAuthenticator(&config.Config{AuthJwksUrl: issuer.server.URL, AuthRequired: true})
+	if !authenticator.Required() {
+		t.Error("expected tokens to be required")
+	}
+
+	identity, err := authenticator.Authenticate(context.Background(), issuer.sign(t, "key-1", map[string]interface{}{
+		"sub":              "0f1e2d3c",
+		"cognito:username": "somchai",
+		"email":            "somchai@example.com",
+		"exp":              time.Now().Add(time.Hour).Unix(),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity.UserId != "0f1e2d3c" || identity.Username != "somchai" || identity.Email != "somchai@example.com" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	ctx := WithIdentity(context.Background(), identity)
+	if UserIdFromContext(ctx) != "0f1e2d3c" || UserIdFromContext(context.Background()) != "" {
+		t.Error("expected the user ID of the identity in the context only")
+	}
+
+	accessToken := issuer.sign(t, "key-1", map[string]interface{}{
+		"username": "somchai",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	if _, err := authenticator.Authenticate(context.Background(), accessToken); err == nil {
+		t.Error("expected a token without the user claim to be rejected")
+	}
+
+	byUsername := NewAuthenticator(&config.Config{AuthJwksUrl: issuer.server.URL, AuthUserClaim: "username"})
+	if identity, err := byUsername.Authenticate(context.Background(), accessToken); err != nil || identity.UserId != "somchai" {
+		t.Errorf("expected the configured user claim, got %+v %v", identity, err)
+	}
+
+	if NewAuthenticator(&config.Config{}) != nil {
+		t.Error("expected no authenticator without AUTH_JWKS_URL")
+	}
+}
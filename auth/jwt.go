@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksRefreshInterval is how long fetched signing keys are used before they are fetched
+	// again, so rotated keys are picked up
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval bounds refetches for tokens signed with an unknown key
+	jwksMinRefreshInterval = time.Minute
+	// clockSkew is the leeway for exp and nbf
+	clockSkew = time.Minute
+)
+
+// Claims are the claims of a verified token
+type Claims map[string]interface{}
+
+// Verifier verifies RS256 JWTs against the signing keys published at a JWKS URL, e.g. a
+// Cognito user pool's /.well-known/jwks.json
+type Verifier struct {
+	jwksUrl    string
+	issuer     string // Optional, the iss claim must match when set
+	audience   string // Optional, the aud claim, or client_id of Cognito access tokens, must contain it when set
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey // By key ID
+	fetchedAt time.Time
+	fetching  chan struct{} // Closed when the key set fetch in flight is done, nil without one
+}
+
+func NewVerifier(jwksUrl string, issuer string, audience string) *Verifier {
+	return &Verifier{
+		jwksUrl:    jwksUrl,
+		issuer:     issuer,
+		audience:   audience,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Verify checks the token's signature, expiry, issuer and audience and returns its claims
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err := v.validateClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) validateClaims(claims Claims, now time.Time) error {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token not yet valid")
+	}
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return fmt.Errorf("unexpected token issuer")
+	}
+
+	// Cognito issues ID tokens and access tokens, the latter with the app client in client_id
+	// instead of aud
+	audienceClaim := "aud"
+	if tokenUse, ok := claims["token_use"]; ok {
+		switch tokenUse {
+		case "id":
+		case "access":
+			audienceClaim = "client_id"
+		default:
+			return fmt.Errorf("unexpected token use %v", tokenUse)
+		}
+	}
+	if v.audience != "" && !containsClaim(claims[audienceClaim], v.audience) {
+		return fmt.Errorf("unexpected token audience")
+	}
+	return nil
+}
+
+// key returns the signing key with the ID, fetching the key set when it is stale or does
+// not know the key yet. A single fetch runs at a time, without holding the lock: requests
+// with a cached key keep using it meanwhile, the others wait for the fetch.
+func (v *Verifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	key, known := v.keys[kid]
+	age := time.Since(v.fetchedAt)
+	if (known && age < jwksRefreshInterval) || (!known && age < jwksMinRefreshInterval) {
+		v.mu.Unlock()
+		if !known {
+			return nil, fmt.Errorf("unknown token signing key %q", kid)
+		}
+		return key, nil
+	}
+
+	if fetching := v.fetching; fetching != nil {
+		v.mu.Unlock()
+		if known {
+			return key, nil
+		}
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		v.mu.Lock()
+		key, known = v.keys[kid]
+		v.mu.Unlock()
+		if !known {
+			return nil, fmt.Errorf("unknown token signing key %q", kid)
+		}
+		return key, nil
+	}
+	fetching := make(chan struct{})
+	v.fetching = fetching
+	v.mu.Unlock()
+
+	// The fetch serves the requests waiting for it too, so it outlives this one
+	keys, err := v.fetchKeys(context.WithoutCancel(ctx))
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.fetching = nil
+	close(fetching)
+	if err != nil {
+		if known {
+			return key, nil // Keep using the cached key while the JWKS URL is unavailable
+		}
+		return nil, err
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	if key, known = keys[kid]; !known {
+		return nil, fmt.Errorf("unknown token signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS URL: %w", err)
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch signing keys: status %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("failed to parse signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// Roles returns the roles in a claim, either a list or a string of roles separated by
+// spaces or commas. Cognito puts groups in "cognito:groups".
+func (c Claims) Roles(claim string) []string {
+	switch value := c[claim].(type) {
+	case string:
+		return strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' })
+	case []interface{}:
+		roles := make([]string, 0, len(value))
+		for _, role := range value {
+			if s, ok := role.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON token segment
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// containsClaim reports whether a string or list claim contains the value
+func containsClaim(claim interface{}, value string) bool {
+	switch claim := claim.(type) {
+	case string:
+		return claim == value
+	case []interface{}:
+		for _, item := range claim {
+			if item == value {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testIssuer signs tokens and publishes its key as a JWKS
+type testIssuer struct {
+	key     *rsa.PrivateKey
+	kid     string
+	server  *httptest.Server
+	hits    atomic.Int32
+	release chan struct{} // JWKS requests wait for it when set
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	issuer := &testIssuer{key: key, kid: "key-1"}
+	issuer.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.hits.Add(1)
+		if issuer.release != nil {
+			<-issuer.release
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": issuer.kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifier_AcceptsValidTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := NewVerifier(issuer.server.URL, "https://idp.example.com", "teletubpax")
+
+	token := issuer.sign(t, "key-1", map[string]interface{}{
+		"iss":            "https://idp.example.com",
+		"aud":            []string{"teletubpax", "other"},
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"cognito:groups": []string{"compliance", "retail"},
+	})
+	claims, err := verifier.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if roles := claims.Roles("cognito:groups"); len(roles) != 2 || roles[0] != "compliance" {
+		t.Errorf("expected the groups as roles, got %v", roles)
+	}
+
+	// The key set is cached between tokens
+	verifier.Verify(context.Background(), token)
+	if issuer.hits.Load() != 1 {
+		t.Errorf("expected one JWKS fetch, got %d", issuer.hits.Load())
+	}
+}
+
+func TestVerifier_FetchesKeysOnceForConcurrentRequests(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.release = make(chan struct{})
+	verifier := NewVerifier(issuer.server.URL, "", "")
+	token := issuer.sign(t, "key-1", map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()})
+
+	errs := make(chan error, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := verifier.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(issuer.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if issuer.hits.Load() != 1 {
+		t.Errorf("expected a single JWKS fetch, got %d", issuer.hits.Load())
+	}
+
+	// A stale key set is refreshed while requests keep using the cached key
+	issuer.release = make(chan struct{})
+	verifier.fetchedAt = time.Now().Add(-2 * jwksRefreshInterval)
+	done := make(chan struct{})
+	go func() {
+		verifier.Verify(context.Background(), token)
+		close(done)
+	}()
+	for issuer.hits.Load() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Errorf("expected the cached key during the refresh, got %v", err)
+	}
+	close(issuer.release)
+	<-done
+}
+
+func TestVerifier_ChecksCognitoTokenUse(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := NewVerifier(issuer.server.URL, "", "app-client")
+	exp := time.Now().Add(time.Hour).Unix()
+
+	accepted := map[string]map[string]interface{}{
+		"ID token":     {"token_use": "id", "aud": "app-client", "exp": exp},
+		"access token": {"token_use": "access", "client_id": "app-client", "exp": exp},
+	}
+	for name, claims := range accepted {
+		if _, err := verifier.Verify(context.Background(), issuer.sign(t, "key-1", claims)); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+
+	rejected := map[string]map[string]interface{}{
+		"access token of another client": {"token_use": "access", "client_id": "other", "aud": "app-client", "exp": exp},
+		"ID token of another client":     {"token_use": "id", "aud": "other", "client_id": "app-client", "exp": exp},
+		"unknown token use":              {"token_use": "refresh", "aud": "app-client", "exp": exp},
+	}
+	for name, claims := range rejected {
+		if _, err := verifier.Verify(context.Background(), issuer.sign(t, "key-1", claims)); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+}
+
+func TestVerifier_RejectsInvalidTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := NewVerifier(issuer.server.URL, "https://idp.example.com", "teletubpax")
+	valid := map[string]interface{}{
+		"iss": "https://idp.example.com",
+		"aud": "teletubpax",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := map[string]interface{}{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+
+	tokens := map[string]string{
+		"expired":        issuer.sign(t, "key-1", with("exp", time.Now().Add(-time.Hour).Unix())),
+		"wrong issuer":   issuer.sign(t, "key-1", with("iss", "https://evil.example.com")),
+		"wrong audience": issuer.sign(t, "key-1", with("aud", "other")),
+		"unknown key":    issuer.sign(t, "key-2", valid),
+		"malformed":      "not-a-token",
+	}
+	tampered := issuer.sign(t, "key-1", valid)
+	tokens["tampered"] = tampered[:len(tampered)-4] + "AAAA"
+
+	for name, token := range tokens {
+		if _, err := verifier.Verify(context.Background(), token); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+}
+
+func TestClaims_Roles(t *testing.T) {
+	claims := Claims{"roles": "compliance, retail hr", "groups": []interface{}{"a", 1, "b"}}
+	if roles := claims.Roles("roles"); len(roles) != 3 || roles[2] != "hr" {
+		t.Errorf("expected roles split on spaces and commas, got %v", roles)
+	}
+	if roles := claims.Roles("groups"); len(roles) != 2 || roles[1] != "b" {
+		t.Errorf("expected the string roles of a list, got %v", roles)
+	}
+	if roles := claims.Roles("missing"); roles != nil {
+		t.Errorf("expected no roles for a missing claim, got %v", roles)
+	}
+}
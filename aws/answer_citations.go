@@ -0,0 +1,150 @@
+package aws
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"teletubpax-api/utils"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+// maxCitationExcerptLength caps the characters of the cited chunk returned with an answer
+const maxCitationExcerptLength = 500
+
+// Citation ties a part of an answer to the document chunks it came from
+type Citation struct {
+	Text    string           `json:"text"`           // The cited part of the answer
+	Span    *CitationSpan    `json:"span,omitempty"` // Where the text is in the answer, omitted when the answer reworded it, e.g. translated
+	Sources []CitationSource `json:"sources"`
+}
+
+// CitationSpan is a range of characters of an answer
+type CitationSpan struct {
+	Start int `json:"start"` // Offset of the first character
+	End   int `json:"end"`   // Offset after the last character
+}
+
+// CitationSource is a chunk of a document that backs a citation
+type CitationSource struct {
+	DocumentUrl string `json:"documentUrl"`
+	PageNumber  int    `json:"pageNumber,omitempty"`
+	ChunkId     string `json:"chunkId,omitempty"`
+	Excerpt     string `json:"excerpt"`
+}
+
+// CitationCollector collects the citations of the knowledge base answers given while
+// serving one request
+type CitationCollector struct {
+	mu        sync.Mutex
+	citations []Citation
+}
+
+type citationsKey struct{}
+
+// WithCitationCollector attaches a new collector to the context of a request
+func WithCitationCollector(ctx context.Context) (context.Context, *CitationCollector) {
+	collector := &CitationCollector{}
+	return context.WithValue(ctx, citationsKey{}, collector), collector
+}
+
+// CitationCollectorFromContext returns the collector of the request, nil without one
+func CitationCollectorFromContext(ctx context.Context) *CitationCollector {
+	collector, _ := ctx.Value(citationsKey{}).(*CitationCollector)
+	return collector
+}
+
+// RecordCitations adds citations to the request's collector. It does nothing without a
+// collector.
+func RecordCitations(ctx context.Context, citations []Citation) {
+	collector := CitationCollectorFromContext(ctx)
+	if collector == nil || len(citations) == 0 {
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.citations = append(collector.citations, citations...)
+}
+
+// List returns the collected citations
+func (c *CitationCollector) List() []Citation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Citation(nil), c.citations...)
+}
+
+// LocateCitations sets the span of every citation whose text is part of the answer, and
+// orders them as they appear in it. Citations the answer does not quote, e.g. from a
+// knowledge base answer merged away or translated, come last without a span.
+func LocateCitations(answer string, citations []Citation) []Citation {
+	located := make([]Citation, len(citations))
+	for i, citation := range citations {
+		citation.Span = nil
+		if index := strings.Index(answer, citation.Text); index >= 0 {
+			start := utf8.RuneCountInString(answer[:index])
+			citation.Span = &CitationSpan{Start: start, End: start + utf8.RuneCountInString(citation.Text)}
+		}
+		located[i] = citation
+	}
+
+	sort.SliceStable(located, func(i, j int) bool {
+		if located[i].Span == nil || located[j].Span == nil {
+			return located[i].Span != nil && located[j].Span == nil
+		}
+		return located[i].Span.Start < located[j].Span.Start
+	})
+	return located
+}
+
+// answerCitations converts the citations of a RetrieveAndGenerate answer. The cited text
+// is cleaned like the answer, so it can be found in it.
+func (c *BedrockKBClient) answerCitations(ctx context.Context, citations []types.Citation) []Citation {
+	var converted []Citation
+	for _, citation := range citations {
+		if citation.GeneratedResponsePart == nil || citation.GeneratedResponsePart.TextResponsePart == nil ||
+			citation.GeneratedResponsePart.TextResponsePart.Text == nil {
+			continue
+		}
+		text := utils.CleanMarkdown(*citation.GeneratedResponsePart.TextResponsePart.Text)
+		if text == "" {
+			continue
+		}
+
+		var sources []CitationSource
+		for _, ref := range citation.RetrievedReferences {
+			if ref.Location == nil || ref.Location.S3Location == nil || ref.Location.S3Location.Uri == nil {
+				continue
+			}
+			source := CitationSource{DocumentUrl: c.documentLinker.DocumentLink(ctx, *ref.Location.S3Location.Uri)}
+			if ref.Content != nil && ref.Content.Text != nil {
+				source.Excerpt = excerpt(*ref.Content.Text)
+			}
+			if value, ok := ref.Metadata["x-amz-bedrock-kb-chunk-id"]; ok {
+				value.UnmarshalSmithyDocument(&source.ChunkId)
+			}
+			if value, ok := ref.Metadata["x-amz-bedrock-kb-document-page-number"]; ok {
+				var page float64
+				value.UnmarshalSmithyDocument(&page)
+				source.PageNumber = int(page)
+			}
+			sources = append(sources, source)
+		}
+		if len(sources) > 0 {
+			converted = append(converted, Citation{Text: text, Sources: sources})
+		}
+	}
+	return converted
+}
+
+// excerpt shortens a cited chunk to maxCitationExcerptLength characters
+func excerpt(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(content) <= maxCitationExcerptLength {
+		return content
+	}
+	return string([]rune(content)[:maxCitationExcerptLength]) + "…"
+}
@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+func TestAnswerCitations(t *testing.T) {
+	client := &BedrockKBClient{region: "ap-southeast-1", documentLinker: NewPublicDocumentLinker("ap-southeast-1")}
+	citations := []types.Citation{
+		{
+			GeneratedResponsePart: &types.GeneratedResponsePart{
+				TextResponsePart: &types.TextResponsePart{Text: aws.String("ค่าธรรมเนียม **200 บาท** ต่อปี\n")},
+			},
+			RetrievedReferences: []types.RetrievedReference{{
+				Content:  &types.RetrievalResultContent{Text: aws.String("ค่าธรรมเนียมรายปี\n  200 บาท")},
+				Location: &types.RetrievalResultLocation{S3Location: &types.RetrievalResultS3Location{Uri: aws.String("s3://docs/2025/01/fees.pdf")}},
+				Metadata: map[string]document.Interface{
+					"x-amz-bedrock-kb-document-page-number": document.NewLazyDocument(3.0),
+					"x-amz-bedrock-kb-chunk-id":             document.NewLazyDocument("chunk-1"),
+				},
+			}},
+		},
+		// Without a document to point to
+		{
+			GeneratedResponsePart: &types.GeneratedResponsePart{
+				TextResponsePart: &types.TextResponsePart{Text: aws.String("uncited")},
+			},
+		},
+	}
+
+	got := client.answerCitations(context.Background(), citations)
+	if len(got) != 1 {
+		t.Fatalf("expected one citation, got %+v", got)
+	}
+	if got[0].Text != "ค่าธรรมเนียม 200 บาท ต่อปี" {
+		t.Errorf("expected the cited text cleaned like the answer, got %q", got[0].Text)
+	}
+	want := CitationSource{
+		DocumentUrl: "https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/fees.pdf",
+		PageNumber:  3,
+		ChunkId:     "chunk-1",
+		Excerpt:     "ค่าธรรมเนียมรายปี 200 บาท",
+	}
+	if len(got[0].Sources) != 1 || got[0].Sources[0] != want {
+		t.Errorf("unexpected sources %+v", got[0].Sources)
+	}
+}
+
+func TestLocateCitations(t *testing.T) {
+	answer := "บัตรเดบิต: ค่าธรรมเนียม 200 บาท ต่อปี ยกเว้นปีแรก"
+	citations := []Citation{
+		{Text: "ยกเว้นปีแรก"},
+		{Text: "annual fee waived"},
+		{Text: "ค่าธรรมเนียม 200 บาท ต่อปี", Span: &CitationSpan{Start: 0, End: 1}},
+	}
+
+	got := LocateCitations(answer, citations)
+	if got[0].Text != citations[2].Text || got[0].Span == nil || *got[0].Span != (CitationSpan{Start: 11, End: 37}) {
+		t.Errorf("expected the first part of the answer first, got %+v", got[0])
+	}
+	if got[1].Span == nil || *got[1].Span != (CitationSpan{Start: 38, End: 49}) {
+		t.Errorf("unexpected span %+v", got[1].Span)
+	}
+	if got[2].Text != "annual fee waived" || got[2].Span != nil {
+		t.Errorf("expected the unquoted citation last without a span, got %+v", got[2])
+	}
+	if citations[0].Span != nil {
+		t.Error("expected the recorded citations to stay unchanged")
+	}
+}
+
+func TestCitationCollector(t *testing.T) {
+	// Nothing is recorded without a collector
+	RecordCitations(context.Background(), []Citation{{Text: "a"}})
+
+	ctx, collector := WithCitationCollector(context.Background())
+	RecordCitations(ctx, []Citation{{Text: "a"}})
+	RecordCitations(ctx, []Citation{{Text: "b"}})
+	if got := collector.List(); len(got) != 2 || got[1].Text != "b" {
+		t.Errorf("unexpected citations %+v", got)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("ก", maxCitationExcerptLength+10)
+	if got := excerpt(long); len([]rune(got)) != maxCitationExcerptLength+1 || !strings.HasSuffix(got, "…") {
+		t.Errorf("expected the excerpt cut to %d characters, got %d", maxCitationExcerptLength, len([]rune(got)))
+	}
+}
@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"teletubpax-api/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	rttypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+type AnswerComparisonClient interface {
+	CompareAnswers(ctx context.Context, question, answerA, answerB string) (string, error)
+}
+
+// BedrockAnswerComparisonClient asks the generative model to summarize how two answers to
+// the same question differ
+type BedrockAnswerComparisonClient struct {
+	runtimeClient     *bedrockruntime.Client
+	generativeModelId func() string
+	instructions      func() string
+}
+
+func NewBedrockAnswerComparisonClient(cfg aws.Config, generativeModelId func() string, instructions func() string) *BedrockAnswerComparisonClient {
+	return &BedrockAnswerComparisonClient{
+		runtimeClient:     bedrockruntime.NewFromConfig(cfg),
+		generativeModelId: generativeModelId,
+		instructions:      instructions,
+	}
+}
+
+func (c *BedrockAnswerComparisonClient) CompareAnswers(ctx context.Context, question, answerA, answerB string) (string, error) {
+	userMessage := fmt.Sprintf(`%s
+
+Question: %s
+
+Answer A (current):
+%s
+
+Answer B (candidate):
+%s`, c.instructions(), question, answerA, answerB)
+
+	generativeModelId := c.generativeModelId()
+
+	output, err := c.runtimeClient.Converse(ctx, &bedrockruntime.ConverseInput{
+		// Get the model identifier of the region (inference profile for Claude Haiku)
+		ModelId: aws.String(invocationModelId(generativeModelId)),
+		Messages: []rttypes.Message{
+			{
+				Role: rttypes.ConversationRoleUser,
+				Content: []rttypes.ContentBlock{
+					&rttypes.ContentBlockMemberText{
+						Value: userMessage,
+					},
+				},
+			},
+		},
+		InferenceConfig: &rttypes.InferenceConfiguration{
+			MaxTokens:   aws.Int32(1024),
+			Temperature: aws.Float32(0.0), // Deterministic comparisons for repeatable spot checks
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("answer comparison converse API failed: %w", err)
+	}
+	recordConverseUsage(ctx, generativeModelId, output.Usage)
+
+	if msg, ok := output.Output.(*rttypes.ConverseOutputMemberMessage); ok && len(msg.Value.Content) > 0 {
+		if textBlock, ok := msg.Value.Content[0].(*rttypes.ContentBlockMemberText); ok {
+			return utils.CleanMarkdown(textBlock.Value), nil
+		}
+	}
+
+	return "", fmt.Errorf("no answer comparison output received")
+}
@@ -0,0 +1,117 @@
+package aws
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+// confidenceRetrievalResults is how many chunks are retrieved to score how well the
+// documents match a question; only the best score is used
+const confidenceRetrievalResults = 1
+
+// ConfidenceRecorder collects the confidence of the knowledge base answers given while
+// serving one request
+type ConfidenceRecorder struct {
+	mu       sync.Mutex
+	answered []float64
+	recorded bool
+}
+
+type confidenceKey struct{}
+
+// WithConfidenceRecorder attaches a new recorder to the context of a request. Knowledge base
+// answers are only scored when the context has a recorder, as scoring costs a retrieval.
+func WithConfidenceRecorder(ctx context.Context) (context.Context, *ConfidenceRecorder) {
+	recorder := &ConfidenceRecorder{}
+	return context.WithValue(ctx, confidenceKey{}, recorder), recorder
+}
+
+// ConfidenceRecorderFromContext returns the recorder of the request, nil without one
+func ConfidenceRecorderFromContext(ctx context.Context) *ConfidenceRecorder {
+	recorder, _ := ctx.Value(confidenceKey{}).(*ConfidenceRecorder)
+	return recorder
+}
+
+// RecordConfidence records the confidence of an answer on the request's recorder. An answer
+// that found nothing is recorded as unanswered. It does nothing without a recorder.
+func RecordConfidence(ctx context.Context, confidence float64, answered bool) {
+	recorder := ConfidenceRecorderFromContext(ctx)
+	if recorder == nil {
+		return
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.recorded = true
+	if answered {
+		recorder.answered = append(recorder.answered, confidence)
+	}
+}
+
+// Confidence returns the confidence of the final answer between 0 and 1: that of the best
+// supported knowledge base answer, 0 when no knowledge base had an answer. ok is false when
+// no answer was scored, e.g. for answer backends other than the knowledge base.
+func (r *ConfidenceRecorder) Confidence() (confidence float64, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.recorded {
+		return 0, false
+	}
+	for _, answered := range r.answered {
+		confidence = math.Max(confidence, answered)
+	}
+	return math.Round(confidence*100) / 100, true
+}
+
+// answerConfidence rates a knowledge base answer between 0 and 1 by the best retrieval score,
+// how closely the documents match the question, and the share of the answer backed by
+// citations, weighted equally. Without a retrieval score only the citations count.
+func answerConfidence(retrievalScore float64, scored bool, citationCoverage float64) float64 {
+	if !scored {
+		return citationCoverage
+	}
+	return (math.Min(math.Max(retrievalScore, 0), 1) + citationCoverage) / 2
+}
+
+// citationCoverage returns the share of the generated text, in characters, that citations
+// attribute to retrieved documents
+func citationCoverage(text string, citations []types.Citation) float64 {
+	length := len([]rune(text))
+	if length == 0 {
+		return 0
+	}
+
+	type span struct{ start, end int }
+	var spans []span
+	for _, citation := range citations {
+		if len(citation.RetrievedReferences) == 0 || citation.GeneratedResponsePart == nil ||
+			citation.GeneratedResponsePart.TextResponsePart == nil || citation.GeneratedResponsePart.TextResponsePart.Span == nil {
+			continue
+		}
+		s := citation.GeneratedResponsePart.TextResponsePart.Span
+		if s.Start == nil || s.End == nil {
+			continue
+		}
+		// Bedrock spans are inclusive
+		start, end := int(*s.Start), min(int(*s.End)+1, length)
+		if start >= 0 && start < end {
+			spans = append(spans, span{start, end})
+		}
+	}
+
+	// Overlapping citations cover their characters once
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	covered, last := 0, 0
+	for _, s := range spans {
+		start := max(s.start, last)
+		if s.end > start {
+			covered += s.end - start
+			last = s.end
+		}
+	}
+	return float64(covered) / float64(length)
+}
@@ -0,0 +1,82 @@
+package aws
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+func citation(start, end int32) types.Citation {
+	return types.Citation{
+		GeneratedResponsePart: &types.GeneratedResponsePart{
+			TextResponsePart: &types.TextResponsePart{
+				Span: &types.Span{Start: aws.Int32(start), End: aws.Int32(end)},
+			},
+		},
+		RetrievedReferences: []types.RetrievedReference{{}},
+	}
+}
+
+func TestCitationCoverage(t *testing.T) {
+	text := "0123456789" // 10 characters
+	tests := []struct {
+		name      string
+		citations []types.Citation
+		want      float64
+	}{
+		{"no citations", nil, 0},
+		{"whole text", []types.Citation{citation(0, 9)}, 1},
+		{"half", []types.Citation{citation(0, 4)}, 0.5},
+		{"overlapping", []types.Citation{citation(0, 4), citation(2, 6)}, 0.7},
+		{"past the end", []types.Citation{citation(5, 20)}, 0.5},
+		{"without references", []types.Citation{{GeneratedResponsePart: citation(0, 9).GeneratedResponsePart}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := citationCoverage(text, tt.citations); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("citationCoverage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Spans count characters, not bytes
+	if got := citationCoverage("ค่าธรรมเนียม", []types.Citation{citation(0, 11)}); got != 1 {
+		t.Errorf("expected Thai text to be fully covered, got %v", got)
+	}
+}
+
+func TestAnswerConfidence(t *testing.T) {
+	if got := answerConfidence(0.8, true, 0.6); math.Abs(got-0.7) > 1e-9 {
+		t.Errorf("expected the average of score and coverage, got %v", got)
+	}
+	if got := answerConfidence(1.4, true, 1); got != 1 {
+		t.Errorf("expected the score to be capped at 1, got %v", got)
+	}
+	if got := answerConfidence(0, false, 0.6); got != 0.6 {
+		t.Errorf("expected only the coverage without a score, got %v", got)
+	}
+}
+
+func TestConfidenceRecorder(t *testing.T) {
+	// Nothing is recorded without a recorder
+	RecordConfidence(context.Background(), 0.9, true)
+
+	ctx, recorder := WithConfidenceRecorder(context.Background())
+	if _, ok := recorder.Confidence(); ok {
+		t.Error("expected no confidence before an answer was scored")
+	}
+
+	RecordConfidence(ctx, 0.9, false)
+	if confidence, ok := recorder.Confidence(); !ok || confidence != 0 {
+		t.Errorf("expected 0 when no knowledge base answered, got %v %v", confidence, ok)
+	}
+
+	RecordConfidence(ctx, 0.456, true)
+	RecordConfidence(ctx, 0.3, true)
+	if confidence, ok := recorder.Confidence(); !ok || confidence != 0.46 {
+		t.Errorf("expected the best answer's confidence, got %v %v", confidence, ok)
+	}
+}
@@ -0,0 +1,54 @@
+package aws
+
+import (
+	"context"
+	"strings"
+
+	"teletubpax-api/utils"
+)
+
+// NoAnswerText is returned when no knowledge base produced an answer to a Thai question
+const NoAnswerText = "ไม่พบคำตอบที่เกี่ยวข้องกับคำถามของคุณ"
+
+// NoAnswerTextEnglish is returned when no knowledge base produced an answer to an English
+// question
+const NoAnswerTextEnglish = "No answer related to your question was found."
+
+// What the question search prompts tell the model to answer with when the knowledge base
+// has nothing relevant
+const (
+	noInformationText        = "ไม่พบข้อมูลในระบบ"
+	noInformationTextEnglish = "Information not found in system"
+)
+
+// NoAnswerFor returns the no-answer text in the language of the question
+func NoAnswerFor(language string) string {
+	if language == utils.LanguageEnglish {
+		return NoAnswerTextEnglish
+	}
+	return NoAnswerText
+}
+
+// IsNoAnswer reports whether an answer means the knowledge bases had nothing relevant
+func IsNoAnswer(answer string) bool {
+	answer = strings.TrimSpace(answer)
+	return answer == "" || answer == NoAnswerText || answer == NoAnswerTextEnglish ||
+		strings.HasPrefix(answer, noInformationText) || strings.HasPrefix(answer, noInformationTextEnglish)
+}
+
+type questionLanguageKey struct{}
+
+// WithQuestionLanguage attaches the language of the question to the context, so the
+// knowledge base client prompts and answers "nothing found" in that language
+func WithQuestionLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, questionLanguageKey{}, language)
+}
+
+// QuestionLanguageFromContext returns the language of the question, Thai when it was not
+// detected
+func QuestionLanguageFromContext(ctx context.Context) string {
+	if language, ok := ctx.Value(questionLanguageKey{}).(string); ok && language != "" {
+		return language
+	}
+	return utils.LanguageThai
+}
@@ -0,0 +1,30 @@
+package aws
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNoAnswerFor(t *testing.T) {
+	if NoAnswerFor("en") != NoAnswerTextEnglish || NoAnswerFor("th") != NoAnswerText {
+		t.Errorf("expected the no-answer text in the language of the question")
+	}
+	for _, answer := range []string{NoAnswerText, NoAnswerTextEnglish, "ไม่พบข้อมูลในระบบ", "Information not found in system.", " "} {
+		if !IsNoAnswer(answer) {
+			t.Errorf("expected %q to be a no-answer", answer)
+		}
+	}
+	if IsNoAnswer("The annual fee is 500 baht.") {
+		t.Error("expected an answer not to be a no-answer")
+	}
+}
+
+func TestQuestionLanguageFromContext(t *testing.T) {
+	if language := QuestionLanguageFromContext(context.Background()); language != "th" {
+		t.Errorf("expected Thai without a detected language, got %q", language)
+	}
+	ctx := WithQuestionLanguage(context.Background(), "en")
+	if language := QuestionLanguageFromContext(ctx); language != "en" {
+		t.Errorf("expected the language of the context, got %q", language)
+	}
+}
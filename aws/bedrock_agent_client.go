@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"teletubpax-api/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+type AgentClient interface {
+	// InvokeAgent sends a question to the agent within a conversation session and returns
+	// the answer with the public URLs of the documents it cited
+	InvokeAgent(ctx context.Context, sessionId string, question string) (string, []string, error)
+}
+
+// BedrockAgentClient answers questions through a Bedrock Agent, which decides on its own
+// which knowledge bases and action groups to use. Callers with restricted document access
+// get the retrieval filter on the agent's knowledge bases, which must be knowledgeBaseIds.
+// The search type is set on the same knowledge bases.
+type BedrockAgentClient struct {
+	client           *bedrockagentruntime.Client
+	agentId          string
+	agentAliasId     string
+	knowledgeBaseIds func() []string
+	documentLinker   DocumentLinker
+	searchType       string // Optional, "HYBRID" or "SEMANTIC" unless the request asks for another
+}
+
+func NewBedrockAgentClient(cfg aws.Config, agentId string, agentAliasId string, knowledgeBaseIds func() []string, documentLinker DocumentLinker, searchType string) *BedrockAgentClient {
+	return &BedrockAgentClient{
+		client:           bedrockagentruntime.NewFromConfig(cfg),
+		agentId:          agentId,
+		agentAliasId:     agentAliasId,
+		knowledgeBaseIds: knowledgeBaseIds,
+		documentLinker:   documentLinker,
+		searchType:       searchType,
+	}
+}
+
+func (c *BedrockAgentClient) InvokeAgent(ctx context.Context, sessionId string, question string) (string, []string, error) {
+	input := &bedrockagentruntime.InvokeAgentInput{
+		AgentId:      aws.String(c.agentId),
+		AgentAliasId: aws.String(c.agentAliasId),
+		SessionId:    aws.String(sessionId),
+		InputText:    aws.String(question),
+	}
+	filter, searchType := retrievalFilter(ctx, nil), overrideSearchType(ctx, c.searchType)
+	if filter != nil || searchType != "" {
+		sessionState := &types.SessionState{}
+		for _, knowledgeBaseId := range c.knowledgeBaseIds() {
+			sessionState.KnowledgeBaseConfigurations = append(sessionState.KnowledgeBaseConfigurations, types.KnowledgeBaseConfiguration{
+				KnowledgeBaseId: aws.String(knowledgeBaseId),
+				RetrievalConfiguration: &types.KnowledgeBaseRetrievalConfiguration{
+					VectorSearchConfiguration: &types.KnowledgeBaseVectorSearchConfiguration{
+						Filter:             filter,
+						OverrideSearchType: searchType,
+					},
+				},
+			})
+		}
+		input.SessionState = sessionState
+	}
+
+	output, err := c.client.InvokeAgent(ctx, input)
+	if err != nil {
+		return "", nil, fmt.Errorf("invoke agent failed: %w", err)
+	}
+
+	stream := output.GetStream()
+	defer stream.Close()
+
+	// The answer arrives as a stream of chunks, each with the citations of its text
+	var answer strings.Builder
+	var documents []string
+	documentSet := make(map[string]bool)
+	for event := range stream.Events() {
+		chunk, ok := event.(*types.ResponseStreamMemberChunk)
+		if !ok {
+			continue
+		}
+		answer.Write(chunk.Value.Bytes)
+		if chunk.Value.Attribution == nil {
+			continue
+		}
+		for _, citation := range chunk.Value.Attribution.Citations {
+			for _, reference := range citation.RetrievedReferences {
+				if reference.Location == nil || reference.Location.S3Location == nil || reference.Location.S3Location.Uri == nil {
+					continue
+				}
+				s3Uri := *reference.Location.S3Location.Uri
+				if !documentSet[s3Uri] {
+					documentSet[s3Uri] = true
+					documents = append(documents, c.documentLinker.DocumentLink(ctx, s3Uri))
+				}
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return "", nil, fmt.Errorf("agent response stream failed: %w", err)
+	}
+
+	return utils.CleanMarkdown(answer.String()), documents, nil
+}
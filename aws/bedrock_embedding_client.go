@@ -1,105 +1,188 @@
-package aws
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"teletubpax-api/errors"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
-)
-
-type EmbeddingClient interface {
-	GenerateEmbedding(ctx context.Context, text string) ([]float64, error)
-}
-
-type BedrockEmbeddingClient struct {
-	client  *bedrockruntime.Client
-	modelId string
-}
-
-func NewBedrockEmbeddingClient(cfg aws.Config, modelId string) *BedrockEmbeddingClient {
-	return &BedrockEmbeddingClient{
-		client:  bedrockruntime.NewFromConfig(cfg),
-		modelId: modelId,
-	}
-}
-
-type titanEmbedRequest struct {
-	InputText string `json:"inputText"`
-}
-
-type titanEmbedResponse struct {
-	Embedding []float64 `json:"embedding"`
-}
-
-func (c *BedrockEmbeddingClient) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
-	request := titanEmbedRequest{
-		InputText: text,
-	}
-
-	requestBody, err := json.Marshal(request)
-	if err != nil {
-		return nil, errors.NewEmbeddingError("failed to marshal embedding request", err)
-	}
-
-	input := &bedrockruntime.InvokeModelInput{
-		ModelId:     aws.String(c.modelId),
-		Body:        requestBody,
-		ContentType: aws.String("application/json"),
-	}
-
-	output, err := c.client.InvokeModel(ctx, input)
-	if err != nil {
-		return nil, c.handleAWSError(err)
-	}
-
-	var response titanEmbedResponse
-	if err := json.Unmarshal(output.Body, &response); err != nil {
-		return nil, errors.NewEmbeddingError("failed to parse embedding response", err)
-	}
-
-	if len(response.Embedding) == 0 {
-		return nil, errors.NewEmbeddingError("empty embedding vector returned", nil)
-	}
-
-	return response.Embedding, nil
-}
-
-func (c *BedrockEmbeddingClient) handleAWSError(err error) error {
-	errMsg := err.Error()
-	
-	if contains(errMsg, "ValidationException") || contains(errMsg, "invalid") {
-		return errors.NewValidationError(fmt.Sprintf("invalid input for embedding: %v", err))
-	}
-	
-	if contains(errMsg, "ThrottlingException") || contains(errMsg, "TooManyRequestsException") {
-		return errors.NewThrottlingError("embedding service throttled", err)
-	}
-	
-	if contains(errMsg, "AccessDeniedException") || contains(errMsg, "UnauthorizedException") {
-		return errors.NewAWSServiceError("invalid or missing AWS credentials", err)
-	}
-	
-	if contains(errMsg, "ServiceUnavailableException") || contains(errMsg, "InternalServerException") {
-		return errors.NewAWSServiceError("embedding service unavailable", err)
-	}
-	
-	return errors.NewEmbeddingError("embedding generation failed", err)
-}
-
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && 
-		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || 
-		findSubstring(s, substr)))
-}
-
-func findSubstring(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"teletubpax-api/errors"
+	"teletubpax-api/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// embeddingConcurrency bounds the parallel InvokeModel calls of one GenerateEmbeddings
+const embeddingConcurrency = 4
+
+type EmbeddingClient interface {
+	GenerateEmbedding(ctx context.Context, text string) ([]float64, error)
+	// GenerateEmbeddings embeds several texts, returning their vectors in the order of the
+	// texts. It fails when any text fails.
+	GenerateEmbeddings(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// BedrockEmbeddingClient embeds texts with the configured model, in the request format of
+// its family: Titan v1, Titan v2 or Cohere. The model is read on every call, so a model
+// reloaded from SSM applies without a restart.
+type BedrockEmbeddingClient struct {
+	client  *bedrockruntime.Client
+	modelId func() string
+	options EmbeddingOptions
+}
+
+func NewBedrockEmbeddingClient(cfg aws.Config, modelId func() string, options EmbeddingOptions) *BedrockEmbeddingClient {
+	return &BedrockEmbeddingClient{
+		client:  bedrockruntime.NewFromConfig(cfg),
+		modelId: modelId,
+		options: options,
+	}
+}
+
+func (c *BedrockEmbeddingClient) GenerateEmbedding(ctx context.Context, text string) (_ []float64, err error) {
+	modelId := c.modelId()
+	ctx, span := tracing.Start(ctx, "BedrockEmbeddingClient.GenerateEmbedding", tracing.AttrModelId.String(modelId))
+	defer func() { tracing.End(span, err) }()
+
+	embeddings, err := c.invoke(ctx, modelId, embeddingFormatFor(modelId, c.options), []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// GenerateEmbeddings embeds the texts in requests of as many texts as the model takes.
+// Titan models take a single text, so their requests run in parallel.
+func (c *BedrockEmbeddingClient) GenerateEmbeddings(ctx context.Context, texts []string) (_ [][]float64, err error) {
+	modelId := c.modelId()
+	format := embeddingFormatFor(modelId, c.options)
+	if format.maxTexts() == 1 {
+		return embedConcurrently(ctx, texts, c.GenerateEmbedding)
+	}
+
+	ctx, span := tracing.Start(ctx, "BedrockEmbeddingClient.GenerateEmbeddings", tracing.AttrModelId.String(modelId))
+	defer func() { tracing.End(span, err) }()
+
+	embeddings := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += format.maxTexts() {
+		batch, err := c.invoke(ctx, modelId, format, texts[start:min(start+format.maxTexts(), len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+// invoke embeds texts, at most the format's maxTexts, in one InvokeModel request
+func (c *BedrockEmbeddingClient) invoke(ctx context.Context, modelId string, format embeddingFormat, texts []string) ([][]float64, error) {
+	requestBody, err := format.request(texts)
+	if err != nil {
+		return nil, errors.NewEmbeddingError("failed to marshal embedding request", err)
+	}
+
+	input := &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(invocationModelId(modelId)),
+		Body:        requestBody,
+		ContentType: aws.String("application/json"),
+	}
+
+	output, err := c.client.InvokeModel(ctx, input)
+	if err != nil {
+		return nil, c.handleAWSError(err)
+	}
+
+	embeddings, inputTokens, estimated, err := format.response(output.Body, texts)
+	if err != nil {
+		return nil, errors.NewEmbeddingError("failed to parse embedding response", err)
+	}
+	RecordUsage(ctx, modelId, inputTokens, 0, estimated)
+
+	for _, embedding := range embeddings {
+		if len(embedding) == 0 {
+			return nil, errors.NewEmbeddingError("empty embedding vector returned", nil)
+		}
+	}
+
+	return embeddings, nil
+}
+
+// embedConcurrently runs embed on up to embeddingConcurrency texts at a time. The first
+// failure cancels the texts not yet embedded and is returned.
+func embedConcurrently(ctx context.Context, texts []string, embed func(context.Context, string) ([]float64, error)) ([][]float64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	embeddings := make([][]float64, len(texts))
+	var wg sync.WaitGroup
+	var failure sync.Once
+	var firstErr error
+	slots := make(chan struct{}, embeddingConcurrency)
+	for i, text := range texts {
+		slots <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			embedding, err := embed(ctx, text)
+			if err != nil {
+				failure.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			embeddings[i] = embedding
+		}(i, text)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+func (c *BedrockEmbeddingClient) handleAWSError(err error) error {
+	errMsg := err.Error()
+	
+	if contains(errMsg, "ValidationException") || contains(errMsg, "invalid") {
+		return errors.NewValidationError(fmt.Sprintf("invalid input for embedding: %v", err))
+	}
+	
+	if contains(errMsg, "ThrottlingException") || contains(errMsg, "TooManyRequestsException") {
+		return errors.NewThrottlingError("embedding service throttled", err)
+	}
+	
+	if contains(errMsg, "AccessDeniedException") || contains(errMsg, "UnauthorizedException") {
+		return errors.NewAWSServiceError("invalid or missing AWS credentials", err)
+	}
+	
+	if contains(errMsg, "ServiceUnavailableException") || contains(errMsg, "InternalServerException") {
+		return errors.NewAWSServiceError("embedding service unavailable", err)
+	}
+	
+	return errors.NewEmbeddingError("embedding generation failed", err)
+}
+
+func contains(s, substr string) bool {
+	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && 
+		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || 
+		findSubstring(s, substr)))
+}
+
+func findSubstring(s, substr string) bool {
+	for i := 0; i <= len(s)-len(substr); i++ {
+		if s[i:i+len(substr)] == substr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"teletubpax-api/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	rttypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+type GenerationClient interface {
+	// Generate answers a single user message under the given system prompt, with the
+	// temperature of the request's generation settings
+	Generate(ctx context.Context, systemPrompt string, userMessage string, maxTokens int) (string, error)
+}
+
+// BedrockGenerationClient calls the generative model directly through the Converse API, for
+// answers generated from context the service assembled itself
+type BedrockGenerationClient struct {
+	runtimeClient     *bedrockruntime.Client
+	generativeModelId func() string
+}
+
+func NewBedrockGenerationClient(cfg aws.Config, generativeModelId func() string) *BedrockGenerationClient {
+	return &BedrockGenerationClient{
+		runtimeClient:     bedrockruntime.NewFromConfig(cfg),
+		generativeModelId: generativeModelId,
+	}
+}
+
+func (c *BedrockGenerationClient) Generate(ctx context.Context, systemPrompt string, userMessage string, maxTokens int) (string, error) {
+	generativeModelId := c.generativeModelId()
+
+	input := &bedrockruntime.ConverseInput{
+		// Get the model identifier of the region (inference profile for Claude Haiku)
+		ModelId: aws.String(invocationModelId(generativeModelId)),
+		Messages: []rttypes.Message{
+			{
+				Role: rttypes.ConversationRoleUser,
+				Content: []rttypes.ContentBlock{
+					&rttypes.ContentBlockMemberText{
+						Value: userMessage,
+					},
+				},
+			},
+		},
+		InferenceConfig: &rttypes.InferenceConfiguration{
+			MaxTokens:   aws.Int32(int32(maxTokens)),
+			Temperature: aws.Float32(GenerationSettingsFromContext(ctx).TemperatureOr(0.3)),
+		},
+	}
+	if systemPrompt != "" {
+		input.System = []rttypes.SystemContentBlock{
+			&rttypes.SystemContentBlockMemberText{Value: systemPrompt},
+		}
+	}
+
+	output, err := converse(ctx, c.runtimeClient, input)
+	if err != nil {
+		return "", fmt.Errorf("generation converse API failed: %w", err)
+	}
+	recordConverseUsage(ctx, generativeModelId, output.Usage)
+
+	if msg, ok := output.Output.(*rttypes.ConverseOutputMemberMessage); ok && len(msg.Value.Content) > 0 {
+		if textBlock, ok := msg.Value.Content[0].(*rttypes.ContentBlockMemberText); ok {
+			return utils.CleanMarkdown(textBlock.Value), nil
+		}
+	}
+
+	return "", fmt.Errorf("no generation output received")
+}
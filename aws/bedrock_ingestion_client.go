@@ -0,0 +1,229 @@
+package aws
+
+import (
+	"context"
+	stdErrors "errors"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagent"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagent/types"
+)
+
+// ErrIngestionJobRunning is returned when an ingestion job is started on a data source that
+// already has one in progress
+var ErrIngestionJobRunning = stdErrors.New("an ingestion job is already running on the data source")
+
+// DataSource is a data source of a knowledge base, which ingestion jobs sync into it
+type DataSource struct {
+	KnowledgeBaseId string     `json:"knowledgeBaseId"`
+	DataSourceId    string     `json:"dataSourceId"`
+	Name            string     `json:"name"`
+	Description     string     `json:"description,omitempty"`
+	Status          string     `json:"status"`
+	UpdatedAt       *time.Time `json:"updatedAt,omitempty"`
+}
+
+// IngestionJob is a sync of a data source into its knowledge base
+type IngestionJob struct {
+	KnowledgeBaseId string                  `json:"knowledgeBaseId"`
+	DataSourceId    string                  `json:"dataSourceId"`
+	IngestionJobId  string                  `json:"ingestionJobId"`
+	Status          string                  `json:"status"` // STARTING, IN_PROGRESS, COMPLETE, FAILED, STOPPING or STOPPED
+	StartedAt       *time.Time              `json:"startedAt,omitempty"`
+	UpdatedAt       *time.Time              `json:"updatedAt,omitempty"`
+	Statistics      *IngestionJobStatistics `json:"statistics,omitempty"`
+	FailureReasons  []string                `json:"failureReasons,omitempty"`
+}
+
+// KnowledgeBaseDocument is a source document of a data source, as ingested into its
+// knowledge base
+type KnowledgeBaseDocument struct {
+	KnowledgeBaseId string
+	DataSourceId    string
+	Uri             string     // s3:// URI of the source object, empty for custom data sources
+	Status          string     // INDEXED, PARTIALLY_INDEXED, PENDING, FAILED, IGNORED, ...
+	UpdatedAt       *time.Time // Of the last ingestion of the document
+}
+
+type IngestionJobStatistics struct {
+	DocumentsScanned         int64 `json:"documentsScanned"`
+	NewDocumentsIndexed      int64 `json:"newDocumentsIndexed"`
+	ModifiedDocumentsIndexed int64 `json:"modifiedDocumentsIndexed"`
+	DocumentsDeleted         int64 `json:"documentsDeleted"`
+	DocumentsFailed          int64 `json:"documentsFailed"`
+}
+
+type IngestionClient interface {
+	ListDataSources(ctx context.Context, knowledgeBaseId string) ([]DataSource, error)
+	// StartIngestionJob returns nil without an error when the data source does not exist
+	StartIngestionJob(ctx context.Context, knowledgeBaseId string, dataSourceId string) (*IngestionJob, error)
+	// GetIngestionJob returns nil without an error when the job does not exist
+	GetIngestionJob(ctx context.Context, knowledgeBaseId string, dataSourceId string, ingestionJobId string) (*IngestionJob, error)
+	// ListIngestionJobs returns the latest jobs of a data source, newest first
+	ListIngestionJobs(ctx context.Context, knowledgeBaseId string, dataSourceId string, maxResults int) ([]IngestionJob, error)
+	// ListDocuments returns a page of the documents of a data source and the token of the
+	// next page, "" on the last one
+	ListDocuments(ctx context.Context, knowledgeBaseId string, dataSourceId string, nextToken string) ([]KnowledgeBaseDocument, string, error)
+}
+
+// BedrockIngestionClient manages knowledge base data sources and their ingestion jobs
+// through the Bedrock Agent control plane
+type BedrockIngestionClient struct {
+	client *bedrockagent.Client
+}
+
+func NewBedrockIngestionClient(cfg aws.Config) *BedrockIngestionClient {
+	return &BedrockIngestionClient{
+		client: bedrockagent.NewFromConfig(cfg),
+	}
+}
+
+func (c *BedrockIngestionClient) ListDataSources(ctx context.Context, knowledgeBaseId string) ([]DataSource, error) {
+	dataSources := []DataSource{}
+	paginator := bedrockagent.NewListDataSourcesPaginator(c.client, &bedrockagent.ListDataSourcesInput{
+		KnowledgeBaseId: aws.String(knowledgeBaseId),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to list data sources", err)
+		}
+		for _, summary := range page.DataSourceSummaries {
+			dataSources = append(dataSources, DataSource{
+				KnowledgeBaseId: aws.ToString(summary.KnowledgeBaseId),
+				DataSourceId:    aws.ToString(summary.DataSourceId),
+				Name:            aws.ToString(summary.Name),
+				Description:     aws.ToString(summary.Description),
+				Status:          string(summary.Status),
+				UpdatedAt:       summary.UpdatedAt,
+			})
+		}
+	}
+	return dataSources, nil
+}
+
+func (c *BedrockIngestionClient) StartIngestionJob(ctx context.Context, knowledgeBaseId string, dataSourceId string) (*IngestionJob, error) {
+	output, err := c.client.StartIngestionJob(ctx, &bedrockagent.StartIngestionJobInput{
+		KnowledgeBaseId: aws.String(knowledgeBaseId),
+		DataSourceId:    aws.String(dataSourceId),
+	})
+	var conflict *types.ConflictException
+	if stdErrors.As(err, &conflict) {
+		return nil, ErrIngestionJobRunning
+	}
+	var notFound *types.ResourceNotFoundException
+	if stdErrors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to start ingestion job", err)
+	}
+	return ingestionJob(output.IngestionJob), nil
+}
+
+func (c *BedrockIngestionClient) GetIngestionJob(ctx context.Context, knowledgeBaseId string, dataSourceId string, ingestionJobId string) (*IngestionJob, error) {
+	output, err := c.client.GetIngestionJob(ctx, &bedrockagent.GetIngestionJobInput{
+		KnowledgeBaseId: aws.String(knowledgeBaseId),
+		DataSourceId:    aws.String(dataSourceId),
+		IngestionJobId:  aws.String(ingestionJobId),
+	})
+	var notFound *types.ResourceNotFoundException
+	if stdErrors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to get ingestion job", err)
+	}
+	return ingestionJob(output.IngestionJob), nil
+}
+
+func (c *BedrockIngestionClient) ListIngestionJobs(ctx context.Context, knowledgeBaseId string, dataSourceId string, maxResults int) ([]IngestionJob, error) {
+	output, err := c.client.ListIngestionJobs(ctx, &bedrockagent.ListIngestionJobsInput{
+		KnowledgeBaseId: aws.String(knowledgeBaseId),
+		DataSourceId:    aws.String(dataSourceId),
+		MaxResults:      aws.Int32(int32(maxResults)),
+		SortBy: &types.IngestionJobSortBy{
+			Attribute: types.IngestionJobSortByAttributeStartedAt,
+			Order:     types.SortOrderDescending,
+		},
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to list ingestion jobs", err)
+	}
+
+	jobs := make([]IngestionJob, 0, len(output.IngestionJobSummaries))
+	for _, summary := range output.IngestionJobSummaries {
+		jobs = append(jobs, IngestionJob{
+			KnowledgeBaseId: aws.ToString(summary.KnowledgeBaseId),
+			DataSourceId:    aws.ToString(summary.DataSourceId),
+			IngestionJobId:  aws.ToString(summary.IngestionJobId),
+			Status:          string(summary.Status),
+			StartedAt:       summary.StartedAt,
+			UpdatedAt:       summary.UpdatedAt,
+			Statistics:      ingestionJobStatistics(summary.Statistics),
+		})
+	}
+	return jobs, nil
+}
+
+func (c *BedrockIngestionClient) ListDocuments(ctx context.Context, knowledgeBaseId string, dataSourceId string, nextToken string) ([]KnowledgeBaseDocument, string, error) {
+	input := &bedrockagent.ListKnowledgeBaseDocumentsInput{
+		KnowledgeBaseId: aws.String(knowledgeBaseId),
+		DataSourceId:    aws.String(dataSourceId),
+		MaxResults:      aws.Int32(1000),
+	}
+	if nextToken != "" {
+		input.NextToken = aws.String(nextToken)
+	}
+	output, err := c.client.ListKnowledgeBaseDocuments(ctx, input)
+	if err != nil {
+		return nil, "", errors.NewAWSServiceError("failed to list knowledge base documents", err)
+	}
+
+	documents := make([]KnowledgeBaseDocument, 0, len(output.DocumentDetails))
+	for _, detail := range output.DocumentDetails {
+		document := KnowledgeBaseDocument{
+			KnowledgeBaseId: aws.ToString(detail.KnowledgeBaseId),
+			DataSourceId:    aws.ToString(detail.DataSourceId),
+			Status:          string(detail.Status),
+			UpdatedAt:       detail.UpdatedAt,
+		}
+		if detail.Identifier != nil && detail.Identifier.S3 != nil {
+			document.Uri = aws.ToString(detail.Identifier.S3.Uri)
+		}
+		documents = append(documents, document)
+	}
+	return documents, aws.ToString(output.NextToken), nil
+}
+
+func ingestionJob(job *types.IngestionJob) *IngestionJob {
+	if job == nil {
+		return nil
+	}
+	return &IngestionJob{
+		KnowledgeBaseId: aws.ToString(job.KnowledgeBaseId),
+		DataSourceId:    aws.ToString(job.DataSourceId),
+		IngestionJobId:  aws.ToString(job.IngestionJobId),
+		Status:          string(job.Status),
+		StartedAt:       job.StartedAt,
+		UpdatedAt:       job.UpdatedAt,
+		Statistics:      ingestionJobStatistics(job.Statistics),
+		FailureReasons:  job.FailureReasons,
+	}
+}
+
+func ingestionJobStatistics(statistics *types.IngestionJobStatistics) *IngestionJobStatistics {
+	if statistics == nil {
+		return nil
+	}
+	return &IngestionJobStatistics{
+		DocumentsScanned:         statistics.NumberOfDocumentsScanned,
+		NewDocumentsIndexed:      statistics.NumberOfNewDocumentsIndexed,
+		ModifiedDocumentsIndexed: statistics.NumberOfModifiedDocumentsIndexed,
+		DocumentsDeleted:         statistics.NumberOfDocumentsDeleted,
+		DocumentsFailed:          statistics.NumberOfDocumentsFailed,
+	}
+}
@@ -1,447 +1,778 @@
-package aws
-
-import (
-	"context"
-	"fmt"
-	"strings"
-	"sync"
-	"teletubpax-api/errors"
-	"teletubpax-api/utils"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
-	rttypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
-)
-
-type KnowledgeBaseClient interface {
-	QueryKnowledgeBase(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error)
-	QueryMultipleKnowledgeBases(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error)
-}
-
-type BedrockKBClient struct {
-	client             *bedrockagentruntime.Client
-	runtimeClient      *bedrockruntime.Client
-	knowledgeBaseIds   []string
-	generativeModelId  string
-	region             string
-	systemInstructions string
-}
-
-func NewBedrockKBClient(cfg aws.Config, knowledgeBaseIds []string, generativeModelId string, region string, systemInstructions string) *BedrockKBClient {
-	return &BedrockKBClient{
-		client:             bedrockagentruntime.NewFromConfig(cfg),
-		runtimeClient:      bedrockruntime.NewFromConfig(cfg),
-		knowledgeBaseIds:   knowledgeBaseIds,
-		generativeModelId:  generativeModelId,
-		region:             region,
-		systemInstructions: systemInstructions,
-	}
-}
-
-func (c *BedrockKBClient) QueryKnowledgeBase(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
-	// Use the first knowledge base for backward compatibility
-	if len(c.knowledgeBaseIds) == 0 {
-		return "", nil, fmt.Errorf("no knowledge base IDs configured")
-	}
-	return c.queryKnowledgeBaseById(ctx, c.knowledgeBaseIds[0], question, enableRelateDocument)
-}
-
-func (c *BedrockKBClient) queryKnowledgeBaseById(ctx context.Context, knowledgeBaseId string, question string, enableRelateDocument bool) (string, []string, error) {
-	// Build the correct model identifier based on model type
-	var modelArn string
-	if strings.HasPrefix(c.generativeModelId, "arn:") {
-		// Already an ARN, use as-is
-		modelArn = c.generativeModelId
-	} else if strings.Contains(c.generativeModelId, "anthropic.claude") && strings.Contains(c.generativeModelId, "haiku") {
-		// For Claude Haiku models, use cross-region inference profile ID (not ARN)
-		modelArn = "us.anthropic.claude-haiku-4-5-20251001-v1:0"
-	} else {
-		// Standard foundation model ARN
-		modelArn = fmt.Sprintf("arn:aws:bedrock:%s::foundation-model/%s", c.region, c.generativeModelId)
-	}
-
-	kbConfig := &types.KnowledgeBaseRetrieveAndGenerateConfiguration{
-		KnowledgeBaseId: aws.String(knowledgeBaseId),
-		ModelArn:        aws.String(modelArn),
-	}
-
-	// Add system instructions if provided
-	if c.systemInstructions != "" {
-		kbConfig.GenerationConfiguration = &types.GenerationConfiguration{
-			PromptTemplate: &types.PromptTemplate{
-				TextPromptTemplate: aws.String(c.systemInstructions + "\n\nQuestion: $query$\n\nContext: $search_results$"),
-			},
-		}
-	}
-
-	input := &bedrockagentruntime.RetrieveAndGenerateInput{
-		Input: &types.RetrieveAndGenerateInput{
-			Text: aws.String(question),
-		},
-		RetrieveAndGenerateConfiguration: &types.RetrieveAndGenerateConfiguration{
-			Type:                       types.RetrieveAndGenerateTypeKnowledgeBase,
-			KnowledgeBaseConfiguration: kbConfig,
-		},
-	}
-
-	output, err := c.client.RetrieveAndGenerate(ctx, input)
-	if err != nil {
-		return "", nil, c.handleAWSError(err)
-	}
-
-	var relatedDocuments []string
-	if enableRelateDocument {
-		fmt.Printf("DEBUG: enableRelateDocument=true, extracting citations...\n")
-		fmt.Printf("DEBUG: Citations count: %d\n", len(output.Citations))
-
-		documentSet := make(map[string]bool) // Deduplicate documents
-
-		if output.Citations != nil && len(output.Citations) > 0 {
-			for i, citation := range output.Citations {
-				fmt.Printf("DEBUG: Processing citation %d\n", i)
-				if citation.RetrievedReferences != nil {
-					fmt.Printf("DEBUG: Citation %d has %d retrieved references\n", i, len(citation.RetrievedReferences))
-					for j, ref := range citation.RetrievedReferences {
-						if ref.Location != nil && ref.Location.S3Location != nil {
-							if ref.Location.S3Location.Uri != nil {
-								s3Uri := *ref.Location.S3Location.Uri
-								publicUrl := c.convertS3UriToPublicUrl(s3Uri)
-								if !documentSet[publicUrl] {
-									documentSet[publicUrl] = true
-									fmt.Printf("DEBUG: Adding document %d from citation %d: %s\n", j, i, publicUrl)
-									relatedDocuments = append(relatedDocuments, publicUrl)
-								}
-							}
-						}
-					}
-				}
-			}
-		} else {
-			fmt.Printf("DEBUG: No citations found in output\n")
-		}
-
-		// If no documents found via citations, use Retrieve API to get source documents
-		if len(relatedDocuments) == 0 {
-			fmt.Printf("DEBUG: No documents from citations, using Retrieve API...\n")
-			retrievedDocs, err := c.retrieveSourceDocuments(ctx, knowledgeBaseId, question)
-			if err != nil {
-				fmt.Printf("DEBUG: Retrieve API failed: %v\n", err)
-			} else {
-				for _, doc := range retrievedDocs {
-					if !documentSet[doc] {
-						documentSet[doc] = true
-						relatedDocuments = append(relatedDocuments, doc)
-					}
-				}
-				fmt.Printf("DEBUG: Retrieved %d documents from Retrieve API\n", len(retrievedDocs))
-			}
-		}
-
-		fmt.Printf("DEBUG: Total related documents collected: %d\n", len(relatedDocuments))
-	} else {
-		fmt.Printf("DEBUG: enableRelateDocument=false, skipping document extraction\n")
-	}
-
-	if output.Output != nil && output.Output.Text != nil {
-		cleanedAnswer := utils.CleanMarkdown(*output.Output.Text)
-		return cleanedAnswer, relatedDocuments, nil
-	}
-
-	return "ไม่พบคำตอบที่เกี่ยวข้องกับคำถามของคุณ", relatedDocuments, nil
-}
-
-// retrieveSourceDocuments uses the Retrieve API to get source documents for a question
-func (c *BedrockKBClient) retrieveSourceDocuments(ctx context.Context, knowledgeBaseId string, question string) ([]string, error) {
-	input := &bedrockagentruntime.RetrieveInput{
-		KnowledgeBaseId: aws.String(knowledgeBaseId),
-		RetrievalQuery: &types.KnowledgeBaseQuery{
-			Text: aws.String(question),
-		},
-		RetrievalConfiguration: &types.KnowledgeBaseRetrievalConfiguration{
-			VectorSearchConfiguration: &types.KnowledgeBaseVectorSearchConfiguration{
-				NumberOfResults: aws.Int32(5), // Get top 5 relevant documents
-			},
-		},
-	}
-
-	output, err := c.client.Retrieve(ctx, input)
-	if err != nil {
-		return nil, err
-	}
-
-	var documents []string
-	documentSet := make(map[string]bool)
-
-	if output.RetrievalResults != nil {
-		for _, result := range output.RetrievalResults {
-			if result.Location != nil && result.Location.S3Location != nil {
-				if result.Location.S3Location.Uri != nil {
-					s3Uri := *result.Location.S3Location.Uri
-					publicUrl := c.convertS3UriToPublicUrl(s3Uri)
-					if !documentSet[publicUrl] {
-						documentSet[publicUrl] = true
-						documents = append(documents, publicUrl)
-					}
-				}
-			}
-		}
-	}
-
-	return documents, nil
-}
-
-func (c *BedrockKBClient) QueryMultipleKnowledgeBases(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
-	if len(c.knowledgeBaseIds) == 0 {
-		return "", nil, fmt.Errorf("no knowledge base IDs configured")
-	}
-
-	type kbResult struct {
-		answer    string
-		documents []string
-		err       error
-		kbId      string
-	}
-
-	results := make(chan kbResult, len(c.knowledgeBaseIds))
-	var wg sync.WaitGroup
-
-	// Query all knowledge bases in parallel
-	for _, kbId := range c.knowledgeBaseIds {
-		wg.Add(1)
-		go func(knowledgeBaseId string) {
-			defer wg.Done()
-			answer, docs, err := c.queryKnowledgeBaseById(ctx, knowledgeBaseId, question, enableRelateDocument)
-			results <- kbResult{
-				answer:    answer,
-				documents: docs,
-				err:       err,
-				kbId:      knowledgeBaseId,
-			}
-		}(kbId)
-	}
-
-	// Wait for all queries to complete
-	wg.Wait()
-	close(results)
-
-	// Collect and combine results
-	var combinedAnswer strings.Builder
-	var allDocuments []string
-	documentSet := make(map[string]bool)
-	successCount := 0
-	var lastError error
-
-	for result := range results {
-		if result.err != nil {
-			lastError = result.err
-			continue
-		}
-
-		successCount++
-
-		// Combine answers from different KBs
-		if result.answer != "" && result.answer != "ไม่พบคำตอบที่เกี่ยวข้องกับคำถามของคุณ" {
-			if combinedAnswer.Len() > 0 {
-				combinedAnswer.WriteString("\n\n")
-			}
-			combinedAnswer.WriteString(result.answer)
-		}
-
-		// Deduplicate documents
-		for _, doc := range result.documents {
-			if !documentSet[doc] {
-				documentSet[doc] = true
-				allDocuments = append(allDocuments, doc)
-			}
-		}
-	}
-
-	// If all queries failed, return the last error
-	if successCount == 0 {
-		if lastError != nil {
-			return "", nil, lastError
-		}
-		return "", nil, fmt.Errorf("all knowledge base queries failed")
-	}
-
-	// Return combined results
-	finalAnswer := combinedAnswer.String()
-	if finalAnswer == "" {
-		finalAnswer = "ไม่พบคำตอบที่เกี่ยวข้องกับคำถามของคุณ"
-		return finalAnswer, allDocuments, nil
-	}
-
-	// Synthesize multiple answers into one coherent response
-	fmt.Printf("DEBUG: Starting synthesis for question: %s\n", question)
-	fmt.Printf("DEBUG: Combined answers length: %d characters\n", len(finalAnswer))
-
-	synthesizedAnswer, err := c.synthesizeAnswers(ctx, question, finalAnswer, allDocuments)
-	if err != nil {
-		// If synthesis fails, log the error and return the combined answer as fallback
-		fmt.Printf("ERROR: Synthesis failed: %v. Returning combined answers.\n", err)
-		return finalAnswer, allDocuments, nil
-	}
-
-	fmt.Printf("DEBUG: Synthesis successful. Result length: %d characters\n", len(synthesizedAnswer))
-	return synthesizedAnswer, allDocuments, nil
-}
-
-func (c *BedrockKBClient) synthesizeAnswers(ctx context.Context, question string, combinedAnswers string, relatedDocuments []string) (string, error) {
-	fmt.Printf("DEBUG: synthesizeAnswers called with modelId: %s\n", c.generativeModelId)
-
-	// Build document metadata context
-	var documentContext strings.Builder
-	if len(relatedDocuments) > 0 {
-		documentContext.WriteString("\n\nReference Documents (for version/date analysis):\n")
-		for i, docUrl := range relatedDocuments {
-			documentContext.WriteString(fmt.Sprintf("%d. %s\n", i+1, docUrl))
-		}
-	}
-
-	// Create synthesis prompt
-	userMessage := fmt.Sprintf(`You have received multiple answers from different knowledge bases for the same question. Synthesize them into ONE clear, coherent answer.
-
-Original Question: %s
-
-Multiple Answers:
-%s
-%s
-#### CRITICAL: Recency Resolution Protocol
-You must identify and use **only the single most recent document**. Ignore older versions.
-
-**Step 1: Primary Signal (S3 Path Date)**
-  Look at the document URLs (e.g., .../YYYY/MM/...). Extract YYYY and MM.
-  The document with the highest (YYYY, MM) is the newest.
-  Example: 2025/12 > 2025/11 > 2024/12.
-
-**Step 2: Tie-Breaker (Version Number in Filename)**
-If S3 path dates are identical, check the filename:
-  **Version Tokens:** Look for patterns like v4, v4.0, ver4, version-4. Highest number wins.
-  **Numeric Suffix:** Look for patterns like -1.pdf, -2.pdf, _3.pdf. Highest number wins.
-  **Rule:** An explicit version token (e.g., v4.0) **always overrides** a simple suffix (e.g., -2).
-
-**Step 3: If Still Tied**
-  Use the answer that appears to have more complete or detailed information.
-
-Instructions:
-1. Remove "Sorry, I am unable to assist" messages unless ALL answers contain them
-2. ALWAYS prefer information from the most recent documents (use the protocol above)
-3. Remove duplicate information
-4. Combine complementary details into a single coherent response
-5. If answers contradict, choose the most recent/authoritative one based on document date/version
-6. Maintain the same language as the original question
-7. Be concise and direct
-8. No Fluff: Do NOT use phrases like "Based on the document...", "The system found...", or "According to...". Start with the answer immediately.
-	8.1 Check if the user's input ends with or contains specific question particles indicating a need for exact data:
-  		**Keywords:** ไร, อะไร, ไหน, ที่ไหน, หรือไม่, ไหม, มั๊ย, เท่าไหร่, กี่บาท, ยัง (Yet), ใคร (Who).
-		**Action:** Start with the answer immediately. No filler.
-    	**Constraint:** Maximum 25 words.
-    	**Example:** "ดอกเบี้ย 5%% ต่อปี สำหรับลูกค้าใหม่"
-	8.2 Provide ONLY the final synthesized answer:`, question, combinedAnswers, documentContext.String())
-
-	fmt.Printf("DEBUG: Calling Bedrock Converse API...\n")
-
-	// Get the correct model identifier (inference profile for Claude Haiku)
-	modelId := c.generativeModelId
-	if strings.Contains(c.generativeModelId, "anthropic.claude") && strings.Contains(c.generativeModelId, "haiku") {
-		// Use cross-region inference profile ID for Claude Haiku
-		modelId = "us.anthropic.claude-haiku-4-5-20251001-v1:0"
-	}
-
-	fmt.Printf("DEBUG: Using model ID: %s\n", modelId)
-
-	// Use Bedrock Runtime Converse API for direct model invocation
-	converseInput := &bedrockruntime.ConverseInput{
-		ModelId: aws.String(modelId),
-		Messages: []rttypes.Message{
-			{
-				Role: rttypes.ConversationRoleUser,
-				Content: []rttypes.ContentBlock{
-					&rttypes.ContentBlockMemberText{
-						Value: userMessage,
-					},
-				},
-			},
-		},
-		InferenceConfig: &rttypes.InferenceConfiguration{
-			MaxTokens:   aws.Int32(2048),
-			Temperature: aws.Float32(0.3), // Lower temperature for more focused synthesis
-		},
-	}
-
-	output, err := c.runtimeClient.Converse(ctx, converseInput)
-	if err != nil {
-		fmt.Printf("ERROR: Converse API call failed: %v\n", err)
-		return "", fmt.Errorf("synthesis converse API failed: %w", err)
-	}
-
-	fmt.Printf("DEBUG: Converse API call successful, extracting response...\n")
-
-	// Extract the response text
-	if output.Output != nil {
-		if msg, ok := output.Output.(*rttypes.ConverseOutputMemberMessage); ok {
-			if len(msg.Value.Content) > 0 {
-				if textBlock, ok := msg.Value.Content[0].(*rttypes.ContentBlockMemberText); ok {
-					fmt.Printf("DEBUG: Successfully extracted synthesized text\n")
-					cleanedAnswer := utils.CleanMarkdown(textBlock.Value)
-					return cleanedAnswer, nil
-				}
-			}
-		}
-	}
-
-	fmt.Printf("ERROR: Failed to extract text from Converse response\n")
-	return "", fmt.Errorf("no synthesis output received")
-}
-
-func (c *BedrockKBClient) getModelArn() string {
-	if strings.HasPrefix(c.generativeModelId, "arn:") {
-		return c.generativeModelId
-	} else if strings.Contains(c.generativeModelId, "anthropic.claude") && strings.Contains(c.generativeModelId, "haiku") {
-		return "us.anthropic.claude-haiku-4-5-20251001-v1:0"
-	}
-	return fmt.Sprintf("arn:aws:bedrock:%s::foundation-model/%s", c.region, c.generativeModelId)
-}
-
-func (c *BedrockKBClient) convertS3UriToPublicUrl(s3Uri string) string {
-	s3Uri = strings.TrimPrefix(s3Uri, "s3://")
-	parts := strings.SplitN(s3Uri, "/", 2)
-	if len(parts) != 2 {
-		return s3Uri
-	}
-	bucket := parts[0]
-	key := parts[1]
-	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, c.region, key)
-}
-
-func (c *BedrockKBClient) handleAWSError(err error) error {
-	errMsg := err.Error()
-
-	if contains(errMsg, "ValidationException") || contains(errMsg, "invalid") {
-		return errors.NewValidationError(fmt.Sprintf("invalid knowledge base query: %v", err))
-	}
-
-	if contains(errMsg, "ThrottlingException") || contains(errMsg, "TooManyRequestsException") {
-		return errors.NewThrottlingError("knowledge base service throttled", err)
-	}
-
-	if contains(errMsg, "AccessDeniedException") || contains(errMsg, "UnauthorizedException") {
-		return errors.NewAWSServiceError("invalid or missing AWS credentials", err)
-	}
-
-	if contains(errMsg, "ResourceNotFoundException") {
-		return errors.NewKnowledgeBaseError(fmt.Sprintf("resource not found: %v", err), err)
-	}
-
-	if contains(errMsg, "ServiceUnavailableException") || contains(errMsg, "InternalServerException") {
-		return errors.NewAWSServiceError("knowledge base service unavailable", err)
-	}
-
-	if contains(errMsg, "TimeoutException") || contains(errMsg, "timeout") {
-		return errors.NewAWSServiceError("knowledge base query timeout", err)
-	}
-
-	return errors.NewKnowledgeBaseError("knowledge base query failed", err)
-}
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"teletubpax-api/config"
+	"teletubpax-api/errors"
+	"teletubpax-api/experiments"
+	"teletubpax-api/logger"
+	"teletubpax-api/tracing"
+	"teletubpax-api/utils"
+	"teletubpax-api/warnings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	rttypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+type KnowledgeBaseClient interface {
+	QueryKnowledgeBase(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error)
+	QueryMultipleKnowledgeBases(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error)
+	RetrieveFromKnowledgeBases(ctx context.Context, question string, numberOfResults int) ([]KnowledgeBaseRetrieval, error)
+}
+
+// RetrievedChunk is a single retrieval result, as the model would see it before generation
+type RetrievedChunk struct {
+	Content   string                 `json:"content"`
+	Score     float64                `json:"score"`
+	SourceUri string                 `json:"sourceUri,omitempty"`
+	SourceUrl string                 `json:"sourceUrl,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// KnowledgeBaseRetrieval holds the retrieval output of one knowledge base. Error is set
+// instead of failing the whole call so a single broken KB stays visible in diagnostics.
+type KnowledgeBaseRetrieval struct {
+	KnowledgeBaseId string           `json:"knowledgeBaseId"`
+	Chunks          []RetrievedChunk `json:"chunks"`
+	Error           string           `json:"error,omitempty"`
+}
+
+// BedrockKBClient reads its knowledge bases, model and instructions on every call, so
+// settings reloaded from SSM apply without a restart
+type BedrockKBClient struct {
+	client             *bedrockagentruntime.Client
+	runtimeClient      *bedrockruntime.Client
+	knowledgeBases     func() []config.KnowledgeBase // Enabled knowledge bases, highest weight first
+	generativeModelId  func() string
+	fallbackModelIds   func() []string // Optional, models tried in order when the generative model fails
+	region             string
+	systemInstructions func(config.KnowledgeBase, string) string // Prompt of a knowledge base for a language, empty for the Bedrock default
+	synthesisRules     func() string                             // Rules for merging the answers of several knowledge bases
+	sourceFilter       SourceFilter                              // Optional, excluded documents are never retrieved
+	documentLinker     DocumentLinker
+	searchType         string                  // Optional, "HYBRID" or "SEMANTIC" unless the request asks for another
+	retrieval          RetrievalSettings       // Used where the request's retrieval settings are unset
+	generation         GenerationSettings      // Used where the request's generation settings are unset
+	bulkheads          *KnowledgeBaseBulkheads // Optional, isolates the knowledge bases of multi knowledge base answers
+	queryTimeout       time.Duration           // Of each knowledge base in multi knowledge base answers without its own timeoutMs, 0 for none
+}
+
+func NewBedrockKBClient(cfg aws.Config, knowledgeBases func() []config.KnowledgeBase, generativeModelId func() string, fallbackModelIds func() []string, region string, systemInstructions func(config.KnowledgeBase, string) string, synthesisRules func() string, sourceFilter SourceFilter, documentLinker DocumentLinker, searchType string, retrieval RetrievalSettings, generation GenerationSettings, bulkheads *KnowledgeBaseBulkheads, queryTimeout time.Duration) *BedrockKBClient {
+	return &BedrockKBClient{
+		client:             bedrockagentruntime.NewFromConfig(cfg),
+		runtimeClient:      bedrockruntime.NewFromConfig(cfg),
+		knowledgeBases:     knowledgeBases,
+		generativeModelId:  generativeModelId,
+		fallbackModelIds:   fallbackModelIds,
+		region:             region,
+		systemInstructions: systemInstructions,
+		synthesisRules:     synthesisRules,
+		sourceFilter:       sourceFilter,
+		documentLinker:     documentLinker,
+		searchType:         searchType,
+		retrieval:          retrieval,
+		generation:         generation,
+		bulkheads:          bulkheads,
+		queryTimeout:       queryTimeout,
+	}
+}
+
+// queryContext bounds a query of one knowledge base of a multi knowledge base answer by its
+// timeout, so a slow knowledge base is cancelled instead of holding up the answer
+func (c *BedrockKBClient) queryContext(ctx context.Context, knowledgeBase config.KnowledgeBase) (context.Context, context.CancelFunc) {
+	timeout := c.queryTimeout
+	if knowledgeBase.TimeoutMs > 0 {
+		timeout = time.Duration(knowledgeBase.TimeoutMs) * time.Millisecond
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// retrievalSettings returns the retrieval settings of an answer: the request's, else the
+// configured ones, else the top 5 chunks of any score
+func (c *BedrockKBClient) retrievalSettings(ctx context.Context) RetrievalSettings {
+	return RetrievalSettingsFromContext(ctx).Or(c.retrieval).Or(RetrievalSettings{NumberOfResults: 5})
+}
+
+// generativeModel returns the generative model of an answer: the experiment variant's, else
+// the configured one
+func (c *BedrockKBClient) generativeModel(ctx context.Context) string {
+	if assignment := experiments.FromContext(ctx); assignment != nil && assignment.Variant.ModelId != "" {
+		return assignment.Variant.ModelId
+	}
+	return c.generativeModelId()
+}
+
+// instructions returns the prompt of a knowledge base for a language: the experiment
+// variant's, else the configured one
+func (c *BedrockKBClient) instructions(ctx context.Context, knowledgeBase config.KnowledgeBase) string {
+	if assignment := experiments.FromContext(ctx); assignment != nil && assignment.Variant.Instructions != "" {
+		return assignment.Variant.Instructions
+	}
+	return c.systemInstructions(knowledgeBase, QuestionLanguageFromContext(ctx))
+}
+
+// synthesisInstructions returns the rules for merging the answers of several knowledge
+// bases: the experiment variant's, else the configured ones
+func (c *BedrockKBClient) synthesisInstructions(ctx context.Context) string {
+	if assignment := experiments.FromContext(ctx); assignment != nil && assignment.Variant.SynthesisInstructions != "" {
+		return assignment.Variant.SynthesisInstructions
+	}
+	return c.synthesisRules()
+}
+
+func (c *BedrockKBClient) QueryKnowledgeBase(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	// Use the knowledge base with the highest weight for the question's intent
+	knowledgeBases := c.routedKnowledgeBases(ctx)
+	if len(knowledgeBases) == 0 {
+		return "", nil, fmt.Errorf("no knowledge base IDs configured")
+	}
+	return c.queryKnowledgeBase(ctx, knowledgeBases[0], question, enableRelateDocument)
+}
+
+func (c *BedrockKBClient) queryKnowledgeBase(ctx context.Context, knowledgeBase config.KnowledgeBase, question string, enableRelateDocument bool) (_ string, _ []string, err error) {
+	knowledgeBaseId := knowledgeBase.Id
+	ctx, span := tracing.Start(ctx, "BedrockKBClient.queryKnowledgeBase", tracing.AttrKnowledgeBaseId.String(knowledgeBaseId))
+	defer func() { tracing.End(span, err) }()
+	recordKnowledgeBase(ctx, knowledgeBaseId)
+
+	kbConfig := &types.KnowledgeBaseRetrieveAndGenerateConfiguration{
+		KnowledgeBaseId: aws.String(knowledgeBaseId),
+	}
+
+	// Add the system instructions of the knowledge base for the question's language if provided,
+	// with the request's instruction suffix, in the configured prompt template. The request's
+	// temperature and generation limit take precedence over the configured inference settings.
+	generation := GenerationSettingsFromContext(ctx).Or(c.generation)
+	systemInstructions := generation.Instructions(c.instructions(ctx, knowledgeBase))
+	kbConfig.GenerationConfiguration = generation.GenerationConfiguration(systemInstructions)
+
+	// Keep excluded documents and those outside the request's metadata filter out of the
+	// generation context, and search the way the request or the configuration asks. Bedrock
+	// does not score the chunks it generates from, so the minimum score cannot apply here.
+	kbConfig.RetrievalConfiguration = &types.KnowledgeBaseRetrievalConfiguration{
+		VectorSearchConfiguration: &types.KnowledgeBaseVectorSearchConfiguration{
+			NumberOfResults:    aws.Int32(int32(c.retrievalSettings(ctx).NumberOfResults)),
+			Filter:             retrievalFilter(ctx, c.sourceFilter),
+			OverrideSearchType: overrideSearchType(ctx, c.searchType),
+		},
+	}
+
+	input := &bedrockagentruntime.RetrieveAndGenerateInput{
+		Input: &types.RetrieveAndGenerateInput{
+			Text: aws.String(question),
+		},
+		RetrieveAndGenerateConfiguration: &types.RetrieveAndGenerateConfiguration{
+			Type:                       types.RetrieveAndGenerateTypeKnowledgeBase,
+			KnowledgeBaseConfiguration: kbConfig,
+		},
+	}
+
+	// Score how well the documents match the question alongside generation, when the
+	// request wants the confidence of the answer
+	var scoring *sync.WaitGroup
+	var retrievalScore float64
+	var scored bool
+	if ConfidenceRecorderFromContext(ctx) != nil {
+		scoring = &sync.WaitGroup{}
+		scoring.Add(1)
+		go func() {
+			defer scoring.Done()
+			retrievalScore, scored = c.bestRetrievalScore(ctx, knowledgeBaseId, question)
+		}()
+	}
+
+	// Continue the knowledge base's session of the conversation, if any
+	conversation := ConversationFromContext(ctx)
+	if conversation != nil {
+		if sessionId := conversation.session(knowledgeBaseId); sessionId != "" {
+			input.SessionId = aws.String(sessionId)
+		}
+	}
+
+	var output *bedrockagentruntime.RetrieveAndGenerateOutput
+	err = c.withModelFallback(ctx, func(modelId string) (err error) {
+		// Inference profile ID or foundation model ARN, as resolved for the region
+		kbConfig.ModelArn = aws.String(invocationModelArn(modelId, c.region))
+		output, err = retrieveAndGenerate(ctx, c.client, input)
+		if err != nil && input.SessionId != nil && isSessionExpired(err) {
+			// Bedrock drops sessions after inactivity, answer in a new session instead
+			conversation.expire(knowledgeBaseId)
+			warnings.Add(ctx, warnings.CodeSessionExpired, "The conversation expired, the question was answered without the earlier questions")
+			input.SessionId = nil
+			output, err = retrieveAndGenerate(ctx, c.client, input)
+		}
+		if err == nil && output.Output != nil {
+			recordRetrieveAndGenerateUsage(ctx, modelId, systemInstructions, question, aws.ToString(output.Output.Text), output.Citations)
+		}
+		return err
+	})
+	if err != nil {
+		return "", nil, c.handleAWSError(err)
+	}
+	if conversation != nil && output.SessionId != nil {
+		conversation.setSession(knowledgeBaseId, *output.SessionId)
+	}
+
+	// Keep which parts of the answer came from which documents, when the request wants them
+	if CitationCollectorFromContext(ctx) != nil {
+		RecordCitations(ctx, c.answerCitations(ctx, output.Citations))
+	}
+
+	var relatedDocuments []string
+	if enableRelateDocument {
+		fmt.Printf("DEBUG: enableRelateDocument=true, extracting citations...\n")
+		fmt.Printf("DEBUG: Citations count: %d\n", len(output.Citations))
+
+		documentSet := make(map[string]bool) // Deduplicate documents
+
+		if output.Citations != nil && len(output.Citations) > 0 {
+			for i, citation := range output.Citations {
+				fmt.Printf("DEBUG: Processing citation %d\n", i)
+				if citation.RetrievedReferences != nil {
+					fmt.Printf("DEBUG: Citation %d has %d retrieved references\n", i, len(citation.RetrievedReferences))
+					for j, ref := range citation.RetrievedReferences {
+						if ref.Location != nil && ref.Location.S3Location != nil {
+							if ref.Location.S3Location.Uri != nil {
+								s3Uri := *ref.Location.S3Location.Uri
+								if !documentSet[s3Uri] {
+									documentSet[s3Uri] = true
+									fmt.Printf("DEBUG: Adding document %d from citation %d: %s\n", j, i, s3Uri)
+									relatedDocuments = append(relatedDocuments, c.documentLinker.DocumentLink(ctx, s3Uri))
+								}
+							}
+						}
+					}
+				}
+			}
+		} else {
+			fmt.Printf("DEBUG: No citations found in output\n")
+		}
+
+		// If no documents found via citations, use Retrieve API to get source documents
+		if len(relatedDocuments) == 0 {
+			fmt.Printf("DEBUG: No documents from citations, using Retrieve API...\n")
+			retrievedDocs, err := c.retrieveSourceDocuments(ctx, knowledgeBaseId, question)
+			if err != nil {
+				fmt.Printf("DEBUG: Retrieve API failed: %v\n", err)
+			} else {
+				for _, doc := range retrievedDocs {
+					if !documentSet[doc] {
+						documentSet[doc] = true
+						relatedDocuments = append(relatedDocuments, doc)
+					}
+				}
+				fmt.Printf("DEBUG: Retrieved %d documents from Retrieve API\n", len(retrievedDocs))
+			}
+		}
+
+		fmt.Printf("DEBUG: Total related documents collected: %d\n", len(relatedDocuments))
+	} else {
+		fmt.Printf("DEBUG: enableRelateDocument=false, skipping document extraction\n")
+	}
+
+	answer := NoAnswerFor(QuestionLanguageFromContext(ctx))
+	var coverage float64
+	if output.Output != nil && output.Output.Text != nil {
+		answer = utils.CleanMarkdown(*output.Output.Text)
+		coverage = citationCoverage(*output.Output.Text, output.Citations)
+	}
+	if scoring != nil {
+		scoring.Wait()
+		RecordConfidence(ctx, answerConfidence(retrievalScore, scored, coverage), !IsNoAnswer(answer))
+	}
+
+	return answer, relatedDocuments, nil
+}
+
+// bestRetrievalScore returns the score of the chunk that best matches the question, 0 when
+// nothing matches. scored is false when the retrieval failed.
+func (c *BedrockKBClient) bestRetrievalScore(ctx context.Context, knowledgeBaseId string, question string) (score float64, scored bool) {
+	chunks, err := c.retrieveChunks(ctx, knowledgeBaseId, question, confidenceRetrievalResults)
+	if err != nil {
+		return 0, false
+	}
+	for _, chunk := range chunks {
+		score = max(score, chunk.Score)
+	}
+	return score, true
+}
+
+// retrieveSourceDocuments uses the Retrieve API to get source documents for a question,
+// leaving out those whose chunks score below the minimum score
+func (c *BedrockKBClient) retrieveSourceDocuments(ctx context.Context, knowledgeBaseId string, question string) (_ []string, err error) {
+	ctx, span := tracing.Start(ctx, "BedrockKBClient.retrieveSourceDocuments", tracing.AttrKnowledgeBaseId.String(knowledgeBaseId))
+	defer func() { tracing.End(span, err) }()
+
+	settings := c.retrievalSettings(ctx)
+
+	input := &bedrockagentruntime.RetrieveInput{
+		KnowledgeBaseId: aws.String(knowledgeBaseId),
+		RetrievalQuery: &types.KnowledgeBaseQuery{
+			Text: aws.String(question),
+		},
+		RetrievalConfiguration: &types.KnowledgeBaseRetrievalConfiguration{
+			VectorSearchConfiguration: &types.KnowledgeBaseVectorSearchConfiguration{
+				NumberOfResults:    aws.Int32(int32(settings.NumberOfResults)),
+				Filter:             retrievalFilter(ctx, c.sourceFilter),
+				OverrideSearchType: overrideSearchType(ctx, c.searchType),
+			},
+		},
+	}
+
+	output, err := c.client.Retrieve(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []string
+	documentSet := make(map[string]bool)
+
+	if output.RetrievalResults != nil {
+		for _, result := range output.RetrievalResults {
+			if result.Score != nil && !settings.Keeps(*result.Score) {
+				continue
+			}
+			if result.Location != nil && result.Location.S3Location != nil {
+				if result.Location.S3Location.Uri != nil {
+					s3Uri := *result.Location.S3Location.Uri
+					if !documentSet[s3Uri] {
+						documentSet[s3Uri] = true
+						documents = append(documents, c.documentLinker.DocumentLink(ctx, s3Uri))
+					}
+				}
+			}
+		}
+	}
+
+	return documents, nil
+}
+
+// RetrieveFromKnowledgeBases runs only the Retrieve stage against every configured knowledge
+// base in parallel, without any generation. Results keep the configured KB order.
+func (c *BedrockKBClient) RetrieveFromKnowledgeBases(ctx context.Context, question string, numberOfResults int) ([]KnowledgeBaseRetrieval, error) {
+	ctx, span := tracing.Start(ctx, "BedrockKBClient.RetrieveFromKnowledgeBases")
+	defer span.End()
+
+	knowledgeBases := c.routedKnowledgeBases(ctx)
+	if len(knowledgeBases) == 0 {
+		return nil, fmt.Errorf("no knowledge base IDs configured")
+	}
+
+	retrievals := make([]KnowledgeBaseRetrieval, len(knowledgeBases))
+	var wg sync.WaitGroup
+
+	for i, knowledgeBase := range knowledgeBases {
+		wg.Add(1)
+		go func(i int, knowledgeBaseId string) {
+			defer wg.Done()
+			retrievals[i] = KnowledgeBaseRetrieval{KnowledgeBaseId: knowledgeBaseId, Chunks: []RetrievedChunk{}}
+
+			chunks, err := c.retrieveChunks(ctx, knowledgeBaseId, question, numberOfResults)
+			if err != nil {
+				retrievals[i].Error = c.handleAWSError(err).Error()
+				return
+			}
+			retrievals[i].Chunks = chunks
+		}(i, knowledgeBase.Id)
+	}
+
+	wg.Wait()
+	return retrievals, nil
+}
+
+// retrieveChunks returns the raw retrieval results of a single knowledge base
+func (c *BedrockKBClient) retrieveChunks(ctx context.Context, knowledgeBaseId string, question string, numberOfResults int) (_ []RetrievedChunk, err error) {
+	ctx, span := tracing.Start(ctx, "BedrockKBClient.retrieveChunks", tracing.AttrKnowledgeBaseId.String(knowledgeBaseId))
+	defer func() { tracing.End(span, err) }()
+	recordKnowledgeBase(ctx, knowledgeBaseId)
+
+	input := &bedrockagentruntime.RetrieveInput{
+		KnowledgeBaseId: aws.String(knowledgeBaseId),
+		RetrievalQuery: &types.KnowledgeBaseQuery{
+			Text: aws.String(question),
+		},
+		RetrievalConfiguration: &types.KnowledgeBaseRetrievalConfiguration{
+			VectorSearchConfiguration: &types.KnowledgeBaseVectorSearchConfiguration{
+				NumberOfResults:    aws.Int32(int32(numberOfResults)),
+				Filter:             retrievalFilter(ctx, c.sourceFilter),
+				OverrideSearchType: overrideSearchType(ctx, c.searchType),
+			},
+		},
+	}
+
+	output, err := c.client.Retrieve(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make([]RetrievedChunk, 0, len(output.RetrievalResults))
+	for _, result := range output.RetrievalResults {
+		chunk := RetrievedChunk{}
+		if result.Content != nil && result.Content.Text != nil {
+			chunk.Content = *result.Content.Text
+		}
+		if result.Score != nil {
+			chunk.Score = *result.Score
+		}
+		if result.Location != nil && result.Location.S3Location != nil && result.Location.S3Location.Uri != nil {
+			chunk.SourceUri = *result.Location.S3Location.Uri
+			chunk.SourceUrl = c.documentLinker.DocumentLink(ctx, chunk.SourceUri)
+		}
+		if len(result.Metadata) > 0 {
+			chunk.Metadata = make(map[string]interface{}, len(result.Metadata))
+			for key, value := range result.Metadata {
+				var jsonValue interface{}
+				if err := value.UnmarshalSmithyDocument(&jsonValue); err == nil {
+					chunk.Metadata[key] = jsonValue
+				}
+			}
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, nil
+}
+
+func (c *BedrockKBClient) QueryMultipleKnowledgeBases(ctx context.Context, question string, enableRelateDocument bool) (_ string, _ []string, err error) {
+	ctx, span := tracing.Start(ctx, "BedrockKBClient.QueryMultipleKnowledgeBases")
+	defer func() { tracing.End(span, err) }()
+
+	knowledgeBases := c.routedKnowledgeBases(ctx)
+	if len(knowledgeBases) == 0 {
+		return "", nil, fmt.Errorf("no knowledge base IDs configured")
+	}
+
+	type kbResult struct {
+		answer    string
+		documents []string
+		err       error
+		kbId      string
+		status    string
+		duration  time.Duration
+	}
+
+	// Query all knowledge bases in parallel, results keep the order of the knowledge bases
+	// so answers of higher weight come first. Only the synthesis of their answers is streamed.
+	// A knowledge base whose bulkhead is full or which spent its failure budget is skipped, and
+	// one slower than its timeout is cancelled, so the answer comes from the others.
+	queryCtx := WithTokenStream(ctx, nil)
+	results := make([]kbResult, len(knowledgeBases))
+	var wg sync.WaitGroup
+	for i, knowledgeBase := range knowledgeBases {
+		wg.Add(1)
+		go func(i int, knowledgeBase config.KnowledgeBase) {
+			defer wg.Done()
+			release, skippedStatus := c.bulkheads.Acquire(knowledgeBase.Id)
+			if skippedStatus != "" {
+				results[i] = kbResult{err: skippedKnowledgeBaseError(knowledgeBase.Id, skippedStatus), kbId: knowledgeBase.Id, status: skippedStatus}
+				return
+			}
+			defer release()
+
+			kbCtx, cancel := c.queryContext(queryCtx, knowledgeBase)
+			defer cancel()
+			start := time.Now()
+			answer, docs, err := c.queryKnowledgeBase(kbCtx, knowledgeBase, question, enableRelateDocument)
+			results[i] = kbResult{
+				answer:    answer,
+				documents: docs,
+				err:       err,
+				kbId:      knowledgeBase.Id,
+				status:    knowledgeBaseQueryStatus(err),
+				duration:  time.Since(start),
+			}
+			// Invalid requests and requests the caller gave up on say nothing of its health
+			if ctx.Err() == nil && !isValidationError(err) {
+				c.bulkheads.Record(knowledgeBase.Id, err != nil)
+			}
+		}(i, knowledgeBase)
+	}
+
+	// Wait for all queries to complete
+	wg.Wait()
+
+	statuses := make([]KnowledgeBaseStatus, len(results))
+	for i, result := range results {
+		statuses[i] = KnowledgeBaseStatus{KnowledgeBaseId: result.kbId, Status: result.status, DurationMs: result.duration.Milliseconds()}
+	}
+	recordKnowledgeBaseStatuses(ctx, statuses)
+
+	// Collect and combine results. With differing weights the synthesis prompt gets each
+	// answer labelled with its knowledge base and priority.
+	prioritized := hasDifferentWeights(knowledgeBases)
+	var combinedAnswer strings.Builder
+	var labelledAnswers strings.Builder
+	var allDocuments []string
+	documentSet := make(map[string]bool)
+	successCount := 0
+	var lastError error
+	var skipped []kbResult
+
+	for i, result := range results {
+		if result.err != nil {
+			lastError = result.err
+			skipped = append(skipped, result)
+			continue
+		}
+
+		successCount++
+
+		// Combine answers from different KBs
+		if result.answer != "" && result.answer != NoAnswerFor(QuestionLanguageFromContext(ctx)) {
+			if combinedAnswer.Len() > 0 {
+				combinedAnswer.WriteString("\n\n")
+				labelledAnswers.WriteString("\n\n")
+			}
+			combinedAnswer.WriteString(result.answer)
+			labelledAnswers.WriteString(fmt.Sprintf("[Knowledge base: %s, priority %d]\n%s", knowledgeBases[i].Label, priority(knowledgeBases, i), result.answer))
+		}
+
+		// Deduplicate documents
+		for _, doc := range result.documents {
+			if !documentSet[doc] {
+				documentSet[doc] = true
+				allDocuments = append(allDocuments, doc)
+			}
+		}
+	}
+
+	// If all queries failed, return the last error
+	if successCount == 0 {
+		if lastError != nil {
+			return "", nil, lastError
+		}
+		return "", nil, fmt.Errorf("all knowledge base queries failed")
+	}
+	for _, result := range skipped {
+		reason := "query failed"
+		switch result.status {
+		case KnowledgeBaseTimedOut:
+			reason = "timeout"
+		case KnowledgeBaseRejected:
+			reason = "too many queries in flight"
+		case KnowledgeBaseSuspended:
+			reason = "repeated failures"
+		}
+		warnings.Add(ctx, warnings.CodeKnowledgeBaseSkipped, fmt.Sprintf("Knowledge base %s skipped due to %s, the answer may be incomplete", result.kbId, reason))
+	}
+
+	// Return combined results
+	finalAnswer := combinedAnswer.String()
+	if finalAnswer == "" {
+		finalAnswer = NoAnswerFor(QuestionLanguageFromContext(ctx))
+		return finalAnswer, allDocuments, nil
+	}
+
+	// Synthesize multiple answers into one coherent response. The question is not logged:
+	// it is personal data, see the redaction package.
+	log := logger.WithContext(ctx)
+	log.Debug("Synthesizing the answers of several knowledge bases", map[string]interface{}{
+		"combined_length": len(finalAnswer),
+	})
+
+	synthesisInput := finalAnswer
+	if prioritized {
+		synthesisInput = labelledAnswers.String()
+	}
+	synthesizedAnswer, err := c.synthesizeAnswers(ctx, question, synthesisInput, allDocuments, prioritized)
+	if err != nil {
+		// If synthesis fails, log the error and return the combined answer as fallback
+		log.Error("Synthesis failed, returning the combined answers", map[string]interface{}{
+			"error": err.Error(),
+		})
+		warnings.Add(ctx, warnings.CodeSynthesisSkipped, "Answers from several knowledge bases are listed without being merged")
+		return finalAnswer, allDocuments, nil
+	}
+
+	log.Debug("Synthesized the answers", map[string]interface{}{
+		"length": len(synthesizedAnswer),
+	})
+	return synthesizedAnswer, allDocuments, nil
+}
+
+// knowledgeBaseQueryStatus is the status of a knowledge base whose query returned err
+func knowledgeBaseQueryStatus(err error) string {
+	if err == nil {
+		return KnowledgeBaseAnswered
+	}
+	if strings.Contains(strings.ToLower(err.Error()), "timeout") || strings.Contains(err.Error(), "deadline exceeded") {
+		return KnowledgeBaseTimedOut
+	}
+	return KnowledgeBaseFailed
+}
+
+// skippedKnowledgeBaseError is the error of a knowledge base skipped by its bulkhead, the
+// error of the answer when every knowledge base was skipped
+func skippedKnowledgeBaseError(knowledgeBaseId string, status string) error {
+	if status == KnowledgeBaseRejected {
+		return errors.NewThrottlingError(fmt.Sprintf("knowledge base %s has too many queries in flight", knowledgeBaseId), nil)
+	}
+	return errors.NewKnowledgeBaseError(fmt.Sprintf("knowledge base %s is suspended after repeated failures", knowledgeBaseId), nil)
+}
+
+func isValidationError(err error) bool {
+	bedrockErr, ok := err.(*errors.BedrockError)
+	return ok && bedrockErr.Code == errors.ErrCodeValidation
+}
+
+// synthesizeAnswers merges the answers of several knowledge bases. With prioritized set the
+// answers are labelled with their priority, which decides conflicts before recency does.
+func (c *BedrockKBClient) synthesizeAnswers(ctx context.Context, question string, combinedAnswers string, relatedDocuments []string, prioritized bool) (_ string, err error) {
+	generativeModelId := c.generativeModel(ctx)
+	ctx, span := tracing.Start(ctx, "BedrockKBClient.synthesizeAnswers", tracing.AttrModelId.String(generativeModelId))
+	defer func() { tracing.End(span, err) }()
+
+	fmt.Printf("DEBUG: synthesizeAnswers called with modelId: %s\n", generativeModelId)
+
+	// Build document metadata context
+	var documentContext strings.Builder
+	if len(relatedDocuments) > 0 {
+		documentContext.WriteString("\n\nReference Documents (for version/date analysis):\n")
+		for i, docUrl := range relatedDocuments {
+			documentContext.WriteString(fmt.Sprintf("%d. %s\n", i+1, docUrl))
+		}
+	}
+
+	// Conflicts between knowledge bases of different weights are decided by priority
+	var priorityProtocol string
+	if prioritized {
+		priorityProtocol = `
+#### CRITICAL: Knowledge Base Priority
+Each answer is labelled with its knowledge base and priority, 1 being the highest.
+If answers contradict, ALWAYS use the answer with the higher priority, even when a lower-priority answer cites a more recent document.
+Apply the Recency Resolution Protocol below only between answers of the same priority.
+`
+	}
+
+	// Create synthesis prompt
+	userMessage := fmt.Sprintf(`You have received multiple answers from different knowledge bases for the same question. Synthesize them into ONE clear, coherent answer.
+
+Original Question: %s
+
+Multiple Answers:
+%s
+%s%s
+%s`, question, combinedAnswers, documentContext.String(), priorityProtocol, c.synthesisInstructions(ctx))
+
+	fmt.Printf("DEBUG: Calling Bedrock Converse API...\n")
+
+	// Use Bedrock Runtime Converse API for direct model invocation
+	generation := GenerationSettingsFromContext(ctx)
+	converseInput := &bedrockruntime.ConverseInput{
+		Messages: []rttypes.Message{
+			{
+				Role: rttypes.ConversationRoleUser,
+				Content: []rttypes.ContentBlock{
+					&rttypes.ContentBlockMemberText{
+						Value: userMessage,
+					},
+				},
+			},
+		},
+		InferenceConfig: &rttypes.InferenceConfiguration{
+			MaxTokens:   aws.Int32(int32(generation.MaxTokensFor(ctx))),
+			Temperature: aws.Float32(generation.TemperatureOr(0.3)), // Lower temperature for more focused synthesis
+		},
+	}
+	if generation.InstructionSuffix != "" {
+		converseInput.System = []rttypes.SystemContentBlock{
+			&rttypes.SystemContentBlockMemberText{Value: generation.InstructionSuffix},
+		}
+	}
+
+	var output *bedrockruntime.ConverseOutput
+	err = c.withModelFallback(ctx, func(modelId string) (err error) {
+		// Get the model identifier of the region (inference profile for Claude Haiku)
+		converseInput.ModelId = aws.String(invocationModelId(modelId))
+		fmt.Printf("DEBUG: Using model ID: %s\n", *converseInput.ModelId)
+		output, err = converse(ctx, c.runtimeClient, converseInput)
+		if err == nil {
+			recordConverseUsage(ctx, modelId, output.Usage)
+		}
+		return err
+	})
+	if err != nil {
+		fmt.Printf("ERROR: Converse API call failed: %v\n", err)
+		return "", fmt.Errorf("synthesis converse API failed: %w", err)
+	}
+
+	fmt.Printf("DEBUG: Converse API call successful, extracting response...\n")
+
+	// Extract the response text
+	if output.Output != nil {
+		if msg, ok := output.Output.(*rttypes.ConverseOutputMemberMessage); ok {
+			if len(msg.Value.Content) > 0 {
+				if textBlock, ok := msg.Value.Content[0].(*rttypes.ContentBlockMemberText); ok {
+					fmt.Printf("DEBUG: Successfully extracted synthesized text\n")
+					cleanedAnswer := utils.CleanMarkdown(textBlock.Value)
+					return cleanedAnswer, nil
+				}
+			}
+		}
+	}
+
+	fmt.Printf("ERROR: Failed to extract text from Converse response\n")
+	return "", fmt.Errorf("no synthesis output received")
+}
+
+func (c *BedrockKBClient) handleAWSError(err error) error {
+	errMsg := err.Error()
+
+	if contains(errMsg, "ValidationException") || contains(errMsg, "invalid") {
+		return errors.NewValidationError(fmt.Sprintf("invalid knowledge base query: %v", err))
+	}
+
+	if contains(errMsg, "ThrottlingException") || contains(errMsg, "TooManyRequestsException") {
+		return errors.NewThrottlingError("knowledge base service throttled", err)
+	}
+
+	if contains(errMsg, "AccessDeniedException") || contains(errMsg, "UnauthorizedException") {
+		return errors.NewAWSServiceError("invalid or missing AWS credentials", err)
+	}
+
+	if contains(errMsg, "ResourceNotFoundException") {
+		return errors.NewKnowledgeBaseError(fmt.Sprintf("resource not found: %v", err), err)
+	}
+
+	if contains(errMsg, "ServiceUnavailableException") || contains(errMsg, "InternalServerException") {
+		return errors.NewAWSServiceError("knowledge base service unavailable", err)
+	}
+
+	if contains(errMsg, "TimeoutException") || contains(errMsg, "timeout") {
+		return errors.NewAWSServiceError("knowledge base query timeout", err)
+	}
+
+	return errors.NewKnowledgeBaseError("knowledge base query failed", err)
+}
+
+// hasDifferentWeights reports whether the knowledge bases do not all have the same weight
+func hasDifferentWeights(knowledgeBases []config.KnowledgeBase) bool {
+	for _, knowledgeBase := range knowledgeBases {
+		if knowledgeBase.Weight != knowledgeBases[0].Weight {
+			return true
+		}
+	}
+	return false
+}
+
+// priority ranks the knowledge base at index i of knowledgeBases, which are ordered by
+// weight: 1 for the highest weight, knowledge bases of equal weight share a rank
+func priority(knowledgeBases []config.KnowledgeBase, i int) int {
+	rank := 1
+	for j := 1; j <= i; j++ {
+		if knowledgeBases[j].Weight < knowledgeBases[j-1].Weight {
+			rank++
+		}
+	}
+	return rank
+}
@@ -1,153 +1,256 @@
-package aws
-
-import (
-	"context"
-	"testing"
-
-	"github.com/leanovate/gopter"
-	"github.com/leanovate/gopter/gen"
-	"github.com/leanovate/gopter/prop"
-)
-
-// Feature: bedrock-question-search, Property 6: Knowledge base results are extracted correctly
-// Validates: Requirements 3.2
-func TestKBResultExtraction_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("KB responses with text are extracted correctly", prop.ForAll(
-		func(answerText string) bool {
-			mockClient := &MockKBClient{
-				response: answerText,
-			}
-
-			result, _, err := mockClient.QueryKnowledgeBase(context.Background(), "test question", false)
-
-			// Should not error
-			if err != nil {
-				return false
-			}
-
-			// Should return the answer text
-			return result == answerText
-		},
-		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 }),
-	))
-
-	properties.Property("empty KB responses return empty string", prop.ForAll(
-		func(question string) bool {
-			mockClient := &MockKBClient{
-				response: "",
-			}
-
-			result, _, err := mockClient.QueryKnowledgeBase(context.Background(), question, false)
-
-			// Should not error
-			if err != nil {
-				return false
-			}
-
-			// Should return empty string
-			return result == ""
-		},
-		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 }),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-// Feature: bedrock-question-search, Property 7: RetrieveAndGenerate returns AI-generated answer
-// Validates: Requirements 4.3
-func TestRetrieveAndGenerate_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("generated answer is returned correctly", prop.ForAll(
-		func(generatedAnswer string) bool {
-			if len(generatedAnswer) == 0 {
-				return true // Skip empty inputs
-			}
-
-			mockClient := &MockKBClient{
-				response: generatedAnswer,
-			}
-
-			result, _, err := mockClient.QueryKnowledgeBase(context.Background(), "test question", false)
-
-			if err != nil {
-				return false
-			}
-
-			// Should return the generated answer
-			return result == generatedAnswer
-		},
-		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 }),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-// Unit tests for KB client
-func TestBedrockKBClient_HandleAWSError(t *testing.T) {
-	client := &BedrockKBClient{
-		knowledgeBaseId: "test-kb",
-	}
-
-	tests := []struct {
-		name         string
-		errorMsg     string
-		expectedCode string
-	}{
-		{
-			name:         "validation exception",
-			errorMsg:     "ValidationException: invalid query",
-			expectedCode: "VALIDATION_ERROR",
-		},
-		{
-			name:         "throttling exception",
-			errorMsg:     "ThrottlingException: rate exceeded",
-			expectedCode: "THROTTLING_ERROR",
-		},
-		{
-			name:         "resource not found",
-			errorMsg:     "ResourceNotFoundException: KB not found",
-			expectedCode: "KB_ERROR",
-		},
-		{
-			name:         "service unavailable",
-			errorMsg:     "ServiceUnavailableException: service down",
-			expectedCode: "AWS_SERVICE_ERROR",
-		},
-		{
-			name:         "timeout",
-			errorMsg:     "TimeoutException: request timeout",
-			expectedCode: "AWS_SERVICE_ERROR",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := &mockError{msg: tt.errorMsg}
-			bedrockErr := client.handleAWSError(err)
-
-			if bedrockErr == nil {
-				t.Fatal("expected error, got nil")
-			}
-		})
-	}
-}
-
-
-
-// Mock clients for testing
-type MockKBClient struct {
-	response string
-	err      error
-}
-
-func (m *MockKBClient) QueryKnowledgeBase(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
-	if m.err != nil {
-		return "", nil, m.err
-	}
-	return m.response, []string{}, nil
-}
-
-
+package aws
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/warnings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+)
+
+// Feature: bedrock-question-search, Property 6: Knowledge base results are extracted correctly
+// Validates: Requirements 3.2
+func TestKBResultExtraction_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("KB responses with text are extracted correctly", prop.ForAll(
+		func(answerText string) bool {
+			mockClient := &MockKBClient{
+				response: answerText,
+			}
+
+			result, _, err := mockClient.QueryKnowledgeBase(context.Background(), "test question", false)
+
+			// Should not error
+			if err != nil {
+				return false
+			}
+
+			// Should return the answer text
+			return result == answerText
+		},
+		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 }),
+	))
+
+	properties.Property("empty KB responses return empty string", prop.ForAll(
+		func(question string) bool {
+			mockClient := &MockKBClient{
+				response: "",
+			}
+
+			result, _, err := mockClient.QueryKnowledgeBase(context.Background(), question, false)
+
+			// Should not error
+			if err != nil {
+				return false
+			}
+
+			// Should return empty string
+			return result == ""
+		},
+		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 }),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// Feature: bedrock-question-search, Property 7: RetrieveAndGenerate returns AI-generated answer
+// Validates: Requirements 4.3
+func TestRetrieveAndGenerate_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("generated answer is returned correctly", prop.ForAll(
+		func(generatedAnswer string) bool {
+			if len(generatedAnswer) == 0 {
+				return true // Skip empty inputs
+			}
+
+			mockClient := &MockKBClient{
+				response: generatedAnswer,
+			}
+
+			result, _, err := mockClient.QueryKnowledgeBase(context.Background(), "test question", false)
+
+			if err != nil {
+				return false
+			}
+
+			// Should return the generated answer
+			return result == generatedAnswer
+		},
+		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 }),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// Unit tests for KB client
+func TestBedrockKBClient_HandleAWSError(t *testing.T) {
+	client := &BedrockKBClient{
+		knowledgeBases: func() []config.KnowledgeBase { return []config.KnowledgeBase{{Id: "test-kb", Weight: 1, Enabled: true}} },
+	}
+
+	tests := []struct {
+		name         string
+		errorMsg     string
+		expectedCode string
+	}{
+		{
+			name:         "validation exception",
+			errorMsg:     "ValidationException: invalid query",
+			expectedCode: "VALIDATION_ERROR",
+		},
+		{
+			name:         "throttling exception",
+			errorMsg:     "ThrottlingException: rate exceeded",
+			expectedCode: "THROTTLING_ERROR",
+		},
+		{
+			name:         "resource not found",
+			errorMsg:     "ResourceNotFoundException: KB not found",
+			expectedCode: "KB_ERROR",
+		},
+		{
+			name:         "service unavailable",
+			errorMsg:     "ServiceUnavailableException: service down",
+			expectedCode: "AWS_SERVICE_ERROR",
+		},
+		{
+			name:         "timeout",
+			errorMsg:     "TimeoutException: request timeout",
+			expectedCode: "AWS_SERVICE_ERROR",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := &mockError{msg: tt.errorMsg}
+			bedrockErr := client.handleAWSError(err)
+
+			if bedrockErr == nil {
+				t.Fatal("expected error, got nil")
+			}
+		})
+	}
+}
+
+
+
+// Mock clients for testing
+type MockKBClient struct {
+	response string
+	err      error
+}
+
+func (m *MockKBClient) QueryKnowledgeBase(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	if m.err != nil {
+		return "", nil, m.err
+	}
+	return m.response, []string{}, nil
+}
+
+func (m *MockKBClient) QueryMultipleKnowledgeBases(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	return m.QueryKnowledgeBase(ctx, question, enableRelateDocument)
+}
+
+func (m *MockKBClient) RetrieveFromKnowledgeBases(ctx context.Context, question string, numberOfResults int) ([]KnowledgeBaseRetrieval, error) {
+	return nil, nil
+}
+
+
+
+func TestKnowledgeBasePriority(t *testing.T) {
+	knowledgeBases := []config.KnowledgeBase{
+		{Id: "hr", Weight: 3},
+		{Id: "products", Weight: 1},
+		{Id: "faq", Weight: 1},
+		{Id: "archive", Weight: 0.5},
+	}
+
+	if !hasDifferentWeights(knowledgeBases) {
+		t.Error("expected the weights to differ")
+	}
+	if hasDifferentWeights(knowledgeBases[1:3]) {
+		t.Error("expected equal weights not to be prioritized")
+	}
+
+	expected := []int{1, 2, 2, 3}
+	for i, rank := range expected {
+		if got := priority(knowledgeBases, i); got != rank {
+			t.Errorf("expected %s to have priority %d, got %d", knowledgeBases[i].Id, rank, got)
+		}
+	}
+}
+
+func TestQueryMultipleKnowledgeBases_AnswersWithoutSlowKnowledgeBase(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/converse") {
+			w.Write([]byte(`{"output": {"message": {"role": "assistant", "content": [{"text": "The fee is 100 baht."}]}}, "stopReason": "end_turn"}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "kb-slow") {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"output": {"text": "The fee is 100 baht."}, "sessionId": "session-1"}`))
+	}))
+	defer server.Close()
+
+	cfg := aws.Config{
+		Region:           "ap-southeast-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		BaseEndpoint:     aws.String(server.URL),
+		RetryMaxAttempts: 1,
+	}
+	knowledgeBases := []config.KnowledgeBase{
+		{Id: "kb-fees", Label: "Fees", Weight: 1, Enabled: true},
+		{Id: "kb-slow", Label: "Archive", Weight: 1, Enabled: true, TimeoutMs: 100},
+	}
+	client := NewBedrockKBClient(cfg,
+		func() []config.KnowledgeBase { return knowledgeBases },
+		func() string { return "anthropic.claude-3-haiku-20240307-v1:0" },
+		nil, "ap-southeast-1",
+		func(config.KnowledgeBase, string) string { return "" },
+		func() string { return "" },
+		nil, NewPublicDocumentLinker("ap-southeast-1"), "", RetrievalSettings{}, GenerationSettings{},
+		NewKnowledgeBaseBulkheads(0, 0, 0), time.Minute)
+
+	ctx, collected := warnings.WithCollector(context.Background())
+	ctx, statuses := WithKnowledgeBaseStatusRecorder(ctx)
+	start := time.Now()
+	answer, _, err := client.QueryMultipleKnowledgeBases(ctx, "What is the fee?", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the slow knowledge base to be cancelled after its timeout, took %v", elapsed)
+	}
+	if answer != "The fee is 100 baht." {
+		t.Errorf("expected the answer of the other knowledge base, got %q", answer)
+	}
+
+	recorded := statuses.List()
+	if len(recorded) != 2 || recorded[0].Status != KnowledgeBaseAnswered || recorded[1].Status != KnowledgeBaseTimedOut || !statuses.Partial() {
+		t.Errorf("expected a partial answer without the slow knowledge base, got %+v", recorded)
+	}
+	if list := collected.List(); len(list) != 1 || list[0].Code != warnings.CodeKnowledgeBaseSkipped {
+		t.Errorf("expected a knowledge_base_skipped warning, got %+v", list)
+	}
+}
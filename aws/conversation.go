@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxConversationIdLength bounds the session IDs accepted from clients
+const maxConversationIdLength = 4096
+
+// Conversation holds the Bedrock RetrieveAndGenerate sessions of one chat conversation, one
+// per knowledge base since every knowledge base is queried separately. Clients get it back
+// as an opaque session ID and send it with follow-up questions, so any instance can continue
+// the conversation without server-side state.
+type Conversation struct {
+	mu       sync.Mutex
+	sessions map[string]string // Bedrock session ID by knowledge base ID
+	expired  bool
+}
+
+// ParseConversation decodes a session ID returned by an earlier answer. An empty ID starts
+// a new conversation.
+func ParseConversation(sessionId string) (*Conversation, error) {
+	conversation := &Conversation{sessions: map[string]string{}}
+	sessionId = strings.TrimSpace(sessionId)
+	if sessionId == "" {
+		return conversation, nil
+	}
+	if len(sessionId) > maxConversationIdLength {
+		return nil, fmt.Errorf("session ID is too long")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(sessionId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session ID: %w", err)
+	}
+	if err := json.Unmarshal(data, &conversation.sessions); err != nil {
+		return nil, fmt.Errorf("invalid session ID: %w", err)
+	}
+	return conversation, nil
+}
+
+// SessionId encodes the conversation for the client, empty when no knowledge base started
+// a session
+func (c *Conversation) SessionId() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.sessions) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(c.sessions)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Expired reports whether Bedrock invalidated a session of the conversation while
+// answering, so the answer was generated without the earlier questions
+func (c *Conversation) Expired() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.expired
+}
+
+func (c *Conversation) session(knowledgeBaseId string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessions[knowledgeBaseId]
+}
+
+func (c *Conversation) setSession(knowledgeBaseId string, sessionId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions[knowledgeBaseId] = sessionId
+}
+
+func (c *Conversation) expire(knowledgeBaseId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sessions, knowledgeBaseId)
+	c.expired = true
+}
+
+type conversationKey struct{}
+
+// WithConversation attaches the conversation of a request to its context, so knowledge
+// base queries continue its sessions
+func WithConversation(ctx context.Context, conversation *Conversation) context.Context {
+	return context.WithValue(ctx, conversationKey{}, conversation)
+}
+
+// ConversationFromContext returns the conversation attached to the context, nil when the
+// request is not part of one
+func ConversationFromContext(ctx context.Context) *Conversation {
+	conversation, _ := ctx.Value(conversationKey{}).(*Conversation)
+	return conversation
+}
+
+// isSessionExpired reports whether Bedrock rejected a request because its session expired
+// or does not exist
+func isSessionExpired(err error) bool {
+	errMsg := err.Error()
+	return (contains(errMsg, "ValidationException") || contains(errMsg, "ResourceNotFoundException")) &&
+		strings.Contains(strings.ToLower(errMsg), "session")
+}
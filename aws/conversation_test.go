@@ -0,0 +1,64 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestConversation_RoundTrip(t *testing.T) {
+	conversation, err := ParseConversation("")
+	if err != nil || conversation.SessionId() != "" {
+		t.Fatalf("expected a new conversation without a session ID, got %q %v", conversation.SessionId(), err)
+	}
+
+	conversation.setSession("KB1", "session-1")
+	conversation.setSession("KB2", "session-2")
+	parsed, err := ParseConversation(conversation.SessionId())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.session("KB1") != "session-1" || parsed.session("KB2") != "session-2" || parsed.Expired() {
+		t.Errorf("expected both sessions to survive the round trip, got %+v", parsed.sessions)
+	}
+
+	parsed.expire("KB1")
+	if !parsed.Expired() || parsed.session("KB1") != "" || parsed.session("KB2") != "session-2" {
+		t.Errorf("expected only KB1 to be expired, got %+v", parsed.sessions)
+	}
+}
+
+func TestParseConversation_RejectsInvalidIds(t *testing.T) {
+	for _, sessionId := range []string{"not base64!", "bm90IGpzb24", string(make([]byte, maxConversationIdLength+1))} {
+		if _, err := ParseConversation(sessionId); err == nil {
+			t.Errorf("expected an error for %.20q", sessionId)
+		}
+	}
+}
+
+func TestConversationFromContext(t *testing.T) {
+	if ConversationFromContext(context.Background()) != nil {
+		t.Error("expected no conversation")
+	}
+	conversation, _ := ParseConversation("")
+	if ConversationFromContext(WithConversation(context.Background(), conversation)) != conversation {
+		t.Error("expected the attached conversation")
+	}
+}
+
+func TestIsSessionExpired(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{fmt.Errorf("ValidationException: Session with Id 1234 is not valid. Please check and try again."), true},
+		{fmt.Errorf("ResourceNotFoundException: Session 1234 not found"), true},
+		{fmt.Errorf("ValidationException: Input text is too long"), false},
+		{fmt.Errorf("ThrottlingException: session rate exceeded"), false},
+	}
+	for _, tt := range tests {
+		if isSessionExpired(tt.err) != tt.expected {
+			t.Errorf("expected %v for %v", tt.expected, tt.err)
+		}
+	}
+}
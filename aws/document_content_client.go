@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"teletubpax-api/errors"
+	"teletubpax-api/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// maxSourceDocumentBytes bounds the download of a single source document
+const maxSourceDocumentBytes = 50 << 20
+
+type DocumentContentClient interface {
+	// GetDocumentText returns the full extracted text of a source document
+	GetDocumentText(ctx context.Context, s3Uri string) (string, error)
+}
+
+// ContentCache stores extracted document text by S3 key and ETag
+type ContentCache interface {
+	Get(key string) (string, bool)
+	Put(key string, text string)
+}
+
+// S3DocumentContentClient reads source documents from S3 and extracts their text. The text
+// is cached by S3 key and ETag, so a document is only downloaded and extracted again after
+// its object has been replaced; each lookup costs a HeadObject request.
+type S3DocumentContentClient struct {
+	client *s3.Client
+	cache  ContentCache // Optional, every lookup downloads the document when nil
+}
+
+func NewS3DocumentContentClient(cfg aws.Config, cache ContentCache) *S3DocumentContentClient {
+	return &S3DocumentContentClient{
+		client: s3.NewFromConfig(cfg),
+		cache:  cache,
+	}
+}
+
+func (c *S3DocumentContentClient) GetDocumentText(ctx context.Context, s3Uri string) (string, error) {
+	bucket, key, ok := splitS3Uri(s3Uri)
+	if !ok {
+		return "", errors.NewValidationError(fmt.Sprintf("invalid s3 URI: %s", s3Uri))
+	}
+
+	head, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", errors.NewAWSServiceError("failed to read document metadata", err)
+	}
+	if aws.ToInt64(head.ContentLength) > maxSourceDocumentBytes {
+		return "", fmt.Errorf("document %s exceeds %d bytes", s3Uri, maxSourceDocumentBytes)
+	}
+
+	etag := strings.Trim(aws.ToString(head.ETag), `"`)
+	cacheKey := bucket + "/" + key + "@" + etag
+	if c.cache != nil {
+		if text, ok := c.cache.Get(cacheKey); ok {
+			return text, nil
+		}
+	}
+
+	// IfMatch fails the download when the object was replaced after the HeadObject request,
+	// so the text is never cached under a stale ETag
+	object, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		IfMatch: head.ETag,
+	})
+	if err != nil {
+		return "", errors.NewAWSServiceError("failed to download document", err)
+	}
+	defer object.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(object.Body, maxSourceDocumentBytes))
+	if err != nil {
+		return "", errors.NewAWSServiceError("failed to download document", err)
+	}
+
+	text, err := utils.ExtractDocumentText(key, data)
+	if err != nil {
+		return "", err
+	}
+	if c.cache != nil && text != "" {
+		c.cache.Put(cacheKey, text)
+	}
+	return text, nil
+}
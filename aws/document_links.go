@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"teletubpax-api/logger"
+	"time"
+)
+
+// DocumentLinker turns the s3:// URI of a source document into the link returned to
+// callers, in relatedDocuments, citations and document listings
+type DocumentLinker interface {
+	DocumentLink(ctx context.Context, s3Uri string) string
+}
+
+// PublicDocumentLinker links to the bucket URL, which only opens for public buckets
+type PublicDocumentLinker struct {
+	region string
+}
+
+func NewPublicDocumentLinker(region string) *PublicDocumentLinker {
+	return &PublicDocumentLinker{
+		region: region,
+	}
+}
+
+func (l *PublicDocumentLinker) DocumentLink(ctx context.Context, s3Uri string) string {
+	return publicDocumentUrl(s3Uri, l.region)
+}
+
+// PresignedDocumentLinker links to time-limited pre-signed URLs, for private buckets. A
+// document that cannot be signed is linked to its bucket URL.
+type PresignedDocumentLinker struct {
+	client ObjectLinkClient
+	region string
+	expiry time.Duration
+}
+
+func NewPresignedDocumentLinker(client ObjectLinkClient, region string, expiry time.Duration) *PresignedDocumentLinker {
+	return &PresignedDocumentLinker{
+		client: client,
+		region: region,
+		expiry: expiry,
+	}
+}
+
+func (l *PresignedDocumentLinker) DocumentLink(ctx context.Context, s3Uri string) string {
+	link, err := l.client.PresignGetObject(ctx, s3Uri, l.expiry)
+	if err != nil {
+		logger.WithContext(ctx).Warn("Failed to pre-sign document link, using the bucket URL", map[string]interface{}{
+			"uri":   s3Uri,
+			"error": err.Error(),
+		})
+		return publicDocumentUrl(s3Uri, l.region)
+	}
+	return link
+}
+
+// UnsignedDocumentLink returns the bucket URL of a pre-signed link, which identifies the
+// document in stores, caches and requests the way links did before they were signed.
+// Other links are returned unchanged.
+func UnsignedDocumentLink(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Query().Get("X-Amz-Signature") == "" {
+		return link
+	}
+	return parsed.Scheme + "://" + parsed.Host + parsed.Path
+}
+
+// publicDocumentUrl converts s3://bucket/key to the virtual-hosted bucket URL
+func publicDocumentUrl(s3Uri string, region string) string {
+	parts := strings.SplitN(strings.TrimPrefix(s3Uri, "s3://"), "/", 2)
+	if len(parts) != 2 {
+		return s3Uri
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", parts[0], region, parts[1])
+}
@@ -0,0 +1,62 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type fakeObjectLinkClient struct {
+	err    error
+	expiry time.Duration
+}
+
+func (c *fakeObjectLinkClient) PresignGetObject(ctx context.Context, s3Uri string, expiry time.Duration) (string, error) {
+	c.expiry = expiry
+	if c.err != nil {
+		return "", c.err
+	}
+	return "https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/fees.pdf?X-Amz-Expires=900&X-Amz-Signature=abc", nil
+}
+
+func TestPublicDocumentLinker(t *testing.T) {
+	linker := NewPublicDocumentLinker("ap-southeast-1")
+	if got := linker.DocumentLink(context.Background(), "s3://docs/2025/01/fees.pdf"); got != "https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/fees.pdf" {
+		t.Errorf("unexpected link %q", got)
+	}
+}
+
+func TestPresignedDocumentLinker(t *testing.T) {
+	client := &fakeObjectLinkClient{}
+	linker := NewPresignedDocumentLinker(client, "ap-southeast-1", 15*time.Minute)
+
+	got := linker.DocumentLink(context.Background(), "s3://docs/2025/01/fees.pdf")
+	if got != "https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/fees.pdf?X-Amz-Expires=900&X-Amz-Signature=abc" || client.expiry != 15*time.Minute {
+		t.Errorf("expected the pre-signed link, got %q with expiry %v", got, client.expiry)
+	}
+
+	// A link that cannot be signed falls back to the bucket URL
+	client.err = fmt.Errorf("no credentials")
+	if got := linker.DocumentLink(context.Background(), "s3://docs/2025/01/fees.pdf"); got != "https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/fees.pdf" {
+		t.Errorf("expected the bucket URL, got %q", got)
+	}
+}
+
+func TestUnsignedDocumentLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/fees.pdf?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Signature=abc", "https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/fees.pdf"},
+		// Pre-signed paths are escaped, bucket URLs are not
+		{"https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/%E0%B8%84%E0%B9%88%E0%B8%B2.pdf?X-Amz-Signature=abc", "https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/ค่า.pdf"},
+		{"https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/ค่า.pdf", "https://docs.s3.ap-southeast-1.amazonaws.com/2025/01/ค่า.pdf"},
+		{"https://docs.s3.ap-southeast-1.amazonaws.com/fees.pdf?version=2", "https://docs.s3.ap-southeast-1.amazonaws.com/fees.pdf?version=2"},
+	}
+	for _, test := range tests {
+		if got := UnsignedDocumentLink(test.link); got != test.want {
+			t.Errorf("UnsignedDocumentLink(%q) = %q, want %q", test.link, got, test.want)
+		}
+	}
+}
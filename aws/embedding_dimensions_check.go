@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagent"
+)
+
+// EmbeddingDimensionsError is returned when the embedding client produces vectors of
+// another size than a knowledge base indexes
+type EmbeddingDimensionsError struct {
+	KnowledgeBaseId string
+	Configured      int
+	Indexed         int
+}
+
+func (e *EmbeddingDimensionsError) Error() string {
+	return fmt.Sprintf("knowledge base %s indexes %d-dimension embeddings, but the embedding client produces %d", e.KnowledgeBaseId, e.Indexed, e.Configured)
+}
+
+type knowledgeBaseAPI interface {
+	GetKnowledgeBase(ctx context.Context, params *bedrockagent.GetKnowledgeBaseInput, optFns ...func(*bedrockagent.Options)) (*bedrockagent.GetKnowledgeBaseOutput, error)
+}
+
+// EmbeddingDimensionsCheck compares at startup the size of the embeddings the service
+// produces with the vector index of the knowledge bases, so a mismatched
+// EMBEDDING_DIMENSIONS fails the deployment instead of the vector comparisons
+type EmbeddingDimensionsCheck struct {
+	client knowledgeBaseAPI
+}
+
+func NewEmbeddingDimensionsCheck(cfg aws.Config) *EmbeddingDimensionsCheck {
+	return &EmbeddingDimensionsCheck{
+		client: bedrockagent.NewFromConfig(cfg),
+	}
+}
+
+// Check compares the vector size of the embedding model with dimensions, 0 for its default,
+// against each knowledge base. Knowledge bases without a vector index, and models of
+// unknown size, are not checked. The error is an *EmbeddingDimensionsError for the first
+// knowledge base that differs, or the AWS error when a knowledge base could not be read.
+func (c *EmbeddingDimensionsCheck) Check(ctx context.Context, knowledgeBaseIds []string, modelId string, dimensions int) error {
+	configured := dimensions
+	if configured == 0 {
+		configured = defaultEmbeddingDimensions(modelId)
+	}
+	if configured == 0 {
+		return nil
+	}
+
+	for _, knowledgeBaseId := range knowledgeBaseIds {
+		output, err := c.client.GetKnowledgeBase(ctx, &bedrockagent.GetKnowledgeBaseInput{
+			KnowledgeBaseId: aws.String(knowledgeBaseId),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read knowledge base %s: %w", knowledgeBaseId, err)
+		}
+		if output.KnowledgeBase == nil || output.KnowledgeBase.KnowledgeBaseConfiguration == nil || output.KnowledgeBase.KnowledgeBaseConfiguration.VectorKnowledgeBaseConfiguration == nil {
+			continue
+		}
+
+		vector := output.KnowledgeBase.KnowledgeBaseConfiguration.VectorKnowledgeBaseConfiguration
+		indexed := defaultEmbeddingDimensions(aws.ToString(vector.EmbeddingModelArn))
+		if vector.EmbeddingModelConfiguration != nil && vector.EmbeddingModelConfiguration.BedrockEmbeddingModelConfiguration != nil {
+			if set := vector.EmbeddingModelConfiguration.BedrockEmbeddingModelConfiguration.Dimensions; set != nil {
+				indexed = int(*set)
+			}
+		}
+		if indexed != 0 && indexed != configured {
+			return &EmbeddingDimensionsError{KnowledgeBaseId: knowledgeBaseId, Configured: configured, Indexed: indexed}
+		}
+	}
+	return nil
+}
+
+// defaultEmbeddingDimensions returns the vector size of a model ID or ARN without a
+// dimensions setting, 0 when it is not known
+func defaultEmbeddingDimensions(model string) int {
+	switch {
+	case strings.Contains(model, "amazon.titan-embed-text-v2"):
+		return 1024
+	case strings.Contains(model, "amazon.titan-embed-text-v1"), strings.Contains(model, "amazon.titan-embed-g1-text"):
+		return 1536
+	case strings.Contains(model, "cohere.embed-english-v3"), strings.Contains(model, "cohere.embed-multilingual-v3"):
+		return 1024
+	case strings.Contains(model, "cohere.embed-v4"):
+		return 1536
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,56 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagent"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagent/types"
+)
+
+type fakeKnowledgeBases map[string]*types.VectorKnowledgeBaseConfiguration
+
+func (f fakeKnowledgeBases) GetKnowledgeBase(ctx context.Context, params *bedrockagent.GetKnowledgeBaseInput, optFns ...func(*bedrockagent.Options)) (*bedrockagent.GetKnowledgeBaseOutput, error) {
+	return &bedrockagent.GetKnowledgeBaseOutput{KnowledgeBase: &types.KnowledgeBase{
+		KnowledgeBaseConfiguration: &types.KnowledgeBaseConfiguration{
+			VectorKnowledgeBaseConfiguration: f[aws.ToString(params.KnowledgeBaseId)],
+		},
+	}}, nil
+}
+
+func vectorIndex(modelArn string, dimensions int32) *types.VectorKnowledgeBaseConfiguration {
+	index := &types.VectorKnowledgeBaseConfiguration{EmbeddingModelArn: aws.String(modelArn)}
+	if dimensions > 0 {
+		index.EmbeddingModelConfiguration = &types.EmbeddingModelConfiguration{
+			BedrockEmbeddingModelConfiguration: &types.BedrockEmbeddingModelConfiguration{Dimensions: aws.Int32(dimensions)},
+		}
+	}
+	return index
+}
+
+func TestEmbeddingDimensionsCheck(t *testing.T) {
+	check := &EmbeddingDimensionsCheck{client: fakeKnowledgeBases{
+		"KB1": vectorIndex("arn:aws:bedrock:us-east-1::foundation-model/amazon.titan-embed-text-v2:0", 0),
+		"KB2": vectorIndex("arn:aws:bedrock:us-east-1::foundation-model/amazon.titan-embed-text-v2:0", 512),
+		"KB3": nil, // No vector index
+	}}
+	ctx := context.Background()
+
+	if err := check.Check(ctx, []string{"KB1", "KB3"}, "amazon.titan-embed-text-v2:0", 0); err != nil {
+		t.Errorf("expected the default dimensions to match, got %v", err)
+	}
+	if err := check.Check(ctx, []string{"KB2"}, "amazon.titan-embed-text-v2:0", 512); err != nil {
+		t.Errorf("expected 512 dimensions to match, got %v", err)
+	}
+
+	err := check.Check(ctx, []string{"KB1", "KB2"}, "amazon.titan-embed-text-v2:0", 256)
+	mismatch, ok := err.(*EmbeddingDimensionsError)
+	if !ok || mismatch.KnowledgeBaseId != "KB1" || mismatch.Indexed != 1024 || mismatch.Configured != 256 {
+		t.Errorf("expected a mismatch on KB1, got %v", err)
+	}
+
+	if err := check.Check(ctx, []string{"KB1"}, "custom-embedding-model", 0); err != nil {
+		t.Errorf("expected models of unknown size to be skipped, got %v", err)
+	}
+}
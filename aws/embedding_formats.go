@@ -0,0 +1,118 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"teletubpax-api/utils"
+)
+
+// EmbeddingOptions tune the embeddings of the models that support them
+type EmbeddingOptions struct {
+	Dimensions int  // Titan v2 vector size, 256, 512 or 1024; 0 for the model default
+	Normalize  bool // Titan v2 unit-length vectors
+}
+
+// embeddingFormat marshals the requests and parses the responses of a family of Bedrock
+// embedding models
+type embeddingFormat interface {
+	// maxTexts is the number of texts one request embeds
+	maxTexts() int
+	request(texts []string) ([]byte, error)
+	// response returns the vectors in the order of the texts, and the input tokens with
+	// whether they were estimated
+	response(body []byte, texts []string) ([][]float64, int64, bool, error)
+}
+
+// embeddingFormatFor picks the request format from the model ID, which may carry an
+// inference profile prefix such as "us.". Unknown models get the Titan v1 format.
+func embeddingFormatFor(modelId string, options EmbeddingOptions) embeddingFormat {
+	switch {
+	case strings.Contains(modelId, "cohere.embed"):
+		return cohereEmbeddingFormat{}
+	case strings.Contains(modelId, "amazon.titan-embed-text-v2"):
+		return titanEmbeddingFormat{options: options, v2: true}
+	default:
+		return titanEmbeddingFormat{}
+	}
+}
+
+type titanEmbedRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions int    `json:"dimensions,omitempty"`
+	Normalize  *bool  `json:"normalize,omitempty"`
+}
+
+type titanEmbedResponse struct {
+	Embedding           []float64 `json:"embedding"`
+	InputTextTokenCount int64     `json:"inputTextTokenCount"`
+}
+
+// titanEmbeddingFormat embeds one text per request. Only Titan v2 takes the options.
+type titanEmbeddingFormat struct {
+	options EmbeddingOptions
+	v2      bool
+}
+
+func (f titanEmbeddingFormat) maxTexts() int {
+	return 1
+}
+
+func (f titanEmbeddingFormat) request(texts []string) ([]byte, error) {
+	request := titanEmbedRequest{InputText: texts[0]}
+	if f.v2 {
+		request.Dimensions = f.options.Dimensions
+		request.Normalize = &f.options.Normalize
+	}
+	return json.Marshal(request)
+}
+
+func (f titanEmbeddingFormat) response(body []byte, texts []string) ([][]float64, int64, bool, error) {
+	var response titanEmbedResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, 0, false, err
+	}
+	return [][]float64{response.Embedding}, response.InputTextTokenCount, false, nil
+}
+
+// cohereMaxTexts is the most texts Cohere embedding models take per request
+const cohereMaxTexts = 96
+
+type cohereEmbedRequest struct {
+	Texts     []string `json:"texts"`
+	InputType string   `json:"input_type"`
+	Truncate  string   `json:"truncate"`
+}
+
+type cohereEmbedResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+// cohereEmbeddingFormat embeds texts as search queries, the questions and titles this
+// service compares, cutting texts beyond the model's input limit at the end. Cohere does
+// not report tokens, so they are estimated.
+type cohereEmbeddingFormat struct{}
+
+func (f cohereEmbeddingFormat) maxTexts() int {
+	return cohereMaxTexts
+}
+
+func (f cohereEmbeddingFormat) request(texts []string) ([]byte, error) {
+	return json.Marshal(cohereEmbedRequest{Texts: texts, InputType: "search_query", Truncate: "END"})
+}
+
+func (f cohereEmbeddingFormat) response(body []byte, texts []string) ([][]float64, int64, bool, error) {
+	var response cohereEmbedResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, 0, false, err
+	}
+	if len(response.Embeddings) != len(texts) {
+		return nil, 0, false, fmt.Errorf("%d embeddings returned for %d texts", len(response.Embeddings), len(texts))
+	}
+	var tokens int64
+	for _, text := range texts {
+		tokens += int64(utils.EstimateTokens(text))
+	}
+	return response.Embeddings, tokens, true, nil
+}
@@ -0,0 +1,44 @@
+package aws
+
+import (
+	"testing"
+)
+
+func TestEmbeddingFormatFor_Requests(t *testing.T) {
+	options := EmbeddingOptions{Dimensions: 512, Normalize: true}
+	tests := []struct {
+		modelId  string
+		texts    []string
+		expected string
+	}{
+		{"amazon.titan-embed-text-v1", []string{"ค่าธรรมเนียม"}, `{"inputText":"ค่าธรรมเนียม"}`},
+		{"amazon.titan-embed-text-v2:0", []string{"ค่าธรรมเนียม"}, `{"inputText":"ค่าธรรมเนียม","dimensions":512,"normalize":true}`},
+		{"us.cohere.embed-multilingual-v3", []string{"ค่าธรรมเนียม", "WAIVE-03"}, `{"texts":["ค่าธรรมเนียม","WAIVE-03"],"input_type":"search_query","truncate":"END"}`},
+	}
+
+	for _, tt := range tests {
+		body, err := embeddingFormatFor(tt.modelId, options).request(tt.texts)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.modelId, err)
+		}
+		if string(body) != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.modelId, tt.expected, body)
+		}
+	}
+}
+
+func TestEmbeddingFormatFor_Responses(t *testing.T) {
+	embeddings, tokens, estimated, err := embeddingFormatFor("amazon.titan-embed-text-v2:0", EmbeddingOptions{}).response([]byte(`{"embedding":[0.1,0.2],"inputTextTokenCount":7}`), []string{"q"})
+	if err != nil || len(embeddings) != 1 || embeddings[0][1] != 0.2 || tokens != 7 || estimated {
+		t.Errorf("unexpected Titan response %v %d %t %v", embeddings, tokens, estimated, err)
+	}
+
+	format := embeddingFormatFor("cohere.embed-english-v3", EmbeddingOptions{})
+	embeddings, tokens, estimated, err = format.response([]byte(`{"id":"1","embeddings":[[0.1],[0.3]],"response_type":"embeddings_floats"}`), []string{"first", "second"})
+	if err != nil || len(embeddings) != 2 || embeddings[1][0] != 0.3 || tokens == 0 || !estimated {
+		t.Errorf("unexpected Cohere response %v %d %t %v", embeddings, tokens, estimated, err)
+	}
+	if _, _, _, err := format.response([]byte(`{"embeddings":[[0.1]]}`), []string{"first", "second"}); err == nil {
+		t.Error("expected an error for a missing embedding")
+	}
+}
@@ -0,0 +1,49 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+type EventBusClient interface {
+	PutEvent(ctx context.Context, busName string, source string, detailType string, detail string) error
+}
+
+// EventBridgeClient puts events on EventBridge event buses
+type EventBridgeClient struct {
+	client *eventbridge.Client
+}
+
+func NewEventBridgeClient(cfg aws.Config) *EventBridgeClient {
+	return &EventBridgeClient{
+		client: eventbridge.NewFromConfig(cfg),
+	}
+}
+
+// PutEvent puts a single event with a JSON detail. PutEvents reports rejected entries in
+// the response instead of an error, so those are turned into errors too.
+func (c *EventBridgeClient) PutEvent(ctx context.Context, busName string, source string, detailType string, detail string) error {
+	output, err := c.client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []types.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(busName),
+				Source:       aws.String(source),
+				DetailType:   aws.String(detailType),
+				Detail:       aws.String(detail),
+			},
+		},
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to put event", err)
+	}
+	if output.FailedEntryCount > 0 && len(output.Entries) > 0 {
+		entry := output.Entries[0]
+		return errors.NewAWSServiceError("event was rejected", fmt.Errorf("%s: %s", aws.ToString(entry.ErrorCode), aws.ToString(entry.ErrorMessage)))
+	}
+	return nil
+}
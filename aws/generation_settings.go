@@ -0,0 +1,157 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"teletubpax-api/policy"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+const (
+	// MaxGenerationTokens bounds the generation limit a request may ask for
+	MaxGenerationTokens = 4096
+	// MaxInstructionSuffixLength bounds the instructions a request may add to the prompts
+	MaxInstructionSuffixLength = 1000
+	// DefaultPromptTemplate is the knowledge base prompt used when none is configured.
+	// $instructions$ is replaced with the knowledge base's instructions, $query$ and
+	// $search_results$ are filled in by Bedrock.
+	DefaultPromptTemplate = "$instructions$\n\nQuestion: $query$\n\nContext: $search_results$"
+)
+
+// GenerationSettings override the inference settings of the model calls that write an
+// answer: the knowledge base generation, answer synthesis and the retrieval-converse answer.
+// Requests set the temperature, maxTokens and suffix; TopP and PromptTemplate are only
+// configured, for the knowledge base generation.
+type GenerationSettings struct {
+	Temperature       *float64 // 0 to 1, nil for the default
+	TopP              *float64 // 0 to 1, nil for the default
+	MaxTokens         int      // 0 for the endpoint policy's maxTokens
+	InstructionSuffix string   // Added after the prompt's instructions, empty for none
+	PromptTemplate    string   // Knowledge base prompt, empty for DefaultPromptTemplate
+}
+
+// IsZero reports whether nothing is overridden
+func (s GenerationSettings) IsZero() bool {
+	return s.Temperature == nil && s.TopP == nil && s.MaxTokens <= 0 && s.InstructionSuffix == "" && s.PromptTemplate == ""
+}
+
+// Or fills the unset settings from defaults
+func (s GenerationSettings) Or(defaults GenerationSettings) GenerationSettings {
+	if s.Temperature == nil {
+		s.Temperature = defaults.Temperature
+	}
+	if s.TopP == nil {
+		s.TopP = defaults.TopP
+	}
+	if s.MaxTokens <= 0 {
+		s.MaxTokens = defaults.MaxTokens
+	}
+	if s.InstructionSuffix == "" {
+		s.InstructionSuffix = defaults.InstructionSuffix
+	}
+	if s.PromptTemplate == "" {
+		s.PromptTemplate = defaults.PromptTemplate
+	}
+	return s
+}
+
+// TemperatureOr returns the requested temperature, else the default
+func (s GenerationSettings) TemperatureOr(defaultValue float32) float32 {
+	if s.Temperature == nil {
+		return defaultValue
+	}
+	return float32(*s.Temperature)
+}
+
+// MaxTokensFor returns the requested generation limit, else the endpoint policy's
+func (s GenerationSettings) MaxTokensFor(ctx context.Context) int {
+	if s.MaxTokens > 0 {
+		return s.MaxTokens
+	}
+	return policy.FromContext(ctx, policy.Defaults(0)).MaxTokens
+}
+
+// Instructions returns the instructions with the requested suffix added
+func (s GenerationSettings) Instructions(instructions string) string {
+	if s.InstructionSuffix == "" {
+		return instructions
+	}
+	return strings.TrimSpace(instructions + "\n\n" + s.InstructionSuffix)
+}
+
+// Key identifies the settings, empty when nothing is set
+func (s GenerationSettings) Key() string {
+	if s.IsZero() {
+		return ""
+	}
+	key := fmt.Sprintf("maxTokens=%d", s.MaxTokens)
+	if s.Temperature != nil {
+		key += fmt.Sprintf(";temperature=%g", *s.Temperature)
+	}
+	if s.TopP != nil {
+		key += fmt.Sprintf(";topP=%g", *s.TopP)
+	}
+	if s.InstructionSuffix != "" {
+		key += ";suffix=" + s.InstructionSuffix
+	}
+	if s.PromptTemplate != "" {
+		key += ";template=" + s.PromptTemplate
+	}
+	return key
+}
+
+// GenerationConfiguration returns the RetrieveAndGenerate generation step for the
+// instructions, suffix included: the prompt template wraps them and the inference settings
+// that are set apply, Bedrock's defaults otherwise. It is nil when there is nothing to
+// configure.
+func (s GenerationSettings) GenerationConfiguration(instructions string) *types.GenerationConfiguration {
+	var generation types.GenerationConfiguration
+
+	// Without instructions Bedrock's default prompt is used
+	if instructions != "" {
+		template := s.PromptTemplate
+		if template == "" {
+			template = DefaultPromptTemplate
+		}
+		generation.PromptTemplate = &types.PromptTemplate{
+			TextPromptTemplate: aws.String(strings.ReplaceAll(template, "$instructions$", instructions)),
+		}
+	}
+
+	if s.Temperature != nil || s.TopP != nil || s.MaxTokens > 0 {
+		inference := &types.TextInferenceConfig{}
+		if s.Temperature != nil {
+			inference.Temperature = aws.Float32(float32(*s.Temperature))
+		}
+		if s.TopP != nil {
+			inference.TopP = aws.Float32(float32(*s.TopP))
+		}
+		if s.MaxTokens > 0 {
+			inference.MaxTokens = aws.Int32(int32(s.MaxTokens))
+		}
+		generation.InferenceConfig = &types.InferenceConfig{TextInferenceConfig: inference}
+	}
+
+	if generation.PromptTemplate == nil && generation.InferenceConfig == nil {
+		return nil
+	}
+	return &generation
+}
+
+type generationSettingsKey struct{}
+
+// WithGenerationSettings attaches the generation settings a request asked for to its context
+func WithGenerationSettings(ctx context.Context, settings GenerationSettings) context.Context {
+	return context.WithValue(ctx, generationSettingsKey{}, settings)
+}
+
+// GenerationSettingsFromContext returns the generation settings of the request, unset when
+// it did not ask for any
+func GenerationSettingsFromContext(ctx context.Context) GenerationSettings {
+	settings, _ := ctx.Value(generationSettingsKey{}).(GenerationSettings)
+	return settings
+}
@@ -0,0 +1,35 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestGenerationConfiguration(t *testing.T) {
+	if generation := (GenerationSettings{}).GenerationConfiguration(""); generation != nil {
+		t.Errorf("expected Bedrock's defaults without instructions or settings, got %+v", generation)
+	}
+
+	generation := (GenerationSettings{}).GenerationConfiguration("Answer briefly.")
+	if aws.ToString(generation.PromptTemplate.TextPromptTemplate) != "Answer briefly.\n\nQuestion: $query$\n\nContext: $search_results$" || generation.InferenceConfig != nil {
+		t.Errorf("expected the instructions in the default template, got %+v", generation)
+	}
+
+	// The request's temperature takes precedence over the configured one
+	requested, configuredTemperature, configuredTopP := 0.7, 0.1, 0.9
+	configured := GenerationSettings{Temperature: &configuredTemperature, TopP: &configuredTopP, MaxTokens: 500, PromptTemplate: "$search_results$\n\n$instructions$ $query$"}
+	generation = GenerationSettings{Temperature: &requested}.Or(configured).GenerationConfiguration("Answer briefly.")
+	if aws.ToString(generation.PromptTemplate.TextPromptTemplate) != "$search_results$\n\nAnswer briefly. $query$" {
+		t.Errorf("expected the instructions in the configured template, got %q", aws.ToString(generation.PromptTemplate.TextPromptTemplate))
+	}
+	inference := generation.InferenceConfig.TextInferenceConfig
+	if aws.ToFloat32(inference.Temperature) != 0.7 || aws.ToFloat32(inference.TopP) != 0.9 || aws.ToInt32(inference.MaxTokens) != 500 {
+		t.Errorf("expected temperature 0.7, topP 0.9 and 500 tokens, got %+v", inference)
+	}
+
+	generation = configured.GenerationConfiguration("")
+	if generation.PromptTemplate != nil || generation.InferenceConfig == nil {
+		t.Errorf("expected only the inference settings without instructions, got %+v", generation)
+	}
+}
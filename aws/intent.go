@@ -0,0 +1,26 @@
+package aws
+
+import (
+	"context"
+
+	"teletubpax-api/config"
+)
+
+type intentKey struct{}
+
+// WithIntent attaches the topic of the question to the context, so the knowledge base client
+// only searches the knowledge bases for that topic
+func WithIntent(ctx context.Context, intent string) context.Context {
+	return context.WithValue(ctx, intentKey{}, intent)
+}
+
+// IntentFromContext returns the topic of the question, empty when it was not classified
+func IntentFromContext(ctx context.Context) string {
+	intent, _ := ctx.Value(intentKey{}).(string)
+	return intent
+}
+
+// routedKnowledgeBases returns the enabled knowledge bases for the question's intent
+func (c *BedrockKBClient) routedKnowledgeBases(ctx context.Context) []config.KnowledgeBase {
+	return config.KnowledgeBasesForIntent(c.knowledgeBases(), IntentFromContext(ctx))
+}
@@ -0,0 +1,178 @@
+package aws
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Statuses of a knowledge base in a multi knowledge base answer
+const (
+	KnowledgeBaseAnswered  = "answered"
+	KnowledgeBaseFailed    = "failed"
+	KnowledgeBaseTimedOut  = "timeout"
+	KnowledgeBaseRejected  = "rejected"  // Its bulkhead was full, the query was not sent
+	KnowledgeBaseSuspended = "suspended" // It spent its failure budget, the query was not sent
+)
+
+// KnowledgeBaseStatus is how one knowledge base fared in a multi knowledge base answer
+type KnowledgeBaseStatus struct {
+	KnowledgeBaseId string `json:"knowledgeBaseId"`
+	Status          string `json:"status"`
+	DurationMs      int64  `json:"durationMs"` // 0 when the query was not sent
+}
+
+// KnowledgeBaseStatusRecorder collects the knowledge base statuses of one request
+type KnowledgeBaseStatusRecorder struct {
+	mu       sync.Mutex
+	statuses []KnowledgeBaseStatus
+}
+
+type knowledgeBaseStatusKey struct{}
+
+// WithKnowledgeBaseStatusRecorder attaches a new recorder to the context of a request
+func WithKnowledgeBaseStatusRecorder(ctx context.Context) (context.Context, *KnowledgeBaseStatusRecorder) {
+	recorder := &KnowledgeBaseStatusRecorder{}
+	return context.WithValue(ctx, knowledgeBaseStatusKey{}, recorder), recorder
+}
+
+// recordKnowledgeBaseStatuses adds the statuses of a multi knowledge base answer to the
+// request's recorder. It does nothing without a recorder.
+func recordKnowledgeBaseStatuses(ctx context.Context, statuses []KnowledgeBaseStatus) {
+	recorder, _ := ctx.Value(knowledgeBaseStatusKey{}).(*KnowledgeBaseStatusRecorder)
+	if recorder == nil {
+		return
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.statuses = append(recorder.statuses, statuses...)
+}
+
+// List returns the recorded statuses, nil when no multi knowledge base answer was generated
+func (r *KnowledgeBaseStatusRecorder) List() []KnowledgeBaseStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.statuses) == 0 {
+		return nil
+	}
+	return append([]KnowledgeBaseStatus(nil), r.statuses...)
+}
+
+// Partial reports whether a knowledge base of the answer was skipped, so the answer comes
+// from the others only
+func (r *KnowledgeBaseStatusRecorder) Partial() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, status := range r.statuses {
+		if status.Status != KnowledgeBaseAnswered {
+			return true
+		}
+	}
+	return false
+}
+
+// KnowledgeBaseBulkheads isolates the knowledge bases of multi knowledge base answers from
+// each other. Each knowledge base has its own bound on the queries in flight across all
+// requests, so a slow one cannot pile up goroutines and connections, and a failure budget of
+// consecutive failures, after which it is skipped for a cooldown instead of slowing every
+// answer down. After the cooldown a single query at a time probes it, the others are still
+// skipped: one more failure suspends the knowledge base again, a success restores its budget.
+type KnowledgeBaseBulkheads struct {
+	mu            sync.Mutex
+	maxConcurrent int // Queries in flight per knowledge base, 0 for no bound
+	failureBudget int // Consecutive failures before a knowledge base is suspended, 0 never suspends
+	cooldown      time.Duration
+	bulkheads     map[string]*bulkhead // By knowledge base ID
+	now           func() time.Time
+}
+
+type bulkhead struct {
+	slots          chan struct{} // Nil without a bound
+	failures       int
+	suspendedUntil time.Time
+	probing        bool // A query probing the suspended knowledge base is in flight
+}
+
+func NewKnowledgeBaseBulkheads(maxConcurrent int, failureBudget int, cooldown time.Duration) *KnowledgeBaseBulkheads {
+	return &KnowledgeBaseBulkheads{
+		maxConcurrent: maxConcurrent,
+		failureBudget: failureBudget,
+		cooldown:      cooldown,
+		bulkheads:     map[string]*bulkhead{},
+		now:           time.Now,
+	}
+}
+
+func (b *KnowledgeBaseBulkheads) bulkhead(knowledgeBaseId string) *bulkhead {
+	kb := b.bulkheads[knowledgeBaseId]
+	if kb == nil {
+		kb = &bulkhead{}
+		if b.maxConcurrent > 0 {
+			kb.slots = make(chan struct{}, b.maxConcurrent)
+		}
+		b.bulkheads[knowledgeBaseId] = kb
+	}
+	return kb
+}
+
+// Acquire takes a slot of the knowledge base without waiting. It returns the function that
+// frees the slot, or the status the query is skipped with: KnowledgeBaseSuspended or
+// KnowledgeBaseRejected. A nil KnowledgeBaseBulkheads admits every query.
+func (b *KnowledgeBaseBulkheads) Acquire(knowledgeBaseId string) (func(), string) {
+	if b == nil {
+		return func() {}, ""
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kb := b.bulkhead(knowledgeBaseId)
+	probe := false
+	if b.failureBudget > 0 && kb.failures >= b.failureBudget {
+		if kb.probing || b.now().Before(kb.suspendedUntil) {
+			return nil, KnowledgeBaseSuspended
+		}
+		probe = true
+	}
+
+	release := func() {}
+	if kb.slots != nil {
+		select {
+		case kb.slots <- struct{}{}:
+			release = func() { <-kb.slots }
+		default:
+			return nil, KnowledgeBaseRejected
+		}
+	}
+	if !probe {
+		return release, ""
+	}
+
+	// The next probe may go once this one is recorded, or was not because the caller gave up
+	kb.probing = true
+	return func() {
+		release()
+		b.mu.Lock()
+		kb.probing = false
+		b.mu.Unlock()
+	}, ""
+}
+
+// Record counts the outcome of a query against the knowledge base's failure budget
+func (b *KnowledgeBaseBulkheads) Record(knowledgeBaseId string, failed bool) {
+	if b == nil || b.failureBudget <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kb := b.bulkhead(knowledgeBaseId)
+	if !failed {
+		kb.failures = 0
+		return
+	}
+	kb.failures++
+	if kb.failures >= b.failureBudget {
+		kb.suspendedUntil = b.now().Add(b.cooldown)
+	}
+}
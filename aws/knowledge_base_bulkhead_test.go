@@ -0,0 +1,148 @@
+package aws
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKnowledgeBaseBulkheads_BoundsQueriesPerKnowledgeBase(t *testing.T) {
+	bulkheads := NewKnowledgeBaseBulkheads(2, 0, 0)
+
+	first, _ := bulkheads.Acquire("kb-slow")
+	if _, status := bulkheads.Acquire("kb-slow"); status != "" {
+		t.Fatalf("expected a second slot, got %q", status)
+	}
+	if _, status := bulkheads.Acquire("kb-slow"); status != KnowledgeBaseRejected {
+		t.Fatalf("expected the full bulkhead to reject, got %q", status)
+	}
+
+	// Other knowledge bases have their own slots
+	if _, status := bulkheads.Acquire("kb-healthy"); status != "" {
+		t.Errorf("expected another knowledge base to be admitted, got %q", status)
+	}
+
+	first()
+	if _, status := bulkheads.Acquire("kb-slow"); status != "" {
+		t.Errorf("expected a freed slot to be reused, got %q", status)
+	}
+}
+
+func TestKnowledgeBaseBulkheads_SuspendsAfterFailureBudget(t *testing.T) {
+	now := time.Date(2025, 5, 20, 3, 0, 0, 0, time.UTC)
+	bulkheads := NewKnowledgeBaseBulkheads(0, 2, 30*time.Second)
+	bulkheads.now = func() time.Time { return now }
+
+	bulkheads.Record("kb-1", true)
+	bulkheads.Record("kb-1", false)
+	bulkheads.Record("kb-1", true)
+	if _, status := bulkheads.Acquire("kb-1"); status != "" {
+		t.Fatalf("expected a success to restore the budget, got %q", status)
+	}
+
+	bulkheads.Record("kb-1", true)
+	if _, status := bulkheads.Acquire("kb-1"); status != KnowledgeBaseSuspended {
+		t.Fatalf("expected the knowledge base to be suspended, got %q", status)
+	}
+	if _, status := bulkheads.Acquire("kb-2"); status != "" {
+		t.Errorf("expected other knowledge bases to be admitted, got %q", status)
+	}
+
+	// After the cooldown a single query probes it, and one more failure suspends it again
+	now = now.Add(31 * time.Second)
+	release, status := bulkheads.Acquire("kb-1")
+	if status != "" {
+		t.Fatalf("expected a probe after the cooldown, got %q", status)
+	}
+	bulkheads.Record("kb-1", true)
+	release()
+	if _, status := bulkheads.Acquire("kb-1"); status != KnowledgeBaseSuspended {
+		t.Errorf("expected a failed probe to suspend it again, got %q", status)
+	}
+}
+
+func TestKnowledgeBaseBulkheads_AdmitsOneProbeAtATime(t *testing.T) {
+	now := time.Date(2025, 5, 20, 3, 0, 0, 0, time.UTC)
+	bulkheads := NewKnowledgeBaseBulkheads(0, 1, 30*time.Second)
+	bulkheads.now = func() time.Time { return now }
+	bulkheads.Record("kb-1", true)
+	now = now.Add(31 * time.Second)
+
+	var admitted, suspended atomic.Int32
+	releases := make(chan func(), 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, status := bulkheads.Acquire("kb-1")
+			switch status {
+			case "":
+				admitted.Add(1)
+				releases <- release
+			case KnowledgeBaseSuspended:
+				suspended.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if admitted.Load() != 1 || suspended.Load() != 9 {
+		t.Fatalf("expected a single probe, got %d admitted and %d suspended", admitted.Load(), suspended.Load())
+	}
+
+	// A probe the caller gave up on without an outcome lets the next query probe
+	(<-releases)()
+	release, status := bulkheads.Acquire("kb-1")
+	if status != "" {
+		t.Fatalf("expected the next probe to be admitted, got %q", status)
+	}
+	bulkheads.Record("kb-1", false)
+	release()
+	if _, status := bulkheads.Acquire("kb-1"); status != "" {
+		t.Errorf("expected a successful probe to restore the knowledge base, got %q", status)
+	}
+	if _, status := bulkheads.Acquire("kb-1"); status != "" {
+		t.Errorf("expected concurrent queries once restored, got %q", status)
+	}
+}
+
+func TestKnowledgeBaseBulkheads_NilAdmitsEveryQuery(t *testing.T) {
+	var bulkheads *KnowledgeBaseBulkheads
+	release, status := bulkheads.Acquire("kb-1")
+	if status != "" || release == nil {
+		t.Fatalf("expected the query to be admitted, got %q", status)
+	}
+	release()
+	bulkheads.Record("kb-1", true)
+}
+
+func TestKnowledgeBaseStatusRecorder(t *testing.T) {
+	recordKnowledgeBaseStatuses(context.Background(), []KnowledgeBaseStatus{{KnowledgeBaseId: "kb-1"}})
+
+	ctx, recorder := WithKnowledgeBaseStatusRecorder(context.Background())
+	if recorder.List() != nil {
+		t.Fatal("expected no statuses before an answer")
+	}
+	recordKnowledgeBaseStatuses(ctx, []KnowledgeBaseStatus{
+		{KnowledgeBaseId: "kb-1", Status: KnowledgeBaseAnswered, DurationMs: 850},
+		{KnowledgeBaseId: "kb-2", Status: KnowledgeBaseRejected},
+	})
+	statuses := recorder.List()
+	if len(statuses) != 2 || statuses[1].Status != KnowledgeBaseRejected {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+}
+
+func TestKnowledgeBaseQueryStatus(t *testing.T) {
+	if status := knowledgeBaseQueryStatus(nil); status != KnowledgeBaseAnswered {
+		t.Errorf("expected answered, got %q", status)
+	}
+	if status := knowledgeBaseQueryStatus(context.DeadlineExceeded); status != KnowledgeBaseTimedOut {
+		t.Errorf("expected timeout, got %q", status)
+	}
+	if status := knowledgeBaseQueryStatus(skippedKnowledgeBaseError("kb-1", KnowledgeBaseSuspended)); status != KnowledgeBaseFailed {
+		t.Errorf("expected failed, got %q", status)
+	}
+}
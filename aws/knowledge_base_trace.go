@@ -0,0 +1,46 @@
+package aws
+
+import (
+	"context"
+	"sync"
+)
+
+// KnowledgeBaseTrace collects the knowledge bases queried while serving one request, for
+// the audit trail
+type KnowledgeBaseTrace struct {
+	mu             sync.Mutex
+	knowledgeBases []string // In the order they were first queried
+}
+
+type knowledgeBaseTraceKey struct{}
+
+// WithKnowledgeBaseTrace attaches a new trace to the context of a request
+func WithKnowledgeBaseTrace(ctx context.Context) (context.Context, *KnowledgeBaseTrace) {
+	trace := &KnowledgeBaseTrace{}
+	return context.WithValue(ctx, knowledgeBaseTraceKey{}, trace), trace
+}
+
+// recordKnowledgeBase adds a queried knowledge base to the request's trace. It does nothing
+// without a trace.
+func recordKnowledgeBase(ctx context.Context, knowledgeBaseId string) {
+	trace, _ := ctx.Value(knowledgeBaseTraceKey{}).(*KnowledgeBaseTrace)
+	if trace == nil {
+		return
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	for _, recorded := range trace.knowledgeBases {
+		if recorded == knowledgeBaseId {
+			return
+		}
+	}
+	trace.knowledgeBases = append(trace.knowledgeBases, knowledgeBaseId)
+}
+
+// List returns the IDs of the queried knowledge bases
+func (t *KnowledgeBaseTrace) List() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.knowledgeBases...)
+}
@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+// MetadataFilter restricts retrieval to documents whose metadata attributes match, e.g. the
+// documents of one department. Unlike DocumentAccess, documents without a filtered
+// attribute are not retrieved.
+type MetadataFilter struct {
+	In      map[string][]string // Attribute to its accepted values
+	AtLeast map[string]float64  // Numeric attribute to its minimum, e.g. an effective date as YYYYMMDD
+	AtMost  map[string]float64  // Numeric attribute to its maximum
+}
+
+// Empty reports whether the filter accepts every document
+func (f *MetadataFilter) Empty() bool {
+	if f == nil {
+		return true
+	}
+	for _, values := range f.In {
+		if len(values) > 0 {
+			return false
+		}
+	}
+	return len(f.AtLeast) == 0 && len(f.AtMost) == 0
+}
+
+// Key identifies the filter, filters with the same key retrieve the same documents. It is
+// empty when the filter is empty.
+func (f *MetadataFilter) Key() string {
+	if f.Empty() {
+		return ""
+	}
+	var parts []string
+	for attribute, values := range f.In {
+		if len(values) == 0 {
+			continue
+		}
+		sorted := append([]string(nil), values...)
+		sort.Strings(sorted)
+		parts = append(parts, attribute+"="+strings.Join(sorted, ","))
+	}
+	for attribute, min := range f.AtLeast {
+		parts = append(parts, attribute+">="+strconv.FormatFloat(min, 'f', -1, 64))
+	}
+	for attribute, max := range f.AtMost {
+		parts = append(parts, attribute+"<="+strconv.FormatFloat(max, 'f', -1, 64))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ";")
+}
+
+// retrievalFilter builds the filter of the attributes, sorted so filters are stable
+func (f *MetadataFilter) retrievalFilter() types.RetrievalFilter {
+	if f.Empty() {
+		return nil
+	}
+	var filters []types.RetrievalFilter
+	for _, attribute := range sortedKeys(f.In) {
+		if values := f.In[attribute]; len(values) > 0 {
+			filters = append(filters, &types.RetrievalFilterMemberIn{
+				Value: types.FilterAttribute{Key: aws.String(attribute), Value: document.NewLazyDocument(values)},
+			})
+		}
+	}
+	for _, attribute := range sortedKeys(f.AtLeast) {
+		filters = append(filters, &types.RetrievalFilterMemberGreaterThanOrEquals{
+			Value: types.FilterAttribute{Key: aws.String(attribute), Value: document.NewLazyDocument(f.AtLeast[attribute])},
+		})
+	}
+	for _, attribute := range sortedKeys(f.AtMost) {
+		filters = append(filters, &types.RetrievalFilterMemberLessThanOrEquals{
+			Value: types.FilterAttribute{Key: aws.String(attribute), Value: document.NewLazyDocument(f.AtMost[attribute])},
+		})
+	}
+	return andFilters(filters...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type metadataFilterKey struct{}
+
+// WithMetadataFilter attaches the metadata filter of a request to its context
+func WithMetadataFilter(ctx context.Context, filter *MetadataFilter) context.Context {
+	return context.WithValue(ctx, metadataFilterKey{}, filter)
+}
+
+// MetadataFilterFromContext returns the metadata filter of the request, nil when retrieval
+// is not filtered
+func MetadataFilterFromContext(ctx context.Context) *MetadataFilter {
+	filter, _ := ctx.Value(metadataFilterKey{}).(*MetadataFilter)
+	return filter
+}
+
+// retrievalFilter builds the filter of answer retrieval: the exclusion filter restricted to
+// the metadata filter of the request, nil when neither filters anything
+func retrievalFilter(ctx context.Context, filter SourceFilter) types.RetrievalFilter {
+	return andFilters(exclusionFilter(ctx, filter), MetadataFilterFromContext(ctx).retrievalFilter())
+}
@@ -0,0 +1,55 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+func TestMetadataFilter(t *testing.T) {
+	filter := &MetadataFilter{
+		In:      map[string][]string{"document_type": {"policy", "faq"}, "department": {"retail"}},
+		AtLeast: map[string]float64{"effective_date": 20240101},
+	}
+
+	if got := filter.Key(); got != "department=retail;document_type=faq,policy;effective_date>=20240101" {
+		t.Errorf("expected a stable filter key, got %q", got)
+	}
+
+	andAll, ok := filter.retrievalFilter().(*types.RetrievalFilterMemberAndAll)
+	if !ok || len(andAll.Value) != 3 {
+		t.Fatalf("expected an andAll of the three attributes, got %#v", filter.retrievalFilter())
+	}
+	if in := andAll.Value[0].(*types.RetrievalFilterMemberIn); *in.Value.Key != "department" {
+		t.Errorf("expected attributes in sorted order, got %s", *in.Value.Key)
+	}
+	if _, ok := andAll.Value[2].(*types.RetrievalFilterMemberGreaterThanOrEquals); !ok {
+		t.Errorf("expected the effective date minimum last, got %T", andAll.Value[2])
+	}
+
+	empty := &MetadataFilter{In: map[string][]string{"department": nil}}
+	if !empty.Empty() || empty.Key() != "" || empty.retrievalFilter() != nil {
+		t.Error("expected a filter without values to filter nothing")
+	}
+}
+
+func TestRetrievalFilter_CombinesExclusionAndMetadata(t *testing.T) {
+	ctx := context.Background()
+	if filter := retrievalFilter(ctx, nil); filter != nil {
+		t.Errorf("expected no filter without exclusions or metadata filter, got %T", filter)
+	}
+
+	ctx = WithMetadataFilter(ctx, &MetadataFilter{In: map[string][]string{"department": {"retail"}}})
+	if _, ok := retrievalFilter(ctx, nil).(*types.RetrievalFilterMemberIn); !ok {
+		t.Errorf("expected the metadata filter alone, got %T", retrievalFilter(ctx, nil))
+	}
+
+	andAll, ok := retrievalFilter(ctx, staticSourceFilter{"s3://docs/old.pdf"}).(*types.RetrievalFilterMemberAndAll)
+	if !ok || len(andAll.Value) != 2 {
+		t.Fatalf("expected the exclusion and the metadata filter, got %#v", andAll)
+	}
+	if _, ok := andAll.Value[0].(*types.RetrievalFilterMemberNotIn); !ok {
+		t.Errorf("expected the exclusion first, got %T", andAll.Value[0])
+	}
+}
@@ -0,0 +1,75 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/warnings"
+)
+
+// modelIds returns the generative models to answer with, in order: the experiment variant's
+// or the configured model, then its fallbacks without repeats
+func (c *BedrockKBClient) modelIds(ctx context.Context) []string {
+	modelIds := []string{c.generativeModel(ctx)}
+	if c.fallbackModelIds == nil {
+		return modelIds
+	}
+	for _, modelId := range c.fallbackModelIds() {
+		if !slices.Contains(modelIds, modelId) {
+			modelIds = append(modelIds, modelId)
+		}
+	}
+	return modelIds
+}
+
+// withModelFallback calls invoke with the generative model and, while the model throttles
+// or fails, with each fallback model in turn. Errors of the request itself, e.g. an invalid
+// input, are returned without trying another model.
+func (c *BedrockKBClient) withModelFallback(ctx context.Context, invoke func(modelId string) error) error {
+	modelIds := c.modelIds(ctx)
+	var err error
+	for i, modelId := range modelIds {
+		err = invoke(modelId)
+		if err == nil {
+			if i > 0 {
+				warnings.Add(ctx, warnings.CodeModelFallback, fmt.Sprintf("The generative model is unavailable, the answer was generated by %s", modelId))
+			}
+			return nil
+		}
+		if !isModelFailure(err) || ctx.Err() != nil || i == len(modelIds)-1 {
+			return err
+		}
+		logger.WithContext(ctx).Warn("Generative model failed, trying the next model", map[string]interface{}{
+			"model_id":      modelId,
+			"next_model_id": modelIds[i+1],
+			"error":         err.Error(),
+		})
+	}
+	return err
+}
+
+// isModelFailure reports whether an error comes from the model rather than the request, so
+// another model may succeed: throttling, capacity, timeouts, model errors and models not
+// enabled for the account
+func isModelFailure(err error) bool {
+	errMsg := err.Error()
+	for _, code := range []string{
+		"ThrottlingException",
+		"TooManyRequestsException",
+		"ServiceUnavailableException",
+		"InternalServerException",
+		"ModelTimeoutException",
+		"ModelNotReadyException",
+		"ModelErrorException",
+		"DependencyFailedException",
+		"BadGatewayException",
+		"AccessDeniedException",
+	} {
+		if contains(errMsg, code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"teletubpax-api/experiments"
+	"teletubpax-api/warnings"
+)
+
+func fallbackClient(fallbackModelIds ...string) *BedrockKBClient {
+	return &BedrockKBClient{
+		generativeModelId: func() string { return "haiku" },
+		fallbackModelIds:  func() []string { return fallbackModelIds },
+	}
+}
+
+func TestModelIds(t *testing.T) {
+	if got := (&BedrockKBClient{generativeModelId: func() string { return "haiku" }}).modelIds(context.Background()); !slices.Equal(got, []string{"haiku"}) {
+		t.Errorf("expected only the generative model without fallbacks, got %v", got)
+	}
+	variant := experiments.WithAssignment(context.Background(), &experiments.Assignment{Experiment: "models", Variant: &experiments.Variant{Name: "sonnet", ModelId: "sonnet"}})
+	if got := fallbackClient("sonnet", "haiku").modelIds(variant); !slices.Equal(got, []string{"sonnet", "haiku"}) {
+		t.Errorf("expected the variant's model then the fallbacks, got %v", got)
+	}
+	if got := fallbackClient("sonnet", "haiku", "titan", "sonnet").modelIds(context.Background()); !slices.Equal(got, []string{"haiku", "sonnet", "titan"}) {
+		t.Errorf("expected the generative model then the fallbacks without repeats, got %v", got)
+	}
+}
+
+func TestWithModelFallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		errors  map[string]error
+		want    []string // Models invoked
+		wantErr bool
+		warning bool
+	}{
+		{
+			name: "generative model answers",
+			want: []string{"haiku"},
+		},
+		{
+			name:    "throttled model falls back",
+			errors:  map[string]error{"haiku": fmt.Errorf("ThrottlingException: rate exceeded")},
+			want:    []string{"haiku", "sonnet"},
+			warning: true,
+		},
+		{
+			name: "every model fails",
+			errors: map[string]error{
+				"haiku":  fmt.Errorf("ThrottlingException: rate exceeded"),
+				"sonnet": fmt.Errorf("ModelTimeoutException: timed out"),
+				"titan":  fmt.Errorf("ServiceUnavailableException: unavailable"),
+			},
+			want:    []string{"haiku", "sonnet", "titan"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid request does not fall back",
+			errors:  map[string]error{"haiku": fmt.Errorf("ValidationException: input is too long")},
+			want:    []string{"haiku"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, collector := warnings.WithCollector(context.Background())
+			var invoked []string
+			err := fallbackClient("sonnet", "titan").withModelFallback(ctx, func(modelId string) error {
+				invoked = append(invoked, modelId)
+				return tt.errors[modelId]
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(invoked, tt.want) {
+				t.Errorf("expected models %v to be invoked, got %v", tt.want, invoked)
+			}
+			if got := len(collector.List()) == 1 && collector.List()[0].Code == warnings.CodeModelFallback; got != tt.warning {
+				t.Errorf("expected fallback warning %v, got %+v", tt.warning, collector.List())
+			}
+		})
+	}
+}
+
+func TestWithModelFallback_StopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var invoked int
+	err := fallbackClient("sonnet").withModelFallback(ctx, func(modelId string) error {
+		invoked++
+		cancel()
+		return fmt.Errorf("ThrottlingException: rate exceeded")
+	})
+	if err == nil || invoked != 1 {
+		t.Errorf("expected the canceled request to fail without a fallback, got %v after %d calls", err, invoked)
+	}
+}
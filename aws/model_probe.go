@@ -0,0 +1,260 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+)
+
+// legacyHaikuProfile is the inference profile Haiku models were invoked through before
+// model identifiers were resolved at startup. It only exists in US regions.
+const legacyHaikuProfile = "us.anthropic.claude-haiku-4-5-20251001-v1:0"
+
+// modelIdentifier is how a configured model is invoked in the current region
+type modelIdentifier struct {
+	invocationId     string
+	inferenceProfile bool // invocationId is an inference profile, not a foundation model
+}
+
+var (
+	modelIdentifiersMu sync.RWMutex
+	modelIdentifiers   = map[string]modelIdentifier{}
+)
+
+// invocationModelId returns the identifier to invoke a configured model with: the one the
+// model probe resolved, or the legacy US Haiku profile when the model was not probed
+func invocationModelId(modelId string) string {
+	id, _ := resolvedModelId(modelId)
+	return id
+}
+
+// invocationModelArn returns the model identifier for RetrieveAndGenerate, which takes an
+// inference profile id or a foundation model ARN
+func invocationModelArn(modelId string, region string) string {
+	id, inferenceProfile := resolvedModelId(modelId)
+	if inferenceProfile || strings.HasPrefix(id, "arn:") {
+		return id
+	}
+	return fmt.Sprintf("arn:aws:bedrock:%s::foundation-model/%s", region, id)
+}
+
+func resolvedModelId(modelId string) (string, bool) {
+	modelIdentifiersMu.RLock()
+	resolved, ok := modelIdentifiers[modelId]
+	modelIdentifiersMu.RUnlock()
+	if ok {
+		return resolved.invocationId, resolved.inferenceProfile
+	}
+	if strings.Contains(modelId, "anthropic.claude") && strings.Contains(modelId, "haiku") {
+		return legacyHaikuProfile, true
+	}
+	return modelId, false
+}
+
+// ModelResolution reports how one configured model is invoked in the region
+type ModelResolution struct {
+	ModelId          string
+	InvocationId     string
+	InferenceProfile bool
+}
+
+func (r ModelResolution) String() string {
+	if r.InferenceProfile {
+		return fmt.Sprintf("%s: inference profile %s", r.ModelId, r.InvocationId)
+	}
+	return fmt.Sprintf("%s: on-demand", r.ModelId)
+}
+
+// ModelUnavailableError is returned when a configured model cannot be invoked in the region
+type ModelUnavailableError struct {
+	ModelId string
+	Region  string
+	Reason  string
+}
+
+func (e *ModelUnavailableError) Error() string {
+	return fmt.Sprintf("model %s cannot be invoked in %s: %s", e.ModelId, e.Region, e.Reason)
+}
+
+type bedrockControlAPI interface {
+	ListFoundationModels(ctx context.Context, params *bedrock.ListFoundationModelsInput, optFns ...func(*bedrock.Options)) (*bedrock.ListFoundationModelsOutput, error)
+	ListInferenceProfiles(ctx context.Context, params *bedrock.ListInferenceProfilesInput, optFns ...func(*bedrock.Options)) (*bedrock.ListInferenceProfilesOutput, error)
+}
+
+// ModelProbe checks at startup that the configured models can be invoked in the region,
+// and maps models that are only served through inference profiles to a profile of the
+// region, instead of failing the first user request with a ValidationException
+type ModelProbe struct {
+	client bedrockControlAPI
+	region string
+}
+
+func NewModelProbe(cfg aws.Config, region string) *ModelProbe {
+	return &ModelProbe{
+		client: bedrock.NewFromConfig(cfg),
+		region: region,
+	}
+}
+
+// Resolve finds the invocation identifier of every model. Models given as ARNs are used
+// as is. The error is a *ModelUnavailableError for the first model that cannot be invoked,
+// or the AWS error when the region could not be listed.
+func (p *ModelProbe) Resolve(ctx context.Context, modelIds []string) ([]ModelResolution, error) {
+	models, err := p.client.ListFoundationModels(ctx, &bedrock.ListFoundationModelsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list foundation models: %w", err)
+	}
+	profiles, err := p.listInferenceProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	onDemand := map[string]bool{}
+	available := map[string]bool{}
+	for _, model := range models.ModelSummaries {
+		modelId := aws.ToString(model.ModelId)
+		available[modelId] = true
+		for _, inferenceType := range model.InferenceTypesSupported {
+			if inferenceType == types.InferenceTypeOnDemand {
+				onDemand[modelId] = true
+			}
+		}
+	}
+
+	var resolutions []ModelResolution
+	seen := map[string]bool{}
+	for _, modelId := range modelIds {
+		if modelId == "" || seen[modelId] || strings.HasPrefix(modelId, "arn:") {
+			continue
+		}
+		seen[modelId] = true
+
+		resolution, err := p.resolve(modelId, available, onDemand, profiles)
+		if err != nil {
+			return resolutions, err
+		}
+		resolutions = append(resolutions, resolution)
+	}
+	return resolutions, nil
+}
+
+func (p *ModelProbe) resolve(modelId string, available map[string]bool, onDemand map[string]bool, profiles map[string][]string) (ModelResolution, error) {
+	// Configured as an inference profile of this region
+	if _, ok := profiles[modelId]; ok {
+		return ModelResolution{ModelId: modelId, InvocationId: modelId, InferenceProfile: true}, nil
+	}
+	if onDemand[modelId] {
+		return ModelResolution{ModelId: modelId, InvocationId: modelId}, nil
+	}
+
+	// Served only through inference profiles, or configured as a profile of another
+	// geography, e.g. us.anthropic... in eu-central-1
+	foundationModelId := modelId
+	if !available[modelId] {
+		if i := strings.Index(modelId, "."); i > 0 && available[modelId[i+1:]] {
+			foundationModelId = modelId[i+1:]
+		}
+	}
+	if candidates := p.profilesFor(foundationModelId, profiles); len(candidates) > 0 {
+		return ModelResolution{ModelId: modelId, InvocationId: candidates[0], InferenceProfile: true}, nil
+	}
+
+	if available[foundationModelId] {
+		return ModelResolution{}, &ModelUnavailableError{ModelId: modelId, Region: p.region, Reason: "no on-demand throughput and no inference profile for it in this region"}
+	}
+	return ModelResolution{}, &ModelUnavailableError{ModelId: modelId, Region: p.region, Reason: "the model is not offered in this region"}
+}
+
+// profilesFor returns the active inference profiles serving a foundation model, profiles
+// of the region's own geography first, then global profiles
+func (p *ModelProbe) profilesFor(foundationModelId string, profiles map[string][]string) []string {
+	suffix := "foundation-model/" + foundationModelId
+	var candidates []string
+	for profileId, modelArns := range profiles {
+		for _, modelArn := range modelArns {
+			if strings.HasSuffix(modelArn, suffix) {
+				candidates = append(candidates, profileId)
+				break
+			}
+		}
+	}
+
+	geography := regionGeography(p.region)
+	rank := func(profileId string) int {
+		switch {
+		case strings.HasPrefix(profileId, geography+"."):
+			return 0
+		case strings.HasPrefix(profileId, "global."):
+			return 1
+		}
+		return 2
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if rank(candidates[i]) != rank(candidates[j]) {
+			return rank(candidates[i]) < rank(candidates[j])
+		}
+		return candidates[i] < candidates[j]
+	})
+	return candidates
+}
+
+// listInferenceProfiles returns the model ARNs of every active inference profile, keyed by
+// profile id
+func (p *ModelProbe) listInferenceProfiles(ctx context.Context) (map[string][]string, error) {
+	profiles := map[string][]string{}
+	input := &bedrock.ListInferenceProfilesInput{}
+	for {
+		output, err := p.client.ListInferenceProfiles(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list inference profiles: %w", err)
+		}
+		for _, profile := range output.InferenceProfileSummaries {
+			if profile.Status != types.InferenceProfileStatusActive {
+				continue
+			}
+			var modelArns []string
+			for _, model := range profile.Models {
+				modelArns = append(modelArns, aws.ToString(model.ModelArn))
+			}
+			profiles[aws.ToString(profile.InferenceProfileId)] = modelArns
+		}
+		if output.NextToken == nil {
+			return profiles, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// regionGeography returns the inference profile prefix of a region, e.g. "eu" for
+// eu-central-1 and "apac" for ap-southeast-1
+func regionGeography(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return "us-gov"
+	case strings.HasPrefix(region, "ap-"):
+		return "apac"
+	}
+	if i := strings.Index(region, "-"); i > 0 {
+		return region[:i]
+	}
+	return region
+}
+
+// UseModelResolutions makes the Bedrock clients invoke the configured models with the
+// resolved identifiers
+func UseModelResolutions(resolutions []ModelResolution) {
+	modelIdentifiersMu.Lock()
+	defer modelIdentifiersMu.Unlock()
+	for _, resolution := range resolutions {
+		modelIdentifiers[resolution.ModelId] = modelIdentifier{
+			invocationId:     resolution.InvocationId,
+			inferenceProfile: resolution.InferenceProfile,
+		}
+	}
+}
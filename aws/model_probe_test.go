@@ -0,0 +1,137 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+)
+
+const haikuModelId = "anthropic.claude-haiku-4-5-20251001-v1:0"
+
+type fakeBedrockControl struct {
+	models   []types.FoundationModelSummary
+	profiles [][]types.InferenceProfileSummary // One page per call
+	err      error
+}
+
+func (f *fakeBedrockControl) ListFoundationModels(ctx context.Context, params *bedrock.ListFoundationModelsInput, optFns ...func(*bedrock.Options)) (*bedrock.ListFoundationModelsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &bedrock.ListFoundationModelsOutput{ModelSummaries: f.models}, nil
+}
+
+func (f *fakeBedrockControl) ListInferenceProfiles(ctx context.Context, params *bedrock.ListInferenceProfilesInput, optFns ...func(*bedrock.Options)) (*bedrock.ListInferenceProfilesOutput, error) {
+	page := 0
+	if params.NextToken != nil {
+		fmt.Sscan(*params.NextToken, &page)
+	}
+	output := &bedrock.ListInferenceProfilesOutput{InferenceProfileSummaries: f.profiles[page]}
+	if page+1 < len(f.profiles) {
+		output.NextToken = aws.String(fmt.Sprint(page + 1))
+	}
+	return output, nil
+}
+
+func foundationModel(modelId string, inferenceTypes ...types.InferenceType) types.FoundationModelSummary {
+	return types.FoundationModelSummary{ModelId: aws.String(modelId), InferenceTypesSupported: inferenceTypes}
+}
+
+func inferenceProfile(profileId string, region string, modelId string) types.InferenceProfileSummary {
+	return types.InferenceProfileSummary{
+		InferenceProfileId: aws.String(profileId),
+		Status:             types.InferenceProfileStatusActive,
+		Models: []types.InferenceProfileModel{
+			{ModelArn: aws.String(fmt.Sprintf("arn:aws:bedrock:%s::foundation-model/%s", region, modelId))},
+		},
+	}
+}
+
+func TestModelProbe_Resolve(t *testing.T) {
+	client := &fakeBedrockControl{
+		models: []types.FoundationModelSummary{
+			foundationModel(haikuModelId),
+			foundationModel("amazon.titan-embed-text-v2:0", types.InferenceTypeOnDemand),
+		},
+		profiles: [][]types.InferenceProfileSummary{
+			{inferenceProfile("global.anthropic.claude-haiku-4-5-20251001-v1:0", "eu-central-1", haikuModelId)},
+			{inferenceProfile("eu.anthropic.claude-haiku-4-5-20251001-v1:0", "eu-central-1", haikuModelId)},
+		},
+	}
+	probe := &ModelProbe{client: client, region: "eu-central-1"}
+
+	resolutions, err := probe.Resolve(context.Background(), []string{
+		haikuModelId,
+		"us.anthropic.claude-haiku-4-5-20251001-v1:0",
+		"amazon.titan-embed-text-v2:0",
+		"arn:aws:bedrock:eu-central-1::foundation-model/custom",
+		haikuModelId,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ModelResolution{
+		{ModelId: haikuModelId, InvocationId: "eu.anthropic.claude-haiku-4-5-20251001-v1:0", InferenceProfile: true},
+		{ModelId: "us.anthropic.claude-haiku-4-5-20251001-v1:0", InvocationId: "eu.anthropic.claude-haiku-4-5-20251001-v1:0", InferenceProfile: true},
+		{ModelId: "amazon.titan-embed-text-v2:0", InvocationId: "amazon.titan-embed-text-v2:0"},
+	}
+	if fmt.Sprint(resolutions) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, resolutions)
+	}
+}
+
+func TestModelProbe_ReportsUnavailableModels(t *testing.T) {
+	client := &fakeBedrockControl{
+		models:   []types.FoundationModelSummary{foundationModel(haikuModelId)},
+		profiles: [][]types.InferenceProfileSummary{{}},
+	}
+	probe := &ModelProbe{client: client, region: "ap-southeast-7"}
+
+	for _, modelId := range []string{haikuModelId, "anthropic.claude-opus-4-1-20250805-v1:0"} {
+		_, err := probe.Resolve(context.Background(), []string{modelId})
+		if unavailable, ok := err.(*ModelUnavailableError); !ok || unavailable.ModelId != modelId || unavailable.Region != "ap-southeast-7" {
+			t.Errorf("expected a ModelUnavailableError for %s, got %v", modelId, err)
+		}
+	}
+
+	client.err = fmt.Errorf("AccessDeniedException")
+	if _, err := probe.Resolve(context.Background(), []string{haikuModelId}); err == nil {
+		t.Fatal("expected the list error")
+	} else if _, ok := err.(*ModelUnavailableError); ok {
+		t.Errorf("expected a list error, not an unavailable model: %v", err)
+	}
+}
+
+func TestInvocationModelId(t *testing.T) {
+	defer func() {
+		modelIdentifiersMu.Lock()
+		modelIdentifiers = map[string]modelIdentifier{}
+		modelIdentifiersMu.Unlock()
+	}()
+
+	if id := invocationModelId(haikuModelId); id != legacyHaikuProfile {
+		t.Errorf("expected the legacy profile before probing, got %s", id)
+	}
+	if arn := invocationModelArn("amazon.nova-lite-v1:0", "us-east-1"); arn != "arn:aws:bedrock:us-east-1::foundation-model/amazon.nova-lite-v1:0" {
+		t.Errorf("expected a foundation model ARN, got %s", arn)
+	}
+
+	UseModelResolutions([]ModelResolution{
+		{ModelId: haikuModelId, InvocationId: "eu.anthropic.claude-haiku-4-5-20251001-v1:0", InferenceProfile: true},
+		{ModelId: "amazon.nova-lite-v1:0", InvocationId: "amazon.nova-lite-v1:0"},
+	})
+	if id := invocationModelId(haikuModelId); id != "eu.anthropic.claude-haiku-4-5-20251001-v1:0" {
+		t.Errorf("expected the resolved profile, got %s", id)
+	}
+	if arn := invocationModelArn(haikuModelId, "eu-central-1"); arn != "eu.anthropic.claude-haiku-4-5-20251001-v1:0" {
+		t.Errorf("expected the profile id for RetrieveAndGenerate, got %s", arn)
+	}
+	if arn := invocationModelArn("amazon.nova-lite-v1:0", "eu-central-1"); arn != "arn:aws:bedrock:eu-central-1::foundation-model/amazon.nova-lite-v1:0" {
+		t.Errorf("expected a foundation model ARN, got %s", arn)
+	}
+}
@@ -0,0 +1,69 @@
+package aws
+
+import (
+	"context"
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	sestypes "github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// maxTopicSubjectLength is the SNS limit for the subject of email endpoints
+const maxTopicSubjectLength = 100
+
+type NotificationClient interface {
+	SendEmail(ctx context.Context, from string, to []string, subject string, body string) error
+	PublishToTopic(ctx context.Context, topicArn string, subject string, message string) error
+}
+
+// SESNotificationClient sends email through SES and publishes to SNS topics
+type SESNotificationClient struct {
+	ses *sesv2.Client
+	sns *sns.Client
+}
+
+func NewSESNotificationClient(cfg aws.Config) *SESNotificationClient {
+	return &SESNotificationClient{
+		ses: sesv2.NewFromConfig(cfg),
+		sns: sns.NewFromConfig(cfg),
+	}
+}
+
+func (c *SESNotificationClient) SendEmail(ctx context.Context, from string, to []string, subject string, body string) error {
+	_, err := c.ses.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(from),
+		Destination: &sestypes.Destination{
+			ToAddresses: to,
+		},
+		Content: &sestypes.EmailContent{
+			Simple: &sestypes.Message{
+				Subject: &sestypes.Content{Data: aws.String(subject), Charset: aws.String("UTF-8")},
+				Body: &sestypes.Body{
+					Text: &sestypes.Content{Data: aws.String(body), Charset: aws.String("UTF-8")},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to send email", err)
+	}
+	return nil
+}
+
+func (c *SESNotificationClient) PublishToTopic(ctx context.Context, topicArn string, subject string, message string) error {
+	if runes := []rune(subject); len(runes) > maxTopicSubjectLength {
+		subject = string(runes[:maxTopicSubjectLength])
+	}
+
+	_, err := c.sns.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(topicArn),
+		Subject:  aws.String(subject),
+		Message:  aws.String(message),
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to publish to topic", err)
+	}
+	return nil
+}
@@ -1,388 +1,740 @@
-package aws
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"regexp"
-	"sort"
-	"strconv"
-	"strings"
-	"teletubpax-api/errors"
-	"time"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
-)
-
-type OpenSearchClient interface {
-	GetLastUpdateDocuments(ctx context.Context) ([]map[string]interface{}, error)
-	CompareDocumentVersions(ctx context.Context, newerContent, olderContent, topic string) (string, error)
-}
-
-type BedrockOpenSearchClient struct {
-	client                         *bedrockagentruntime.Client
-	knowledgeBaseId                string
-	region                         string
-	kbClient                       KnowledgeBaseClient
-	generativeModelId              string
-	documentComparisonInstructions string
-}
-
-func NewBedrockOpenSearchClient(cfg aws.Config, knowledgeBaseId string, region string, kbClient KnowledgeBaseClient, generativeModelId string, documentComparisonInstructions string) *BedrockOpenSearchClient {
-	return &BedrockOpenSearchClient{
-		client:                         bedrockagentruntime.NewFromConfig(cfg),
-		knowledgeBaseId:                knowledgeBaseId,
-		region:                         region,
-		kbClient:                       kbClient,
-		generativeModelId:              generativeModelId,
-		documentComparisonInstructions: documentComparisonInstructions,
-	}
-}
-
-func (c *BedrockOpenSearchClient) GetLastUpdateDocuments(ctx context.Context) ([]map[string]interface{}, error) {
-	// Use Bedrock Agent Runtime Retrieve API to get documents from the knowledge base
-	// This retrieves documents from the underlying OpenSearch index
-	input := &bedrockagentruntime.RetrieveInput{
-		KnowledgeBaseId: aws.String(c.knowledgeBaseId),
-		RetrievalQuery: &types.KnowledgeBaseQuery{
-			Text: aws.String("*"), // Query all documents
-		},
-		RetrievalConfiguration: &types.KnowledgeBaseRetrievalConfiguration{
-			VectorSearchConfiguration: &types.KnowledgeBaseVectorSearchConfiguration{
-				NumberOfResults: aws.Int32(100), // Adjust as needed
-			},
-		},
-	}
-
-	output, err := c.client.Retrieve(ctx, input)
-	if err != nil {
-		return nil, c.handleAWSError(err)
-	}
-
-	// Parse the response and extract document details
-	var documents []map[string]interface{}
-
-	if output.RetrievalResults != nil {
-		for _, result := range output.RetrievalResults {
-			doc := make(map[string]interface{})
-
-			// Extract content
-			if result.Content != nil && result.Content.Text != nil {
-				doc["content"] = *result.Content.Text
-			}
-
-			// Extract score
-			if result.Score != nil {
-				doc["score"] = *result.Score
-			}
-
-			var publicUrl string
-
-			// Extract location information
-			if result.Location != nil {
-				location := make(map[string]interface{})
-
-				if result.Location.S3Location != nil {
-					s3Location := make(map[string]interface{})
-					if result.Location.S3Location.Uri != nil {
-						s3Uri := *result.Location.S3Location.Uri
-						publicUrl = c.convertS3UriToPublicUrl(s3Uri)
-						s3Location["uri"] = s3Uri
-						s3Location["publicUrl"] = publicUrl
-					}
-					location["s3Location"] = s3Location
-				}
-
-				if result.Location.Type != "" {
-					location["type"] = string(result.Location.Type)
-				}
-
-				doc["location"] = location
-			}
-
-			// Extract and parse date from URL path (e.g., content/2025/05/)
-			yearMonth := c.extractYearMonthFromUrl(publicUrl)
-			doc["yearMonth"] = yearMonth
-			doc["sortKey"] = c.createSortKey(yearMonth)
-
-			// Extract version number from filename (e.g., -1, -2)
-			versionNumber := c.extractVersionNumber(publicUrl)
-			doc["version"] = versionNumber
-
-			// Extract last modified date from metadata
-			var lastModified time.Time
-			if result.Metadata != nil {
-				metadata := make(map[string]interface{})
-				for key, value := range result.Metadata {
-					// Convert document.Interface to string representation
-					if valueBytes, err := json.Marshal(value); err == nil {
-						var jsonValue interface{}
-						if err := json.Unmarshal(valueBytes, &jsonValue); err == nil {
-							metadata[key] = jsonValue
-
-							// Try to extract last modified date
-							if strings.Contains(strings.ToLower(key), "modified") ||
-								strings.Contains(strings.ToLower(key), "updated") ||
-								key == "lastModified" || key == "last_modified" {
-								if dateStr, ok := jsonValue.(string); ok {
-									if parsedTime, err := time.Parse(time.RFC3339, dateStr); err == nil {
-										lastModified = parsedTime
-									}
-								}
-							}
-						} else {
-							metadata[key] = string(valueBytes)
-						}
-					}
-				}
-				doc["metadata"] = metadata
-			}
-
-			doc["lastModified"] = lastModified
-			doc["lastModifiedUnix"] = lastModified.Unix()
-
-			documents = append(documents, doc)
-		}
-	}
-
-	// Sort with multiple criteria:
-	// 1. Year/Month (newest first)
-	// 2. Last modified date (newest first)
-	// 3. Version number (highest version first: -2, -1, no version)
-	sort.Slice(documents, func(i, j int) bool {
-		// Primary: Sort by year/month
-		sortKeyI := documents[i]["sortKey"].(string)
-		sortKeyJ := documents[j]["sortKey"].(string)
-
-		if sortKeyI != sortKeyJ {
-			return sortKeyI > sortKeyJ // Descending (newest first)
-		}
-
-		// Secondary: Sort by last modified date
-		lastModI := documents[i]["lastModifiedUnix"].(int64)
-		lastModJ := documents[j]["lastModifiedUnix"].(int64)
-
-		if lastModI != lastModJ {
-			return lastModI > lastModJ // Descending (newest first)
-		}
-
-		// Tertiary: Sort by version number
-		versionI := documents[i]["version"].(int)
-		versionJ := documents[j]["version"].(int)
-
-		return versionI > versionJ // Descending (highest version first)
-	})
-
-	// Return only the last 10 newest documents
-	if len(documents) > 10 {
-		documents = documents[:10]
-	}
-
-	// Transform to simplified response format
-	simplifiedDocs := make([]map[string]interface{}, 0, len(documents))
-	for _, doc := range documents {
-		simplified := make(map[string]interface{})
-
-		// 1. lastModifyDate
-		if lastMod, ok := doc["lastModified"].(time.Time); ok && !lastMod.IsZero() {
-			simplified["lastModifyDate"] = lastMod.Format(time.RFC3339)
-		} else {
-			// Use yearMonth as fallback
-			if yearMonth, ok := doc["yearMonth"].(string); ok {
-				simplified["lastModifyDate"] = yearMonth
-			} else {
-				simplified["lastModifyDate"] = ""
-			}
-		}
-
-		// 2. link (public URL)
-		if location, ok := doc["location"].(map[string]interface{}); ok {
-			if s3Location, ok := location["s3Location"].(map[string]interface{}); ok {
-				if publicUrl, ok := s3Location["publicUrl"].(string); ok {
-					simplified["link"] = publicUrl
-				}
-			}
-		}
-
-		// 3. topic (extracted from filename)
-		var topic string
-		var publicUrl string
-		if location, ok := doc["location"].(map[string]interface{}); ok {
-			if s3Location, ok := location["s3Location"].(map[string]interface{}); ok {
-				if url, ok := s3Location["publicUrl"].(string); ok {
-					topic = c.extractTopicFromUrl(url)
-					publicUrl = url
-					simplified["topic"] = topic
-					simplified["link"] = publicUrl
-				}
-			}
-		}
-
-		// 4. version - current version number
-		currentVersion := 0
-		if version, ok := doc["version"].(int); ok {
-			currentVersion = version
-			simplified["version"] = currentVersion
-		}
-
-		// 5. changeSummary - compare with older version if exists
-		simplified["changeSummary"] = ""
-
-		// 6. Keep content for version comparison (will be removed by service layer)
-		if content, ok := doc["content"].(string); ok {
-			simplified["content"] = content
-		}
-
-		simplifiedDocs = append(simplifiedDocs, simplified)
-	}
-
-	return simplifiedDocs, nil
-}
-
-// extractYearMonthFromUrl extracts year/month from URL path like "content/2025/05/"
-func (c *BedrockOpenSearchClient) extractYearMonthFromUrl(url string) string {
-	// Pattern to match year/month in the URL path (e.g., /2025/05/)
-	re := regexp.MustCompile(`/(\d{4})/(\d{2})/`)
-	matches := re.FindStringSubmatch(url)
-
-	if len(matches) >= 3 {
-		year := matches[1]
-		month := matches[2]
-		return fmt.Sprintf("%s/%s", year, month)
-	}
-
-	return "0000/00" // Default for documents without date in path
-}
-
-// createSortKey creates a sortable key from year/month string
-func (c *BedrockOpenSearchClient) createSortKey(yearMonth string) string {
-	// Convert "2025/05" to "202505" for easy sorting
-	return strings.ReplaceAll(yearMonth, "/", "")
-}
-
-// extractVersionNumber extracts version number from filename
-// Examples:
-//   - "file-1-2.pdf" -> 2
-//   - "file-1-1.pdf" -> 1
-//   - "file-1.pdf" -> 0
-//   - "Horaland1-2.pdf" -> 2
-func (c *BedrockOpenSearchClient) extractVersionNumber(url string) int {
-	// Extract filename from URL
-	parts := strings.Split(url, "/")
-	if len(parts) == 0 {
-		return 0
-	}
-	filename := parts[len(parts)-1]
-
-	// Remove file extension
-	filename = strings.TrimSuffix(filename, ".pdf")
-	filename = strings.TrimSuffix(filename, ".PDF")
-	filename = strings.TrimSuffix(filename, ".doc")
-	filename = strings.TrimSuffix(filename, ".docx")
-	filename = strings.TrimSuffix(filename, ".txt")
-
-	// Pattern to match version number at the end: -1, -2, etc.
-	re := regexp.MustCompile(`-(\d+)$`)
-	matches := re.FindStringSubmatch(filename)
-
-	if len(matches) >= 2 {
-		if version, err := strconv.Atoi(matches[1]); err == nil {
-			return version
-		}
-	}
-
-	return 0 // No version number found
-}
-
-// extractTopicFromUrl extracts the topic/title from the filename in URL
-// Examples:
-//   - "https://.../สื่อความสาขา-_-Horaland1-2.pdf" -> "สื่อความสาขา-_-Horaland1"
-//   - "https://.../การขอลดค่างวด-waive.pdf" -> "การขอลดค่างวด-waive"
-func (c *BedrockOpenSearchClient) extractTopicFromUrl(url string) string {
-	// Extract filename from URL
-	parts := strings.Split(url, "/")
-	if len(parts) == 0 {
-		return ""
-	}
-	filename := parts[len(parts)-1]
-
-	// Remove file extension
-	filename = strings.TrimSuffix(filename, ".pdf")
-	filename = strings.TrimSuffix(filename, ".PDF")
-	filename = strings.TrimSuffix(filename, ".doc")
-	filename = strings.TrimSuffix(filename, ".docx")
-	filename = strings.TrimSuffix(filename, ".txt")
-
-	// Remove version number suffix if present (e.g., -1, -2)
-	re := regexp.MustCompile(`-(\d+)$`)
-	filename = re.ReplaceAllString(filename, "")
-
-	return filename
-}
-
-func (c *BedrockOpenSearchClient) convertS3UriToPublicUrl(s3Uri string) string {
-	s3Uri = strings.TrimPrefix(s3Uri, "s3://")
-	parts := strings.SplitN(s3Uri, "/", 2)
-	if len(parts) != 2 {
-		return s3Uri
-	}
-	bucket := parts[0]
-	key := parts[1]
-	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, c.region, key)
-}
-
-// CompareDocumentVersions uses Bedrock to compare two document versions and summarize changes
-func (c *BedrockOpenSearchClient) CompareDocumentVersions(ctx context.Context, newerContent, olderContent, topic string) (string, error) {
-	// Create a prompt using the document comparison instructions
-	prompt := fmt.Sprintf(`%s
-
-Document Topic: %s
-
-Older Version:
-%s
-
-Newer Version:
-%s
-
-Please analyze and provide the comparison in JSON format.`, c.documentComparisonInstructions, topic, olderContent, newerContent)
-
-	// Use the KB client to query Bedrock
-	answer, _, err := c.kbClient.QueryKnowledgeBase(ctx, prompt, false)
-	if err != nil {
-		return "", err
-	}
-
-	return answer, nil
-}
-
-func (c *BedrockOpenSearchClient) handleAWSError(err error) error {
-	errMsg := err.Error()
-
-	if contains(errMsg, "ValidationException") || contains(errMsg, "invalid") {
-		return errors.NewValidationError(fmt.Sprintf("invalid OpenSearch query: %v", err))
-	}
-
-	if contains(errMsg, "ThrottlingException") || contains(errMsg, "TooManyRequestsException") {
-		return errors.NewThrottlingError("OpenSearch service throttled", err)
-	}
-
-	if contains(errMsg, "AccessDeniedException") || contains(errMsg, "UnauthorizedException") {
-		return errors.NewAWSServiceError("invalid or missing AWS credentials", err)
-	}
-
-	if contains(errMsg, "ResourceNotFoundException") {
-		return errors.NewAWSServiceError("knowledge base not found", err)
-	}
-
-	if contains(errMsg, "ServiceUnavailableException") || contains(errMsg, "InternalServerException") {
-		return errors.NewAWSServiceError("OpenSearch service unavailable", err)
-	}
-
-	if contains(errMsg, "TimeoutException") || contains(errMsg, "timeout") {
-		return errors.NewAWSServiceError("OpenSearch query timeout", err)
-	}
-
-	return errors.NewAWSServiceError("OpenSearch query failed", err)
-}
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/utils"
+	"teletubpax-api/warnings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+const (
+	sourceContentConcurrency = 4    // Bounds the parallel source document reads of one listing
+	comparisonContextLines   = 2    // Unchanged lines around each change sent to the model
+	maxComparisonDiffTokens  = 8000 // Estimated tokens of the diff in a comparison prompt
+)
+
+// unchangedComparison is the answer the document comparison instructions give for identical
+// documents, returned without a model call when the diff is empty
+const unchangedComparison = `{"version": "same", "changeSummary": "ไม่มีการเปลี่ยนแปลง", "keyChanges": []}`
+
+type OpenSearchClient interface {
+	GetLastUpdateDocuments(ctx context.Context) ([]map[string]interface{}, error)
+	ListDocuments(ctx context.Context) ([]map[string]interface{}, error)
+	// ListTopicVersions returns every retrievable version of a topic once, like ListDocuments
+	ListTopicVersions(ctx context.Context, topic string) ([]map[string]interface{}, error)
+	CompareDocumentVersions(ctx context.Context, newerContent, olderContent, topic string) (string, error)
+	SummarizeDocument(ctx context.Context, content, topic string) (string, error)
+	GetDocumentChunks(ctx context.Context, documentUri string) ([]DocumentChunk, error)
+	// GetDocumentContent returns the text of a single document, "" when it has no chunks
+	GetDocumentContent(ctx context.Context, documentUri string) (string, error)
+	// SearchDocumentChunks returns up to numberOfResults chunks of a single document, most
+	// relevant to the query first
+	SearchDocumentChunks(ctx context.Context, documentUri string, query string, numberOfResults int) ([]DocumentChunk, error)
+}
+
+// DocumentChunk is a single indexed chunk of a source document
+type DocumentChunk struct {
+	ChunkId    string                 `json:"chunkId,omitempty"`
+	PageNumber int                    `json:"pageNumber,omitempty"`
+	Score      float64                `json:"score"`
+	Content    string                 `json:"content"`
+	SourceText string                 `json:"sourceText,omitempty"` // Original content when Content was translated
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type BedrockOpenSearchClient struct {
+	client                         *bedrockagentruntime.Client
+	knowledgeBaseId                string
+	documentLinker                 DocumentLinker
+	kbClient                       KnowledgeBaseClient
+	generativeModelId              string
+	documentComparisonInstructions func() string
+	documentSummaryInstructions    func() string
+	sourceFilter                   SourceFilter          // Optional, excluded documents are hidden from listings
+	contentClient                  DocumentContentClient // Optional, listings carry the retrieved chunks when nil
+}
+
+func NewBedrockOpenSearchClient(cfg aws.Config, knowledgeBaseId string, documentLinker DocumentLinker, kbClient KnowledgeBaseClient, generativeModelId string, documentComparisonInstructions func() string, documentSummaryInstructions func() string, sourceFilter SourceFilter, contentClient DocumentContentClient) *BedrockOpenSearchClient {
+	return &BedrockOpenSearchClient{
+		client:                         bedrockagentruntime.NewFromConfig(cfg),
+		knowledgeBaseId:                knowledgeBaseId,
+		documentLinker:                 documentLinker,
+		kbClient:                       kbClient,
+		generativeModelId:              generativeModelId,
+		documentComparisonInstructions: documentComparisonInstructions,
+		documentSummaryInstructions:    documentSummaryInstructions,
+		sourceFilter:                   sourceFilter,
+		contentClient:                  contentClient,
+	}
+}
+
+func (c *BedrockOpenSearchClient) GetLastUpdateDocuments(ctx context.Context) ([]map[string]interface{}, error) {
+	documents, err := c.retrieveDocuments(ctx, "*", exclusionFilter(ctx, c.sourceFilter))
+	if err != nil {
+		return nil, err
+	}
+
+	// Return only the last 10 newest documents
+	if len(documents) > 10 {
+		documents = documents[:10]
+	}
+
+	simplified := c.simplifyDocuments(documents)
+	c.loadSourceContent(ctx, simplified)
+	return simplified, nil
+}
+
+// ListDocuments returns every retrievable document once (newest first), with the
+// content of all retrieved chunks of the same document joined together
+func (c *BedrockOpenSearchClient) ListDocuments(ctx context.Context) ([]map[string]interface{}, error) {
+	documents, err := c.retrieveDocuments(ctx, "*", exclusionFilter(ctx, c.sourceFilter))
+	if err != nil {
+		return nil, err
+	}
+
+	merged := c.mergeDocuments(c.simplifyDocuments(documents))
+	c.loadSourceContent(ctx, merged)
+	return merged, nil
+}
+
+// ListTopicVersions queries the knowledge base for the topic, filtered on source URIs
+// containing it, so versions are found however many other documents there are. Documents
+// whose topic only contains the requested one are dropped. Each version also carries its
+// yearMonth.
+func (c *BedrockOpenSearchClient) ListTopicVersions(ctx context.Context, topic string) ([]map[string]interface{}, error) {
+	filter := andFilters(
+		&types.RetrievalFilterMemberStringContains{
+			Value: types.FilterAttribute{
+				Key:   aws.String(sourceUriMetadataKey),
+				Value: document.NewLazyDocument(topic),
+			},
+		},
+		exclusionFilter(ctx, c.sourceFilter),
+	)
+	documents, err := c.retrieveDocuments(ctx, topic, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []map[string]interface{}
+	for _, doc := range c.simplifyDocuments(documents) {
+		if docTopic, _ := doc["topic"].(string); docTopic != topic {
+			continue
+		}
+		link, _ := doc["link"].(string)
+		doc["yearMonth"] = c.extractYearMonthFromUrl(UnsignedDocumentLink(link))
+		versions = append(versions, doc)
+	}
+
+	merged := c.mergeDocuments(versions)
+	c.loadSourceContent(ctx, merged)
+	return merged, nil
+}
+
+// DocumentKeyMetadata returns the topic, version and yearMonth that listings derive from the
+// key of a source document, e.g. "fee", 2 and "2025/05" for s3://bucket/content/2025/05/fee-2.pdf
+func DocumentKeyMetadata(s3Uri string) (topic string, version int, yearMonth string) {
+	// The extractors use no state of the client
+	var c BedrockOpenSearchClient
+	return c.extractTopicFromUrl(s3Uri), c.extractVersionNumber(s3Uri), c.extractYearMonthFromUrl(s3Uri)
+}
+
+// mergeDocuments keeps the first document of every link, with the content of all its
+// retrieved chunks joined together
+func (c *BedrockOpenSearchClient) mergeDocuments(documents []map[string]interface{}) []map[string]interface{} {
+	byLink := make(map[string]map[string]interface{})
+	merged := make([]map[string]interface{}, 0, len(documents))
+	for _, doc := range documents {
+		link, _ := doc["link"].(string)
+		if link == "" {
+			continue
+		}
+
+		if existing, ok := byLink[link]; ok {
+			existingContent, _ := existing["content"].(string)
+			if content, ok := doc["content"].(string); ok && content != "" {
+				existing["content"] = strings.TrimSpace(existingContent + "\n" + content)
+			}
+			continue
+		}
+
+		byLink[link] = doc
+		merged = append(merged, doc)
+	}
+	return merged
+}
+
+// loadSourceContent replaces the retrieved chunks of each document with the full text of its
+// source object, so comparisons and summaries see the whole document. A document whose
+// source cannot be read keeps its chunks.
+func (c *BedrockOpenSearchClient) loadSourceContent(ctx context.Context, documents []map[string]interface{}) {
+	if c.contentClient == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, sourceContentConcurrency)
+	for _, doc := range documents {
+		link, _ := doc["link"].(string)
+		if link == "" {
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(doc map[string]interface{}, link string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			text, err := c.contentClient.GetDocumentText(ctx, c.convertPublicUrlToS3Uri(link))
+			if err != nil {
+				logger.WithContext(ctx).Warn("Failed to read source document, using retrieved chunks", map[string]interface{}{
+					"link":  link,
+					"error": err.Error(),
+				})
+				warnings.Add(ctx, warnings.CodeSourceContentFallback, fmt.Sprintf("Full text of %s is unavailable, retrieved excerpts were used", link))
+				return
+			}
+			if text != "" {
+				doc["content"] = text
+			}
+		}(doc, link)
+	}
+	wg.Wait()
+}
+
+// retrieveDocuments fetches document chunks for the query from the knowledge base sorted
+// newest first, "*" querying all documents
+func (c *BedrockOpenSearchClient) retrieveDocuments(ctx context.Context, query string, filter types.RetrievalFilter) ([]map[string]interface{}, error) {
+	// Use Bedrock Agent Runtime Retrieve API to get documents from the knowledge base
+	// This retrieves documents from the underlying OpenSearch index
+	input := &bedrockagentruntime.RetrieveInput{
+		KnowledgeBaseId: aws.String(c.knowledgeBaseId),
+		RetrievalQuery: &types.KnowledgeBaseQuery{
+			Text: aws.String(query),
+		},
+		RetrievalConfiguration: &types.KnowledgeBaseRetrievalConfiguration{
+			VectorSearchConfiguration: &types.KnowledgeBaseVectorSearchConfiguration{
+				NumberOfResults: aws.Int32(100), // Adjust as needed
+				Filter:          filter,
+			},
+		},
+	}
+
+	output, err := c.client.Retrieve(ctx, input)
+	if err != nil {
+		return nil, c.handleAWSError(err)
+	}
+
+	// Parse the response and extract document details
+	var documents []map[string]interface{}
+
+	if output.RetrievalResults != nil {
+		for _, result := range output.RetrievalResults {
+			doc := make(map[string]interface{})
+
+			// Extract content
+			if result.Content != nil && result.Content.Text != nil {
+				doc["content"] = *result.Content.Text
+			}
+
+			// Extract score
+			if result.Score != nil {
+				doc["score"] = *result.Score
+			}
+
+			var s3Uri string
+
+			// Extract location information
+			if result.Location != nil {
+				location := make(map[string]interface{})
+
+				if result.Location.S3Location != nil {
+					s3Location := make(map[string]interface{})
+					if result.Location.S3Location.Uri != nil {
+						s3Uri = *result.Location.S3Location.Uri
+						s3Location["uri"] = s3Uri
+						s3Location["publicUrl"] = c.documentLinker.DocumentLink(ctx, s3Uri)
+					}
+					location["s3Location"] = s3Location
+				}
+
+				if result.Location.Type != "" {
+					location["type"] = string(result.Location.Type)
+				}
+
+				doc["location"] = location
+			}
+
+			// Extract and parse date from URL path (e.g., content/2025/05/)
+			yearMonth := c.extractYearMonthFromUrl(s3Uri)
+			doc["yearMonth"] = yearMonth
+			doc["sortKey"] = c.createSortKey(yearMonth)
+
+			// Extract version number from filename (e.g., -1, -2)
+			versionNumber := c.extractVersionNumber(s3Uri)
+			doc["version"] = versionNumber
+
+			// Extract last modified date from metadata
+			var lastModified time.Time
+			if result.Metadata != nil {
+				metadata := make(map[string]interface{})
+				for key, value := range result.Metadata {
+					// Convert document.Interface to string representation
+					if valueBytes, err := json.Marshal(value); err == nil {
+						var jsonValue interface{}
+						if err := json.Unmarshal(valueBytes, &jsonValue); err == nil {
+							metadata[key] = jsonValue
+
+							// Try to extract last modified date
+							if strings.Contains(strings.ToLower(key), "modified") ||
+								strings.Contains(strings.ToLower(key), "updated") ||
+								key == "lastModified" || key == "last_modified" {
+								if dateStr, ok := jsonValue.(string); ok {
+									if parsedTime, err := time.Parse(time.RFC3339, dateStr); err == nil {
+										lastModified = parsedTime
+									}
+								}
+							}
+						} else {
+							metadata[key] = string(valueBytes)
+						}
+					}
+				}
+				doc["metadata"] = metadata
+			}
+
+			doc["lastModified"] = lastModified
+			doc["lastModifiedUnix"] = lastModified.Unix()
+
+			documents = append(documents, doc)
+		}
+	}
+
+	// Sort with multiple criteria:
+	// 1. Year/Month (newest first)
+	// 2. Last modified date (newest first)
+	// 3. Version number (highest version first: -2, -1, no version)
+	sort.Slice(documents, func(i, j int) bool {
+		// Primary: Sort by year/month
+		sortKeyI := documents[i]["sortKey"].(string)
+		sortKeyJ := documents[j]["sortKey"].(string)
+
+		if sortKeyI != sortKeyJ {
+			return sortKeyI > sortKeyJ // Descending (newest first)
+		}
+
+		// Secondary: Sort by last modified date
+		lastModI := documents[i]["lastModifiedUnix"].(int64)
+		lastModJ := documents[j]["lastModifiedUnix"].(int64)
+
+		if lastModI != lastModJ {
+			return lastModI > lastModJ // Descending (newest first)
+		}
+
+		// Tertiary: Sort by version number
+		versionI := documents[i]["version"].(int)
+		versionJ := documents[j]["version"].(int)
+
+		return versionI > versionJ // Descending (highest version first)
+	})
+
+	return documents, nil
+}
+
+// simplifyDocuments transforms raw retrieval results to the simplified response format
+func (c *BedrockOpenSearchClient) simplifyDocuments(documents []map[string]interface{}) []map[string]interface{} {
+	simplifiedDocs := make([]map[string]interface{}, 0, len(documents))
+	for _, doc := range documents {
+		simplified := make(map[string]interface{})
+
+		// 1. lastModifyDate
+		if lastMod, ok := doc["lastModified"].(time.Time); ok && !lastMod.IsZero() {
+			simplified["lastModifyDate"] = lastMod.Format(time.RFC3339)
+		} else {
+			// Use yearMonth as fallback
+			if yearMonth, ok := doc["yearMonth"].(string); ok {
+				simplified["lastModifyDate"] = yearMonth
+			} else {
+				simplified["lastModifyDate"] = ""
+			}
+		}
+
+		// 2. link (public URL)
+		if location, ok := doc["location"].(map[string]interface{}); ok {
+			if s3Location, ok := location["s3Location"].(map[string]interface{}); ok {
+				if publicUrl, ok := s3Location["publicUrl"].(string); ok {
+					simplified["link"] = publicUrl
+				}
+			}
+		}
+
+		// 3. topic (extracted from filename)
+		var topic string
+		var publicUrl string
+		if location, ok := doc["location"].(map[string]interface{}); ok {
+			if s3Location, ok := location["s3Location"].(map[string]interface{}); ok {
+				if url, ok := s3Location["publicUrl"].(string); ok {
+					topic = c.extractTopicFromUrl(UnsignedDocumentLink(url))
+					publicUrl = url
+					simplified["topic"] = topic
+					simplified["link"] = publicUrl
+				}
+			}
+		}
+
+		// 4. version - current version number
+		currentVersion := 0
+		if version, ok := doc["version"].(int); ok {
+			currentVersion = version
+			simplified["version"] = currentVersion
+		}
+
+		// 5. changeSummary - compare with older version if exists
+		simplified["changeSummary"] = ""
+
+		// 6. Keep content for version comparison (will be removed by service layer)
+		if content, ok := doc["content"].(string); ok {
+			simplified["content"] = content
+		}
+
+		simplifiedDocs = append(simplifiedDocs, simplified)
+	}
+
+	return simplifiedDocs
+}
+
+// GetDocumentChunks returns the chunks indexed for a single document, using a Retrieve
+// call filtered on the source URI. Accepts either an s3:// URI or the public URL.
+func (c *BedrockOpenSearchClient) GetDocumentChunks(ctx context.Context, documentUri string) ([]DocumentChunk, error) {
+	// Retrieve needs query text, the topic keeps scores meaningful for the document
+	topic := c.extractTopicFromUrl(c.convertPublicUrlToS3Uri(documentUri))
+	if topic == "" {
+		topic = "*"
+	}
+
+	chunks, err := c.retrieveDocumentChunks(ctx, documentUri, topic, 100) // Retrieve API maximum
+	if err != nil {
+		return nil, err
+	}
+
+	// Present chunks in document order when page numbers are available
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].PageNumber < chunks[j].PageNumber
+	})
+
+	return chunks, nil
+}
+
+// GetDocumentContent returns the full text of the source object of a document like the
+// listings do, or its chunks in document order without a content client. Its chunks are
+// retrieved either way, so a document the caller may not see, or that is not indexed, has
+// no content.
+func (c *BedrockOpenSearchClient) GetDocumentContent(ctx context.Context, documentUri string) (string, error) {
+	chunks, err := c.GetDocumentChunks(ctx, documentUri)
+	if err != nil || len(chunks) == 0 {
+		return "", err
+	}
+
+	contents := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		contents = append(contents, chunk.Content)
+	}
+	doc := map[string]interface{}{
+		"link":    documentUri,
+		"content": strings.TrimSpace(strings.Join(contents, "\n")),
+	}
+	c.loadSourceContent(ctx, []map[string]interface{}{doc})
+	content, _ := doc["content"].(string)
+	return content, nil
+}
+
+// SearchDocumentChunks runs a Retrieve call for the query filtered on the source URI.
+// Accepts either an s3:// URI or the public URL.
+func (c *BedrockOpenSearchClient) SearchDocumentChunks(ctx context.Context, documentUri string, query string, numberOfResults int) ([]DocumentChunk, error) {
+	chunks, err := c.retrieveDocumentChunks(ctx, documentUri, query, numberOfResults)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].Score > chunks[j].Score
+	})
+
+	return chunks, nil
+}
+
+// retrieveDocumentChunks returns the chunks of a single document retrieved for the query,
+// none when the document is excluded
+func (c *BedrockOpenSearchClient) retrieveDocumentChunks(ctx context.Context, documentUri string, query string, numberOfResults int) ([]DocumentChunk, error) {
+	s3Uri := c.convertPublicUrlToS3Uri(documentUri)
+	if isExcludedSource(ctx, c.sourceFilter, s3Uri) {
+		return []DocumentChunk{}, nil
+	}
+
+	input := &bedrockagentruntime.RetrieveInput{
+		KnowledgeBaseId: aws.String(c.knowledgeBaseId),
+		RetrievalQuery: &types.KnowledgeBaseQuery{
+			Text: aws.String(query),
+		},
+		RetrievalConfiguration: &types.KnowledgeBaseRetrievalConfiguration{
+			VectorSearchConfiguration: &types.KnowledgeBaseVectorSearchConfiguration{
+				NumberOfResults: aws.Int32(int32(numberOfResults)),
+				Filter: andFilters(
+					&types.RetrievalFilterMemberEquals{
+						Value: types.FilterAttribute{
+							Key:   aws.String("x-amz-bedrock-kb-source-uri"),
+							Value: document.NewLazyDocument(s3Uri),
+						},
+					},
+					exclusionFilter(ctx, c.sourceFilter),
+				),
+			},
+		},
+	}
+
+	output, err := c.client.Retrieve(ctx, input)
+	if err != nil {
+		return nil, c.handleAWSError(err)
+	}
+
+	chunks := make([]DocumentChunk, 0, len(output.RetrievalResults))
+	for _, result := range output.RetrievalResults {
+		chunk := DocumentChunk{}
+		if result.Content != nil && result.Content.Text != nil {
+			chunk.Content = *result.Content.Text
+		}
+		if result.Score != nil {
+			chunk.Score = *result.Score
+		}
+
+		chunk.Metadata = c.convertMetadata(result.Metadata)
+		if value, ok := result.Metadata["x-amz-bedrock-kb-chunk-id"]; ok {
+			value.UnmarshalSmithyDocument(&chunk.ChunkId)
+		}
+		if value, ok := result.Metadata["x-amz-bedrock-kb-document-page-number"]; ok {
+			var page float64
+			value.UnmarshalSmithyDocument(&page)
+			chunk.PageNumber = int(page)
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, nil
+}
+
+// convertMetadata converts retrieval metadata documents to plain JSON values
+func (c *BedrockOpenSearchClient) convertMetadata(metadata map[string]document.Interface) map[string]interface{} {
+	converted := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		var jsonValue interface{}
+		if err := value.UnmarshalSmithyDocument(&jsonValue); err == nil {
+			converted[key] = jsonValue
+		}
+	}
+	return converted
+}
+
+// extractYearMonthFromUrl extracts year/month from URL path like "content/2025/05/"
+func (c *BedrockOpenSearchClient) extractYearMonthFromUrl(url string) string {
+	// Pattern to match year/month in the URL path (e.g., /2025/05/)
+	re := regexp.MustCompile(`/(\d{4})/(\d{2})/`)
+	matches := re.FindStringSubmatch(url)
+
+	if len(matches) >= 3 {
+		year := matches[1]
+		month := matches[2]
+		return fmt.Sprintf("%s/%s", year, month)
+	}
+
+	return "0000/00" // Default for documents without date in path
+}
+
+// createSortKey creates a sortable key from year/month string
+func (c *BedrockOpenSearchClient) createSortKey(yearMonth string) string {
+	// Convert "2025/05" to "202505" for easy sorting
+	return strings.ReplaceAll(yearMonth, "/", "")
+}
+
+// extractVersionNumber extracts version number from filename
+// Examples:
+//   - "file-1-2.pdf" -> 2
+//   - "file-1-1.pdf" -> 1
+//   - "file-1.pdf" -> 0
+//   - "Horaland1-2.pdf" -> 2
+func (c *BedrockOpenSearchClient) extractVersionNumber(url string) int {
+	// Extract filename from URL
+	parts := strings.Split(url, "/")
+	if len(parts) == 0 {
+		return 0
+	}
+	filename := parts[len(parts)-1]
+
+	// Remove file extension
+	filename = strings.TrimSuffix(filename, ".pdf")
+	filename = strings.TrimSuffix(filename, ".PDF")
+	filename = strings.TrimSuffix(filename, ".doc")
+	filename = strings.TrimSuffix(filename, ".docx")
+	filename = strings.TrimSuffix(filename, ".txt")
+
+	// Pattern to match version number at the end: -1, -2, etc.
+	re := regexp.MustCompile(`-(\d+)$`)
+	matches := re.FindStringSubmatch(filename)
+
+	if len(matches) >= 2 {
+		if version, err := strconv.Atoi(matches[1]); err == nil {
+			return version
+		}
+	}
+
+	return 0 // No version number found
+}
+
+// extractTopicFromUrl extracts the topic/title from the filename in URL
+// Examples:
+//   - "https://.../สื่อความสาขา-_-Horaland1-2.pdf" -> "สื่อความสาขา-_-Horaland1"
+//   - "https://.../การขอลดค่างวด-waive.pdf" -> "การขอลดค่างวด-waive"
+func (c *BedrockOpenSearchClient) extractTopicFromUrl(url string) string {
+	// Extract filename from URL
+	parts := strings.Split(url, "/")
+	if len(parts) == 0 {
+		return ""
+	}
+	filename := parts[len(parts)-1]
+
+	// Remove file extension
+	filename = strings.TrimSuffix(filename, ".pdf")
+	filename = strings.TrimSuffix(filename, ".PDF")
+	filename = strings.TrimSuffix(filename, ".doc")
+	filename = strings.TrimSuffix(filename, ".docx")
+	filename = strings.TrimSuffix(filename, ".txt")
+
+	// Remove version number suffix if present (e.g., -1, -2)
+	re := regexp.MustCompile(`-(\d+)$`)
+	filename = re.ReplaceAllString(filename, "")
+
+	return filename
+}
+
+// convertPublicUrlToS3Uri converts a bucket URL, pre-signed or not, back to its s3:// URI
+func (c *BedrockOpenSearchClient) convertPublicUrlToS3Uri(publicUrl string) string {
+	re := regexp.MustCompile(`^https://([^.]+)\.s3\.[^.]+\.amazonaws\.com/(.+)$`)
+	matches := re.FindStringSubmatch(UnsignedDocumentLink(publicUrl))
+	if len(matches) >= 3 {
+		return fmt.Sprintf("s3://%s/%s", matches[1], matches[2])
+	}
+	return publicUrl
+}
+
+// CompareDocumentVersions diffs two document versions line by line and has Bedrock summarize
+// only the changed sections, so long documents fit the prompt and the model cannot report
+// changes that are not in the diff. Versions with the same text are not sent to Bedrock.
+func (c *BedrockOpenSearchClient) CompareDocumentVersions(ctx context.Context, newerContent, olderContent, topic string) (string, error) {
+	hunks := utils.DiffText(olderContent, newerContent, comparisonContextLines)
+	if len(hunks) == 0 {
+		return unchangedComparison, nil
+	}
+
+	// Create a prompt using the document comparison instructions
+	prompt := fmt.Sprintf(`%s
+
+Document Topic: %s
+
+Changes from the older to the newer version, as diff hunks separated by "@@". Lines starting with "-" were removed, lines starting with "+" were added, the other lines are unchanged context:
+%s
+Please analyze and provide the comparison in JSON format.`, c.documentComparisonInstructions(), topic, comparisonDiff(hunks))
+
+	// Use the KB client to query Bedrock
+	answer, _, err := c.kbClient.QueryKnowledgeBase(ctx, prompt, false)
+	if err != nil {
+		return "", err
+	}
+
+	return answer, nil
+}
+
+// comparisonDiff formats the hunks up to maxComparisonDiffTokens, noting what was left out.
+// A first hunk over the limit, like that of a rewritten document, is cut.
+func comparisonDiff(hunks []utils.DiffHunk) string {
+	var b strings.Builder
+	tokens := 0
+	for i, hunk := range hunks {
+		text := hunk.String()
+		if tokens+utils.EstimateTokens(text) > maxComparisonDiffTokens {
+			if i > 0 {
+				fmt.Fprintf(&b, "(%d more changed sections are not shown)\n", len(hunks)-i)
+				break
+			}
+			for len(hunk) > 1 && utils.EstimateTokens(text) > maxComparisonDiffTokens {
+				hunk = hunk[:len(hunk)/2]
+				text = hunk.String()
+			}
+			text += "(the rest of this section is not shown)\n"
+		}
+		b.WriteString(text)
+		tokens += utils.EstimateTokens(text)
+	}
+	return b.String()
+}
+
+// SummarizeDocument uses Bedrock to produce a short summary of a single document
+func (c *BedrockOpenSearchClient) SummarizeDocument(ctx context.Context, content, topic string) (string, error) {
+	prompt := fmt.Sprintf(`%s
+
+Document Topic: %s
+
+Document Content:
+%s`, c.documentSummaryInstructions(), topic, content)
+
+	answer, _, err := c.kbClient.QueryKnowledgeBase(ctx, prompt, false)
+	if err != nil {
+		return "", err
+	}
+
+	return answer, nil
+}
+
+func (c *BedrockOpenSearchClient) handleAWSError(err error) error {
+	errMsg := err.Error()
+
+	if contains(errMsg, "ValidationException") || contains(errMsg, "invalid") {
+		return errors.NewValidationError(fmt.Sprintf("invalid OpenSearch query: %v", err))
+	}
+
+	if contains(errMsg, "ThrottlingException") || contains(errMsg, "TooManyRequestsException") {
+		return errors.NewThrottlingError("OpenSearch service throttled", err)
+	}
+
+	if contains(errMsg, "AccessDeniedException") || contains(errMsg, "UnauthorizedException") {
+		return errors.NewAWSServiceError("invalid or missing AWS credentials", err)
+	}
+
+	if contains(errMsg, "ResourceNotFoundException") {
+		return errors.NewAWSServiceError("knowledge base not found", err)
+	}
+
+	if contains(errMsg, "ServiceUnavailableException") || contains(errMsg, "InternalServerException") {
+		return errors.NewAWSServiceError("OpenSearch service unavailable", err)
+	}
+
+	if contains(errMsg, "TimeoutException") || contains(errMsg, "timeout") {
+		return errors.NewAWSServiceError("OpenSearch query timeout", err)
+	}
+
+	return errors.NewAWSServiceError("OpenSearch query failed", err)
+}
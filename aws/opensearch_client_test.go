@@ -0,0 +1,46 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestGetDocumentChunks_ReadsChunkMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"retrievalResults": [
+			{"content": {"text": "waived in the first year"}, "score": 0.9, "metadata": {"x-amz-bedrock-kb-chunk-id": "chunk-2", "x-amz-bedrock-kb-document-page-number": 2}},
+			{"content": {"text": "annual fee 200 baht"}, "score": 0.8, "metadata": {"x-amz-bedrock-kb-chunk-id": "chunk-1", "x-amz-bedrock-kb-document-page-number": 1}}
+		]}`))
+	}))
+	defer server.Close()
+
+	cfg := aws.Config{
+		Region:           "ap-southeast-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		BaseEndpoint:     aws.String(server.URL),
+		RetryMaxAttempts: 1,
+	}
+	client := NewBedrockOpenSearchClient(cfg, "kb-1", NewPublicDocumentLinker("ap-southeast-1"), nil, "", nil, nil, nil, nil)
+
+	chunks, err := client.GetDocumentChunks(context.Background(), "s3://docs/content/2025/05/fees-1.pdf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+
+	// Chunks come back in page order
+	if chunks[0].ChunkId != "chunk-1" || chunks[0].PageNumber != 1 || chunks[1].ChunkId != "chunk-2" || chunks[1].PageNumber != 2 {
+		t.Errorf("expected the chunk IDs and page numbers in page order, got %+v", chunks)
+	}
+	if chunks[0].Metadata["x-amz-bedrock-kb-chunk-id"] != "chunk-1" {
+		t.Errorf("expected the metadata values, got %+v", chunks[0].Metadata)
+	}
+}
@@ -0,0 +1,57 @@
+package aws
+
+import (
+	"context"
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/comprehend"
+	"github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+)
+
+// PIIEntity is personal data found in a text. Offsets count characters (runes), the end is
+// exclusive.
+type PIIEntity struct {
+	Type        string
+	BeginOffset int
+	EndOffset   int
+	Score       float64
+}
+
+type PIIDetectionClient interface {
+	// DetectPII finds personal data in English text, the only language of the questions that
+	// Amazon Comprehend PII detection supports
+	DetectPII(ctx context.Context, text string) ([]PIIEntity, error)
+}
+
+// ComprehendPIIClient detects personal data with Amazon Comprehend
+type ComprehendPIIClient struct {
+	client *comprehend.Client
+}
+
+func NewComprehendPIIClient(cfg aws.Config) *ComprehendPIIClient {
+	return &ComprehendPIIClient{
+		client: comprehend.NewFromConfig(cfg),
+	}
+}
+
+func (c *ComprehendPIIClient) DetectPII(ctx context.Context, text string) ([]PIIEntity, error) {
+	output, err := c.client.DetectPiiEntities(ctx, &comprehend.DetectPiiEntitiesInput{
+		Text:         aws.String(text),
+		LanguageCode: types.LanguageCodeEn,
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("Amazon Comprehend PII detection failed", err)
+	}
+
+	entities := make([]PIIEntity, 0, len(output.Entities))
+	for _, entity := range output.Entities {
+		entities = append(entities, PIIEntity{
+			Type:        string(entity.Type),
+			BeginOffset: int(aws.ToInt32(entity.BeginOffset)),
+			EndOffset:   int(aws.ToInt32(entity.EndOffset)),
+			Score:       float64(aws.ToFloat32(entity.Score)),
+		})
+	}
+	return entities, nil
+}
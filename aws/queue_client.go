@@ -0,0 +1,35 @@
+package aws
+
+import (
+	"context"
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+type QueueClient interface {
+	SendMessage(ctx context.Context, queueUrl string, body string) error
+}
+
+// SQSQueueClient sends messages to SQS queues
+type SQSQueueClient struct {
+	client *sqs.Client
+}
+
+func NewSQSQueueClient(cfg aws.Config) *SQSQueueClient {
+	return &SQSQueueClient{
+		client: sqs.NewFromConfig(cfg),
+	}
+}
+
+func (c *SQSQueueClient) SendMessage(ctx context.Context, queueUrl string, body string) error {
+	_, err := c.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(queueUrl),
+		MessageBody: aws.String(body),
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to send message to queue", err)
+	}
+	return nil
+}
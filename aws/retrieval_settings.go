@@ -0,0 +1,70 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+)
+
+// MaxRetrievalResults is the most results the Retrieve API returns per knowledge base
+const MaxRetrievalResults = 100
+
+// RetrievalSettings bound the chunks knowledge base retrieval passes on to an answer and
+// its related documents
+type RetrievalSettings struct {
+	NumberOfResults int      // Chunks retrieved per knowledge base, 0 for the default
+	MinScore        *float64 // Chunks scoring below it are dropped, nil for the default
+}
+
+// Or returns the settings with the unset ones taken from defaults
+func (s RetrievalSettings) Or(defaults RetrievalSettings) RetrievalSettings {
+	if s.NumberOfResults <= 0 {
+		s.NumberOfResults = defaults.NumberOfResults
+	}
+	if s.MinScore == nil {
+		s.MinScore = defaults.MinScore
+	}
+	return s
+}
+
+// Keeps reports whether a chunk of the score reaches the minimum score
+func (s RetrievalSettings) Keeps(score float64) bool {
+	return s.MinScore == nil || score >= *s.MinScore
+}
+
+// Filter drops the chunks scoring below the minimum score
+func (s RetrievalSettings) Filter(chunks []RetrievedChunk) []RetrievedChunk {
+	kept := make([]RetrievedChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		if s.Keeps(chunk.Score) {
+			kept = append(kept, chunk)
+		}
+	}
+	return kept
+}
+
+// Key identifies the settings, empty when nothing is set
+func (s RetrievalSettings) Key() string {
+	if s.NumberOfResults <= 0 && s.MinScore == nil {
+		return ""
+	}
+	key := fmt.Sprintf("results=%d", s.NumberOfResults)
+	if s.MinScore != nil {
+		key += fmt.Sprintf(";minScore=%g", *s.MinScore)
+	}
+	return key
+}
+
+type retrievalSettingsKey struct{}
+
+// WithRetrievalSettings attaches the retrieval settings a request asked for to its context.
+// Unset settings keep the configured ones.
+func WithRetrievalSettings(ctx context.Context, settings RetrievalSettings) context.Context {
+	return context.WithValue(ctx, retrievalSettingsKey{}, settings)
+}
+
+// RetrievalSettingsFromContext returns the retrieval settings of the request, unset when
+// it did not ask for any
+func RetrievalSettingsFromContext(ctx context.Context) RetrievalSettings {
+	settings, _ := ctx.Value(retrievalSettingsKey{}).(RetrievalSettings)
+	return settings
+}
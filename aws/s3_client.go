@@ -0,0 +1,195 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"teletubpax-api/errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// metadataFileSuffix is the suffix of the Bedrock knowledge base metadata file stored next
+// to a source document
+const metadataFileSuffix = ".metadata.json"
+
+type ObjectStorageClient interface {
+	// DeleteDocument removes a source document and its metadata file, deleting a missing
+	// object is not an error
+	DeleteDocument(ctx context.Context, s3Uri string) error
+}
+
+type ObjectWriterClient interface {
+	// PutObject writes an object, replacing any object with the same key
+	PutObject(ctx context.Context, bucket string, key string, body []byte, contentType string) error
+}
+
+// StoredObject is the content of an object and when it was last written
+type StoredObject struct {
+	Body         []byte
+	LastModified time.Time
+}
+
+type ObjectReaderClient interface {
+	// GetObject reads an object of up to maxBytes, nil without an error when it does not exist
+	GetObject(ctx context.Context, bucket string, key string, maxBytes int64) (*StoredObject, error)
+	// ObjectLastModified returns when an object was last written, nil without an error when
+	// it does not exist
+	ObjectLastModified(ctx context.Context, bucket string, key string) (*time.Time, error)
+}
+
+type ObjectArchiveClient interface {
+	// ArchiveDocument copies a source document and its metadata file to the same key in
+	// another bucket, and returns the s3:// URI of the copy
+	ArchiveDocument(ctx context.Context, s3Uri string, archiveBucket string) (string, error)
+}
+
+type ObjectLinkClient interface {
+	// PresignGetObject returns a URL reading the object without credentials until expiry
+	PresignGetObject(ctx context.Context, s3Uri string, expiry time.Duration) (string, error)
+}
+
+type S3ObjectStorageClient struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+}
+
+func NewS3ObjectStorageClient(cfg aws.Config) *S3ObjectStorageClient {
+	client := s3.NewFromConfig(cfg)
+	return &S3ObjectStorageClient{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+	}
+}
+
+func (c *S3ObjectStorageClient) DeleteDocument(ctx context.Context, s3Uri string) error {
+	bucket, key, ok := splitS3Uri(s3Uri)
+	if !ok {
+		return errors.NewValidationError(fmt.Sprintf("invalid s3 URI: %s", s3Uri))
+	}
+
+	for _, objectKey := range []string{key, key + metadataFileSuffix} {
+		_, err := c.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(objectKey),
+		})
+		if err != nil {
+			return errors.NewAWSServiceError("failed to delete document object", err)
+		}
+	}
+	return nil
+}
+
+func (c *S3ObjectStorageClient) PutObject(ctx context.Context, bucket string, key string, body []byte, contentType string) error {
+	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write object", err)
+	}
+	return nil
+}
+
+func (c *S3ObjectStorageClient) GetObject(ctx context.Context, bucket string, key string, maxBytes int64) (*StoredObject, error) {
+	output, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if stdErrors.As(err, &noSuchKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read object", err)
+	}
+	defer output.Body.Close()
+
+	if aws.ToInt64(output.ContentLength) > maxBytes {
+		return nil, fmt.Errorf("object s3://%s/%s exceeds %d bytes", bucket, key, maxBytes)
+	}
+	body, err := io.ReadAll(io.LimitReader(output.Body, maxBytes))
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read object", err)
+	}
+	return &StoredObject{Body: body, LastModified: aws.ToTime(output.LastModified)}, nil
+}
+
+func (c *S3ObjectStorageClient) ObjectLastModified(ctx context.Context, bucket string, key string) (*time.Time, error) {
+	output, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	// HeadObject has no body, so a missing object is only reported as NotFound
+	var notFound *types.NotFound
+	if stdErrors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read object metadata", err)
+	}
+	return output.LastModified, nil
+}
+
+func (c *S3ObjectStorageClient) ArchiveDocument(ctx context.Context, s3Uri string, archiveBucket string) (string, error) {
+	bucket, key, ok := splitS3Uri(s3Uri)
+	if !ok {
+		return "", errors.NewValidationError(fmt.Sprintf("invalid s3 URI: %s", s3Uri))
+	}
+
+	for _, objectKey := range []string{key, key + metadataFileSuffix} {
+		_, err := c.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(archiveBucket),
+			Key:        aws.String(objectKey),
+			CopySource: aws.String((&url.URL{Path: bucket + "/" + objectKey}).EscapedPath()),
+		})
+		// Documents without metadata have no metadata file to archive
+		var noSuchKey *types.NoSuchKey
+		if stdErrors.As(err, &noSuchKey) && objectKey != key {
+			continue
+		}
+		if err != nil {
+			return "", errors.NewAWSServiceError("failed to archive document object", err)
+		}
+	}
+	return "s3://" + archiveBucket + "/" + key, nil
+}
+
+// PresignGetObject signs a GetObject request with the client's credentials. The URL stops
+// working at expiry, or earlier when temporary credentials such as a Lambda role's expire.
+func (c *S3ObjectStorageClient) PresignGetObject(ctx context.Context, s3Uri string, expiry time.Duration) (string, error) {
+	bucket, key, ok := splitS3Uri(s3Uri)
+	if !ok {
+		return "", errors.NewValidationError(fmt.Sprintf("invalid s3 URI: %s", s3Uri))
+	}
+
+	request, err := c.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", errors.NewAWSServiceError("failed to pre-sign object URL", err)
+	}
+	return request.URL, nil
+}
+
+// splitS3Uri splits s3://bucket/key into its bucket and key
+func splitS3Uri(s3Uri string) (string, string, bool) {
+	if !strings.HasPrefix(s3Uri, "s3://") {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(s3Uri, "s3://"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
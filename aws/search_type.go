@@ -0,0 +1,38 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+// Search types of knowledge base retrieval. Hybrid search adds keyword matching to the
+// vector search, so exact terms such as policy codes are found.
+const (
+	SearchTypeHybrid   = string(types.SearchTypeHybrid)
+	SearchTypeSemantic = string(types.SearchTypeSemantic)
+)
+
+type searchTypeKey struct{}
+
+// WithSearchType attaches the search type a request asked for to its context. An empty
+// search type keeps the configured one.
+func WithSearchType(ctx context.Context, searchType string) context.Context {
+	return context.WithValue(ctx, searchTypeKey{}, searchType)
+}
+
+// SearchTypeFromContext returns the search type of the request, "" when it did not ask
+// for one
+func SearchTypeFromContext(ctx context.Context) string {
+	searchType, _ := ctx.Value(searchTypeKey{}).(string)
+	return searchType
+}
+
+// overrideSearchType returns the search type of a retrieval: the request's, else the
+// configured one. It is empty when neither is set, leaving the choice to Bedrock.
+func overrideSearchType(ctx context.Context, configured string) types.SearchType {
+	if searchType := SearchTypeFromContext(ctx); searchType != "" {
+		return types.SearchType(searchType)
+	}
+	return types.SearchType(configured)
+}
@@ -0,0 +1,37 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+type SecretsClient interface {
+	GetSecretString(ctx context.Context, secretId string) (string, error)
+}
+
+type SecretsManagerClient struct {
+	client *secretsmanager.Client
+}
+
+func NewSecretsManagerClient(cfg aws.Config) *SecretsManagerClient {
+	return &SecretsManagerClient{
+		client: secretsmanager.NewFromConfig(cfg),
+	}
+}
+
+func (c *SecretsManagerClient) GetSecretString(ctx context.Context, secretId string) (string, error) {
+	output, err := c.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretId),
+	})
+	if err != nil {
+		return "", errors.NewAWSServiceError("failed to read secret", err)
+	}
+	if output.SecretString == nil || *output.SecretString == "" {
+		return "", errors.NewAWSServiceError("secret has no string value", fmt.Errorf("secret %s is empty", secretId))
+	}
+	return *output.SecretString, nil
+}
@@ -0,0 +1,143 @@
+package aws
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+// sourceUriMetadataKey is the metadata attribute Bedrock sets to the s3:// URI of each chunk
+const sourceUriMetadataKey = "x-amz-bedrock-kb-source-uri"
+
+// SourceFilter lists documents that must not be retrieved or listed, e.g. soft-deleted
+// documents that are still indexed until their scheduled purge and the next sync
+type SourceFilter interface {
+	ExcludedSourceUris(ctx context.Context) []string
+}
+
+// excludedSources returns the excluded s3:// URIs, nil when there is no filter
+func excludedSources(ctx context.Context, filter SourceFilter) []string {
+	if filter == nil {
+		return nil
+	}
+	return filter.ExcludedSourceUris(ctx)
+}
+
+// DocumentAccess lists the metadata attribute values a caller is not entitled to, e.g.
+// {"confidentiality": ["restricted"]}. Chunks of documents with a denied value are never
+// retrieved for the caller; documents without the attribute are not restricted.
+type DocumentAccess struct {
+	Denied map[string][]string
+}
+
+// Restricted reports whether the access hides any documents
+func (a *DocumentAccess) Restricted() bool {
+	if a == nil {
+		return false
+	}
+	for _, values := range a.Denied {
+		if len(values) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Key identifies the access, callers with the same key see the same documents. It is empty
+// when nothing is denied.
+func (a *DocumentAccess) Key() string {
+	if !a.Restricted() {
+		return ""
+	}
+	var parts []string
+	for _, attribute := range a.attributes() {
+		values := append([]string(nil), a.Denied[attribute]...)
+		sort.Strings(values)
+		parts = append(parts, attribute+"="+strings.Join(values, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// attributes returns the attributes with denied values, sorted so filters are stable
+func (a *DocumentAccess) attributes() []string {
+	var attributes []string
+	for attribute, values := range a.Denied {
+		if len(values) > 0 {
+			attributes = append(attributes, attribute)
+		}
+	}
+	sort.Strings(attributes)
+	return attributes
+}
+
+type documentAccessKey struct{}
+
+// WithDocumentAccess attaches the caller's document access to the context of a request
+func WithDocumentAccess(ctx context.Context, access *DocumentAccess) context.Context {
+	return context.WithValue(ctx, documentAccessKey{}, access)
+}
+
+// DocumentAccessFromContext returns the caller's document access, nil when the caller is
+// not restricted
+func DocumentAccessFromContext(ctx context.Context) *DocumentAccess {
+	access, _ := ctx.Value(documentAccessKey{}).(*DocumentAccess)
+	return access
+}
+
+// exclusionFilter builds the retrieval filter that drops chunks of excluded documents and of
+// documents the caller may not see, nil when nothing is excluded so retrieval stays
+// unfiltered
+func exclusionFilter(ctx context.Context, filter SourceFilter) types.RetrievalFilter {
+	var filters []types.RetrievalFilter
+	if excluded := excludedSources(ctx, filter); len(excluded) > 0 {
+		filters = append(filters, &types.RetrievalFilterMemberNotIn{
+			Value: types.FilterAttribute{
+				Key:   aws.String(sourceUriMetadataKey),
+				Value: document.NewLazyDocument(excluded),
+			},
+		})
+	}
+	if access := DocumentAccessFromContext(ctx); access.Restricted() {
+		for _, attribute := range access.attributes() {
+			filters = append(filters, &types.RetrievalFilterMemberNotIn{
+				Value: types.FilterAttribute{
+					Key:   aws.String(attribute),
+					Value: document.NewLazyDocument(access.Denied[attribute]),
+				},
+			})
+		}
+	}
+	return andFilters(filters...)
+}
+
+// andFilters combines retrieval filters, Bedrock rejects andAll with fewer than two filters
+func andFilters(filters ...types.RetrievalFilter) types.RetrievalFilter {
+	var present []types.RetrievalFilter
+	for _, filter := range filters {
+		if filter != nil {
+			present = append(present, filter)
+		}
+	}
+	switch len(present) {
+	case 0:
+		return nil
+	case 1:
+		return present[0]
+	default:
+		return &types.RetrievalFilterMemberAndAll{Value: present}
+	}
+}
+
+// isExcludedSource reports whether the s3:// URI belongs to an excluded document
+func isExcludedSource(ctx context.Context, filter SourceFilter, s3Uri string) bool {
+	for _, excluded := range excludedSources(ctx, filter) {
+		if excluded == s3Uri {
+			return true
+		}
+	}
+	return false
+}
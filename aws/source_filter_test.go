@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+type staticSourceFilter []string
+
+func (f staticSourceFilter) ExcludedSourceUris(ctx context.Context) []string {
+	return f
+}
+
+func TestExclusionFilter(t *testing.T) {
+	ctx := context.Background()
+
+	if filter := exclusionFilter(ctx, nil); filter != nil {
+		t.Errorf("expected no filter without a source filter, got %T", filter)
+	}
+	if filter := exclusionFilter(ctx, staticSourceFilter{}); filter != nil {
+		t.Errorf("expected no filter when nothing is excluded, got %T", filter)
+	}
+
+	filter, ok := exclusionFilter(ctx, staticSourceFilter{"s3://docs/old.pdf"}).(*types.RetrievalFilterMemberNotIn)
+	if !ok {
+		t.Fatal("expected a notIn filter")
+	}
+	if *filter.Value.Key != sourceUriMetadataKey {
+		t.Errorf("expected the filter on %s, got %s", sourceUriMetadataKey, *filter.Value.Key)
+	}
+}
+
+func TestIsExcludedSource(t *testing.T) {
+	filter := staticSourceFilter{"s3://docs/old.pdf"}
+
+	if !isExcludedSource(context.Background(), filter, "s3://docs/old.pdf") {
+		t.Error("expected the deleted document to be excluded")
+	}
+	if isExcludedSource(context.Background(), filter, "s3://docs/new.pdf") {
+		t.Error("expected other documents to be kept")
+	}
+	if isExcludedSource(context.Background(), nil, "s3://docs/old.pdf") {
+		t.Error("expected nothing excluded without a filter")
+	}
+}
+
+func TestExclusionFilter_DocumentAccess(t *testing.T) {
+	access := &DocumentAccess{Denied: map[string][]string{
+		"department":      {"treasury"},
+		"confidentiality": {"secret", "restricted"},
+	}}
+	ctx := WithDocumentAccess(context.Background(), access)
+
+	if got := access.Key(); got != "confidentiality=restricted,secret;department=treasury" {
+		t.Errorf("expected a stable access key, got %q", got)
+	}
+
+	filter, ok := exclusionFilter(ctx, staticSourceFilter{"s3://docs/old.pdf"}).(*types.RetrievalFilterMemberAndAll)
+	if !ok {
+		t.Fatal("expected an andAll filter")
+	}
+	if len(filter.Value) != 3 {
+		t.Fatalf("expected the source and both attribute filters, got %d", len(filter.Value))
+	}
+	if notIn := filter.Value[1].(*types.RetrievalFilterMemberNotIn); *notIn.Value.Key != "confidentiality" {
+		t.Errorf("expected attributes in sorted order, got %s", *notIn.Value.Key)
+	}
+
+	// A caller entitled to everything adds no filter
+	ctx = WithDocumentAccess(context.Background(), &DocumentAccess{Denied: map[string][]string{"department": nil}})
+	if filter := exclusionFilter(ctx, nil); filter != nil {
+		t.Errorf("expected no filter for an unrestricted caller, got %T", filter)
+	}
+}
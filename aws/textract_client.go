@@ -0,0 +1,120 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/textract"
+	"github.com/aws/aws-sdk-go-v2/service/textract/types"
+)
+
+// textractPollInterval is the wait between checks of a running text detection job
+const textractPollInterval = 5 * time.Second
+
+type TextDetectionClient interface {
+	// DetectDocumentText reads the text of a scanned PDF in S3 and returns it page by page.
+	// It waits for the text detection to finish, so the context bounds how long it may take.
+	DetectDocumentText(ctx context.Context, s3Uri string) ([]string, error)
+}
+
+// TextractClient detects text with Amazon Textract. Multi-page PDFs are only accepted by
+// the asynchronous API, so it starts a job and polls it; Textract reads the PDF from S3
+// with the caller's credentials.
+type TextractClient struct {
+	client *textract.Client
+}
+
+func NewTextractClient(cfg aws.Config) *TextractClient {
+	return &TextractClient{
+		client: textract.NewFromConfig(cfg),
+	}
+}
+
+func (c *TextractClient) DetectDocumentText(ctx context.Context, s3Uri string) ([]string, error) {
+	bucket, key, ok := splitS3Uri(s3Uri)
+	if !ok {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid s3 URI: %s", s3Uri))
+	}
+
+	started, err := c.client.StartDocumentTextDetection(ctx, &textract.StartDocumentTextDetectionInput{
+		DocumentLocation: &types.DocumentLocation{
+			S3Object: &types.S3Object{
+				Bucket: aws.String(bucket),
+				Name:   aws.String(key),
+			},
+		},
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to start text detection", err)
+	}
+	jobId := aws.ToString(started.JobId)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("text detection job %s did not finish: %w", jobId, ctx.Err())
+		case <-time.After(textractPollInterval):
+		}
+
+		output, err := c.client.GetDocumentTextDetection(ctx, &textract.GetDocumentTextDetectionInput{
+			JobId: aws.String(jobId),
+		})
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to get text detection", err)
+		}
+
+		switch output.JobStatus {
+		case types.JobStatusInProgress:
+			continue
+		case types.JobStatusSucceeded, types.JobStatusPartialSuccess:
+			return c.collectPages(ctx, jobId, output)
+		default:
+			return nil, errors.NewAWSServiceError("text detection failed",
+				fmt.Errorf("job %s: %s %s", jobId, output.JobStatus, aws.ToString(output.StatusMessage)))
+		}
+	}
+}
+
+// collectPages joins the detected lines of every page of the job's results, which are
+// paginated from its first page of results
+func (c *TextractClient) collectPages(ctx context.Context, jobId string, output *textract.GetDocumentTextDetectionOutput) ([]string, error) {
+	lines := [][]string{} // By page
+	for {
+		for _, block := range output.Blocks {
+			if block.BlockType != types.BlockTypeLine || block.Text == nil {
+				continue
+			}
+			page := int(aws.ToInt32(block.Page))
+			if page < 1 {
+				page = 1
+			}
+			for len(lines) < page {
+				lines = append(lines, nil)
+			}
+			lines[page-1] = append(lines[page-1], *block.Text)
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+		var err error
+		output, err = c.client.GetDocumentTextDetection(ctx, &textract.GetDocumentTextDetectionInput{
+			JobId:     aws.String(jobId),
+			NextToken: output.NextToken,
+		})
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to get text detection", err)
+		}
+	}
+
+	pages := make([]string, len(lines))
+	for i, pageLines := range lines {
+		pages[i] = strings.Join(pageLines, "\n")
+	}
+	return pages, nil
+}
@@ -0,0 +1,134 @@
+package aws
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	rttypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// TokenStream receives the text of an answer while the model generates it, for clients that
+// show the answer as it is written. The streamed text is the raw generation: the answer
+// returned at the end, cleaned up, translated or with a disclaimer, replaces it.
+type TokenStream interface {
+	// Token is called with each piece of generated text, in order
+	Token(text string)
+	// Restart is called before every generation that streams, so the text of an attempt that
+	// failed and is retried is discarded
+	Restart()
+}
+
+type tokenStreamKey struct{}
+
+// WithTokenStream attaches a token stream to the context, so the model call that writes the
+// answer streams its text. A nil stream turns streaming off, e.g. for the answers of several
+// knowledge bases that are merged before they are returned.
+func WithTokenStream(ctx context.Context, stream TokenStream) context.Context {
+	return context.WithValue(ctx, tokenStreamKey{}, stream)
+}
+
+// TokenStreamFromContext returns the token stream of the request, nil when the answer is
+// not streamed
+func TokenStreamFromContext(ctx context.Context) TokenStream {
+	stream, _ := ctx.Value(tokenStreamKey{}).(TokenStream)
+	return stream
+}
+
+// converse calls Converse, or ConverseStream when the request streams tokens, collecting the
+// streamed text and usage into the output Converse would have returned
+func converse(ctx context.Context, client *bedrockruntime.Client, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+	stream := TokenStreamFromContext(ctx)
+	if stream == nil {
+		return client.Converse(ctx, input)
+	}
+
+	stream.Restart()
+	response, err := client.ConverseStream(ctx, &bedrockruntime.ConverseStreamInput{
+		ModelId:         input.ModelId,
+		Messages:        input.Messages,
+		System:          input.System,
+		InferenceConfig: input.InferenceConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+	events := response.GetStream()
+	defer events.Close()
+
+	var text strings.Builder
+	output := &bedrockruntime.ConverseOutput{}
+	for event := range events.Events() {
+		switch event := event.(type) {
+		case *rttypes.ConverseStreamOutputMemberContentBlockDelta:
+			if delta, ok := event.Value.Delta.(*rttypes.ContentBlockDeltaMemberText); ok && delta.Value != "" {
+				text.WriteString(delta.Value)
+				stream.Token(delta.Value)
+			}
+		case *rttypes.ConverseStreamOutputMemberMessageStop:
+			output.StopReason = event.Value.StopReason
+		case *rttypes.ConverseStreamOutputMemberMetadata:
+			output.Usage = event.Value.Usage
+		}
+	}
+	if err := events.Err(); err != nil {
+		return nil, err
+	}
+
+	output.Output = &rttypes.ConverseOutputMemberMessage{Value: rttypes.Message{
+		Role:    rttypes.ConversationRoleAssistant,
+		Content: []rttypes.ContentBlock{&rttypes.ContentBlockMemberText{Value: text.String()}},
+	}}
+	return output, nil
+}
+
+// retrieveAndGenerate calls RetrieveAndGenerate, or RetrieveAndGenerateStream when the
+// request streams tokens, collecting the streamed text and citations into the output
+// RetrieveAndGenerate would have returned
+func retrieveAndGenerate(ctx context.Context, client *bedrockagentruntime.Client, input *bedrockagentruntime.RetrieveAndGenerateInput) (*bedrockagentruntime.RetrieveAndGenerateOutput, error) {
+	stream := TokenStreamFromContext(ctx)
+	if stream == nil {
+		return client.RetrieveAndGenerate(ctx, input)
+	}
+
+	stream.Restart()
+	response, err := client.RetrieveAndGenerateStream(ctx, &bedrockagentruntime.RetrieveAndGenerateStreamInput{
+		Input:                            input.Input,
+		RetrieveAndGenerateConfiguration: input.RetrieveAndGenerateConfiguration,
+		SessionConfiguration:             input.SessionConfiguration,
+		SessionId:                        input.SessionId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	events := response.GetStream()
+	defer events.Close()
+
+	var text strings.Builder
+	output := &bedrockagentruntime.RetrieveAndGenerateOutput{SessionId: response.SessionId}
+	for event := range events.Events() {
+		switch event := event.(type) {
+		case *types.RetrieveAndGenerateStreamResponseOutputMemberOutput:
+			if token := aws.ToString(event.Value.Text); token != "" {
+				text.WriteString(token)
+				stream.Token(token)
+			}
+		case *types.RetrieveAndGenerateStreamResponseOutputMemberCitation:
+			output.Citations = append(output.Citations, types.Citation{
+				GeneratedResponsePart: event.Value.GeneratedResponsePart,
+				RetrievedReferences:   event.Value.RetrievedReferences,
+			})
+		case *types.RetrieveAndGenerateStreamResponseOutputMemberGuardrail:
+			output.GuardrailAction = event.Value.Action
+		}
+	}
+	if err := events.Err(); err != nil {
+		return nil, err
+	}
+
+	output.Output = &types.RetrieveAndGenerateOutput{Text: aws.String(text.String())}
+	return output, nil
+}
@@ -0,0 +1,109 @@
+package aws
+
+import (
+	"context"
+	"sync"
+
+	"teletubpax-api/utils"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	rttypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// TokenUsage is the tokens one request used on one model
+type TokenUsage struct {
+	ModelId        string `json:"modelId"`
+	Calls          int64  `json:"calls"`
+	InputTokens    int64  `json:"inputTokens"`
+	OutputTokens   int64  `json:"outputTokens"`
+	EstimatedCalls int64  `json:"estimatedCalls,omitempty"` // Calls whose tokens are estimated from text length, RetrieveAndGenerate reports no usage
+}
+
+// UsageRecorder collects the token usage of the Bedrock calls made while serving one request
+type UsageRecorder struct {
+	mu    sync.Mutex
+	usage []TokenUsage // In the order the models were first used
+}
+
+type usageKey struct{}
+
+// WithUsageRecorder attaches a new recorder to the context of a request
+func WithUsageRecorder(ctx context.Context) (context.Context, *UsageRecorder) {
+	recorder := &UsageRecorder{}
+	return context.WithValue(ctx, usageKey{}, recorder), recorder
+}
+
+// UsageRecorderFromContext returns the recorder of the request, nil without one
+func UsageRecorderFromContext(ctx context.Context) *UsageRecorder {
+	recorder, _ := ctx.Value(usageKey{}).(*UsageRecorder)
+	return recorder
+}
+
+// RecordUsage adds the tokens of a model call to the request's recorder. It does nothing
+// without a recorder.
+func RecordUsage(ctx context.Context, modelId string, inputTokens int64, outputTokens int64, estimated bool) {
+	recorder := UsageRecorderFromContext(ctx)
+	if recorder == nil {
+		return
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	i := 0
+	for i < len(recorder.usage) && recorder.usage[i].ModelId != modelId {
+		i++
+	}
+	if i == len(recorder.usage) {
+		recorder.usage = append(recorder.usage, TokenUsage{ModelId: modelId})
+	}
+	usage := &recorder.usage[i]
+	usage.Calls++
+	usage.InputTokens += inputTokens
+	usage.OutputTokens += outputTokens
+	if estimated {
+		usage.EstimatedCalls++
+	}
+}
+
+// List returns the recorded usage by model
+func (r *UsageRecorder) List() []TokenUsage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]TokenUsage(nil), r.usage...)
+}
+
+// recordConverseUsage records the tokens a Converse call reported
+func recordConverseUsage(ctx context.Context, modelId string, usage *rttypes.TokenUsage) {
+	if usage == nil {
+		return
+	}
+	var inputTokens, outputTokens int64
+	if usage.InputTokens != nil {
+		inputTokens = int64(*usage.InputTokens)
+	}
+	if usage.OutputTokens != nil {
+		outputTokens = int64(*usage.OutputTokens)
+	}
+	RecordUsage(ctx, modelId, inputTokens, outputTokens, false)
+}
+
+// recordRetrieveAndGenerateUsage records the estimated tokens of a RetrieveAndGenerate call,
+// which reports none: the prompt, the question and the cited chunks in, the answer out.
+// Chunks retrieved but not cited are not counted, so the input is on the low side.
+func recordRetrieveAndGenerateUsage(ctx context.Context, modelId string, prompt string, question string, answer string, citations []types.Citation) {
+	if UsageRecorderFromContext(ctx) == nil {
+		return
+	}
+	inputTokens := utils.EstimateTokens(prompt) + utils.EstimateTokens(question)
+	cited := map[string]bool{}
+	for _, citation := range citations {
+		for _, ref := range citation.RetrievedReferences {
+			if ref.Content == nil || ref.Content.Text == nil || cited[*ref.Content.Text] {
+				continue
+			}
+			cited[*ref.Content.Text] = true
+			inputTokens += utils.EstimateTokens(*ref.Content.Text)
+		}
+	}
+	RecordUsage(ctx, modelId, int64(inputTokens), int64(utils.EstimateTokens(answer)), true)
+}
@@ -0,0 +1,51 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+func TestRecordUsage(t *testing.T) {
+	// Without a recorder nothing is recorded
+	RecordUsage(context.Background(), "haiku", 10, 5, false)
+
+	ctx, recorder := WithUsageRecorder(context.Background())
+	RecordUsage(ctx, "haiku", 100, 20, false)
+	RecordUsage(ctx, "titan-embed", 8, 0, false)
+	RecordUsage(ctx, "haiku", 50, 10, true)
+
+	got := recorder.List()
+	want := []TokenUsage{
+		{ModelId: "haiku", Calls: 2, InputTokens: 150, OutputTokens: 30, EstimatedCalls: 1},
+		{ModelId: "titan-embed", Calls: 1, InputTokens: 8},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %+v, got %+v", want[i], got[i])
+		}
+	}
+}
+
+func TestRecordRetrieveAndGenerateUsage(t *testing.T) {
+	ctx, recorder := WithUsageRecorder(context.Background())
+	chunk := &types.RetrievalResultContent{Text: aws.String("ค่าธรรมเนียมรายปี 200 บาท")} // 25 characters
+	citations := []types.Citation{
+		{RetrievedReferences: []types.RetrievedReference{{Content: chunk}}},
+		// The same chunk cited twice is in the context once
+		{RetrievedReferences: []types.RetrievedReference{{Content: chunk}}},
+	}
+
+	recordRetrieveAndGenerateUsage(ctx, "haiku", "ตอบสั้นๆ", "ค่าธรรมเนียมเท่าไหร่", "200 บาท", citations)
+
+	got := recorder.List()
+	// Prompt 8/3+1, question 20/3+1 and chunk 25/3+1 characters in, answer 7/3+1 out
+	if len(got) != 1 || got[0].InputTokens != 3+7+9 || got[0].OutputTokens != 3 || got[0].EstimatedCalls != 1 {
+		t.Errorf("expected estimated tokens of the prompt, question and cited chunk, got %+v", got)
+	}
+}
@@ -0,0 +1,102 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"teletubpax-api/errors"
+	"teletubpax-api/policy"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	rttypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/translate"
+)
+
+type TranslationClient interface {
+	TranslateText(ctx context.Context, text string, sourceLanguage string, targetLanguage string) (string, error)
+}
+
+// AmazonTranslateClient translates with Amazon Translate
+type AmazonTranslateClient struct {
+	client *translate.Client
+}
+
+func NewAmazonTranslateClient(cfg aws.Config) *AmazonTranslateClient {
+	return &AmazonTranslateClient{
+		client: translate.NewFromConfig(cfg),
+	}
+}
+
+func (c *AmazonTranslateClient) TranslateText(ctx context.Context, text string, sourceLanguage string, targetLanguage string) (string, error) {
+	output, err := c.client.TranslateText(ctx, &translate.TranslateTextInput{
+		Text:               aws.String(text),
+		SourceLanguageCode: aws.String(sourceLanguage),
+		TargetLanguageCode: aws.String(targetLanguage),
+	})
+	if err != nil {
+		return "", errors.NewAWSServiceError("Amazon Translate request failed", err)
+	}
+	return aws.ToString(output.TranslatedText), nil
+}
+
+var languageNames = map[string]string{
+	"th": "Thai",
+	"en": "English",
+}
+
+// BedrockTranslationClient translates with the generative model, which keeps banking terms
+// and product names more consistent with the documents than general machine translation
+type BedrockTranslationClient struct {
+	runtimeClient     *bedrockruntime.Client
+	generativeModelId func() string
+}
+
+func NewBedrockTranslationClient(cfg aws.Config, generativeModelId func() string) *BedrockTranslationClient {
+	return &BedrockTranslationClient{
+		runtimeClient:     bedrockruntime.NewFromConfig(cfg),
+		generativeModelId: generativeModelId,
+	}
+}
+
+func (c *BedrockTranslationClient) TranslateText(ctx context.Context, text string, sourceLanguage string, targetLanguage string) (string, error) {
+	userMessage := fmt.Sprintf(`Translate the following text from %s to %s.
+Keep product names, numbers, dates, fees and URLs exactly as they are.
+Return only the translation, without any explanation.
+
+Text:
+%s`, languageNames[sourceLanguage], languageNames[targetLanguage], text)
+
+	generativeModelId := c.generativeModelId()
+
+	output, err := c.runtimeClient.Converse(ctx, &bedrockruntime.ConverseInput{
+		// Get the model identifier of the region (inference profile for Claude Haiku)
+		ModelId: aws.String(invocationModelId(generativeModelId)),
+		Messages: []rttypes.Message{
+			{
+				Role: rttypes.ConversationRoleUser,
+				Content: []rttypes.ContentBlock{
+					&rttypes.ContentBlockMemberText{
+						Value: userMessage,
+					},
+				},
+			},
+		},
+		InferenceConfig: &rttypes.InferenceConfiguration{
+			MaxTokens:   aws.Int32(int32(policy.FromContext(ctx, policy.Defaults(0)).MaxTokens)),
+			Temperature: aws.Float32(0.0),
+		},
+	})
+	if err != nil {
+		return "", errors.NewAWSServiceError("translation converse API failed", err)
+	}
+	recordConverseUsage(ctx, generativeModelId, output.Usage)
+
+	if msg, ok := output.Output.(*rttypes.ConverseOutputMemberMessage); ok && len(msg.Value.Content) > 0 {
+		if textBlock, ok := msg.Value.Content[0].(*rttypes.ContentBlockMemberText); ok {
+			return strings.TrimSpace(textBlock.Value), nil
+		}
+	}
+
+	return "", fmt.Errorf("no translation output received")
+}
@@ -0,0 +1,39 @@
+package aws
+
+import (
+	"context"
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi"
+)
+
+type WebSocketClient interface {
+	// PostToConnection sends data to a client connected to the API Gateway WebSocket API at
+	// endpoint, https://{domain}/{stage}
+	PostToConnection(ctx context.Context, endpoint string, connectionId string, data []byte) error
+}
+
+// ApiGatewayWebSocketClient sends messages to the clients of API Gateway WebSocket APIs
+type ApiGatewayWebSocketClient struct {
+	client *apigatewaymanagementapi.Client
+}
+
+func NewApiGatewayWebSocketClient(cfg aws.Config) *ApiGatewayWebSocketClient {
+	return &ApiGatewayWebSocketClient{
+		client: apigatewaymanagementapi.NewFromConfig(cfg),
+	}
+}
+
+func (c *ApiGatewayWebSocketClient) PostToConnection(ctx context.Context, endpoint string, connectionId string, data []byte) error {
+	_, err := c.client.PostToConnection(ctx, &apigatewaymanagementapi.PostToConnectionInput{
+		ConnectionId: aws.String(connectionId),
+		Data:         data,
+	}, func(o *apigatewaymanagementapi.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to send message to connection", err)
+	}
+	return nil
+}
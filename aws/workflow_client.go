@@ -0,0 +1,38 @@
+package aws
+
+import (
+	"context"
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+)
+
+type WorkflowClient interface {
+	// StartExecution starts the state machine with a JSON input. The name must be unique
+	// per state machine, so starting the same execution twice fails.
+	StartExecution(ctx context.Context, stateMachineArn string, name string, input string) error
+}
+
+// StepFunctionsWorkflowClient starts Step Functions executions
+type StepFunctionsWorkflowClient struct {
+	client *sfn.Client
+}
+
+func NewStepFunctionsWorkflowClient(cfg aws.Config) *StepFunctionsWorkflowClient {
+	return &StepFunctionsWorkflowClient{
+		client: sfn.NewFromConfig(cfg),
+	}
+}
+
+func (c *StepFunctionsWorkflowClient) StartExecution(ctx context.Context, stateMachineArn string, name string, input string) error {
+	_, err := c.client.StartExecution(ctx, &sfn.StartExecutionInput{
+		StateMachineArn: aws.String(stateMachineArn),
+		Name:            aws.String(name),
+		Input:           aws.String(input),
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to start workflow execution", err)
+	}
+	return nil
+}
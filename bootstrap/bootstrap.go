@@ -0,0 +1,301 @@
+package bootstrap
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"time"
+
+	"teletubpax-api/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// tableActiveTimeout bounds the wait for a new table to become usable
+	tableActiveTimeout = 2 * time.Minute
+
+	// logRetentionDays is set on created log groups, which otherwise keep logs forever
+	logRetentionDays = 30
+)
+
+const (
+	StatusCreated  = "created"  // The resource did not exist and was created
+	StatusExists   = "exists"   // The resource exists with the expected schema
+	StatusUpdated  = "updated"  // The resource existed, missing settings such as TTL were applied
+	StatusMismatch = "mismatch" // The resource exists with a different schema and was left as is
+	StatusFailed   = "failed"   // The resource could not be checked or created
+)
+
+// TableSpec is the schema a store expects of its DynamoDB table
+type TableSpec struct {
+	Name         string
+	PartitionKey string // String partition key
+	SortKey      string // String sort key, empty for the stores keyed by the partition key alone
+	TTLAttribute string // Empty when items do not expire
+}
+
+// Result reports what bootstrap did with one resource
+type Result struct {
+	Kind   string // "dynamodb-table" or "log-group"
+	Name   string
+	Status string
+	Detail string
+}
+
+func (r Result) String() string {
+	if r.Detail == "" {
+		return fmt.Sprintf("%s %s: %s", r.Kind, r.Name, r.Status)
+	}
+	return fmt.Sprintf("%s %s: %s (%s)", r.Kind, r.Name, r.Status, r.Detail)
+}
+
+type dynamoDBAPI interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+}
+
+type logsAPI interface {
+	CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error)
+	PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error)
+}
+
+// Bootstrapper creates the AWS resources the service needs when they are missing, so a new
+// environment can be stood up by running the binary once. Existing resources are never
+// modified beyond enabling a missing TTL.
+type Bootstrapper struct {
+	dynamodb     dynamoDBAPI
+	logs         logsAPI
+	waitForTable func(ctx context.Context, tableName string) error
+}
+
+func NewBootstrapper(cfg aws.Config) *Bootstrapper {
+	client := dynamodb.NewFromConfig(cfg)
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	return &Bootstrapper{
+		dynamodb: client,
+		logs:     cloudwatchlogs.NewFromConfig(cfg),
+		waitForTable: func(ctx context.Context, tableName string) error {
+			return waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, tableActiveTimeout)
+		},
+	}
+}
+
+// Tables returns the table of every store enabled in the configuration, with the key
+// schemas of the stores in the storage package
+func Tables(cfg *config.Config) []TableSpec {
+	specs := []TableSpec{
+		{Name: cfg.DocumentSummaryTable, PartitionKey: "link"},
+		{Name: cfg.JobCheckpointTable, PartitionKey: "jobName"},
+		{Name: cfg.NotFoundTable, PartitionKey: "id", TTLAttribute: "expiresAt"},
+		{Name: cfg.FeedbackTable, PartitionKey: "id", TTLAttribute: "expiresAt"},
+		{Name: cfg.HistoryTable, PartitionKey: "userId", SortKey: "turnId", TTLAttribute: "expiresAt"},
+		{Name: cfg.UsageTable, PartitionKey: "id", TTLAttribute: "expiresAt"},
+		{Name: cfg.ExperimentTable, PartitionKey: "id", TTLAttribute: "expiresAt"},
+		{Name: cfg.ApiKeyTable, PartitionKey: "id"},
+		{Name: cfg.ApiKeyQuotaTable, PartitionKey: "key", TTLAttribute: "expiresAt"},
+		{Name: cfg.IdempotencyTable, PartitionKey: "key", TTLAttribute: "expiresAt"},
+		{Name: cfg.JobsTable, PartitionKey: "jobId", TTLAttribute: "expiresAt"},
+		{Name: cfg.ChatConnectionsTable, PartitionKey: "connectionId", TTLAttribute: "expiresAt"},
+		{Name: cfg.SummaryJobsTable, PartitionKey: "id", TTLAttribute: "expiresAt"},
+		{Name: cfg.NormalizationTable, PartitionKey: "term"},
+		{Name: cfg.SessionLimitTable, PartitionKey: "key", TTLAttribute: "expiresAt"},
+		{Name: cfg.DeletedDocumentsTable, PartitionKey: "sourceUri", TTLAttribute: "expiresAt"},
+		{Name: cfg.WebhookTable, PartitionKey: "id"},
+		{Name: cfg.DigestSubscriptionTable, PartitionKey: "id"},
+		{Name: cfg.VersionComparisonTable, PartitionKey: "key", TTLAttribute: "expiresAt"},
+	}
+
+	var enabled []TableSpec
+	for _, spec := range specs {
+		if spec.Name != "" {
+			enabled = append(enabled, spec)
+		}
+	}
+	return enabled
+}
+
+// Run ensures every table and log group exists. It continues past failures so one run
+// reports every problem, and returns an error when any resource failed or has a schema the
+// stores cannot use.
+func (b *Bootstrapper) Run(ctx context.Context, tables []TableSpec, logGroups []string) ([]Result, error) {
+	var results []Result
+	var failed int
+
+	for _, spec := range tables {
+		result, err := b.ensureTable(ctx, spec)
+		if err != nil {
+			result = Result{Kind: "dynamodb-table", Name: spec.Name, Status: StatusFailed, Detail: err.Error()}
+		}
+		if result.Status == StatusFailed || result.Status == StatusMismatch {
+			failed++
+		}
+		results = append(results, result)
+	}
+	for _, name := range logGroups {
+		result, err := b.ensureLogGroup(ctx, name)
+		if err != nil {
+			failed++
+			result = Result{Kind: "log-group", Name: name, Status: StatusFailed, Detail: err.Error()}
+		}
+		results = append(results, result)
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d resources could not be bootstrapped", failed, len(results))
+	}
+	return results, nil
+}
+
+func (b *Bootstrapper) ensureTable(ctx context.Context, spec TableSpec) (Result, error) {
+	result := Result{Kind: "dynamodb-table", Name: spec.Name, Status: StatusExists}
+
+	output, err := b.dynamodb.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(spec.Name)})
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case stdErrors.As(err, &notFound):
+		if err := b.createTable(ctx, spec); err != nil {
+			return result, err
+		}
+		result.Status = StatusCreated
+	case err != nil:
+		return result, fmt.Errorf("failed to describe table: %w", err)
+	default:
+		if detail := keySchemaMismatch(output.Table, spec); detail != "" {
+			result.Status = StatusMismatch
+			result.Detail = detail
+			return result, nil
+		}
+	}
+
+	if spec.TTLAttribute == "" {
+		return result, nil
+	}
+	updated, err := b.ensureTimeToLive(ctx, spec)
+	if err != nil {
+		return result, err
+	}
+	if updated && result.Status == StatusExists {
+		result.Status = StatusUpdated
+		result.Detail = "enabled TTL on " + spec.TTLAttribute
+	}
+	return result, nil
+}
+
+func (b *Bootstrapper) createTable(ctx context.Context, spec TableSpec) error {
+	attributes := []types.AttributeDefinition{
+		{AttributeName: aws.String(spec.PartitionKey), AttributeType: types.ScalarAttributeTypeS},
+	}
+	keySchema := []types.KeySchemaElement{
+		{AttributeName: aws.String(spec.PartitionKey), KeyType: types.KeyTypeHash},
+	}
+	if spec.SortKey != "" {
+		attributes = append(attributes, types.AttributeDefinition{AttributeName: aws.String(spec.SortKey), AttributeType: types.ScalarAttributeTypeS})
+		keySchema = append(keySchema, types.KeySchemaElement{AttributeName: aws.String(spec.SortKey), KeyType: types.KeyTypeRange})
+	}
+
+	_, err := b.dynamodb.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String(spec.Name),
+		AttributeDefinitions: attributes,
+		KeySchema:            keySchema,
+		BillingMode:          types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		var inUse *types.ResourceInUseException
+		if !stdErrors.As(err, &inUse) { // Created concurrently by another instance
+			return fmt.Errorf("failed to create table: %w", err)
+		}
+	}
+	if err := b.waitForTable(ctx, spec.Name); err != nil {
+		return fmt.Errorf("table did not become active: %w", err)
+	}
+	return nil
+}
+
+// ensureTimeToLive enables TTL on the expected attribute, reporting whether it changed
+// anything. TTL already enabled on another attribute is left alone.
+func (b *Bootstrapper) ensureTimeToLive(ctx context.Context, spec TableSpec) (bool, error) {
+	output, err := b.dynamodb.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(spec.Name)})
+	if err != nil {
+		return false, fmt.Errorf("failed to describe TTL: %w", err)
+	}
+	if description := output.TimeToLiveDescription; description != nil {
+		switch description.TimeToLiveStatus {
+		case types.TimeToLiveStatusEnabled, types.TimeToLiveStatusEnabling:
+			if aws.ToString(description.AttributeName) != spec.TTLAttribute {
+				return false, fmt.Errorf("TTL is enabled on %s instead of %s", aws.ToString(description.AttributeName), spec.TTLAttribute)
+			}
+			return false, nil
+		}
+	}
+
+	_, err = b.dynamodb.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(spec.Name),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(spec.TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to enable TTL: %w", err)
+	}
+	return true, nil
+}
+
+// keySchemaMismatch describes how a table's key differs from the spec, empty when it matches
+func keySchemaMismatch(table *types.TableDescription, spec TableSpec) string {
+	if table == nil {
+		return ""
+	}
+	var hashKey, rangeKey string
+	for _, element := range table.KeySchema {
+		if element.KeyType == types.KeyTypeHash {
+			hashKey = aws.ToString(element.AttributeName)
+		} else {
+			rangeKey = aws.ToString(element.AttributeName)
+		}
+	}
+	if hashKey != spec.PartitionKey {
+		return fmt.Sprintf("partition key is %s, expected %s", hashKey, spec.PartitionKey)
+	}
+	if rangeKey != spec.SortKey {
+		if spec.SortKey == "" {
+			return fmt.Sprintf("unexpected sort key %s", rangeKey)
+		}
+		if rangeKey == "" {
+			return fmt.Sprintf("missing sort key %s", spec.SortKey)
+		}
+		return fmt.Sprintf("sort key is %s, expected %s", rangeKey, spec.SortKey)
+	}
+	return ""
+}
+
+func (b *Bootstrapper) ensureLogGroup(ctx context.Context, name string) (Result, error) {
+	result := Result{Kind: "log-group", Name: name, Status: StatusCreated}
+
+	_, err := b.logs.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{LogGroupName: aws.String(name)})
+	if err != nil {
+		var exists *cwtypes.ResourceAlreadyExistsException
+		if !stdErrors.As(err, &exists) {
+			return result, fmt.Errorf("failed to create log group: %w", err)
+		}
+		// Keep the retention of existing groups, it may have been chosen on purpose
+		result.Status = StatusExists
+		return result, nil
+	}
+
+	_, err = b.logs.PutRetentionPolicy(ctx, &cloudwatchlogs.PutRetentionPolicyInput{
+		LogGroupName:    aws.String(name),
+		RetentionInDays: aws.Int32(logRetentionDays),
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to set log retention: %w", err)
+	}
+	return result, nil
+}
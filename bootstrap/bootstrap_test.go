@@ -0,0 +1,189 @@
+package bootstrap
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"teletubpax-api/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeDynamoDB keeps tables by name with their partition key, sort key and TTL attribute
+type fakeDynamoDB struct {
+	keys     map[string]string
+	sortKeys map[string]string
+	ttl      map[string]string
+	created  []string
+}
+
+func (f *fakeDynamoDB) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	key, ok := f.keys[aws.ToString(params.TableName)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("not found")}
+	}
+	keySchema := []types.KeySchemaElement{{AttributeName: aws.String(key), KeyType: types.KeyTypeHash}}
+	if sortKey, ok := f.sortKeys[aws.ToString(params.TableName)]; ok {
+		keySchema = append(keySchema, types.KeySchemaElement{AttributeName: aws.String(sortKey), KeyType: types.KeyTypeRange})
+	}
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{KeySchema: keySchema}}, nil
+}
+
+func (f *fakeDynamoDB) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	name := aws.ToString(params.TableName)
+	f.keys[name] = aws.ToString(params.KeySchema[0].AttributeName)
+	if len(params.KeySchema) > 1 {
+		if f.sortKeys == nil {
+			f.sortKeys = map[string]string{}
+		}
+		f.sortKeys[name] = aws.ToString(params.KeySchema[1].AttributeName)
+	}
+	f.created = append(f.created, name)
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (f *fakeDynamoDB) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	description := &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}
+	if attribute, ok := f.ttl[aws.ToString(params.TableName)]; ok {
+		description = &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusEnabled, AttributeName: aws.String(attribute)}
+	}
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: description}, nil
+}
+
+func (f *fakeDynamoDB) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	f.ttl[aws.ToString(params.TableName)] = aws.ToString(params.TimeToLiveSpecification.AttributeName)
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+type fakeLogs struct {
+	groups map[string]int32 // Retention days by group
+}
+
+func (f *fakeLogs) CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	name := aws.ToString(params.LogGroupName)
+	if _, ok := f.groups[name]; ok {
+		return nil, &cwtypes.ResourceAlreadyExistsException{Message: aws.String("exists")}
+	}
+	f.groups[name] = 0
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (f *fakeLogs) PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	f.groups[aws.ToString(params.LogGroupName)] = aws.ToInt32(params.RetentionInDays)
+	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
+}
+
+func newTestBootstrapper(db *fakeDynamoDB, logs *fakeLogs) *Bootstrapper {
+	return &Bootstrapper{
+		dynamodb:     db,
+		logs:         logs,
+		waitForTable: func(ctx context.Context, tableName string) error { return nil },
+	}
+}
+
+func TestTables_OnlyConfiguredStores(t *testing.T) {
+	tables := Tables(&config.Config{NotFoundTable: "not-found", WebhookTable: "webhooks"})
+	if len(tables) != 2 || tables[0].Name != "not-found" || tables[0].TTLAttribute != "expiresAt" || tables[1].PartitionKey != "id" {
+		t.Errorf("unexpected tables %+v", tables)
+	}
+}
+
+func TestRun_CreatesMissingResources(t *testing.T) {
+	db := &fakeDynamoDB{
+		keys: map[string]string{"summaries": "link", "counters": "key"},
+		ttl:  map[string]string{},
+	}
+	logs := &fakeLogs{groups: map[string]int32{"/existing": 7}}
+	tables := []TableSpec{
+		{Name: "summaries", PartitionKey: "link"},
+		{Name: "counters", PartitionKey: "key", TTLAttribute: "expiresAt"},
+		{Name: "not-found", PartitionKey: "id", TTLAttribute: "expiresAt"},
+	}
+
+	results, err := newTestBootstrapper(db, logs).Run(context.Background(), tables, []string{"/teletubpax-api/local", "/existing"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{StatusExists, StatusUpdated, StatusCreated, StatusCreated, StatusExists}
+	for i, result := range results {
+		if result.Status != expected[i] {
+			t.Errorf("%s: expected %s, got %s", result.Name, expected[i], result)
+		}
+	}
+	if len(db.created) != 1 || db.created[0] != "not-found" || db.ttl["not-found"] != "expiresAt" || db.ttl["counters"] != "expiresAt" {
+		t.Errorf("expected the missing table with TTL, got created %v ttl %v", db.created, db.ttl)
+	}
+	if logs.groups["/teletubpax-api/local"] != logRetentionDays || logs.groups["/existing"] != 7 {
+		t.Errorf("expected retention only on the new log group, got %v", logs.groups)
+	}
+
+	// A second run changes nothing
+	db.created = nil
+	results, _ = newTestBootstrapper(db, logs).Run(context.Background(), tables, nil)
+	for _, result := range results {
+		if result.Status != StatusExists {
+			t.Errorf("expected %s to exist, got %s", result.Name, result)
+		}
+	}
+}
+
+func TestRun_ReportsSchemaProblems(t *testing.T) {
+	db := &fakeDynamoDB{
+		keys: map[string]string{"webhooks": "webhookId", "counters": "key"},
+		ttl:  map[string]string{"counters": "ttl"},
+	}
+	tables := []TableSpec{
+		{Name: "webhooks", PartitionKey: "id"},
+		{Name: "counters", PartitionKey: "key", TTLAttribute: "expiresAt"},
+	}
+
+	results, err := newTestBootstrapper(db, &fakeLogs{groups: map[string]int32{}}).Run(context.Background(), tables, nil)
+	if err == nil || !strings.Contains(err.Error(), "2 of 2") {
+		t.Fatalf("expected the key mismatch and TTL conflict to fail the run, got %v", err)
+	}
+	if results[0].Status != StatusMismatch || !strings.Contains(results[0].Detail, "webhookId") {
+		t.Errorf("expected a key mismatch, got %s", results[0])
+	}
+	if results[1].Status != StatusFailed || db.ttl["counters"] != "ttl" {
+		t.Errorf("expected the existing TTL to be kept, got %s", results[1])
+	}
+}
+
+func TestRun_SortKeys(t *testing.T) {
+	db := &fakeDynamoDB{
+		keys:     map[string]string{"feedback": "id", "answers": "id"},
+		sortKeys: map[string]string{"answers": "answeredAt"},
+		ttl:      map[string]string{},
+	}
+	tables := []TableSpec{
+		{Name: "history", PartitionKey: "userId", SortKey: "turnId"},
+		{Name: "feedback", PartitionKey: "id", SortKey: "answeredAt"},
+		{Name: "answers", PartitionKey: "id"},
+	}
+
+	results, err := newTestBootstrapper(db, &fakeLogs{groups: map[string]int32{}}).Run(context.Background(), tables, nil)
+	if err == nil {
+		t.Fatal("expected the sort key mismatches to fail the run")
+	}
+	if results[0].Status != StatusCreated || db.sortKeys["history"] != "turnId" {
+		t.Errorf("expected the table created with its sort key, got %s", results[0])
+	}
+	if results[1].Status != StatusMismatch || !strings.Contains(results[1].Detail, "missing sort key") {
+		t.Errorf("expected a missing sort key, got %s", results[1])
+	}
+	if results[2].Status != StatusMismatch || !strings.Contains(results[2].Detail, "unexpected sort key answeredAt") {
+		t.Errorf("expected an unexpected sort key, got %s", results[2])
+	}
+
+	// The created table matches on the next run
+	results, _ = newTestBootstrapper(db, &fakeLogs{groups: map[string]int32{}}).Run(context.Background(), tables[:1], nil)
+	if results[0].Status != StatusExists {
+		t.Errorf("expected the table to exist, got %s", results[0])
+	}
+}
@@ -12,7 +12,7 @@ echo "Building Go Lambda function..."
 mkdir -p lambda-build
 
 # Build Go binary for Lambda (Linux AMD64)
-GOOS=linux GOARCH=amd64 CGO_ENABLED=0 go build -tags lambda -o lambda-build/bootstrap lambda_main.go
+GOOS=linux GOARCH=amd64 CGO_ENABLED=0 go build -tags lambda -o lambda-build/bootstrap lambda_main.go lambda_worker.go lambda_workflow.go lambda_documents.go lambda_chat.go
 
 echo "Go binary built successfully"
 
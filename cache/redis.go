@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	stdErrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces the keys in a Redis shared with other services
+const redisKeyPrefix = "teletubpax:"
+
+// RedisStore shares values between instances through Redis, e.g. ElastiCache. Redis
+// expires the keys, so the store needs no cleanup.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to the Redis at addr (host:port). ElastiCache clusters with
+// in-transit encryption need useTLS; password is the AUTH token, empty for none.
+func NewRedisStore(addr string, password string, useTLS bool) *RedisStore {
+	options := &redis.Options{
+		Addr:         addr,
+		Password:     password,
+		DialTimeout:  2 * time.Second,
+		ReadTimeout:  time.Second,
+		WriteTimeout: time.Second,
+	}
+	if useTLS {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return &RedisStore{client: redis.NewClient(options)}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if stdErrors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache key: %w", err)
+	}
+	return value, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.client.Set(ctx, redisKeyPrefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to write cache key: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) SetIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	stored, err := s.client.SetNX(ctx, redisKeyPrefix+key, value, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to write cache key: %w", err)
+	}
+	return stored, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, redisKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to delete cache key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps values by key for a limited time. Deployments point the Lambda function and
+// the container at the same Redis so both see the same cached answers, comparisons and
+// idempotency keys; without one every instance keeps its own in memory.
+type Store interface {
+	// Get returns the value, nil when there is none or it expired
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetIfAbsent stores the value only when the key has none, reporting whether it did
+	SetIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryStore keeps values in the instance's memory, evicting the least recently used
+// value beyond maxEntries
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // Most recently used first
+}
+
+// NewMemoryStore keeps up to maxEntries values, 0 for no limit
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element := s.live(key)
+	if element == nil {
+		return nil, nil
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*memoryEntry).value, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value, ttl)
+	return nil
+}
+
+func (s *MemoryStore) SetIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.live(key) != nil {
+		return false, nil
+	}
+	s.set(key, value, ttl)
+	return true, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.order.Remove(element)
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// Len returns the number of values, including expired ones not yet evicted
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// live returns the unexpired entry of the key, removing an expired one
+func (s *MemoryStore) live(key string) *list.Element {
+	element, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(element.Value.(*memoryEntry).expiresAt) {
+		s.order.Remove(element)
+		delete(s.entries, key)
+		return nil
+	}
+	return element
+}
+
+func (s *MemoryStore) set(key string, value []byte, ttl time.Duration) {
+	entry := &memoryEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)}
+	if element, ok := s.entries[key]; ok {
+		element.Value = entry
+		s.order.MoveToFront(element)
+		return
+	}
+	s.entries[key] = s.order.PushFront(entry)
+
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_GetSetDelete(t *testing.T) {
+	store := NewMemoryStore(10)
+	ctx := context.Background()
+
+	if value, err := store.Get(ctx, "a"); err != nil || value != nil {
+		t.Fatalf("expected a miss on an empty store, got %q %v", value, err)
+	}
+
+	store.Set(ctx, "a", []byte("1"), time.Minute)
+	if value, _ := store.Get(ctx, "a"); string(value) != "1" {
+		t.Errorf("expected the stored value, got %q", value)
+	}
+
+	store.Delete(ctx, "a")
+	if value, _ := store.Get(ctx, "a"); value != nil {
+		t.Errorf("expected the deleted value to be gone, got %q", value)
+	}
+}
+
+func TestMemoryStore_SetIfAbsent(t *testing.T) {
+	store := NewMemoryStore(10)
+	ctx := context.Background()
+
+	if stored, _ := store.SetIfAbsent(ctx, "a", []byte("1"), time.Minute); !stored {
+		t.Fatal("expected the first value to be stored")
+	}
+	if stored, _ := store.SetIfAbsent(ctx, "a", []byte("2"), time.Minute); stored {
+		t.Error("expected the second value to be refused")
+	}
+	if value, _ := store.Get(ctx, "a"); string(value) != "1" {
+		t.Errorf("expected the first value to be kept, got %q", value)
+	}
+
+	// An expired value no longer holds the key
+	store.Set(ctx, "b", []byte("1"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if stored, _ := store.SetIfAbsent(ctx, "b", []byte("2"), time.Minute); !stored {
+		t.Error("expected the expired value to be replaced")
+	}
+}
+
+func TestMemoryStore_Expires(t *testing.T) {
+	store := NewMemoryStore(10)
+	ctx := context.Background()
+
+	store.Set(ctx, "a", []byte("1"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if value, _ := store.Get(ctx, "a"); value != nil {
+		t.Errorf("expected the expired value to be gone, got %q", value)
+	}
+	if store.Len() != 0 {
+		t.Errorf("expected the expired value to be removed, %d left", store.Len())
+	}
+}
+
+func TestMemoryStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewMemoryStore(2)
+	ctx := context.Background()
+
+	store.Set(ctx, "a", []byte("a"), time.Minute)
+	store.Set(ctx, "b", []byte("b"), time.Minute)
+	store.Get(ctx, "a")
+	store.Set(ctx, "c", []byte("c"), time.Minute)
+
+	if value, _ := store.Get(ctx, "b"); value != nil {
+		t.Error("expected the least recently used value to be evicted")
+	}
+	if value, _ := store.Get(ctx, "a"); value == nil {
+		t.Error("expected the recently read value to be kept")
+	}
+	if store.Len() != 2 {
+		t.Errorf("expected 2 values, got %d", store.Len())
+	}
+}
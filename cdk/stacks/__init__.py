@@ -1 +1 @@
-# CDK Stacks
+# CDK Stacks
@@ -1,136 +1,1089 @@
-from aws_cdk import (
-    Stack,
-    Duration,
-    CfnOutput,
-    aws_lambda as lambda_,
-    aws_apigatewayv2 as apigw,
-    aws_apigatewayv2_integrations as integrations,
-    aws_iam as iam,
-    aws_logs as logs,
-)
-from constructs import Construct
-
-
-class BedrockApiStack(Stack):
-    def __init__(self, scope: Construct, construct_id: str, **kwargs):
-        super().__init__(scope, construct_id, **kwargs)
-
-        # Get configuration from context or use defaults
-        aws_region = self.node.try_get_context("aws_region") or "us-east-1"
-        embedding_model = self.node.try_get_context("embedding_model") or "amazon.titan-embed-text-v2"
-        # Multiple Knowledge Base IDs - hardcoded in Go code (config/bedrock_config.go)
-        knowledge_base_ids = ["ZHYAWGPBRS","I2XCL5FZAQ","CC46VWUAVL"]
-        max_question_length = self.node.try_get_context("max_question_length") or "1000"
-        retry_attempts = self.node.try_get_context("retry_attempts") or "3"
-
-        # IAM role for Lambda with Bedrock permissions
-        lambda_role = iam.Role(
-            self,
-            "BedrockApiLambdaRole",
-            assumed_by=iam.ServicePrincipal("lambda.amazonaws.com"),
-            managed_policies=[
-                iam.ManagedPolicy.from_aws_managed_policy_name(
-                    "service-role/AWSLambdaBasicExecutionRole"
-                )
-            ],
-        )
-
-        # Add Bedrock permissions
-        lambda_role.add_to_policy(
-            iam.PolicyStatement(
-                effect=iam.Effect.ALLOW,
-                actions=[
-                    "bedrock:InvokeModel",
-                    "bedrock:InvokeModelWithResponseStream",
-                ],
-                resources=[
-                    f"arn:aws:bedrock:{aws_region}::foundation-model/{embedding_model}",
-                    f"arn:aws:bedrock:{aws_region}::foundation-model/*",
-                ],
-            )
-        )
-
-        # Add Bedrock Agent Runtime permissions for Knowledge Bases
-        kb_resources = [
-            f"arn:aws:bedrock:{aws_region}:{self.account}:knowledge-base/{kb_id}"
-            for kb_id in knowledge_base_ids
-        ]
-        lambda_role.add_to_policy(
-            iam.PolicyStatement(
-                effect=iam.Effect.ALLOW,
-                actions=[
-                    "bedrock:Retrieve",
-                    "bedrock:RetrieveAndGenerate",
-                    "bedrock:InvokeModel",
-                ],
-                resources=kb_resources + [
-                    f"arn:aws:bedrock:*::foundation-model/*",
-                    "arn:aws:bedrock:*:*:inference-profile/*",
-                ],
-            )
-        )
-
-        # Lambda function for Go API using custom runtime
-        api_lambda = lambda_.Function(
-            self,
-            "BedrockApiFunction",
-            runtime=lambda_.Runtime.PROVIDED_AL2023,
-            handler="bootstrap",
-            code=lambda_.Code.from_asset("../lambda-build"),
-            role=lambda_role,
-            timeout=Duration.seconds(30),
-            memory_size=512,
-            architecture=lambda_.Architecture.X86_64,
-            environment={
-                "BEDROCK_REGION": aws_region,
-                "BEDROCK_EMBEDDING_MODEL": embedding_model,
-                # Note: Knowledge Base IDs are hardcoded in Go code (config/bedrock_config.go)
-                # No need to pass BEDROCK_KB_ID as environment variable
-                "MAX_QUESTION_LENGTH": max_question_length,
-                "RETRY_ATTEMPTS": retry_attempts,
-                "AWS_LWA_INVOKE_MODE": "response_stream",
-            },
-            log_retention=logs.RetentionDays.ONE_WEEK,
-            description="Bedrock Question Search API Lambda Function",
-        )
-
-        # HTTP API Gateway
-        http_api = apigw.HttpApi(
-            self,
-            "BedrockHttpApi",
-            api_name="bedrock-question-search-api",
-            description="Bedrock Question Search API",
-            cors_preflight=apigw.CorsPreflightOptions(
-                allow_origins=["*"],
-                allow_methods=[apigw.CorsHttpMethod.GET, apigw.CorsHttpMethod.POST],
-                allow_headers=["Content-Type", "Authorization"],
-            ),
-        )
-
-        # Lambda integration
-        lambda_integration = integrations.HttpLambdaIntegration(
-            "LambdaIntegration",
-            api_lambda,
-        )
-
-        # Add catch-all route to forward all requests to Lambda
-        http_api.add_routes(
-            path="/{proxy+}",
-            methods=[apigw.HttpMethod.ANY],
-            integration=lambda_integration,
-        )
-
-        # Outputs
-        CfnOutput(
-            self,
-            "ApiUrl",
-            value=http_api.url or "",
-            description="HTTP API Gateway URL",
-        )
-
-        CfnOutput(
-            self,
-            "LambdaFunctionName",
-            value=api_lambda.function_name,
-            description="Lambda function name",
-        )
+from aws_cdk import (
+    Stack,
+    ArnFormat,
+    Duration,
+    CfnOutput,
+    RemovalPolicy,
+    aws_lambda as lambda_,
+    aws_apigatewayv2 as apigw,
+    aws_apigatewayv2_integrations as integrations,
+    aws_iam as iam,
+    aws_logs as logs,
+    aws_dynamodb as dynamodb,
+    aws_s3 as s3,
+    aws_secretsmanager as secretsmanager,
+    aws_events as events,
+    aws_events_targets as targets,
+    aws_sqs as sqs,
+    aws_sns as sns,
+    aws_sns_subscriptions as subscriptions,
+    aws_lambda_event_sources as lambda_event_sources,
+    aws_stepfunctions as sfn,
+    aws_stepfunctions_tasks as sfn_tasks,
+)
+from constructs import Construct
+
+
+class BedrockApiStack(Stack):
+    def __init__(self, scope: Construct, construct_id: str, **kwargs):
+        super().__init__(scope, construct_id, **kwargs)
+
+        # Get configuration from context or use defaults
+        aws_region = self.node.try_get_context("aws_region") or "us-east-1"
+        embedding_model = self.node.try_get_context("embedding_model") or "amazon.titan-embed-text-v2"
+        # Titan v2 vector size ("0" for the model default) and unit-length vectors
+        embedding_dimensions = self.node.try_get_context("embedding_dimensions") or "0"
+        embedding_normalize = self.node.try_get_context("embedding_normalize") or "true"
+        # Multiple Knowledge Base IDs, the Lambda may only query these
+        knowledge_base_ids = ["ZHYAWGPBRS","I2XCL5FZAQ","CC46VWUAVL"]
+        # Optional JSON list with weights and labels of the knowledge bases above (KNOWLEDGE_BASES)
+        knowledge_bases = self.node.try_get_context("knowledge_bases") or ""
+        max_question_length = self.node.try_get_context("max_question_length") or "1000"
+        max_request_body_bytes = self.node.try_get_context("max_request_body_bytes") or "1048576"
+        retry_attempts = self.node.try_get_context("retry_attempts") or "3"
+        admin_api_token = self.node.try_get_context("admin_api_token") or ""
+        safe_mode = self.node.try_get_context("safe_mode") or "false"
+        # Answer cache, in memory per Lambda instance unless a Redis reachable from the function is set.
+        # The Redis also holds comparisons and idempotency keys when their tables are not deployed;
+        # the answer_cache_redis_* names predate that and still work.
+        answer_cache_ttl_seconds = self.node.try_get_context("answer_cache_ttl_seconds") or "0"
+        # Lifetime of cached embeddings of repeated texts, "0" disables the cache
+        embedding_cache_ttl_seconds = self.node.try_get_context("embedding_cache_ttl_seconds") or "86400"
+        cache_redis_addr = (self.node.try_get_context("cache_redis_addr")
+                            or self.node.try_get_context("answer_cache_redis_addr") or "")
+        cache_redis_tls = (self.node.try_get_context("cache_redis_tls")
+                           or self.node.try_get_context("answer_cache_redis_tls") or "false")
+        cache_redis_auth_secret = (self.node.try_get_context("cache_redis_auth_secret")
+                                   or self.node.try_get_context("answer_cache_redis_auth_secret") or "")
+        # Caller identity and document access control from bearer tokens signed by e.g. a Cognito user pool
+        access_control_rules = self.node.try_get_context("access_control_rules") or ""
+        access_control_role_claim = self.node.try_get_context("access_control_role_claim") or "roles"
+        auth_jwks_url = self.node.try_get_context("auth_jwks_url") or ""
+        auth_issuer = self.node.try_get_context("auth_issuer") or ""
+        auth_audience = self.node.try_get_context("auth_audience") or ""
+        auth_user_claim = self.node.try_get_context("auth_user_claim") or "sub"
+        auth_required = self.node.try_get_context("auth_required") or "false"
+        # API keys are managed by the admin API, requests without one are rejected when required
+        api_key_required = self.node.try_get_context("api_key_required") or "false"
+        # Comma-separated IAM role/user ARNs of services calling with SigV4 signed headers, a trailing * matches any suffix
+        iam_auth_allowed_principals = self.node.try_get_context("iam_auth_allowed_principals") or ""
+        # Tracing exports to the ADOT collector layer on localhost, add it with the layer ARN of the region
+        tracing_exporter = self.node.try_get_context("tracing_exporter") or ""
+        tracing_endpoint = self.node.try_get_context("tracing_endpoint") or ""
+        tracing_sample_percent = self.node.try_get_context("tracing_sample_percent") or "100"
+        # Error rate and throttling alerts, published to the AlertTopic output; subscribe the pager to it
+        alert_email = self.node.try_get_context("alert_email") or ""
+        alert_error_rate_percent = self.node.try_get_context("alert_error_rate_percent") or "10"
+        alert_throttle_rate_percent = self.node.try_get_context("alert_throttle_rate_percent") or "25"
+        # Fault injection is refused in prod, deploy a test stack with -c environment=staging
+        environment = self.node.try_get_context("environment") or "prod"
+        fault_injection_enabled = self.node.try_get_context("fault_injection_enabled") or "false"
+        fault_injection = self.node.try_get_context("fault_injection") or ""
+        # Redact personal data found by Amazon Comprehend in logged English questions
+        pii_detection_enabled = self.node.try_get_context("pii_detection_enabled") or "false"
+        maintenance_mode = self.node.try_get_context("maintenance_mode") or "false"
+        feature_flags = self.node.try_get_context("feature_flags") or ""
+        # Optional SSM parameter name (e.g. /teletubpax/feature-flags) for hot-reloadable flags
+        feature_flags_parameter = self.node.try_get_context("feature_flags_parameter") or ""
+        # Optional SSM path prefix (e.g. /teletubpax/prod) with parameters replacing env vars
+        config_ssm_prefix = self.node.try_get_context("config_ssm_prefix") or ""
+        # Optional Secrets Manager secret name holding the response signing HMAC key
+        response_signing_secret = self.node.try_get_context("response_signing_secret") or ""
+        endpoint_policies = self.node.try_get_context("endpoint_policies") or ""
+        endpoint_policies_parameter = self.node.try_get_context("endpoint_policies_parameter") or ""
+        documents_bucket = self.node.try_get_context("documents_bucket") or ""
+        # Optional CDN domain serving documents_bucket, accepted in summary-document requests
+        document_cdn_domain = self.node.try_get_context("document_cdn_domain") or ""
+        deleted_document_retention_days = self.node.try_get_context("deleted_document_retention_days") or "30"
+        # Optional bucket receiving a copy of documents deleted with DELETE /documents
+        document_archive_bucket = self.node.try_get_context("document_archive_bucket") or ""
+        conversation_history_retention_days = self.node.try_get_context("conversation_history_retention_days") or "90"
+        audit_retention_days = self.node.try_get_context("audit_retention_days") or "365"
+        digest_sender_email = self.node.try_get_context("digest_sender_email") or ""
+        document_content_source = self.node.try_get_context("document_content_source") or "knowledge-base"
+        # Pre-signed document links for a private documents_bucket, signed with the function's role
+        document_link_mode = self.node.try_get_context("document_link_mode") or "public"
+        document_link_expiry_seconds = self.node.try_get_context("document_link_expiry_seconds") or "3600"
+        # Textract text of scanned PDFs uploaded to documents_bucket, and the data source synced
+        # after it is written as "<knowledge base ID>/<data source ID>"
+        scanned_pdf_ocr_enabled = self.node.try_get_context("scanned_pdf_ocr_enabled") or "false"
+        scanned_pdf_min_text_characters = self.node.try_get_context("scanned_pdf_min_text_characters") or "100"
+        scanned_pdf_sync_data_source = self.node.try_get_context("scanned_pdf_sync_data_source") or ""
+        answer_backend = self.node.try_get_context("answer_backend") or "knowledge-base"
+        answer_backend_tenants = self.node.try_get_context("answer_backend_tenants") or ""
+        # "HYBRID" adds keyword matching to the vector search of retrieval, "SEMANTIC" uses
+        # the vector search only, empty leaves the choice to Bedrock
+        retrieval_search_type = self.node.try_get_context("retrieval_search_type") or ""
+        # Chunks retrieved per knowledge base for an answer, and the score below which they are
+        # dropped, from 0 to 1
+        retrieval_results = self.node.try_get_context("retrieval_results") or "5"
+        retrieval_min_score = self.node.try_get_context("retrieval_min_score") or "0"
+        # Let question-search requests set the temperature, generation limit and an instruction
+        # suffix, for testing tools; keep it off where the API is public
+        generation_overrides_enabled = self.node.try_get_context("generation_overrides_enabled") or "false"
+        # Knowledge base answer temperature, topP and generation limit, empty for Bedrock's
+        # defaults, and its prompt template, empty for the built-in one
+        kb_generation_temperature = self.node.try_get_context("kb_generation_temperature") or ""
+        kb_generation_top_p = self.node.try_get_context("kb_generation_top_p") or ""
+        kb_generation_max_tokens = self.node.try_get_context("kb_generation_max_tokens") or ""
+        kb_prompt_template = self.node.try_get_context("kb_prompt_template") or ""
+        # WebSocket chat with streamed answers, on its own WebSocket API
+        chat_enabled = self.node.try_get_context("chat_enabled") or "false"
+        # Abbreviations rewritten in questions with the embedded synonym file, or with the file
+        # at synonyms_key in synonyms_bucket when set
+        synonyms_enabled = self.node.try_get_context("synonyms_enabled") or "false"
+        synonyms_bucket = self.node.try_get_context("synonyms_bucket") or ""
+        synonyms_key = self.node.try_get_context("synonyms_key") or "synonyms.txt"
+        # Existing bucket holding the golden dataset of /admin/eval, where its reports are
+        # written too; empty disables evaluation
+        eval_bucket = self.node.try_get_context("eval_bucket") or ""
+        eval_dataset_key = self.node.try_get_context("eval_dataset_key") or "eval/golden.json"
+        eval_report_prefix = self.node.try_get_context("eval_report_prefix") or "eval/reports"
+        # JSON prompt/model experiment splitting question-search sessions between variants;
+        # empty runs no experiment
+        experiment = self.node.try_get_context("experiment") or ""
+        bedrock_agent_id = self.node.try_get_context("bedrock_agent_id") or ""
+        bedrock_agent_alias_id = self.node.try_get_context("bedrock_agent_alias_id") or ""
+        answer_disclaimer = self.node.try_get_context("answer_disclaimer") or ""
+        disclaimer_tenants = self.node.try_get_context("disclaimer_tenants") or ""
+        disclaimer_placement = self.node.try_get_context("disclaimer_placement") or "append"
+        # EventBridge schedule expression of the warm-up invocation, e.g. "cron(45 0 ? * MON-FRI *)"
+        # for 07:45 Bangkok time on weekdays; empty for none
+        warm_up_schedule = self.node.try_get_context("warm_up_schedule") or ""
+        # Bulk summaries: documents per job, per workflow task, and chunks summarized in parallel
+        summary_job_max_documents = self.node.try_get_context("summary_job_max_documents") or "200"
+        summary_job_chunk_size = self.node.try_get_context("summary_job_chunk_size") or "10"
+        summary_job_concurrency = int(self.node.try_get_context("summary_job_concurrency") or "4")
+
+        # IAM role for Lambda with Bedrock permissions
+        lambda_role = iam.Role(
+            self,
+            "BedrockApiLambdaRole",
+            assumed_by=iam.ServicePrincipal("lambda.amazonaws.com"),
+            managed_policies=[
+                iam.ManagedPolicy.from_aws_managed_policy_name(
+                    "service-role/AWSLambdaBasicExecutionRole"
+                )
+            ],
+        )
+
+        # Add Bedrock permissions
+        lambda_role.add_to_policy(
+            iam.PolicyStatement(
+                effect=iam.Effect.ALLOW,
+                actions=[
+                    "bedrock:InvokeModel",
+                    "bedrock:InvokeModelWithResponseStream",
+                ],
+                resources=[
+                    f"arn:aws:bedrock:{aws_region}::foundation-model/{embedding_model}",
+                    f"arn:aws:bedrock:{aws_region}::foundation-model/*",
+                ],
+            )
+        )
+
+        # Add Bedrock Agent Runtime permissions for Knowledge Bases
+        kb_resources = [
+            f"arn:aws:bedrock:{aws_region}:{self.account}:knowledge-base/{kb_id}"
+            for kb_id in knowledge_base_ids
+        ]
+        lambda_role.add_to_policy(
+            iam.PolicyStatement(
+                effect=iam.Effect.ALLOW,
+                actions=[
+                    "bedrock:Retrieve",
+                    "bedrock:RetrieveAndGenerate",
+                    "bedrock:InvokeModel",
+                ],
+                resources=kb_resources + [
+                    f"arn:aws:bedrock:*::foundation-model/*",
+                    "arn:aws:bedrock:*:*:inference-profile/*",
+                ],
+            )
+        )
+
+        # Knowledge base data source sync via /api/teletubpax/v1/admin/knowledge-bases/*, and
+        # the document listing of /api/teletubpax/v1/documents/export
+        lambda_role.add_to_policy(
+            iam.PolicyStatement(
+                effect=iam.Effect.ALLOW,
+                actions=[
+                    "bedrock:ListDataSources",
+                    "bedrock:StartIngestionJob",
+                    "bedrock:GetIngestionJob",
+                    "bedrock:ListIngestionJobs",
+                    "bedrock:ListKnowledgeBaseDocuments",
+                ],
+                resources=kb_resources,
+            )
+        )
+
+        # Startup check of the embedding size against the knowledge bases' vector indexes
+        lambda_role.add_to_policy(
+            iam.PolicyStatement(
+                effect=iam.Effect.ALLOW,
+                actions=["bedrock:GetKnowledgeBase"],
+                resources=kb_resources,
+            )
+        )
+
+        # Startup check of the configured models against the region's models and profiles
+        lambda_role.add_to_policy(
+            iam.PolicyStatement(
+                effect=iam.Effect.ALLOW,
+                actions=[
+                    "bedrock:ListFoundationModels",
+                    "bedrock:ListInferenceProfiles",
+                ],
+                resources=["*"],
+            )
+        )
+
+        # Bedrock Agent for the agent answer backend (optional)
+        if bedrock_agent_id:
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["bedrock:InvokeAgent"],
+                    resources=[
+                        f"arn:aws:bedrock:{aws_region}:{self.account}:agent-alias/{bedrock_agent_id}/*",
+                    ],
+                )
+            )
+
+        # DynamoDB tables for precomputed document summaries, batch job checkpoints,
+        # unanswered question analytics, the question normalization dictionary, session
+        # limit counters, soft-deleted documents, document version webhooks, document
+        # change digest subscriptions and cached version comparisons
+        document_summary_table = dynamodb.Table(
+            self,
+            "DocumentSummaryTable",
+            partition_key=dynamodb.Attribute(name="link", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+        )
+        job_checkpoint_table = dynamodb.Table(
+            self,
+            "JobCheckpointTable",
+            partition_key=dynamodb.Attribute(name="jobName", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+        )
+        not_found_table = dynamodb.Table(
+            self,
+            "NotFoundTable",
+            partition_key=dynamodb.Attribute(name="id", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        feedback_table = dynamodb.Table(
+            self,
+            "FeedbackTable",
+            partition_key=dynamodb.Attribute(name="id", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        # Questions and answers of signed-in users, one partition per user for PDPA erasure
+        history_table = dynamodb.Table(
+            self,
+            "ConversationHistoryTable",
+            partition_key=dynamodb.Attribute(name="userId", type=dynamodb.AttributeType.STRING),
+            sort_key=dynamodb.Attribute(name="turnId", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        normalization_table = dynamodb.Table(
+            self,
+            "NormalizationTable",
+            partition_key=dynamodb.Attribute(name="term", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+        )
+        session_counter_table = dynamodb.Table(
+            self,
+            "SessionCounterTable",
+            partition_key=dynamodb.Attribute(name="key", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        deleted_documents_table = dynamodb.Table(
+            self,
+            "DeletedDocumentsTable",
+            partition_key=dynamodb.Attribute(name="sourceUri", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        webhook_table = dynamodb.Table(
+            self,
+            "WebhookTable",
+            partition_key=dynamodb.Attribute(name="id", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+        )
+        digest_subscription_table = dynamodb.Table(
+            self,
+            "DigestSubscriptionTable",
+            partition_key=dynamodb.Attribute(name="id", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+        )
+        version_comparison_table = dynamodb.Table(
+            self,
+            "VersionComparisonTable",
+            partition_key=dynamodb.Attribute(name="key", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        usage_table = dynamodb.Table(
+            self,
+            "UsageTable",
+            partition_key=dynamodb.Attribute(name="id", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        experiment_table = dynamodb.Table(
+            self,
+            "ExperimentTable",
+            partition_key=dynamodb.Attribute(name="id", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        api_key_table = dynamodb.Table(
+            self,
+            "ApiKeyTable",
+            partition_key=dynamodb.Attribute(name="id", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+        )
+        api_key_quota_table = dynamodb.Table(
+            self,
+            "ApiKeyQuotaTable",
+            partition_key=dynamodb.Attribute(name="key", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        # Long-running operations polled at jobs/{id}: queued questions, bulk summaries and
+        # ingestion syncs. The construct keeps the ID it had when it held questions alone, so
+        # the table is not replaced.
+        jobs_table = dynamodb.Table(
+            self,
+            "QuestionJobsTable",
+            partition_key=dynamodb.Attribute(name="jobId", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        # Open WebSocket chat connections, each message being a separate invocation
+        chat_connections_table = dynamodb.Table(
+            self,
+            "ChatConnectionsTable",
+            partition_key=dynamodb.Attribute(name="connectionId", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        # Bulk summary jobs and the chunks their workflow tasks work on
+        summary_jobs_table = dynamodb.Table(
+            self,
+            "SummaryJobsTable",
+            partition_key=dynamodb.Attribute(name="id", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        # Responses replayed to retries with an Idempotency-Key, shared by the Lambda instances
+        idempotency_table = dynamodb.Table(
+            self,
+            "IdempotencyTable",
+            partition_key=dynamodb.Attribute(name="key", type=dynamodb.AttributeType.STRING),
+            billing_mode=dynamodb.BillingMode.PAY_PER_REQUEST,
+            time_to_live_attribute="expiresAt",
+        )
+        document_summary_table.grant_read_write_data(lambda_role)
+        job_checkpoint_table.grant_read_write_data(lambda_role)
+        not_found_table.grant_read_write_data(lambda_role)
+        feedback_table.grant_read_write_data(lambda_role)
+        history_table.grant_read_write_data(lambda_role)
+        normalization_table.grant_read_write_data(lambda_role)
+        session_counter_table.grant_read_write_data(lambda_role)
+        deleted_documents_table.grant_read_write_data(lambda_role)
+        webhook_table.grant_read_write_data(lambda_role)
+        digest_subscription_table.grant_read_write_data(lambda_role)
+        version_comparison_table.grant_read_write_data(lambda_role)
+        usage_table.grant_read_write_data(lambda_role)
+        experiment_table.grant_read_write_data(lambda_role)
+        api_key_table.grant_read_write_data(lambda_role)
+        api_key_quota_table.grant_read_write_data(lambda_role)
+        idempotency_table.grant_read_write_data(lambda_role)
+        jobs_table.grant_read_write_data(lambda_role)
+        chat_connections_table.grant_read_write_data(lambda_role)
+        summary_jobs_table.grant_read_write_data(lambda_role)
+
+        # Questions answered in the background by the worker function below. A message is
+        # delivered at most 3 times (maxQuestionJobAttempts in lambda_worker.go), the
+        # visibility timeout covers the worker's 5 minute timeout with room for retries.
+        question_job_dead_letter_queue = sqs.Queue(
+            self,
+            "QuestionJobDeadLetterQueue",
+            retention_period=Duration.days(14),
+            encryption=sqs.QueueEncryption.SQS_MANAGED,
+        )
+        question_job_queue = sqs.Queue(
+            self,
+            "QuestionJobQueue",
+            visibility_timeout=Duration.minutes(30),
+            encryption=sqs.QueueEncryption.SQS_MANAGED,
+            dead_letter_queue=sqs.DeadLetterQueue(max_receive_count=3, queue=question_job_dead_letter_queue),
+        )
+        question_job_queue.grant_send_messages(lambda_role)
+
+        # The bulk summary state machine is named, so the functions it invokes get its ARN
+        # without depending on it
+        summary_workflow_name = f"{self.stack_name}-summary-workflow"
+        summary_workflow_arn = self.format_arn(
+            service="states",
+            resource="stateMachine",
+            resource_name=summary_workflow_name,
+            arn_format=ArnFormat.COLON_RESOURCE_NAME,
+        )
+        lambda_role.add_to_policy(
+            iam.PolicyStatement(
+                effect=iam.Effect.ALLOW,
+                actions=["states:StartExecution"],
+                resources=[summary_workflow_arn],
+            )
+        )
+
+        # Sustained errors and throttling, published by every Lambda instance at most once per
+        # ALERT_COOLDOWN_SECONDS (sns:Publish is granted with the digest above)
+        alert_topic = sns.Topic(self, "AlertTopic", display_name="Teletubpax API alerts")
+        if alert_email:
+            alert_topic.add_subscription(subscriptions.EmailSubscription(alert_email))
+
+        # Document lifecycle events (ingested, new version, comparison completed) for the
+        # intranet portal and notification bot, which add their own rules on this bus
+        document_event_bus = events.EventBus(self, "DocumentEventBus")
+        document_event_bus.grant_put_events_to(lambda_role)
+
+        # Daily analytics export for Athena, kept beyond the DynamoDB TTLs and the stack
+        analytics_export_bucket = s3.Bucket(
+            self,
+            "AnalyticsExportBucket",
+            encryption=s3.BucketEncryption.S3_MANAGED,
+            block_public_access=s3.BlockPublicAccess.BLOCK_ALL,
+            enforce_ssl=True,
+            removal_policy=RemovalPolicy.RETAIN,
+        )
+        analytics_export_bucket.grant_put(lambda_role, "analytics/*")
+
+        # Write-once audit trail of every question; the API locks each record in compliance
+        # mode for AUDIT_RETENTION_DAYS, so it outlives the stack and cannot be deleted early
+        audit_bucket = s3.Bucket(
+            self,
+            "AuditBucket",
+            encryption=s3.BucketEncryption.S3_MANAGED,
+            block_public_access=s3.BlockPublicAccess.BLOCK_ALL,
+            enforce_ssl=True,
+            object_lock_enabled=True,
+            removal_policy=RemovalPolicy.RETAIN,
+        )
+        audit_bucket.grant_put(lambda_role, "audit/*")
+        audit_bucket.grant_read(lambda_role, "audit/*")
+
+        # Weekly document change digest, by email through SES or to team SNS topics
+        lambda_role.add_to_policy(
+            iam.PolicyStatement(
+                effect=iam.Effect.ALLOW,
+                actions=["ses:SendEmail", "sns:Publish"],
+                resources=["*"],
+            )
+        )
+
+        # Hard-delete of purged documents from the knowledge base bucket, full document reads
+        # for DOCUMENT_CONTENT_SOURCE=s3 and the reads of pre-signed document links (optional)
+        if documents_bucket:
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["s3:DeleteObject", "s3:GetObject"],
+                    resources=[f"arn:aws:s3:::{documents_bucket}/*"],
+                )
+            )
+
+        # Synonym file read on startup (SYNONYMS_BUCKET)
+        if synonyms_enabled == "true" and synonyms_bucket:
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["s3:GetObject"],
+                    resources=[f"arn:aws:s3:::{synonyms_bucket}/{synonyms_key}"],
+                )
+            )
+
+        # Golden dataset, evaluation reports and baseline (EVAL_BUCKET)
+        if eval_bucket:
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["s3:GetObject"],
+                    resources=[f"arn:aws:s3:::{eval_bucket}/{eval_dataset_key}"],
+                )
+            )
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["s3:GetObject", "s3:PutObject"],
+                    resources=[f"arn:aws:s3:::{eval_bucket}/{eval_report_prefix}/*"],
+                )
+            )
+            # Without it a missing baseline or report reads as access denied instead of absent
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["s3:ListBucket"],
+                    resources=[f"arn:aws:s3:::{eval_bucket}"],
+                    conditions={"StringLike": {"s3:prefix": [f"{eval_report_prefix}/*"]}},
+                )
+            )
+
+        # Copies of documents deleted for good (DOCUMENT_ARCHIVE_BUCKET)
+        if documents_bucket and document_archive_bucket:
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["s3:PutObject"],
+                    resources=[f"arn:aws:s3:::{document_archive_bucket}/*"],
+                )
+            )
+
+        # Text of scanned PDFs: Textract reads the PDF with the function's role, and the text is
+        # written next to it (SCANNED_PDF_OCR_ENABLED)
+        if documents_bucket and scanned_pdf_ocr_enabled == "true":
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["textract:StartDocumentTextDetection", "textract:GetDocumentTextDetection"],
+                    resources=["*"],
+                )
+            )
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["s3:PutObject"],
+                    resources=[f"arn:aws:s3:::{documents_bucket}/*"],
+                )
+            )
+
+        # Amazon Comprehend for PII redaction of logged questions (PII_DETECTION_ENABLED)
+        if pii_detection_enabled == "true":
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["comprehend:DetectPiiEntities"],
+                    resources=["*"],
+                )
+            )
+
+        # Amazon Translate for answer and snippet translation
+        lambda_role.add_to_policy(
+            iam.PolicyStatement(
+                effect=iam.Effect.ALLOW,
+                actions=["translate:TranslateText"],
+                resources=["*"],
+            )
+        )
+
+        # Endpoint policies parameter (optional)
+        if endpoint_policies_parameter:
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["ssm:GetParameter"],
+                    resources=[
+                        f"arn:aws:ssm:{aws_region}:{self.account}:parameter/{endpoint_policies_parameter.lstrip('/')}",
+                    ],
+                )
+            )
+
+        # Response signing key (optional)
+        if response_signing_secret:
+            secretsmanager.Secret.from_secret_name_v2(
+                self, "ResponseSigningSecret", response_signing_secret
+            ).grant_read(lambda_role)
+
+        # Cache Redis AUTH token (optional)
+        if cache_redis_auth_secret:
+            secretsmanager.Secret.from_secret_name_v2(
+                self, "AnswerCacheRedisAuthSecret", cache_redis_auth_secret
+            ).grant_read(lambda_role)
+
+        # Configuration parameters (optional)
+        if config_ssm_prefix:
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["ssm:GetParametersByPath"],
+                    resources=[
+                        f"arn:aws:ssm:{aws_region}:{self.account}:parameter/{config_ssm_prefix.strip('/')}",
+                        f"arn:aws:ssm:{aws_region}:{self.account}:parameter/{config_ssm_prefix.strip('/')}/*",
+                    ],
+                )
+            )
+            # Prompts saved and restored through the admin prompt API
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["ssm:PutParameter", "ssm:GetParameterHistory"],
+                    resources=[
+                        f"arn:aws:ssm:{aws_region}:{self.account}:parameter/{config_ssm_prefix.strip('/')}/*_INSTRUCTIONS",
+                    ],
+                )
+            )
+
+        # Feature flags parameter (optional)
+        if feature_flags_parameter:
+            lambda_role.add_to_policy(
+                iam.PolicyStatement(
+                    effect=iam.Effect.ALLOW,
+                    actions=["ssm:GetParameter"],
+                    resources=[
+                        f"arn:aws:ssm:{aws_region}:{self.account}:parameter/{feature_flags_parameter.lstrip('/')}",
+                    ],
+                )
+            )
+
+        # Shared by the API function, the worker of asynchronous questions and the bulk
+        # summary workflow function
+        api_environment = {
+            "BEDROCK_REGION": aws_region,
+            "BEDROCK_EMBEDDING_MODEL": embedding_model,
+            "EMBEDDING_DIMENSIONS": embedding_dimensions,
+            "EMBEDDING_NORMALIZE": embedding_normalize,
+            "BEDROCK_KB_ID": ",".join(knowledge_base_ids),
+            "KNOWLEDGE_BASES": knowledge_bases,
+            "CONFIG_SSM_PREFIX": config_ssm_prefix,
+            "MAX_QUESTION_LENGTH": max_question_length,
+            "MAX_REQUEST_BODY_BYTES": max_request_body_bytes,
+            "RETRY_ATTEMPTS": retry_attempts,
+            "ADMIN_API_TOKEN": admin_api_token,
+            "DOCUMENT_SUMMARY_TABLE": document_summary_table.table_name,
+            "JOB_CHECKPOINT_TABLE": job_checkpoint_table.table_name,
+            "NOT_FOUND_TABLE": not_found_table.table_name,
+            "FEEDBACK_TABLE": feedback_table.table_name,
+            "CONVERSATION_HISTORY_TABLE": history_table.table_name,
+            "CONVERSATION_HISTORY_RETENTION_DAYS": conversation_history_retention_days,
+            "NORMALIZATION_TABLE": normalization_table.table_name,
+            "SYNONYMS_ENABLED": synonyms_enabled,
+            "SYNONYMS_BUCKET": synonyms_bucket,
+            "SYNONYMS_KEY": synonyms_key,
+            "EVAL_BUCKET": eval_bucket,
+            "EVAL_DATASET_KEY": eval_dataset_key,
+            "EVAL_REPORT_PREFIX": eval_report_prefix,
+            "SESSION_LIMIT_TABLE": session_counter_table.table_name,
+            "DELETED_DOCUMENTS_TABLE": deleted_documents_table.table_name,
+            "DELETED_DOCUMENT_RETENTION_DAYS": deleted_document_retention_days,
+            "DOCUMENT_ARCHIVE_BUCKET": document_archive_bucket if documents_bucket else "",
+            "DOCUMENTS_BUCKET": documents_bucket,
+            "DOCUMENT_CDN_DOMAIN": document_cdn_domain,
+            "WEBHOOK_TABLE": webhook_table.table_name,
+            "DIGEST_SUBSCRIPTION_TABLE": digest_subscription_table.table_name,
+            "VERSION_COMPARISON_TABLE": version_comparison_table.table_name,
+            "USAGE_TABLE": usage_table.table_name,
+            "EXPERIMENT": experiment,
+            "EXPERIMENT_TABLE": experiment_table.table_name,
+            "API_KEY_TABLE": api_key_table.table_name,
+            "API_KEY_QUOTA_TABLE": api_key_quota_table.table_name,
+            "IDEMPOTENCY_TABLE": idempotency_table.table_name,
+            "QUESTION_JOB_QUEUE_URL": question_job_queue.queue_url,
+            "JOBS_TABLE": jobs_table.table_name,
+            "CHAT_ENABLED": chat_enabled,
+            "CHAT_CONNECTIONS_TABLE": chat_connections_table.table_name,
+            "SUMMARY_WORKFLOW_ARN": summary_workflow_arn,
+            "SUMMARY_JOBS_TABLE": summary_jobs_table.table_name,
+            "SUMMARY_JOB_MAX_DOCUMENTS": summary_job_max_documents,
+            "SUMMARY_JOB_CHUNK_SIZE": summary_job_chunk_size,
+            "ANALYTICS_EXPORT_BUCKET": analytics_export_bucket.bucket_name,
+            "AUDIT_BUCKET": audit_bucket.bucket_name,
+            "AUDIT_RETENTION_DAYS": audit_retention_days,
+            "DIGEST_SENDER_EMAIL": digest_sender_email,
+            "DOCUMENT_CONTENT_SOURCE": document_content_source,
+            "DOCUMENT_LINK_MODE": document_link_mode,
+            "DOCUMENT_LINK_EXPIRY_SECONDS": document_link_expiry_seconds,
+            "SCANNED_PDF_OCR_ENABLED": scanned_pdf_ocr_enabled if documents_bucket else "false",
+            "SCANNED_PDF_MIN_TEXT_CHARACTERS": scanned_pdf_min_text_characters,
+            "SCANNED_PDF_SYNC_DATA_SOURCE": scanned_pdf_sync_data_source,
+            "ANSWER_BACKEND": answer_backend,
+            "ANSWER_BACKEND_TENANTS": answer_backend_tenants,
+            "RETRIEVAL_SEARCH_TYPE": retrieval_search_type,
+            "RETRIEVAL_RESULTS": retrieval_results,
+            "RETRIEVAL_MIN_SCORE": retrieval_min_score,
+            "GENERATION_OVERRIDES_ENABLED": generation_overrides_enabled,
+            "KB_GENERATION_TEMPERATURE": kb_generation_temperature,
+            "KB_GENERATION_TOP_P": kb_generation_top_p,
+            "KB_GENERATION_MAX_TOKENS": kb_generation_max_tokens,
+            "KB_PROMPT_TEMPLATE": kb_prompt_template,
+            "BEDROCK_AGENT_ID": bedrock_agent_id,
+            "BEDROCK_AGENT_ALIAS_ID": bedrock_agent_alias_id,
+            "ANSWER_DISCLAIMER": answer_disclaimer,
+            "DISCLAIMER_TENANTS": disclaimer_tenants,
+            "DISCLAIMER_PLACEMENT": disclaimer_placement,
+            "ANSWER_CACHE_TTL_SECONDS": answer_cache_ttl_seconds,
+            "EMBEDDING_CACHE_TTL_SECONDS": embedding_cache_ttl_seconds,
+            "CACHE_REDIS_ADDR": cache_redis_addr,
+            "CACHE_REDIS_TLS": cache_redis_tls,
+            "CACHE_REDIS_AUTH_SECRET_ID": cache_redis_auth_secret,
+            "ACCESS_CONTROL_RULES": access_control_rules,
+            "ACCESS_CONTROL_ROLE_CLAIM": access_control_role_claim,
+            "AUTH_JWKS_URL": auth_jwks_url,
+            "AUTH_ISSUER": auth_issuer,
+            "AUTH_AUDIENCE": auth_audience,
+            "AUTH_USER_CLAIM": auth_user_claim,
+            "AUTH_REQUIRED": auth_required,
+            "API_KEY_REQUIRED": api_key_required,
+            "IAM_AUTH_ALLOWED_PRINCIPALS": iam_auth_allowed_principals,
+            "TRACING_EXPORTER": tracing_exporter,
+            "TRACING_ENDPOINT": tracing_endpoint,
+            "TRACING_SAMPLE_PERCENT": tracing_sample_percent,
+            "ALERT_TOPIC_ARN": alert_topic.topic_arn,
+            "ALERT_ERROR_RATE_PERCENT": alert_error_rate_percent,
+            "ALERT_THROTTLE_RATE_PERCENT": alert_throttle_rate_percent,
+            "EVENT_BUS_NAME": document_event_bus.event_bus_name,
+            "SAFE_MODE": safe_mode,
+            "ENVIRONMENT": environment,
+            "FAULT_INJECTION_ENABLED": fault_injection_enabled,
+            "PII_DETECTION_ENABLED": pii_detection_enabled,
+            "FAULT_INJECTION": fault_injection,
+            "MAINTENANCE_MODE": maintenance_mode,
+            "FEATURE_FLAGS": feature_flags,
+            "FEATURE_FLAGS_SSM_PARAMETER": feature_flags_parameter,
+            "RESPONSE_SIGNING_SECRET_ID": response_signing_secret,
+            "ENDPOINT_POLICIES": endpoint_policies,
+            "ENDPOINT_POLICIES_SSM_PARAMETER": endpoint_policies_parameter,
+        }
+
+        # Lambda function for Go API using custom runtime
+        api_lambda = lambda_.Function(
+            self,
+            "BedrockApiFunction",
+            runtime=lambda_.Runtime.PROVIDED_AL2023,
+            handler="bootstrap",
+            code=lambda_.Code.from_asset("../lambda-build"),
+            role=lambda_role,
+            timeout=Duration.seconds(30),
+            memory_size=512,
+            architecture=lambda_.Architecture.X86_64,
+            tracing=lambda_.Tracing.ACTIVE if tracing_exporter == "xray" else lambda_.Tracing.DISABLED,
+            environment={**api_environment, "AWS_LWA_INVOKE_MODE": "response_stream"},
+            log_retention=logs.RetentionDays.ONE_WEEK,
+            description="Bedrock Question Search API Lambda Function",
+        )
+
+        # Daily purge of soft-deleted documents past their retention period. The rule invokes
+        # the function with an HTTP API event for the admin purge endpoint.
+        if admin_api_token:
+            purge_path = "/api/teletubpax/v1/admin/documents/purge"
+            events.Rule(
+                self,
+                "DeletedDocumentPurgeSchedule",
+                schedule=events.Schedule.rate(Duration.days(1)),
+                targets=[
+                    targets.LambdaFunction(
+                        api_lambda,
+                        event=events.RuleTargetInput.from_object({
+                            "version": "2.0",
+                            "routeKey": "$default",
+                            "rawPath": purge_path,
+                            "headers": {"x-admin-token": admin_api_token},
+                            "requestContext": {
+                                "http": {"method": "POST", "path": purge_path},
+                            },
+                            "isBase64Encoded": False,
+                        }),
+                    )
+                ],
+            )
+
+            # Daily analytics export of the previous UTC day, 07:30 Bangkok time
+            analytics_export_path = "/api/teletubpax/v1/admin/analytics/export"
+            events.Rule(
+                self,
+                "AnalyticsExportSchedule",
+                schedule=events.Schedule.cron(minute="30", hour="0"),
+                targets=[
+                    targets.LambdaFunction(
+                        api_lambda,
+                        event=events.RuleTargetInput.from_object({
+                            "version": "2.0",
+                            "routeKey": "$default",
+                            "rawPath": analytics_export_path,
+                            "headers": {"x-admin-token": admin_api_token},
+                            "requestContext": {
+                                "http": {"method": "POST", "path": analytics_export_path},
+                            },
+                            "isBase64Encoded": False,
+                        }),
+                    )
+                ],
+            )
+
+            # Weekly document change digest, Monday 08:00 Bangkok time
+            digest_path = "/api/teletubpax/v1/admin/digest/send"
+            events.Rule(
+                self,
+                "DocumentChangeDigestSchedule",
+                schedule=events.Schedule.cron(minute="0", hour="1", week_day="MON"),
+                targets=[
+                    targets.LambdaFunction(
+                        api_lambda,
+                        event=events.RuleTargetInput.from_object({
+                            "version": "2.0",
+                            "routeKey": "$default",
+                            "rawPath": digest_path,
+                            "headers": {"x-admin-token": admin_api_token},
+                            "requestContext": {
+                                "http": {"method": "POST", "path": digest_path},
+                            },
+                            "isBase64Encoded": False,
+                        }),
+                    )
+                ],
+            )
+
+        # Worker of asynchronous questions: the same binary, invoked by the queue with a
+        # timeout beyond API Gateway's 30 seconds
+        question_job_worker = lambda_.Function(
+            self,
+            "QuestionJobWorkerFunction",
+            runtime=lambda_.Runtime.PROVIDED_AL2023,
+            handler="bootstrap",
+            code=lambda_.Code.from_asset("../lambda-build"),
+            role=lambda_role,
+            timeout=Duration.minutes(5),
+            memory_size=512,
+            architecture=lambda_.Architecture.X86_64,
+            tracing=lambda_.Tracing.ACTIVE if tracing_exporter == "xray" else lambda_.Tracing.DISABLED,
+            environment=api_environment,
+            log_retention=logs.RetentionDays.ONE_WEEK,
+            description="Bedrock Question Search asynchronous question worker",
+        )
+        question_job_worker.add_event_source(
+            lambda_event_sources.SqsEventSource(
+                question_job_queue,
+                batch_size=1,
+                report_batch_item_failures=True,
+            )
+        )
+
+        # Bulk summaries: fetch and chunk the documents, summarize and compare the chunks in
+        # parallel, then aggregate. Every step is a task of the workflow function; a step
+        # that still fails after its retries marks the job failed.
+        summary_workflow_function = lambda_.Function(
+            self,
+            "SummaryWorkflowFunction",
+            runtime=lambda_.Runtime.PROVIDED_AL2023,
+            handler="bootstrap",
+            code=lambda_.Code.from_asset("../lambda-build"),
+            role=lambda_role,
+            timeout=Duration.minutes(10),
+            memory_size=512,
+            architecture=lambda_.Architecture.X86_64,
+            tracing=lambda_.Tracing.ACTIVE if tracing_exporter == "xray" else lambda_.Tracing.DISABLED,
+            environment=api_environment,
+            log_retention=logs.RetentionDays.ONE_WEEK,
+            description="Bedrock Question Search bulk summary workflow tasks",
+        )
+
+        def summary_task(state_id, task, **fields):
+            state = sfn_tasks.LambdaInvoke(
+                self,
+                state_id,
+                lambda_function=summary_workflow_function,
+                payload=sfn.TaskInput.from_object({"summaryWorkflowTask": task, **fields}),
+                payload_response_only=True,
+            )
+            # Throttled Bedrock calls and timeouts are worth another try
+            state.add_retry(
+                errors=["States.TaskFailed", "States.Timeout"],
+                interval=Duration.seconds(10),
+                max_attempts=2,
+                backoff_rate=2,
+            )
+            return state
+
+        job_id = sfn.JsonPath.string_at("$.jobId")
+        chunk = sfn.JsonPath.number_at("$.chunk")
+        fetch_documents = summary_task("FetchDocuments", "fetch", jobId=job_id)
+        summarize_chunks = sfn.Map(
+            self,
+            "SummarizeChunks",
+            items_path="$.chunks",
+            item_selector={"jobId": job_id, "chunk": sfn.JsonPath.number_at("$$.Map.Item.Value")},
+            max_concurrency=summary_job_concurrency,
+            result_path=sfn.JsonPath.DISCARD,
+        )
+        summarize_chunks.item_processor(
+            summary_task("SummarizeChunk", "summarize", jobId=job_id, chunk=chunk).next(
+                summary_task("CompareChunk", "compare", jobId=job_id, chunk=chunk)
+            )
+        )
+        aggregate_summaries = summary_task("AggregateSummaries", "aggregate", jobId=job_id)
+
+        fail_summary_job = sfn_tasks.LambdaInvoke(
+            self,
+            "FailSummaryJob",
+            lambda_function=summary_workflow_function,
+            payload=sfn.TaskInput.from_object(
+                {
+                    "summaryWorkflowTask": "fail",
+                    "jobId": job_id,
+                    "error": sfn.JsonPath.string_at("$.error.Cause"),
+                }
+            ),
+            payload_response_only=True,
+        ).next(sfn.Fail(self, "SummaryJobFailed"))
+        for state in (fetch_documents, summarize_chunks, aggregate_summaries):
+            state.add_catch(fail_summary_job, result_path="$.error")
+
+        sfn.StateMachine(
+            self,
+            "SummaryWorkflow",
+            state_machine_name=summary_workflow_name,
+            definition_body=sfn.DefinitionBody.from_chainable(
+                fetch_documents.next(summarize_chunks).next(aggregate_summaries)
+            ),
+            timeout=Duration.hours(2),
+        )
+
+        # Text of scanned PDFs, extracted when they are uploaded to documents_bucket. The bucket
+        # must send its events to EventBridge (Properties > Event notifications). Long scans
+        # take Textract minutes, and a failed event is retried twice.
+        if documents_bucket and scanned_pdf_ocr_enabled == "true":
+            scanned_document_function = lambda_.Function(
+                self,
+                "ScannedDocumentFunction",
+                runtime=lambda_.Runtime.PROVIDED_AL2023,
+                handler="bootstrap",
+                code=lambda_.Code.from_asset("../lambda-build"),
+                role=lambda_role,
+                timeout=Duration.minutes(15),
+                memory_size=512,
+                architecture=lambda_.Architecture.X86_64,
+                tracing=lambda_.Tracing.ACTIVE if tracing_exporter == "xray" else lambda_.Tracing.DISABLED,
+                environment=api_environment,
+                log_retention=logs.RetentionDays.ONE_WEEK,
+                description="Bedrock Question Search scanned PDF text extraction",
+            )
+            events.Rule(
+                self,
+                "ScannedPdfUploads",
+                event_pattern=events.EventPattern(
+                    source=["aws.s3"],
+                    detail_type=["Object Created"],
+                    detail={
+                        "bucket": {"name": [documents_bucket]},
+                        "object": {"key": [{"suffix": ".pdf"}, {"suffix": ".PDF"}]},
+                    },
+                ),
+                targets=[targets.LambdaFunction(scanned_document_function, retry_attempts=2)],
+            )
+
+        # Warm-up before the first questions of the day, so they do not pay for resolving
+        # credentials, connecting to Bedrock and fetching the SSM prompts
+        if warm_up_schedule:
+            events.Rule(
+                self,
+                "WarmUpSchedule",
+                schedule=events.Schedule.expression(warm_up_schedule),
+                targets=[
+                    targets.LambdaFunction(
+                        api_lambda,
+                        event=events.RuleTargetInput.from_object({"warmUp": True}),
+                    )
+                ],
+            )
+
+        # HTTP API Gateway
+        http_api = apigw.HttpApi(
+            self,
+            "BedrockHttpApi",
+            api_name="bedrock-question-search-api",
+            description="Bedrock Question Search API",
+            # No cors_preflight: OPTIONS is forwarded to Lambda, where the router answers
+            # preflights with the methods registered for each route
+        )
+
+        # Lambda integration
+        lambda_integration = integrations.HttpLambdaIntegration(
+            "LambdaIntegration",
+            api_lambda,
+        )
+
+        # Add catch-all route to forward all requests to Lambda
+        http_api.add_routes(
+            path="/{proxy+}",
+            methods=[apigw.HttpMethod.ANY],
+            integration=lambda_integration,
+        )
+
+        # WebSocket API of the chat: connections, messages and disconnections invoke the
+        # function, which posts the replies back through the management API
+        if chat_enabled == "true":
+            chat_integration = integrations.WebSocketLambdaIntegration("ChatIntegration", api_lambda)
+            chat_api = apigw.WebSocketApi(
+                self,
+                "ChatWebSocketApi",
+                api_name="bedrock-question-search-chat",
+                description="Bedrock Question Search chat",
+                connect_route_options=apigw.WebSocketRouteOptions(integration=chat_integration),
+                disconnect_route_options=apigw.WebSocketRouteOptions(integration=chat_integration),
+                default_route_options=apigw.WebSocketRouteOptions(integration=chat_integration),
+            )
+            chat_stage = apigw.WebSocketStage(
+                self,
+                "ChatWebSocketStage",
+                web_socket_api=chat_api,
+                stage_name="prod",
+                auto_deploy=True,
+            )
+            chat_api.grant_manage_connections(lambda_role)
+
+            CfnOutput(
+                self,
+                "ChatUrl",
+                value=chat_stage.url,
+                description="WebSocket URL of the chat",
+            )
+
+        # Outputs
+        CfnOutput(
+            self,
+            "ApiUrl",
+            value=http_api.url or "",
+            description="HTTP API Gateway URL",
+        )
+
+        CfnOutput(
+            self,
+            "LambdaFunctionName",
+            value=api_lambda.function_name,
+            description="Lambda function name",
+        )
+
+        CfnOutput(
+            self,
+            "AlertTopicArn",
+            value=alert_topic.topic_arn,
+            description="SNS topic of error rate and throttling alerts",
+        )
+
+        CfnOutput(
+            self,
+            "DocumentEventBusName",
+            value=document_event_bus.event_bus_name,
+            description="EventBridge bus of document lifecycle events",
+        )
+
+        CfnOutput(
+            self,
+            "AnalyticsExportBucketName",
+            value=analytics_export_bucket.bucket_name,
+            description="S3 bucket of the daily analytics export",
+        )
+
+        CfnOutput(
+            self,
+            "AuditBucketName",
+            value=audit_bucket.bucket_name,
+            description="S3 bucket of the write-once question and answer audit trail",
+        )
@@ -1,97 +1,777 @@
-package config
-
-import (
-	_ "embed"
-	"fmt"
-	"os"
-	"strconv"
-	"strings"
-)
-
-//go:embed question_search_instructions.txt
-var questionSearchInstructions string
-
-//go:embed document_comparison_instructions.txt
-var documentComparisonInstructions string
-
-type Config struct {
-	AWSRegion                      string
-	EmbeddingModelId               string
-	KnowledgeBaseIds               []string
-	GenerativeModelId              string
-	SystemInstructions             string // Deprecated: Use QuestionSearchInstructions
-	QuestionSearchInstructions     string
-	DocumentComparisonInstructions string
-	MaxQuestionLength              int
-	RetryAttempts                  int
-	OpenSearchEndpoint             string
-	OpenSearchIndex                string
-}
-
-func LoadConfig() (*Config, error) {
-	region := getEnv("BEDROCK_REGION", "")
-	if region == "" {
-		region = getEnv("AWS_REGION", "us-east-1")
-	}
-
-	config := &Config{
-		AWSRegion:                      region,
-		EmbeddingModelId:               getEnv("BEDROCK_EMBEDDING_MODEL", "amazon.titan-embed-text-v2:0"),
-		KnowledgeBaseIds:               []string{"ZHYAWGPBRS", "I2XCL5FZAQ", "CC46VWUAVL"},                             // Multiple Knowledge Base IDs
-		GenerativeModelId:              getEnv("BEDROCK_GENERATIVE_MODEL", "anthropic.claude-haiku-4-5-20251001-v1:0"), // Claude 3.5 Haiku
-		SystemInstructions:             strings.TrimSpace(questionSearchInstructions),                                  // Backward compatibility
-		QuestionSearchInstructions:     strings.TrimSpace(questionSearchInstructions),
-		DocumentComparisonInstructions: strings.TrimSpace(documentComparisonInstructions),
-		MaxQuestionLength:              getEnvAsInt("MAX_QUESTION_LENGTH", 1000),
-		RetryAttempts:                  getEnvAsInt("RETRY_ATTEMPTS", 3),
-		OpenSearchEndpoint:             getEnv("OPENSEARCH_ENDPOINT", ""),
-		OpenSearchIndex:                getEnv("OPENSEARCH_INDEX", "bedrock-knowledge-base-default-index"),
-	}
-
-	if err := config.Validate(); err != nil {
-		return nil, err
-	}
-
-	return config, nil
-}
-
-func (c *Config) Validate() error {
-	if c.AWSRegion == "" {
-		return fmt.Errorf("AWS_REGION is required")
-	}
-	if c.EmbeddingModelId == "" {
-		return fmt.Errorf("BEDROCK_EMBEDDING_MODEL is required")
-	}
-	if len(c.KnowledgeBaseIds) == 0 {
-		return fmt.Errorf("at least one BEDROCK_KB_ID is required")
-	}
-	if c.GenerativeModelId == "" {
-		return fmt.Errorf("BEDROCK_GENERATIVE_MODEL is required")
-	}
-	if c.MaxQuestionLength <= 0 {
-		return fmt.Errorf("MAX_QUESTION_LENGTH must be positive")
-	}
-	if c.RetryAttempts < 0 {
-		return fmt.Errorf("RETRY_ATTEMPTS must be non-negative")
-	}
-	return nil
-}
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-func getEnvAsInt(key string, defaultValue int) int {
-	valueStr := os.Getenv(key)
-	if valueStr == "" {
-		return defaultValue
-	}
-	value, err := strconv.Atoi(valueStr)
-	if err != nil {
-		return defaultValue
-	}
-	return value
-}
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed question_search_instructions.txt
+var questionSearchInstructions string
+
+//go:embed question_search_instructions_en.txt
+var questionSearchInstructionsEnglish string
+
+//go:embed document_comparison_instructions.txt
+var documentComparisonInstructions string
+
+//go:embed document_summary_instructions.txt
+var documentSummaryInstructions string
+
+//go:embed question_search_candidate_instructions.txt
+var questionSearchCandidateInstructions string
+
+//go:embed answer_diff_instructions.txt
+var answerDiffInstructions string
+
+//go:embed answer_judge_instructions.txt
+var answerJudgeInstructions string
+
+//go:embed related_questions_instructions.txt
+var relatedQuestionsInstructions string
+
+//go:embed answer_synthesis_instructions.txt
+var answerSynthesisInstructions string
+
+//go:embed synonyms.txt
+var synonyms string
+
+type Config struct {
+	AWSRegion                      string
+	EmbeddingModelId               string
+	KnowledgeBases                 []KnowledgeBase
+	GenerativeModelId              string
+	FallbackModelIds               []string // Generative models tried in order when GenerativeModelId fails
+	SystemInstructions             string   // Deprecated: Use QuestionSearchInstructions
+	QuestionSearchInstructions     string
+	QuestionSearchInstructionsEn   string // Question search prompt for English questions
+	DocumentComparisonInstructions string
+	DocumentSummaryInstructions    string
+	CandidateInstructions          string // Candidate question search prompt for answer diffs
+	AnswerDiffInstructions         string
+	AnswerJudgeInstructions        string // Grading prompt of evaluation runs
+	RelatedQuestionsInstructions   string
+	SynthesisInstructions          string // Rules for merging the answers of several knowledge bases
+	MaxQuestionLength              int
+	MaxRequestBodyBytes            int
+	ListenAddr                     string
+	ServerReadTimeoutSeconds       int
+	ServerWriteTimeoutSeconds      int
+	ServerIdleTimeoutSeconds       int
+	RequestTimeoutSeconds          int
+	LegacyPathsSunset              time.Time // Announced in the Sunset header of the unversioned paths, zero for none
+	RetryAttempts                  int
+	OpenSearchEndpoint             string
+	OpenSearchIndex                string
+	AdminToken                     string
+	DocumentSummaryTable           string
+	JobCheckpointTable             string
+	ResummarizeConcurrency         int
+	SafeMode                       *SafeMode
+	MaintenanceMode                *MaintenanceMode
+	FeatureFlags                   string
+	FeatureFlagsParameter          string
+	FeatureFlagsRefreshSeconds     int
+	ResponseSigningSecretId        string
+	DocumentAllowedHosts           []string
+	DocumentLinkMode               string
+	DocumentLinkExpirySeconds      int
+	MaxSummaryDocuments            int
+	SummaryWorkers                 int
+	SummaryDocumentTimeoutSeconds  int
+	CandidateModelId               string
+	NotFoundTable                  string
+	NotFoundRetentionDays          int
+	NormalizationTable             string
+	NormalizationRefreshSeconds    int
+	SynonymsEnabled                bool
+	Synonyms                       string // Embedded synonym file, replaced by the SYNONYMS_BUCKET object
+	SynonymsBucket                 string
+	SynonymsKey                    string
+	EvalBucket                     string
+	EvalDatasetKey                 string
+	EvalReportPrefix               string
+	EvalConcurrency                int
+	TranslationProvider            string
+	EndpointPolicies               string
+	EndpointPoliciesParameter      string
+	EndpointPoliciesRefreshSeconds int
+	SessionMaxQuestionsPerMinute   int
+	SessionMaxTokens               int
+	SessionWindowMinutes           int
+	SessionLimitTable              string
+	DeletedDocumentsTable          string
+	DeletedDocumentRetentionDays   int
+	DeletedDocumentsRefreshSeconds int
+	DocumentArchiveBucket          string
+	WebhookTable                   string
+	WebhookMaxAttempts             int
+	WebhookTimeoutSeconds          int
+	DigestSubscriptionTable        string
+	DigestSenderEmail              string
+	DigestDays                     int
+	DocumentContentSource          string
+	ContentCacheDir                string
+	ContentCacheMaxMB              int
+	ScannedPdfOcrEnabled           bool
+	ScannedPdfMinTextCharacters    int
+	ScannedPdfSyncDataSource       string
+	ClarificationMaxQuestionLength int
+	ClarificationMaxParts          int
+	ClarificationTopics            string
+	AnswerBackend                  string
+	AnswerBackendTenants           string
+	MergedRetrievalResults         int
+	RetrievalSearchType            string
+	RetrievalResults               int
+	RetrievalMinScore              float64
+	GenerationOverridesEnabled     bool
+	KBGenerationTemperature        *float64 // nil for Bedrock's default
+	KBGenerationTopP               *float64 // nil for Bedrock's default
+	KBGenerationMaxTokens          int
+	KBPromptTemplate               string
+	KBMaxConcurrentQueries         int
+	KBFailureBudget                int
+	KBSuspendSeconds               int
+	KBQueryTimeoutMs               int
+	BedrockAgentId                 string
+	BedrockAgentAliasId            string
+	StubAnswer                     string
+	BootstrapResources             bool
+	AnswerDisclaimer               string
+	DisclaimerTenants              string
+	DisclaimerPlacement            string
+	BedrockModelProbe              bool
+	WarmUpPrefetchPrompts          bool
+	Environment                    string
+	FaultInjectionEnabled          bool
+	FaultInjection                 string
+	BedrockMaxConcurrency          int
+	BedrockOperationLimits         []string
+	BedrockQueueTimeoutMs          int
+	BedrockAdaptiveThrottle        bool
+	BedrockMinRate                 float64
+	BedrockMaxRate                 float64
+	AnswerCacheTTLSeconds          int
+	EmbeddingCacheTTLSeconds       int
+	EmbeddingDimensions            int
+	EmbeddingNormalize             bool
+	EmbeddingCacheMaxEntries       int
+	AnswerCacheMaxEntries          int
+	CacheRedisAddr                 string
+	CacheRedisTLS                  bool
+	CacheRedisAuthSecretId         string
+	IdempotencyTTLSeconds          int
+	IdempotencyTable               string
+	QuestionJobQueueUrl            string
+	JobsTable                      string
+	JobTTLSeconds                  int
+	QuestionJobTTLSeconds          int
+	ChatEnabled                    bool
+	ChatPingSeconds                int
+	ChatConnectionsTable           string
+	SummaryWorkflowArn             string
+	SummaryJobsTable               string
+	SummaryJobTTLSeconds           int
+	SummaryJobMaxDocuments         int
+	SummaryJobChunkSize            int
+	AnalyticsExportBucket          string
+	AnalyticsExportPrefix          string
+	AccessControlRules             string
+	AccessControlRoleClaim         string
+	AuthJwksUrl                    string
+	AuthIssuer                     string
+	AuthAudience                   string
+	AuthUserClaim                  string
+	AuthRequired                   bool
+	IamAuthAllowedPrincipals       []string
+	IamAuthServerId                string
+	IamAuthStsRegion               string
+	TracingExporter                string
+	TracingEndpoint                string
+	TracingSamplePercent           int
+	AlertTopicArn                  string
+	AlertWindowSeconds             int
+	AlertMinRequests               int
+	AlertErrorRatePercent          int
+	AlertThrottleRatePercent       int
+	AlertCooldownSeconds           int
+	EventBusName                   string
+	FeedbackTable                  string
+	FeedbackRetentionDays          int
+	HistoryTable                   string
+	HistoryRetentionDays           int
+	AuditBucket                    string
+	AuditPrefix                    string
+	AuditRetentionDays             int
+	ConfigSSMPrefix                string
+	ConfigRefreshSeconds           int
+	LiveSettings                   *LiveSettings // Current knowledge base, model and prompt settings, nil keeps the fields above
+	PIIDetectionEnabled            bool
+	VersionComparisonTable         string
+	ComparisonWorkers              int
+	IntentKeywords                 string
+	UsageTable                     string
+	UsageRetentionDays             int
+	ModelPrices                    string
+	Experiment                     string
+	ExperimentTable                string
+	ExperimentRetentionDays        int
+	ApiKeyTable                    string
+	ApiKeyQuotaTable               string
+	ApiKeyRequired                 bool
+}
+
+// Current returns the knowledge base, model and prompt settings in effect, which SSM
+// parameters may have changed since the configuration was loaded
+func (c *Config) Current() Settings {
+	if c.LiveSettings != nil {
+		return c.LiveSettings.Get()
+	}
+	return Settings{
+		KnowledgeBases:                 c.KnowledgeBases,
+		EmbeddingModelId:               c.EmbeddingModelId,
+		GenerativeModelId:              c.GenerativeModelId,
+		FallbackModelIds:               c.FallbackModelIds,
+		CandidateModelId:               c.CandidateModelId,
+		QuestionSearchInstructions:     c.QuestionSearchInstructions,
+		QuestionSearchInstructionsEn:   c.QuestionSearchInstructionsEn,
+		DocumentComparisonInstructions: c.DocumentComparisonInstructions,
+		DocumentSummaryInstructions:    c.DocumentSummaryInstructions,
+		CandidateInstructions:          c.CandidateInstructions,
+		AnswerDiffInstructions:         c.AnswerDiffInstructions,
+		AnswerJudgeInstructions:        c.AnswerJudgeInstructions,
+		RelatedQuestionsInstructions:   c.RelatedQuestionsInstructions,
+		SynthesisInstructions:          c.SynthesisInstructions,
+	}
+}
+
+// LoadConfig reads the configuration from env vars. With CONFIG_SSM_PREFIX set, SSM parameters
+// under the prefix named after an env var take its place, and the knowledge base, model and
+// prompt settings are reloaded from them every CONFIG_REFRESH_SECONDS. When the parameters
+// cannot be read the env vars are used.
+func LoadConfig() (*Config, error) {
+	env := environment{}
+	region := env.getEnv("BEDROCK_REGION", "")
+	if region == "" {
+		region = env.getEnv("AWS_REGION", "us-east-1")
+	}
+
+	var parameterSource ParameterSource
+	if prefix := env.getEnv("CONFIG_SSM_PREFIX", ""); prefix != "" {
+		source, err := newDefaultSSMParameterSource(region, prefix)
+		if err != nil {
+			return nil, err
+		}
+		parameterSource = source
+		env.parameters, err = loadParameters(source)
+		if err != nil {
+			log.Printf("Failed to read configuration parameters from %s, using env vars: %v", source.Name(), err)
+		}
+	}
+	settings, err := settingsFrom(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// The unversioned /api/teletubpax paths are deprecated aliases of /api/teletubpax/v1
+	var legacyPathsSunset time.Time
+	if value := env.getEnv("LEGACY_PATHS_SUNSET", ""); value != "" {
+		legacyPathsSunset, err = time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, fmt.Errorf("LEGACY_PATHS_SUNSET must be a date like 2027-06-30: %w", err)
+		}
+	}
+
+	// The Redis was the answer cache's alone before, so its ANSWER_CACHE_REDIS_* names still work
+	redisAddr := env.getEnv("ANSWER_CACHE_REDIS_ADDR", "")
+	redisTLS := env.getEnvAsBool("ANSWER_CACHE_REDIS_TLS", false)
+	redisAuth := env.getEnv("ANSWER_CACHE_REDIS_AUTH_SECRET_ID", "")
+
+	// The jobs table held queued questions alone before, so QUESTION_JOBS_TABLE still works
+	questionJobsTable := env.getEnv("QUESTION_JOBS_TABLE", "")
+
+	// Only documents of the documents bucket and its CDN are summarized unless other hosts
+	// are allowed, without either every document URL is refused
+	var documentHosts []string
+	if bucket := env.getEnv("DOCUMENTS_BUCKET", ""); bucket != "" {
+		documentHosts = append(documentHosts, bucket+".s3."+region+".amazonaws.com")
+	}
+	if cdnDomain := env.getEnv("DOCUMENT_CDN_DOMAIN", ""); cdnDomain != "" {
+		documentHosts = append(documentHosts, cdnDomain)
+	}
+
+	config := &Config{
+		AWSRegion:                      region,
+		EmbeddingModelId:               settings.EmbeddingModelId,
+		KnowledgeBases:                 settings.KnowledgeBases,
+		GenerativeModelId:              settings.GenerativeModelId,
+		FallbackModelIds:               settings.FallbackModelIds,
+		SystemInstructions:             settings.QuestionSearchInstructions, // Backward compatibility
+		QuestionSearchInstructions:     settings.QuestionSearchInstructions,
+		QuestionSearchInstructionsEn:   settings.QuestionSearchInstructionsEn,
+		DocumentComparisonInstructions: settings.DocumentComparisonInstructions,
+		DocumentSummaryInstructions:    settings.DocumentSummaryInstructions,
+		CandidateInstructions:          settings.CandidateInstructions,
+		AnswerDiffInstructions:         settings.AnswerDiffInstructions,
+		AnswerJudgeInstructions:        settings.AnswerJudgeInstructions,
+		RelatedQuestionsInstructions:   settings.RelatedQuestionsInstructions,
+		SynthesisInstructions:          settings.SynthesisInstructions,
+		ConfigSSMPrefix:                env.getEnv("CONFIG_SSM_PREFIX", ""),
+		ConfigRefreshSeconds:           env.getEnvAsInt("CONFIG_REFRESH_SECONDS", 60),
+		MaxQuestionLength:              env.getEnvAsInt("MAX_QUESTION_LENGTH", 1000),
+		MaxRequestBodyBytes:            env.getEnvAsInt("MAX_REQUEST_BODY_BYTES", 1<<20),    // Larger bodies answer 413, 0 disables the limit; the largest valid request is a list of document URLs for a summary
+		ListenAddr:                     env.getEnv("LISTEN_ADDR", ":8080"),                  // Address of the container's HTTP server
+		ServerReadTimeoutSeconds:       env.getEnvAsInt("SERVER_READ_TIMEOUT_SECONDS", 15),  // Reading the request headers and body, 0 means none
+		ServerWriteTimeoutSeconds:      env.getEnvAsInt("SERVER_WRITE_TIMEOUT_SECONDS", 90), // From the end of the request headers to the end of the response, 0 means none
+		ServerIdleTimeoutSeconds:       env.getEnvAsInt("SERVER_IDLE_TIMEOUT_SECONDS", 120), // Keep-alive connections between requests, 0 uses the read timeout
+		RequestTimeoutSeconds:          env.getEnvAsInt("REQUEST_TIMEOUT_SECONDS", 60),      // Deadline of requests without an endpoint policy timeout, 0 means none
+		LegacyPathsSunset:              legacyPathsSunset,
+		RetryAttempts:                  env.getEnvAsInt("RETRY_ATTEMPTS", 3),
+		OpenSearchEndpoint:             env.getEnv("OPENSEARCH_ENDPOINT", ""),
+		OpenSearchIndex:                env.getEnv("OPENSEARCH_INDEX", "bedrock-knowledge-base-default-index"),
+		AdminToken:                     env.getEnv("ADMIN_API_TOKEN", ""),        // Empty disables admin endpoints
+		DocumentSummaryTable:           env.getEnv("DOCUMENT_SUMMARY_TABLE", ""), // Precomputed summaries (optional)
+		JobCheckpointTable:             env.getEnv("JOB_CHECKPOINT_TABLE", ""),   // Batch job checkpoints (optional)
+		ResummarizeConcurrency:         env.getEnvAsInt("RESUMMARIZE_CONCURRENCY", 4),
+		SafeMode:                       NewSafeMode(env.getEnvAsBool("SAFE_MODE", false)),
+		FeatureFlags:                   env.getEnv("FEATURE_FLAGS", ""),               // e.g. "semantic-cache,answer-diff=false"
+		FeatureFlagsParameter:          env.getEnv("FEATURE_FLAGS_SSM_PARAMETER", ""), // Overrides FEATURE_FLAGS (optional)
+		FeatureFlagsRefreshSeconds:     env.getEnvAsInt("FEATURE_FLAGS_REFRESH_SECONDS", 60),
+		ResponseSigningSecretId:        env.getEnv("RESPONSE_SIGNING_SECRET_ID", ""), // Secrets Manager HMAC key, empty disables signing
+		DocumentAllowedHosts:           env.getEnvAsList("DOCUMENT_ALLOWED_HOSTS", documentHosts),
+		DocumentLinkMode:               env.getEnv("DOCUMENT_LINK_MODE", "public"), // "public" bucket URLs or time-limited "presigned" URLs
+		DocumentLinkExpirySeconds:      env.getEnvAsInt("DOCUMENT_LINK_EXPIRY_SECONDS", 3600),
+		MaxSummaryDocuments:            env.getEnvAsInt("MAX_SUMMARY_DOCUMENTS", 20),
+		SummaryWorkers:                 env.getEnvAsInt("SUMMARY_WORKERS", 4),
+		SummaryDocumentTimeoutSeconds:  env.getEnvAsInt("SUMMARY_DOCUMENT_TIMEOUT_SECONDS", 10),
+		VersionComparisonTable:         env.getEnv("VERSION_COMPARISON_TABLE", ""), // Cached version change summaries (optional)
+		ComparisonWorkers:              env.getEnvAsInt("COMPARISON_WORKERS", 4),
+		CandidateModelId:               settings.CandidateModelId,
+		NotFoundTable:                  env.getEnv("NOT_FOUND_TABLE", ""), // Unanswered question analytics (optional)
+		NotFoundRetentionDays:          env.getEnvAsInt("NOT_FOUND_RETENTION_DAYS", 90),
+		FeedbackTable:                  env.getEnv("FEEDBACK_TABLE", ""), // Answer feedback (optional)
+		FeedbackRetentionDays:          env.getEnvAsInt("FEEDBACK_RETENTION_DAYS", 180),
+		HistoryTable:                   env.getEnv("CONVERSATION_HISTORY_TABLE", ""), // Questions and answers of signed-in users (optional)
+		HistoryRetentionDays:           env.getEnvAsInt("CONVERSATION_HISTORY_RETENTION_DAYS", 90),
+		AuditBucket:                    env.getEnv("AUDIT_BUCKET", ""),               // S3 bucket with Object Lock for the Q&A audit trail (optional)
+		AuditPrefix:                    env.getEnv("AUDIT_PREFIX", "audit"),          // Key prefix of the audit records
+		AuditRetentionDays:             env.getEnvAsInt("AUDIT_RETENTION_DAYS", 365), // How long audit records are locked against changes and deletion
+		UsageTable:                     env.getEnv("USAGE_TABLE", ""),                // Token usage aggregates, in-memory per instance when empty
+		UsageRetentionDays:             env.getEnvAsInt("USAGE_RETENTION_DAYS", 400),
+		ModelPrices:                    env.getEnv("MODEL_PRICES", ""),              // JSON {"model": {"input": USD, "output": USD}} per 1,000 tokens, added to the built-in prices
+		ApiKeyTable:                    env.getEnv("API_KEY_TABLE", ""),             // API keys managed by the admin API (optional)
+		ApiKeyQuotaTable:               env.getEnv("API_KEY_QUOTA_TABLE", ""),       // Shared daily quota counters, in-memory per instance when empty
+		ApiKeyRequired:                 env.getEnvAsBool("API_KEY_REQUIRED", false), // Reject requests without an X-Api-Key header
+		NormalizationTable:             env.getEnv("NORMALIZATION_TABLE", ""),       // Question normalization dictionary (optional)
+		NormalizationRefreshSeconds:    env.getEnvAsInt("NORMALIZATION_REFRESH_SECONDS", 60),
+		SynonymsEnabled:                env.getEnvAsBool("SYNONYMS_ENABLED", false), // Rewrite abbreviations in questions with the synonym file
+		SynonymsBucket:                 env.getEnv("SYNONYMS_BUCKET", ""),           // S3 bucket of a synonym file replacing the embedded one (optional)
+		SynonymsKey:                    env.getEnv("SYNONYMS_KEY", "synonyms.txt"),
+		Synonyms:                       synonyms,
+		EvalBucket:                     env.getEnv("EVAL_BUCKET", ""),                      // S3 bucket of the golden dataset and evaluation reports (optional)
+		EvalDatasetKey:                 env.getEnv("EVAL_DATASET_KEY", "eval/golden.json"), // JSON array of golden cases
+		EvalReportPrefix:               env.getEnv("EVAL_REPORT_PREFIX", "eval/reports"),   // Key prefix of the reports and the baseline
+		EvalConcurrency:                env.getEnvAsInt("EVAL_CONCURRENCY", 4),
+		TranslationProvider:            env.getEnv("TRANSLATION_PROVIDER", "translate"),   // "translate" (Amazon Translate), "bedrock" or "off"
+		EndpointPolicies:               env.getEnv("ENDPOINT_POLICIES", ""),               // JSON policy blocks per endpoint
+		EndpointPoliciesParameter:      env.getEnv("ENDPOINT_POLICIES_SSM_PARAMETER", ""), // Overrides ENDPOINT_POLICIES per endpoint (optional)
+		EndpointPoliciesRefreshSeconds: env.getEnvAsInt("ENDPOINT_POLICIES_REFRESH_SECONDS", 60),
+		Experiment:                     env.getEnv("EXPERIMENT", ""),       // JSON {"name": "...", "variants": [{"name": "...", "percent": 10, ...}]}, empty runs no experiment
+		ExperimentTable:                env.getEnv("EXPERIMENT_TABLE", ""), // Per-variant answer and feedback counts, in-memory per instance when empty
+		ExperimentRetentionDays:        env.getEnvAsInt("EXPERIMENT_RETENTION_DAYS", 90),
+		SessionMaxQuestionsPerMinute:   env.getEnvAsInt("SESSION_MAX_QUESTIONS_PER_MINUTE", 10), // 0 disables
+		SessionMaxTokens:               env.getEnvAsInt("SESSION_MAX_TOKENS", 50000),            // Per session window, 0 disables
+		SessionWindowMinutes:           env.getEnvAsInt("SESSION_WINDOW_MINUTES", 60),
+		SessionLimitTable:              env.getEnv("SESSION_LIMIT_TABLE", ""),     // Shared session counters, in-memory per instance when empty
+		DeletedDocumentsTable:          env.getEnv("DELETED_DOCUMENTS_TABLE", ""), // Soft-deleted documents (optional)
+		DeletedDocumentRetentionDays:   env.getEnvAsInt("DELETED_DOCUMENT_RETENTION_DAYS", 30),
+		DeletedDocumentsRefreshSeconds: env.getEnvAsInt("DELETED_DOCUMENTS_REFRESH_SECONDS", 60),
+		DocumentArchiveBucket:          env.getEnv("DOCUMENT_ARCHIVE_BUCKET", ""),
+		WebhookTable:                   env.getEnv("WEBHOOK_TABLE", ""), // Document version webhooks (optional)
+		WebhookMaxAttempts:             env.getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 3),
+		WebhookTimeoutSeconds:          env.getEnvAsInt("WEBHOOK_TIMEOUT_SECONDS", 5),
+		DigestSubscriptionTable:        env.getEnv("DIGEST_SUBSCRIPTION_TABLE", ""), // Weekly document change digest subscriptions (optional)
+		DigestSenderEmail:              env.getEnv("DIGEST_SENDER_EMAIL", ""),       // SES verified sender for email digests
+		DigestDays:                     env.getEnvAsInt("DIGEST_DAYS", 7),
+		DocumentContentSource:          env.getEnv("DOCUMENT_CONTENT_SOURCE", "knowledge-base"), // "knowledge-base" (retrieved chunks) or "s3" (full source documents)
+		ContentCacheDir:                env.getEnv("CONTENT_CACHE_DIR", filepath.Join(os.TempDir(), "teletubpax-content")),
+		ContentCacheMaxMB:              env.getEnvAsInt("CONTENT_CACHE_MAX_MB", 128),              // 0 disables the cache
+		ScannedPdfOcrEnabled:           env.getEnvAsBool("SCANNED_PDF_OCR_ENABLED", false),        // Extract the text of uploaded scanned PDFs with Amazon Textract
+		ScannedPdfMinTextCharacters:    env.getEnvAsInt("SCANNED_PDF_MIN_TEXT_CHARACTERS", 100),   // PDFs with less text are treated as scans
+		ScannedPdfSyncDataSource:       env.getEnv("SCANNED_PDF_SYNC_DATA_SOURCE", ""),            // <knowledge base ID>/<data source ID> synced after the text is written (optional)
+		ClarificationMaxQuestionLength: env.getEnvAsInt("CLARIFICATION_MAX_QUESTION_LENGTH", 300), // Characters, 0 disables the length check
+		ClarificationMaxParts:          env.getEnvAsInt("CLARIFICATION_MAX_PARTS", 3),             // Questions asked at once, 0 disables the check
+		ClarificationTopics:            env.getEnv("CLARIFICATION_TOPICS", ""),                    // JSON {"term": ["refinement", ...]}, empty uses the built-in topics
+		IntentKeywords:                 env.getEnv("INTENT_KEYWORDS", ""),                         // JSON {"intent": ["keyword", ...]}, empty disables intent routing
+		AnswerBackend:                  env.getEnv("ANSWER_BACKEND", "knowledge-base"),            // "knowledge-base", "retrieval-converse", "agent" or "stub"
+		AnswerBackendTenants:           env.getEnv("ANSWER_BACKEND_TENANTS", ""),                  // JSON {"tenant": "backend"}, selected by the X-Tenant-Id header
+		MergedRetrievalResults:         env.getEnvAsInt("MERGED_RETRIEVAL_RESULTS", 5),            // Chunks per knowledge base for retrieval-converse
+		RetrievalSearchType:            env.getEnv("RETRIEVAL_SEARCH_TYPE", ""),                   // "HYBRID" (vector and keyword) or "SEMANTIC", empty lets Bedrock choose
+		RetrievalResults:               env.getEnvAsInt("RETRIEVAL_RESULTS", 5),                   // Chunks per knowledge base an answer is generated from
+		RetrievalMinScore:              env.getEnvAsFloat("RETRIEVAL_MIN_SCORE", 0),               // Retrieved chunks scoring below it are dropped, 0 keeps all
+		GenerationOverridesEnabled:     env.getEnvAsBool("GENERATION_OVERRIDES_ENABLED", false),   // Accept temperature, maxTokens and instructionSuffix in question-search requests
+		KBGenerationTemperature:        env.getEnvAsOptionalFloat("KB_GENERATION_TEMPERATURE"),    // RetrieveAndGenerate temperature, empty for Bedrock's default
+		KBGenerationTopP:               env.getEnvAsOptionalFloat("KB_GENERATION_TOP_P"),          // RetrieveAndGenerate topP, empty for Bedrock's default
+		KBGenerationMaxTokens:          env.getEnvAsInt("KB_GENERATION_MAX_TOKENS", 0),            // RetrieveAndGenerate generation limit, 0 for Bedrock's default
+		KBPromptTemplate:               env.getEnv("KB_PROMPT_TEMPLATE", ""),                      // RetrieveAndGenerate prompt with $instructions$, $query$ and $search_results$, empty for the built-in one
+		KBMaxConcurrentQueries:         env.getEnvAsInt("KB_MAX_CONCURRENT_QUERIES", 20),          // Queries in flight per knowledge base in multi knowledge base answers, 0 for no bound
+		KBFailureBudget:                env.getEnvAsInt("KB_FAILURE_BUDGET", 5),                   // Consecutive failures before a knowledge base is skipped for KB_SUSPEND_SECONDS, 0 never skips
+		KBSuspendSeconds:               env.getEnvAsInt("KB_SUSPEND_SECONDS", 30),                 // How long a knowledge base that spent its failure budget is skipped
+		KBQueryTimeoutMs:               env.getEnvAsInt("KB_QUERY_TIMEOUT_MS", 15000),             // Multi knowledge base answers go on without a slower knowledge base, 0 waits for all
+		EmbeddingCacheTTLSeconds:       env.getEnvAsInt("EMBEDDING_CACHE_TTL_SECONDS", 86400),     // Lifetime of cached embeddings, 0 disables the cache
+		EmbeddingCacheMaxEntries:       env.getEnvAsInt("EMBEDDING_CACHE_MAX_ENTRIES", 1000),      // Embeddings kept by the in-memory cache
+		EmbeddingDimensions:            env.getEnvAsInt("EMBEDDING_DIMENSIONS", 0),                // Titan v2 vector size, 256, 512 or 1024; 0 for the model default
+		EmbeddingNormalize:             env.getEnvAsBool("EMBEDDING_NORMALIZE", true),             // Titan v2 unit-length vectors
+		BedrockAgentId:                 env.getEnv("BEDROCK_AGENT_ID", ""),                        // Enables the agent backend
+		BedrockAgentAliasId:            env.getEnv("BEDROCK_AGENT_ALIAS_ID", ""),
+		StubAnswer:                     env.getEnv("STUB_ANSWER", "This is a stub answer."),
+		BootstrapResources:             env.getEnvAsBool("BOOTSTRAP_RESOURCES", false),       // Create missing tables and log groups on startup
+		AnswerDisclaimer:               env.getEnv("ANSWER_DISCLAIMER", ""),                  // Text added to every answer, empty for none
+		DisclaimerTenants:              env.getEnv("DISCLAIMER_TENANTS", ""),                 // JSON {"tenant": "text"}, selected by the X-Tenant-Id header
+		DisclaimerPlacement:            env.getEnv("DISCLAIMER_PLACEMENT", "append"),         // "append" to the answer or a separate "field"
+		BedrockModelProbe:              env.getEnvAsBool("BEDROCK_MODEL_PROBE", true),        // Check the configured models against the region on startup
+		WarmUpPrefetchPrompts:          env.getEnvAsBool("WARM_UP_PREFETCH_PROMPTS", true),   // Reload the CONFIG_SSM_PREFIX prompts and settings on Lambda warm-up events
+		Environment:                    env.getEnv("ENVIRONMENT", "local"),                   // Deployment environment, fault injection is refused in "prod"
+		FaultInjectionEnabled:          env.getEnvAsBool("FAULT_INJECTION_ENABLED", false),   // Inject faults into AWS calls from FAULT_INJECTION and the X-Fault-Injection header
+		FaultInjection:                 env.getEnv("FAULT_INJECTION", ""),                    // JSON [{"kind": "throttle", "target": "bedrock-agent-runtime", "probability": 0.2}]
+		BedrockMaxConcurrency:          env.getEnvAsInt("BEDROCK_MAX_CONCURRENCY", 0),        // Bedrock model calls in flight at once per instance, 0 for no limit
+		BedrockOperationLimits:         env.getEnvAsList("BEDROCK_OPERATION_LIMITS", nil),    // Per-operation limits within it, e.g. Converse=8,InvokeModel=16
+		BedrockQueueTimeoutMs:          env.getEnvAsInt("BEDROCK_QUEUE_TIMEOUT_MS", 5000),    // How long a call waits for a free slot before it fails as throttled
+		BedrockAdaptiveThrottle:        env.getEnvAsBool("BEDROCK_ADAPTIVE_THROTTLE", true),  // Pace Bedrock model calls once they are throttled, per operation
+		BedrockMinRate:                 env.getEnvAsFloat("BEDROCK_MIN_RATE", 0.5),           // Calls per second a throttled operation is never paced below
+		BedrockMaxRate:                 env.getEnvAsFloat("BEDROCK_MAX_RATE", 20),            // Calls per second at which an operation is no longer paced
+		AnswerCacheTTLSeconds:          env.getEnvAsInt("ANSWER_CACHE_TTL_SECONDS", 0),       // Lifetime of cached answers, 0 disables the cache unless a policy sets cacheTtlSeconds
+		AnswerCacheMaxEntries:          env.getEnvAsInt("ANSWER_CACHE_MAX_ENTRIES", 1000),    // Answers kept by the in-memory cache
+		CacheRedisAddr:                 env.getEnv("CACHE_REDIS_ADDR", redisAddr),            // host:port of a Redis/ElastiCache shared by all instances, empty keeps caches in memory
+		CacheRedisTLS:                  env.getEnvAsBool("CACHE_REDIS_TLS", redisTLS),        // Connect with TLS, for in-transit encryption
+		CacheRedisAuthSecretId:         env.getEnv("CACHE_REDIS_AUTH_SECRET_ID", redisAuth),  // Secrets Manager AUTH token of the Redis, empty for none
+		IdempotencyTTLSeconds:          env.getEnvAsInt("IDEMPOTENCY_TTL_SECONDS", 86400),    // How long responses are replayed to retries with the same Idempotency-Key, 0 ignores the header
+		IdempotencyTable:               env.getEnv("IDEMPOTENCY_TABLE", ""),                  // Responses shared between instances, in-memory per instance when empty
+		QuestionJobQueueUrl:            env.getEnv("QUESTION_JOB_QUEUE_URL", ""),             // SQS queue of questions answered in the background, empty disables question-search/async
+		JobsTable:                      env.getEnv("JOBS_TABLE", questionJobsTable),          // Long-running operations polled at /jobs/{id}, required with QUESTION_JOB_QUEUE_URL
+		JobTTLSeconds:                  env.getEnvAsInt("JOB_TTL_SECONDS", 86400),            // How long ingestion syncs can be polled, questions and summaries have their own
+		QuestionJobTTLSeconds:          env.getEnvAsInt("QUESTION_JOB_TTL_SECONDS", 86400),   // How long jobs and their answers can be polled
+		ChatEnabled:                    env.getEnvAsBool("CHAT_ENABLED", false),              // WebSocket chat with streamed answers
+		ChatPingSeconds:                env.getEnvAsInt("CHAT_PING_SECONDS", 30),             // Interval of the container's pings to chat clients, 0 disables them
+		ChatConnectionsTable:           env.getEnv("CHAT_CONNECTIONS_TABLE", ""),             // API Gateway WebSocket connections on Lambda, in the shared cache when empty
+		SummaryWorkflowArn:             env.getEnv("SUMMARY_WORKFLOW_ARN", ""),               // Step Functions state machine of bulk summaries, empty disables summary-document/jobs
+		SummaryJobsTable:               env.getEnv("SUMMARY_JOBS_TABLE", ""),                 // Bulk summary jobs and their chunks, required with SUMMARY_WORKFLOW_ARN
+		SummaryJobTTLSeconds:           env.getEnvAsInt("SUMMARY_JOB_TTL_SECONDS", 86400),    // How long bulk summary jobs and their results can be polled
+		SummaryJobMaxDocuments:         env.getEnvAsInt("SUMMARY_JOB_MAX_DOCUMENTS", 200),    // Maximum document URLs per bulk summary job
+		SummaryJobChunkSize:            env.getEnvAsInt("SUMMARY_JOB_CHUNK_SIZE", 10),        // Documents summarized per workflow task, all versions of a topic share a chunk
+		AnalyticsExportBucket:          env.getEnv("ANALYTICS_EXPORT_BUCKET", ""),            // S3 bucket for the daily analytics export, empty disables it
+		AnalyticsExportPrefix:          env.getEnv("ANALYTICS_EXPORT_PREFIX", "analytics"),   // Key prefix of the exported datasets
+		AccessControlRules:             env.getEnv("ACCESS_CONTROL_RULES", ""),               // JSON {"attribute": {"value": ["role"]}}, documents with a listed value are only retrieved for those roles
+		AccessControlRoleClaim:         env.getEnv("ACCESS_CONTROL_ROLE_CLAIM", "roles"),     // Token claim listing the caller's roles, e.g. cognito:groups
+		AuthJwksUrl:                    env.getEnv("AUTH_JWKS_URL", ""),                      // Signing keys of the bearer tokens, empty treats every caller as anonymous
+		AuthIssuer:                     env.getEnv("AUTH_ISSUER", ""),                        // Required iss claim, empty accepts any issuer
+		AuthAudience:                   env.getEnv("AUTH_AUDIENCE", ""),                      // Required aud claim, empty accepts any audience
+		AuthUserClaim:                  env.getEnv("AUTH_USER_CLAIM", "sub"),                 // Token claim identifying the user in audit logs and session limits
+		AuthRequired:                   env.getEnvAsBool("AUTH_REQUIRED", false),             // Reject requests without a bearer token
+		IamAuthAllowedPrincipals:       env.getEnvAsList("IAM_AUTH_ALLOWED_PRINCIPALS", nil), // IAM principal ARNs allowed to call with SigV4, a trailing * matches any suffix; empty disables IAM authentication
+		IamAuthServerId:                env.getEnv("IAM_AUTH_SERVER_ID", "teletubpax-api"),   // Value callers sign in X-Teletubpax-Server-Id
+		IamAuthStsRegion:               env.getEnv("IAM_AUTH_STS_REGION", ""),                // Region of the STS endpoint callers sign for, empty uses AWS_REGION
+		TracingExporter:                env.getEnv("TRACING_EXPORTER", ""),                   // "otlp" or "xray", empty disables tracing
+		TracingEndpoint:                env.getEnv("TRACING_ENDPOINT", ""),                   // OTLP/HTTP traces URL, empty uses OTEL_EXPORTER_OTLP_ENDPOINT or http://localhost:4318
+		TracingSamplePercent:           env.getEnvAsInt("TRACING_SAMPLE_PERCENT", 100),       // Share of new traces recorded, traces sampled by the caller are always kept
+		AlertTopicArn:                  env.getEnv("ALERT_TOPIC_ARN", ""),                    // SNS topic paged on sustained errors or throttling, empty disables alerts
+		AlertWindowSeconds:             env.getEnvAsInt("ALERT_WINDOW_SECONDS", 300),         // Responses are counted per window of this length
+		AlertMinRequests:               env.getEnvAsInt("ALERT_MIN_REQUESTS", 20),            // Windows with fewer responses never alert
+		AlertErrorRatePercent:          env.getEnvAsInt("ALERT_ERROR_RATE_PERCENT", 10),      // Share of 5xx responses in a window that alerts, 0 disables the alert
+		AlertThrottleRatePercent:       env.getEnvAsInt("ALERT_THROTTLE_RATE_PERCENT", 25),   // Share of 429 responses in a window that alerts, 0 disables the alert
+		AlertCooldownSeconds:           env.getEnvAsInt("ALERT_COOLDOWN_SECONDS", 1800),      // An alert is not repeated within this time
+		EventBusName:                   env.getEnv("EVENT_BUS_NAME", ""),                     // EventBridge bus of document lifecycle events, empty disables the events
+		PIIDetectionEnabled:            env.getEnvAsBool("PII_DETECTION_ENABLED", false),     // Also redact names and addresses found by Amazon Comprehend in logged English questions
+		MaintenanceMode: NewMaintenanceMode(MaintenanceStatus{
+			Enabled:           env.getEnvAsBool("MAINTENANCE_MODE", false),
+			MessageTh:         env.getEnv("MAINTENANCE_MESSAGE_TH", ""),
+			MessageEn:         env.getEnv("MAINTENANCE_MESSAGE_EN", ""),
+			RetryAfterSeconds: env.getEnvAsInt("MAINTENANCE_RETRY_AFTER", 0),
+		}),
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config.LiveSettings = NewLiveSettings(settings, parameterSource, time.Duration(config.ConfigRefreshSeconds)*time.Second)
+
+	return config, nil
+}
+
+func (c *Config) Validate() error {
+	if c.AWSRegion == "" {
+		return fmt.Errorf("AWS_REGION is required")
+	}
+	if c.EmbeddingModelId == "" {
+		return fmt.Errorf("BEDROCK_EMBEDDING_MODEL is required")
+	}
+	if len(EnabledKnowledgeBases(c.KnowledgeBases)) == 0 {
+		return fmt.Errorf("at least one enabled knowledge base is required in BEDROCK_KB_ID or KNOWLEDGE_BASES")
+	}
+	if c.GenerativeModelId == "" {
+		return fmt.Errorf("BEDROCK_GENERATIVE_MODEL is required")
+	}
+	if c.MaxQuestionLength <= 0 {
+		return fmt.Errorf("MAX_QUESTION_LENGTH must be positive")
+	}
+	if c.MaxRequestBodyBytes < 0 {
+		return fmt.Errorf("MAX_REQUEST_BODY_BYTES must be non-negative")
+	}
+	if c.ServerReadTimeoutSeconds < 0 || c.ServerWriteTimeoutSeconds < 0 || c.ServerIdleTimeoutSeconds < 0 || c.RequestTimeoutSeconds < 0 {
+		return fmt.Errorf("SERVER_READ_TIMEOUT_SECONDS, SERVER_WRITE_TIMEOUT_SECONDS, SERVER_IDLE_TIMEOUT_SECONDS and REQUEST_TIMEOUT_SECONDS must be non-negative")
+	}
+	// A write timeout cutting the response off before the deadline would drop the error response
+	if c.ServerWriteTimeoutSeconds > 0 && c.RequestTimeoutSeconds > 0 && c.ServerWriteTimeoutSeconds <= c.RequestTimeoutSeconds {
+		return fmt.Errorf("SERVER_WRITE_TIMEOUT_SECONDS must be greater than REQUEST_TIMEOUT_SECONDS")
+	}
+	if c.RetryAttempts < 0 {
+		return fmt.Errorf("RETRY_ATTEMPTS must be non-negative")
+	}
+	if c.SessionMaxTokens > 0 && c.SessionWindowMinutes <= 0 {
+		return fmt.Errorf("SESSION_WINDOW_MINUTES must be positive when SESSION_MAX_TOKENS is set")
+	}
+	if c.DeletedDocumentRetentionDays < 0 {
+		return fmt.Errorf("DELETED_DOCUMENT_RETENTION_DAYS must be non-negative")
+	}
+	if c.FeedbackTable != "" && c.FeedbackRetentionDays <= 0 {
+		return fmt.Errorf("FEEDBACK_RETENTION_DAYS must be positive when FEEDBACK_TABLE is set")
+	}
+	if c.HistoryTable != "" && c.HistoryRetentionDays <= 0 {
+		return fmt.Errorf("CONVERSATION_HISTORY_RETENTION_DAYS must be positive when CONVERSATION_HISTORY_TABLE is set")
+	}
+	if c.AuditBucket != "" && c.AuditRetentionDays <= 0 {
+		return fmt.Errorf("AUDIT_RETENTION_DAYS must be positive when AUDIT_BUCKET is set")
+	}
+	if c.EvalBucket != "" && (c.EvalDatasetKey == "" || c.EvalConcurrency <= 0) {
+		return fmt.Errorf("EVAL_DATASET_KEY and a positive EVAL_CONCURRENCY are required when EVAL_BUCKET is set")
+	}
+	if c.SynonymsBucket != "" && c.SynonymsKey == "" {
+		return fmt.Errorf("SYNONYMS_KEY is required when SYNONYMS_BUCKET is set")
+	}
+	if c.UsageTable != "" && c.UsageRetentionDays <= 0 {
+		return fmt.Errorf("USAGE_RETENTION_DAYS must be positive when USAGE_TABLE is set")
+	}
+	if c.ExperimentTable != "" && c.ExperimentRetentionDays <= 0 {
+		return fmt.Errorf("EXPERIMENT_RETENTION_DAYS must be positive when EXPERIMENT_TABLE is set")
+	}
+	if c.AuthRequired && c.AuthJwksUrl == "" {
+		return fmt.Errorf("AUTH_JWKS_URL is required when AUTH_REQUIRED is set")
+	}
+	if c.ApiKeyRequired && c.ApiKeyTable == "" {
+		return fmt.Errorf("API_KEY_TABLE is required when API_KEY_REQUIRED is set")
+	}
+	switch c.TranslationProvider {
+	case "", "translate", "bedrock", "off": // Empty disables translation, like "off"
+	default:
+		return fmt.Errorf("TRANSLATION_PROVIDER must be translate, bedrock or off")
+	}
+	switch c.DocumentContentSource {
+	case "", "knowledge-base", "s3": // Empty uses the retrieved chunks, like "knowledge-base"
+	default:
+		return fmt.Errorf("DOCUMENT_CONTENT_SOURCE must be knowledge-base or s3")
+	}
+	switch c.DocumentLinkMode {
+	case "", "public", "presigned": // Empty links to the bucket URL, like "public"
+	default:
+		return fmt.Errorf("DOCUMENT_LINK_MODE must be public or presigned")
+	}
+	// SigV4 pre-signed URLs are valid for at most 7 days
+	if c.DocumentLinkMode == "presigned" && (c.DocumentLinkExpirySeconds <= 0 || c.DocumentLinkExpirySeconds > 604800) {
+		return fmt.Errorf("DOCUMENT_LINK_EXPIRY_SECONDS must be between 1 and 604800")
+	}
+	if c.ScannedPdfMinTextCharacters < 0 {
+		return fmt.Errorf("SCANNED_PDF_MIN_TEXT_CHARACTERS must be non-negative")
+	}
+	if c.ScannedPdfSyncDataSource != "" {
+		if knowledgeBaseId, dataSourceId, ok := strings.Cut(c.ScannedPdfSyncDataSource, "/"); !ok || knowledgeBaseId == "" || dataSourceId == "" {
+			return fmt.Errorf("SCANNED_PDF_SYNC_DATA_SOURCE must be <knowledge base ID>/<data source ID>")
+		}
+	}
+	switch c.RetrievalSearchType {
+	case "", "HYBRID", "SEMANTIC":
+	default:
+		return fmt.Errorf("RETRIEVAL_SEARCH_TYPE must be HYBRID or SEMANTIC")
+	}
+	if c.RetrievalResults < 0 || c.RetrievalResults > 100 {
+		return fmt.Errorf("RETRIEVAL_RESULTS must be between 1 and 100")
+	}
+	if c.RetrievalMinScore < 0 || c.RetrievalMinScore > 1 {
+		return fmt.Errorf("RETRIEVAL_MIN_SCORE must be between 0 and 1")
+	}
+	if c.KBGenerationTemperature != nil && (*c.KBGenerationTemperature < 0 || *c.KBGenerationTemperature > 1) {
+		return fmt.Errorf("KB_GENERATION_TEMPERATURE must be between 0 and 1")
+	}
+	if c.KBGenerationTopP != nil && (*c.KBGenerationTopP < 0 || *c.KBGenerationTopP > 1) {
+		return fmt.Errorf("KB_GENERATION_TOP_P must be between 0 and 1")
+	}
+	if c.KBGenerationMaxTokens < 0 {
+		return fmt.Errorf("KB_GENERATION_MAX_TOKENS must be non-negative")
+	}
+	if c.KBPromptTemplate != "" && !strings.Contains(c.KBPromptTemplate, "$search_results$") {
+		return fmt.Errorf("KB_PROMPT_TEMPLATE must contain $search_results$")
+	}
+	if c.KBMaxConcurrentQueries < 0 || c.KBFailureBudget < 0 || c.KBSuspendSeconds < 0 || c.KBQueryTimeoutMs < 0 {
+		return fmt.Errorf("KB_MAX_CONCURRENT_QUERIES, KB_FAILURE_BUDGET, KB_SUSPEND_SECONDS and KB_QUERY_TIMEOUT_MS must be non-negative")
+	}
+	if c.AnswerCacheTTLSeconds < 0 {
+		return fmt.Errorf("ANSWER_CACHE_TTL_SECONDS must be non-negative")
+	}
+	if c.AnswerCacheMaxEntries < 0 {
+		return fmt.Errorf("ANSWER_CACHE_MAX_ENTRIES must be non-negative")
+	}
+	if c.EmbeddingCacheTTLSeconds < 0 || c.EmbeddingCacheMaxEntries < 0 {
+		return fmt.Errorf("EMBEDDING_CACHE_TTL_SECONDS and EMBEDDING_CACHE_MAX_ENTRIES must be non-negative")
+	}
+	switch c.EmbeddingDimensions {
+	case 0, 256, 512, 1024:
+	default:
+		return fmt.Errorf("EMBEDDING_DIMENSIONS must be 256, 512 or 1024")
+	}
+	if c.IdempotencyTTLSeconds < 0 {
+		return fmt.Errorf("IDEMPOTENCY_TTL_SECONDS must be non-negative")
+	}
+	if c.QuestionJobQueueUrl != "" && c.JobsTable == "" {
+		return fmt.Errorf("JOBS_TABLE is required with QUESTION_JOB_QUEUE_URL")
+	}
+	if c.JobsTable != "" && c.JobTTLSeconds <= 0 {
+		return fmt.Errorf("JOB_TTL_SECONDS must be positive")
+	}
+	if c.QuestionJobQueueUrl != "" && c.QuestionJobTTLSeconds <= 0 {
+		return fmt.Errorf("QUESTION_JOB_TTL_SECONDS must be positive")
+	}
+	if c.ChatPingSeconds < 0 {
+		return fmt.Errorf("CHAT_PING_SECONDS must be non-negative")
+	}
+	if c.SummaryWorkflowArn != "" && c.SummaryJobsTable == "" {
+		return fmt.Errorf("SUMMARY_JOBS_TABLE is required with SUMMARY_WORKFLOW_ARN")
+	}
+	if c.SummaryWorkflowArn != "" && c.SummaryJobTTLSeconds <= 0 {
+		return fmt.Errorf("SUMMARY_JOB_TTL_SECONDS must be positive")
+	}
+	if c.SummaryJobMaxDocuments < 0 {
+		return fmt.Errorf("SUMMARY_JOB_MAX_DOCUMENTS must be non-negative")
+	}
+	if c.SummaryJobChunkSize < 0 {
+		return fmt.Errorf("SUMMARY_JOB_CHUNK_SIZE must be non-negative")
+	}
+	switch c.TracingExporter {
+	case "", "otlp", "xray":
+	default:
+		return fmt.Errorf("TRACING_EXPORTER must be otlp or xray")
+	}
+	if c.TracingSamplePercent < 0 || c.TracingSamplePercent > 100 {
+		return fmt.Errorf("TRACING_SAMPLE_PERCENT must be between 0 and 100")
+	}
+	if c.AlertTopicArn != "" && c.AlertWindowSeconds <= 0 {
+		return fmt.Errorf("ALERT_WINDOW_SECONDS must be positive")
+	}
+	if c.AlertMinRequests < 0 || c.AlertCooldownSeconds < 0 {
+		return fmt.Errorf("ALERT_MIN_REQUESTS and ALERT_COOLDOWN_SECONDS must be non-negative")
+	}
+	if c.AlertErrorRatePercent < 0 || c.AlertErrorRatePercent > 100 || c.AlertThrottleRatePercent < 0 || c.AlertThrottleRatePercent > 100 {
+		return fmt.Errorf("ALERT_ERROR_RATE_PERCENT and ALERT_THROTTLE_RATE_PERCENT must be between 0 and 100")
+	}
+	if c.FaultInjectionEnabled && (c.Environment == "prod" || c.Environment == "production") {
+		return fmt.Errorf("FAULT_INJECTION_ENABLED cannot be set in the %s environment", c.Environment)
+	}
+	if c.BedrockMaxConcurrency < 0 {
+		return fmt.Errorf("BEDROCK_MAX_CONCURRENCY must be non-negative")
+	}
+	if (c.BedrockMaxConcurrency > 0 || len(c.BedrockOperationLimits) > 0) && c.BedrockQueueTimeoutMs <= 0 {
+		return fmt.Errorf("BEDROCK_QUEUE_TIMEOUT_MS must be positive with a Bedrock concurrency limit")
+	}
+	if c.BedrockAdaptiveThrottle && (c.BedrockMinRate <= 0 || c.BedrockMaxRate < c.BedrockMinRate) {
+		return fmt.Errorf("BEDROCK_MIN_RATE must be positive and at most BEDROCK_MAX_RATE")
+	}
+	return nil
+}
+
+// environment reads configuration values from SSM parameters, falling back to env vars for
+// values without a parameter
+type environment struct {
+	parameters map[string]string
+}
+
+func (e environment) lookup(key string) string {
+	if value, ok := e.parameters[key]; ok {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+func (e environment) getEnv(key, defaultValue string) string {
+	if value := e.lookup(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func (e environment) getEnvAsInt(key string, defaultValue int) int {
+	valueStr := e.lookup(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsList reads a comma-separated list, ignoring empty entries
+func (e environment) getEnvAsList(key string, defaultValue []string) []string {
+	valueStr := e.lookup(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	var values []string
+	for _, value := range strings.Split(valueStr, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (e environment) getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := e.lookup(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsOptionalFloat reads a number, nil when it is unset or invalid
+func (e environment) getEnvAsOptionalFloat(key string) *float64 {
+	valueStr := e.lookup(key)
+	if valueStr == "" {
+		return nil
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+func (e environment) getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := e.lookup(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
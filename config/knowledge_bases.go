@@ -0,0 +1,166 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// defaultKnowledgeBaseIds are searched when neither KNOWLEDGE_BASES nor BEDROCK_KB_ID is set
+var defaultKnowledgeBaseIds = []string{"ZHYAWGPBRS", "I2XCL5FZAQ", "CC46VWUAVL"}
+
+// KnowledgeBase is one knowledge base questions are answered from. When the answers of
+// several knowledge bases conflict, the one with the higher weight wins.
+type KnowledgeBase struct {
+	Id      string  `json:"id"`
+	Label   string  `json:"label"`   // Name the synthesis model sees, defaults to the ID
+	Weight  float64 `json:"weight"`  // Priority of its answers, defaults to 1
+	Enabled bool    `json:"enabled"` // Disabled knowledge bases are not searched, defaults to true
+	// SystemInstructions replace the question search instructions for this knowledge base,
+	// e.g. to have a policy knowledge base quote exact numbers. Empty uses the global ones.
+	SystemInstructions string `json:"systemInstructions"`
+	// Intents are the question topics the knowledge base is searched for when intent routing
+	// is on, e.g. "hr". Knowledge bases without intents are searched for every question.
+	Intents []string `json:"intents"`
+	// TimeoutMs bounds its queries in answers from several knowledge bases, which go on
+	// without it when it is slower. 0 uses KB_QUERY_TIMEOUT_MS.
+	TimeoutMs int `json:"timeoutMs"`
+}
+
+func (k KnowledgeBase) equal(other KnowledgeBase) bool {
+	return k.Id == other.Id && k.Label == other.Label && k.Weight == other.Weight && k.Enabled == other.Enabled &&
+		k.SystemInstructions == other.SystemInstructions && slices.Equal(k.Intents, other.Intents) && k.TimeoutMs == other.TimeoutMs
+}
+
+// parseKnowledgeBases reads KNOWLEDGE_BASES, a JSON list such as
+// [{"id": "ZHYAWGPBRS", "label": "HR", "weight": 2}, {"id": "CC46VWUAVL", "label": "Archive", "weight": 0.5}]
+func parseKnowledgeBases(value string) ([]KnowledgeBase, error) {
+	var entries []struct {
+		Id                 string   `json:"id"`
+		Label              string   `json:"label"`
+		Weight             *float64 `json:"weight"`
+		Enabled            *bool    `json:"enabled"`
+		SystemInstructions string   `json:"systemInstructions"`
+		Intents            []string `json:"intents"`
+		TimeoutMs          int      `json:"timeoutMs"`
+	}
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil, fmt.Errorf("invalid KNOWLEDGE_BASES: %w", err)
+	}
+
+	knowledgeBases := make([]KnowledgeBase, 0, len(entries))
+	for _, entry := range entries {
+		knowledgeBase := KnowledgeBase{
+			Id:                 entry.Id,
+			Label:              entry.Label,
+			Weight:             1,
+			Enabled:            true,
+			SystemInstructions: strings.TrimSpace(entry.SystemInstructions),
+			TimeoutMs:          entry.TimeoutMs,
+		}
+		for _, intent := range entry.Intents {
+			if intent = strings.ToLower(strings.TrimSpace(intent)); intent != "" {
+				knowledgeBase.Intents = append(knowledgeBase.Intents, intent)
+			}
+		}
+		if entry.Weight != nil {
+			knowledgeBase.Weight = *entry.Weight
+		}
+		if entry.Enabled != nil {
+			knowledgeBase.Enabled = *entry.Enabled
+		}
+		knowledgeBases = append(knowledgeBases, knowledgeBase)
+	}
+	if err := validateKnowledgeBases(knowledgeBases); err != nil {
+		return nil, fmt.Errorf("invalid KNOWLEDGE_BASES: %w", err)
+	}
+	return withDefaultLabels(knowledgeBases), nil
+}
+
+// knowledgeBasesFromIds gives every ID of BEDROCK_KB_ID the same weight
+func knowledgeBasesFromIds(ids []string) []KnowledgeBase {
+	knowledgeBases := make([]KnowledgeBase, 0, len(ids))
+	for _, id := range ids {
+		knowledgeBases = append(knowledgeBases, KnowledgeBase{Id: id, Weight: 1, Enabled: true})
+	}
+	return withDefaultLabels(knowledgeBases)
+}
+
+func withDefaultLabels(knowledgeBases []KnowledgeBase) []KnowledgeBase {
+	for i := range knowledgeBases {
+		if knowledgeBases[i].Label == "" {
+			knowledgeBases[i].Label = knowledgeBases[i].Id
+		}
+	}
+	return knowledgeBases
+}
+
+func validateKnowledgeBases(knowledgeBases []KnowledgeBase) error {
+	seen := map[string]bool{}
+	for _, knowledgeBase := range knowledgeBases {
+		if knowledgeBase.Id == "" {
+			return fmt.Errorf("every knowledge base needs an id")
+		}
+		if seen[knowledgeBase.Id] {
+			return fmt.Errorf("knowledge base %s is listed twice", knowledgeBase.Id)
+		}
+		seen[knowledgeBase.Id] = true
+		if knowledgeBase.Weight <= 0 {
+			return fmt.Errorf("weight of knowledge base %s must be positive", knowledgeBase.Id)
+		}
+		if knowledgeBase.TimeoutMs < 0 {
+			return fmt.Errorf("timeoutMs of knowledge base %s must be non-negative", knowledgeBase.Id)
+		}
+	}
+	if len(EnabledKnowledgeBases(knowledgeBases)) == 0 {
+		return fmt.Errorf("at least one enabled knowledge base is required")
+	}
+	return nil
+}
+
+// EnabledKnowledgeBases returns the enabled knowledge bases, highest weight first and in
+// configuration order for equal weights
+func EnabledKnowledgeBases(knowledgeBases []KnowledgeBase) []KnowledgeBase {
+	var enabled []KnowledgeBase
+	for _, knowledgeBase := range knowledgeBases {
+		if knowledgeBase.Enabled {
+			enabled = append(enabled, knowledgeBase)
+		}
+	}
+	sort.SliceStable(enabled, func(i, j int) bool { return enabled[i].Weight > enabled[j].Weight })
+	return enabled
+}
+
+// KnowledgeBasesForIntent returns the knowledge bases to search for a question of an intent:
+// those listing the intent and those without intents, in order. Without an intent, or when
+// no knowledge base is for it, all of them are searched.
+func KnowledgeBasesForIntent(knowledgeBases []KnowledgeBase, intent string) []KnowledgeBase {
+	if intent == "" {
+		return knowledgeBases
+	}
+	var routed []KnowledgeBase
+	matched := false
+	for _, knowledgeBase := range knowledgeBases {
+		if len(knowledgeBase.Intents) == 0 {
+			routed = append(routed, knowledgeBase)
+		} else if slices.Contains(knowledgeBase.Intents, intent) {
+			routed = append(routed, knowledgeBase)
+			matched = true
+		}
+	}
+	if !matched {
+		return knowledgeBases
+	}
+	return routed
+}
+
+// knowledgeBaseIds returns the IDs of the knowledge bases in order
+func knowledgeBaseIds(knowledgeBases []KnowledgeBase) []string {
+	ids := make([]string, 0, len(knowledgeBases))
+	for _, knowledgeBase := range knowledgeBases {
+		ids = append(ids, knowledgeBase.Id)
+	}
+	return ids
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseKnowledgeBases(t *testing.T) {
+	knowledgeBases, err := parseKnowledgeBases(`[
+		{"id": "ARCHIVE", "label": "Archive", "weight": 0.5},
+		{"id": "PRODUCTS"},
+		{"id": "HR", "label": "HR", "weight": 2},
+		{"id": "OLD", "enabled": false}
+	]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !knowledgeBases[1].equal(KnowledgeBase{Id: "PRODUCTS", Label: "PRODUCTS", Weight: 1, Enabled: true}) {
+		t.Errorf("expected the defaults for an ID only entry, got %+v", knowledgeBases[1])
+	}
+
+	ids := knowledgeBaseIds(EnabledKnowledgeBases(knowledgeBases))
+	if len(ids) != 3 || ids[0] != "HR" || ids[1] != "PRODUCTS" || ids[2] != "ARCHIVE" {
+		t.Errorf("expected the enabled knowledge bases by weight, got %v", ids)
+	}
+}
+
+func TestKnowledgeBasesForIntent(t *testing.T) {
+	knowledgeBases, err := parseKnowledgeBases(`[
+		{"id": "HR", "intents": [" HR ", "benefits"]},
+		{"id": "CREDIT", "intents": ["credit"]},
+		{"id": "FAQ"}
+	]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if intents := knowledgeBases[0].Intents; len(intents) != 2 || intents[0] != "hr" {
+		t.Errorf("expected trimmed lower-case intents, got %q", intents)
+	}
+
+	tests := []struct {
+		intent string
+		want   []string
+	}{
+		{"hr", []string{"HR", "FAQ"}},
+		{"credit", []string{"CREDIT", "FAQ"}},
+		{"it", []string{"HR", "CREDIT", "FAQ"}}, // No knowledge base for the intent
+		{"", []string{"HR", "CREDIT", "FAQ"}},
+	}
+	for _, tt := range tests {
+		ids := knowledgeBaseIds(KnowledgeBasesForIntent(knowledgeBases, tt.intent))
+		if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("intent %q: expected %v, got %v", tt.intent, tt.want, ids)
+		}
+	}
+}
+
+func TestParseKnowledgeBases_Invalid(t *testing.T) {
+	for _, value := range []string{
+		`{"id": "HR"}`,
+		`[{"label": "HR"}]`,
+		`[{"id": "HR"}, {"id": "HR"}]`,
+		`[{"id": "HR", "weight": 0}]`,
+		`[{"id": "HR", "enabled": false}]`,
+	} {
+		if _, err := parseKnowledgeBases(value); err == nil {
+			t.Errorf("expected %s to be rejected", value)
+		}
+	}
+}
+
+func TestSettingsFrom_KnowledgeBases(t *testing.T) {
+	t.Setenv("BEDROCK_KB_ID", "KB1,KB2")
+	settings, err := settingsFrom(environment{})
+	if err != nil || len(settings.KnowledgeBaseIds()) != 2 || settings.KnowledgeBases[0].Weight != 1 {
+		t.Fatalf("expected BEDROCK_KB_ID with equal weights, got %+v %v", settings.KnowledgeBases, err)
+	}
+
+	t.Setenv("KNOWLEDGE_BASES", `[{"id": "KB3", "label": "HR"}]`)
+	settings, err = settingsFrom(environment{})
+	if err != nil || len(settings.KnowledgeBases) != 1 || settings.KnowledgeBases[0].Label != "HR" {
+		t.Errorf("expected KNOWLEDGE_BASES to replace BEDROCK_KB_ID, got %+v %v", settings.KnowledgeBases, err)
+	}
+
+	t.Setenv("KNOWLEDGE_BASES", `[{"id": "KB3", "weight": -1}]`)
+	if _, err := settingsFrom(environment{}); err == nil {
+		t.Error("expected invalid KNOWLEDGE_BASES to fail")
+	}
+}
+
+func TestQuestionSearchInstructionsFor(t *testing.T) {
+	knowledgeBases, err := parseKnowledgeBases(`[
+		{"id": "CREDIT", "systemInstructions": "  Quote exact numbers from the policy.  "},
+		{"id": "FAQ"}
+	]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	live := NewLiveSettings(Settings{
+		KnowledgeBases:               knowledgeBases,
+		QuestionSearchInstructions:   "Answer briefly.",
+		QuestionSearchInstructionsEn: "Answer briefly in English.",
+		CandidateInstructions:        "Candidate.",
+	}, nil, 0)
+
+	if instructions := live.QuestionSearchInstructionsFor(knowledgeBases[0], "en"); instructions != "Quote exact numbers from the policy." {
+		t.Errorf("expected the instructions of the knowledge base, got %q", instructions)
+	}
+	if instructions := live.QuestionSearchInstructionsFor(knowledgeBases[1], "th"); instructions != "Answer briefly." {
+		t.Errorf("expected the global instructions without own ones, got %q", instructions)
+	}
+	if instructions := live.QuestionSearchInstructionsFor(knowledgeBases[1], "en"); instructions != "Answer briefly in English." {
+		t.Errorf("expected the English instructions for an English question, got %q", instructions)
+	}
+	if instructions := live.CandidateInstructionsFor(knowledgeBases[0], "en"); instructions != "Candidate." {
+		t.Errorf("expected the candidate instructions for every knowledge base, got %q", instructions)
+	}
+}
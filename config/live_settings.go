@@ -0,0 +1,303 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/utils"
+)
+
+// reloadTimeout bounds a reload triggered from a request path
+const reloadTimeout = 2 * time.Second
+
+// Settings are the knowledge base IDs, model IDs and prompts of the answer pipeline, the
+// configuration CONFIG_SSM_PREFIX parameters can change without a redeploy
+type Settings struct {
+	KnowledgeBases                 []KnowledgeBase
+	EmbeddingModelId               string
+	GenerativeModelId              string
+	CandidateModelId               string
+	FallbackModelIds               []string // Tried in order when the generative model fails
+	QuestionSearchInstructions     string
+	QuestionSearchInstructionsEn   string // For English questions
+	DocumentComparisonInstructions string
+	DocumentSummaryInstructions    string
+	CandidateInstructions          string
+	AnswerDiffInstructions         string
+	AnswerJudgeInstructions        string
+	RelatedQuestionsInstructions   string
+	SynthesisInstructions          string // Rules for merging the answers of several knowledge bases
+}
+
+// PromptNames are the env vars of the prompts among the settings, which the prompt API can
+// change through their CONFIG_SSM_PREFIX parameters
+var PromptNames = []string{
+	"QUESTION_SEARCH_INSTRUCTIONS",
+	"ENGLISH_QUESTION_SEARCH_INSTRUCTIONS",
+	"SYNTHESIS_INSTRUCTIONS",
+	"DOCUMENT_COMPARISON_INSTRUCTIONS",
+	"DOCUMENT_SUMMARY_INSTRUCTIONS",
+	"CANDIDATE_INSTRUCTIONS",
+	"ANSWER_DIFF_INSTRUCTIONS",
+	"ANSWER_JUDGE_INSTRUCTIONS",
+	"RELATED_QUESTIONS_INSTRUCTIONS",
+}
+
+// settingsFrom reads the settings, each from its SSM parameter or env var, falling back to
+// the built-in knowledge bases, models and embedded prompts. KNOWLEDGE_BASES takes precedence
+// over the plain ID list of BEDROCK_KB_ID.
+func settingsFrom(env environment) (Settings, error) {
+	settings := Settings{
+		KnowledgeBases:                 knowledgeBasesFromIds(env.getEnvAsList("BEDROCK_KB_ID", defaultKnowledgeBaseIds)), // Multiple Knowledge Base IDs
+		EmbeddingModelId:               env.getEnv("BEDROCK_EMBEDDING_MODEL", "amazon.titan-embed-text-v2:0"),
+		GenerativeModelId:              env.getEnv("BEDROCK_GENERATIVE_MODEL", "anthropic.claude-haiku-4-5-20251001-v1:0"), // Claude 3.5 Haiku
+		CandidateModelId:               env.getEnv("CANDIDATE_GENERATIVE_MODEL", ""),                                       // Defaults to BEDROCK_GENERATIVE_MODEL
+		FallbackModelIds:               env.getEnvAsList("BEDROCK_FALLBACK_MODELS", nil),
+		QuestionSearchInstructions:     env.getEnv("QUESTION_SEARCH_INSTRUCTIONS", strings.TrimSpace(questionSearchInstructions)),
+		QuestionSearchInstructionsEn:   env.getEnv("ENGLISH_QUESTION_SEARCH_INSTRUCTIONS", strings.TrimSpace(questionSearchInstructionsEnglish)),
+		DocumentComparisonInstructions: env.getEnv("DOCUMENT_COMPARISON_INSTRUCTIONS", strings.TrimSpace(documentComparisonInstructions)),
+		DocumentSummaryInstructions:    env.getEnv("DOCUMENT_SUMMARY_INSTRUCTIONS", strings.TrimSpace(documentSummaryInstructions)),
+		CandidateInstructions:          env.getEnv("CANDIDATE_INSTRUCTIONS", strings.TrimSpace(questionSearchCandidateInstructions)),
+		AnswerDiffInstructions:         env.getEnv("ANSWER_DIFF_INSTRUCTIONS", strings.TrimSpace(answerDiffInstructions)),
+		AnswerJudgeInstructions:        env.getEnv("ANSWER_JUDGE_INSTRUCTIONS", strings.TrimSpace(answerJudgeInstructions)),
+		RelatedQuestionsInstructions:   env.getEnv("RELATED_QUESTIONS_INSTRUCTIONS", strings.TrimSpace(relatedQuestionsInstructions)),
+		SynthesisInstructions:          env.getEnv("SYNTHESIS_INSTRUCTIONS", strings.TrimSpace(answerSynthesisInstructions)),
+	}
+	if settings.CandidateModelId == "" {
+		settings.CandidateModelId = settings.GenerativeModelId
+	}
+	if value := env.getEnv("KNOWLEDGE_BASES", ""); value != "" {
+		knowledgeBases, err := parseKnowledgeBases(value)
+		if err != nil {
+			return settings, err
+		}
+		settings.KnowledgeBases = knowledgeBases
+	}
+	return settings, nil
+}
+
+// Prompt returns the prompt of an env var in PromptNames, false for another name
+func (s Settings) Prompt(name string) (string, bool) {
+	switch name {
+	case "QUESTION_SEARCH_INSTRUCTIONS":
+		return s.QuestionSearchInstructions, true
+	case "ENGLISH_QUESTION_SEARCH_INSTRUCTIONS":
+		return s.QuestionSearchInstructionsEn, true
+	case "SYNTHESIS_INSTRUCTIONS":
+		return s.SynthesisInstructions, true
+	case "DOCUMENT_COMPARISON_INSTRUCTIONS":
+		return s.DocumentComparisonInstructions, true
+	case "DOCUMENT_SUMMARY_INSTRUCTIONS":
+		return s.DocumentSummaryInstructions, true
+	case "CANDIDATE_INSTRUCTIONS":
+		return s.CandidateInstructions, true
+	case "ANSWER_DIFF_INSTRUCTIONS":
+		return s.AnswerDiffInstructions, true
+	case "ANSWER_JUDGE_INSTRUCTIONS":
+		return s.AnswerJudgeInstructions, true
+	case "RELATED_QUESTIONS_INSTRUCTIONS":
+		return s.RelatedQuestionsInstructions, true
+	}
+	return "", false
+}
+
+// EnabledKnowledgeBases returns the knowledge bases to search, highest weight first
+func (s Settings) EnabledKnowledgeBases() []KnowledgeBase {
+	return EnabledKnowledgeBases(s.KnowledgeBases)
+}
+
+// KnowledgeBaseIds returns the IDs of the knowledge bases to search, highest weight first
+func (s Settings) KnowledgeBaseIds() []string {
+	return knowledgeBaseIds(s.EnabledKnowledgeBases())
+}
+
+func (s Settings) validate() error {
+	if len(s.EnabledKnowledgeBases()) == 0 {
+		return fmt.Errorf("at least one enabled knowledge base is required in BEDROCK_KB_ID or KNOWLEDGE_BASES")
+	}
+	if s.EmbeddingModelId == "" {
+		return fmt.Errorf("BEDROCK_EMBEDDING_MODEL is required")
+	}
+	if s.GenerativeModelId == "" {
+		return fmt.Errorf("BEDROCK_GENERATIVE_MODEL is required")
+	}
+	return nil
+}
+
+// LiveSettings holds the current Settings. With a parameter source they are reloaded once
+// older than the refresh interval, so the Bedrock clients, which read them on every call,
+// pick up changed SSM parameters. A failed or invalid reload keeps the current settings until
+// the next interval.
+type LiveSettings struct {
+	source   ParameterSource
+	ttl      time.Duration
+	mu       sync.RWMutex
+	settings Settings
+	loadedAt time.Time
+}
+
+// NewLiveSettings holds settings loaded from source, nil for settings that never change
+func NewLiveSettings(settings Settings, source ParameterSource, ttl time.Duration) *LiveSettings {
+	return &LiveSettings{
+		source:   source,
+		ttl:      ttl,
+		settings: settings,
+		loadedAt: time.Now(),
+	}
+}
+
+// Get returns the current settings
+func (l *LiveSettings) Get() Settings {
+	l.reloadIfStale()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.settings
+}
+
+// The getters below read a single current setting, for clients that take it as a func
+
+func (l *LiveSettings) KnowledgeBases() []KnowledgeBase {
+	return l.Get().EnabledKnowledgeBases()
+}
+
+func (l *LiveSettings) KnowledgeBaseIds() []string {
+	return l.Get().KnowledgeBaseIds()
+}
+
+func (l *LiveSettings) EmbeddingModelId() string {
+	return l.Get().EmbeddingModelId
+}
+
+func (l *LiveSettings) GenerativeModelId() string {
+	return l.Get().GenerativeModelId
+}
+
+func (l *LiveSettings) CandidateModelId() string {
+	return l.Get().CandidateModelId
+}
+
+func (l *LiveSettings) FallbackModelIds() []string {
+	return l.Get().FallbackModelIds
+}
+
+func (l *LiveSettings) QuestionSearchInstructions() string {
+	return l.Get().QuestionSearchInstructions
+}
+
+// QuestionSearchInstructionsFor returns the instructions of the knowledge base, and when it
+// has none of its own the global question search instructions for the question's language
+func (l *LiveSettings) QuestionSearchInstructionsFor(knowledgeBase KnowledgeBase, language string) string {
+	if knowledgeBase.SystemInstructions != "" {
+		return knowledgeBase.SystemInstructions
+	}
+	settings := l.Get()
+	if language == utils.LanguageEnglish && settings.QuestionSearchInstructionsEn != "" {
+		return settings.QuestionSearchInstructionsEn
+	}
+	return settings.QuestionSearchInstructions
+}
+
+func (l *LiveSettings) DocumentComparisonInstructions() string {
+	return l.Get().DocumentComparisonInstructions
+}
+
+func (l *LiveSettings) DocumentSummaryInstructions() string {
+	return l.Get().DocumentSummaryInstructions
+}
+
+func (l *LiveSettings) CandidateInstructions() string {
+	return l.Get().CandidateInstructions
+}
+
+// CandidateInstructionsFor returns the candidate instructions for every knowledge base and
+// language, so answer diffs compare the candidate prompt with each one's own instructions
+func (l *LiveSettings) CandidateInstructionsFor(KnowledgeBase, string) string {
+	return l.Get().CandidateInstructions
+}
+
+func (l *LiveSettings) AnswerDiffInstructions() string {
+	return l.Get().AnswerDiffInstructions
+}
+
+func (l *LiveSettings) AnswerJudgeInstructions() string {
+	return l.Get().AnswerJudgeInstructions
+}
+
+func (l *LiveSettings) SynthesisInstructions() string {
+	return l.Get().SynthesisInstructions
+}
+
+// Reload reads the parameters now. Settings without a parameter fall back to their env var.
+func (l *LiveSettings) Reload(ctx context.Context) error {
+	if l.source == nil {
+		return nil
+	}
+
+	parameters, err := l.source.Load(ctx)
+	var settings Settings
+	if err == nil {
+		settings, err = settingsFrom(environment{parameters: parameters})
+	}
+	if err == nil {
+		err = settings.validate()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loadedAt = time.Now()
+	if err != nil {
+		logger.Warn("Failed to reload configuration, keeping the current settings", map[string]interface{}{
+			"source": l.source.Name(),
+			"error":  err.Error(),
+		})
+		return err
+	}
+	if !settings.equal(l.settings) {
+		logger.Info("Configuration reloaded", map[string]interface{}{
+			"source":              l.source.Name(),
+			"knowledge_base_ids":  settings.KnowledgeBaseIds(),
+			"generative_model_id": settings.GenerativeModelId,
+		})
+	}
+	l.settings = settings
+	return nil
+}
+
+func (l *LiveSettings) reloadIfStale() {
+	if l.source == nil {
+		return
+	}
+	l.mu.RLock()
+	stale := l.ttl > 0 && time.Since(l.loadedAt) > l.ttl
+	l.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reloadTimeout)
+	defer cancel()
+	l.Reload(ctx)
+}
+
+func (s Settings) equal(other Settings) bool {
+	return slices.EqualFunc(s.KnowledgeBases, other.KnowledgeBases, KnowledgeBase.equal) &&
+		s.EmbeddingModelId == other.EmbeddingModelId &&
+		s.GenerativeModelId == other.GenerativeModelId &&
+		s.CandidateModelId == other.CandidateModelId &&
+		slices.Equal(s.FallbackModelIds, other.FallbackModelIds) &&
+		s.QuestionSearchInstructions == other.QuestionSearchInstructions &&
+		s.QuestionSearchInstructionsEn == other.QuestionSearchInstructionsEn &&
+		s.DocumentComparisonInstructions == other.DocumentComparisonInstructions &&
+		s.DocumentSummaryInstructions == other.DocumentSummaryInstructions &&
+		s.CandidateInstructions == other.CandidateInstructions &&
+		s.AnswerDiffInstructions == other.AnswerDiffInstructions &&
+		s.AnswerJudgeInstructions == other.AnswerJudgeInstructions &&
+		s.RelatedQuestionsInstructions == other.RelatedQuestionsInstructions &&
+		s.SynthesisInstructions == other.SynthesisInstructions
+}
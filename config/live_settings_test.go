@@ -0,0 +1,105 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type staticParameterSource struct {
+	parameters map[string]string
+	err        error
+	loads      int
+}
+
+func (s *staticParameterSource) Name() string {
+	return "static"
+}
+
+func (s *staticParameterSource) Load(ctx context.Context) (map[string]string, error) {
+	s.loads++
+	return s.parameters, s.err
+}
+
+func TestLiveSettings_ReloadsParametersWithEnvFallback(t *testing.T) {
+	t.Setenv("BEDROCK_EMBEDDING_MODEL", "env-embedding")
+	source := &staticParameterSource{parameters: map[string]string{
+		"BEDROCK_KB_ID":                "KB1, KB2",
+		"BEDROCK_GENERATIVE_MODEL":     "ssm-model",
+		"BEDROCK_FALLBACK_MODELS":      "sonnet, titan",
+		"QUESTION_SEARCH_INSTRUCTIONS": "Answer briefly.",
+	}}
+	live := NewLiveSettings(Settings{KnowledgeBases: knowledgeBasesFromIds([]string{"KB0"}), GenerativeModelId: "startup-model"}, source, time.Minute)
+
+	if err := live.Reload(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	settings := live.Get()
+	if ids := settings.KnowledgeBaseIds(); len(ids) != 2 || ids[1] != "KB2" {
+		t.Errorf("expected the knowledge bases of the parameter, got %v", ids)
+	}
+	if settings.GenerativeModelId != "ssm-model" || settings.CandidateModelId != "ssm-model" {
+		t.Errorf("expected the model of the parameter for both variants, got %q and %q", settings.GenerativeModelId, settings.CandidateModelId)
+	}
+	if len(settings.FallbackModelIds) != 2 || settings.FallbackModelIds[1] != "titan" {
+		t.Errorf("expected the fallback models of the parameter, got %v", settings.FallbackModelIds)
+	}
+	if settings.EmbeddingModelId != "env-embedding" {
+		t.Errorf("expected the env var without a parameter, got %q", settings.EmbeddingModelId)
+	}
+	if settings.QuestionSearchInstructions != "Answer briefly." || settings.DocumentSummaryInstructions == "" || settings.SynthesisInstructions == "" {
+		t.Errorf("expected the parameter prompt and the embedded defaults, got %+v", settings)
+	}
+	if prompt, ok := settings.Prompt("QUESTION_SEARCH_INSTRUCTIONS"); !ok || prompt != "Answer briefly." {
+		t.Errorf("expected the prompt by its env var, got %q", prompt)
+	}
+	if live.GenerativeModelId() != "ssm-model" {
+		t.Errorf("expected the getter to read the current setting, got %q", live.GenerativeModelId())
+	}
+}
+
+func TestLiveSettings_KeepsSettingsWhenReloadFails(t *testing.T) {
+	startup := Settings{KnowledgeBases: knowledgeBasesFromIds([]string{"KB0"}), EmbeddingModelId: "embedding", GenerativeModelId: "startup-model"}
+
+	source := &staticParameterSource{err: fmt.Errorf("access denied")}
+	live := NewLiveSettings(startup, source, time.Minute)
+	if err := live.Reload(context.Background()); err == nil {
+		t.Error("expected the load error")
+	}
+	if live.Get().GenerativeModelId != "startup-model" {
+		t.Errorf("expected the startup settings after a failed load, got %+v", live.Get())
+	}
+
+	source = &staticParameterSource{parameters: map[string]string{"BEDROCK_KB_ID": " , "}}
+	live = NewLiveSettings(startup, source, time.Minute)
+	if err := live.Reload(context.Background()); err == nil {
+		t.Error("expected invalid settings to be rejected")
+	}
+	if ids := live.Get().KnowledgeBaseIds(); len(ids) != 1 || ids[0] != "KB0" {
+		t.Errorf("expected the startup knowledge bases after invalid settings, got %v", ids)
+	}
+}
+
+func TestLiveSettings_ReloadsOnceStale(t *testing.T) {
+	source := &staticParameterSource{parameters: map[string]string{"BEDROCK_GENERATIVE_MODEL": "new-model"}}
+	live := NewLiveSettings(Settings{GenerativeModelId: "startup-model"}, source, time.Hour)
+
+	if live.GenerativeModelId() != "startup-model" || source.loads != 0 {
+		t.Fatalf("expected no reload within the refresh interval, got %q after %d loads", live.GenerativeModelId(), source.loads)
+	}
+
+	live.loadedAt = time.Now().Add(-2 * time.Hour)
+	if live.GenerativeModelId() != "new-model" || source.loads != 1 {
+		t.Errorf("expected a reload once stale, got %q after %d loads", live.GenerativeModelId(), source.loads)
+	}
+}
+
+func TestConfigCurrent_WithoutLiveSettings(t *testing.T) {
+	cfg := &Config{KnowledgeBases: knowledgeBasesFromIds([]string{"KB0"}), GenerativeModelId: "model", QuestionSearchInstructions: "prompt"}
+
+	settings := cfg.Current()
+	if len(settings.KnowledgeBaseIds()) != 1 || settings.GenerativeModelId != "model" || settings.QuestionSearchInstructions != "prompt" {
+		t.Errorf("expected the loaded fields, got %+v", settings)
+	}
+}
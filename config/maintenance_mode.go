@@ -0,0 +1,60 @@
+package config
+
+import "sync"
+
+const (
+	defaultMaintenanceMessageTh  = "ระบบอยู่ระหว่างการปรับปรุง กรุณาลองใหม่อีกครั้งภายหลัง"
+	defaultMaintenanceMessageEn  = "The service is under maintenance, please try again later"
+	defaultMaintenanceRetryAfter = 1800
+)
+
+// MaintenanceStatus is a snapshot of the maintenance switch and the messages served while it is on
+type MaintenanceStatus struct {
+	Enabled           bool   `json:"enabled"`
+	MessageTh         string `json:"messageTh"`
+	MessageEn         string `json:"messageEn"`
+	RetryAfterSeconds int    `json:"retryAfterSeconds"`
+}
+
+// MaintenanceMode makes every non-health, non-admin endpoint answer 503 while enabled, e.g.
+// during planned knowledge base migrations. A nil MaintenanceMode is treated as disabled.
+type MaintenanceMode struct {
+	mu     sync.RWMutex
+	status MaintenanceStatus
+}
+
+func NewMaintenanceMode(status MaintenanceStatus) *MaintenanceMode {
+	mode := &MaintenanceMode{}
+	mode.Update(status)
+	return mode
+}
+
+func (m *MaintenanceMode) Status() MaintenanceStatus {
+	if m == nil {
+		return MaintenanceStatus{}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+func (m *MaintenanceMode) Enabled() bool {
+	return m.Status().Enabled
+}
+
+// Update replaces the status, empty messages and a non-positive Retry-After fall back to the defaults
+func (m *MaintenanceMode) Update(status MaintenanceStatus) {
+	if status.MessageTh == "" {
+		status.MessageTh = defaultMaintenanceMessageTh
+	}
+	if status.MessageEn == "" {
+		status.MessageEn = defaultMaintenanceMessageEn
+	}
+	if status.RetryAfterSeconds <= 0 {
+		status.RetryAfterSeconds = defaultMaintenanceRetryAfter
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = status
+}
@@ -0,0 +1,24 @@
+package config
+
+import "sync/atomic"
+
+// SafeMode is a process-wide operational switch for Bedrock capacity incidents. While it
+// is enabled, answers come from a single knowledge base without synthesis and document
+// versions are not compared. A nil SafeMode is treated as disabled.
+type SafeMode struct {
+	enabled atomic.Bool
+}
+
+func NewSafeMode(enabled bool) *SafeMode {
+	mode := &SafeMode{}
+	mode.enabled.Store(enabled)
+	return mode
+}
+
+func (m *SafeMode) Enabled() bool {
+	return m != nil && m.enabled.Load()
+}
+
+func (m *SafeMode) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
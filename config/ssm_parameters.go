@@ -0,0 +1,76 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// startupLoadTimeout bounds reading the parameters while the configuration is loaded
+const startupLoadTimeout = 5 * time.Second
+
+// ParameterSource supplies configuration values by env var name
+type ParameterSource interface {
+	Name() string
+	Load(ctx context.Context) (map[string]string, error)
+}
+
+// SSMParameterSource reads every parameter under a path prefix. The rest of a parameter's
+// name is the env var it replaces, e.g. /teletubpax/prod/BEDROCK_GENERATIVE_MODEL.
+type SSMParameterSource struct {
+	client *ssm.Client
+	prefix string
+}
+
+func NewSSMParameterSource(cfg aws.Config, prefix string) *SSMParameterSource {
+	return &SSMParameterSource{
+		client: ssm.NewFromConfig(cfg),
+		prefix: "/" + strings.Trim(prefix, "/") + "/",
+	}
+}
+
+func (s *SSMParameterSource) Name() string {
+	return "ssm:" + s.prefix
+}
+
+func (s *SSMParameterSource) Load(ctx context.Context) (map[string]string, error) {
+	parameters := map[string]string{}
+	paginator := ssm.NewGetParametersByPathPaginator(s.client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(s.prefix),
+		WithDecryption: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to read configuration parameters", err)
+		}
+		for _, parameter := range page.Parameters {
+			name := strings.TrimPrefix(aws.ToString(parameter.Name), s.prefix)
+			parameters[name] = aws.ToString(parameter.Value)
+		}
+	}
+	return parameters, nil
+}
+
+// newDefaultSSMParameterSource reads the parameters with the default AWS credentials, as the
+// configuration is loaded before the AWS SDK config of the clients
+func newDefaultSSMParameterSource(region string, prefix string) (*SSMParameterSource, error) {
+	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background(), awsConfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration for CONFIG_SSM_PREFIX: %w", err)
+	}
+	return NewSSMParameterSource(awsCfg, prefix), nil
+}
+
+func loadParameters(source ParameterSource) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), startupLoadTimeout)
+	defer cancel()
+	return source.Load(ctx)
+}
@@ -0,0 +1,41 @@
+package errors
+
+import (
+	stdErrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// RetryAfter returns the wait a throttled AWS call asked for in its Retry-After header,
+// given in seconds or as an HTTP date. It reports false when err carries no such hint.
+func RetryAfter(err error) (time.Duration, bool) {
+	var responseErr *smithyhttp.ResponseError
+	if !stdErrors.As(err, &responseErr) || responseErr.Response == nil || responseErr.Response.Response == nil {
+		return 0, false
+	}
+	return parseRetryAfter(responseErr.Response.Header.Get("Retry-After"), time.Now())
+}
+
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func throttledResponse(retryAfter string) error {
+	response := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	if retryAfter != "" {
+		response.Header.Set("Retry-After", retryAfter)
+	}
+	return &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: response},
+		Err:      fmt.Errorf("ThrottlingException: Too many requests"),
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	wrapped := NewThrottlingError("knowledge base service throttled", fmt.Errorf("operation error: %w", throttledResponse("3")))
+	if wait, ok := RetryAfter(wrapped); !ok || wait != 3*time.Second {
+		t.Errorf("expected the 3s hint through the wrapping errors, got %v %v", wait, ok)
+	}
+	if _, ok := RetryAfter(throttledResponse("")); ok {
+		t.Error("expected no hint without the header")
+	}
+	if _, ok := RetryAfter(NewThrottlingError("throttled", nil)); ok {
+		t.Error("expected no hint without a response")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{value: "2", expected: 2 * time.Second, ok: true},
+		{value: now.Add(5 * time.Second).Format(http.TimeFormat), expected: 5 * time.Second, ok: true},
+		{value: now.Add(-5 * time.Second).Format(http.TimeFormat), expected: 0, ok: true},
+		{value: "-1", ok: false},
+		{value: "soon", ok: false},
+	}
+
+	for _, tt := range tests {
+		wait, ok := parseRetryAfter(tt.value, now)
+		if wait != tt.expected || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v %v, want %v %v", tt.value, wait, ok, tt.expected, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package eval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/errors"
+	"teletubpax-api/logger"
+)
+
+// similarityRegression is how far the judged similarity of a case may drop below its
+// baseline before it counts as a regression, leaving room for the judge's own variance
+const similarityRegression = 0.2
+
+// Case is a golden question with the answer it is expected to get and, optionally, the
+// documents the answer is expected to cite
+type Case struct {
+	Id                string   `json:"id"`
+	Question          string   `json:"question"`
+	ExpectedAnswer    string   `json:"expectedAnswer"`
+	ExpectedDocuments []string `json:"expectedDocuments,omitempty"` // As listed in relatedDocuments
+}
+
+// Answer is what the answer pipeline returned for a case's question
+type Answer struct {
+	Answer           string
+	RelatedDocuments []string
+}
+
+// Answerer runs a question through the answer pipeline
+type Answerer func(ctx context.Context, question string) (*Answer, error)
+
+// CaseResult is the scored answer to one case
+type CaseResult struct {
+	Id               string   `json:"id"`
+	Question         string   `json:"question"`
+	Answer           string   `json:"answer,omitempty"`
+	RelatedDocuments []string `json:"relatedDocuments,omitempty"`
+	CitationMatch    *bool    `json:"citationMatch,omitempty"` // Exactly the expected documents were cited, unset without expected documents
+	Similarity       *float64 `json:"similarity,omitempty"`    // 0 to 1 as judged by the model, unset when judging failed
+	JudgeReason      string   `json:"judgeReason,omitempty"`
+	Error            string   `json:"error,omitempty"`
+	Regression       string   `json:"regression,omitempty"` // How the case got worse than its baseline
+	DurationMs       int64    `json:"durationMs"`
+}
+
+// Report is the outcome of a run, with the results in dataset order
+type Report struct {
+	RunId           string       `json:"runId"`
+	StartedAt       time.Time    `json:"startedAt"`
+	DurationMs      int64        `json:"durationMs"`
+	Cases           int          `json:"cases"`
+	Failures        int          `json:"failures"`
+	CitationMatches int          `json:"citationMatches"`
+	CitationCases   int          `json:"citationCases"` // Cases with expected documents
+	MeanSimilarity  *float64     `json:"meanSimilarity,omitempty"`
+	Regressions     int          `json:"regressions"`
+	BaselineUpdated bool         `json:"baselineUpdated"`
+	Results         []CaseResult `json:"results"`
+}
+
+// RunOptions select the cases of a run and whether it becomes the baseline
+type RunOptions struct {
+	CaseIds        []string // All cases when empty
+	UpdateBaseline bool     // Store the results as the baseline of later runs
+}
+
+type Service interface {
+	// Run answers the golden cases through answer, scores them and compares them with the
+	// baseline. The report is stored, and returned even when storing it failed.
+	Run(ctx context.Context, answer Answerer, options RunOptions) (*Report, error)
+	// Report returns a stored report, the latest when runId is empty, nil when there is none
+	Report(ctx context.Context, runId string) (*Report, error)
+}
+
+// Evaluator scores the answers to a golden dataset so prompt and model changes can be
+// checked for regressions before they reach users
+type Evaluator struct {
+	store       Store
+	judge       Judge
+	concurrency int
+}
+
+func NewEvaluator(store Store, judge Judge, concurrency int) *Evaluator {
+	return &Evaluator{
+		store:       store,
+		judge:       judge,
+		concurrency: concurrency,
+	}
+}
+
+func (e *Evaluator) Run(ctx context.Context, answer Answerer, options RunOptions) (*Report, error) {
+	log := logger.WithContext(ctx)
+	startedAt := time.Now().UTC()
+
+	cases, err := e.store.Cases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cases, err = selectCases(cases, options.CaseIds)
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := e.store.Baseline(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	report := &Report{
+		RunId:     startedAt.Format("20060102T150405Z") + "-" + hex.EncodeToString(id),
+		StartedAt: startedAt,
+		Cases:     len(cases),
+		Results:   make([]CaseResult, len(cases)),
+	}
+
+	concurrency := e.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, c := range cases {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, c Case) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			report.Results[i] = e.runCase(ctx, answer, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	var similarities []float64
+	for i := range report.Results {
+		result := &report.Results[i]
+		if previous, ok := baseline[result.Id]; ok {
+			result.Regression = regression(previous, *result)
+		}
+		if result.Error != "" {
+			report.Failures++
+		}
+		if result.CitationMatch != nil {
+			report.CitationCases++
+			if *result.CitationMatch {
+				report.CitationMatches++
+			}
+		}
+		if result.Similarity != nil {
+			similarities = append(similarities, *result.Similarity)
+		}
+		if result.Regression != "" {
+			report.Regressions++
+		}
+	}
+	if len(similarities) > 0 {
+		var sum float64
+		for _, similarity := range similarities {
+			sum += similarity
+		}
+		mean := sum / float64(len(similarities))
+		report.MeanSimilarity = &mean
+	}
+	report.DurationMs = time.Since(startedAt).Milliseconds()
+
+	if options.UpdateBaseline {
+		if baseline == nil {
+			baseline = map[string]CaseResult{}
+		}
+		for _, result := range report.Results {
+			result.Regression = ""
+			baseline[result.Id] = result
+		}
+		report.BaselineUpdated = true
+	}
+	if err := e.store.SaveReport(ctx, report); err != nil {
+		return report, err
+	}
+	if options.UpdateBaseline {
+		if err := e.store.SaveBaseline(ctx, baseline); err != nil {
+			return report, err
+		}
+	}
+
+	log.Info("Evaluation run completed", map[string]interface{}{
+		"run_id":           report.RunId,
+		"cases":            report.Cases,
+		"failures":         report.Failures,
+		"citation_matches": report.CitationMatches,
+		"regressions":      report.Regressions,
+		"duration_ms":      report.DurationMs,
+	})
+	return report, nil
+}
+
+func (e *Evaluator) Report(ctx context.Context, runId string) (*Report, error) {
+	return e.store.Report(ctx, runId)
+}
+
+// runCase answers and scores one case. Failures are recorded in the result rather than
+// ending the run.
+func (e *Evaluator) runCase(ctx context.Context, answer Answerer, c Case) CaseResult {
+	startTime := time.Now()
+	result := CaseResult{Id: c.Id, Question: c.Question}
+
+	answered, err := answer(ctx, c.Question)
+	result.DurationMs = time.Since(startTime).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Answer = answered.Answer
+	result.RelatedDocuments = answered.RelatedDocuments
+
+	if len(c.ExpectedDocuments) > 0 {
+		match := sameDocuments(c.ExpectedDocuments, answered.RelatedDocuments)
+		result.CitationMatch = &match
+	}
+
+	similarity, reason, err := e.judge.Similarity(ctx, c.Question, c.ExpectedAnswer, answered.Answer)
+	if err != nil {
+		logger.WithContext(ctx).Warn("Failed to judge answer", map[string]interface{}{
+			"case_id": c.Id,
+			"error":   err.Error(),
+		})
+		return result
+	}
+	result.Similarity = &similarity
+	result.JudgeReason = reason
+	return result
+}
+
+// selectCases returns the cases with the given IDs in dataset order, or every case
+func selectCases(cases []Case, ids []string) ([]Case, error) {
+	if len(ids) == 0 {
+		return cases, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var selected []Case
+	for _, c := range cases {
+		if wanted[c.Id] {
+			selected = append(selected, c)
+			delete(wanted, c.Id)
+		}
+	}
+	if len(wanted) > 0 {
+		missing := make([]string, 0, len(wanted))
+		for id := range wanted {
+			missing = append(missing, id)
+		}
+		sort.Strings(missing)
+		return nil, errors.NewValidationError(fmt.Sprintf("unknown case IDs: %s", strings.Join(missing, ", ")))
+	}
+	return selected, nil
+}
+
+// sameDocuments reports whether the cited documents are exactly the expected ones, in any order
+func sameDocuments(expected []string, cited []string) bool {
+	remaining := map[string]int{}
+	for _, document := range expected {
+		remaining[document]++
+	}
+	for _, document := range cited {
+		if remaining[document] == 0 {
+			return false
+		}
+		remaining[document]--
+	}
+	for _, count := range remaining {
+		if count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// regression describes how a result is worse than its baseline, empty when it is not
+func regression(baseline CaseResult, result CaseResult) string {
+	if result.Error != "" {
+		if baseline.Error == "" {
+			return "answering failed"
+		}
+		return ""
+	}
+
+	var reasons []string
+	if baseline.CitationMatch != nil && *baseline.CitationMatch && result.CitationMatch != nil && !*result.CitationMatch {
+		reasons = append(reasons, "citations no longer match")
+	}
+	if baseline.Similarity != nil && result.Similarity != nil && *baseline.Similarity-*result.Similarity > similarityRegression {
+		reasons = append(reasons, fmt.Sprintf("similarity dropped from %.2f to %.2f", *baseline.Similarity, *result.Similarity))
+	}
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,140 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"teletubpax-api/errors"
+)
+
+type memoryStore struct {
+	cases    []Case
+	baseline map[string]CaseResult
+	reports  []*Report
+}
+
+func (s *memoryStore) Cases(ctx context.Context) ([]Case, error) {
+	return s.cases, nil
+}
+
+func (s *memoryStore) Baseline(ctx context.Context) (map[string]CaseResult, error) {
+	return s.baseline, nil
+}
+
+func (s *memoryStore) SaveBaseline(ctx context.Context, baseline map[string]CaseResult) error {
+	s.baseline = baseline
+	return nil
+}
+
+func (s *memoryStore) SaveReport(ctx context.Context, report *Report) error {
+	s.reports = append(s.reports, report)
+	return nil
+}
+
+func (s *memoryStore) Report(ctx context.Context, runId string) (*Report, error) {
+	return nil, nil
+}
+
+// fixedJudge scores each answer from a table, failing for answers it does not know
+type fixedJudge map[string]float64
+
+func (j fixedJudge) Similarity(ctx context.Context, question string, expected string, answer string) (float64, string, error) {
+	score, ok := j[answer]
+	if !ok {
+		return 0, "", fmt.Errorf("judge unavailable")
+	}
+	return score, "scored", nil
+}
+
+func answers(table map[string]*Answer) Answerer {
+	return func(ctx context.Context, question string) (*Answer, error) {
+		if answer, ok := table[question]; ok {
+			return answer, nil
+		}
+		return nil, fmt.Errorf("question-search answered 500")
+	}
+}
+
+func goldenCases() []Case {
+	return []Case{
+		{Id: "fee", Question: "ค่าธรรมเนียมโอน", ExpectedAnswer: "ฟรี", ExpectedDocuments: []string{"a.pdf", "b.pdf"}},
+		{Id: "limit", Question: "วงเงินถอน", ExpectedAnswer: "50,000 บาท"},
+		{Id: "hours", Question: "เวลาทำการ", ExpectedAnswer: "8:30-15:30"},
+	}
+}
+
+func TestEvaluatorRun(t *testing.T) {
+	store := &memoryStore{cases: goldenCases()}
+	evaluator := NewEvaluator(store, fixedJudge{"ฟรี": 1, "50,000": 0.9}, 2)
+	pipeline := answers(map[string]*Answer{
+		"ค่าธรรมเนียมโอน": {Answer: "ฟรี", RelatedDocuments: []string{"b.pdf", "a.pdf"}},
+		"วงเงินถอน":       {Answer: "50,000"},
+	})
+
+	report, err := evaluator.Run(context.Background(), pipeline, RunOptions{UpdateBaseline: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Cases != 3 || report.Failures != 1 || report.CitationCases != 1 || report.CitationMatches != 1 || report.Regressions != 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if report.MeanSimilarity == nil || *report.MeanSimilarity != 0.95 {
+		t.Errorf("expected a mean similarity of 0.95, got %v", report.MeanSimilarity)
+	}
+	if report.Results[2].Id != "hours" || report.Results[2].Error == "" {
+		t.Errorf("expected the results in dataset order with the failure recorded, got %+v", report.Results[2])
+	}
+	if len(store.reports) != 1 || len(store.baseline) != 3 || !report.BaselineUpdated {
+		t.Errorf("expected the report and the baseline to be stored, got %d reports and %v", len(store.reports), store.baseline)
+	}
+
+	// A worse prompt: wrong citations and a much lower similarity
+	pipeline = answers(map[string]*Answer{
+		"ค่าธรรมเนียมโอน": {Answer: "ฟรี", RelatedDocuments: []string{"a.pdf"}},
+		"วงเงินถอน":       {Answer: "ไม่ทราบ"},
+		"เวลาทำการ":       {Answer: "8:30-15:30"},
+	})
+	evaluator.judge = fixedJudge{"ฟรี": 1, "ไม่ทราบ": 0.1, "8:30-15:30": 1}
+	report, err = evaluator.Run(context.Background(), pipeline, RunOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Regressions != 2 || report.Results[0].Regression != "citations no longer match" || report.Results[1].Regression != "similarity dropped from 0.90 to 0.10" {
+		t.Errorf("unexpected regressions %+v", report.Results)
+	}
+	if report.BaselineUpdated || store.baseline["limit"].Similarity == nil || *store.baseline["limit"].Similarity != 0.9 {
+		t.Error("expected the baseline to be kept without updateBaseline")
+	}
+}
+
+func TestEvaluatorRun_SelectsCases(t *testing.T) {
+	store := &memoryStore{cases: goldenCases()}
+	evaluator := NewEvaluator(store, fixedJudge{}, 1)
+	pipeline := answers(map[string]*Answer{"เวลาทำการ": {Answer: "8:30-15:30"}})
+
+	report, err := evaluator.Run(context.Background(), pipeline, RunOptions{CaseIds: []string{"hours"}})
+	if err != nil || report.Cases != 1 || report.Results[0].Id != "hours" || report.Results[0].Similarity != nil {
+		t.Fatalf("expected only the selected case, unjudged, got %+v %v", report, err)
+	}
+
+	_, err = evaluator.Run(context.Background(), pipeline, RunOptions{CaseIds: []string{"hours", "missing"}})
+	if bedrockErr, ok := err.(*errors.BedrockError); !ok || bedrockErr.Code != errors.ErrCodeValidation {
+		t.Errorf("expected a validation error for an unknown case, got %v", err)
+	}
+}
+
+func TestParseJudgement(t *testing.T) {
+	score, reason, err := parseJudgement("```json\n{\"score\": 0.7, \"reason\": \"ขาดเงื่อนไข\"}\n```")
+	if err != nil || score != 0.7 || reason != "ขาดเงื่อนไข" {
+		t.Errorf("unexpected judgement %v %q %v", score, reason, err)
+	}
+	if score, _, _ := parseJudgement(`{"score": 1.5}`); score != 1 {
+		t.Errorf("expected the score to be clamped to 1, got %v", score)
+	}
+	for _, output := range []string{"Looks correct", `{"reason": "no score"}`} {
+		if _, _, err := parseJudgement(output); err == nil {
+			t.Errorf("expected an error for %q", output)
+		}
+	}
+}
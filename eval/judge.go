@@ -0,0 +1,61 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"teletubpax-api/aws"
+)
+
+// judgeMaxTokens is enough for the score and a one-sentence reason in Thai
+const judgeMaxTokens = 300
+
+// Judge scores from 0 to 1 how well an answer agrees with the expected answer, with the
+// reason for the score
+type Judge interface {
+	Similarity(ctx context.Context, question string, expected string, answer string) (float64, string, error)
+}
+
+// GenerationJudge asks the generative model to grade answers against the expected answers
+type GenerationJudge struct {
+	client       aws.GenerationClient
+	instructions func() string
+}
+
+func NewGenerationJudge(client aws.GenerationClient, instructions func() string) *GenerationJudge {
+	return &GenerationJudge{
+		client:       client,
+		instructions: instructions,
+	}
+}
+
+func (j *GenerationJudge) Similarity(ctx context.Context, question string, expected string, answer string) (float64, string, error) {
+	prompt := fmt.Sprintf("Question: %s\n\nExpected answer:\n%s\n\nAnswer:\n%s", question, expected, answer)
+	output, err := j.client.Generate(ctx, j.instructions(), prompt, judgeMaxTokens)
+	if err != nil {
+		return 0, "", err
+	}
+	return parseJudgement(output)
+}
+
+// parseJudgement reads the JSON object of the judge's output, clamping the score to 0 to 1
+func parseJudgement(output string) (float64, string, error) {
+	start, end := strings.Index(output, "{"), strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return 0, "", fmt.Errorf("no JSON object in %q", output)
+	}
+	var judgement struct {
+		Score  *float64 `json:"score"`
+		Reason string   `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(output[start:end+1]), &judgement); err != nil {
+		return 0, "", fmt.Errorf("invalid JSON object: %w", err)
+	}
+	if judgement.Score == nil {
+		return 0, "", fmt.Errorf("no score in %q", output)
+	}
+	score := min(max(*judgement.Score, 0), 1)
+	return score, strings.TrimSpace(judgement.Reason), nil
+}
@@ -0,0 +1,128 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"teletubpax-api/aws"
+)
+
+// maxObjectBytes bounds the dataset, report and baseline objects read from S3
+const maxObjectBytes = 10 << 20
+
+// Store reads the golden dataset and keeps the reports and the baseline of the runs
+type Store interface {
+	Cases(ctx context.Context) ([]Case, error)
+	// Baseline returns the baseline result of each case by ID, nil when there is none
+	Baseline(ctx context.Context) (map[string]CaseResult, error)
+	SaveBaseline(ctx context.Context, baseline map[string]CaseResult) error
+	// SaveReport stores the report under its run ID and as the latest report
+	SaveReport(ctx context.Context, report *Report) error
+	// Report returns a report by run ID, the latest when runId is empty, nil when there is none
+	Report(ctx context.Context, runId string) (*Report, error)
+}
+
+// reportObjects reads and writes the dataset and report objects, like S3ObjectStorageClient
+type reportObjects interface {
+	aws.ObjectReaderClient
+	aws.ObjectWriterClient
+}
+
+// S3Store reads the golden dataset, a JSON array of cases, from an S3 object and writes the
+// reports next to the baseline under a key prefix:
+//
+//	<prefix>/<runId>.json    report of a run
+//	<prefix>/latest.json     report of the latest run
+//	<prefix>/baseline.json   results later runs are compared with
+type S3Store struct {
+	objects      reportObjects
+	bucket       string
+	datasetKey   string
+	reportPrefix string
+}
+
+func NewS3Store(objects reportObjects, bucket string, datasetKey string, reportPrefix string) *S3Store {
+	return &S3Store{
+		objects:      objects,
+		bucket:       bucket,
+		datasetKey:   datasetKey,
+		reportPrefix: reportPrefix,
+	}
+}
+
+func (s *S3Store) Cases(ctx context.Context) ([]Case, error) {
+	var cases []Case
+	found, err := s.read(ctx, s.datasetKey, &cases)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("golden dataset s3://%s/%s does not exist", s.bucket, s.datasetKey)
+	}
+
+	seen := map[string]bool{}
+	for i, c := range cases {
+		if c.Id == "" || c.Question == "" || c.ExpectedAnswer == "" {
+			return nil, fmt.Errorf("golden dataset case %d: id, question and expectedAnswer are required", i+1)
+		}
+		if seen[c.Id] {
+			return nil, fmt.Errorf("golden dataset case %d: duplicate id %q", i+1, c.Id)
+		}
+		seen[c.Id] = true
+	}
+	return cases, nil
+}
+
+func (s *S3Store) Baseline(ctx context.Context) (map[string]CaseResult, error) {
+	var baseline map[string]CaseResult
+	if _, err := s.read(ctx, path.Join(s.reportPrefix, "baseline.json"), &baseline); err != nil {
+		return nil, err
+	}
+	return baseline, nil
+}
+
+func (s *S3Store) SaveBaseline(ctx context.Context, baseline map[string]CaseResult) error {
+	return s.write(ctx, path.Join(s.reportPrefix, "baseline.json"), baseline)
+}
+
+func (s *S3Store) SaveReport(ctx context.Context, report *Report) error {
+	if err := s.write(ctx, path.Join(s.reportPrefix, report.RunId+".json"), report); err != nil {
+		return err
+	}
+	return s.write(ctx, path.Join(s.reportPrefix, "latest.json"), report)
+}
+
+func (s *S3Store) Report(ctx context.Context, runId string) (*Report, error) {
+	name := "latest"
+	if runId != "" {
+		name = runId
+	}
+	var report Report
+	found, err := s.read(ctx, path.Join(s.reportPrefix, name+".json"), &report)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// read decodes a JSON object, reporting false without an error when it does not exist
+func (s *S3Store) read(ctx context.Context, key string, value interface{}) (bool, error) {
+	object, err := s.objects.GetObject(ctx, s.bucket, key, maxObjectBytes)
+	if err != nil || object == nil {
+		return false, err
+	}
+	if err := json.Unmarshal(object.Body, value); err != nil {
+		return false, fmt.Errorf("failed to parse s3://%s/%s: %w", s.bucket, key, err)
+	}
+	return true, nil
+}
+
+func (s *S3Store) write(ctx context.Context, key string, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal s3://%s/%s: %w", s.bucket, key, err)
+	}
+	return s.objects.PutObject(ctx, s.bucket, key, body, "application/json")
+}
@@ -0,0 +1,114 @@
+package experiments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+)
+
+// namePattern matches experiment and variant names, which are part of metric keys and logs
+var namePattern = regexp.MustCompile(`^[0-9A-Za-z_.-]{1,64}$`)
+
+// Experiment splits question-search traffic between prompt and model variants by session
+type Experiment struct {
+	Name     string    `json:"name"`
+	Variants []Variant `json:"variants"`
+}
+
+// Variant is one arm of an experiment. Empty fields keep the configured setting, so the
+// control variant is usually just a name and a percent.
+type Variant struct {
+	Name                  string `json:"name"`
+	Percent               int    `json:"percent"`                         // Share of sessions, the percents of an experiment add up to 100
+	ModelId               string `json:"modelId,omitempty"`               // Generative model answering and synthesizing
+	Instructions          string `json:"instructions,omitempty"`          // Question-search prompt of every knowledge base and language
+	SynthesisInstructions string `json:"synthesisInstructions,omitempty"` // Rules for merging the answers of several knowledge bases
+}
+
+// Parse parses the EXPERIMENT value, a JSON experiment. An empty value runs no experiment.
+func Parse(value string) (*Experiment, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var experiment Experiment
+	if err := json.Unmarshal([]byte(value), &experiment); err != nil {
+		return nil, fmt.Errorf("invalid experiment: %w", err)
+	}
+	if !namePattern.MatchString(experiment.Name) {
+		return nil, fmt.Errorf("experiment name %q must be 1 to 64 letters, digits, '.', '_' or '-'", experiment.Name)
+	}
+	if len(experiment.Variants) < 2 {
+		return nil, fmt.Errorf("experiment %s needs at least two variants", experiment.Name)
+	}
+
+	seen := map[string]bool{}
+	total := 0
+	for _, variant := range experiment.Variants {
+		if !namePattern.MatchString(variant.Name) {
+			return nil, fmt.Errorf("variant name %q must be 1 to 64 letters, digits, '.', '_' or '-'", variant.Name)
+		}
+		if seen[variant.Name] {
+			return nil, fmt.Errorf("duplicate variant %s", variant.Name)
+		}
+		seen[variant.Name] = true
+		if variant.Percent < 0 {
+			return nil, fmt.Errorf("percent of variant %s must not be negative", variant.Name)
+		}
+		total += variant.Percent
+	}
+	if total != 100 {
+		return nil, fmt.Errorf("variant percents of experiment %s add up to %d, expected 100", experiment.Name, total)
+	}
+	return &experiment, nil
+}
+
+// Assign returns the variant of a session. The same session always gets the same variant
+// while the percents are unchanged, so a conversation is not answered by a mix of prompts.
+// It returns nil for a nil experiment.
+func (e *Experiment) Assign(sessionId string) *Variant {
+	if e == nil {
+		return nil
+	}
+
+	// Hashing the experiment name with the session reshuffles sessions between experiments
+	hash := fnv.New32a()
+	hash.Write([]byte(e.Name + "\x00" + sessionId))
+	bucket := int(hash.Sum32() % 100)
+	for i := range e.Variants {
+		bucket -= e.Variants[i].Percent
+		if bucket < 0 {
+			return &e.Variants[i]
+		}
+	}
+	return nil
+}
+
+// Assignment is the experiment variant a request is answered with
+type Assignment struct {
+	Experiment string
+	Variant    *Variant
+}
+
+// Key identifies the variant, e.g. for cache keys. It is empty for a nil assignment.
+func (a *Assignment) Key() string {
+	if a == nil {
+		return ""
+	}
+	return a.Experiment + "/" + a.Variant.Name
+}
+
+type contextKey struct{}
+
+// WithAssignment attaches the variant a request is answered with to its context
+func WithAssignment(ctx context.Context, assignment *Assignment) context.Context {
+	return context.WithValue(ctx, contextKey{}, assignment)
+}
+
+// FromContext returns the assignment of the request, nil when it is not in an experiment
+func FromContext(ctx context.Context) *Assignment {
+	assignment, _ := ctx.Value(contextKey{}).(*Assignment)
+	return assignment
+}
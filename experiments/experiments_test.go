@@ -0,0 +1,70 @@
+package experiments
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	experiment, err := Parse(`{"name": "synthesis-v2", "variants": [{"name": "control", "percent": 90}, {"name": "new-synthesis", "percent": 10, "synthesisInstructions": "Merge the answers."}]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if experiment.Name != "synthesis-v2" || len(experiment.Variants) != 2 || experiment.Variants[1].SynthesisInstructions != "Merge the answers." {
+		t.Errorf("unexpected experiment %+v", experiment)
+	}
+	if experiment, err := Parse(" "); err != nil || experiment != nil {
+		t.Errorf("expected no experiment for an empty value, got %+v %v", experiment, err)
+	}
+
+	for _, value := range []string{
+		`not json`,
+		`{"name": "a b", "variants": [{"name": "control", "percent": 50}, {"name": "candidate", "percent": 50}]}`,
+		`{"name": "single", "variants": [{"name": "control", "percent": 100}]}`,
+		`{"name": "short", "variants": [{"name": "control", "percent": 50}, {"name": "candidate", "percent": 40}]}`,
+		`{"name": "twice", "variants": [{"name": "control", "percent": 50}, {"name": "control", "percent": 50}]}`,
+		`{"name": "negative", "variants": [{"name": "control", "percent": 110}, {"name": "candidate", "percent": -10}]}`,
+	} {
+		if _, err := Parse(value); err == nil {
+			t.Errorf("expected an error for %s", value)
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	experiment := &Experiment{Name: "synthesis-v2", Variants: []Variant{{Name: "control", Percent: 90}, {Name: "new-synthesis", Percent: 10}}}
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		session := fmt.Sprintf("session-%d", i)
+		variant := experiment.Assign(session)
+		if variant == nil {
+			t.Fatalf("expected every session to get a variant")
+		}
+		if again := experiment.Assign(session); again != variant {
+			t.Fatalf("expected session %s to keep variant %s, got %s", session, variant.Name, again.Name)
+		}
+		counts[variant.Name]++
+	}
+	if counts["new-synthesis"] < 800 || counts["new-synthesis"] > 1200 {
+		t.Errorf("expected about 10%% of sessions in new-synthesis, got %v", counts)
+	}
+
+	var none *Experiment
+	if variant := none.Assign("session-1"); variant != nil {
+		t.Errorf("expected no variant without an experiment, got %+v", variant)
+	}
+}
+
+func TestAssignmentContext(t *testing.T) {
+	ctx := context.Background()
+	if assignment := FromContext(ctx); assignment != nil || assignment.Key() != "" {
+		t.Errorf("expected no assignment, got %+v", assignment)
+	}
+
+	ctx = WithAssignment(ctx, &Assignment{Experiment: "synthesis-v2", Variant: &Variant{Name: "control"}})
+	if key := FromContext(ctx).Key(); key != "synthesis-v2/control" {
+		t.Errorf("expected the assignment key synthesis-v2/control, got %q", key)
+	}
+}
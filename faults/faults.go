@@ -0,0 +1,231 @@
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"teletubpax-api/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Kinds of injected faults
+const (
+	KindThrottle  = "throttle"  // Fail the call with a ThrottlingException before it is sent
+	KindLatency   = "latency"   // Delay the call by LatencyMs
+	KindMalformed = "malformed" // Replace the response body with invalid JSON
+)
+
+// defaultLatencyMs is the delay of a latency fault without LatencyMs
+const defaultLatencyMs = 2000
+
+// malformedBody replaces response bodies for malformed faults
+const malformedBody = `{"injected": "malformed response`
+
+// Fault describes one fault to inject into AWS calls
+type Fault struct {
+	Kind        string  `json:"kind"`
+	Target      string  `json:"target,omitempty"`      // "service" or "service/Operation", e.g. "bedrock-agent-runtime/RetrieveAndGenerate"; empty matches every call
+	Probability float64 `json:"probability,omitempty"` // Share of matching calls that fail, 0 means every call
+	LatencyMs   int     `json:"latencyMs,omitempty"`   // Delay of latency faults
+}
+
+func (f Fault) validate() error {
+	switch f.Kind {
+	case KindThrottle, KindLatency, KindMalformed:
+	default:
+		return fmt.Errorf("unknown fault kind %q, expected %s, %s or %s", f.Kind, KindThrottle, KindLatency, KindMalformed)
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("fault probability must be between 0 and 1")
+	}
+	if f.LatencyMs < 0 {
+		return fmt.Errorf("fault latency must be non-negative")
+	}
+	return nil
+}
+
+// matches reports whether the fault applies to a call of the operation
+func (f Fault) matches(service string, operation string) bool {
+	if f.Target == "" {
+		return true
+	}
+	targetService, targetOperation, hasOperation := strings.Cut(f.Target, "/")
+	if !strings.EqualFold(targetService, service) {
+		return false
+	}
+	return !hasOperation || strings.EqualFold(targetOperation, operation)
+}
+
+// ParseRules parses the FAULT_INJECTION value, a JSON list of faults. An empty value has
+// no faults.
+func ParseRules(value string) ([]Fault, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var faults []Fault
+	if err := json.Unmarshal([]byte(value), &faults); err != nil {
+		return nil, fmt.Errorf("invalid fault rules: %w", err)
+	}
+	for _, fault := range faults {
+		if err := fault.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return faults, nil
+}
+
+// ParseHeader parses the X-Fault-Injection header, a comma separated list of
+// kind[=latencyMs][@target], e.g. "throttle@bedrock-agent-runtime, latency=3000". Header
+// faults apply to every matching call of the request.
+func ParseHeader(value string) ([]Fault, error) {
+	var faults []Fault
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var fault Fault
+		part, fault.Target, _ = strings.Cut(part, "@")
+		kind, latency, hasLatency := strings.Cut(part, "=")
+		fault.Kind = strings.ToLower(strings.TrimSpace(kind))
+		if hasLatency {
+			latencyMs, err := strconv.Atoi(strings.TrimSpace(latency))
+			if err != nil {
+				return nil, fmt.Errorf("invalid fault latency %q", latency)
+			}
+			fault.LatencyMs = latencyMs
+		}
+		fault.Target = strings.TrimSpace(fault.Target)
+		if err := fault.validate(); err != nil {
+			return nil, err
+		}
+		faults = append(faults, fault)
+	}
+	return faults, nil
+}
+
+type contextKey struct{}
+
+// WithFaults attaches the faults requested for one request to its context
+func WithFaults(ctx context.Context, faults []Fault) context.Context {
+	return context.WithValue(ctx, contextKey{}, faults)
+}
+
+// FromContext returns the faults attached to the context, nil when there are none
+func FromContext(ctx context.Context) []Fault {
+	faults, _ := ctx.Value(contextKey{}).([]Fault)
+	return faults
+}
+
+// malformedKey marks a call whose response body is replaced
+type malformedKey struct{}
+
+// Injector injects faults into every AWS call made with the configs it was added to, from
+// its rules and from the faults attached to the request context. It exists to exercise the
+// retry and degradation paths end to end and must never be enabled in production.
+type Injector struct {
+	rules  []Fault
+	random func() float64
+}
+
+func New(rules []Fault) *Injector {
+	return &Injector{
+		rules:  rules,
+		random: rand.Float64,
+	}
+}
+
+// AddTo registers the injector with an AWS config. Clients created from the config
+// afterwards go through it.
+func (i *Injector) AddTo(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		if err := stack.Initialize.Add(middleware.InitializeMiddlewareFunc("FaultInjection", i.handleInitialize), middleware.After); err != nil {
+			return err
+		}
+		// Added last, so it wraps the raw response before the operation deserializes it
+		return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("FaultInjectionMalformed", i.handleDeserialize), middleware.After)
+	})
+}
+
+// selected returns the faults that apply to this call of the operation
+func (i *Injector) selected(ctx context.Context, service string, operation string) []Fault {
+	var selected []Fault
+	for _, fault := range append(append([]Fault(nil), i.rules...), FromContext(ctx)...) {
+		if !fault.matches(service, operation) {
+			continue
+		}
+		if fault.Probability > 0 && i.random() >= fault.Probability {
+			continue
+		}
+		selected = append(selected, fault)
+	}
+	return selected
+}
+
+func (i *Injector) handleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	service := serviceName(awsmiddleware.GetServiceID(ctx))
+	operation := awsmiddleware.GetOperationName(ctx)
+
+	for _, fault := range i.selected(ctx, service, operation) {
+		logger.WithContext(ctx).Warn("Injecting fault", map[string]interface{}{
+			"kind":      fault.Kind,
+			"service":   service,
+			"operation": operation,
+		})
+
+		switch fault.Kind {
+		case KindThrottle:
+			return middleware.InitializeOutput{}, middleware.Metadata{}, &smithy.GenericAPIError{
+				Code:    "ThrottlingException",
+				Message: "Rate exceeded (injected fault)",
+				Fault:   smithy.FaultClient,
+			}
+		case KindLatency:
+			latencyMs := fault.LatencyMs
+			if latencyMs == 0 {
+				latencyMs = defaultLatencyMs
+			}
+			select {
+			case <-time.After(time.Duration(latencyMs) * time.Millisecond):
+			case <-ctx.Done():
+				return middleware.InitializeOutput{}, middleware.Metadata{}, ctx.Err()
+			}
+		case KindMalformed:
+			ctx = middleware.WithStackValue(ctx, malformedKey{}, true)
+		}
+	}
+	return next.HandleInitialize(ctx, in)
+}
+
+func (i *Injector) handleDeserialize(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+	out, metadata, err := next.HandleDeserialize(ctx, in)
+	if err != nil || middleware.GetStackValue(ctx, malformedKey{}) == nil {
+		return out, metadata, err
+	}
+
+	if response, ok := out.RawResponse.(*smithyhttp.Response); ok {
+		response.Body.Close()
+		response.Body = io.NopCloser(strings.NewReader(malformedBody))
+		response.ContentLength = int64(len(malformedBody))
+		response.Header.Del("Content-Length")
+	}
+	return out, metadata, err
+}
+
+// serviceName turns an SDK service ID into a target name, e.g. "Bedrock Agent Runtime"
+// into "bedrock-agent-runtime"
+func serviceName(serviceId string) string {
+	return strings.ReplaceAll(strings.ToLower(serviceId), " ", "-")
+}
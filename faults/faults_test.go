@@ -0,0 +1,144 @@
+package faults
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+func TestParseHeader(t *testing.T) {
+	faults, err := ParseHeader("throttle@bedrock-agent-runtime/RetrieveAndGenerate, latency=1500, Malformed@dynamodb")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Fault{
+		{Kind: KindThrottle, Target: "bedrock-agent-runtime/RetrieveAndGenerate"},
+		{Kind: KindLatency, LatencyMs: 1500},
+		{Kind: KindMalformed, Target: "dynamodb"},
+	}
+	if len(faults) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, faults)
+	}
+	for i := range expected {
+		if faults[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], faults[i])
+		}
+	}
+
+	for _, value := range []string{"explode", "latency=soon"} {
+		if _, err := ParseHeader(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(`[{"kind": "throttle", "target": "bedrock-runtime", "probability": 0.2}]`)
+	if err != nil || len(rules) != 1 || rules[0].Probability != 0.2 {
+		t.Fatalf("unexpected rules %+v %v", rules, err)
+	}
+	if rules, err := ParseRules(""); err != nil || rules != nil {
+		t.Errorf("expected no rules for an empty value, got %+v %v", rules, err)
+	}
+	for _, value := range []string{`not json`, `[{"kind": "throttle", "probability": 2}]`} {
+		if _, err := ParseRules(value); err == nil {
+			t.Errorf("expected an error for %s", value)
+		}
+	}
+}
+
+func TestFault_Matches(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected bool
+	}{
+		{"", true},
+		{"dynamodb", true},
+		{"DynamoDB/DescribeTable", true},
+		{"dynamodb/PutItem", false},
+		{"bedrock-runtime", false},
+	}
+	for _, tt := range tests {
+		if matched := (Fault{Target: tt.target}).matches("dynamodb", "DescribeTable"); matched != tt.expected {
+			t.Errorf("expected %v for target %q", tt.expected, tt.target)
+		}
+	}
+}
+
+// newTestClient returns a DynamoDB client served by a local server that answers every call
+// with an empty table description, and a count of the calls that reached it
+func newTestClient(t *testing.T, injector *Injector) (*dynamodb.Client, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{"Table": {"TableName": "test"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := aws.Config{
+		Region:           "us-east-1",
+		Credentials:      aws.AnonymousCredentials{},
+		BaseEndpoint:     aws.String(server.URL),
+		RetryMaxAttempts: 1,
+	}
+	injector.AddTo(&cfg)
+	return dynamodb.NewFromConfig(cfg), &calls
+}
+
+func TestInjector_InjectsRequestedFaults(t *testing.T) {
+	client, calls := newTestClient(t, New(nil))
+	input := &dynamodb.DescribeTableInput{TableName: aws.String("test")}
+
+	if _, err := client.DescribeTable(context.Background(), input); err != nil || atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("expected an untouched call without faults, got %v", err)
+	}
+
+	ctx := WithFaults(context.Background(), []Fault{{Kind: KindThrottle, Target: "dynamodb"}})
+	_, err := client.DescribeTable(ctx, input)
+	if err == nil || !strings.Contains(err.Error(), "ThrottlingException") || atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("expected a ThrottlingException without a call, got %v", err)
+	}
+
+	ctx = WithFaults(context.Background(), []Fault{{Kind: KindMalformed}})
+	if _, err := client.DescribeTable(ctx, input); err == nil || atomic.LoadInt32(calls) != 2 {
+		t.Fatalf("expected the malformed response to fail deserialization, got %v", err)
+	}
+
+	ctx = WithFaults(context.Background(), []Fault{{Kind: KindLatency, LatencyMs: 50}})
+	start := time.Now()
+	if _, err := client.DescribeTable(ctx, input); err != nil || time.Since(start) < 50*time.Millisecond {
+		t.Fatalf("expected a delayed call, got %v after %v", err, time.Since(start))
+	}
+
+	ctx = WithFaults(context.Background(), []Fault{{Kind: KindThrottle, Target: "bedrock-runtime"}})
+	if _, err := client.DescribeTable(ctx, input); err != nil {
+		t.Fatalf("expected faults of other services to be ignored, got %v", err)
+	}
+}
+
+func TestInjector_AppliesRulesWithProbability(t *testing.T) {
+	injector := New([]Fault{{Kind: KindThrottle, Probability: 0.5}})
+	draws := []float64{0.7, 0.2}
+	injector.random = func() float64 {
+		draw := draws[0]
+		draws = draws[1:]
+		return draw
+	}
+	client, _ := newTestClient(t, injector)
+	input := &dynamodb.DescribeTableInput{TableName: aws.String("test")}
+
+	if _, err := client.DescribeTable(context.Background(), input); err != nil {
+		t.Fatalf("expected the first call to pass, got %v", err)
+	}
+	if _, err := client.DescribeTable(context.Background(), input); err == nil {
+		t.Fatal("expected the second call to be throttled")
+	}
+}
@@ -0,0 +1,183 @@
+package flags
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/logger"
+)
+
+// loadTimeout bounds a load triggered by a flag lookup
+const loadTimeout = 2 * time.Second
+
+// Source loads the current flag values, e.g. from an env var or an SSM parameter
+type Source interface {
+	Name() string
+	Load(ctx context.Context) (map[string]bool, error)
+}
+
+// Flags caches flag values from its sources and reloads them once they are older than the
+// refresh interval, so a changed SSM parameter is picked up without a redeploy. Later
+// sources override earlier ones. Unknown flags are disabled. Stale values are reloaded in
+// the background and served meanwhile, so lookups never wait for SSM after the first load.
+type Flags struct {
+	sources   []Source
+	ttl       time.Duration
+	mu        sync.RWMutex
+	values    map[string]bool
+	loadedAt  time.Time
+	reloading bool       // A background reload is in flight
+	loadMu    sync.Mutex // Held by the first load, which concurrent lookups wait for
+}
+
+func New(ttl time.Duration, sources ...Source) *Flags {
+	return &Flags{
+		sources: sources,
+		ttl:     ttl,
+		values:  map[string]bool{},
+	}
+}
+
+// Enabled reports whether the named flag is on. A nil Flags has every flag off.
+func (f *Flags) Enabled(name string) bool {
+	if f == nil {
+		return false
+	}
+	f.reloadIfStale()
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.values[normalizeName(name)]
+}
+
+// Snapshot returns a copy of the current flag values
+func (f *Flags) Snapshot() map[string]bool {
+	if f == nil {
+		return map[string]bool{}
+	}
+	f.reloadIfStale()
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	snapshot := make(map[string]bool, len(f.values))
+	for name, enabled := range f.values {
+		snapshot[name] = enabled
+	}
+	return snapshot
+}
+
+// Reload loads every source now. When a source fails the previous values are kept and the
+// next attempt happens after the refresh interval.
+func (f *Flags) Reload(ctx context.Context) error {
+	values := map[string]bool{}
+	var loadErr error
+	for _, source := range f.sources {
+		sourceValues, err := source.Load(ctx)
+		if err != nil {
+			logger.WithContext(ctx).Warn("Failed to load feature flags", map[string]interface{}{
+				"source": source.Name(),
+				"error":  err.Error(),
+			})
+			loadErr = err
+			break
+		}
+		for name, enabled := range sourceValues {
+			values[name] = enabled
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if loadErr == nil {
+		f.values = values
+	}
+	f.loadedAt = time.Now()
+	return loadErr
+}
+
+// reloadIfStale loads the values on first use and starts a single background reload once
+// they are older than the refresh interval
+func (f *Flags) reloadIfStale() {
+	f.mu.Lock()
+	loaded := !f.loadedAt.IsZero()
+	if loaded {
+		stale := f.ttl > 0 && time.Since(f.loadedAt) > f.ttl
+		if stale && !f.reloading {
+			f.reloading = true
+			go func() {
+				f.load()
+				f.mu.Lock()
+				f.reloading = false
+				f.mu.Unlock()
+			}()
+		}
+		f.mu.Unlock()
+		return
+	}
+	f.mu.Unlock()
+
+	// Nothing to serve yet, so lookups wait for a single load
+	f.loadMu.Lock()
+	defer f.loadMu.Unlock()
+	f.mu.RLock()
+	loaded = !f.loadedAt.IsZero()
+	f.mu.RUnlock()
+	if !loaded {
+		f.load()
+	}
+}
+
+func (f *Flags) load() {
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	f.Reload(ctx)
+}
+
+// Parse reads a comma-separated flag list such as "semantic-cache,answer-diff=false".
+// A bare name enables the flag.
+func Parse(value string) map[string]bool {
+	values := map[string]bool{}
+	for _, entry := range strings.Split(value, ",") {
+		name, setting, hasSetting := strings.Cut(entry, "=")
+		name = normalizeName(name)
+		if name == "" {
+			continue
+		}
+
+		enabled := true
+		if hasSetting {
+			parsed, err := strconv.ParseBool(strings.TrimSpace(setting))
+			if err != nil {
+				continue
+			}
+			enabled = parsed
+		}
+		values[name] = enabled
+	}
+	return values
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Global flags instance
+var globalFlags *Flags
+
+// Initialize sets the flags consulted by the package-level Enabled
+func Initialize(flags *Flags) {
+	globalFlags = flags
+}
+
+// Default returns the global flags instance, nil when not initialized
+func Default() *Flags {
+	return globalFlags
+}
+
+// Enabled reports whether the named flag is on in the global flags instance
+func Enabled(name string) bool {
+	return globalFlags.Enabled(name)
+}
@@ -0,0 +1,136 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	values  map[string]bool
+	err     error
+	loads   int
+	release chan struct{} // Loads wait for it when set
+}
+
+func (s *fakeSource) Name() string {
+	return "fake"
+}
+
+func (s *fakeSource) Load(ctx context.Context) (map[string]bool, error) {
+	s.loads++
+	if s.release != nil {
+		<-s.release
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.values, nil
+}
+
+func TestParse(t *testing.T) {
+	values := Parse(" Semantic-Cache , answer-diff=false,bad=maybe,,x=1")
+
+	expected := map[string]bool{"semantic-cache": true, "answer-diff": false, "x": true}
+	if len(values) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, values)
+	}
+	for name, enabled := range expected {
+		if values[name] != enabled {
+			t.Fatalf("expected %s=%v, got %v", name, enabled, values[name])
+		}
+	}
+}
+
+func TestEnabled_LaterSourcesOverride(t *testing.T) {
+	flags := New(time.Minute, NewEnvSource("semantic-cache,answer-diff"), &fakeSource{values: map[string]bool{"answer-diff": false}})
+
+	if !flags.Enabled("semantic-cache") {
+		t.Fatal("expected semantic-cache to be enabled by the env source")
+	}
+	if flags.Enabled("answer-diff") {
+		t.Fatal("expected answer-diff to be disabled by the later source")
+	}
+	if flags.Enabled("unknown") {
+		t.Fatal("expected unknown flags to be disabled")
+	}
+}
+
+func TestEnabled_CachesUntilStale(t *testing.T) {
+	source := &fakeSource{values: map[string]bool{"semantic-cache": true}}
+	flags := New(time.Hour, source)
+
+	flags.Enabled("semantic-cache")
+	flags.Enabled("semantic-cache")
+	if source.loads != 1 {
+		t.Fatalf("expected 1 load within the refresh interval, got %d", source.loads)
+	}
+
+	source.values = map[string]bool{"semantic-cache": false}
+	flags.loadedAt = time.Now().Add(-2 * time.Hour)
+	waitForValue(t, flags, "semantic-cache", false)
+}
+
+func TestEnabled_ServesStaleValuesWhileReloading(t *testing.T) {
+	source := &fakeSource{values: map[string]bool{"semantic-cache": true}}
+	flags := New(time.Hour, source)
+	flags.Enabled("semantic-cache")
+
+	release := make(chan struct{})
+	source.values = map[string]bool{"semantic-cache": false}
+	source.release = release
+	flags.loadedAt = time.Now().Add(-2 * time.Hour)
+
+	// Concurrent lookups do not wait for the reload
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !flags.Enabled("semantic-cache") {
+				t.Error("expected the stale value while reloading")
+			}
+		}()
+	}
+	wg.Wait()
+
+	close(release)
+	waitForValue(t, flags, "semantic-cache", false)
+	if source.loads != 2 {
+		t.Errorf("expected a single reload, got %d loads", source.loads)
+	}
+}
+
+// waitForValue waits until a background reload gives the flag the expected value
+func waitForValue(t *testing.T, flags *Flags, name string, expected bool) {
+	deadline := time.Now().Add(time.Second)
+	for flags.Enabled(name) != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s=%v after the reload", name, expected)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReload_KeepsValuesOnFailure(t *testing.T) {
+	source := &fakeSource{values: map[string]bool{"semantic-cache": true}}
+	flags := New(time.Hour, source)
+	flags.Enabled("semantic-cache")
+
+	source.err = fmt.Errorf("parameter not found")
+	if err := flags.Reload(context.Background()); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if !flags.Enabled("semantic-cache") {
+		t.Fatal("expected previous values to be kept after a failed reload")
+	}
+}
+
+func TestEnabled_NilFlags(t *testing.T) {
+	var flags *Flags
+	if flags.Enabled("semantic-cache") {
+		t.Fatal("expected nil flags to have every flag disabled")
+	}
+}
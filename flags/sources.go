@@ -0,0 +1,58 @@
+package flags
+
+import (
+	"context"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// EnvSource serves flags from a static value, normally the FEATURE_FLAGS env var
+type EnvSource struct {
+	value string
+}
+
+func NewEnvSource(value string) *EnvSource {
+	return &EnvSource{value: value}
+}
+
+func (s *EnvSource) Name() string {
+	return "env"
+}
+
+func (s *EnvSource) Load(ctx context.Context) (map[string]bool, error) {
+	return Parse(s.value), nil
+}
+
+// SSMSource reads flags from an SSM parameter using the same format as the env var
+type SSMSource struct {
+	client        *ssm.Client
+	parameterName string
+}
+
+func NewSSMSource(cfg aws.Config, parameterName string) *SSMSource {
+	return &SSMSource{
+		client:        ssm.NewFromConfig(cfg),
+		parameterName: parameterName,
+	}
+}
+
+func (s *SSMSource) Name() string {
+	return "ssm:" + s.parameterName
+}
+
+func (s *SSMSource) Load(ctx context.Context) (map[string]bool, error) {
+	output, err := s.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(s.parameterName),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read feature flags parameter", err)
+	}
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return map[string]bool{}, nil
+	}
+	return Parse(*output.Parameter.Value), nil
+}
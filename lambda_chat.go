@@ -0,0 +1,84 @@
+//go:build lambda
+// +build lambda
+
+// WebSocket chat of the Lambda entry point. API Gateway's WebSocket API invokes the function
+// for each connection, message and disconnection, and the replies are posted back to the
+// connection through its management API.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/routing"
+)
+
+// chatGateway is nil when CHAT_ENABLED is not set
+var chatGateway *routing.ChatGateway
+
+// webSocketClient posts the replies of the chat to its connections
+var webSocketClient aws.WebSocketClient
+
+// UnmarshalJSON decodes the event, keeping a WebSocket API request apart from the HTTP API
+// request it shares its fields with
+func (e *lambdaEvent) UnmarshalJSON(data []byte) error {
+	type plainEvent lambdaEvent
+	if err := json.Unmarshal(data, (*plainEvent)(e)); err != nil {
+		return err
+	}
+
+	var webSocket events.APIGatewayWebsocketProxyRequest
+	if err := json.Unmarshal(data, &webSocket); err == nil && webSocket.RequestContext.ConnectionID != "" {
+		e.WebSocket = &webSocket
+	}
+	return nil
+}
+
+// handleChatEvent serves an event of the WebSocket API. Only the status of a connection
+// request reaches the client: the replies to its messages are posted to the connection.
+func handleChatEvent(ctx context.Context, request *events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if chatGateway == nil {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound}, nil
+	}
+	if tracerProvider != nil {
+		defer tracerProvider.ForceFlush(ctx)
+	}
+	connectionId := request.RequestContext.ConnectionID
+
+	switch request.RequestContext.EventType {
+	case "CONNECT":
+		query := url.Values{}
+		for name, values := range request.MultiValueQueryStringParameters {
+			query[name] = values
+		}
+		status, body := chatGateway.Connect(ctx, connectionId, request.Headers, query.Encode())
+		return events.APIGatewayProxyResponse{StatusCode: status, Body: string(body)}, nil
+	case "DISCONNECT":
+		if err := chatGateway.Disconnect(ctx, connectionId); err != nil {
+			log.Printf("Failed to forget chat connection %s: %v", connectionId, err)
+		}
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	}
+
+	// The management API of the stage the connection was opened on
+	endpoint := "https://" + request.RequestContext.DomainName + "/" + request.RequestContext.Stage
+	send := func(message routing.ChatMessage) error {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		return webSocketClient.PostToConnection(ctx, endpoint, connectionId, data)
+	}
+	if err := chatGateway.Message(ctx, connectionId, []byte(request.Body), send); err != nil {
+		// The client is gone or the connection could not be read, API Gateway must not retry
+		log.Printf("Failed to serve chat message of connection %s: %v", connectionId, err)
+	}
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+}
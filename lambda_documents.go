@@ -0,0 +1,39 @@
+//go:build lambda
+// +build lambda
+
+// Handler of the Lambda entry point for documents uploaded to the knowledge base bucket,
+// which an EventBridge rule invokes with the S3 "Object Created" event of every PDF.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"teletubpax-api/services"
+)
+
+// scannedDocumentService is nil when SCANNED_PDF_OCR_ENABLED is not set
+var scannedDocumentService services.ScannedDocumentService
+
+// objectCreatedDetail is the detail of an S3 "Object Created" event sent to EventBridge
+type objectCreatedDetail struct {
+	Bucket struct {
+		Name string `json:"name"`
+	} `json:"bucket"`
+	Object struct {
+		Key string `json:"key"`
+	} `json:"object"`
+}
+
+// handleObjectCreated extracts the text of an uploaded scanned PDF. Its error fails the
+// asynchronous invocation, which Lambda retries.
+func handleObjectCreated(ctx context.Context, detail objectCreatedDetail) (interface{}, error) {
+	if tracerProvider != nil {
+		defer tracerProvider.ForceFlush(ctx)
+	}
+	if scannedDocumentService == nil {
+		return nil, fmt.Errorf("received upload of s3://%s/%s, but SCANNED_PDF_OCR_ENABLED is not set", detail.Bucket.Name, detail.Object.Key)
+	}
+	return scannedDocumentService.ProcessObject(ctx, detail.Bucket.Name, detail.Object.Key)
+}
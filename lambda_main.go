@@ -1,103 +1,733 @@
-//go:build lambda
-// +build lambda
-
-// Lambda entry point for AWS deployment
-// This file is used when building for Lambda (go build -tags lambda)
-// For local development, use main.go instead
-
-package main
-
-import (
-	"context"
-	"log"
-	"os"
-
-	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-lambda-go/lambda"
-	awsConfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
-
-	"teletubpax-api/aws"
-	"teletubpax-api/config"
-	"teletubpax-api/logger"
-	"teletubpax-api/routing"
-	"teletubpax-api/services"
-)
-
-var httpLambda *httpadapter.HandlerAdapterV2
-
-func init() {
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
-	}
-
-	// Initialize AWS SDK config
-	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background(),
-		awsConfig.WithRegion(cfg.AWSRegion),
-	)
-	if err != nil {
-		log.Fatalf("Failed to load AWS configuration: %v", err)
-	}
-
-	// Initialize Standard Logger for Lambda (CloudWatch handles logs automatically)
-	logger.Initialize(&logger.StandardLogger{})
-	logger.SetLogLevel(logger.ERROR) // Only log errors in Lambda
-
-	log.Printf("Lambda initialization started for function: %s", os.Getenv("AWS_LAMBDA_FUNCTION_NAME"))
-
-	// Create AWS clients
-	embeddingClient := aws.NewBedrockEmbeddingClient(awsCfg, cfg.EmbeddingModelId)
-	kbClient := aws.NewBedrockKBClient(awsCfg, cfg.KnowledgeBaseIds, cfg.GenerativeModelId, cfg.AWSRegion, cfg.QuestionSearchInstructions)
-	openSearchClient := aws.NewBedrockOpenSearchClient(awsCfg, cfg.KnowledgeBaseIds[0], cfg.AWSRegion, kbClient, cfg.GenerativeModelId, cfg.DocumentComparisonInstructions)
-
-	// Create services
-	questionSearchService := services.NewBedrockQuestionSearchService(
-		embeddingClient,
-		kbClient,
-		cfg,
-	)
-
-	documentDetailsService := services.NewOpenSearchDocumentService(
-		openSearchClient,
-		cfg,
-	)
-
-	documentSummaryService := services.NewBedrockDocumentSummaryService(
-		openSearchClient,
-		kbClient,
-		cfg,
-	)
-
-	// Setup routes
-	router := routing.SetupRoutes(questionSearchService, documentDetailsService, documentSummaryService, cfg.MaxQuestionLength)
-
-	// Create Lambda adapter for API Gateway V2 (HTTP API)
-	httpLambda = httpadapter.NewV2(router)
-
-	log.Println("Lambda initialization completed successfully")
-}
-
-func Handler(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
-	// Get response from HTTP adapter
-	resp, err := httpLambda.ProxyWithContext(ctx, req)
-
-	// Ensure CORS headers are always present in Lambda response
-	if resp.Headers == nil {
-		resp.Headers = make(map[string]string)
-	}
-
-	// Add CORS headers to response (these will be merged with any existing headers)
-	resp.Headers["Access-Control-Allow-Origin"] = "*"
-	resp.Headers["Access-Control-Allow-Methods"] = "GET, POST, OPTIONS"
-	resp.Headers["Access-Control-Allow-Headers"] = "Content-Type, Authorization"
-	resp.Headers["Access-Control-Max-Age"] = "3600"
-	resp.Headers["Content-Type"] = "application/json"
-
-	return resp, err
-}
-
-func main() {
-	lambda.Start(Handler)
-}
+//go:build lambda
+// +build lambda
+
+// Lambda entry point for AWS deployment
+// This file is used when building for Lambda (go build -tags lambda)
+// For local development, use main.go instead
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"teletubpax-api/alerting"
+	"teletubpax-api/auth"
+	"teletubpax-api/aws"
+	"teletubpax-api/bootstrap"
+	"teletubpax-api/cache"
+	"teletubpax-api/config"
+	"teletubpax-api/eval"
+	"teletubpax-api/experiments"
+	"teletubpax-api/faults"
+	"teletubpax-api/flags"
+	"teletubpax-api/limiter"
+	"teletubpax-api/logger"
+	"teletubpax-api/normalization"
+	"teletubpax-api/policy"
+	"teletubpax-api/redaction"
+	"teletubpax-api/routing"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+	"teletubpax-api/tracing"
+)
+
+var httpLambda *httpadapter.HandlerAdapterV2
+
+// tracerProvider is flushed after every invocation, nil when tracing is off
+var tracerProvider *sdktrace.TracerProvider
+
+// warmUp readies the execution environment for the first user request, run on warm-up events
+var warmUp func(ctx context.Context)
+
+// lambdaEvent is an HTTP API request, a WebSocket API request of the chat, a batch of queued
+// questions from SQS, a task of the bulk summary workflow, an upload to the knowledge base
+// bucket, or a warm-up from a scheduled EventBridge rule: the default scheduled event, or a
+// rule input of {"warmUp": true}
+type lambdaEvent struct {
+	events.APIGatewayV2HTTPRequest
+	services.SummaryWorkflowTask
+	Records    []events.SQSMessage                     `json:"Records"`
+	WarmUp     bool                                    `json:"warmUp"`
+	Source     string                                  `json:"source"`
+	DetailType string                                  `json:"detail-type"`
+	Detail     objectCreatedDetail                     `json:"detail"`
+	WebSocket  *events.APIGatewayWebsocketProxyRequest `json:"-"` // Set by UnmarshalJSON
+}
+
+func (e lambdaEvent) isWarmUp() bool {
+	return e.WarmUp || (e.Source == "aws.events" && e.DetailType == "Scheduled Event")
+}
+
+func init() {
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Initialize AWS SDK config
+	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background(),
+		awsConfig.WithRegion(cfg.AWSRegion),
+	)
+	if err != nil {
+		log.Fatalf("Failed to load AWS configuration: %v", err)
+	}
+
+	// Trace requests, services and AWS calls to an OTLP endpoint or X-Ray (optional)
+	tracerProvider, err = tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("Invalid tracing settings: %v", err)
+	}
+	if tracerProvider != nil {
+		tracing.AddTo(&awsCfg)
+	}
+
+	// Queue Bedrock model calls over the concurrency limits instead of sending them into
+	// ThrottlingExceptions (optional). Added after tracing, so the traces show the wait.
+	if cfg.BedrockMaxConcurrency > 0 || len(cfg.BedrockOperationLimits) > 0 {
+		operationLimits, err := limiter.ParseOperationLimits(cfg.BedrockOperationLimits)
+		if err != nil {
+			log.Fatalf("Invalid BEDROCK_OPERATION_LIMITS: %v", err)
+		}
+		limiter.New(cfg.BedrockMaxConcurrency, operationLimits, time.Duration(cfg.BedrockQueueTimeoutMs)*time.Millisecond).AddTo(&awsCfg)
+	}
+
+	// Pace Bedrock model calls once they are throttled, retries included
+	if cfg.BedrockAdaptiveThrottle {
+		limiter.NewAdaptiveThrottle(cfg.BedrockMinRate, cfg.BedrockMaxRate).AddTo(&awsCfg)
+	}
+
+	// Inject throttling, latency and malformed responses into AWS calls (non-production only)
+	if cfg.FaultInjectionEnabled {
+		faultRules, err := faults.ParseRules(cfg.FaultInjection)
+		if err != nil {
+			log.Fatalf("Invalid FAULT_INJECTION: %v", err)
+		}
+		faults.New(faultRules).AddTo(&awsCfg)
+		log.Printf("Fault injection enabled with %d rules", len(faultRules))
+	}
+
+	// Initialize Standard Logger for Lambda (CloudWatch handles logs automatically)
+	logger.Initialize(&logger.StandardLogger{})
+	logger.SetLogLevel(logger.ERROR) // Only log errors in Lambda
+
+	log.Printf("Lambda initialization started for function: %s", os.Getenv("AWS_LAMBDA_FUNCTION_NAME"))
+
+	// Create missing tables with BOOTSTRAP_RESOURCES=true, Lambda manages its own log group
+	if cfg.BootstrapResources {
+		results, err := bootstrap.NewBootstrapper(awsCfg).Run(context.Background(), bootstrap.Tables(cfg), nil)
+		for _, result := range results {
+			log.Printf("Bootstrap %s", result)
+		}
+		if err != nil {
+			log.Fatalf("Bootstrap failed: %v", err)
+		}
+	}
+
+	// Create feature flags, the SSM parameter (if set) overrides FEATURE_FLAGS
+	flagSources := []flags.Source{flags.NewEnvSource(cfg.FeatureFlags)}
+	if cfg.FeatureFlagsParameter != "" {
+		flagSources = append(flagSources, flags.NewSSMSource(awsCfg, cfg.FeatureFlagsParameter))
+	}
+	featureFlags := flags.New(time.Duration(cfg.FeatureFlagsRefreshSeconds)*time.Second, flagSources...)
+	flags.Initialize(featureFlags)
+
+	// Create endpoint policies, the SSM parameter (if set) overrides ENDPOINT_POLICIES per endpoint
+	if _, err := policy.Parse(cfg.EndpointPolicies); err != nil {
+		log.Fatalf("Invalid ENDPOINT_POLICIES: %v", err)
+	}
+	policySources := []policy.Source{policy.NewEnvSource(cfg.EndpointPolicies)}
+	if cfg.EndpointPoliciesParameter != "" {
+		policySources = append(policySources, policy.NewSSMSource(awsCfg, cfg.EndpointPoliciesParameter))
+	}
+	endpointPolicies := policy.New(
+		policy.Defaults(cfg.RetryAttempts),
+		time.Duration(cfg.EndpointPoliciesRefreshSeconds)*time.Second,
+		policySources...,
+	)
+
+	// Create the soft-deleted document registry (optional), deleted documents are excluded
+	// from retrieval and listings until they are restored or purged
+	var deletedDocumentStore storage.DeletedDocumentStore
+	var documentDeletionService services.DocumentDeletionService
+	if cfg.DeletedDocumentsTable != "" {
+		deletedDocumentStore = storage.NewDynamoDBDeletedDocumentStore(awsCfg, cfg.DeletedDocumentsTable)
+		documentDeletionService = services.NewStoreDocumentDeletionService(
+			deletedDocumentStore,
+			aws.NewS3ObjectStorageClient(awsCfg),
+			cfg,
+		)
+	}
+
+	// Read full source documents from S3 for comparisons and summaries (optional), with the
+	// extracted text cached in /tmp, which survives between invocations of a warm sandbox
+	var documentContentClient aws.DocumentContentClient
+	if cfg.DocumentContentSource == "s3" {
+		var contentCache aws.ContentCache
+		if cfg.ContentCacheMaxMB > 0 {
+			if diskCache, err := storage.NewDiskContentCache(cfg.ContentCacheDir, int64(cfg.ContentCacheMaxMB)<<20); err == nil {
+				contentCache = diskCache
+			}
+		}
+		documentContentClient = aws.NewS3DocumentContentClient(awsCfg, contentCache)
+	}
+
+	// Check the configured models against the region on startup, models only served through
+	// inference profiles are invoked through a profile of the region
+	if cfg.BedrockModelProbe {
+		resolutions, err := aws.NewModelProbe(awsCfg, cfg.AWSRegion).Resolve(context.Background(), append([]string{cfg.GenerativeModelId, cfg.CandidateModelId, cfg.EmbeddingModelId}, cfg.FallbackModelIds...))
+		if _, ok := err.(*aws.ModelUnavailableError); ok {
+			log.Fatalf("Bedrock model check failed: %v", err)
+		}
+		if err != nil {
+			log.Printf("Skipping Bedrock model check: %v", err)
+		} else {
+			for _, resolution := range resolutions {
+				log.Printf("Bedrock model %s", resolution)
+			}
+			aws.UseModelResolutions(resolutions)
+		}
+
+		// Check the size of the embeddings against the vector index of the knowledge bases too
+		err = aws.NewEmbeddingDimensionsCheck(awsCfg).Check(context.Background(), cfg.Current().KnowledgeBaseIds(), cfg.EmbeddingModelId, cfg.EmbeddingDimensions)
+		if _, ok := err.(*aws.EmbeddingDimensionsError); ok {
+			log.Fatalf("Embedding dimensions check failed: %v", err)
+		}
+		if err != nil {
+			log.Printf("Skipping embedding dimensions check: %v", err)
+		}
+	}
+
+	// Links to source documents, pre-signed for buckets that are not public
+	var documentLinker aws.DocumentLinker = aws.NewPublicDocumentLinker(cfg.AWSRegion)
+	if cfg.DocumentLinkMode == "presigned" {
+		documentLinker = aws.NewPresignedDocumentLinker(aws.NewS3ObjectStorageClient(awsCfg), cfg.AWSRegion, time.Duration(cfg.DocumentLinkExpirySeconds)*time.Second)
+	}
+
+	// Create AWS clients, retrieving the configured number of chunks per knowledge base and
+	// dropping those below the minimum score unless the request asks otherwise
+	retrievalSettings := aws.RetrievalSettings{NumberOfResults: cfg.RetrievalResults, MinScore: &cfg.RetrievalMinScore}
+	generationSettings := aws.GenerationSettings{Temperature: cfg.KBGenerationTemperature, TopP: cfg.KBGenerationTopP, MaxTokens: cfg.KBGenerationMaxTokens, PromptTemplate: cfg.KBPromptTemplate}
+	// Each knowledge base has its own bound on queries in flight and failure budget, shared by
+	// the answers and their candidate variant
+	kbBulkheads := aws.NewKnowledgeBaseBulkheads(cfg.KBMaxConcurrentQueries, cfg.KBFailureBudget, time.Duration(cfg.KBSuspendSeconds)*time.Second)
+	kbQueryTimeout := time.Duration(cfg.KBQueryTimeoutMs) * time.Millisecond
+	embeddingClient := aws.NewBedrockEmbeddingClient(awsCfg, cfg.LiveSettings.EmbeddingModelId, aws.EmbeddingOptions{Dimensions: cfg.EmbeddingDimensions, Normalize: cfg.EmbeddingNormalize})
+	kbClient := aws.NewBedrockKBClient(awsCfg, cfg.LiveSettings.KnowledgeBases, cfg.LiveSettings.GenerativeModelId, cfg.LiveSettings.FallbackModelIds, cfg.AWSRegion, cfg.LiveSettings.QuestionSearchInstructionsFor, cfg.LiveSettings.SynthesisInstructions, documentDeletionService, documentLinker, cfg.RetrievalSearchType, retrievalSettings, generationSettings, kbBulkheads, kbQueryTimeout)
+	openSearchClient := aws.NewBedrockOpenSearchClient(awsCfg, cfg.Current().KnowledgeBaseIds()[0], documentLinker, kbClient, cfg.GenerativeModelId, cfg.LiveSettings.DocumentComparisonInstructions, cfg.LiveSettings.DocumentSummaryInstructions, documentDeletionService, documentContentClient)
+
+	// A Redis shared with the container deployment backs the answer, embedding, comparison and
+	// idempotency caches; without one they stay in each execution environment's memory
+	var sharedCache cache.Store
+	if cfg.CacheRedisAddr != "" {
+		var redisPassword string
+		if cfg.CacheRedisAuthSecretId != "" {
+			redisPassword, err = aws.NewSecretsManagerClient(awsCfg).GetSecretString(context.Background(), cfg.CacheRedisAuthSecretId)
+			if err != nil {
+				log.Fatalf("Failed to load cache credentials: %v", err)
+			}
+		}
+		sharedCache = cache.NewRedisStore(cfg.CacheRedisAddr, redisPassword, cfg.CacheRedisTLS)
+	}
+
+	// Embeddings of repeated texts, shared with the container deployment when a Redis is set.
+	// Warm-ups use the client without the cache, to reach Bedrock.
+	var embeddingCache storage.EmbeddingCache = storage.NewMemoryEmbeddingCache(cfg.EmbeddingCacheMaxEntries)
+	if sharedCache != nil {
+		embeddingCache = storage.NewStoreEmbeddingCache(sharedCache)
+	}
+	cachingEmbeddingClient := services.NewCachingEmbeddingClient(embeddingClient, embeddingCache, cfg.LiveSettings.EmbeddingModelId, cfg)
+
+	// Create optional DynamoDB stores
+	var summaryStore storage.DocumentSummaryStore
+	if cfg.DocumentSummaryTable != "" {
+		summaryStore = storage.NewDynamoDBDocumentSummaryStore(awsCfg, cfg.DocumentSummaryTable)
+	}
+	var comparisonStore storage.VersionComparisonStore
+	if cfg.VersionComparisonTable != "" {
+		comparisonStore = storage.NewDynamoDBVersionComparisonStore(awsCfg, cfg.VersionComparisonTable)
+	} else if sharedCache != nil {
+		comparisonStore = storage.NewCacheVersionComparisonStore(sharedCache)
+	}
+	var notFoundStore storage.NotFoundStore
+	if cfg.NotFoundTable != "" {
+		notFoundStore = storage.NewDynamoDBNotFoundStore(awsCfg, cfg.NotFoundTable)
+	}
+	var normalizationDictionary *normalization.Dictionary
+	if cfg.NormalizationTable != "" {
+		normalizationDictionary = normalization.New(
+			storage.NewDynamoDBNormalizationStore(awsCfg, cfg.NormalizationTable),
+			time.Duration(cfg.NormalizationRefreshSeconds)*time.Second,
+		)
+		normalization.Initialize(normalizationDictionary)
+	}
+	if cfg.SynonymsEnabled {
+		synonyms, err := normalization.LoadSynonyms(context.Background(), aws.NewS3ObjectStorageClient(awsCfg), cfg.SynonymsBucket, cfg.SynonymsKey, cfg.Synonyms)
+		if err != nil {
+			log.Fatalf("Failed to load the synonym file: %v", err)
+		}
+		// Without the normalization table the synonyms apply alone and the admin API stays off
+		dictionary := normalizationDictionary
+		if dictionary == nil {
+			dictionary = normalization.New(nil, 0)
+			normalization.Initialize(dictionary)
+		}
+		dictionary.SetSynonyms(synonyms)
+	}
+
+	// Questions are logged with personal data redacted, by pattern unless PII detection is on
+	if cfg.PIIDetectionEnabled {
+		redaction.Initialize(redaction.New(aws.NewComprehendPIIClient(awsCfg)))
+	}
+
+	// Answer backends, selected per tenant or endpoint policy with ANSWER_BACKEND as default
+	var agentClient aws.AgentClient
+	if cfg.BedrockAgentId != "" {
+		agentClient = aws.NewBedrockAgentClient(awsCfg, cfg.BedrockAgentId, cfg.BedrockAgentAliasId, cfg.LiveSettings.KnowledgeBaseIds, documentLinker, cfg.RetrievalSearchType)
+	}
+	generationClient := aws.NewBedrockGenerationClient(awsCfg, cfg.LiveSettings.GenerativeModelId)
+	answerBackends, err := services.NewAnswerBackends(cfg, kbClient, generationClient, agentClient)
+	if err != nil {
+		log.Fatalf("Invalid answer backend settings: %v", err)
+	}
+
+	// Create services
+	var questionSearchService services.QuestionSearchService = services.NewBedrockQuestionSearchService(
+		cachingEmbeddingClient,
+		kbClient,
+		notFoundStore,
+		answerBackends,
+		cfg,
+	)
+
+	// Search only the knowledge bases for the question's topic, when intents are configured
+	if cfg.IntentKeywords != "" {
+		intentClassifier, err := services.NewKeywordIntentClassifier(cfg.IntentKeywords)
+		if err != nil {
+			log.Fatalf("Invalid intent routing settings: %v", err)
+		}
+		questionSearchService = services.NewIntentRoutingQuestionSearchService(questionSearchService, intentClassifier)
+		log.Println("Intent routing enabled")
+	}
+
+	// Answers to repeated questions, shared between instances when a Redis is set
+	var answerCache storage.AnswerCache = storage.NewMemoryAnswerCache(cfg.AnswerCacheMaxEntries)
+	if sharedCache != nil {
+		answerCache = storage.NewStoreAnswerCache(sharedCache)
+	}
+	questionSearchService = services.NewCachingQuestionSearchService(questionSearchService, answerCache, cfg)
+
+	// Clarification prompts for broad or multi-part questions, behind the question-clarification flag
+	clarifyingService, err := services.NewClarifyingQuestionSearchService(questionSearchService, cfg)
+	if err != nil {
+		log.Fatalf("Invalid clarification settings: %v", err)
+	}
+	questionSearchService = clarifyingService
+
+	// Per-session question rate and token limits, shared between instances when a table is set
+	if cfg.SessionMaxQuestionsPerMinute > 0 || cfg.SessionMaxTokens > 0 {
+		var sessionCounters storage.CounterStore = storage.NewMemoryCounterStore()
+		if cfg.SessionLimitTable != "" {
+			sessionCounters = storage.NewDynamoDBCounterStore(awsCfg, cfg.SessionLimitTable)
+		}
+		questionSearchService = services.NewSessionLimitedQuestionSearchService(questionSearchService, sessionCounters, cfg)
+	}
+
+	// Prompt and model A/B test, sticky per session, with answer and feedback counts per variant
+	// shared between instances when a table is set (optional)
+	experiment, err := experiments.Parse(cfg.Experiment)
+	if err != nil {
+		log.Fatalf("Invalid EXPERIMENT: %v", err)
+	}
+	var experimentService services.ExperimentService
+	if experiment != nil || cfg.ExperimentTable != "" {
+		var experimentStore storage.ExperimentStore = storage.NewMemoryExperimentStore()
+		if cfg.ExperimentTable != "" {
+			experimentStore = storage.NewDynamoDBExperimentStore(awsCfg, cfg.ExperimentTable)
+		}
+		experimentService = services.NewStoreExperimentService(experiment, experimentStore, cfg)
+	}
+
+	var feedbackService services.FeedbackService
+	if cfg.FeedbackTable != "" {
+		feedbackService = services.NewStoreFeedbackService(storage.NewDynamoDBFeedbackStore(awsCfg, cfg.FeedbackTable), experimentService, cfg)
+		questionSearchService = services.NewFeedbackQuestionSearchService(questionSearchService, feedbackService)
+	}
+
+	// Outside the answer cache and the feedback records, so both see the variant
+	if experiment != nil {
+		questionSearchService = services.NewExperimentQuestionSearchService(questionSearchService, experimentService)
+	}
+
+	var historyService services.HistoryService
+	if cfg.HistoryTable != "" {
+		historyService = services.NewStoreHistoryService(storage.NewDynamoDBConversationHistoryStore(awsCfg, cfg.HistoryTable), cfg)
+		questionSearchService = services.NewHistoryQuestionSearchService(questionSearchService, historyService)
+	}
+
+	var auditService services.AuditService
+	var auditStore storage.AuditStore
+	if cfg.AuditBucket != "" {
+		auditStore = storage.NewS3AuditStore(awsCfg, cfg.AuditBucket, cfg.AuditPrefix, cfg.AuditRetentionDays)
+		auditService = services.NewStoreAuditService(auditStore)
+		questionSearchService = services.NewAuditQuestionSearchService(questionSearchService, auditStore)
+	}
+
+	var usageStore storage.UsageStore = storage.NewMemoryUsageStore()
+	if cfg.UsageTable != "" {
+		usageStore = storage.NewDynamoDBUsageStore(awsCfg, cfg.UsageTable)
+	}
+	usageService, err := services.NewStoreUsageService(usageStore, cfg)
+	if err != nil {
+		log.Fatalf("Invalid token usage configuration: %v", err)
+	}
+
+	var documentEvents services.DocumentEventPublisher
+	if cfg.EventBusName != "" {
+		documentEvents = services.NewEventBridgeDocumentEvents(aws.NewEventBridgeClient(awsCfg), cfg.EventBusName)
+	}
+
+	documentDetailsService := services.NewOpenSearchDocumentService(
+		openSearchClient,
+		summaryStore,
+		comparisonStore,
+		documentEvents,
+		cfg,
+	)
+
+	documentSummaryService := services.NewBedrockDocumentSummaryService(
+		openSearchClient,
+		kbClient,
+		summaryStore,
+		cfg,
+	)
+
+	retrievalDiagnosticsService := services.NewBedrockRetrievalDiagnosticsService(kbClient, cfg)
+	relatedQuestionsService := services.NewBedrockRelatedQuestionsService(kbClient, generationClient, cfg)
+
+	// Jobs of long-running operations, polled at /jobs/{id}
+	var jobStore storage.JobStore
+	if cfg.JobsTable != "" {
+		jobStore = storage.NewDynamoDBJobStore(awsCfg, cfg.JobsTable)
+	}
+
+	ingestionClient := aws.NewBedrockIngestionClient(awsCfg)
+	ingestionService := services.NewBedrockIngestionService(ingestionClient, cfg.LiveSettings.KnowledgeBaseIds, jobStore, time.Duration(cfg.JobTTLSeconds)*time.Second)
+	documentExportService := services.NewBedrockDocumentExportService(ingestionClient, documentLinker, documentDeletionService, cfg.LiveSettings.KnowledgeBaseIds)
+
+	// Permanent deletion of retired documents, recorded on the audit trail when there is one
+	documentRemovalService := services.NewS3DocumentRemovalService(
+		aws.NewS3ObjectStorageClient(awsCfg),
+		cfg.DocumentArchiveBucket,
+		ingestionService,
+		auditStore,
+		deletedDocumentStore,
+		cfg,
+	)
+
+	// Extract the text of scanned PDFs uploaded to the knowledge base bucket (optional)
+	if cfg.ScannedPdfOcrEnabled {
+		objectStorage := aws.NewS3ObjectStorageClient(awsCfg)
+		scannedDocumentService = services.NewTextractScannedDocumentService(
+			objectStorage,
+			objectStorage,
+			aws.NewTextractClient(awsCfg),
+			ingestionService,
+			cfg.ScannedPdfSyncDataSource,
+			cfg.ScannedPdfMinTextCharacters,
+		)
+	}
+
+	answerDiffService := services.NewBedrockAnswerDiffService(
+		kbClient,
+		aws.NewBedrockKBClient(awsCfg, cfg.LiveSettings.KnowledgeBases, cfg.LiveSettings.CandidateModelId, nil, cfg.AWSRegion, cfg.LiveSettings.CandidateInstructionsFor, cfg.LiveSettings.SynthesisInstructions, documentDeletionService, documentLinker, cfg.RetrievalSearchType, retrievalSettings, generationSettings, kbBulkheads, kbQueryTimeout),
+		aws.NewBedrockAnswerComparisonClient(awsCfg, cfg.LiveSettings.GenerativeModelId, cfg.LiveSettings.AnswerDiffInstructions),
+		cfg,
+	)
+
+	// Evaluation runs of the golden dataset, with the answers graded by the generative model
+	var evaluationService eval.Service
+	if cfg.EvalBucket != "" {
+		evaluationService = eval.NewEvaluator(
+			eval.NewS3Store(aws.NewS3ObjectStorageClient(awsCfg), cfg.EvalBucket, cfg.EvalDatasetKey, cfg.EvalReportPrefix),
+			eval.NewGenerationJudge(generationClient, cfg.LiveSettings.AnswerJudgeInstructions),
+			cfg.EvalConcurrency,
+		)
+	}
+
+	var translationService services.TranslationService
+	switch cfg.TranslationProvider {
+	case "translate":
+		translationService = services.NewClientTranslationService(aws.NewAmazonTranslateClient(awsCfg))
+	case "bedrock":
+		translationService = services.NewClientTranslationService(aws.NewBedrockTranslationClient(awsCfg, cfg.LiveSettings.GenerativeModelId))
+	}
+
+	// Answer disclaimers per tenant or endpoint, added after the answer is generated
+	answerDisclaimers, err := services.NewAnswerDisclaimers(cfg)
+	if err != nil {
+		log.Fatalf("Invalid disclaimer settings: %v", err)
+	}
+
+	// Caller identity from bearer tokens, also when API Gateway already checked them (optional)
+	authenticator := auth.NewAuthenticator(cfg)
+	iamVerifier := auth.NewIamVerifier(cfg)
+
+	// Per-document access control by the roles in the caller's bearer token (optional)
+	accessControl, err := auth.NewAccessControl(cfg)
+	if err != nil {
+		log.Fatalf("Invalid access control settings: %v", err)
+	}
+
+	var knowledgeGapService services.KnowledgeGapService
+	if notFoundStore != nil {
+		knowledgeGapService = services.NewStoreKnowledgeGapService(notFoundStore)
+	}
+
+	// Daily analytics export to S3 for Athena (optional)
+	var analyticsExportService services.AnalyticsExportService
+	if cfg.AnalyticsExportBucket != "" && (notFoundStore != nil || deletedDocumentStore != nil) {
+		analyticsExportService = services.NewS3AnalyticsExportService(notFoundStore, deletedDocumentStore, aws.NewS3ObjectStorageClient(awsCfg), cfg)
+	}
+
+	var webhookService services.WebhookService
+	if cfg.WebhookTable != "" {
+		webhookService = services.NewHTTPWebhookService(storage.NewDynamoDBWebhookStore(awsCfg, cfg.WebhookTable), cfg)
+	}
+
+	var apiKeyService services.ApiKeyService
+	if cfg.ApiKeyTable != "" {
+		var quotaCounters storage.CounterStore = storage.NewMemoryCounterStore()
+		if cfg.ApiKeyQuotaTable != "" {
+			quotaCounters = storage.NewDynamoDBCounterStore(awsCfg, cfg.ApiKeyQuotaTable)
+		}
+		apiKeyService = services.NewStoreApiKeyService(storage.NewDynamoDBApiKeyStore(awsCfg, cfg.ApiKeyTable), quotaCounters)
+	}
+
+	var idempotencyStore storage.IdempotencyStore
+	if cfg.IdempotencyTTLSeconds > 0 {
+		idempotencyStore = storage.NewMemoryIdempotencyStore()
+		if cfg.IdempotencyTable != "" {
+			idempotencyStore = storage.NewDynamoDBIdempotencyStore(awsCfg, cfg.IdempotencyTable)
+		} else if sharedCache != nil {
+			idempotencyStore = storage.NewCacheIdempotencyStore(sharedCache)
+		}
+	}
+
+	// Posts finished asynchronous jobs to the callback URL they were submitted with
+	jobCallbackService := services.NewHTTPJobCallbackService(cfg)
+
+	if cfg.QuestionJobQueueUrl != "" {
+		questionJobService = services.NewQueueQuestionJobService(
+			aws.NewSQSQueueClient(awsCfg),
+			cfg.QuestionJobQueueUrl,
+			jobStore,
+			time.Duration(cfg.QuestionJobTTLSeconds)*time.Second,
+			jobCallbackService,
+		)
+	}
+
+	if cfg.SummaryWorkflowArn != "" {
+		summaryWorkflowService = services.NewStepFunctionsSummaryWorkflowService(
+			aws.NewStepFunctionsWorkflowClient(awsCfg),
+			cfg.SummaryWorkflowArn,
+			storage.NewDynamoDBSummaryJobStore(awsCfg, cfg.SummaryJobsTable),
+			jobStore,
+			documentSummaryService,
+			jobCallbackService,
+			cfg,
+		)
+	}
+
+	var jobService services.JobService
+	if jobStore != nil {
+		jobService = services.NewStoreJobService(jobStore, map[string]services.JobRefresher{
+			storage.JobTypeIngestion: ingestionService,
+		})
+	}
+
+	var documentResummarizeService services.DocumentResummarizeService
+	if summaryStore != nil && cfg.JobCheckpointTable != "" {
+		documentResummarizeService = services.NewBedrockDocumentResummarizeService(
+			openSearchClient,
+			summaryStore,
+			storage.NewDynamoDBJobCheckpointStore(awsCfg, cfg.JobCheckpointTable),
+			webhookService,
+			documentEvents,
+			cfg,
+		)
+	}
+
+	var digestService services.DigestService
+	if summaryStore != nil && cfg.DigestSubscriptionTable != "" {
+		digestService = services.NewStoreDigestService(
+			summaryStore,
+			storage.NewDynamoDBDigestSubscriptionStore(awsCfg, cfg.DigestSubscriptionTable),
+			aws.NewSESNotificationClient(awsCfg),
+			cfg,
+		)
+	}
+
+	// Load the response signing key (optional)
+	var responseSigningKey []byte
+	if cfg.ResponseSigningSecretId != "" {
+		secret, err := aws.NewSecretsManagerClient(awsCfg).GetSecretString(context.Background(), cfg.ResponseSigningSecretId)
+		if err != nil {
+			log.Fatalf("Failed to load response signing key: %v", err)
+		}
+		responseSigningKey = []byte(secret)
+	}
+
+	var promptService services.PromptService
+	if cfg.ConfigSSMPrefix != "" {
+		promptService = services.NewStorePromptService(storage.NewSSMPromptStore(awsCfg, cfg.ConfigSSMPrefix), cfg.LiveSettings)
+	}
+
+	// Error rate and throttling alerts paged through SNS (optional)
+	var alertMonitor *alerting.Monitor
+	if cfg.AlertTopicArn != "" {
+		alertMonitor = alerting.New(aws.NewSESNotificationClient(awsCfg), cfg.AlertTopicArn, "teletubpax-api "+cfg.Environment, alerting.Thresholds{
+			Window:       time.Duration(cfg.AlertWindowSeconds) * time.Second,
+			MinRequests:  cfg.AlertMinRequests,
+			ErrorRate:    float64(cfg.AlertErrorRatePercent) / 100,
+			ThrottleRate: float64(cfg.AlertThrottleRatePercent) / 100,
+			Cooldown:     time.Duration(cfg.AlertCooldownSeconds) * time.Second,
+		})
+	}
+
+	// Readiness also fails while the AWS credentials cannot be resolved, e.g. an expired role
+	var readinessChecks []routing.ReadinessCheck
+	if cfg.AnswerBackend != services.AnswerBackendStub {
+		readinessChecks = append(readinessChecks, routing.ReadinessCheck{Name: "aws_credentials", Check: func(ctx context.Context) error {
+			_, err := awsCfg.Credentials.Retrieve(ctx)
+			return err
+		}})
+	}
+
+	// Setup routes
+	router := routing.SetupRoutes(routing.RouteServices{
+		QuestionSearch:       questionSearchService,
+		DocumentDetails:      documentDetailsService,
+		DocumentSummary:      documentSummaryService,
+		DocumentResummarize:  documentResummarizeService,
+		RetrievalDiagnostics: retrievalDiagnosticsService,
+		RelatedQuestions:     relatedQuestionsService,
+		Ingestion:            ingestionService,
+		AnswerDiff:           answerDiffService,
+		Evaluation:           evaluationService,
+		Experiments:          experimentService,
+		Prompts:              promptService,
+		KnowledgeGaps:        knowledgeGapService,
+		AnalyticsExport:      analyticsExportService,
+		DocumentDeletion:     documentDeletionService,
+		DocumentRemoval:      documentRemovalService,
+		DocumentExport:       documentExportService,
+		Webhooks:             webhookService,
+		Digest:               digestService,
+		Feedback:             feedbackService,
+		History:              historyService,
+		Audit:                auditService,
+		Usage:                usageService,
+		ApiKeys:              apiKeyService,
+		Idempotency:          idempotencyStore,
+		QuestionJobs:         questionJobService,
+		Jobs:                 jobService,
+		SummaryJobs:          summaryWorkflowService,
+		Translation:          translationService,
+		Disclaimers:          answerDisclaimers,
+		FeatureFlags:         featureFlags,
+		Normalization:        normalizationDictionary,
+		Policies:             endpointPolicies,
+		Authenticator:        authenticator,
+		IamVerifier:          iamVerifier,
+		AccessControl:        accessControl,
+		ResponseSigningKey:   responseSigningKey,
+		ReadinessChecks:      readinessChecks,
+		Alerts:               alertMonitor,
+	}, cfg)
+
+	// Create Lambda adapter for API Gateway V2 (HTTP API)
+	httpLambda = httpadapter.NewV2(router)
+	questionJobRunner = routing.QuestionJobRunner(router)
+
+	if cfg.ChatEnabled {
+		var chatConnections storage.ChatConnectionStore = storage.NewCacheChatConnectionStore(cache.NewMemoryStore(0))
+		if cfg.ChatConnectionsTable != "" {
+			chatConnections = storage.NewDynamoDBChatConnectionStore(awsCfg, cfg.ChatConnectionsTable)
+		} else if sharedCache != nil {
+			chatConnections = storage.NewCacheChatConnectionStore(sharedCache)
+		}
+		chatGateway = routing.NewChatGateway(router, chatConnections)
+		webSocketClient = aws.NewApiGatewayWebSocketClient(awsCfg)
+	}
+
+	// Clients are created above, but credentials, connections and SSM parameters are only
+	// fetched by the first request. A warm-up does it ahead of the morning's first question.
+	warmUp = func(ctx context.Context) {
+		start := time.Now()
+		if _, err := awsCfg.Credentials.Retrieve(ctx); err != nil {
+			log.Printf("Warm-up failed to resolve AWS credentials: %v", err)
+			return
+		}
+		if cfg.WarmUpPrefetchPrompts {
+			if err := cfg.LiveSettings.Reload(ctx); err != nil {
+				log.Printf("Warm-up failed to reload settings: %v", err)
+			}
+		}
+		// Opens the embedding client's connection to Bedrock Runtime, the credentials retrieved
+		// above are cached for every client
+		if _, err := embeddingClient.GenerateEmbedding(ctx, "warm-up"); err != nil {
+			log.Printf("Warm-up failed to reach Bedrock: %v", err)
+		}
+		log.Printf("Warm-up completed in %dms", time.Since(start).Milliseconds())
+	}
+
+	log.Println("Lambda initialization completed successfully")
+}
+
+func Handler(ctx context.Context, event lambdaEvent) (interface{}, error) {
+	if event.isWarmUp() {
+		warmUp(ctx)
+		return events.APIGatewayV2HTTPResponse{StatusCode: 200}, nil
+	}
+	if event.WebSocket != nil {
+		return handleChatEvent(ctx, event.WebSocket)
+	}
+	if len(event.Records) > 0 {
+		return handleQuestionJobs(ctx, event.Records), nil
+	}
+	if event.Task != "" {
+		return handleSummaryWorkflowTask(ctx, event.SummaryWorkflowTask)
+	}
+	if event.Source == "aws.s3" && event.DetailType == "Object Created" {
+		return handleObjectCreated(ctx, event.Detail)
+	}
+	req := event.APIGatewayV2HTTPRequest
+
+	// CORS headers and preflights are handled by the router (routing.CORSMiddleware)
+	if tracerProvider != nil {
+		// Continue the invocation's X-Ray trace when the caller sent no trace header, and export
+		// the spans before the execution environment is frozen
+		if traceHeader := os.Getenv("_X_AMZN_TRACE_ID"); traceHeader != "" && req.Headers["x-amzn-trace-id"] == "" && req.Headers["traceparent"] == "" {
+			if req.Headers == nil {
+				req.Headers = map[string]string{}
+			}
+			req.Headers["x-amzn-trace-id"] = traceHeader
+		}
+		defer tracerProvider.ForceFlush(ctx)
+	}
+	return httpLambda.ProxyWithContext(ctx, req)
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -0,0 +1,53 @@
+//go:build lambda
+// +build lambda
+
+// SQS worker of the Lambda entry point, answering the questions queued by
+// POST /question-search/async. It runs in its own function, subscribed to the queue with
+// a timeout well above API Gateway's 30 seconds.
+
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"teletubpax-api/services"
+)
+
+// questionJobService is nil when QUESTION_JOB_QUEUE_URL is not set
+var questionJobService services.QuestionJobService
+
+// questionJobRunner serves queued questions through the router
+var questionJobRunner services.QuestionJobRunner
+
+// maxQuestionJobAttempts is the queue's maxReceiveCount: on the last delivery a throttled
+// question fails instead of being retried, so its job does not stay queued forever
+const maxQuestionJobAttempts = 3
+
+// handleQuestionJobs answers a batch of queued questions. Messages that should be delivered
+// again are reported as batch item failures, so the rest of the batch is not retried.
+func handleQuestionJobs(ctx context.Context, records []events.SQSMessage) events.SQSEventResponse {
+	if tracerProvider != nil {
+		defer tracerProvider.ForceFlush(ctx)
+	}
+
+	var response events.SQSEventResponse
+	for _, record := range records {
+		if questionJobService == nil {
+			log.Printf("Received queued question %s, but QUESTION_JOB_QUEUE_URL is not set", record.MessageId)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+			continue
+		}
+
+		receiveCount, _ := strconv.Atoi(record.Attributes["ApproximateReceiveCount"])
+		lastAttempt := receiveCount >= maxQuestionJobAttempts
+		if err := questionJobService.Process(ctx, record.Body, questionJobRunner, lastAttempt); err != nil {
+			log.Printf("Queued question %s will be retried: %v", record.MessageId, err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+		}
+	}
+	return response
+}
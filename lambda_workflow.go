@@ -0,0 +1,29 @@
+//go:build lambda
+// +build lambda
+
+// Task handler of the Lambda entry point for the bulk summary workflow, which the Step
+// Functions state machine invokes with a {"summaryWorkflowTask": ...} input per step.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"teletubpax-api/services"
+)
+
+// summaryWorkflowService is nil when SUMMARY_WORKFLOW_ARN is not set
+var summaryWorkflowService services.SummaryWorkflowService
+
+// handleSummaryWorkflowTask runs one task of the workflow. Its error fails the state, which
+// the state machine retries and finally catches to fail the job.
+func handleSummaryWorkflowTask(ctx context.Context, task services.SummaryWorkflowTask) (interface{}, error) {
+	if tracerProvider != nil {
+		defer tracerProvider.ForceFlush(ctx)
+	}
+	if summaryWorkflowService == nil {
+		return nil, fmt.Errorf("received summary workflow task %q, but SUMMARY_WORKFLOW_ARN is not set", task.Task)
+	}
+	return summaryWorkflowService.RunTask(ctx, task)
+}
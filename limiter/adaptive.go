@@ -0,0 +1,213 @@
+package limiter
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"teletubpax-api/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const (
+	adaptiveBackoff  = 0.5 // The rate of an operation is multiplied by it on every throttled call
+	adaptiveIncrease = 0.1 // Calls per second the rate grows by on every successful call
+)
+
+// throttlingCodes are the error codes of calls refused over the account's quotas
+var throttlingCodes = map[string]bool{
+	"ThrottlingException":      true,
+	"TooManyRequestsException": true,
+}
+
+// AdaptiveThrottle paces the Bedrock model calls made with the configs it was added to, in the
+// style of the SDK's adaptive retry mode. Calls go out freely until Bedrock throttles one;
+// from then on every attempt of the operation, retries included, takes a token from a bucket
+// refilled at a rate that halves on every throttled call and grows on every successful one.
+// Once the rate is back at its maximum the operation goes out freely again. A spike then
+// slows down to what Bedrock accepts instead of its retries adding to the spike.
+type AdaptiveThrottle struct {
+	mu      sync.Mutex
+	minRate float64                 // Calls per second a throttled operation never drops below
+	maxRate float64                 // Calls per second at which an operation is no longer paced
+	buckets map[string]*tokenBucket // By operation
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	paced       bool
+	rate        float64 // Calls per second while paced
+	tokens      float64 // Negative while calls wait for their token
+	refilledAt  time.Time
+	decreasedAt time.Time // Calls sent before it were throttled at the rate before
+
+	// The rate calls were sent at, measured over windows of a second, which the first
+	// throttled call halves. A window a call ends after an idle spell measured the spell, not
+	// the calls, so it is dropped.
+	sent         int
+	windowStart  time.Time
+	measuredRate float64
+}
+
+func NewAdaptiveThrottle(minRate float64, maxRate float64) *AdaptiveThrottle {
+	return &AdaptiveThrottle{
+		minRate: minRate,
+		maxRate: maxRate,
+		buckets: map[string]*tokenBucket{},
+		now:     time.Now,
+	}
+}
+
+// AddTo registers the throttle with an AWS config. Clients created from the config afterwards
+// go through it.
+func (a *AdaptiveThrottle) AddTo(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		// After the SDK's retries, so every attempt takes a token and reports its outcome
+		return stack.Finalize.Insert(middleware.FinalizeMiddlewareFunc("AdaptiveThrottle", a.handleFinalize), "Retry", middleware.After)
+	})
+}
+
+func (a *AdaptiveThrottle) handleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	operation, limited := limitedOperation(ctx)
+	if !limited {
+		return next.HandleFinalize(ctx, in)
+	}
+
+	wait, err := a.reserve(ctx, operation)
+	if err != nil {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+	}
+	if wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return middleware.FinalizeOutput{}, middleware.Metadata{}, ctx.Err()
+		}
+	}
+
+	sentAt := a.now()
+	out, metadata, err := next.HandleFinalize(ctx, in)
+	a.record(ctx, operation, isThrottling(err), sentAt)
+	return out, metadata, err
+}
+
+func (a *AdaptiveThrottle) bucket(operation string) *tokenBucket {
+	bucket := a.buckets[operation]
+	if bucket == nil {
+		bucket = &tokenBucket{windowStart: a.now()}
+		a.buckets[operation] = bucket
+	}
+	return bucket
+}
+
+// reserve takes a token of the operation and returns how long the call waits for it. A call
+// that would wait past the deadline of ctx fails right away as throttled, and gives its token
+// back.
+func (a *AdaptiveThrottle) reserve(ctx context.Context, operation string) (time.Duration, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	bucket := a.bucket(operation)
+
+	if elapsed := now.Sub(bucket.windowStart); elapsed >= time.Second {
+		bucket.measuredRate = 0
+		if elapsed < 2*time.Second {
+			bucket.measuredRate = float64(bucket.sent) / elapsed.Seconds()
+		}
+		bucket.sent = 0
+		bucket.windowStart = now
+	}
+	bucket.sent++
+	if !bucket.paced {
+		return 0, nil
+	}
+
+	// A bucket holds at most a second of calls, so a quiet spell does not let a burst through
+	capacity := math.Max(1, bucket.rate)
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.refilledAt).Seconds()*bucket.rate)
+	bucket.refilledAt = now
+	bucket.tokens--
+	if bucket.tokens >= 0 {
+		return 0, nil
+	}
+
+	wait := time.Duration(-bucket.tokens / bucket.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(wait)) {
+		bucket.tokens++
+		return 0, pacedError{&smithy.GenericAPIError{
+			Code:    "ThrottlingException",
+			Message: fmt.Sprintf("Bedrock is throttling %s calls to %.1f per second, no call possible before the deadline", operation, bucket.rate),
+			Fault:   smithy.FaultClient,
+		}}
+	}
+	return wait, nil
+}
+
+// record adapts the rate of the operation to the outcome of a call sent at sentAt. Calls
+// throttled together, as in a burst, slow the operation down once.
+func (a *AdaptiveThrottle) record(ctx context.Context, operation string, throttled bool, sentAt time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	bucket := a.bucket(operation)
+
+	if !throttled {
+		if bucket.paced {
+			bucket.rate += adaptiveIncrease
+			if bucket.rate >= a.maxRate {
+				bucket.paced = false
+			}
+		}
+		return
+	}
+	if bucket.paced && sentAt.Before(bucket.decreasedAt) {
+		return
+	}
+
+	rate := bucket.rate
+	if !bucket.paced {
+		rate = bucket.sendRate(a.now())
+		if rate == 0 || rate > a.maxRate {
+			rate = a.maxRate
+		}
+		bucket.paced = true
+		bucket.tokens = 0
+		bucket.refilledAt = a.now()
+	}
+	bucket.rate = math.Max(a.minRate, rate*adaptiveBackoff)
+	bucket.decreasedAt = a.now()
+	logger.WithContext(ctx).Warn("Bedrock throttled, slowing down", map[string]interface{}{
+		"operation": operation,
+		"rate":      bucket.rate,
+	})
+}
+
+// sendRate is the rate calls were sent at over the last window and the one under way, 0 when
+// unmeasured
+func (b *tokenBucket) sendRate(now time.Time) float64 {
+	rate := b.measuredRate
+	if elapsed := now.Sub(b.windowStart).Seconds(); elapsed > 0 {
+		rate = math.Max(rate, float64(b.sent)/elapsed)
+	}
+	return rate
+}
+
+// pacedError is a call that could not get a token before its deadline. The SDK does not
+// retry it, a retry could not get one either.
+type pacedError struct {
+	*smithy.GenericAPIError
+}
+
+func (pacedError) RetryableError() bool {
+	return false
+}
+
+func isThrottling(err error) bool {
+	var apiErr smithy.APIError
+	return stdErrors.As(err, &apiErr) && throttlingCodes[apiErr.ErrorCode()]
+}
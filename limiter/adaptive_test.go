@@ -0,0 +1,144 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+func TestAdaptiveThrottle_AdaptsTheRate(t *testing.T) {
+	now := time.Date(2025, 5, 20, 3, 0, 0, 0, time.UTC)
+	throttle := NewAdaptiveThrottle(0.5, 4)
+	throttle.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Unthrottled calls go out freely
+	for i := 0; i < 10; i++ {
+		if wait, _ := throttle.reserve(ctx, "Converse"); wait != 0 {
+			t.Fatalf("expected no wait before throttling, got %v", wait)
+		}
+	}
+
+	// A burst throttled together halves the rate once
+	sentAt := now
+	now = now.Add(time.Second)
+	throttle.record(ctx, "Converse", true, sentAt)
+	throttle.record(ctx, "Converse", true, sentAt)
+	if rate := throttle.buckets["Converse"].rate; rate != 2 {
+		t.Fatalf("expected the maximum rate halved to 2, got %v", rate)
+	}
+
+	// Calls now wait for their token, other operations do not
+	if wait, _ := throttle.reserve(ctx, "Converse"); wait != 500*time.Millisecond {
+		t.Errorf("expected a wait of half a second, got %v", wait)
+	}
+	if wait, _ := throttle.reserve(ctx, "Converse"); wait != time.Second {
+		t.Errorf("expected the next call to wait behind it, got %v", wait)
+	}
+	if wait, _ := throttle.reserve(ctx, "InvokeModel"); wait != 0 {
+		t.Errorf("expected other operations to go out freely, got %v", wait)
+	}
+
+	// A call that cannot get a token before its deadline fails right away
+	deadlineCtx, cancel := context.WithDeadline(ctx, now.Add(100*time.Millisecond))
+	defer cancel()
+	if _, err := throttle.reserve(deadlineCtx, "Converse"); err == nil || !strings.Contains(err.Error(), "ThrottlingException") {
+		t.Errorf("expected a ThrottlingException, got %v", err)
+	}
+
+	// Successes grow the rate back until calls go out freely again
+	for i := 0; i < 20; i++ {
+		throttle.record(ctx, "Converse", false, now)
+	}
+	if bucket := throttle.buckets["Converse"]; bucket.paced {
+		t.Errorf("expected the rate to recover, got %v", bucket.rate)
+	}
+}
+
+func TestAdaptiveThrottle_NeverDropsBelowMinimum(t *testing.T) {
+	now := time.Date(2025, 5, 20, 3, 0, 0, 0, time.UTC)
+	throttle := NewAdaptiveThrottle(0.5, 4)
+	throttle.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		now = now.Add(time.Second)
+		throttle.record(context.Background(), "InvokeModel", true, now)
+	}
+	if rate := throttle.buckets["InvokeModel"].rate; rate != 0.5 {
+		t.Errorf("expected the minimum rate, got %v", rate)
+	}
+}
+
+func TestAdaptiveThrottle_HalvesTheBurstRateAfterIdleSpell(t *testing.T) {
+	now := time.Date(2025, 5, 20, 3, 0, 0, 0, time.UTC)
+	throttle := NewAdaptiveThrottle(0.5, 20)
+	throttle.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	throttle.reserve(ctx, "Converse")
+	now = now.Add(10 * time.Minute)
+
+	// A burst of 8 calls in half a second after the idle spell is throttled
+	for i := 0; i < 8; i++ {
+		throttle.reserve(ctx, "Converse")
+	}
+	sentAt := now
+	now = now.Add(500 * time.Millisecond)
+	throttle.record(ctx, "Converse", true, sentAt)
+	if rate := throttle.buckets["Converse"].rate; rate != 8 {
+		t.Errorf("expected the burst rate of 16 calls per second halved to 8, got %v", rate)
+	}
+}
+
+func TestAdaptiveThrottle_PacesCallsAfterThrottling(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("X-Amzn-Errortype", "ThrottlingException")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message": "Too many requests"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	cfg := aws.Config{
+		Region:           "us-east-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		BaseEndpoint:     aws.String(server.URL),
+		RetryMaxAttempts: 1,
+	}
+	NewAdaptiveThrottle(1, 10).AddTo(&cfg)
+	client := bedrockruntime.NewFromConfig(cfg)
+
+	if err := invokeModel(client); err == nil || !strings.Contains(err.Error(), "ThrottlingException") {
+		t.Fatalf("expected the first call to be throttled, got %v", err)
+	}
+
+	// Paced at 5 calls per second, the next call waits for its token
+	start := time.Now()
+	if err := invokeModel(client); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected the call to wait for its token, it took %v", elapsed)
+	}
+
+	// One that cannot get a token in time is not sent
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{ModelId: aws.String("amazon.titan-embed-text-v2:0"), Body: []byte(`{}`)})
+	if err == nil || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("expected the call to fail without being sent, got %v after %d calls", err, atomic.LoadInt32(&calls))
+	}
+}
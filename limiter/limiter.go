@@ -0,0 +1,151 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"teletubpax-api/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// limitedOperations are the Bedrock operations that invoke a model, as "service/Operation",
+// with the operation whose limit they share: a stream counts as its operation
+var limitedOperations = map[string]string{
+	"bedrock-runtime/Converse":                        "Converse",
+	"bedrock-runtime/ConverseStream":                  "Converse",
+	"bedrock-runtime/InvokeModel":                     "InvokeModel",
+	"bedrock-runtime/InvokeModelWithResponseStream":   "InvokeModel",
+	"bedrock-agent-runtime/RetrieveAndGenerate":       "RetrieveAndGenerate",
+	"bedrock-agent-runtime/RetrieveAndGenerateStream": "RetrieveAndGenerate",
+	"bedrock-agent-runtime/InvokeAgent":               "InvokeAgent",
+}
+
+// Limiter caps the Bedrock model calls in flight at once, made with the configs it was added
+// to, so a traffic spike queues calls instead of failing them with ThrottlingExceptions from
+// the account's quotas. Calls over a limit wait in line for a free slot and fail as throttled
+// once they have waited for the queue timeout. A slot is held across the SDK's retries and,
+// for streams, until the stream has started.
+type Limiter struct {
+	slots          chan struct{}            // Nil without a global limit
+	operationSlots map[string]chan struct{} // By operation, for the operations with a limit
+	queueTimeout   time.Duration
+}
+
+// New returns a limiter of maxConcurrency calls overall, 0 for no limit, and of the
+// operationLimits calls per operation
+func New(maxConcurrency int, operationLimits map[string]int, queueTimeout time.Duration) *Limiter {
+	limiter := &Limiter{
+		operationSlots: map[string]chan struct{}{},
+		queueTimeout:   queueTimeout,
+	}
+	if maxConcurrency > 0 {
+		limiter.slots = make(chan struct{}, maxConcurrency)
+	}
+	for operation, limit := range operationLimits {
+		limiter.operationSlots[operation] = make(chan struct{}, limit)
+	}
+	return limiter
+}
+
+// ParseOperationLimits parses BEDROCK_OPERATION_LIMITS entries, "Operation=limit" with one of
+// Converse, InvokeModel, RetrieveAndGenerate or InvokeAgent
+func ParseOperationLimits(values []string) (map[string]int, error) {
+	limits := map[string]int{}
+	for _, value := range values {
+		operation, limitValue, found := strings.Cut(value, "=")
+		operation = strings.TrimSpace(operation)
+		if !found || !knownOperation(operation) {
+			return nil, fmt.Errorf("invalid operation limit %q, expected Converse, InvokeModel, RetrieveAndGenerate or InvokeAgent=limit", value)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(limitValue))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid operation limit %q, the limit must be a positive number", value)
+		}
+		limits[operation] = limit
+	}
+	return limits, nil
+}
+
+func knownOperation(operation string) bool {
+	for _, limited := range limitedOperations {
+		if limited == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTo registers the limiter with an AWS config. Clients created from the config afterwards
+// go through it.
+func (l *Limiter) AddTo(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ConcurrencyLimit", l.handleInitialize), middleware.After)
+	})
+}
+
+// limitedOperation returns the operation whose limit the call shares, false for calls
+// that are not limited
+func limitedOperation(ctx context.Context) (string, bool) {
+	service := strings.ReplaceAll(strings.ToLower(awsmiddleware.GetServiceID(ctx)), " ", "-")
+	operation, limited := limitedOperations[service+"/"+awsmiddleware.GetOperationName(ctx)]
+	return operation, limited
+}
+
+func (l *Limiter) handleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	operation, limited := limitedOperation(ctx)
+	if !limited {
+		return next.HandleInitialize(ctx, in)
+	}
+
+	release, err := l.acquire(ctx, operation)
+	if err != nil {
+		return middleware.InitializeOutput{}, middleware.Metadata{}, err
+	}
+	defer release()
+	return next.HandleInitialize(ctx, in)
+}
+
+// acquire waits for a slot of the operation and then for a slot overall, always in that order
+// so calls never wait on each other's slots, and returns the function that frees them
+func (l *Limiter) acquire(ctx context.Context, operation string) (func(), error) {
+	var held []chan struct{}
+	release := func() {
+		for _, slots := range held {
+			<-slots
+		}
+	}
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	for _, slots := range []chan struct{}{l.operationSlots[operation], l.slots} {
+		if slots == nil {
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+			held = append(held, slots)
+		case <-timer.C:
+			release()
+			logger.WithContext(ctx).Warn("Bedrock call queued for too long", map[string]interface{}{
+				"operation":     operation,
+				"queue_timeout": l.queueTimeout.String(),
+			})
+			return nil, &smithy.GenericAPIError{
+				Code:    "ThrottlingException",
+				Message: fmt.Sprintf("Too many Bedrock calls in flight, no free slot within %s", l.queueTimeout),
+				Fault:   smithy.FaultClient,
+			}
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
@@ -0,0 +1,128 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// newTestClient returns a Bedrock runtime client served by a local server that answers every
+// call with an empty JSON object once release is closed, and a count of the calls that
+// reached it
+func newTestClient(t *testing.T, limiter *Limiter, release chan struct{}) (*bedrockruntime.Client, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := aws.Config{
+		Region:           "us-east-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		BaseEndpoint:     aws.String(server.URL),
+		RetryMaxAttempts: 1,
+	}
+	limiter.AddTo(&cfg)
+	return bedrockruntime.NewFromConfig(cfg), &calls
+}
+
+func invokeModel(client *bedrockruntime.Client) error {
+	_, err := client.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{
+		ModelId: aws.String("amazon.titan-embed-text-v2:0"),
+		Body:    []byte(`{"inputText": "fee"}`),
+	})
+	return err
+}
+
+// waitForCalls waits until the server has received count calls
+func waitForCalls(t *testing.T, calls *int32, count int32) {
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(calls) < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d calls, got %d", count, atomic.LoadInt32(calls))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLimiter_QueuesCallsOverTheLimit(t *testing.T) {
+	release := make(chan struct{})
+	client, calls := newTestClient(t, New(1, nil, 50*time.Millisecond), release)
+
+	first := make(chan error)
+	go func() { first <- invokeModel(client) }()
+	waitForCalls(t, calls, 1)
+
+	// The only slot is held, so the second call waits and gives up
+	err := invokeModel(client)
+	if err == nil || !strings.Contains(err.Error(), "ThrottlingException") || atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("expected a ThrottlingException without a call, got %v", err)
+	}
+
+	// A call queued behind it runs once the slot is freed
+	second := make(chan error)
+	go func() { second <- invokeModel(client) }()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := <-second; err != nil {
+		t.Fatalf("expected the queued call to run, got %v", err)
+	}
+	if atomic.LoadInt32(calls) != 2 {
+		t.Errorf("expected 2 calls, got %d", atomic.LoadInt32(calls))
+	}
+}
+
+func TestLimiter_LimitsEachOperation(t *testing.T) {
+	release := make(chan struct{})
+	client, calls := newTestClient(t, New(0, map[string]int{"Converse": 1}, 50*time.Millisecond), release)
+
+	converse := func() error {
+		_, err := client.Converse(context.Background(), &bedrockruntime.ConverseInput{ModelId: aws.String("anthropic.claude-3-haiku-20240307-v1:0")})
+		return err
+	}
+	first := make(chan error)
+	go func() { first <- converse() }()
+	waitForCalls(t, calls, 1)
+
+	if err := converse(); err == nil || !strings.Contains(err.Error(), "ThrottlingException") {
+		t.Fatalf("expected the second Converse call to be throttled, got %v", err)
+	}
+
+	// Other operations have no limit
+	invoked := make(chan error)
+	go func() { invoked <- invokeModel(client) }()
+	waitForCalls(t, calls, 2)
+	close(release)
+	if err := <-invoked; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := <-first; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParseOperationLimits(t *testing.T) {
+	limits, err := ParseOperationLimits([]string{"Converse=8", " InvokeModel = 16"})
+	if err != nil || len(limits) != 2 || limits["Converse"] != 8 || limits["InvokeModel"] != 16 {
+		t.Fatalf("unexpected limits %v %v", limits, err)
+	}
+	for _, value := range []string{"Converse", "Retrieve=4", "Converse=0", "Converse=many"} {
+		if _, err := ParseOperationLimits([]string{value}); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
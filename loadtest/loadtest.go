@@ -0,0 +1,365 @@
+package loadtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"teletubpax-api/services"
+	"teletubpax-api/stages"
+	"teletubpax-api/utils"
+)
+
+// questionSearchPath is the endpoint the load test sends questions to
+const questionSearchPath = "/api/teletubpax/question-search"
+
+// StageTotal is the whole request, from the router to the encoded response
+const StageTotal = "total"
+
+// stageOrder is the order stages are reported in, the order a question passes them
+var stageOrder = []string{
+	StageTotal,
+	stages.Clarification,
+	stages.AnswerCache,
+	stages.Normalization,
+	stages.AnswerBackend,
+	stages.Retrieval,
+	stages.Generation,
+}
+
+// defaultQuestions are asked when no question file is given
+var defaultQuestions = []string{
+	"ค่าธรรมเนียมโอนเงินต่างธนาคารเท่าไหร่",
+	"เปิดบัญชีออมทรัพย์ต้องใช้เอกสารอะไรบ้าง",
+	"วงเงินถอนเงินสดผ่าน ATM ต่อวันเท่าไหร่",
+	"ขั้นตอนการอายัดบัตรเดบิตทำอย่างไร",
+	"What is the interest rate of a fixed deposit account?",
+}
+
+// Options of a load test run
+type Options struct {
+	Questions            []string      // Asked in turn by every worker
+	Concurrency          int           // Requests in flight at once
+	Requests             int           // Stop after this many requests, 0 for no limit
+	Duration             time.Duration // Stop after this long, 0 for no limit
+	TokenBudget          int           // Stop once the estimated tokens of questions and answers reach it, 0 for no limit
+	Live                 bool          // Allow answer backends that call Bedrock
+	EnableRelateDocument bool
+}
+
+// ParseOptions parses the arguments of the loadtest subcommand
+func ParseOptions(args []string) (Options, error) {
+	var options Options
+	var questionFile string
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	flags.IntVar(&options.Concurrency, "concurrency", 8, "requests in flight at once")
+	flags.IntVar(&options.Requests, "requests", 1000, "stop after this many requests, 0 for no limit")
+	flags.DurationVar(&options.Duration, "duration", 0, "stop after this long, e.g. 30s, 0 for no limit")
+	flags.IntVar(&options.TokenBudget, "token-budget", 0, "stop once the estimated tokens of questions and answers reach it, required with -live")
+	flags.BoolVar(&options.Live, "live", false, "allow answer backends that call Bedrock")
+	flags.BoolVar(&options.EnableRelateDocument, "related-documents", true, "ask for related documents")
+	flags.StringVar(&questionFile, "questions", "", "file with one question per line, built-in sample questions when empty")
+	if err := flags.Parse(args); err != nil {
+		return options, err
+	}
+
+	options.Questions = defaultQuestions
+	if questionFile != "" {
+		questions, err := readQuestions(questionFile)
+		if err != nil {
+			return options, err
+		}
+		options.Questions = questions
+	}
+	return options, nil
+}
+
+// Validate checks the options against the default answer backend. Backends other than the
+// stub call Bedrock and are billed per token, so they need -live and a token budget.
+func (o Options) Validate(answerBackend string) error {
+	if o.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive")
+	}
+	if o.Requests <= 0 && o.Duration <= 0 && o.TokenBudget <= 0 {
+		return fmt.Errorf("set -requests, -duration or -token-budget, the load test would never stop")
+	}
+	if len(o.Questions) == 0 {
+		return fmt.Errorf("no questions to ask")
+	}
+	if answerBackend != services.AnswerBackendStub {
+		if !o.Live {
+			return fmt.Errorf("answer backend %q calls Bedrock, set ANSWER_BACKEND=stub or pass -live", answerBackend)
+		}
+		if o.TokenBudget <= 0 {
+			return fmt.Errorf("-live needs a -token-budget")
+		}
+	}
+	return nil
+}
+
+func readQuestions(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open questions: %w", err)
+	}
+	defer file.Close()
+
+	var questions []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if question := strings.TrimSpace(scanner.Text()); question != "" {
+			questions = append(questions, question)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read questions: %w", err)
+	}
+	return questions, nil
+}
+
+// StageLatency are the latency percentiles of one stage
+type StageLatency struct {
+	Stage string
+	Count int // Requests that entered the stage
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Report is the result of a load test run
+type Report struct {
+	Requests         int
+	StatusCodes      map[int]int
+	Elapsed          time.Duration
+	Throughput       float64 // Requests per second
+	BytesPerRequest  uint64  // Heap bytes allocated per request, by the whole process
+	AllocsPerRequest uint64  // Heap allocations per request, by the whole process
+	EstimatedTokens  int
+	Stages           []StageLatency
+}
+
+// Errors returns the number of requests that did not answer 2xx
+func (r *Report) Errors() int {
+	errors := 0
+	for code, count := range r.StatusCodes {
+		if code < 200 || code >= 300 {
+			errors += count
+		}
+	}
+	return errors
+}
+
+// Print writes the report as a table
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "requests: %d (%d errors) in %s, %.1f req/s\n", r.Requests, r.Errors(), r.Elapsed.Round(time.Millisecond), r.Throughput)
+	fmt.Fprintf(w, "allocations: %d B/req, %d allocs/req\n", r.BytesPerRequest, r.AllocsPerRequest)
+	if r.EstimatedTokens > 0 {
+		fmt.Fprintf(w, "estimated tokens: %d\n", r.EstimatedTokens)
+	}
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "status %d: %d\n", code, r.StatusCodes[code])
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "stage\tcount\tp50\tp90\tp99\tmax\t")
+	for _, stage := range r.Stages {
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\t%s\t\n", stage.Stage, stage.Count, formatLatency(stage.P50), formatLatency(stage.P90), formatLatency(stage.P99), formatLatency(stage.Max))
+	}
+	table.Flush()
+}
+
+func formatLatency(d time.Duration) string {
+	if d >= time.Millisecond {
+		return d.Round(10 * time.Microsecond).String()
+	}
+	return d.Round(100 * time.Nanosecond).String()
+}
+
+// sample is the outcome of one request
+type sample struct {
+	status    int
+	tokens    int
+	durations map[string]time.Duration
+}
+
+// Run sends questions to the question search endpoint of the handler, in process, until a
+// limit of the options is reached. The handler is the full router, so every middleware and
+// decorator of the pipeline is measured. Requests in flight when the token budget runs out
+// still complete, the budget can be exceeded by up to Concurrency answers.
+func Run(ctx context.Context, handler http.Handler, options Options) *Report {
+	if options.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Duration)
+		defer cancel()
+	}
+	path := questionSearchPath
+	if options.EnableRelateDocument {
+		path += "?enableRelateDocument=true"
+	}
+
+	var issued, tokens int64
+	next := func() (int, bool) {
+		if ctx.Err() != nil || (options.TokenBudget > 0 && atomic.LoadInt64(&tokens) >= int64(options.TokenBudget)) {
+			return 0, false
+		}
+		n := atomic.AddInt64(&issued, 1)
+		if options.Requests > 0 && n > int64(options.Requests) {
+			return 0, false
+		}
+		return int(n - 1), true
+	}
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	startTime := time.Now()
+
+	results := make([][]sample, options.Concurrency)
+	var wg sync.WaitGroup
+	for worker := 0; worker < options.Concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for {
+				n, ok := next()
+				if !ok {
+					return
+				}
+				s := send(ctx, handler, path, n, options.Questions[n%len(options.Questions)])
+				atomic.AddInt64(&tokens, int64(s.tokens))
+				results[worker] = append(results[worker], s)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	elapsed := time.Since(startTime)
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	var samples []sample
+	for _, workerSamples := range results {
+		samples = append(samples, workerSamples...)
+	}
+	report := &Report{
+		Requests:        len(samples),
+		StatusCodes:     map[int]int{},
+		Elapsed:         elapsed,
+		EstimatedTokens: int(tokens),
+	}
+	if len(samples) == 0 {
+		return report
+	}
+	report.Throughput = float64(len(samples)) / elapsed.Seconds()
+	report.BytesPerRequest = (after.TotalAlloc - before.TotalAlloc) / uint64(len(samples))
+	report.AllocsPerRequest = (after.Mallocs - before.Mallocs) / uint64(len(samples))
+	for _, s := range samples {
+		report.StatusCodes[s.status]++
+	}
+	report.Stages = stageLatencies(samples)
+	return report
+}
+
+// send asks one question and times its stages. Every request is its own chat session, as
+// many users asking once each, so session limits do not throttle the run.
+func send(ctx context.Context, handler http.Handler, path string, n int, question string) sample {
+	body, _ := json.Marshal(map[string]string{"question": question})
+	requestCtx, recorder := stages.WithRecorder(context.WithoutCancel(ctx))
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)).WithContext(requestCtx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Session-Id", fmt.Sprintf("loadtest-%d", n))
+	w := httptest.NewRecorder()
+
+	startTime := time.Now()
+	handler.ServeHTTP(w, req)
+	total := time.Since(startTime)
+
+	var response struct {
+		Answer string `json:"answer"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	durations := recorder.Durations()
+	durations[StageTotal] = total
+	return sample{
+		status:    w.Code,
+		tokens:    utils.EstimateTokens(question) + utils.EstimateTokens(response.Answer),
+		durations: durations,
+	}
+}
+
+// stageLatencies computes the percentiles of every stage that was entered, in pipeline
+// order with stages this package does not know last
+func stageLatencies(samples []sample) []StageLatency {
+	byStage := map[string][]time.Duration{}
+	for _, s := range samples {
+		for stage, duration := range s.durations {
+			byStage[stage] = append(byStage[stage], duration)
+		}
+	}
+
+	names := make([]string, 0, len(byStage))
+	for stage := range byStage {
+		names = append(names, stage)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := stageRank(names[i]), stageRank(names[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+
+	latencies := make([]StageLatency, 0, len(names))
+	for _, stage := range names {
+		durations := byStage[stage]
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		latencies = append(latencies, StageLatency{
+			Stage: stage,
+			Count: len(durations),
+			P50:   percentile(durations, 50),
+			P90:   percentile(durations, 90),
+			P99:   percentile(durations, 99),
+			Max:   durations[len(durations)-1],
+		})
+	}
+	return latencies
+}
+
+func stageRank(stage string) int {
+	for i, known := range stageOrder {
+		if known == stage {
+			return i
+		}
+	}
+	return len(stageOrder)
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,159 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/routing"
+	"teletubpax-api/services"
+	"teletubpax-api/stages"
+	"teletubpax-api/utils"
+)
+
+// newStubRouter serves question search from the stub answer backend
+func newStubRouter(t *testing.T) (http.Handler, *config.Config) {
+	t.Helper()
+	cfg := &config.Config{
+		AnswerBackend:     services.AnswerBackendStub,
+		StubAnswer:        "ค่าธรรมเนียม 10 บาท",
+		RetryAttempts:     1,
+		MaxQuestionLength: 1000,
+		SafeMode:          config.NewSafeMode(false),
+	}
+	backends, err := services.NewAnswerBackends(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return routing.SetupRoutes(routing.RouteServices{
+		QuestionSearch: services.NewBedrockQuestionSearchService(nil, nil, nil, backends, cfg),
+	}, cfg), cfg
+}
+
+func TestRun_StubBackend(t *testing.T) {
+	router, _ := newStubRouter(t)
+
+	report := Run(context.Background(), router, Options{
+		Questions:   defaultQuestions,
+		Concurrency: 4,
+		Requests:    50,
+	})
+
+	if report.Requests != 50 || report.StatusCodes[200] != 50 || report.Errors() != 0 {
+		t.Fatalf("expected 50 successful requests, got %d with %v", report.Requests, report.StatusCodes)
+	}
+	var names []string
+	for _, stage := range report.Stages {
+		names = append(names, stage.Stage)
+		if stage.Count != 50 || stage.P50 > stage.P99 || stage.P99 > stage.Max {
+			t.Errorf("unexpected latencies for %s: %+v", stage.Stage, stage)
+		}
+	}
+	if got := strings.Join(names, ","); got != "total,normalization,answer_backend" {
+		t.Errorf("expected the stages in pipeline order, got %s", got)
+	}
+	if report.EstimatedTokens == 0 || report.Throughput <= 0 {
+		t.Errorf("expected tokens and throughput, got %+v", report)
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	if !strings.Contains(out.String(), "answer_backend") {
+		t.Errorf("expected the stage table, got:\n%s", out.String())
+	}
+}
+
+func TestRun_StopsAtTokenBudget(t *testing.T) {
+	router, cfg := newStubRouter(t)
+
+	report := Run(context.Background(), router, Options{
+		Questions:   []string{"ค่าธรรมเนียมโอนเงินเท่าไหร่"},
+		Concurrency: 1,
+		Duration:    10 * time.Second,
+		TokenBudget: 100,
+	})
+
+	perRequest := utils.EstimateTokens("ค่าธรรมเนียมโอนเงินเท่าไหร่") + utils.EstimateTokens(cfg.StubAnswer)
+	if report.Requests != (100+perRequest-1)/perRequest {
+		t.Errorf("expected the run to stop once the budget was used, got %d requests of %d tokens", report.Requests, perRequest)
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	options, err := ParseOptions([]string{"-concurrency", "2", "-requests", "10"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := options.Validate(services.AnswerBackendStub); err != nil {
+		t.Errorf("expected stub runs to be allowed, got %v", err)
+	}
+	if err := options.Validate(services.AnswerBackendKnowledgeBase); err == nil {
+		t.Error("expected live backends to need -live")
+	}
+
+	options.Live = true
+	if err := options.Validate(services.AnswerBackendKnowledgeBase); err == nil {
+		t.Error("expected live runs to need a token budget")
+	}
+	options.TokenBudget = 50000
+	if err := options.Validate(services.AnswerBackendKnowledgeBase); err != nil {
+		t.Errorf("expected a budgeted live run to be allowed, got %v", err)
+	}
+
+	options.Requests = 0
+	options.TokenBudget = 0
+	if err := options.Validate(services.AnswerBackendStub); err == nil {
+		t.Error("expected a run without limits to be rejected")
+	}
+}
+
+func TestParseOptions_QuestionFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "questions.txt")
+	os.WriteFile(path, []byte("คำถามที่หนึ่ง\n\n  คำถามที่สอง  \n"), 0644)
+
+	options, err := ParseOptions([]string{"-questions", path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(options.Questions) != 2 || options.Questions[1] != "คำถามที่สอง" {
+		t.Errorf("expected the non-empty lines, got %q", options.Questions)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var durations []time.Duration
+	for i := 1; i <= 100; i++ {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	if p := percentile(durations, 50); p != 50*time.Millisecond {
+		t.Errorf("expected p50 of 50ms, got %s", p)
+	}
+	if p := percentile(durations, 99); p != 99*time.Millisecond {
+		t.Errorf("expected p99 of 99ms, got %s", p)
+	}
+	if p := percentile(durations[:1], 99); p != time.Millisecond {
+		t.Errorf("expected the only sample, got %s", p)
+	}
+}
+
+func TestStageLatencies_UnknownStagesLast(t *testing.T) {
+	latencies := stageLatencies([]sample{{durations: map[string]time.Duration{
+		"custom":             time.Millisecond,
+		stages.AnswerBackend: time.Millisecond,
+		StageTotal:           2 * time.Millisecond,
+		stages.AnswerCache:   time.Microsecond,
+	}}})
+	var names []string
+	for _, latency := range latencies {
+		names = append(names, latency.Stage)
+	}
+	if got := strings.Join(names, ","); got != "total,answer_cache,answer_backend,custom" {
+		t.Errorf("unexpected stage order %s", got)
+	}
+}
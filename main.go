@@ -1,106 +1,785 @@
-package main
-
-import (
-	"context"
-	"log"
-	"net/http"
-	"os"
-
-	awsConfig "github.com/aws/aws-sdk-go-v2/config"
-
-	"teletubpax-api/aws"
-	"teletubpax-api/config"
-	"teletubpax-api/logger"
-	"teletubpax-api/routing"
-	"teletubpax-api/services"
-)
-
-func main() {
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
-	}
-
-	// Initialize AWS SDK config
-	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background(),
-		awsConfig.WithRegion(cfg.AWSRegion),
-	)
-	if err != nil {
-		log.Fatalf("Failed to load AWS configuration: %v", err)
-	}
-
-	// Initialize CloudWatch Logger for local/container development
-	hostname, _ := os.Hostname()
-	if hostname == "" {
-		hostname = "local"
-	}
-	logGroupName := "/teletubpax-api/local"
-	logStreamName := hostname
-
-	cwLogger, err := logger.NewCloudWatchLogger(awsCfg, logGroupName, logStreamName)
-	if err != nil {
-		log.Printf("Failed to initialize CloudWatch logger, using standard logger: %v", err)
-		logger.Initialize(&logger.StandardLogger{})
-	} else {
-		logger.Initialize(cwLogger)
-	}
-
-	// Set log level to ERROR for container/production
-	logLevel := os.Getenv("LOG_LEVEL")
-	if logLevel == "" {
-		logLevel = "ERROR" // Default to ERROR
-	}
-
-	switch logLevel {
-	case "DEBUG":
-		logger.SetLogLevel(logger.DEBUG)
-	case "INFO":
-		logger.SetLogLevel(logger.INFO)
-	case "WARN":
-		logger.SetLogLevel(logger.WARN)
-	case "ERROR":
-		logger.SetLogLevel(logger.ERROR)
-	default:
-		logger.SetLogLevel(logger.ERROR)
-	}
-
-	log.Printf("Logger initialized with level: %s", logLevel)
-	log.Printf("Configuration loaded: Region=%s, Model=%s, KBs=%v", cfg.AWSRegion, cfg.EmbeddingModelId, cfg.KnowledgeBaseIds)
-
-	// Create AWS clients
-	embeddingClient := aws.NewBedrockEmbeddingClient(awsCfg, cfg.EmbeddingModelId)
-	kbClient := aws.NewBedrockKBClient(awsCfg, cfg.KnowledgeBaseIds, cfg.GenerativeModelId, cfg.AWSRegion, cfg.QuestionSearchInstructions)
-	openSearchClient := aws.NewBedrockOpenSearchClient(awsCfg, cfg.KnowledgeBaseIds[0], cfg.AWSRegion, kbClient, cfg.GenerativeModelId, cfg.DocumentComparisonInstructions)
-	log.Println("AWS Bedrock clients initialized")
-
-	// Create services
-	questionSearchService := services.NewBedrockQuestionSearchService(
-		embeddingClient,
-		kbClient,
-		cfg,
-	)
-	log.Println("Question search service created")
-
-	documentDetailsService := services.NewOpenSearchDocumentService(
-		openSearchClient,
-		cfg,
-	)
-	log.Println("Document details service created")
-
-	documentSummaryService := services.NewBedrockDocumentSummaryService(
-		openSearchClient,
-		kbClient,
-		cfg,
-	)
-	log.Println("Document summary service created")
-
-	// Setup routes with services
-	router := routing.SetupRoutes(questionSearchService, documentDetailsService, documentSummaryService, cfg.MaxQuestionLength)
-
-	log.Println("Server starting on :8080")
-	if err := http.ListenAndServe(":8080", router); err != nil {
-		logger.Error("Server failed", map[string]interface{}{"error": err.Error()})
-		log.Fatal(err)
-	}
-}
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+
+	"teletubpax-api/alerting"
+	"teletubpax-api/auth"
+	"teletubpax-api/aws"
+	"teletubpax-api/bootstrap"
+	"teletubpax-api/cache"
+	"teletubpax-api/config"
+	"teletubpax-api/eval"
+	"teletubpax-api/experiments"
+	"teletubpax-api/faults"
+	"teletubpax-api/flags"
+	"teletubpax-api/limiter"
+	"teletubpax-api/loadtest"
+	"teletubpax-api/logger"
+	"teletubpax-api/normalization"
+	"teletubpax-api/policy"
+	"teletubpax-api/redaction"
+	"teletubpax-api/routing"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+	"teletubpax-api/tracing"
+)
+
+func main() {
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Initialize AWS SDK config
+	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background(),
+		awsConfig.WithRegion(cfg.AWSRegion),
+	)
+	if err != nil {
+		log.Fatalf("Failed to load AWS configuration: %v", err)
+	}
+
+	// Trace requests, services and AWS calls to an OTLP endpoint or X-Ray (optional)
+	tracerProvider, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("Invalid tracing settings: %v", err)
+	}
+	if tracerProvider != nil {
+		tracing.AddTo(&awsCfg)
+		defer tracerProvider.Shutdown(context.Background())
+		log.Printf("Tracing enabled: exporter %s, %d%% of new traces sampled", cfg.TracingExporter, cfg.TracingSamplePercent)
+	}
+
+	// Queue Bedrock model calls over the concurrency limits instead of sending them into
+	// ThrottlingExceptions (optional). Added after tracing, so the traces show the wait.
+	if cfg.BedrockMaxConcurrency > 0 || len(cfg.BedrockOperationLimits) > 0 {
+		operationLimits, err := limiter.ParseOperationLimits(cfg.BedrockOperationLimits)
+		if err != nil {
+			log.Fatalf("Invalid BEDROCK_OPERATION_LIMITS: %v", err)
+		}
+		limiter.New(cfg.BedrockMaxConcurrency, operationLimits, time.Duration(cfg.BedrockQueueTimeoutMs)*time.Millisecond).AddTo(&awsCfg)
+		log.Printf("Bedrock concurrency limited: %d calls overall, %v per operation", cfg.BedrockMaxConcurrency, operationLimits)
+	}
+
+	// Pace Bedrock model calls once they are throttled, retries included
+	if cfg.BedrockAdaptiveThrottle {
+		limiter.NewAdaptiveThrottle(cfg.BedrockMinRate, cfg.BedrockMaxRate).AddTo(&awsCfg)
+	}
+
+	// Inject throttling, latency and malformed responses into AWS calls (non-production only)
+	if cfg.FaultInjectionEnabled {
+		faultRules, err := faults.ParseRules(cfg.FaultInjection)
+		if err != nil {
+			log.Fatalf("Invalid FAULT_INJECTION: %v", err)
+		}
+		faults.New(faultRules).AddTo(&awsCfg)
+		log.Printf("Fault injection enabled with %d rules", len(faultRules))
+	}
+	logGroupName := "/teletubpax-api/local"
+
+	// Create missing tables and log groups, either once with "teletubpax-api bootstrap" or on
+	// every start with BOOTSTRAP_RESOURCES=true
+	runBootstrap := len(os.Args) > 1 && os.Args[1] == "bootstrap"
+	if runBootstrap || cfg.BootstrapResources {
+		results, err := bootstrap.NewBootstrapper(awsCfg).Run(context.Background(), bootstrap.Tables(cfg), []string{logGroupName})
+		for _, result := range results {
+			log.Printf("Bootstrap %s", result)
+		}
+		if err != nil {
+			log.Fatalf("Bootstrap failed: %v", err)
+		}
+		if runBootstrap {
+			return
+		}
+	}
+
+	// Initialize CloudWatch Logger for local/container development
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "local"
+	}
+	logStreamName := hostname
+
+	cwLogger, err := logger.NewCloudWatchLogger(awsCfg, logGroupName, logStreamName)
+	if err != nil {
+		log.Printf("Failed to initialize CloudWatch logger, using standard logger: %v", err)
+		logger.Initialize(&logger.StandardLogger{})
+	} else {
+		logger.Initialize(cwLogger)
+	}
+
+	// Set log level to ERROR for container/production
+	logLevel := os.Getenv("LOG_LEVEL")
+	if logLevel == "" {
+		logLevel = "ERROR" // Default to ERROR
+	}
+
+	switch logLevel {
+	case "DEBUG":
+		logger.SetLogLevel(logger.DEBUG)
+	case "INFO":
+		logger.SetLogLevel(logger.INFO)
+	case "WARN":
+		logger.SetLogLevel(logger.WARN)
+	case "ERROR":
+		logger.SetLogLevel(logger.ERROR)
+	default:
+		logger.SetLogLevel(logger.ERROR)
+	}
+
+	log.Printf("Logger initialized with level: %s", logLevel)
+	log.Printf("Configuration loaded: Region=%s, Model=%s, KBs=%v", cfg.AWSRegion, cfg.EmbeddingModelId, cfg.Current().KnowledgeBaseIds())
+
+	// Create feature flags, the SSM parameter (if set) overrides FEATURE_FLAGS
+	flagSources := []flags.Source{flags.NewEnvSource(cfg.FeatureFlags)}
+	if cfg.FeatureFlagsParameter != "" {
+		flagSources = append(flagSources, flags.NewSSMSource(awsCfg, cfg.FeatureFlagsParameter))
+	}
+	featureFlags := flags.New(time.Duration(cfg.FeatureFlagsRefreshSeconds)*time.Second, flagSources...)
+	flags.Initialize(featureFlags)
+	log.Println("Feature flags initialized")
+
+	// Create endpoint policies, the SSM parameter (if set) overrides ENDPOINT_POLICIES per endpoint
+	if _, err := policy.Parse(cfg.EndpointPolicies); err != nil {
+		log.Fatalf("Invalid ENDPOINT_POLICIES: %v", err)
+	}
+	policySources := []policy.Source{policy.NewEnvSource(cfg.EndpointPolicies)}
+	if cfg.EndpointPoliciesParameter != "" {
+		policySources = append(policySources, policy.NewSSMSource(awsCfg, cfg.EndpointPoliciesParameter))
+	}
+	endpointPolicies := policy.New(
+		policy.Defaults(cfg.RetryAttempts),
+		time.Duration(cfg.EndpointPoliciesRefreshSeconds)*time.Second,
+		policySources...,
+	)
+	log.Println("Endpoint policies initialized")
+
+	// Create the soft-deleted document registry (optional), deleted documents are excluded
+	// from retrieval and listings until they are restored or purged
+	var deletedDocumentStore storage.DeletedDocumentStore
+	var documentDeletionService services.DocumentDeletionService
+	if cfg.DeletedDocumentsTable != "" {
+		deletedDocumentStore = storage.NewDynamoDBDeletedDocumentStore(awsCfg, cfg.DeletedDocumentsTable)
+		documentDeletionService = services.NewStoreDocumentDeletionService(
+			deletedDocumentStore,
+			aws.NewS3ObjectStorageClient(awsCfg),
+			cfg,
+		)
+		log.Printf("Document soft-delete enabled: table=%s, retention=%d days", cfg.DeletedDocumentsTable, cfg.DeletedDocumentRetentionDays)
+	}
+
+	// Read full source documents from S3 for comparisons and summaries (optional), with the
+	// extracted text cached on local disk
+	var documentContentClient aws.DocumentContentClient
+	if cfg.DocumentContentSource == "s3" {
+		var contentCache aws.ContentCache
+		if cfg.ContentCacheMaxMB > 0 {
+			diskCache, err := storage.NewDiskContentCache(cfg.ContentCacheDir, int64(cfg.ContentCacheMaxMB)<<20)
+			if err != nil {
+				log.Printf("Content cache disabled: %v", err)
+			} else {
+				contentCache = diskCache
+				log.Printf("Content cache enabled: dir=%s, max=%d bytes", cfg.ContentCacheDir, diskCache.MaxBytes())
+			}
+		}
+		documentContentClient = aws.NewS3DocumentContentClient(awsCfg, contentCache)
+		log.Println("Document content is read from S3")
+	}
+
+	// Check the configured models against the region on startup, models only served through
+	// inference profiles are invoked through a profile of the region
+	if cfg.BedrockModelProbe {
+		resolutions, err := aws.NewModelProbe(awsCfg, cfg.AWSRegion).Resolve(context.Background(), append([]string{cfg.GenerativeModelId, cfg.CandidateModelId, cfg.EmbeddingModelId}, cfg.FallbackModelIds...))
+		if _, ok := err.(*aws.ModelUnavailableError); ok {
+			log.Fatalf("Bedrock model check failed: %v", err)
+		}
+		if err != nil {
+			log.Printf("Skipping Bedrock model check: %v", err)
+		} else {
+			for _, resolution := range resolutions {
+				log.Printf("Bedrock model %s", resolution)
+			}
+			aws.UseModelResolutions(resolutions)
+		}
+
+		// Check the size of the embeddings against the vector index of the knowledge bases too
+		err = aws.NewEmbeddingDimensionsCheck(awsCfg).Check(context.Background(), cfg.Current().KnowledgeBaseIds(), cfg.EmbeddingModelId, cfg.EmbeddingDimensions)
+		if _, ok := err.(*aws.EmbeddingDimensionsError); ok {
+			log.Fatalf("Embedding dimensions check failed: %v", err)
+		}
+		if err != nil {
+			log.Printf("Skipping embedding dimensions check: %v", err)
+		}
+	}
+
+	// Links to source documents, pre-signed for buckets that are not public
+	var documentLinker aws.DocumentLinker = aws.NewPublicDocumentLinker(cfg.AWSRegion)
+	if cfg.DocumentLinkMode == "presigned" {
+		documentLinker = aws.NewPresignedDocumentLinker(aws.NewS3ObjectStorageClient(awsCfg), cfg.AWSRegion, time.Duration(cfg.DocumentLinkExpirySeconds)*time.Second)
+		log.Printf("Document links: pre-signed, expiry=%ds", cfg.DocumentLinkExpirySeconds)
+	}
+
+	// Create AWS clients, retrieving the configured number of chunks per knowledge base and
+	// dropping those below the minimum score unless the request asks otherwise
+	retrievalSettings := aws.RetrievalSettings{NumberOfResults: cfg.RetrievalResults, MinScore: &cfg.RetrievalMinScore}
+	generationSettings := aws.GenerationSettings{Temperature: cfg.KBGenerationTemperature, TopP: cfg.KBGenerationTopP, MaxTokens: cfg.KBGenerationMaxTokens, PromptTemplate: cfg.KBPromptTemplate}
+	// Each knowledge base has its own bound on queries in flight and failure budget, shared by
+	// the answers and their candidate variant
+	kbBulkheads := aws.NewKnowledgeBaseBulkheads(cfg.KBMaxConcurrentQueries, cfg.KBFailureBudget, time.Duration(cfg.KBSuspendSeconds)*time.Second)
+	kbQueryTimeout := time.Duration(cfg.KBQueryTimeoutMs) * time.Millisecond
+	embeddingClient := aws.NewBedrockEmbeddingClient(awsCfg, cfg.LiveSettings.EmbeddingModelId, aws.EmbeddingOptions{Dimensions: cfg.EmbeddingDimensions, Normalize: cfg.EmbeddingNormalize})
+	kbClient := aws.NewBedrockKBClient(awsCfg, cfg.LiveSettings.KnowledgeBases, cfg.LiveSettings.GenerativeModelId, cfg.LiveSettings.FallbackModelIds, cfg.AWSRegion, cfg.LiveSettings.QuestionSearchInstructionsFor, cfg.LiveSettings.SynthesisInstructions, documentDeletionService, documentLinker, cfg.RetrievalSearchType, retrievalSettings, generationSettings, kbBulkheads, kbQueryTimeout)
+	openSearchClient := aws.NewBedrockOpenSearchClient(awsCfg, cfg.Current().KnowledgeBaseIds()[0], documentLinker, kbClient, cfg.GenerativeModelId, cfg.LiveSettings.DocumentComparisonInstructions, cfg.LiveSettings.DocumentSummaryInstructions, documentDeletionService, documentContentClient)
+	log.Println("AWS Bedrock clients initialized")
+
+	// A Redis shared by every instance, the Lambda function and the container alike, backs the
+	// answer, embedding, comparison and idempotency caches; without one they stay in each
+	// instance's memory
+	var sharedCache cache.Store
+	if cfg.CacheRedisAddr != "" {
+		var redisPassword string
+		if cfg.CacheRedisAuthSecretId != "" {
+			redisPassword, err = aws.NewSecretsManagerClient(awsCfg).GetSecretString(context.Background(), cfg.CacheRedisAuthSecretId)
+			if err != nil {
+				log.Fatalf("Failed to load cache credentials: %v", err)
+			}
+		}
+		sharedCache = cache.NewRedisStore(cfg.CacheRedisAddr, redisPassword, cfg.CacheRedisTLS)
+		log.Printf("Shared cache: redis=%s", cfg.CacheRedisAddr)
+	}
+
+	// Embeddings of repeated texts, shared between instances when a Redis is set
+	var embeddingCache storage.EmbeddingCache = storage.NewMemoryEmbeddingCache(cfg.EmbeddingCacheMaxEntries)
+	if sharedCache != nil {
+		embeddingCache = storage.NewStoreEmbeddingCache(sharedCache)
+	}
+	cachingEmbeddingClient := services.NewCachingEmbeddingClient(embeddingClient, embeddingCache, cfg.LiveSettings.EmbeddingModelId, cfg)
+
+	// Create optional DynamoDB stores
+	var summaryStore storage.DocumentSummaryStore
+	if cfg.DocumentSummaryTable != "" {
+		summaryStore = storage.NewDynamoDBDocumentSummaryStore(awsCfg, cfg.DocumentSummaryTable)
+		log.Printf("Precomputed summary store enabled: table=%s", cfg.DocumentSummaryTable)
+	}
+	var comparisonStore storage.VersionComparisonStore
+	if cfg.VersionComparisonTable != "" {
+		comparisonStore = storage.NewDynamoDBVersionComparisonStore(awsCfg, cfg.VersionComparisonTable)
+		log.Printf("Version comparison cache enabled: table=%s", cfg.VersionComparisonTable)
+	} else if sharedCache != nil {
+		comparisonStore = storage.NewCacheVersionComparisonStore(sharedCache)
+		log.Printf("Version comparison cache enabled: redis=%s", cfg.CacheRedisAddr)
+	}
+	var notFoundStore storage.NotFoundStore
+	if cfg.NotFoundTable != "" {
+		notFoundStore = storage.NewDynamoDBNotFoundStore(awsCfg, cfg.NotFoundTable)
+		log.Printf("Unanswered question analytics enabled: table=%s", cfg.NotFoundTable)
+	}
+	var normalizationDictionary *normalization.Dictionary
+	if cfg.NormalizationTable != "" {
+		normalizationDictionary = normalization.New(
+			storage.NewDynamoDBNormalizationStore(awsCfg, cfg.NormalizationTable),
+			time.Duration(cfg.NormalizationRefreshSeconds)*time.Second,
+		)
+		normalization.Initialize(normalizationDictionary)
+		log.Printf("Question normalization enabled: table=%s", cfg.NormalizationTable)
+	}
+	if cfg.SynonymsEnabled {
+		synonyms, err := normalization.LoadSynonyms(context.Background(), aws.NewS3ObjectStorageClient(awsCfg), cfg.SynonymsBucket, cfg.SynonymsKey, cfg.Synonyms)
+		if err != nil {
+			log.Fatalf("Failed to load the synonym file: %v", err)
+		}
+		// Without the normalization table the synonyms apply alone and the admin API stays off
+		dictionary := normalizationDictionary
+		if dictionary == nil {
+			dictionary = normalization.New(nil, 0)
+			normalization.Initialize(dictionary)
+		}
+		dictionary.SetSynonyms(synonyms)
+		log.Printf("Question synonyms enabled: %d terms", len(synonyms))
+	}
+
+	// Questions are logged with personal data redacted, by pattern unless PII detection is on
+	if cfg.PIIDetectionEnabled {
+		redaction.Initialize(redaction.New(aws.NewComprehendPIIClient(awsCfg)))
+		log.Println("PII detection enabled for logged questions")
+	}
+
+	// Answer backends, selected per tenant or endpoint policy with ANSWER_BACKEND as default
+	var agentClient aws.AgentClient
+	if cfg.BedrockAgentId != "" {
+		agentClient = aws.NewBedrockAgentClient(awsCfg, cfg.BedrockAgentId, cfg.BedrockAgentAliasId, cfg.LiveSettings.KnowledgeBaseIds, documentLinker, cfg.RetrievalSearchType)
+	}
+	generationClient := aws.NewBedrockGenerationClient(awsCfg, cfg.LiveSettings.GenerativeModelId)
+	answerBackends, err := services.NewAnswerBackends(cfg, kbClient, generationClient, agentClient)
+	if err != nil {
+		log.Fatalf("Invalid answer backend settings: %v", err)
+	}
+	log.Printf("Answer backends available: %v, default %s", answerBackends.Names(), cfg.AnswerBackend)
+
+	// Create services
+	var questionSearchService services.QuestionSearchService = services.NewBedrockQuestionSearchService(
+		cachingEmbeddingClient,
+		kbClient,
+		notFoundStore,
+		answerBackends,
+		cfg,
+	)
+	log.Println("Question search service created")
+
+	// Search only the knowledge bases for the question's topic, when intents are configured
+	if cfg.IntentKeywords != "" {
+		intentClassifier, err := services.NewKeywordIntentClassifier(cfg.IntentKeywords)
+		if err != nil {
+			log.Fatalf("Invalid intent routing settings: %v", err)
+		}
+		questionSearchService = services.NewIntentRoutingQuestionSearchService(questionSearchService, intentClassifier)
+		log.Println("Intent routing enabled")
+	}
+
+	// Answers to repeated questions, shared between instances when a Redis is set
+	var answerCache storage.AnswerCache = storage.NewMemoryAnswerCache(cfg.AnswerCacheMaxEntries)
+	if sharedCache != nil {
+		answerCache = storage.NewStoreAnswerCache(sharedCache)
+	}
+	questionSearchService = services.NewCachingQuestionSearchService(questionSearchService, answerCache, cfg)
+	if sharedCache != nil {
+		log.Printf("Answer cache: redis=%s, ttl=%ds", cfg.CacheRedisAddr, cfg.AnswerCacheTTLSeconds)
+	} else {
+		log.Printf("Answer cache: memory, %d entries, ttl=%ds", cfg.AnswerCacheMaxEntries, cfg.AnswerCacheTTLSeconds)
+	}
+
+	// Clarification prompts for broad or multi-part questions, behind the question-clarification flag
+	clarifyingService, err := services.NewClarifyingQuestionSearchService(questionSearchService, cfg)
+	if err != nil {
+		log.Fatalf("Invalid clarification settings: %v", err)
+	}
+	questionSearchService = clarifyingService
+
+	// Per-session question rate and token limits, shared between instances when a table is set
+	if cfg.SessionMaxQuestionsPerMinute > 0 || cfg.SessionMaxTokens > 0 {
+		var sessionCounters storage.CounterStore = storage.NewMemoryCounterStore()
+		if cfg.SessionLimitTable != "" {
+			sessionCounters = storage.NewDynamoDBCounterStore(awsCfg, cfg.SessionLimitTable)
+		}
+		questionSearchService = services.NewSessionLimitedQuestionSearchService(questionSearchService, sessionCounters, cfg)
+		log.Printf("Session limits enabled: %d questions/minute, %d tokens per %d minutes", cfg.SessionMaxQuestionsPerMinute, cfg.SessionMaxTokens, cfg.SessionWindowMinutes)
+	}
+
+	// Prompt and model A/B test, sticky per session, with answer and feedback counts per variant
+	// shared between instances when a table is set (optional)
+	experiment, err := experiments.Parse(cfg.Experiment)
+	if err != nil {
+		log.Fatalf("Invalid EXPERIMENT: %v", err)
+	}
+	var experimentService services.ExperimentService
+	if experiment != nil || cfg.ExperimentTable != "" {
+		var experimentStore storage.ExperimentStore = storage.NewMemoryExperimentStore()
+		if cfg.ExperimentTable != "" {
+			experimentStore = storage.NewDynamoDBExperimentStore(awsCfg, cfg.ExperimentTable)
+		}
+		experimentService = services.NewStoreExperimentService(experiment, experimentStore, cfg)
+	}
+
+	// Answers are recorded under an answer ID that feedback is sent with (optional)
+	var feedbackService services.FeedbackService
+	if cfg.FeedbackTable != "" {
+		feedbackService = services.NewStoreFeedbackService(storage.NewDynamoDBFeedbackStore(awsCfg, cfg.FeedbackTable), experimentService, cfg)
+		questionSearchService = services.NewFeedbackQuestionSearchService(questionSearchService, feedbackService)
+		log.Printf("Answer feedback enabled: table=%s", cfg.FeedbackTable)
+	}
+
+	// Outside the answer cache and the feedback records, so both see the variant
+	if experiment != nil {
+		questionSearchService = services.NewExperimentQuestionSearchService(questionSearchService, experimentService)
+		log.Printf("Experiment %s enabled: %d variants", experiment.Name, len(experiment.Variants))
+	}
+
+	// Questions and answers of signed-in callers, for their history and PDPA erasure (optional)
+	var historyService services.HistoryService
+	if cfg.HistoryTable != "" {
+		historyService = services.NewStoreHistoryService(storage.NewDynamoDBConversationHistoryStore(awsCfg, cfg.HistoryTable), cfg)
+		questionSearchService = services.NewHistoryQuestionSearchService(questionSearchService, historyService)
+		log.Printf("Conversation history enabled: table=%s, retention=%d days", cfg.HistoryTable, cfg.HistoryRetentionDays)
+	}
+
+	// Write-once audit trail of every question, outermost so refused questions are audited too (optional)
+	var auditService services.AuditService
+	var auditStore storage.AuditStore
+	if cfg.AuditBucket != "" {
+		auditStore = storage.NewS3AuditStore(awsCfg, cfg.AuditBucket, cfg.AuditPrefix, cfg.AuditRetentionDays)
+		auditService = services.NewStoreAuditService(auditStore)
+		questionSearchService = services.NewAuditQuestionSearchService(questionSearchService, auditStore)
+		log.Printf("Audit trail enabled: bucket=%s, retention=%d days", cfg.AuditBucket, cfg.AuditRetentionDays)
+	}
+
+	// Token usage and cost by tenant, shared between instances when a table is set
+	var usageStore storage.UsageStore = storage.NewMemoryUsageStore()
+	if cfg.UsageTable != "" {
+		usageStore = storage.NewDynamoDBUsageStore(awsCfg, cfg.UsageTable)
+		log.Printf("Token usage accounting enabled: table=%s", cfg.UsageTable)
+	}
+	usageService, err := services.NewStoreUsageService(usageStore, cfg)
+	if err != nil {
+		log.Fatalf("Invalid token usage configuration: %v", err)
+	}
+
+	var documentEvents services.DocumentEventPublisher
+	if cfg.EventBusName != "" {
+		documentEvents = services.NewEventBridgeDocumentEvents(aws.NewEventBridgeClient(awsCfg), cfg.EventBusName)
+		log.Printf("Document events enabled: bus=%s", cfg.EventBusName)
+	}
+
+	documentDetailsService := services.NewOpenSearchDocumentService(
+		openSearchClient,
+		summaryStore,
+		comparisonStore,
+		documentEvents,
+		cfg,
+	)
+	log.Println("Document details service created")
+
+	documentSummaryService := services.NewBedrockDocumentSummaryService(
+		openSearchClient,
+		kbClient,
+		summaryStore,
+		cfg,
+	)
+	log.Println("Document summary service created")
+
+	retrievalDiagnosticsService := services.NewBedrockRetrievalDiagnosticsService(kbClient, cfg)
+	relatedQuestionsService := services.NewBedrockRelatedQuestionsService(kbClient, generationClient, cfg)
+	log.Println("Retrieval diagnostics service created")
+
+	// Jobs of long-running operations, polled at /jobs/{id}
+	var jobStore storage.JobStore
+	if cfg.JobsTable != "" {
+		jobStore = storage.NewDynamoDBJobStore(awsCfg, cfg.JobsTable)
+		log.Printf("Jobs enabled: table=%s", cfg.JobsTable)
+	}
+
+	ingestionClient := aws.NewBedrockIngestionClient(awsCfg)
+	ingestionService := services.NewBedrockIngestionService(ingestionClient, cfg.LiveSettings.KnowledgeBaseIds, jobStore, time.Duration(cfg.JobTTLSeconds)*time.Second)
+	documentExportService := services.NewBedrockDocumentExportService(ingestionClient, documentLinker, documentDeletionService, cfg.LiveSettings.KnowledgeBaseIds)
+
+	// Permanent deletion of retired documents, recorded on the audit trail when there is one
+	documentRemovalService := services.NewS3DocumentRemovalService(
+		aws.NewS3ObjectStorageClient(awsCfg),
+		cfg.DocumentArchiveBucket,
+		ingestionService,
+		auditStore,
+		deletedDocumentStore,
+		cfg,
+	)
+
+	answerDiffService := services.NewBedrockAnswerDiffService(
+		kbClient,
+		aws.NewBedrockKBClient(awsCfg, cfg.LiveSettings.KnowledgeBases, cfg.LiveSettings.CandidateModelId, nil, cfg.AWSRegion, cfg.LiveSettings.CandidateInstructionsFor, cfg.LiveSettings.SynthesisInstructions, documentDeletionService, documentLinker, cfg.RetrievalSearchType, retrievalSettings, generationSettings, kbBulkheads, kbQueryTimeout),
+		aws.NewBedrockAnswerComparisonClient(awsCfg, cfg.LiveSettings.GenerativeModelId, cfg.LiveSettings.AnswerDiffInstructions),
+		cfg,
+	)
+	log.Println("Answer diff service created")
+
+	// Evaluation runs of the golden dataset, with the answers graded by the generative model
+	var evaluationService eval.Service
+	if cfg.EvalBucket != "" {
+		evaluationService = eval.NewEvaluator(
+			eval.NewS3Store(aws.NewS3ObjectStorageClient(awsCfg), cfg.EvalBucket, cfg.EvalDatasetKey, cfg.EvalReportPrefix),
+			eval.NewGenerationJudge(generationClient, cfg.LiveSettings.AnswerJudgeInstructions),
+			cfg.EvalConcurrency,
+		)
+		log.Printf("Evaluation enabled: dataset=s3://%s/%s", cfg.EvalBucket, cfg.EvalDatasetKey)
+	}
+
+	var translationService services.TranslationService
+	switch cfg.TranslationProvider {
+	case "translate":
+		translationService = services.NewClientTranslationService(aws.NewAmazonTranslateClient(awsCfg))
+	case "bedrock":
+		translationService = services.NewClientTranslationService(aws.NewBedrockTranslationClient(awsCfg, cfg.LiveSettings.GenerativeModelId))
+	}
+	log.Printf("Translation provider: %s", cfg.TranslationProvider)
+
+	// Answer disclaimers per tenant or endpoint, added after the answer is generated
+	answerDisclaimers, err := services.NewAnswerDisclaimers(cfg)
+	if err != nil {
+		log.Fatalf("Invalid disclaimer settings: %v", err)
+	}
+
+	// Caller identity from bearer tokens signed by the identity provider, e.g. a Cognito user pool (optional)
+	authenticator := auth.NewAuthenticator(cfg)
+	if authenticator != nil {
+		log.Printf("Bearer token authentication enabled: user claim %s, required %t", cfg.AuthUserClaim, cfg.AuthRequired)
+	}
+
+	// Service-to-service callers authenticated by the IAM principal of their SigV4 signature (optional)
+	iamVerifier := auth.NewIamVerifier(cfg)
+	if iamVerifier != nil {
+		log.Printf("IAM authentication enabled: %d allowed principals", len(cfg.IamAuthAllowedPrincipals))
+	}
+
+	// Per-document access control by the roles in the caller's bearer token (optional)
+	accessControl, err := auth.NewAccessControl(cfg)
+	if err != nil {
+		log.Fatalf("Invalid access control settings: %v", err)
+	}
+	if accessControl != nil {
+		log.Printf("Document access control enabled: role claim %s, token verification %t", cfg.AccessControlRoleClaim, cfg.AuthJwksUrl != "")
+	}
+
+	var knowledgeGapService services.KnowledgeGapService
+	if notFoundStore != nil {
+		knowledgeGapService = services.NewStoreKnowledgeGapService(notFoundStore)
+	}
+
+	// Daily analytics export to S3 for Athena (optional)
+	var analyticsExportService services.AnalyticsExportService
+	if cfg.AnalyticsExportBucket != "" && (notFoundStore != nil || deletedDocumentStore != nil) {
+		analyticsExportService = services.NewS3AnalyticsExportService(notFoundStore, deletedDocumentStore, aws.NewS3ObjectStorageClient(awsCfg), cfg)
+		log.Printf("Analytics export enabled: s3://%s/%s", cfg.AnalyticsExportBucket, cfg.AnalyticsExportPrefix)
+	}
+
+	var webhookService services.WebhookService
+	if cfg.WebhookTable != "" {
+		webhookService = services.NewHTTPWebhookService(storage.NewDynamoDBWebhookStore(awsCfg, cfg.WebhookTable), cfg)
+		log.Printf("Document version webhooks enabled: table=%s", cfg.WebhookTable)
+	}
+
+	// API keys of internal consumers, managed by the admin API (optional)
+	var apiKeyService services.ApiKeyService
+	if cfg.ApiKeyTable != "" {
+		var quotaCounters storage.CounterStore = storage.NewMemoryCounterStore()
+		if cfg.ApiKeyQuotaTable != "" {
+			quotaCounters = storage.NewDynamoDBCounterStore(awsCfg, cfg.ApiKeyQuotaTable)
+		}
+		apiKeyService = services.NewStoreApiKeyService(storage.NewDynamoDBApiKeyStore(awsCfg, cfg.ApiKeyTable), quotaCounters)
+		log.Printf("API keys enabled: table=%s, required=%t", cfg.ApiKeyTable, cfg.ApiKeyRequired)
+	}
+
+	// Responses replayed to retries with the same Idempotency-Key header
+	var idempotencyStore storage.IdempotencyStore
+	if cfg.IdempotencyTTLSeconds > 0 {
+		idempotencyStore = storage.NewMemoryIdempotencyStore()
+		if cfg.IdempotencyTable != "" {
+			idempotencyStore = storage.NewDynamoDBIdempotencyStore(awsCfg, cfg.IdempotencyTable)
+			log.Printf("Idempotency keys: table=%s, ttl=%ds", cfg.IdempotencyTable, cfg.IdempotencyTTLSeconds)
+		} else if sharedCache != nil {
+			idempotencyStore = storage.NewCacheIdempotencyStore(sharedCache)
+			log.Printf("Idempotency keys: redis=%s, ttl=%ds", cfg.CacheRedisAddr, cfg.IdempotencyTTLSeconds)
+		} else {
+			log.Printf("Idempotency keys: memory, ttl=%ds", cfg.IdempotencyTTLSeconds)
+		}
+	}
+
+	// Posts finished asynchronous jobs to the callback URL they were submitted with
+	jobCallbackService := services.NewHTTPJobCallbackService(cfg)
+
+	// Questions answered in the background, by the Lambda worker reading the queue
+	var questionJobService services.QuestionJobService
+	if cfg.QuestionJobQueueUrl != "" {
+		questionJobService = services.NewQueueQuestionJobService(
+			aws.NewSQSQueueClient(awsCfg),
+			cfg.QuestionJobQueueUrl,
+			jobStore,
+			time.Duration(cfg.QuestionJobTTLSeconds)*time.Second,
+			jobCallbackService,
+		)
+		log.Printf("Asynchronous questions enabled: queue=%s", cfg.QuestionJobQueueUrl)
+	}
+	if cfg.ChatEnabled {
+		log.Printf("WebSocket chat enabled: ping interval %ds", cfg.ChatPingSeconds)
+	}
+
+	// Bulk summaries, run by the Step Functions workflow invoking the Lambda function
+	var summaryWorkflowService services.SummaryWorkflowService
+	if cfg.SummaryWorkflowArn != "" {
+		summaryWorkflowService = services.NewStepFunctionsSummaryWorkflowService(
+			aws.NewStepFunctionsWorkflowClient(awsCfg),
+			cfg.SummaryWorkflowArn,
+			storage.NewDynamoDBSummaryJobStore(awsCfg, cfg.SummaryJobsTable),
+			jobStore,
+			documentSummaryService,
+			jobCallbackService,
+			cfg,
+		)
+		log.Printf("Bulk summaries enabled: workflow=%s, table=%s", cfg.SummaryWorkflowArn, cfg.SummaryJobsTable)
+	}
+
+	var jobService services.JobService
+	if jobStore != nil {
+		jobService = services.NewStoreJobService(jobStore, map[string]services.JobRefresher{
+			storage.JobTypeIngestion: ingestionService,
+		})
+	}
+
+	var documentResummarizeService services.DocumentResummarizeService
+	if summaryStore != nil && cfg.JobCheckpointTable != "" {
+		documentResummarizeService = services.NewBedrockDocumentResummarizeService(
+			openSearchClient,
+			summaryStore,
+			storage.NewDynamoDBJobCheckpointStore(awsCfg, cfg.JobCheckpointTable),
+			webhookService,
+			documentEvents,
+			cfg,
+		)
+		log.Println("Document re-summarization job enabled")
+	}
+
+	var digestService services.DigestService
+	if summaryStore != nil && cfg.DigestSubscriptionTable != "" {
+		digestService = services.NewStoreDigestService(
+			summaryStore,
+			storage.NewDynamoDBDigestSubscriptionStore(awsCfg, cfg.DigestSubscriptionTable),
+			aws.NewSESNotificationClient(awsCfg),
+			cfg,
+		)
+		log.Printf("Document change digest enabled: table=%s", cfg.DigestSubscriptionTable)
+	}
+
+	// Load the response signing key (optional)
+	var responseSigningKey []byte
+	if cfg.ResponseSigningSecretId != "" {
+		secret, err := aws.NewSecretsManagerClient(awsCfg).GetSecretString(context.Background(), cfg.ResponseSigningSecretId)
+		if err != nil {
+			log.Fatalf("Failed to load response signing key: %v", err)
+		}
+		responseSigningKey = []byte(secret)
+		log.Println("Response signing enabled")
+	}
+
+	// Prompts saved as CONFIG_SSM_PREFIX parameters through the admin API (optional)
+	var promptService services.PromptService
+	if cfg.ConfigSSMPrefix != "" {
+		promptService = services.NewStorePromptService(storage.NewSSMPromptStore(awsCfg, cfg.ConfigSSMPrefix), cfg.LiveSettings)
+		log.Printf("Prompt API enabled: prefix=%s", cfg.ConfigSSMPrefix)
+	}
+
+	// Error rate and throttling alerts paged through SNS (optional)
+	var alertMonitor *alerting.Monitor
+	if cfg.AlertTopicArn != "" {
+		alertMonitor = alerting.New(aws.NewSESNotificationClient(awsCfg), cfg.AlertTopicArn, "teletubpax-api "+cfg.Environment, alerting.Thresholds{
+			Window:       time.Duration(cfg.AlertWindowSeconds) * time.Second,
+			MinRequests:  cfg.AlertMinRequests,
+			ErrorRate:    float64(cfg.AlertErrorRatePercent) / 100,
+			ThrottleRate: float64(cfg.AlertThrottleRatePercent) / 100,
+			Cooldown:     time.Duration(cfg.AlertCooldownSeconds) * time.Second,
+		})
+		log.Printf("Alerts enabled: topic=%s", cfg.AlertTopicArn)
+	}
+
+	// Readiness also fails while the AWS credentials cannot be resolved, e.g. an expired role
+	var readinessChecks []routing.ReadinessCheck
+	if cfg.AnswerBackend != services.AnswerBackendStub {
+		readinessChecks = append(readinessChecks, routing.ReadinessCheck{Name: "aws_credentials", Check: func(ctx context.Context) error {
+			_, err := awsCfg.Credentials.Retrieve(ctx)
+			return err
+		}})
+	}
+
+	// Setup routes with services
+	router := routing.SetupRoutes(routing.RouteServices{
+		QuestionSearch:       questionSearchService,
+		DocumentDetails:      documentDetailsService,
+		DocumentSummary:      documentSummaryService,
+		DocumentResummarize:  documentResummarizeService,
+		RetrievalDiagnostics: retrievalDiagnosticsService,
+		RelatedQuestions:     relatedQuestionsService,
+		Ingestion:            ingestionService,
+		AnswerDiff:           answerDiffService,
+		Evaluation:           evaluationService,
+		Experiments:          experimentService,
+		Prompts:              promptService,
+		KnowledgeGaps:        knowledgeGapService,
+		AnalyticsExport:      analyticsExportService,
+		DocumentDeletion:     documentDeletionService,
+		DocumentRemoval:      documentRemovalService,
+		DocumentExport:       documentExportService,
+		Webhooks:             webhookService,
+		Digest:               digestService,
+		Feedback:             feedbackService,
+		History:              historyService,
+		Audit:                auditService,
+		Usage:                usageService,
+		ApiKeys:              apiKeyService,
+		Idempotency:          idempotencyStore,
+		QuestionJobs:         questionJobService,
+		Jobs:                 jobService,
+		SummaryJobs:          summaryWorkflowService,
+		Translation:          translationService,
+		Disclaimers:          answerDisclaimers,
+		FeatureFlags:         featureFlags,
+		Normalization:        normalizationDictionary,
+		Policies:             endpointPolicies,
+		Authenticator:        authenticator,
+		IamVerifier:          iamVerifier,
+		AccessControl:        accessControl,
+		ResponseSigningKey:   responseSigningKey,
+		ReadinessChecks:      readinessChecks,
+		Alerts:               alertMonitor,
+	}, cfg)
+
+	// Measure the answer pipeline in process with "teletubpax-api loadtest", against the stub
+	// answer backend or, with -live and a token budget, against Bedrock
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		options, err := loadtest.ParseOptions(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Invalid load test options: %v", err)
+		}
+		if err := options.Validate(cfg.AnswerBackend); err != nil {
+			log.Fatalf("Invalid load test options: %v", err)
+		}
+		log.Printf("Load test: %d questions, concurrency %d, answer backend %s", len(options.Questions), options.Concurrency, cfg.AnswerBackend)
+		loadtest.Run(context.Background(), router, options).Print(os.Stdout)
+		return
+	}
+
+	// Purge soft-deleted documents past their retention period once a day. On Lambda an
+	// EventBridge schedule calls the purge endpoint instead.
+	if documentDeletionService != nil {
+		go func() {
+			for range time.Tick(24 * time.Hour) {
+				documentDeletionService.Purge(context.Background())
+			}
+		}()
+	}
+
+	// Export the previous day's analytics once a day, on Lambda an EventBridge schedule calls
+	// the export endpoint instead
+	if analyticsExportService != nil {
+		go func() {
+			for range time.Tick(24 * time.Hour) {
+				analyticsExportService.Export(context.Background(), time.Now().UTC().AddDate(0, 0, -1))
+			}
+		}()
+	}
+
+	// Send the document change digest once a week, on Lambda an EventBridge schedule calls
+	// the send endpoint instead
+	if digestService != nil {
+		go func() {
+			for range time.Tick(7 * 24 * time.Hour) {
+				digestService.Send(context.Background())
+			}
+		}()
+	}
+
+	server := &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           router,
+		ReadHeaderTimeout: time.Duration(cfg.ServerReadTimeoutSeconds) * time.Second,
+		ReadTimeout:       time.Duration(cfg.ServerReadTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(cfg.ServerWriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(cfg.ServerIdleTimeoutSeconds) * time.Second,
+	}
+	log.Printf("Server starting on %s: read timeout %ds, write timeout %ds, request timeout %ds", cfg.ListenAddr, cfg.ServerReadTimeoutSeconds, cfg.ServerWriteTimeoutSeconds, cfg.RequestTimeoutSeconds)
+	if err := server.ListenAndServe(); err != nil {
+		logger.Error("Server failed", map[string]interface{}{"error": err.Error()})
+		log.Fatal(err)
+	}
+}
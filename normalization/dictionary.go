@@ -0,0 +1,289 @@
+package normalization
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+// loadTimeout bounds a reload triggered from a request path
+const loadTimeout = 2 * time.Second
+
+type entry struct {
+	term      []rune
+	canonical string
+	key       string
+}
+
+// Dictionary rewrites bank jargon and common misspellings in questions to the canonical terms
+// used in the documents. Terms are cached from the store and reloaded once they are older
+// than the refresh interval, so admin changes reach every instance without a redeploy.
+// Match counts are kept in memory and added to the store on each reload. Synonyms from a
+// synonym file are matched too, unless the store maps the same term.
+type Dictionary struct {
+	store    storage.NormalizationStore // Optional, nil for the synonyms alone
+	synonyms map[string]string
+	ttl      time.Duration
+	mu       sync.RWMutex
+	entries  []entry // Longest term first, so the most specific mapping wins
+	terms    map[string]storage.NormalizationTerm
+	loadedAt time.Time
+	pending  map[string]int64 // Matches not yet added to the store
+}
+
+func New(store storage.NormalizationStore, ttl time.Duration) *Dictionary {
+	return &Dictionary{
+		store:   store,
+		ttl:     ttl,
+		terms:   map[string]storage.NormalizationTerm{},
+		pending: map[string]int64{},
+	}
+}
+
+// SetSynonyms replaces the synonym mappings, which apply from the next question
+func (d *Dictionary) SetSynonyms(synonyms map[string]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.synonyms = synonyms
+	d.loadedAt = time.Time{}
+}
+
+// Normalize returns the question with every known term replaced by its canonical wording and
+// the terms that matched. Matching ignores case, and terms made of latin letters or digits
+// only match whole words so short abbreviations do not rewrite parts of other words. Thai is
+// written without spaces, so Thai terms match anywhere. A nil Dictionary leaves the question
+// unchanged.
+func (d *Dictionary) Normalize(question string) (string, []string) {
+	if d == nil {
+		return question, nil
+	}
+	d.reloadIfStale()
+
+	d.mu.RLock()
+	entries := d.entries
+	d.mu.RUnlock()
+	if len(entries) == 0 {
+		return question, nil
+	}
+
+	original := []rune(question)
+	lowered := make([]rune, len(original))
+	for i, r := range original {
+		lowered[i] = unicode.ToLower(r)
+	}
+
+	var b strings.Builder
+	var matched []string
+	for i := 0; i < len(original); {
+		e, ok := matchAt(entries, lowered, i)
+		if !ok {
+			b.WriteRune(original[i])
+			i++
+			continue
+		}
+		b.WriteString(e.canonical)
+		matched = append(matched, e.key)
+		i += len(e.term)
+	}
+
+	if len(matched) > 0 {
+		d.mu.Lock()
+		for _, key := range matched {
+			d.pending[key]++
+		}
+		d.mu.Unlock()
+	}
+	return b.String(), matched
+}
+
+func matchAt(entries []entry, text []rune, start int) (entry, bool) {
+	for _, e := range entries {
+		end := start + len(e.term)
+		if end > len(text) || !runesEqual(text[start:end], e.term) {
+			continue
+		}
+		if isWordRune(e.term[0]) && start > 0 && isWordRune(text[start-1]) {
+			continue
+		}
+		if isWordRune(e.term[len(e.term)-1]) && end < len(text) && isWordRune(text[end]) {
+			continue
+		}
+		return e, true
+	}
+	return entry{}, false
+}
+
+// isWordRune reports whether r is a latin letter or digit, which need word boundaries
+func isWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Terms returns the current mappings with their match counts, most matched first
+func (d *Dictionary) Terms() []storage.NormalizationTerm {
+	if d == nil {
+		return []storage.NormalizationTerm{}
+	}
+	d.reloadIfStale()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	terms := make([]storage.NormalizationTerm, 0, len(d.terms))
+	for key, term := range d.terms {
+		term.MatchCount += d.pending[key]
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].MatchCount != terms[j].MatchCount {
+			return terms[i].MatchCount > terms[j].MatchCount
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	return terms
+}
+
+// PutTerm stores a mapping and reloads so it applies on this instance right away. Other
+// instances pick it up after their refresh interval.
+func (d *Dictionary) PutTerm(ctx context.Context, term string, canonical string) (*storage.NormalizationTerm, error) {
+	record, err := d.store.PutTerm(ctx, NormalizeTerm(term), strings.TrimSpace(canonical))
+	if err != nil {
+		return nil, err
+	}
+	d.Reload(ctx)
+	return record, nil
+}
+
+// DeleteTerm removes a mapping, returning false when it did not exist
+func (d *Dictionary) DeleteTerm(ctx context.Context, term string) (bool, error) {
+	deleted, err := d.store.DeleteTerm(ctx, NormalizeTerm(term))
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	d.Reload(ctx)
+	return true, nil
+}
+
+// Reload adds the pending match counts to the store and loads the terms again. When the
+// store fails the previous terms are kept and the next attempt happens after the refresh
+// interval.
+func (d *Dictionary) Reload(ctx context.Context) error {
+	log := logger.WithContext(ctx)
+
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = map[string]int64{}
+	d.mu.Unlock()
+
+	if d.store == nil {
+		// Without a store there are only the synonyms, and no counts to save
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.loadedAt = time.Now()
+		d.build(nil)
+		return nil
+	}
+
+	if len(pending) > 0 {
+		if err := d.store.AddMatchCounts(ctx, pending, time.Now()); err != nil {
+			// Keep the counts for the next reload rather than losing them
+			log.Warn("Failed to save normalization match counts", map[string]interface{}{
+				"error": err.Error(),
+			})
+			d.mu.Lock()
+			for key, count := range pending {
+				d.pending[key] += count
+			}
+			d.mu.Unlock()
+		}
+	}
+
+	records, err := d.store.ListTerms(ctx)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.loadedAt = time.Now()
+	if err != nil {
+		log.Warn("Failed to load normalization terms", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+	d.build(records)
+	return nil
+}
+
+// build replaces the entries with the store's records and the synonyms they do not cover.
+// The caller holds the write lock.
+func (d *Dictionary) build(records []storage.NormalizationTerm) {
+	terms := make(map[string]storage.NormalizationTerm, len(records))
+	entries := make([]entry, 0, len(records)+len(d.synonyms))
+	for _, record := range records {
+		key := NormalizeTerm(record.Term)
+		if key == "" {
+			continue
+		}
+		terms[key] = record
+		entries = append(entries, entry{term: []rune(key), canonical: record.Canonical, key: key})
+	}
+	for key, canonical := range d.synonyms {
+		if _, ok := terms[key]; !ok {
+			entries = append(entries, entry{term: []rune(key), canonical: canonical, key: key})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return len(entries[i].term) > len(entries[j].term)
+	})
+
+	d.terms = terms
+	d.entries = entries
+}
+
+func (d *Dictionary) reloadIfStale() {
+	d.mu.RLock()
+	stale := d.loadedAt.IsZero() || (d.ttl > 0 && time.Since(d.loadedAt) > d.ttl)
+	d.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	d.Reload(ctx)
+}
+
+// NormalizeTerm is the form terms are stored and matched in
+func NormalizeTerm(term string) string {
+	return strings.Map(unicode.ToLower, strings.TrimSpace(term))
+}
+
+// Global dictionary instance
+var globalDictionary *Dictionary
+
+// Initialize sets the dictionary used by the package-level Normalize
+func Initialize(dictionary *Dictionary) {
+	globalDictionary = dictionary
+}
+
+// Default returns the global dictionary, nil when normalization is not configured
+func Default() *Dictionary {
+	return globalDictionary
+}
+
+// Normalize rewrites the question with the global dictionary
+func Normalize(question string) (string, []string) {
+	return globalDictionary.Normalize(question)
+}
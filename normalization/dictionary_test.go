@@ -0,0 +1,120 @@
+package normalization
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"teletubpax-api/storage"
+)
+
+type fakeStore struct {
+	terms   []storage.NormalizationTerm
+	counts  map[string]int64
+	listErr error
+}
+
+func (s *fakeStore) ListTerms(ctx context.Context) ([]storage.NormalizationTerm, error) {
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+	return s.terms, nil
+}
+
+func (s *fakeStore) PutTerm(ctx context.Context, term string, canonical string) (*storage.NormalizationTerm, error) {
+	record := storage.NormalizationTerm{Term: term, Canonical: canonical}
+	s.terms = append(s.terms, record)
+	return &record, nil
+}
+
+func (s *fakeStore) DeleteTerm(ctx context.Context, term string) (bool, error) {
+	return false, nil
+}
+
+func (s *fakeStore) AddMatchCounts(ctx context.Context, counts map[string]int64, matchedAt time.Time) error {
+	if s.counts == nil {
+		s.counts = map[string]int64{}
+	}
+	for term, count := range counts {
+		s.counts[term] += count
+	}
+	return nil
+}
+
+func TestNormalize(t *testing.T) {
+	store := &fakeStore{terms: []storage.NormalizationTerm{
+		{Term: "CC", Canonical: "บัตรเครดิต"},
+		{Term: "บัตรเครดิด", Canonical: "บัตรเครดิต"},
+		{Term: "k-plus app", Canonical: "K PLUS"},
+		{Term: "k-plus", Canonical: "K PLUS application"},
+	}}
+	d := New(store, time.Minute)
+
+	tests := []struct {
+		question string
+		expected string
+		matches  int
+	}{
+		{question: "สมัคร cc ยังไง", expected: "สมัคร บัตรเครดิต ยังไง", matches: 1},
+		{question: "สมัครบัตรเครดิดออนไลน์", expected: "สมัครบัตรเครดิตออนไลน์", matches: 1},
+		{question: "Open an account", expected: "Open an account", matches: 0},       // "cc" inside a word
+		{question: "Reset K-Plus App PIN", expected: "Reset K PLUS PIN", matches: 1}, // Longest term wins
+		{question: "", expected: "", matches: 0},
+	}
+
+	for _, tt := range tests {
+		normalized, matched := d.Normalize(tt.question)
+		if normalized != tt.expected || len(matched) != tt.matches {
+			t.Errorf("Normalize(%q) = %q, %v; expected %q with %d matches", tt.question, normalized, matched, tt.expected, tt.matches)
+		}
+	}
+}
+
+func TestMatchCountsAreSavedOnReload(t *testing.T) {
+	store := &fakeStore{terms: []storage.NormalizationTerm{{Term: "cc", Canonical: "credit card", MatchCount: 5}}}
+	d := New(store, time.Hour)
+
+	d.Normalize("cc limit")
+	d.Normalize("CC fee")
+
+	terms := d.Terms()
+	if len(terms) != 1 || terms[0].MatchCount != 7 {
+		t.Fatalf("expected stored and pending matches to be combined, got %+v", terms)
+	}
+
+	if err := d.Reload(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.counts["cc"] != 2 {
+		t.Fatalf("expected 2 matches saved, got %d", store.counts["cc"])
+	}
+	if err := d.Reload(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.counts["cc"] != 2 {
+		t.Fatalf("expected matches to be saved once, got %d", store.counts["cc"])
+	}
+}
+
+func TestReloadKeepsTermsWhenStoreFails(t *testing.T) {
+	store := &fakeStore{terms: []storage.NormalizationTerm{{Term: "cc", Canonical: "credit card"}}}
+	d := New(store, 0)
+	d.Reload(context.Background())
+
+	store.listErr = fmt.Errorf("throttled")
+	if err := d.Reload(context.Background()); err == nil {
+		t.Fatal("expected reload error")
+	}
+
+	if normalized, _ := d.Normalize("cc"); normalized != "credit card" {
+		t.Fatalf("expected previous terms to be kept, got %q", normalized)
+	}
+}
+
+func TestNilDictionaryLeavesQuestionUnchanged(t *testing.T) {
+	var d *Dictionary
+	if normalized, matched := d.Normalize("cc"); normalized != "cc" || matched != nil {
+		t.Fatalf("expected unchanged question, got %q %v", normalized, matched)
+	}
+}
@@ -0,0 +1,58 @@
+package normalization
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"teletubpax-api/aws"
+)
+
+// maxSynonymFileBytes bounds the synonym file read from S3
+const maxSynonymFileBytes = 1 << 20
+
+// ParseSynonyms reads a synonym file: one "term = canonical" mapping per line, where several
+// terms separated by commas may share a canonical. Blank lines and lines starting with #
+// are skipped. A term listed twice keeps its last mapping.
+func ParseSynonyms(text string) (map[string]string, error) {
+	synonyms := map[string]string{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		terms, canonical, ok := strings.Cut(line, "=")
+		canonical = strings.TrimSpace(canonical)
+		if !ok || canonical == "" {
+			return nil, fmt.Errorf("synonym line %d: expected \"term = canonical\"", i+1)
+		}
+		for _, term := range strings.Split(terms, ",") {
+			key := NormalizeTerm(term)
+			if key == "" {
+				return nil, fmt.Errorf("synonym line %d: empty term", i+1)
+			}
+			if key != NormalizeTerm(canonical) {
+				synonyms[key] = canonical
+			}
+		}
+	}
+	return synonyms, nil
+}
+
+// LoadSynonyms parses the synonym file in the bucket, or the embedded file when bucket is
+// empty. A missing object is an error, so a wrong key does not silently drop the synonyms.
+func LoadSynonyms(ctx context.Context, reader aws.ObjectReaderClient, bucket string, key string, embedded string) (map[string]string, error) {
+	if bucket == "" {
+		return ParseSynonyms(embedded)
+	}
+
+	object, err := reader.GetObject(ctx, bucket, key, maxSynonymFileBytes)
+	if err != nil {
+		return nil, err
+	}
+	if object == nil {
+		return nil, fmt.Errorf("synonym file s3://%s/%s does not exist", bucket, key)
+	}
+	return ParseSynonyms(string(object.Body))
+}
@@ -0,0 +1,55 @@
+package normalization
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"teletubpax-api/storage"
+)
+
+func TestParseSynonyms(t *testing.T) {
+	synonyms, err := ParseSynonyms("# comment\n\nสนญ., สนญ = สำนักงานใหญ่\r\nHP Loan = สินเชื่อเช่าซื้อ\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(synonyms) != 3 || synonyms["สนญ."] != "สำนักงานใหญ่" || synonyms["hp loan"] != "สินเชื่อเช่าซื้อ" {
+		t.Errorf("unexpected synonyms %v", synonyms)
+	}
+
+	for _, text := range []string{"สนญ.", "สนญ. =", " = สำนักงานใหญ่", "สนญ.,, = สำนักงานใหญ่"} {
+		if _, err := ParseSynonyms(text); err == nil {
+			t.Errorf("expected an error for %q", text)
+		}
+	}
+}
+
+func TestEmbeddedSynonymsParse(t *testing.T) {
+	text, err := os.ReadFile("../config/synonyms.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if synonyms, err := ParseSynonyms(string(text)); err != nil || len(synonyms) == 0 {
+		t.Fatalf("expected the embedded synonyms to parse, got %v %v", synonyms, err)
+	}
+}
+
+func TestSynonyms(t *testing.T) {
+	synonyms := map[string]string{"สนญ.": "สำนักงานใหญ่", "hp loan": "สินเชื่อเช่าซื้อ", "cc": "credit card"}
+
+	d := New(nil, 0)
+	d.SetSynonyms(synonyms)
+	if normalized, matched := d.Normalize("ติดต่อ สนญ. เรื่อง HP Loan"); normalized != "ติดต่อ สำนักงานใหญ่ เรื่อง สินเชื่อเช่าซื้อ" || len(matched) != 2 {
+		t.Errorf("unexpected synonyms-only result %q %v", normalized, matched)
+	}
+
+	store := &fakeStore{terms: []storage.NormalizationTerm{{Term: "cc", Canonical: "บัตรเครดิต"}}}
+	d = New(store, time.Minute)
+	d.SetSynonyms(synonyms)
+	if normalized, _ := d.Normalize("สนญ. cc"); normalized != "สำนักงานใหญ่ บัตรเครดิต" {
+		t.Errorf("expected the store to override the synonyms, got %q", normalized)
+	}
+	if terms := d.Terms(); len(terms) != 1 {
+		t.Errorf("expected only the store's terms to be listed, got %v", terms)
+	}
+}
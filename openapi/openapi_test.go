@@ -0,0 +1,108 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"teletubpax-api/storage"
+)
+
+type base struct {
+	Id string `json:"id"`
+}
+
+type node struct {
+	base
+	Name     string            `json:"name"`
+	Note     string            `json:"note,omitempty"`
+	Created  time.Time         `json:"created"`
+	Score    *float64          `json:"score,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	Children []node            `json:"children"`
+	Data     interface{}       `json:"data"`
+	Secret   string            `json:"-"`
+	hidden   string
+}
+
+func TestSchemaOf(t *testing.T) {
+	document := New(Info{Title: "test", Version: "1"})
+
+	ref := document.SchemaOf(node{})
+	if ref.Ref != "#/components/schemas/node" {
+		t.Fatalf("expected a reference to the node component, got %+v", ref)
+	}
+	schema := document.Components.Schemas["node"]
+	if schema == nil || schema.Type != "object" {
+		t.Fatalf("expected an object component, got %+v", schema)
+	}
+
+	expected := map[string]Schema{
+		"id":      {Type: "string"},
+		"name":    {Type: "string"},
+		"note":    {Type: "string"},
+		"created": {Type: "string", Format: "date-time"},
+		"score":   {Type: "number", Format: "double", Nullable: true},
+		"data":    {},
+	}
+	for name, want := range expected {
+		got := schema.Properties[name]
+		if got == nil || !reflect.DeepEqual(*got, want) {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+	}
+	if labels := schema.Properties["labels"]; labels.Type != "object" || labels.AdditionalProperties.Type != "string" {
+		t.Errorf("expected a string map, got %+v", labels)
+	}
+	if children := schema.Properties["children"]; children.Type != "array" || children.Items.Ref != "#/components/schemas/node" {
+		t.Errorf("expected an array referring to node itself, got %+v", children)
+	}
+	for _, name := range []string{"Secret", "hidden", "base"} {
+		if _, ok := schema.Properties[name]; ok {
+			t.Errorf("expected %s to be left out", name)
+		}
+	}
+
+	required := map[string]bool{}
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+	if !required["id"] || !required["name"] || required["note"] || required["score"] {
+		t.Errorf("expected fields without omitempty to be required, got %v", schema.Required)
+	}
+}
+
+// Webhook is named like storage.Webhook
+type Webhook struct {
+	Url string `json:"url"`
+}
+
+func TestSchemaOf_SameNameInTwoPackages(t *testing.T) {
+	document := New(Info{Title: "test", Version: "1"})
+
+	first := document.SchemaOf(storage.Webhook{})
+	second := document.SchemaOf(Webhook{})
+	if first.Ref != "#/components/schemas/Webhook" || second.Ref != "#/components/schemas/openapi.Webhook" {
+		t.Errorf("expected distinct components, got %s and %s", first.Ref, second.Ref)
+	}
+	if again := document.SchemaOf(Webhook{}); again.Ref != second.Ref {
+		t.Errorf("expected the same component again, got %s", again.Ref)
+	}
+}
+
+func TestAddOperation(t *testing.T) {
+	document := New(Info{Title: "test", Version: "1"})
+	document.AddOperation("GET", "/items", &Operation{Responses: map[string]*Response{"200": document.JSONResponse("OK", []string{})}})
+	document.AddOperation("DELETE", "/items", &Operation{Responses: map[string]*Response{"204": document.JSONResponse("No Content", nil)}})
+
+	item := *document.Paths["/items"]
+	if item["get"] == nil || item["delete"] == nil {
+		t.Fatalf("expected both operations under lower-case methods, got %v", item)
+	}
+	if item["delete"].Responses["204"].Content != nil {
+		t.Error("expected no content for a response without a body")
+	}
+	if schema := item["get"].Responses["200"].Content["application/json"].Schema; schema.Type != "array" {
+		t.Errorf("expected an array response, got %+v", schema)
+	}
+}
@@ -0,0 +1,258 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/utils"
+)
+
+// loadTimeout bounds a reload triggered from a request path
+const loadTimeout = 2 * time.Second
+
+// DefaultEndpoint names the policy block every endpoint inherits from
+const DefaultEndpoint = "default"
+
+type RetryProfile struct {
+	MaxAttempts       int     `json:"maxAttempts,omitempty"`
+	InitialBackoffMs  int     `json:"initialBackoffMs,omitempty"`
+	BackoffMultiplier float64 `json:"backoffMultiplier,omitempty"`
+	MaxBackoffMs      int     `json:"maxBackoffMs,omitempty"`
+	AttemptTimeoutMs  int     `json:"attemptTimeoutMs,omitempty"` // 0 bounds attempts by the request deadline only
+}
+
+// Policy holds the runtime limits of one endpoint. Zero or omitted fields inherit from the
+// default block, and from the built-in defaults after that.
+type Policy struct {
+	TimeoutSeconds    int          `json:"timeoutSeconds,omitempty"`    // Request deadline, 0 means none
+	MaxConcurrency    int          `json:"maxConcurrency,omitempty"`    // In-flight requests per instance, 0 means unlimited
+	Retry             RetryProfile `json:"retry"`                       // Backoff for retried AWS calls
+	CacheTTLSeconds   int          `json:"cacheTtlSeconds,omitempty"`   // Response cache lifetime, 0 disables caching
+	RetryAfterSeconds int          `json:"retryAfterSeconds,omitempty"` // Retry-After sent with 429 responses
+	MaxTokens         int          `json:"maxTokens,omitempty"`         // Generation limit for model calls
+	AnswerBackend     string       `json:"answerBackend,omitempty"`     // Backend answering questions, empty uses ANSWER_BACKEND
+	Disclaimer        string       `json:"disclaimer,omitempty"`        // Text added to answers, empty uses ANSWER_DISCLAIMER
+}
+
+// Defaults returns the built-in policy, matching the values that used to be hardcoded
+func Defaults(retryAttempts int) Policy {
+	return Policy{
+		Retry: RetryProfile{
+			MaxAttempts:       retryAttempts,
+			InitialBackoffMs:  100,
+			BackoffMultiplier: 2.0,
+			MaxBackoffMs:      2000,
+		},
+		RetryAfterSeconds: 60,
+		MaxTokens:         2048,
+	}
+}
+
+// RetryConfig converts the retry profile for utils.RetryWithBackoff
+func (p Policy) RetryConfig() utils.RetryConfig {
+	return utils.RetryConfig{
+		MaxAttempts:       p.Retry.MaxAttempts,
+		InitialBackoff:    time.Duration(p.Retry.InitialBackoffMs) * time.Millisecond,
+		BackoffMultiplier: p.Retry.BackoffMultiplier,
+		MaxBackoff:        time.Duration(p.Retry.MaxBackoffMs) * time.Millisecond,
+		Jitter:            true,
+		AttemptTimeout:    time.Duration(p.Retry.AttemptTimeoutMs) * time.Millisecond,
+	}
+}
+
+// Timeout returns the request deadline, 0 when the endpoint has none
+func (p Policy) Timeout() time.Duration {
+	return time.Duration(p.TimeoutSeconds) * time.Second
+}
+
+// merge returns p with the non-zero fields of override applied
+func (p Policy) merge(override Policy) Policy {
+	if override.TimeoutSeconds > 0 {
+		p.TimeoutSeconds = override.TimeoutSeconds
+	}
+	if override.MaxConcurrency > 0 {
+		p.MaxConcurrency = override.MaxConcurrency
+	}
+	if override.Retry.MaxAttempts > 0 {
+		p.Retry.MaxAttempts = override.Retry.MaxAttempts
+	}
+	if override.Retry.InitialBackoffMs > 0 {
+		p.Retry.InitialBackoffMs = override.Retry.InitialBackoffMs
+	}
+	if override.Retry.BackoffMultiplier > 0 {
+		p.Retry.BackoffMultiplier = override.Retry.BackoffMultiplier
+	}
+	if override.Retry.MaxBackoffMs > 0 {
+		p.Retry.MaxBackoffMs = override.Retry.MaxBackoffMs
+	}
+	if override.Retry.AttemptTimeoutMs > 0 {
+		p.Retry.AttemptTimeoutMs = override.Retry.AttemptTimeoutMs
+	}
+	if override.CacheTTLSeconds > 0 {
+		p.CacheTTLSeconds = override.CacheTTLSeconds
+	}
+	if override.RetryAfterSeconds > 0 {
+		p.RetryAfterSeconds = override.RetryAfterSeconds
+	}
+	if override.MaxTokens > 0 {
+		p.MaxTokens = override.MaxTokens
+	}
+	if override.AnswerBackend != "" {
+		p.AnswerBackend = override.AnswerBackend
+	}
+	if override.Disclaimer != "" {
+		p.Disclaimer = override.Disclaimer
+	}
+	return p
+}
+
+// Source loads policy blocks keyed by endpoint name, e.g. from an env var or an SSM parameter
+type Source interface {
+	Name() string
+	Load(ctx context.Context) (map[string]Policy, error)
+}
+
+// Policies caches the per-endpoint policy blocks from its sources and reloads them once
+// they are older than the refresh interval. Later sources override earlier ones per
+// endpoint. Endpoints are named by their path below /api/teletubpax, e.g. "question-search"
+// or "admin/diagnostics/retrieval".
+type Policies struct {
+	defaults Policy
+	sources  []Source
+	ttl      time.Duration
+	mu       sync.RWMutex
+	blocks   map[string]Policy
+	loadedAt time.Time
+}
+
+func New(defaults Policy, ttl time.Duration, sources ...Source) *Policies {
+	return &Policies{
+		defaults: defaults,
+		sources:  sources,
+		ttl:      ttl,
+		blocks:   map[string]Policy{},
+	}
+}
+
+// For returns the effective policy of an endpoint: the built-in defaults, overridden by the
+// default block, overridden by the endpoint's own block. A nil Policies returns the
+// built-in defaults for a single attempt.
+func (p *Policies) For(endpoint string) Policy {
+	if p == nil {
+		return Defaults(1)
+	}
+	p.reloadIfStale()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.defaults.merge(p.blocks[DefaultEndpoint]).merge(p.blocks[normalizeEndpoint(endpoint)])
+}
+
+// Snapshot returns the effective policy of every configured endpoint, including the default
+func (p *Policies) Snapshot() map[string]Policy {
+	if p == nil {
+		return map[string]Policy{DefaultEndpoint: Defaults(1)}
+	}
+	p.reloadIfStale()
+
+	p.mu.RLock()
+	endpoints := make([]string, 0, len(p.blocks)+1)
+	endpoints = append(endpoints, DefaultEndpoint)
+	for endpoint := range p.blocks {
+		if endpoint != DefaultEndpoint {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	p.mu.RUnlock()
+
+	sort.Strings(endpoints)
+	snapshot := make(map[string]Policy, len(endpoints))
+	for _, endpoint := range endpoints {
+		snapshot[endpoint] = p.For(endpoint)
+	}
+	return snapshot
+}
+
+// Reload loads every source now. When a source fails the previous blocks are kept and the
+// next attempt happens after the refresh interval.
+func (p *Policies) Reload(ctx context.Context) error {
+	blocks := map[string]Policy{}
+	var loadErr error
+	for _, source := range p.sources {
+		sourceBlocks, err := source.Load(ctx)
+		if err != nil {
+			logger.WithContext(ctx).Warn("Failed to load endpoint policies", map[string]interface{}{
+				"source": source.Name(),
+				"error":  err.Error(),
+			})
+			loadErr = err
+			break
+		}
+		for endpoint, block := range sourceBlocks {
+			blocks[endpoint] = block
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if loadErr == nil {
+		p.blocks = blocks
+	}
+	p.loadedAt = time.Now()
+	return loadErr
+}
+
+func (p *Policies) reloadIfStale() {
+	p.mu.RLock()
+	stale := p.loadedAt.IsZero() || (p.ttl > 0 && time.Since(p.loadedAt) > p.ttl)
+	p.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	p.Reload(ctx)
+}
+
+// Parse reads policy blocks from JSON such as
+// {"default": {"timeoutSeconds": 25}, "question-search": {"maxConcurrency": 20}}
+func Parse(value string) (map[string]Policy, error) {
+	blocks := map[string]Policy{}
+	if strings.TrimSpace(value) == "" {
+		return blocks, nil
+	}
+
+	var parsed map[string]Policy
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, err
+	}
+	for endpoint, block := range parsed {
+		blocks[normalizeEndpoint(endpoint)] = block
+	}
+	return blocks, nil
+}
+
+func normalizeEndpoint(endpoint string) string {
+	return strings.Trim(strings.ToLower(strings.TrimSpace(endpoint)), "/")
+}
+
+type contextKey struct{}
+
+// WithPolicy attaches the policy of the endpoint serving a request to its context
+func WithPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the policy attached to the context, or fallback when there is none
+func FromContext(ctx context.Context, fallback Policy) Policy {
+	if p, ok := ctx.Value(contextKey{}).(Policy); ok {
+		return p
+	}
+	return fallback
+}
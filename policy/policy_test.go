@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	blocks map[string]Policy
+	err    error
+}
+
+func (s *fakeSource) Name() string {
+	return "fake"
+}
+
+func (s *fakeSource) Load(ctx context.Context) (map[string]Policy, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.blocks, nil
+}
+
+func TestParse(t *testing.T) {
+	blocks, err := Parse(`{"Default": {"timeoutSeconds": 25}, "/question-search": {"maxConcurrency": 20, "retry": {"maxAttempts": 5}}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blocks["default"].TimeoutSeconds != 25 {
+		t.Errorf("expected default block, got %+v", blocks)
+	}
+	if blocks["question-search"].MaxConcurrency != 20 || blocks["question-search"].Retry.MaxAttempts != 5 {
+		t.Errorf("expected question-search block, got %+v", blocks)
+	}
+
+	if _, err := Parse(`{"question-search": 5}`); err == nil {
+		t.Error("expected an error for an invalid block")
+	}
+	if blocks, err := Parse("  "); err != nil || len(blocks) != 0 {
+		t.Errorf("expected no blocks for an empty value, got %v %v", blocks, err)
+	}
+}
+
+func TestFor_MergesDefaultsDefaultBlockAndEndpointBlock(t *testing.T) {
+	source := &fakeSource{blocks: map[string]Policy{
+		"default":         {TimeoutSeconds: 25, RetryAfterSeconds: 30},
+		"question-search": {TimeoutSeconds: 10, MaxConcurrency: 20, Retry: RetryProfile{MaxAttempts: 5}, AnswerBackend: "retrieval-converse", Disclaimer: "Internal use only"},
+	}}
+	policies := New(Defaults(3), time.Minute, source)
+
+	p := policies.For("question-search")
+	if p.TimeoutSeconds != 10 || p.MaxConcurrency != 20 || p.RetryAfterSeconds != 30 || p.AnswerBackend != "retrieval-converse" || p.Disclaimer != "Internal use only" {
+		t.Errorf("unexpected merged policy %+v", p)
+	}
+	if p.Retry.MaxAttempts != 5 || p.Retry.InitialBackoffMs != 100 || p.Retry.MaxBackoffMs != 2000 || p.MaxTokens != 2048 {
+		t.Errorf("expected unset retry fields to keep the built-in defaults, got %+v", p)
+	}
+
+	other := policies.For("summary-document")
+	if other.TimeoutSeconds != 25 || other.MaxConcurrency != 0 || other.Retry.MaxAttempts != 3 || other.AnswerBackend != "" || other.Disclaimer != "" {
+		t.Errorf("expected the default block for unconfigured endpoints, got %+v", other)
+	}
+}
+
+func TestReloadKeepsBlocksWhenSourceFails(t *testing.T) {
+	source := &fakeSource{blocks: map[string]Policy{"question-search": {MaxConcurrency: 7}}}
+	policies := New(Defaults(3), 0, source)
+	policies.Reload(context.Background())
+
+	source.err = fmt.Errorf("throttled")
+	if err := policies.Reload(context.Background()); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if p := policies.For("question-search"); p.MaxConcurrency != 7 {
+		t.Fatalf("expected previous blocks to be kept, got %+v", p)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	fallback := Defaults(3)
+	if p := FromContext(context.Background(), fallback); p.Retry.MaxAttempts != 3 {
+		t.Errorf("expected fallback without a policy, got %+v", p)
+	}
+
+	ctx := WithPolicy(context.Background(), Policy{MaxTokens: 512})
+	if p := FromContext(ctx, fallback); p.MaxTokens != 512 {
+		t.Errorf("expected attached policy, got %+v", p)
+	}
+}
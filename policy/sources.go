@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"context"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// EnvSource serves policies from a static value, normally the ENDPOINT_POLICIES env var
+type EnvSource struct {
+	value string
+}
+
+func NewEnvSource(value string) *EnvSource {
+	return &EnvSource{value: value}
+}
+
+func (s *EnvSource) Name() string {
+	return "env"
+}
+
+func (s *EnvSource) Load(ctx context.Context) (map[string]Policy, error) {
+	blocks, err := Parse(s.value)
+	if err != nil {
+		return nil, errors.NewValidationError("invalid ENDPOINT_POLICIES: " + err.Error())
+	}
+	return blocks, nil
+}
+
+// SSMSource reads policies from an SSM parameter using the same JSON format as the env var
+type SSMSource struct {
+	client        *ssm.Client
+	parameterName string
+}
+
+func NewSSMSource(cfg aws.Config, parameterName string) *SSMSource {
+	return &SSMSource{
+		client:        ssm.NewFromConfig(cfg),
+		parameterName: parameterName,
+	}
+}
+
+func (s *SSMSource) Name() string {
+	return "ssm:" + s.parameterName
+}
+
+func (s *SSMSource) Load(ctx context.Context) (map[string]Policy, error) {
+	output, err := s.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(s.parameterName),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read endpoint policies parameter", err)
+	}
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return map[string]Policy{}, nil
+	}
+
+	blocks, err := Parse(*output.Parameter.Value)
+	if err != nil {
+		return nil, errors.NewValidationError("invalid endpoint policies parameter: " + err.Error())
+	}
+	return blocks, nil
+}
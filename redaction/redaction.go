@@ -0,0 +1,110 @@
+package redaction
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/utils"
+)
+
+// detectionTimeout bounds the PII detection call made for one logged text
+const detectionTimeout = 500 * time.Millisecond
+
+// minDetectionScore is the confidence from which a detected entity is redacted
+const minDetectionScore = 0.5
+
+type pattern struct {
+	expression  *regexp.Regexp
+	replacement string
+}
+
+// patterns match the personal data PDPA forbids logging verbatim. National IDs are matched
+// before phone numbers, which are a shorter run of digits.
+var patterns = []pattern{
+	// Thai national ID, 13 digits written plain or as 1-2345-67890-12-3
+	{regexp.MustCompile(`\b\d[- ]?\d{4}[- ]?\d{5}[- ]?\d{2}[- ]?\d\b`), "[NATIONAL_ID]"},
+	// Thai mobile and landline numbers, e.g. 081-234-5678, 02 123 4567 or +66812345678
+	{regexp.MustCompile(`(?:\+66[- ]?|\b0)\d{1,2}[- ]?\d{3}[- ]?\d{3,4}\b`), "[PHONE]"},
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+}
+
+// Redactor replaces personal data in text that is about to be logged. National IDs, phone
+// numbers and email addresses are always matched by pattern. With a detection client,
+// English text is also checked with Amazon Comprehend, which finds names and addresses the
+// patterns cannot; a failed detection falls back to the patterns only.
+type Redactor struct {
+	detector aws.PIIDetectionClient // Optional
+}
+
+func New(detector aws.PIIDetectionClient) *Redactor {
+	return &Redactor{
+		detector: detector,
+	}
+}
+
+// Redact returns text with every match replaced by its type, e.g. [PHONE]. A nil Redactor
+// only applies the patterns.
+func (r *Redactor) Redact(ctx context.Context, text string) string {
+	if text == "" {
+		return text
+	}
+	if r != nil && r.detector != nil && utils.DetectLanguage(text) == utils.LanguageEnglish {
+		text = r.redactDetected(ctx, text)
+	}
+	for _, p := range patterns {
+		text = p.expression.ReplaceAllString(text, p.replacement)
+	}
+	return text
+}
+
+func (r *Redactor) redactDetected(ctx context.Context, text string) string {
+	ctx, cancel := context.WithTimeout(ctx, detectionTimeout)
+	defer cancel()
+
+	entities, err := r.detector.DetectPII(ctx, text)
+	if err != nil {
+		logger.WithContext(ctx).Warn("PII detection failed, redacting by pattern only", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return text
+	}
+	return replaceEntities(text, entities)
+}
+
+// replaceEntities replaces the rune ranges of the entities, skipping ranges that overlap an
+// earlier one
+func replaceEntities(text string, entities []aws.PIIEntity) string {
+	runes := []rune(text)
+	sort.Slice(entities, func(i, j int) bool { return entities[i].BeginOffset < entities[j].BeginOffset })
+
+	var b strings.Builder
+	last := 0
+	for _, entity := range entities {
+		if entity.Score < minDetectionScore || entity.BeginOffset < last || entity.EndOffset > len(runes) || entity.BeginOffset >= entity.EndOffset {
+			continue
+		}
+		b.WriteString(string(runes[last:entity.BeginOffset]))
+		b.WriteString("[" + entity.Type + "]")
+		last = entity.EndOffset
+	}
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+// Global redactor instance, patterns only until Initialize sets one with a detection client
+var globalRedactor *Redactor
+
+// Initialize sets the redactor used by the package-level Redact
+func Initialize(redactor *Redactor) {
+	globalRedactor = redactor
+}
+
+// Redact redacts text with the global redactor
+func Redact(ctx context.Context, text string) string {
+	return globalRedactor.Redact(ctx, text)
+}
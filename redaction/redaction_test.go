@@ -0,0 +1,67 @@
+package redaction
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"teletubpax-api/aws"
+)
+
+type mockDetector struct {
+	entities []aws.PIIEntity
+	err      error
+	calls    int
+}
+
+func (m *mockDetector) DetectPII(ctx context.Context, text string) ([]aws.PIIEntity, error) {
+	m.calls++
+	return m.entities, m.err
+}
+
+func TestRedact_Patterns(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{text: "เลขบัตรประชาชน 1234567890123 ต้องใช้เอกสารอะไร", expected: "เลขบัตรประชาชน [NATIONAL_ID] ต้องใช้เอกสารอะไร"},
+		{text: "ID 1-2345-67890-12-3 please", expected: "ID [NATIONAL_ID] please"},
+		{text: "โทรกลับที่0812345678ได้ไหม", expected: "โทรกลับที่[PHONE]ได้ไหม"},
+		{text: "call 081-234-5678 or 02 123 4567", expected: "call [PHONE] or [PHONE]"},
+		{text: "my number is +66812345678", expected: "my number is [PHONE]"},
+		{text: "email somchai.j@example.co.th", expected: "email [EMAIL]"},
+		{text: "ดอกเบี้ย 2.5% ต่อปี ขั้นต่ำ 10000 บาท", expected: "ดอกเบี้ย 2.5% ต่อปี ขั้นต่ำ 10000 บาท"},
+	}
+
+	for _, tt := range tests {
+		if redacted := Redact(context.Background(), tt.text); redacted != tt.expected {
+			t.Errorf("Redact(%q) = %q, want %q", tt.text, redacted, tt.expected)
+		}
+	}
+}
+
+func TestRedact_DetectedEntities(t *testing.T) {
+	detector := &mockDetector{entities: []aws.PIIEntity{
+		{Type: "NAME", BeginOffset: 11, EndOffset: 25, Score: 0.99},
+		{Type: "ADDRESS", BeginOffset: 0, EndOffset: 2, Score: 0.1}, // Below the score threshold
+	}}
+	redactor := New(detector)
+
+	redacted := redactor.Redact(context.Background(), "My name is Somchai Jaidee, call 0812345678")
+	if redacted != "My name is [NAME], call [PHONE]" {
+		t.Errorf("expected the detected name and the phone pattern redacted, got %q", redacted)
+	}
+
+	redactor.Redact(context.Background(), "ชื่อสมชาย ใจดี")
+	if detector.calls != 1 {
+		t.Errorf("expected Thai text to skip detection, got %d calls", detector.calls)
+	}
+}
+
+func TestRedact_DetectionFailureFallsBackToPatterns(t *testing.T) {
+	redactor := New(&mockDetector{err: fmt.Errorf("access denied")})
+
+	if redacted := redactor.Redact(context.Background(), "call 0812345678"); redacted != "call [PHONE]" {
+		t.Errorf("expected the pattern redaction, got %q", redacted)
+	}
+}
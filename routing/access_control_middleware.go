@@ -0,0 +1,49 @@
+package routing
+
+import (
+	"net/http"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// AccessControlMiddleware attaches the caller's document access, from the roles in the
+// Authorization bearer token, to the request context so retrieval skips documents the
+// caller is not entitled to. Callers without a token only see unrestricted documents, an
+// invalid token answers 401. Endpoints authenticated by the admin token see every document. Tokens already verified by AuthMiddleware are not verified again.
+func AccessControlMiddleware(accessControl *auth.AccessControl) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHealthPath(r.URL.Path) || isAdminTokenRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+				next.ServeHTTP(w, r.WithContext(aws.WithDocumentAccess(r.Context(), accessControl.AccessForClaims(identity.Claims))))
+				return
+			}
+
+			token, ok := bearerToken(r)
+			if !ok {
+				UnauthorizedHandler(w, "Authorization must be a Bearer token")
+				return
+			}
+
+			access, err := accessControl.Access(r.Context(), token)
+			if err != nil {
+				logger.WithContext(r.Context()).Warn("Rejected bearer token", map[string]interface{}{
+					"error":       err.Error(),
+					"path":        r.URL.Path,
+					"remote_addr": r.RemoteAddr,
+				})
+				UnauthorizedHandler(w, "Invalid or expired token")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(aws.WithDocumentAccess(r.Context(), access)))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+)
+
+func TestAccessControlMiddleware(t *testing.T) {
+	accessControl, err := auth.NewAccessControl(&config.Config{
+		AccessControlRules: `{"confidentiality": {"restricted": ["compliance"]}}`,
+		AuthJwksUrl:        "http://127.0.0.1:0/jwks.json",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var access *aws.DocumentAccess
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		access = aws.DocumentAccessFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := AccessControlMiddleware(accessControl)(next)
+
+	tests := []struct {
+		path          string
+		authorization string
+		expectedCode  int
+		restricted    bool
+	}{
+		{path: "/api/teletubpax/question-search", expectedCode: http.StatusOK, restricted: true},
+		{path: "/api/teletubpax/question-search", authorization: "Basic dXNlcjpwYXNz", expectedCode: http.StatusUnauthorized},
+		{path: "/api/teletubpax/question-search", authorization: "Bearer not-a-token", expectedCode: http.StatusUnauthorized},
+		{path: "/api/teletubpax/admin/knowledge-gaps", authorization: "Bearer not-a-token", expectedCode: http.StatusOK},
+		{path: "/api/teletubpax/healthcheck", expectedCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		access = nil
+		req := httptest.NewRequest("POST", tt.path, nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tt.expectedCode {
+			t.Fatalf("%s %q: expected status %d, got %d", tt.path, tt.authorization, tt.expectedCode, w.Code)
+		}
+		if access.Restricted() != tt.restricted {
+			t.Errorf("%s %q: expected restricted %t", tt.path, tt.authorization, tt.restricted)
+		}
+	}
+}
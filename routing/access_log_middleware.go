@@ -0,0 +1,110 @@
+package routing
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// accessLogEntry collects what the middlewares below the access log learn about a request
+type accessLogEntry struct {
+	identity *auth.Identity
+}
+
+type accessLogKey struct{}
+
+// accessLogWriter remembers the status code and counts the body bytes written through it
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.bytes += n
+	return n, err
+}
+
+// Hijack hands the connection to a WebSocket upgrade, logged as 101
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// AccessLogMiddleware logs one line per request once it is answered: method, path, status,
+// duration, response bytes, request ID and the caller's identity. Server errors are logged
+// at ERROR, client errors at WARN and the rest at INFO. Health checks are not logged.
+func AccessLogMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHealthPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			entry := &accessLogEntry{}
+			recorder := &accessLogWriter{ResponseWriter: w}
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry)))
+
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			fields := map[string]interface{}{
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"status":      status,
+				"duration_ms": time.Since(start).Milliseconds(),
+				"bytes":       recorder.bytes,
+				"request_id":  RequestIdFromContext(r.Context()),
+				"remote_addr": r.RemoteAddr,
+				"user_agent":  r.Header.Get("User-Agent"),
+				// Set by ApiKeyMiddleware for tenant keys
+				"tenant_id": r.Header.Get("X-Tenant-Id"),
+			}
+			if entry.identity != nil {
+				fields["user_id"] = entry.identity.UserId
+				fields["username"] = entry.identity.Username
+			}
+
+			log := logger.WithContext(r.Context())
+			switch {
+			case status >= 500:
+				log.Error("Request completed", fields)
+			case status >= 400:
+				log.Warn("Request completed", fields)
+			default:
+				log.Info("Request completed", fields)
+			}
+		})
+	}
+}
+
+// withIdentity attaches a verified caller to the request and to its access log line
+func withIdentity(r *http.Request, identity *auth.Identity) *http.Request {
+	if entry, ok := r.Context().Value(accessLogKey{}).(*accessLogEntry); ok {
+		entry.identity = identity
+	}
+	return r.WithContext(auth.WithIdentity(r.Context(), identity))
+}
@@ -0,0 +1,122 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/logger"
+)
+
+// recordingLogger keeps the entries logged through it
+type recordingLogger struct {
+	entries *[]loggedEntry
+}
+
+type loggedEntry struct {
+	level   string
+	message string
+	fields  map[string]interface{}
+}
+
+func (l recordingLogger) record(level, message string, fields []map[string]interface{}) {
+	entry := loggedEntry{level: level, message: message, fields: map[string]interface{}{}}
+	for _, f := range fields {
+		for key, value := range f {
+			entry.fields[key] = value
+		}
+	}
+	*l.entries = append(*l.entries, entry)
+}
+
+func (l recordingLogger) Debug(message string, fields ...map[string]interface{}) {
+	l.record("DEBUG", message, fields)
+}
+func (l recordingLogger) Info(message string, fields ...map[string]interface{}) {
+	l.record("INFO", message, fields)
+}
+func (l recordingLogger) Warn(message string, fields ...map[string]interface{}) {
+	l.record("WARN", message, fields)
+}
+func (l recordingLogger) Error(message string, fields ...map[string]interface{}) {
+	l.record("ERROR", message, fields)
+}
+func (l recordingLogger) WithContext(ctx context.Context) logger.Logger { return l }
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var entries []loggedEntry
+	logger.Initialize(recordingLogger{entries: &entries})
+	t.Cleanup(func() { logger.Initialize(nil) })
+
+	handler := RequestIdMiddleware()(AccessLogMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = withIdentity(r, &auth.Identity{UserId: "user-1", Username: "somchai"})
+		if auth.UserIdFromContext(r.Context()) != "user-1" {
+			t.Error("expected the identity on the request context")
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("queued"))
+	})))
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/v1/summary-document", nil)
+	req.Header.Set(RequestIdHeader, "req-1")
+	req.Header.Set("X-Tenant-Id", "tenant-a")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(entries) != 1 {
+		t.Fatalf("expected one access log line, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.level != "INFO" || entry.message != "Request completed" {
+		t.Errorf("unexpected entry %s %q", entry.level, entry.message)
+	}
+	expected := map[string]interface{}{
+		"method":     "POST",
+		"path":       "/api/teletubpax/v1/summary-document",
+		"status":     http.StatusAccepted,
+		"bytes":      6,
+		"request_id": "req-1",
+		"tenant_id":  "tenant-a",
+		"user_id":    "user-1",
+		"username":   "somchai",
+	}
+	for key, value := range expected {
+		if entry.fields[key] != value {
+			t.Errorf("expected %s=%v, got %v", key, value, entry.fields[key])
+		}
+	}
+	if _, ok := entry.fields["duration_ms"]; !ok {
+		t.Error("expected the duration to be logged")
+	}
+}
+
+func TestAccessLogMiddleware_LevelFollowsStatus(t *testing.T) {
+	var entries []loggedEntry
+	logger.Initialize(recordingLogger{entries: &entries})
+	t.Cleanup(func() { logger.Initialize(nil) })
+
+	for _, tt := range []struct {
+		status int
+		level  string
+	}{
+		{http.StatusOK, "INFO"},
+		{http.StatusBadRequest, "WARN"},
+		{http.StatusServiceUnavailable, "ERROR"},
+	} {
+		entries = nil
+		handler := AccessLogMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/teletubpax/v1/question-search", nil))
+		if len(entries) != 1 || entries[0].level != tt.level {
+			t.Errorf("expected status %d to log at %s, got %v", tt.status, tt.level, entries)
+		}
+	}
+
+	entries = nil
+	AccessLogMiddleware()(http.HandlerFunc(HealthCheckHandler)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/teletubpax/v1/healthcheck", nil))
+	if len(entries) != 0 {
+		t.Errorf("expected health checks not to be logged, got %v", entries)
+	}
+}
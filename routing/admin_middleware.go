@@ -0,0 +1,35 @@
+package routing
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"teletubpax-api/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminAuthMiddleware protects admin routes with a shared token sent in the X-Admin-Token
+// header. Admin routes are disabled entirely when no token is configured.
+func AdminAuthMiddleware(adminToken string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if adminToken == "" {
+				ForbiddenHandler(w, "Admin API is disabled")
+				return
+			}
+
+			token := r.Header.Get("X-Admin-Token")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+				logger.WithContext(r.Context()).Warn("Rejected admin request", map[string]interface{}{
+					"path":        r.URL.Path,
+					"remote_addr": r.RemoteAddr,
+				})
+				UnauthorizedHandler(w, "Invalid or missing admin token")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
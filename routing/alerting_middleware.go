@@ -0,0 +1,30 @@
+package routing
+
+import (
+	"net/http"
+
+	"teletubpax-api/alerting"
+
+	"github.com/gorilla/mux"
+)
+
+// AlertingMiddleware counts the status of every response for the monitor's error rate and
+// throttling alerts. Health checks and probes are not counted.
+func AlertingMiddleware(monitor *alerting.Monitor) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHealthPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			recorder := &statusResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			monitor.Record(r.Context(), status)
+		})
+	}
+}
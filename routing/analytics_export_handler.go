@@ -0,0 +1,52 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+type AnalyticsExportHandler struct {
+	service services.AnalyticsExportService
+}
+
+func NewAnalyticsExportHandler(service services.AnalyticsExportService) *AnalyticsExportHandler {
+	return &AnalyticsExportHandler{
+		service: service,
+	}
+}
+
+// HandleExport exports the analytics of one UTC day to S3, called by the daily schedule.
+// Optional query parameter: date (YYYY-MM-DD), yesterday by default.
+func (h *AnalyticsExportHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	day := time.Now().UTC().AddDate(0, 0, -1)
+	if value := r.URL.Query().Get("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			BadRequestHandler(w, "date must be YYYY-MM-DD")
+			return
+		}
+		day = parsed
+	}
+
+	result, err := h.service.Export(r.Context(), day)
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+		BadRequestHandler(w, bedrockErr.Message)
+		return
+	}
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to export analytics", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to export analytics")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
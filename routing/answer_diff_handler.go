@@ -0,0 +1,54 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/utils"
+)
+
+type AnswerDiffRequest struct {
+	Question string `json:"question"`
+}
+
+type AnswerDiffHandler struct {
+	service           services.AnswerDiffService
+	maxQuestionLength int
+}
+
+func NewAnswerDiffHandler(service services.AnswerDiffService, maxQuestionLength int) *AnswerDiffHandler {
+	return &AnswerDiffHandler{
+		service:           service,
+		maxQuestionLength: maxQuestionLength,
+	}
+}
+
+func (h *AnswerDiffHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithContext(r.Context())
+
+	request, ok := DecodeJSONRequest(w, r, func(request *AnswerDiffRequest) []Rule {
+		request.Question = utils.NormalizeThaiText(request.Question)
+		return []Rule{
+			Required("question", request.Question),
+			MaxLength("question", request.Question, h.maxQuestionLength),
+		}
+	})
+	if !ok {
+		return
+	}
+
+	diff, err := h.service.Diff(r.Context(), request.Question)
+	if err != nil {
+		log.Error("Failed to diff answers", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to diff answers")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(diff)
+}
@@ -0,0 +1,100 @@
+package routing
+
+import (
+	stdErrors "errors"
+	"net/http"
+	"strings"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+
+	"github.com/gorilla/mux"
+)
+
+// ApiKeyMiddleware authenticates callers by the X-Api-Key header. Unknown or revoked keys
+// answer 401 and keys over their daily quota 429. A key's tenant replaces the X-Tenant-Id
+// header, so tenant settings and token usage follow the key. Without a key, requests are
+// rejected when keys are required, unless IamAuthMiddleware authenticated the caller, and
+// served as before otherwise. The health check, the API documentation, endpoints
+// authenticated by the admin token and queued questions replayed by the worker are exempt.
+// Chat questions are not: each one is counted against the quota of its connection's key.
+func ApiKeyMiddleware(apiKeys services.ApiKeyService, required bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isAuthExempt(r) || isReplayedQuestion(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := strings.TrimSpace(r.Header.Get("X-Api-Key"))
+			if key == "" {
+				if required && auth.IdentityFromContext(r.Context()) == nil {
+					UnauthorizedHandler(w, "X-Api-Key header is required")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			log := logger.WithContext(r.Context())
+			apiKey, err := apiKeys.Authenticate(r.Context(), key)
+			var quotaErr *services.ApiKeyQuotaError
+			if stdErrors.As(err, &quotaErr) {
+				log.Warn("API key quota exceeded", map[string]interface{}{
+					"path":        r.URL.Path,
+					"daily_quota": quotaErr.DailyQuota,
+				})
+				TooManyRequestsHandler(w, "Daily quota of the API key exceeded", quotaErr.RetryAfterSeconds)
+				return
+			}
+			if stdErrors.Is(err, services.ErrInvalidApiKey) {
+				log.Warn("Rejected API key", map[string]interface{}{
+					"path":        r.URL.Path,
+					"remote_addr": r.RemoteAddr,
+				})
+				UnauthorizedHandler(w, "Invalid or revoked API key")
+				return
+			}
+			if err != nil {
+				log.Error("Failed to verify API key", map[string]interface{}{
+					"error": err.Error(),
+				})
+				InternalServerErrorHandler(w, "Failed to verify API key")
+				return
+			}
+
+			if apiKey.TenantId != "" {
+				r.Header.Set("X-Tenant-Id", apiKey.TenantId)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isAuthExempt reports whether a request is served without API keys and bearer tokens
+func isAuthExempt(r *http.Request) bool {
+	if isHealthPath(r.URL.Path) {
+		return true
+	}
+	switch apiPath(r.URL.Path) {
+	case openAPIPath, apiDocsPath:
+		return true
+	}
+	return isAdminTokenRequest(r)
+}
+
+// isAdminTokenRequest reports whether a request is authenticated by the admin token: the
+// admin endpoints, and the endpoints outside /admin that require X-Admin-Token
+func isAdminTokenRequest(r *http.Request) bool {
+	path := apiPath(r.URL.Path)
+	switch path {
+	case "/api/teletubpax/usage":
+		return true
+	case "/api/teletubpax/documents":
+		return r.Method == http.MethodDelete
+	case "/api/teletubpax/documents/export":
+		return r.Method == http.MethodGet
+	}
+	return strings.HasPrefix(path, "/api/teletubpax/admin/")
+}
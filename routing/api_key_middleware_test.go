@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+type stubApiKeyService struct {
+	services.ApiKeyService
+}
+
+func (s stubApiKeyService) Authenticate(ctx context.Context, key string) (*storage.ApiKey, error) {
+	switch key {
+	case "tpx_valid_secret":
+		return &storage.ApiKey{Id: "valid", TenantId: "treasury"}, nil
+	case "tpx_spent_secret":
+		return nil, &services.ApiKeyQuotaError{DailyQuota: 10, RetryAfterSeconds: 60}
+	}
+	return nil, services.ErrInvalidApiKey
+}
+
+func TestApiKeyMiddleware(t *testing.T) {
+	var tenantId string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantId = r.Header.Get("X-Tenant-Id")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name         string
+		path         string
+		key          string
+		required     bool
+		expectedCode int
+		tenantId     string
+	}{
+		{name: "key sets the tenant", path: "/api/teletubpax/question-search", key: "tpx_valid_secret", expectedCode: http.StatusOK, tenantId: "treasury"},
+		{name: "invalid key", path: "/api/teletubpax/question-search", key: "tpx_revoked_secret", expectedCode: http.StatusUnauthorized},
+		{name: "quota exceeded", path: "/api/teletubpax/question-search", key: "tpx_spent_secret", expectedCode: http.StatusTooManyRequests},
+		{name: "optional key", path: "/api/teletubpax/question-search", expectedCode: http.StatusOK, tenantId: "caller"},
+		{name: "required key", path: "/api/teletubpax/question-search", required: true, expectedCode: http.StatusUnauthorized},
+		{name: "admin endpoint", path: "/api/teletubpax/admin/api-keys", key: "tpx_revoked_secret", required: true, expectedCode: http.StatusOK, tenantId: "caller"},
+		{name: "health check", path: "/api/teletubpax/healthcheck", required: true, expectedCode: http.StatusOK, tenantId: "caller"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenantId = ""
+			req := httptest.NewRequest("POST", tt.path, nil)
+			req.Header.Set("X-Tenant-Id", "caller")
+			if tt.key != "" {
+				req.Header.Set("X-Api-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			ApiKeyMiddleware(stubApiKeyService{}, tt.required)(next).ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedCode, w.Code, w.Body.String())
+			}
+			if tenantId != tt.tenantId {
+				t.Errorf("expected tenant %q, got %q", tt.tenantId, tenantId)
+			}
+			if tt.expectedCode == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "60" {
+				t.Errorf("expected Retry-After until the quota resets, got %q", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+const maxApiKeyNameLength = 100
+
+type ApiKeyRequest struct {
+	Name       string `json:"name"`
+	TenantId   string `json:"tenantId"`
+	DailyQuota int64  `json:"dailyQuota"`
+}
+
+type ApiKeyQuotaRequest struct {
+	Id         string `json:"id"`
+	DailyQuota int64  `json:"dailyQuota"`
+}
+
+type ApiKeysResponse struct {
+	ApiKeys []storage.ApiKey `json:"apiKeys"`
+}
+
+type ApiKeysHandler struct {
+	service services.ApiKeyService
+}
+
+func NewApiKeysHandler(service services.ApiKeyService) *ApiKeysHandler {
+	return &ApiKeysHandler{
+		service: service,
+	}
+}
+
+// HandleList returns every API key, revoked ones included, without secrets
+func (h *ApiKeysHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.List(r.Context())
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to list API keys", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to list API keys")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ApiKeysResponse{ApiKeys: keys})
+}
+
+// HandleCreate creates an API key and returns it, the key is not shown again
+func (h *ApiKeysHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var request ApiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		BadRequestHandler(w, "Invalid JSON format")
+		return
+	}
+	defer r.Body.Close()
+
+	if len(request.Name) > maxApiKeyNameLength {
+		BadRequestHandler(w, "name must not exceed 100 characters")
+		return
+	}
+
+	key, err := h.service.Create(r.Context(), request.Name, request.TenantId, request.DailyQuota)
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+		BadRequestHandler(w, bedrockErr.Message)
+		return
+	}
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to create API key", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to create API key")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// HandleRevoke revokes the API key named by the id query parameter. Revoked keys stay
+// listed, with the time they were revoked.
+func (h *ApiKeysHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" {
+		BadRequestHandler(w, "id query parameter is required")
+		return
+	}
+
+	revoked, err := h.service.Revoke(r.Context(), id)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to revoke API key", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to revoke API key")
+		return
+	}
+	if !revoked {
+		NotFoundHandler(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSetQuota sets the daily request quota of an API key, 0 removes the limit
+func (h *ApiKeysHandler) HandleSetQuota(w http.ResponseWriter, r *http.Request) {
+	var request ApiKeyQuotaRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		BadRequestHandler(w, "Invalid JSON format")
+		return
+	}
+	defer r.Body.Close()
+
+	if strings.TrimSpace(request.Id) == "" {
+		BadRequestHandler(w, "id is required")
+		return
+	}
+
+	updated, err := h.service.SetQuota(r.Context(), strings.TrimSpace(request.Id), request.DailyQuota)
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+		BadRequestHandler(w, bedrockErr.Message)
+		return
+	}
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to set API key quota", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to set API key quota")
+		return
+	}
+	if !updated {
+		NotFoundHandler(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
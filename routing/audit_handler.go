@@ -0,0 +1,41 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+
+	"github.com/gorilla/mux"
+)
+
+type AuditHandler struct {
+	audit services.AuditService
+}
+
+func NewAuditHandler(audit services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		audit: audit,
+	}
+}
+
+// Handle returns the audit record with the ID in the path
+func (h *AuditHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	record, err := h.audit.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to read audit record", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to read the audit record")
+		return
+	}
+	if record == nil {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(record)
+}
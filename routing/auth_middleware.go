@@ -0,0 +1,77 @@
+package routing
+
+import (
+	"net/http"
+	"strings"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// AuthMiddleware verifies the Authorization bearer token against the identity provider's
+// signing keys and attaches the caller's identity to the request context, for audit logs,
+// per-user session limits and document access control. An invalid token answers 401, and
+// so does a missing one when AUTH_REQUIRED is set, unless IamAuthMiddleware authenticated
+// the caller. The health check, the API documentation
+// and endpoints authenticated by the admin token are exempt, and queued questions replayed
+// by the worker and chat questions keep the identity they were submitted or connected with.
+func AuthMiddleware(authenticator *auth.Authenticator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isAuthExempt(r) || isReplayedQuestion(r.Context()) || isChatQuestion(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := bearerToken(r)
+			if !ok {
+				UnauthorizedHandler(w, "Authorization must be a Bearer token")
+				return
+			}
+			if token == "" {
+				if authenticator.Required() && auth.IdentityFromContext(r.Context()) == nil {
+					UnauthorizedHandler(w, "Authorization bearer token is required")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			log := logger.WithContext(r.Context())
+			identity, err := authenticator.Authenticate(r.Context(), token)
+			if err != nil {
+				log.Warn("Rejected bearer token", map[string]interface{}{
+					"error":       err.Error(),
+					"path":        r.URL.Path,
+					"remote_addr": r.RemoteAddr,
+				})
+				UnauthorizedHandler(w, "Invalid or expired token")
+				return
+			}
+
+			log.Info("Authenticated request", map[string]interface{}{
+				"user_id":  identity.UserId,
+				"username": identity.Username,
+				"method":   r.Method,
+				"path":     r.URL.Path,
+			})
+			next.ServeHTTP(w, withIdentity(r, identity))
+		})
+	}
+}
+
+// bearerToken returns the token of the Authorization header, empty without the header. It
+// reports false when the header is not a bearer token.
+func bearerToken(r *http.Request) (string, bool) {
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return "", true
+	}
+	scheme, value, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(value), true
+}
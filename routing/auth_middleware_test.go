@@ -0,0 +1,59 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/config"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		required      bool
+		expectedCode  int
+	}{
+		{name: "anonymous", path: "/api/teletubpax/question-search", expectedCode: http.StatusOK},
+		{name: "anonymous when required", path: "/api/teletubpax/question-search", required: true, expectedCode: http.StatusUnauthorized},
+		{name: "invalid token", path: "/api/teletubpax/question-search", authorization: "Bearer not-a-token", expectedCode: http.StatusUnauthorized},
+		{name: "not a bearer token", path: "/api/teletubpax/question-search", authorization: "Basic dXNlcjpwYXNz", expectedCode: http.StatusUnauthorized},
+		{name: "admin endpoint", path: "/api/teletubpax/admin/webhooks", authorization: "Bearer not-a-token", required: true, expectedCode: http.StatusOK},
+		{name: "health check", path: "/api/teletubpax/healthcheck", required: true, expectedCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := auth.NewAuthenticator(&config.Config{AuthJwksUrl: "http://127.0.0.1:0/jwks.json", AuthRequired: tt.required})
+			req := httptest.NewRequest("POST", tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			AuthMiddleware(authenticator)(next).ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("expected status %d, got %d", tt.expectedCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestSessionKey_PrefersTheUser(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", nil)
+	req.Header.Set("X-Session-Id", "widget-1")
+	if got := sessionKey(req); got != "id:widget-1" {
+		t.Errorf("expected the session header without a user, got %q", got)
+	}
+
+	req = req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{UserId: "0f1e2d3c"}))
+	if got := sessionKey(req); got != "user:0f1e2d3c" {
+		t.Errorf("expected the user of the token, got %q", got)
+	}
+}
@@ -0,0 +1,49 @@
+package routing
+
+import (
+	stdErrors "errors"
+	"fmt"
+	"net/http"
+
+	"teletubpax-api/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// BodyLimitMiddleware bounds request bodies to maxBytes. A Content-Length over the limit
+// answers 413 before the body is read; chunked bodies are cut off at the limit, and the
+// handler reading them answers 413 through writeBodyReadError.
+func BodyLimitMiddleware(maxBytes int64) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				logger.WithContext(r.Context()).Warn("Request body too large", map[string]interface{}{
+					"content_length": r.ContentLength,
+					"path":           r.URL.Path,
+					"remote_addr":    r.RemoteAddr,
+				})
+				RequestEntityTooLargeHandler(w, bodyTooLargeMessage(maxBytes))
+				return
+			}
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeBodyReadError answers a request whose body could not be read: 413 when it is over
+// the BodyLimitMiddleware limit, 400 otherwise
+func writeBodyReadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if stdErrors.As(err, &tooLarge) {
+		RequestEntityTooLargeHandler(w, bodyTooLargeMessage(tooLarge.Limit))
+		return
+	}
+	BadRequestHandler(w, "Failed to read request body")
+}
+
+func bodyTooLargeMessage(maxBytes int64) string {
+	return fmt.Sprintf("Request body must be at most %d bytes", maxBytes)
+}
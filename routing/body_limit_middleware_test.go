@@ -0,0 +1,50 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	decode := BodyLimitMiddleware(64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := decodeTestRequest(w, r); ok {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	oversized := `{"question":"` + strings.Repeat("a", 64) + `"}`
+
+	tests := []struct {
+		name         string
+		body         string
+		chunked      bool
+		expectedCode int
+	}{
+		{name: "within the limit", body: `{"question":"fee?","language":"th","documents":["a"]}`, expectedCode: http.StatusOK},
+		{name: "content length over the limit", body: oversized, expectedCode: http.StatusRequestEntityTooLarge},
+		{name: "chunked body over the limit", body: oversized, chunked: true, expectedCode: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1 // Read until the limit cuts it off
+			}
+			w := httptest.NewRecorder()
+			decode.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedCode, w.Code, w.Body.String())
+			}
+			if tt.expectedCode == http.StatusRequestEntityTooLarge {
+				var response ErrorResponse
+				json.Unmarshal(w.Body.Bytes(), &response)
+				if response.Status != 413 || response.Error != "Request body must be at most 64 bytes" {
+					t.Errorf("unexpected response %s", w.Body.String())
+				}
+			}
+		})
+	}
+}
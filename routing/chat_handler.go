@@ -0,0 +1,380 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	ChatMessageQuestion = "question" // Client: a question, answered like question-search
+	ChatMessageReset    = "reset"    // Client: start a new conversation
+	ChatMessagePing     = "ping"     // Client: answered with a pong
+	ChatMessageToken    = "token"    // Server: generated text of the answer
+	ChatMessageDiscard  = "discard"  // Server: the answer is generated again, drop the tokens so far
+	ChatMessageAnswer   = "answer"   // Server: the question-search response
+	ChatMessageError    = "error"    // Server: a message that could not be served
+	ChatMessagePong     = "pong"     // Server: the answer to a ping
+)
+
+const (
+	// chatConnectionTTL is API Gateway's limit on the duration of a WebSocket connection
+	chatConnectionTTL = 2 * time.Hour
+	// chatWriteTimeout bounds a message to a client that stopped reading
+	chatWriteTimeout = 10 * time.Second
+	// chatMessageBacklog is how many messages a client may send while a question is answered
+	chatMessageBacklog = 8
+)
+
+// chatHeaders are the request headers of a chat connection that change the answer, sent
+// with each of its questions. Bearer tokens and IAM signatures are not: the caller was
+// authenticated on connect. The API key is, see chatQuestionKey.
+var chatHeaders = []string{"X-Tenant-Id", "X-Session-Id", "X-Forwarded-For", "X-Fault-Injection"}
+
+// errChatKeyRejected closes a connection whose API key was revoked while it was open
+var errChatKeyRejected = stdErrors.New("API key of the chat connection rejected")
+
+// chatQuestionKey marks a question of a chat connection, served with the identity its caller
+// was authenticated with on connect. Unlike queued questions, its API key is authenticated
+// again and counted against the key's quota by ApiKeyMiddleware, so a connection cannot ask
+// past the quota or after the key is revoked.
+type chatQuestionKey struct{}
+
+func isChatQuestion(ctx context.Context) bool {
+	chat, _ := ctx.Value(chatQuestionKey{}).(bool)
+	return chat
+}
+
+// ChatRequest is a message from a chat client
+type ChatRequest struct {
+	Type                  string `json:"type"` // "question", "reset" or "ping"
+	QuestionSearchRequest        // The question-search body of a question, sessionId defaults to the connection's conversation
+	EnableRelateDocument  bool   `json:"enableRelateDocument,omitempty"`
+}
+
+// ChatMessage is a message to a chat client
+type ChatMessage struct {
+	Type       string          `json:"type"`                 // "token", "discard", "answer", "error" or "pong"
+	Text       string          `json:"text,omitempty"`       // The generated text of a token, or the problem of an error
+	StatusCode int             `json:"statusCode,omitempty"` // Of the question-search response, for an answer or a rejected question
+	Result     json.RawMessage `json:"result,omitempty"`     // The question-search response, or its error, for an answer
+}
+
+// ChatHandler serves chat over WebSocket connections, answering each question like
+// question-search with the answer streamed while it is generated
+type ChatHandler struct {
+	router          http.Handler
+	upgrader        websocket.Upgrader
+	pingInterval    time.Duration
+	maxMessageBytes int64 // 0 for no limit
+}
+
+func NewChatHandler(router http.Handler, pingInterval time.Duration, maxMessageBytes int64) *ChatHandler {
+	return &ChatHandler{
+		router: router,
+		// Any origin, like the CORS headers of the other endpoints
+		upgrader:        websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		pingInterval:    pingInterval,
+		maxMessageBytes: maxMessageBytes,
+	}
+}
+
+// Handle upgrades a request the middlewares authenticated to a chat connection. A connection
+// request of API Gateway's WebSocket API is only accepted, see ChatGateway.
+func (h *ChatHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	connection := newChatConnection(r)
+	if accepted, ok := r.Context().Value(chatConnectKey{}).(*storage.ChatConnection); ok {
+		connection.ConnectionId = accepted.ConnectionId
+		*accepted = *connection
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader answered the error
+		return
+	}
+	logger.WithContext(r.Context()).Info("Chat connection opened", map[string]interface{}{
+		"tenant_id": connection.Headers["X-Tenant-Id"],
+	})
+	// Questions get their own request context: the connection outlives the request's deadline
+	h.serve(context.Background(), conn, connection)
+}
+
+// serve answers the messages of a connection one at a time until the client closes it or
+// stops answering pings. Messages are read while a question is answered, so pongs arrive
+// and a closed connection cancels the answer.
+func (h *ChatHandler) serve(ctx context.Context, conn *websocket.Conn, connection *storage.ChatConnection) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+	send := func(message ChatMessage) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(chatWriteTimeout))
+		return conn.WriteJSON(message)
+	}
+
+	if h.maxMessageBytes > 0 {
+		conn.SetReadLimit(h.maxMessageBytes)
+	}
+	// The connection outlives the server's read timeout
+	conn.SetReadDeadline(time.Time{})
+	// Clients that miss two pings in a row are disconnected
+	if h.pingInterval > 0 {
+		conn.SetReadDeadline(time.Now().Add(2 * h.pingInterval))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * h.pingInterval))
+		})
+		go func() {
+			ticker := time.NewTicker(h.pingInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(chatWriteTimeout)); err != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	messages := make(chan []byte, chatMessageBacklog)
+	go func() {
+		defer close(messages)
+		defer cancel()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			select {
+			case messages <- data:
+			default:
+				send(ChatMessage{Type: ChatMessageError, Text: "Too many messages, wait for the answer"})
+			}
+		}
+	}()
+
+	for data := range messages {
+		if err := serveChatMessage(ctx, h.router, connection, data, send); err != nil {
+			if stdErrors.Is(err, errChatKeyRejected) {
+				writeMu.Lock()
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Invalid or revoked API key"), time.Now().Add(chatWriteTimeout))
+				writeMu.Unlock()
+			}
+			return
+		}
+	}
+}
+
+// newChatConnection returns the caller of a connection request with the headers its
+// questions are sent with
+func newChatConnection(r *http.Request) *storage.ChatConnection {
+	connection := &storage.ChatConnection{
+		Identity:  auth.IdentityFromContext(r.Context()),
+		ApiKey:    strings.TrimSpace(r.Header.Get("X-Api-Key")),
+		Headers:   map[string]string{},
+		ExpiresAt: time.Now().Add(chatConnectionTTL).Unix(),
+	}
+	for _, header := range chatHeaders {
+		if value := r.Header.Get(header); value != "" {
+			connection.Headers[header] = value
+		}
+	}
+	// Session limits of anonymous callers go by their address
+	if connection.Headers["X-Forwarded-For"] == "" {
+		connection.Headers["X-Forwarded-For"] = clientIP(r)
+	}
+	return connection
+}
+
+// serveChatMessage serves a message of a chat connection. Questions are served as
+// question-search requests through the router, like queued questions, so they get the same
+// middlewares, tenant settings and limits, and continue the connection's conversation.
+// Questions rejected by a limit answer an error; a rejected API key closes the connection.
+func serveChatMessage(ctx context.Context, router http.Handler, connection *storage.ChatConnection, data []byte, send func(ChatMessage) error) error {
+	var request ChatRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return send(ChatMessage{Type: ChatMessageError, Text: "Invalid JSON format"})
+	}
+	switch request.Type {
+	case ChatMessageQuestion:
+	case ChatMessageReset:
+		connection.SessionId = ""
+		return nil
+	case ChatMessagePing:
+		return send(ChatMessage{Type: ChatMessagePong})
+	default:
+		return send(ChatMessage{Type: ChatMessageError, Text: `type must be "question", "reset" or "ping"`})
+	}
+
+	if request.SessionId == "" {
+		request.SessionId = connection.SessionId
+	}
+	body, err := json.Marshal(request.QuestionSearchRequest)
+	if err != nil {
+		return send(ChatMessage{Type: ChatMessageError, Text: "Invalid question"})
+	}
+
+	ctx = context.WithValue(ctx, chatQuestionKey{}, true)
+	if connection.Identity != nil {
+		ctx = auth.WithIdentity(ctx, connection.Identity)
+	}
+	ctx = aws.WithTokenStream(ctx, &chatTokenStream{send: send})
+
+	target := apiV1PathPrefix + "question-search"
+	if request.EnableRelateDocument {
+		target += "?enableRelateDocument=true"
+	}
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for header, value := range connection.Headers {
+		req.Header.Set(header, value)
+	}
+	if connection.ApiKey != "" {
+		req.Header.Set("X-Api-Key", connection.ApiKey)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	switch recorder.Code {
+	case http.StatusUnauthorized, http.StatusTooManyRequests:
+		var rejection ErrorResponse
+		json.Unmarshal(recorder.Body.Bytes(), &rejection)
+		if err := send(ChatMessage{Type: ChatMessageError, Text: rejection.Error, StatusCode: recorder.Code}); err != nil {
+			return err
+		}
+		// Questions are only unauthorized when the key was revoked since the connection opened
+		if recorder.Code == http.StatusUnauthorized {
+			return errChatKeyRejected
+		}
+		return nil
+	}
+
+	// The next question continues the conversation of this answer
+	if recorder.Code == http.StatusOK {
+		var response QuestionSearchResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err == nil {
+			connection.SessionId = response.SessionId
+		}
+	}
+
+	message := ChatMessage{Type: ChatMessageAnswer, StatusCode: recorder.Code}
+	if result := bytes.TrimSpace(recorder.Body.Bytes()); json.Valid(result) {
+		message.Result = result
+	}
+	return send(message)
+}
+
+// chatTokenStream sends the text of an answer to the chat client while it is generated
+type chatTokenStream struct {
+	send func(ChatMessage) error
+	sent bool
+}
+
+func (s *chatTokenStream) Token(text string) {
+	s.sent = true
+	s.send(ChatMessage{Type: ChatMessageToken, Text: text})
+}
+
+// Restart tells the client to drop the text of a failed attempt, when any was sent
+func (s *chatTokenStream) Restart() {
+	if s.sent {
+		s.sent = false
+		s.send(ChatMessage{Type: ChatMessageDiscard})
+	}
+}
+
+type chatConnectKey struct{}
+
+// ChatGateway serves chat over an API Gateway WebSocket API, where opening the connection,
+// each of its messages and closing it are separate invocations. Replies are sent to the
+// connection through the management API.
+type ChatGateway struct {
+	router      http.Handler
+	connections storage.ChatConnectionStore
+}
+
+func NewChatGateway(router http.Handler, connections storage.ChatConnectionStore) *ChatGateway {
+	return &ChatGateway{
+		router:      router,
+		connections: connections,
+	}
+}
+
+// Connect authenticates a connection request as a GET of the chat endpoint through the
+// router and keeps its caller for the messages. API Gateway refuses the connection unless
+// the status is 200.
+func (g *ChatGateway) Connect(ctx context.Context, connectionId string, headers map[string]string, query string) (int, []byte) {
+	connection := &storage.ChatConnection{ConnectionId: connectionId}
+	target := apiV1PathPrefix + "chat"
+	if query != "" {
+		target += "?" + query
+	}
+	req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(context.WithValue(ctx, chatConnectKey{}, connection))
+	for header, value := range headers {
+		req.Header.Set(header, value)
+	}
+	recorder := httptest.NewRecorder()
+	g.router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		return recorder.Code, recorder.Body.Bytes()
+	}
+
+	if err := g.connections.SaveConnection(ctx, connection); err != nil {
+		logger.WithContext(ctx).Error("Failed to save chat connection", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return http.StatusInternalServerError, nil
+	}
+	return http.StatusOK, nil
+}
+
+// Message serves a message of a connection, sending the replies through send
+func (g *ChatGateway) Message(ctx context.Context, connectionId string, data []byte, send func(ChatMessage) error) error {
+	connection, err := g.connections.GetConnection(ctx, connectionId)
+	if err != nil {
+		return err
+	}
+	if connection == nil {
+		return send(ChatMessage{Type: ChatMessageError, Text: "The connection expired, connect again"})
+	}
+
+	sessionId := connection.SessionId
+	if err := serveChatMessage(ctx, g.router, connection, data, send); err != nil {
+		if stdErrors.Is(err, errChatKeyRejected) {
+			// Later messages answer that the connection expired
+			return g.connections.DeleteConnection(ctx, connectionId)
+		}
+		return err
+	}
+	if connection.SessionId != sessionId {
+		return g.connections.SaveConnection(ctx, connection)
+	}
+	return nil
+}
+
+// Disconnect forgets a closed connection
+func (g *ChatGateway) Disconnect(ctx context.Context, connectionId string) error {
+	return g.connections.DeleteConnection(ctx, connectionId)
+}
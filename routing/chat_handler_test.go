@@ -0,0 +1,204 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/cache"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+
+	"github.com/gorilla/websocket"
+)
+
+// previousChatSession is the session ID of an earlier answer, continued by a chat
+const previousChatSession = "eyJrYi0xIjoiYmVkcm9jay0xIn0" // {"kb-1":"bedrock-1"}
+
+// streamingQuestionSearch answers by streaming its tokens, recording the conversation each
+// question continued
+func streamingQuestionSearch(sessions *[]string) *mockQuestionSearchService {
+	return &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, question string, enableRelateDocument bool) (string, error) {
+			*sessions = append(*sessions, aws.ConversationFromContext(ctx).SessionId())
+			if stream := aws.TokenStreamFromContext(ctx); stream != nil {
+				// A failed attempt, retried
+				stream.Restart()
+				stream.Token("The fee")
+				stream.Restart()
+				stream.Token("The fee is ")
+				stream.Token("100 baht")
+			}
+			return "The fee is 100 baht", nil
+		},
+	}
+}
+
+func TestChatHandler_StreamsAnswers(t *testing.T) {
+	var sessions []string
+	router := SetupRoutes(RouteServices{QuestionSearch: streamingQuestionSearch(&sessions)}, allRoutesConfig())
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/teletubpax/v1/chat", http.Header{"X-Session-Id": {"widget-1"}})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	ask := func(request string) []ChatMessage {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(request)); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+		var messages []ChatMessage
+		for {
+			var message ChatMessage
+			if err := conn.ReadJSON(&message); err != nil {
+				t.Fatalf("failed to read: %v", err)
+			}
+			messages = append(messages, message)
+			if message.Type == ChatMessageAnswer || message.Type == ChatMessageError || message.Type == ChatMessagePong {
+				return messages
+			}
+		}
+	}
+
+	messages := ask(`{"type":"question","question":"fee","sessionId":"` + previousChatSession + `"}`)
+	var types []string
+	for _, message := range messages {
+		types = append(types, message.Type+":"+message.Text)
+	}
+	if strings.Join(types, "|") != "token:The fee|discard:|token:The fee is |token:100 baht|answer:" {
+		t.Errorf("expected the tokens, the discarded attempt and the answer, got %v", types)
+	}
+	var response QuestionSearchResponse
+	json.Unmarshal(messages[len(messages)-1].Result, &response)
+	if messages[len(messages)-1].StatusCode != http.StatusOK || response.Answer != "The fee is 100 baht" {
+		t.Errorf("expected the question-search response, got %+v", messages[len(messages)-1])
+	}
+
+	// The next question continues the conversation, until it is reset
+	ask(`{"type":"question","question":"and for students?"}`)
+	if messages := ask(`{"type":"ping"}`); messages[0].Type != ChatMessagePong {
+		t.Errorf("expected a pong, got %+v", messages)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"reset"}`))
+	ask(`{"type":"question","question":"fee"}`)
+	if len(sessions) != 3 || sessions[0] != previousChatSession || sessions[1] != previousChatSession || sessions[2] != "" {
+		t.Errorf("expected the conversation to continue until reset, got %v", sessions)
+	}
+
+	if messages := ask(`{"type":"question","question":""}`); messages[0].StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an invalid question to answer 400, got %+v", messages[0])
+	}
+	if messages := ask(`{"type":"vote"}`); messages[0].Type != ChatMessageError {
+		t.Errorf("expected an unknown type to answer an error, got %+v", messages[0])
+	}
+}
+
+func TestChatGateway_KeepsConnections(t *testing.T) {
+	var sessions []string
+	router := SetupRoutes(RouteServices{QuestionSearch: streamingQuestionSearch(&sessions)}, allRoutesConfig())
+	gateway := NewChatGateway(router, storage.NewCacheChatConnectionStore(cache.NewMemoryStore(0)))
+	ctx := context.Background()
+
+	if status, _ := gateway.Connect(ctx, "connection-1", map[string]string{"X-Session-Id": "widget-1"}, ""); status != http.StatusOK {
+		t.Fatalf("expected the connection to be accepted, got %d", status)
+	}
+
+	var received []ChatMessage
+	send := func(message ChatMessage) error {
+		received = append(received, message)
+		return nil
+	}
+	if err := gateway.Message(ctx, "connection-1", []byte(`{"type":"question","question":"fee","sessionId":"`+previousChatSession+`"}`), send); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(received) != 5 || received[4].Type != ChatMessageAnswer || received[4].StatusCode != http.StatusOK {
+		t.Errorf("expected the streamed answer, got %+v", received)
+	}
+
+	// Each message is a separate invocation, the conversation is kept with the connection
+	gateway.Message(ctx, "connection-1", []byte(`{"type":"question","question":"and for students?"}`), send)
+	if len(sessions) != 2 || sessions[1] != previousChatSession {
+		t.Errorf("expected the second question to continue the conversation, got %v", sessions)
+	}
+
+	gateway.Disconnect(ctx, "connection-1")
+	received = nil
+	gateway.Message(ctx, "connection-1", []byte(`{"type":"ping"}`), send)
+	if len(received) != 1 || received[0].Type != ChatMessageError {
+		t.Errorf("expected a closed connection to answer an error, got %+v", received)
+	}
+}
+
+// revocableApiKeyService allows a key a daily quota of requests until it is revoked
+type revocableApiKeyService struct {
+	services.ApiKeyService
+	requests int
+	quota    int
+	revoked  bool
+}
+
+func (s *revocableApiKeyService) Authenticate(ctx context.Context, key string) (*storage.ApiKey, error) {
+	if s.revoked || key != "tpx_valid_secret" {
+		return nil, services.ErrInvalidApiKey
+	}
+	s.requests++
+	if s.requests > s.quota {
+		return nil, &services.ApiKeyQuotaError{DailyQuota: int64(s.quota), RetryAfterSeconds: 60}
+	}
+	return &storage.ApiKey{Id: "valid", TenantId: "treasury"}, nil
+}
+
+func TestChatGateway_AuthenticatesTheApiKeyOfEachQuestion(t *testing.T) {
+	var sessions []string
+	apiKeys := &revocableApiKeyService{quota: 2}
+	cfg := allRoutesConfig()
+	cfg.ApiKeyRequired = true
+	router := SetupRoutes(RouteServices{QuestionSearch: streamingQuestionSearch(&sessions), ApiKeys: apiKeys}, cfg)
+	gateway := NewChatGateway(router, storage.NewCacheChatConnectionStore(cache.NewMemoryStore(0)))
+	ctx := context.Background()
+
+	if status, _ := gateway.Connect(ctx, "connection-1", map[string]string{"X-Api-Key": "tpx_valid_secret"}, ""); status != http.StatusOK {
+		t.Fatalf("expected the connection to be accepted, got %d", status)
+	}
+
+	var received []ChatMessage
+	send := func(message ChatMessage) error {
+		received = append(received, message)
+		return nil
+	}
+	ask := func() ChatMessage {
+		received = nil
+		if err := gateway.Message(ctx, "connection-1", []byte(`{"type":"question","question":"fee"}`), send); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return received[len(received)-1]
+	}
+
+	// The connection request used the first request of the quota
+	if message := ask(); message.Type != ChatMessageAnswer || message.StatusCode != http.StatusOK {
+		t.Errorf("expected the question within the quota to be answered, got %+v", message)
+	}
+	if message := ask(); message.Type != ChatMessageError || message.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the question over the quota to answer 429, got %+v", message)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("expected only the question within the quota to be answered, got %d", len(sessions))
+	}
+
+	apiKeys.revoked = true
+	if message := ask(); message.Type != ChatMessageError || message.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the question after the revocation to answer 401, got %+v", message)
+	}
+	if message := ask(); message.Type != ChatMessageError || message.StatusCode != 0 {
+		t.Errorf("expected the connection to be closed after the revocation, got %+v", message)
+	}
+}
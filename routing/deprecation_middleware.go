@@ -0,0 +1,29 @@
+package routing
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// legacyPathsDeprecatedAt is when the unversioned paths were superseded by /api/teletubpax/v1
+var legacyPathsDeprecatedAt = time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC)
+
+// DeprecationMiddleware marks responses of the unversioned /api/teletubpax paths as
+// deprecated (RFC 9745) and links the /v1 path replacing them. The Sunset header (RFC 8594)
+// announces when the unversioned paths go away, it is left out when sunset is zero.
+func DeprecationMiddleware(sunset time.Time) mux.MiddlewareFunc {
+	deprecation := "@" + strconv.FormatInt(legacyPathsDeprecatedAt.Unix(), 10)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecation)
+			w.Header().Set("Link", "<"+versionedPath(r.URL.Path)+`>; rel="successor-version"`)
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
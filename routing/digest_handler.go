@@ -0,0 +1,138 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+type DigestSubscriptionRequest struct {
+	Team    string `json:"team"`
+	Channel string `json:"channel"`
+	Target  string `json:"target"`
+}
+
+type DigestSubscriptionsResponse struct {
+	Subscriptions []storage.DigestSubscription `json:"subscriptions"`
+}
+
+type DigestHandler struct {
+	service services.DigestService
+}
+
+func NewDigestHandler(service services.DigestService) *DigestHandler {
+	return &DigestHandler{
+		service: service,
+	}
+}
+
+// HandlePreview returns the digest without sending it. Optional query parameter: days.
+func (h *DigestHandler) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	days, ok := optionalIntParam(r, "days")
+	if !ok {
+		BadRequestHandler(w, "days must be a number")
+		return
+	}
+
+	digest, err := h.service.Preview(r.Context(), days)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to build digest", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to build digest")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(digest)
+}
+
+// HandleSend delivers the digest to every subscription, called by the weekly schedule
+func (h *DigestHandler) HandleSend(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.Send(r.Context())
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to send digest", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to send digest")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *DigestHandler) HandleListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.service.ListSubscriptions(r.Context())
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to list digest subscriptions", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to list digest subscriptions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DigestSubscriptionsResponse{Subscriptions: subscriptions})
+}
+
+func (h *DigestHandler) HandleSubscribe(w http.ResponseWriter, r *http.Request) {
+	var request DigestSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		BadRequestHandler(w, "Invalid JSON format")
+		return
+	}
+	defer r.Body.Close()
+
+	subscription, err := h.service.Subscribe(r.Context(), request.Team, request.Channel, request.Target)
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+		BadRequestHandler(w, bedrockErr.Message)
+		return
+	}
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to create digest subscription", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to create digest subscription")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// HandleUnsubscribe removes the subscription named by the id query parameter
+func (h *DigestHandler) HandleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" {
+		BadRequestHandler(w, "id query parameter is required")
+		return
+	}
+
+	deleted, err := h.service.Unsubscribe(r.Context(), id)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to delete digest subscription", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to delete digest subscription")
+		return
+	}
+	if !deleted {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	logger.WithContext(r.Context()).Info("Digest subscription deleted", map[string]interface{}{
+		"id": id,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
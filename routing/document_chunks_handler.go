@@ -0,0 +1,89 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/utils"
+	"teletubpax-api/warnings"
+)
+
+type DocumentChunksResponse struct {
+	Document string              `json:"document"`
+	Chunks   []aws.DocumentChunk `json:"chunks"`
+	Total    int                 `json:"total"`
+	Warnings []warnings.Warning  `json:"warnings,omitempty"`
+}
+
+type DocumentChunksHandler struct {
+	service     services.DocumentDetailsService
+	translation services.TranslationService // Optional
+}
+
+func NewDocumentChunksHandler(service services.DocumentDetailsService, translation services.TranslationService) *DocumentChunksHandler {
+	return &DocumentChunksHandler{
+		service:     service,
+		translation: translation,
+	}
+}
+
+func (h *DocumentChunksHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithContext(r.Context())
+
+	// Validate uri query parameter
+	documentUri := strings.TrimSpace(r.URL.Query().Get("uri"))
+	if documentUri == "" {
+		BadRequestHandler(w, "uri query parameter is required")
+		return
+	}
+	if !strings.HasPrefix(documentUri, "s3://") && !strings.HasPrefix(documentUri, "https://") {
+		BadRequestHandler(w, "uri must be an s3:// URI or an https:// document URL")
+		return
+	}
+
+	// Optional snippet language, chunks in another language are translated
+	language := r.URL.Query().Get("language")
+	if language != "" && !utils.IsSupportedLanguage(language) {
+		BadRequestHandler(w, "language must be \"th\" or \"en\"")
+		return
+	}
+
+	ctx, collected := warnings.WithCollector(r.Context())
+	chunks, err := h.service.GetDocumentChunks(ctx, documentUri)
+	if err != nil {
+		log.Error("Failed to retrieve document chunks", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to retrieve document chunks")
+		return
+	}
+
+	if language != "" && h.translation == nil && len(chunks) > 0 {
+		warnings.Add(ctx, warnings.CodeTranslationFailed, "Translation is disabled, snippets are shown in their original language")
+	}
+	if language != "" && h.translation != nil && len(chunks) > 0 {
+		contents := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			contents[i] = chunk.Content
+		}
+		for i, translated := range h.translation.TranslateAll(ctx, contents, language) {
+			chunks[i].Content = translated.Text
+			chunks[i].SourceText = translated.SourceText
+		}
+	}
+
+	response := DocumentChunksResponse{
+		Document: documentUri,
+		Chunks:   chunks,
+		Total:    len(chunks),
+		Warnings: collected.List(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,82 @@
+package routing
+
+import (
+	"encoding/json"
+	stdErrors "errors"
+	"net/http"
+	"strings"
+
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+type DocumentComparisonRequest struct {
+	OlderDocument string `json:"olderDocument"`
+	NewerDocument string `json:"newerDocument"`
+}
+
+type DocumentComparisonHandler struct {
+	service services.DocumentDetailsService
+}
+
+func NewDocumentComparisonHandler(service services.DocumentDetailsService) *DocumentComparisonHandler {
+	return &DocumentComparisonHandler{
+		service: service,
+	}
+}
+
+// Handle summarizes the changes between any two documents, given by s3:// URI or link
+func (h *DocumentComparisonHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithContext(r.Context())
+
+	request, ok := DecodeJSONRequest(w, r, func(request *DocumentComparisonRequest) []Rule {
+		request.OlderDocument = strings.TrimSpace(request.OlderDocument)
+		request.NewerDocument = strings.TrimSpace(request.NewerDocument)
+		return []Rule{
+			Required("olderDocument", request.OlderDocument),
+			Required("newerDocument", request.NewerDocument),
+			documentUri("olderDocument", request.OlderDocument),
+			documentUri("newerDocument", request.NewerDocument),
+		}
+	})
+	if !ok {
+		return
+	}
+
+	comparison, err := h.service.CompareDocuments(r.Context(), request.OlderDocument, request.NewerDocument)
+	if err != nil {
+		if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+			BadRequestHandler(w, bedrockErr.Message)
+			return
+		}
+		if stdErrors.Is(err, services.ErrDocumentNotFound) {
+			UnprocessableEntityHandler(w, err.Error())
+			return
+		}
+		if stdErrors.Is(err, services.ErrComparisonsPaused) {
+			ServiceUnavailableHandler(w, err.Error())
+			return
+		}
+		log.Error("Failed to compare documents", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to compare documents")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(comparison)
+}
+
+// documentUri rejects values other than an s3:// URI or an https:// document link. An empty
+// value is left to Required.
+func documentUri(field string, value string) Rule {
+	return func() *FieldError {
+		if value == "" || strings.HasPrefix(value, "s3://") || strings.HasPrefix(value, "https://") {
+			return nil
+		}
+		return &FieldError{Field: field, Message: field + " must be an s3:// URI or an https:// document URL"}
+	}
+}
@@ -0,0 +1,135 @@
+package routing
+
+import (
+	"encoding/json"
+	stdErrors "errors"
+	"net/http"
+	"strings"
+
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+type DocumentDeletionRequest struct {
+	Uri string `json:"uri"` // s3:// URI or public document URL
+}
+
+type DeletedDocumentsResponse struct {
+	Documents []storage.DeletedDocument `json:"documents"`
+	Total     int                       `json:"total"`
+}
+
+type DocumentDeletionHandler struct {
+	service services.DocumentDeletionService
+}
+
+func NewDocumentDeletionHandler(service services.DocumentDeletionService) *DocumentDeletionHandler {
+	return &DocumentDeletionHandler{
+		service: service,
+	}
+}
+
+// HandleList returns the soft-deleted documents, including purged documents that are still
+// excluded until the knowledge base is synced
+func (h *DocumentDeletionHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	documents, err := h.service.ListDeleted(r.Context())
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to list deleted documents", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to list deleted documents")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DeletedDocumentsResponse{Documents: documents, Total: len(documents)})
+}
+
+// HandleDelete soft-deletes a document, it stays restorable until its purge date
+func (h *DocumentDeletionHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	uri, ok := h.decodeUri(w, r)
+	if !ok {
+		return
+	}
+
+	document, err := h.service.SoftDelete(r.Context(), uri)
+	if err != nil {
+		h.writeError(w, r, err, "Failed to delete document")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(document)
+}
+
+// HandleRestore brings a soft-deleted document back into retrieval and listings
+func (h *DocumentDeletionHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	uri, ok := h.decodeUri(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Restore(r.Context(), uri); err != nil {
+		h.writeError(w, r, err, "Failed to restore document")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{Message: "Document restored", Status: 200})
+}
+
+// HandlePurge deletes the source objects of documents past their retention period. Called
+// by the daily schedule, and can be run by hand.
+func (h *DocumentDeletionHandler) HandlePurge(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.Purge(r.Context())
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to purge deleted documents", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to purge deleted documents")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *DocumentDeletionHandler) decodeUri(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var request DocumentDeletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		BadRequestHandler(w, "Invalid JSON format")
+		return "", false
+	}
+	defer r.Body.Close()
+
+	uri := strings.TrimSpace(request.Uri)
+	if uri == "" {
+		BadRequestHandler(w, "uri is required")
+		return "", false
+	}
+	return uri, true
+}
+
+func (h *DocumentDeletionHandler) writeError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+		BadRequestHandler(w, bedrockErr.Message)
+		return
+	}
+	switch {
+	case stdErrors.Is(err, services.ErrDocumentNotDeleted):
+		NotFoundHandler(w, r)
+	case stdErrors.Is(err, services.ErrDocumentAlreadyDeleted), stdErrors.Is(err, services.ErrDocumentPurged):
+		ConflictHandler(w, err.Error())
+	default:
+		logger.WithContext(r.Context()).Error(message, map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, message)
+	}
+}
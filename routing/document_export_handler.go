@@ -0,0 +1,105 @@
+package routing
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+const (
+	exportFormatNDJSON = "ndjson"
+	exportFormatCSV    = "csv"
+)
+
+// documentExportColumns are the CSV columns, the fields of services.ExportedDocument
+var documentExportColumns = []string{"link", "topic", "version", "yearMonth", "lastModified", "knowledgeBaseId", "dataSourceId"}
+
+type DocumentExportHandler struct {
+	service services.DocumentExportService
+}
+
+func NewDocumentExportHandler(service services.DocumentExportService) *DocumentExportHandler {
+	return &DocumentExportHandler{
+		service: service,
+	}
+}
+
+// Handle streams the metadata of every indexed document as NDJSON, one document per line, or
+// as CSV with format=csv. Documents are written as they are listed, so the export is never
+// held in memory. A failure after the first document ends the response early.
+func (h *DocumentExportHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatNDJSON
+	}
+	if format != exportFormatNDJSON && format != exportFormatCSV {
+		BadRequestHandler(w, `format must be "ndjson" or "csv"`)
+		return
+	}
+
+	// The status is sent with the first document, so a failure before it still answers 500
+	started := false
+	start := func() {
+		started = true
+		if format == exportFormatCSV {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="documents.csv"`)
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="documents.ndjson"`)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	encoder := json.NewEncoder(w)
+	csvWriter := csv.NewWriter(w)
+	count := 0
+	err := h.service.Export(r.Context(), func(document services.ExportedDocument) error {
+		if !started {
+			start()
+			if format == exportFormatCSV {
+				csvWriter.Write(documentExportColumns)
+			}
+		}
+		count++
+		if format == exportFormatNDJSON {
+			return encoder.Encode(document)
+		}
+		return csvWriter.Write([]string{
+			document.Link,
+			document.Topic,
+			strconv.Itoa(document.Version),
+			document.YearMonth,
+			document.LastModified,
+			document.KnowledgeBaseId,
+			document.DataSourceId,
+		})
+	})
+	if err == nil && format == exportFormatCSV {
+		csvWriter.Flush()
+		err = csvWriter.Error()
+	}
+
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to export documents", map[string]interface{}{
+			"error":     err.Error(),
+			"documents": count,
+		})
+		if !started {
+			InternalServerErrorHandler(w, "Failed to export documents")
+		}
+		return
+	}
+	if !started {
+		// No documents: an empty export, with the header row of a CSV
+		start()
+		if format == exportFormatCSV {
+			csvWriter.Write(documentExportColumns)
+			csvWriter.Flush()
+		}
+	}
+}
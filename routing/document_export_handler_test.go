@@ -0,0 +1,123 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"teletubpax-api/config"
+	"teletubpax-api/services"
+)
+
+type mockDocumentExportService struct {
+	documents []services.ExportedDocument
+	err       error
+}
+
+func (m *mockDocumentExportService) Export(ctx context.Context, write func(services.ExportedDocument) error) error {
+	for _, document := range m.documents {
+		if err := write(document); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+
+func TestDocumentExportHandler_Formats(t *testing.T) {
+	handler := NewDocumentExportHandler(&mockDocumentExportService{documents: []services.ExportedDocument{
+		{Link: "https://docs.example/fee-2.pdf", Topic: "fee", Version: 2, YearMonth: "2025/05", LastModified: "2025-05-20T03:00:00Z", KnowledgeBaseId: "kb-1", DataSourceId: "ds-1"},
+		{Link: "https://docs.example/loan, car.pdf", Topic: "loan, car", YearMonth: "0000/00", KnowledgeBaseId: "kb-2", DataSourceId: "ds-2"},
+	}})
+
+	w := httptest.NewRecorder()
+	handler.Handle(w, httptest.NewRequest("GET", "/api/teletubpax/v1/documents/export", nil))
+	expected := `{"link":"https://docs.example/fee-2.pdf","topic":"fee","version":2,"yearMonth":"2025/05","lastModified":"2025-05-20T03:00:00Z","knowledgeBaseId":"kb-1","dataSourceId":"ds-1"}` + "\n" +
+		`{"link":"https://docs.example/loan, car.pdf","topic":"loan, car","version":0,"yearMonth":"0000/00","lastModified":"","knowledgeBaseId":"kb-2","dataSourceId":"ds-2"}` + "\n"
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" || w.Body.String() != expected {
+		t.Errorf("expected one JSON document per line, got %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.Handle(w, httptest.NewRequest("GET", "/api/teletubpax/v1/documents/export?format=csv", nil))
+	expected = "link,topic,version,yearMonth,lastModified,knowledgeBaseId,dataSourceId\n" +
+		"https://docs.example/fee-2.pdf,fee,2,2025/05,2025-05-20T03:00:00Z,kb-1,ds-1\n" +
+		"\"https://docs.example/loan, car.pdf\",\"loan, car\",0,0000/00,,kb-2,ds-2\n"
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv; charset=utf-8" || w.Body.String() != expected {
+		t.Errorf("expected a CSV with a header row, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.Handle(w, httptest.NewRequest("GET", "/api/teletubpax/v1/documents/export?format=xlsx", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown format to answer 400, got %d", w.Code)
+	}
+}
+
+func TestDocumentExportHandler_Errors(t *testing.T) {
+	// A failure before the first document still answers 500
+	handler := NewDocumentExportHandler(&mockDocumentExportService{err: errors.New("AccessDeniedException")})
+	w := httptest.NewRecorder()
+	handler.Handle(w, httptest.NewRequest("GET", "/api/teletubpax/v1/documents/export", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+
+	// An empty corpus is an empty export
+	handler = NewDocumentExportHandler(&mockDocumentExportService{})
+	w = httptest.NewRecorder()
+	handler.Handle(w, httptest.NewRequest("GET", "/api/teletubpax/v1/documents/export?format=csv", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "link,topic,version,yearMonth,lastModified,knowledgeBaseId,dataSourceId\n" {
+		t.Errorf("expected only the header row, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDocumentExport_AuthenticatedByTheAdminToken(t *testing.T) {
+	export := &mockDocumentExportService{documents: []services.ExportedDocument{{Link: "https://docs.example/fee-2.pdf", Topic: "fee", Version: 2}}}
+	router := SetupRoutes(adminTokenRouteServices(t, RouteServices{DocumentExport: export}))
+
+	// Neither an API key nor a bearer token is asked for, a token sent anyway is not verified
+	for _, authorization := range []string{"", "Bearer not-a-token"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/teletubpax/v1/documents/export", nil)
+		req.Header.Set("X-Admin-Token", "secret")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "fee-2.pdf") {
+			t.Errorf("%q: expected the admin token to be enough, got %d: %s", authorization, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/teletubpax/v1/documents/export", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a request without the admin token to answer 401, got %d", w.Code)
+	}
+}
+
+func TestDocumentExport_NotSigned(t *testing.T) {
+	export := &mockDocumentExportService{documents: []services.ExportedDocument{{Link: "https://docs.example/fee-2.pdf", Topic: "fee", Version: 2}}}
+	router := SetupRoutes(RouteServices{DocumentExport: export, ResponseSigningKey: []byte("test-signing-key")}, &config.Config{AdminToken: "secret"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/teletubpax/v1/documents/export?format=csv", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "fee-2.pdf") {
+		t.Fatalf("expected the export, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get(SignatureHeader) != "" || w.Header().Get(SignatureTimestampHeader) != "" {
+		t.Errorf("expected the streamed export not to be signed, got %q", w.Header().Get(SignatureHeader))
+	}
+
+	// Other responses are still signed
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/teletubpax/v1/healthcheck", nil))
+	if w.Header().Get(SignatureHeader) == "" {
+		t.Errorf("expected the health check to be signed")
+	}
+}
@@ -0,0 +1,92 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+const (
+	defaultPreviewLength = 300
+	maxPreviewLength     = 2000
+)
+
+type DocumentPreviewResponse struct {
+	Document   string  `json:"document"`
+	Mode       string  `json:"mode"` // "start" of the document, or the chunk most "relevant" to q
+	Excerpt    string  `json:"excerpt"`
+	PageNumber int     `json:"pageNumber,omitempty"`
+	Score      float64 `json:"score,omitempty"` // Retrieval score of the relevant chunk
+	Truncated  bool    `json:"truncated"`
+}
+
+type DocumentPreviewHandler struct {
+	service           services.DocumentDetailsService
+	maxQuestionLength int
+}
+
+func NewDocumentPreviewHandler(service services.DocumentDetailsService, maxQuestionLength int) *DocumentPreviewHandler {
+	return &DocumentPreviewHandler{
+		service:           service,
+		maxQuestionLength: maxQuestionLength,
+	}
+}
+
+// Handle returns a short excerpt of the document in the uri query parameter. Optional query
+// parameters: q (show the chunk most relevant to it instead of the beginning) and length
+// (characters of the excerpt).
+func (h *DocumentPreviewHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	documentUri := strings.TrimSpace(r.URL.Query().Get("uri"))
+	if documentUri == "" {
+		BadRequestHandler(w, "uri query parameter is required")
+		return
+	}
+	if !strings.HasPrefix(documentUri, "s3://") && !strings.HasPrefix(documentUri, "https://") {
+		BadRequestHandler(w, "uri must be an s3:// URI or an https:// document URL")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if utf8.RuneCountInString(query) > h.maxQuestionLength {
+		BadRequestHandler(w, fmt.Sprintf("q must not exceed %d characters", h.maxQuestionLength))
+		return
+	}
+
+	length, ok := optionalIntParam(r, "length")
+	if !ok || length < 0 || length > maxPreviewLength {
+		BadRequestHandler(w, fmt.Sprintf("length must be a number between 1 and %d", maxPreviewLength))
+		return
+	}
+	if length == 0 {
+		length = defaultPreviewLength
+	}
+
+	preview, err := h.service.GetDocumentPreview(r.Context(), documentUri, query, length)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to preview document", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to preview the document")
+		return
+	}
+	if preview == nil {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DocumentPreviewResponse{
+		Document:   documentUri,
+		Mode:       preview.Mode,
+		Excerpt:    preview.Excerpt,
+		PageNumber: preview.PageNumber,
+		Score:      preview.Score,
+		Truncated:  preview.Truncated,
+	})
+}
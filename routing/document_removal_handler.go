@@ -0,0 +1,55 @@
+package routing
+
+import (
+	"encoding/json"
+	stdErrors "errors"
+	"net/http"
+	"strings"
+
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+type DocumentRemovalHandler struct {
+	service services.DocumentRemovalService
+}
+
+func NewDocumentRemovalHandler(service services.DocumentRemovalService) *DocumentRemovalHandler {
+	return &DocumentRemovalHandler{
+		service: service,
+	}
+}
+
+// Handle deletes the document in the uri query parameter for good and syncs the knowledge
+// bases. Unlike the soft delete of /admin/documents/delete it cannot be restored, except
+// from the archive bucket.
+func (h *DocumentRemovalHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	uri := strings.TrimSpace(r.URL.Query().Get("uri"))
+	if uri == "" {
+		BadRequestHandler(w, "uri is required")
+		return
+	}
+
+	removal, err := h.service.Remove(r.Context(), uri)
+	if err != nil {
+		if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+			BadRequestHandler(w, bedrockErr.Message)
+			return
+		}
+		if stdErrors.Is(err, services.ErrDocumentNotFound) {
+			NotFoundHandler(w, r)
+			return
+		}
+		logger.WithContext(r.Context()).Error("Failed to remove document", map[string]interface{}{
+			"uri":   uri,
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to remove document")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(removal)
+}
@@ -0,0 +1,75 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/config"
+	"teletubpax-api/services"
+)
+
+type mockDocumentRemovalService struct {
+	removed []string
+}
+
+func (m *mockDocumentRemovalService) Remove(ctx context.Context, documentUri string) (*services.DocumentRemoval, error) {
+	m.removed = append(m.removed, documentUri)
+	return &services.DocumentRemoval{}, nil
+}
+
+// adminTokenRouteServices authenticates callers by API key and bearer token, both required,
+// with document access control
+func adminTokenRouteServices(t *testing.T, svc RouteServices) (RouteServices, *config.Config) {
+	cfg := &config.Config{
+		AdminToken:         "secret",
+		ApiKeyRequired:     true,
+		AuthJwksUrl:        "http://127.0.0.1:0/jwks.json",
+		AuthRequired:       true,
+		AccessControlRules: `{"confidentiality": {"restricted": ["compliance"]}}`,
+	}
+	accessControl, err := auth.NewAccessControl(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	svc.ApiKeys = stubApiKeyService{}
+	svc.Authenticator = auth.NewAuthenticator(cfg)
+	svc.AccessControl = accessControl
+	return svc, cfg
+}
+
+func TestDocumentRemoval_AuthenticatedByTheAdminToken(t *testing.T) {
+	removal := &mockDocumentRemovalService{}
+	router := SetupRoutes(adminTokenRouteServices(t, RouteServices{DocumentRemoval: removal}))
+
+	// Neither an API key nor a bearer token is asked for, a token sent anyway is not verified
+	for _, authorization := range []string{"", "Bearer not-a-token"} {
+		req := httptest.NewRequest(http.MethodDelete, "/api/teletubpax/v1/documents?uri=s3://docs/fee.pdf", nil)
+		req.Header.Set("X-Admin-Token", "secret")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%q: expected the admin token to be enough, got %d: %s", authorization, w.Code, w.Body.String())
+		}
+	}
+	if len(removal.removed) != 2 {
+		t.Errorf("expected the documents to be removed, got %v", removal.removed)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/teletubpax/v1/documents?uri=s3://docs/fee.pdf", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || len(removal.removed) != 2 {
+		t.Errorf("expected a request without the admin token to answer 401, got %d", w.Code)
+	}
+
+	// Only the DELETE is authenticated by the admin token
+	if isAuthExempt(httptest.NewRequest(http.MethodPost, "/api/teletubpax/v1/documents", nil)) {
+		t.Errorf("expected other methods of /documents to need an API key")
+	}
+}
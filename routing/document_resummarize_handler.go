@@ -0,0 +1,96 @@
+package routing
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+type DocumentResummarizeRequest struct {
+	Restart      bool `json:"restart"`
+	MaxDocuments int  `json:"maxDocuments"`
+}
+
+type DocumentResummarizeResponse struct {
+	Job *storage.JobCheckpoint `json:"job"`
+}
+
+type DocumentResummarizeHandler struct {
+	service services.DocumentResummarizeService
+}
+
+func NewDocumentResummarizeHandler(service services.DocumentResummarizeService) *DocumentResummarizeHandler {
+	return &DocumentResummarizeHandler{
+		service: service,
+	}
+}
+
+// HandleRun runs (or resumes) the re-summarization job within the request's lifetime and
+// returns the checkpoint. Callers re-trigger it until the job status is "completed".
+func (h *DocumentResummarizeHandler) HandleRun(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithContext(r.Context())
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyReadError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	// The body is optional, an empty body resumes the job without limits
+	var request DocumentResummarizeRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			BadRequestHandler(w, "Invalid JSON format")
+			return
+		}
+	}
+
+	if request.MaxDocuments < 0 {
+		BadRequestHandler(w, "maxDocuments must not be negative")
+		return
+	}
+
+	checkpoint, err := h.service.Run(r.Context(), services.ResummarizeOptions{
+		Restart:      request.Restart,
+		MaxDocuments: request.MaxDocuments,
+	})
+	if err == services.ErrSafeModeEnabled {
+		ServiceUnavailableHandler(w, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("Document re-summarization failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to run document re-summarization")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DocumentResummarizeResponse{Job: checkpoint})
+}
+
+func (h *DocumentResummarizeHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	checkpoint, err := h.service.Status(r.Context())
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to read re-summarization status", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to read job status")
+		return
+	}
+	if checkpoint == nil {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DocumentResummarizeResponse{Job: checkpoint})
+}
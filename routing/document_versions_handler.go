@@ -0,0 +1,62 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/warnings"
+)
+
+type DocumentVersionsResponse struct {
+	Topic    string                     `json:"topic"`
+	Versions []services.DocumentVersion `json:"versions"`
+	Total    int                        `json:"total"`
+	Warnings []warnings.Warning         `json:"warnings,omitempty"`
+}
+
+type DocumentVersionsHandler struct {
+	service services.DocumentDetailsService
+}
+
+func NewDocumentVersionsHandler(service services.DocumentDetailsService) *DocumentVersionsHandler {
+	return &DocumentVersionsHandler{
+		service: service,
+	}
+}
+
+// Handle returns the version history of the topic in the topic query parameter, the file
+// name of its documents without the version suffix and extension
+func (h *DocumentVersionsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	topic := strings.TrimSpace(r.URL.Query().Get("topic"))
+	if topic == "" {
+		BadRequestHandler(w, "topic query parameter is required")
+		return
+	}
+
+	ctx, collected := warnings.WithCollector(r.Context())
+	versions, err := h.service.GetDocumentVersions(ctx, topic)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to retrieve document versions", map[string]interface{}{
+			"topic": topic,
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to retrieve document versions")
+		return
+	}
+	if len(versions) == 0 {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DocumentVersionsResponse{
+		Topic:    topic,
+		Versions: versions,
+		Total:    len(versions),
+		Warnings: collected.List(),
+	})
+}
@@ -0,0 +1,148 @@
+package routing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/eval"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+// runIdPattern matches the run IDs of evaluation reports, which name their S3 objects
+var runIdPattern = regexp.MustCompile(`^[0-9A-Za-z-]{1,64}$`)
+
+type EvalRunRequest struct {
+	CaseIds        []string `json:"caseIds,omitempty"`        // Run only these cases, all by default
+	UpdateBaseline bool     `json:"updateBaseline,omitempty"` // Compare later runs with this one
+}
+
+type EvalHandler struct {
+	service eval.Service
+	answer  eval.Answerer
+}
+
+func NewEvalHandler(service eval.Service, answer eval.Answerer) *EvalHandler {
+	return &EvalHandler{
+		service: service,
+		answer:  answer,
+	}
+}
+
+// HandleRun runs the golden dataset and returns the report. The body is optional, an empty
+// body runs every case without updating the baseline.
+func (h *EvalHandler) HandleRun(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithContext(r.Context())
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyReadError(w, err)
+		return
+	}
+	defer r.Body.Close()
+
+	var request EvalRunRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			BadRequestHandler(w, "Invalid JSON format")
+			return
+		}
+	}
+
+	report, err := h.service.Run(r.Context(), h.answer, eval.RunOptions{
+		CaseIds:        request.CaseIds,
+		UpdateBaseline: request.UpdateBaseline,
+	})
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+		BadRequestHandler(w, bedrockErr.Message)
+		return
+	}
+	if err != nil && report == nil {
+		log.Error("Evaluation run failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to run evaluation")
+		return
+	}
+	if err != nil {
+		// The scores are still worth returning when only storing them failed
+		log.Error("Failed to store evaluation report", map[string]interface{}{
+			"run_id": report.RunId,
+			"error":  err.Error(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// HandleReport returns the report of the runId query parameter, or the latest report
+func (h *EvalHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
+	runId := r.URL.Query().Get("runId")
+	if runId != "" && !runIdPattern.MatchString(runId) {
+		BadRequestHandler(w, "runId is not a valid run ID")
+		return
+	}
+
+	report, err := h.service.Report(r.Context(), runId)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to read evaluation report", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to read evaluation report")
+		return
+	}
+	if report == nil {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// EvalAnswerer answers golden questions through the router the way the worker replays
+// queued questions, so they take the full question-search pipeline. Each question gets a
+// fresh answer and its own session, so neither the answer cache nor the session limits
+// skew a run.
+func EvalAnswerer(router http.Handler) eval.Answerer {
+	run := QuestionJobRunner(router)
+	return func(ctx context.Context, question string) (*eval.Answer, error) {
+		body, err := json.Marshal(map[string]interface{}{"question": question, "skipClarification": true})
+		if err != nil {
+			return nil, err
+		}
+		session := make([]byte, 8)
+		if _, err := rand.Read(session); err != nil {
+			return nil, fmt.Errorf("failed to generate session ID: %w", err)
+		}
+
+		statusCode, response := run(ctx, &services.QueuedQuestion{
+			Query: "enableRelateDocument=true",
+			Headers: map[string]string{
+				"Content-Type":  "application/json",
+				"Cache-Control": "no-cache",
+				"X-Session-Id":  "eval-" + hex.EncodeToString(session),
+			},
+			Body: body,
+		})
+		if statusCode != http.StatusOK {
+			return nil, fmt.Errorf("question-search answered %d: %s", statusCode, response)
+		}
+
+		var answer QuestionSearchResponse
+		if err := json.Unmarshal(response, &answer); err != nil {
+			return nil, fmt.Errorf("invalid question-search response: %w", err)
+		}
+		return &eval.Answer{Answer: answer.Answer, RelatedDocuments: answer.RelatedDocuments}, nil
+	}
+}
@@ -0,0 +1,37 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEvalAnswerer(t *testing.T) {
+	var related bool
+	router := SetupRoutes(RouteServices{
+		QuestionSearch: &mockQuestionSearchService{
+			searchAnswerFunc: func(ctx context.Context, question string, enableRelateDocument bool) (string, error) {
+				related = enableRelateDocument
+				if question == "fail" {
+					return "", fmt.Errorf("knowledge base unavailable")
+				}
+				return "The fee is 100 baht", nil
+			},
+			relatedDocuments: []string{"https://docs.example/fees.pdf"},
+		},
+	}, allRoutesConfig())
+	answer := EvalAnswerer(router)
+
+	answered, err := answer(context.Background(), "What is the transfer fee?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if answered.Answer != "The fee is 100 baht" || len(answered.RelatedDocuments) != 1 || !related {
+		t.Errorf("unexpected answer %+v, related documents requested: %t", answered, related)
+	}
+
+	if _, err := answer(context.Background(), "fail"); err == nil || !strings.Contains(err.Error(), "answered 500") {
+		t.Errorf("expected the failed status in the error, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+type ExperimentHandler struct {
+	service services.ExperimentService
+}
+
+func NewExperimentHandler(service services.ExperimentService) *ExperimentHandler {
+	return &ExperimentHandler{
+		service: service,
+	}
+}
+
+// Handle returns the quality metrics of each experiment variant. Optional query parameters:
+// from and to (YYYY-MM-DD in UTC, inclusive, the last 30 days by default).
+func (h *ExperimentHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, ok := optionalDateParam(r, "from", today.AddDate(0, 0, -29))
+	if !ok {
+		BadRequestHandler(w, "from must be a date in YYYY-MM-DD format")
+		return
+	}
+	to, ok := optionalDateParam(r, "to", today)
+	if !ok {
+		BadRequestHandler(w, "to must be a date in YYYY-MM-DD format")
+		return
+	}
+	if to.Before(from) {
+		BadRequestHandler(w, "to must not be before from")
+		return
+	}
+	if to.Sub(from) >= services.MaxExperimentReportDays*24*time.Hour {
+		BadRequestHandler(w, fmt.Sprintf("the report may cover at most %d days", services.MaxExperimentReportDays))
+		return
+	}
+
+	report, err := h.service.Report(r.Context(), from, to)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to build experiment report", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to build experiment report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/config"
+	"teletubpax-api/experiments"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+// variantQuestionSearchService answers with the name of the request's experiment variant
+type variantQuestionSearchService struct{}
+
+func (s *variantQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	return "answered by " + experiments.FromContext(ctx).Key(), nil, nil
+}
+
+func TestExperiments_AssignsSessionsAndReports(t *testing.T) {
+	cfg := &config.Config{MaxQuestionLength: 1000, AdminToken: "secret", ExperimentRetentionDays: 90}
+	experiment, err := experiments.Parse(`{"name": "synthesis-v2", "variants": [{"name": "control", "percent": 50}, {"name": "new-synthesis", "percent": 50}]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	experimentService := services.NewStoreExperimentService(experiment, storage.NewMemoryExperimentStore(), cfg)
+	router := SetupRoutes(RouteServices{
+		QuestionSearch: services.NewExperimentQuestionSearchService(&variantQuestionSearchService{}, experimentService),
+		Experiments:    experimentService,
+	}, cfg)
+
+	answers := map[string]string{}
+	for _, sessionId := range []string{"session-1", "session-2", "session-1"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewBufferString(`{"question": "ค่าธรรมเนียมบัตรเดบิต"}`))
+		req.Header.Set("X-Session-Id", sessionId)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response QuestionSearchResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if previous, ok := answers[sessionId]; ok && previous != response.Answer {
+			t.Errorf("expected session %s to keep its variant, got %q then %q", sessionId, previous, response.Answer)
+		}
+		answers[sessionId] = response.Answer
+	}
+	if answers["session-1"] != "answered by synthesis-v2/"+experiment.Assign("id:session-1").Name {
+		t.Errorf("expected the answer of the session's variant, got %q", answers["session-1"])
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/teletubpax/admin/experiments", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the admin token to be required, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/teletubpax/admin/experiments", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report services.ExperimentReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if len(report.Experiments) != 1 || len(report.Experiments[0].Variants) != 2 {
+		t.Fatalf("expected the two variants of the running experiment, got %+v", report)
+	}
+	variants := report.Experiments[0].Variants
+	if variants[0].Answers+variants[1].Answers != 3 {
+		t.Errorf("expected the three answers counted, got %+v", variants)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/teletubpax/admin/experiments?from=2026-02-01&to=2026-01-31", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for days in reverse, got %d", w.Code)
+	}
+}
@@ -0,0 +1,37 @@
+package routing
+
+import (
+	"net/http"
+
+	"teletubpax-api/faults"
+	"teletubpax-api/logger"
+
+	"github.com/gorilla/mux"
+)
+
+const FaultInjectionHeader = "X-Fault-Injection"
+
+// FaultInjectionMiddleware attaches the faults requested with the X-Fault-Injection header
+// to the request context, for the AWS calls made while serving it. It is only registered
+// when FAULT_INJECTION_ENABLED is set.
+func FaultInjectionMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value := r.Header.Get(FaultInjectionHeader)
+			if value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			requested, err := faults.ParseHeader(value)
+			if err != nil {
+				BadRequestHandler(w, "Invalid "+FaultInjectionHeader+" header: "+err.Error())
+				return
+			}
+			logger.WithContext(r.Context()).Warn("Fault injection requested", map[string]interface{}{
+				"faults": value,
+			})
+			next.ServeHTTP(w, r.WithContext(faults.WithFaults(r.Context(), requested)))
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"teletubpax-api/flags"
+	"teletubpax-api/logger"
+)
+
+type FeatureFlagsResponse struct {
+	Flags map[string]bool `json:"flags"`
+}
+
+type FeatureFlagsHandler struct {
+	flags *flags.Flags
+}
+
+func NewFeatureFlagsHandler(featureFlags *flags.Flags) *FeatureFlagsHandler {
+	return &FeatureFlagsHandler{
+		flags: featureFlags,
+	}
+}
+
+func (h *FeatureFlagsHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	h.writeFlags(w)
+}
+
+// HandleReload reloads the flags immediately instead of waiting for the refresh interval
+func (h *FeatureFlagsHandler) HandleReload(w http.ResponseWriter, r *http.Request) {
+	if err := h.flags.Reload(r.Context()); err != nil {
+		logger.WithContext(r.Context()).Error("Failed to reload feature flags", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to reload feature flags")
+		return
+	}
+
+	h.writeFlags(w)
+}
+
+func (h *FeatureFlagsHandler) writeFlags(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(FeatureFlagsResponse{Flags: h.flags.Snapshot()})
+}
@@ -0,0 +1,67 @@
+package routing
+
+import (
+	"encoding/json"
+	stdErrors "errors"
+	"net/http"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+// maxFeedbackCommentLength bounds the free-text comment of feedback, in bytes
+const maxFeedbackCommentLength = 2000
+
+type FeedbackRequest struct {
+	Question string `json:"question"` // The question the answer was given to
+	AnswerId string `json:"answerId"` // answerId of the question-search response
+	Rating   string `json:"rating"`   // "up" or "down"
+	Comment  string `json:"comment,omitempty"`
+}
+
+type FeedbackHandler struct {
+	service           services.FeedbackService
+	maxQuestionLength int
+}
+
+func NewFeedbackHandler(service services.FeedbackService, maxQuestionLength int) *FeedbackHandler {
+	return &FeedbackHandler{
+		service:           service,
+		maxQuestionLength: maxQuestionLength,
+	}
+}
+
+// Handle saves a thumbs up or down, with an optional comment, on an answer. Sending feedback
+// on the same answer again replaces it.
+func (h *FeedbackHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	request, ok := DecodeJSONRequest(w, r, func(request *FeedbackRequest) []Rule {
+		return []Rule{
+			Required("question", request.Question),
+			MaxLength("question", request.Question, h.maxQuestionLength),
+			Required("answerId", request.AnswerId),
+			Required("rating", request.Rating),
+			OneOf("rating", request.Rating, services.FeedbackUp, services.FeedbackDown),
+			MaxLength("comment", request.Comment, maxFeedbackCommentLength),
+		}
+	})
+	if !ok {
+		return
+	}
+
+	err := h.service.Submit(r.Context(), request.AnswerId, request.Question, request.Rating, request.Comment)
+	if stdErrors.Is(err, services.ErrAnswerNotFound) {
+		NotFoundHandler(w, r)
+		return
+	}
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to save feedback", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to save feedback")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{Message: "Feedback saved", Status: 200})
+}
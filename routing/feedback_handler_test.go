@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/config"
+	"teletubpax-api/services"
+)
+
+type mockFeedbackService struct {
+	answers  map[string]string // Question by answer ID
+	feedback map[string]string // Rating by answer ID
+}
+
+func newMockFeedbackService() *mockFeedbackService {
+	return &mockFeedbackService{answers: map[string]string{}, feedback: map[string]string{}}
+}
+
+func (m *mockFeedbackService) RecordAnswer(ctx context.Context, question string, answer string, relatedDocuments []string) (string, error) {
+	answerId := "answer-1"
+	m.answers[answerId] = question
+	return answerId, nil
+}
+
+func (m *mockFeedbackService) Submit(ctx context.Context, answerId string, question string, rating string, comment string) error {
+	if m.answers[answerId] != question {
+		return services.ErrAnswerNotFound
+	}
+	m.feedback[answerId] = rating
+	return nil
+}
+
+func TestFeedbackOnAnswer(t *testing.T) {
+	feedback := newMockFeedbackService()
+	router := SetupRoutes(RouteServices{
+		QuestionSearch: services.NewFeedbackQuestionSearchService(&mockQuestionSearchService{}, feedback),
+		Feedback:       feedback,
+	}, &config.Config{MaxQuestionLength: 1000})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewBufferString(`{"question": "ค่าธรรมเนียมบัตรเดบิต"}`))
+	router.ServeHTTP(w, req)
+	var answer QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &answer)
+	if w.Code != http.StatusOK || answer.AnswerId != "answer-1" {
+		t.Fatalf("expected an answer with an answer ID, got %d %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "thumbs down with a comment", body: `{"question": "ค่าธรรมเนียมบัตรเดบิต", "answerId": "answer-1", "rating": "down", "comment": "fee is outdated"}`, expectedStatus: http.StatusOK},
+		{name: "unknown answer", body: `{"question": "ค่าธรรมเนียมบัตรเดบิต", "answerId": "answer-2", "rating": "up"}`, expectedStatus: http.StatusNotFound},
+		{name: "other question", body: `{"question": "อัตราดอกเบี้ย", "answerId": "answer-1", "rating": "up"}`, expectedStatus: http.StatusNotFound},
+		{name: "missing rating", body: `{"question": "ค่าธรรมเนียมบัตรเดบิต", "answerId": "answer-1"}`, expectedStatus: http.StatusBadRequest},
+		{name: "invalid rating", body: `{"question": "ค่าธรรมเนียมบัตรเดบิต", "answerId": "answer-1", "rating": "5"}`, expectedStatus: http.StatusBadRequest},
+		{name: "missing answer ID", body: `{"question": "ค่าธรรมเนียมบัตรเดบิต", "rating": "up"}`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/api/teletubpax/feedback", bytes.NewBufferString(tt.body)))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+	if feedback.feedback["answer-1"] != services.FeedbackDown {
+		t.Errorf("expected the thumbs down to be saved, got %q", feedback.feedback["answer-1"])
+	}
+}
+
+func TestFeedbackRouteNeedsFeedbackService(t *testing.T) {
+	router := SetupRoutes(RouteServices{}, &config.Config{MaxQuestionLength: 1000})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/teletubpax/feedback", bytes.NewBufferString(`{}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a feedback service, got %d", w.Code)
+	}
+}
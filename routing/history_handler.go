@@ -0,0 +1,135 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+type ConversationTurnResponse struct {
+	TurnId    string          `json:"turnId"`
+	Question  string          `json:"question"`
+	Answer    string          `json:"answer"`
+	Citations json.RawMessage `json:"citations"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type HistoryResponse struct {
+	Turns      []ConversationTurnResponse `json:"turns"`                // Newest first
+	NextBefore string                     `json:"nextBefore,omitempty"` // Send as before for the older turns, set when the page is full
+}
+
+type HistoryDeleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+type HistoryHandler struct {
+	history services.HistoryService
+}
+
+func NewHistoryHandler(history services.HistoryService) *HistoryHandler {
+	return &HistoryHandler{
+		history: history,
+	}
+}
+
+// HandleList returns the caller's turns, newest first. Optional query parameters: limit
+// (turns per page) and before (the nextBefore of the previous page).
+func (h *HistoryHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	userId := auth.UserIdFromContext(r.Context())
+	if userId == "" {
+		UnauthorizedHandler(w, "Conversation history requires a signed-in user")
+		return
+	}
+	limit, ok := optionalIntParam(r, "limit")
+	if !ok || limit < 0 || limit > maxHistoryLimit {
+		BadRequestHandler(w, "limit must be a number between 1 and 100")
+		return
+	}
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	}
+
+	turns, err := h.history.List(r.Context(), userId, r.URL.Query().Get("before"), limit)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to read conversation history", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to read the conversation history")
+		return
+	}
+
+	response := HistoryResponse{Turns: make([]ConversationTurnResponse, 0, len(turns))}
+	for _, turn := range turns {
+		response.Turns = append(response.Turns, newConversationTurnResponse(turn))
+	}
+	if len(turns) == limit {
+		response.NextBefore = turns[len(turns)-1].TurnId
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleDelete deletes the caller's whole history
+func (h *HistoryHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	userId := auth.UserIdFromContext(r.Context())
+	if userId == "" {
+		UnauthorizedHandler(w, "Conversation history requires a signed-in user")
+		return
+	}
+	h.delete(w, r, userId)
+}
+
+// HandleAdminDelete deletes the history of the user in the userId query parameter, for
+// PDPA erasure requests received by support
+func (h *HistoryHandler) HandleAdminDelete(w http.ResponseWriter, r *http.Request) {
+	userId := strings.TrimSpace(r.URL.Query().Get("userId"))
+	if userId == "" {
+		BadRequestHandler(w, "userId is required")
+		return
+	}
+	h.delete(w, r, userId)
+}
+
+func (h *HistoryHandler) delete(w http.ResponseWriter, r *http.Request, userId string) {
+	deleted, err := h.history.Delete(r.Context(), userId)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to delete conversation history", map[string]interface{}{
+			"error":   err.Error(),
+			"deleted": deleted,
+		})
+		InternalServerErrorHandler(w, "Failed to delete the conversation history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(HistoryDeleteResponse{Deleted: deleted})
+}
+
+func newConversationTurnResponse(turn storage.ConversationTurn) ConversationTurnResponse {
+	response := ConversationTurnResponse{
+		TurnId:    turn.TurnId,
+		Question:  turn.Question,
+		Answer:    turn.Answer,
+		Citations: json.RawMessage("[]"),
+		CreatedAt: turn.CreatedAt,
+	}
+	if json.Valid(turn.Citations) {
+		response.Citations = turn.Citations
+	}
+	return response
+}
@@ -0,0 +1,83 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/config"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+func TestHistoryEndpoints(t *testing.T) {
+	store := storage.NewMemoryConversationHistoryStore()
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	for _, turnId := range []string{"1", "2", "3"} {
+		store.AddTurn(context.Background(), &storage.ConversationTurn{UserId: "user-1", TurnId: turnId, Question: "question " + turnId, ExpiresAt: expiresAt})
+	}
+	store.AddTurn(context.Background(), &storage.ConversationTurn{UserId: "user-2", TurnId: "1", ExpiresAt: expiresAt})
+	router := SetupRoutes(RouteServices{
+		QuestionSearch: &mockQuestionSearchService{},
+		History:        services.NewStoreHistoryService(store, &config.Config{HistoryRetentionDays: 90}),
+	}, &config.Config{AdminToken: "secret"})
+
+	signedIn := func(method string, target string) *http.Request {
+		req := httptest.NewRequest(method, target, nil)
+		return req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{UserId: "user-1"}))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/teletubpax/history", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an anonymous caller, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signedIn("GET", "/api/teletubpax/history?limit=2"))
+	var page HistoryResponse
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if w.Code != http.StatusOK || len(page.Turns) != 2 || page.Turns[0].TurnId != "3" || page.NextBefore != "2" || string(page.Turns[0].Citations) != "[]" {
+		t.Fatalf("expected the newest two turns with a next page, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signedIn("GET", "/api/teletubpax/history?limit=2&before=2"))
+	page = HistoryResponse{}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Turns) != 1 || page.Turns[0].TurnId != "1" || page.NextBefore != "" {
+		t.Errorf("expected the last turn without a next page, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signedIn("GET", "/api/teletubpax/history?limit=500"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a limit over 100, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signedIn("DELETE", "/api/teletubpax/history"))
+	if w.Code != http.StatusOK || w.Body.String() != "{\"deleted\":3}\n" {
+		t.Errorf("expected the caller's 3 turns deleted, got %d %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/teletubpax/admin/history?userId=user-2", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "{\"deleted\":1}\n" {
+		t.Errorf("expected the user's turn deleted by an admin, got %d %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/teletubpax/admin/history", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a userId, got %d", w.Code)
+	}
+}
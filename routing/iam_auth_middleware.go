@@ -0,0 +1,46 @@
+package routing
+
+import (
+	"net/http"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// IamAuthMiddleware authenticates service-to-service callers by IAM principal: requests with
+// an X-Iam-Authorization header carry a SigV4 signed sts:GetCallerIdentity request, which
+// STS verifies. Callers whose signature is invalid or whose principal is not allowed answer
+// 401; the principal is attached to the request context as the caller's identity and
+// satisfies API_KEY_REQUIRED and AUTH_REQUIRED. Requests without the header pass through.
+func IamAuthMiddleware(verifier *auth.IamVerifier) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isAuthExempt(r) || isReplayedQuestion(r.Context()) || isChatQuestion(r.Context()) || r.Header.Get(auth.IamAuthorizationHeader) == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			log := logger.WithContext(r.Context())
+			identity, err := verifier.Verify(r.Context(), r.Header)
+			if err != nil {
+				log.Warn("Rejected IAM signature", map[string]interface{}{
+					"error":       err.Error(),
+					"path":        r.URL.Path,
+					"remote_addr": r.RemoteAddr,
+				})
+				UnauthorizedHandler(w, "Invalid IAM signature or principal not allowed")
+				return
+			}
+
+			log.Info("Authenticated request", map[string]interface{}{
+				"user_id":  identity.UserId,
+				"username": identity.Username,
+				"method":   r.Method,
+				"path":     r.URL.Path,
+			})
+			next.ServeHTTP(w, withIdentity(r, identity))
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/config"
+)
+
+func TestIamAuthMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	verifier := auth.NewIamVerifier(&config.Config{AWSRegion: "us-east-1", IamAuthServerId: "teletubpax-api", IamAuthAllowedPrincipals: []string{"arn:aws:iam::123456789012:role/reporting-task"}})
+
+	// Signatures without the server ID are rejected before they are sent to STS
+	unsigned := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20250601/us-east-1/sts/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=abc"
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		expectedCode  int
+	}{
+		{name: "no signature", path: "/api/teletubpax/question-search", expectedCode: http.StatusOK},
+		{name: "signature without the server ID", path: "/api/teletubpax/question-search", authorization: unsigned, expectedCode: http.StatusUnauthorized},
+		{name: "admin endpoint", path: "/api/teletubpax/admin/api-keys", authorization: unsigned, expectedCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set(auth.IamAuthorizationHeader, tt.authorization)
+				req.Header.Set(auth.IamDateHeader, "20250601T000000Z")
+			}
+			w := httptest.NewRecorder()
+			IamAuthMiddleware(verifier)(next).ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("expected status %d, got %d", tt.expectedCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestRequiredCredentials_AcceptAnIamIdentity(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	authenticator := auth.NewAuthenticator(&config.Config{AuthJwksUrl: "http://127.0.0.1:0/jwks.json", AuthRequired: true})
+	handler := ApiKeyMiddleware(stubApiKeyService{}, true)(AuthMiddleware(authenticator)(next))
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", nil)
+	req = req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{UserId: "arn:aws:iam::123456789012:role/reporting-task"}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the IAM identity to satisfy API_KEY_REQUIRED and AUTH_REQUIRED, got %d", w.Code)
+	}
+}
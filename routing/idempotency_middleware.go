@@ -0,0 +1,175 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	IdempotencyKeyHeader    = "Idempotency-Key"
+	IdempotentReplayHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyLockTTL bounds how long a request in progress holds its key, so the key of
+	// an instance that stopped mid-request frees up again
+	idempotencyLockTTL = 5 * time.Minute
+)
+
+// idempotentPaths are the POST endpoints whose responses are replayed to retries, each
+// request costs model calls
+var idempotentPaths = map[string]bool{
+	"/api/teletubpax/question-search":       true,
+	"/api/teletubpax/question-search/async": true,
+	"/api/teletubpax/summary-document":      true,
+	"/api/teletubpax/summary-document/jobs": true,
+}
+
+// recordingResponseWriter passes the response through and keeps a copy of its body
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// IdempotencyMiddleware replays the stored response to retries of question-search, queued
+// question-search and summary-document POSTs with the same Idempotency-Key header, so a client retrying on a
+// flaky network does not pay for the model calls twice. Keys are scoped to the caller's
+// identity; successful responses are kept for ttl, while errors are not stored so
+// the retry runs again. A retry while the first request is in progress answers 409, and a
+// key reused with a different body 422. Store failures serve the request without the key.
+func IdempotencyMiddleware(store storage.IdempotencyStore, ttl time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+			if idempotencyKey == "" || r.Method != http.MethodPost || !idempotentPaths[apiPath(r.URL.Path)] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(idempotencyKey) > maxIdempotencyKeyLength {
+				BadRequestHandler(w, "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeBodyReadError(w, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := sha256.Sum256(body)
+
+			log := logger.WithContext(r.Context())
+			record := &storage.IdempotencyRecord{
+				Key:         idempotencyStoreKey(r, idempotencyKey),
+				Fingerprint: hex.EncodeToString(fingerprint[:]),
+				ExpiresAt:   time.Now().Add(idempotencyLockTTL).Unix(),
+			}
+			existing, err := store.Reserve(r.Context(), record)
+			if err != nil {
+				log.Warn("Failed to reserve idempotency key, serving the request without it", map[string]interface{}{
+					"error": err.Error(),
+					"path":  r.URL.Path,
+				})
+				next.ServeHTTP(w, r)
+				return
+			}
+			if existing != nil {
+				replayIdempotentResponse(w, existing, record.Fingerprint)
+				return
+			}
+
+			recorder := &recordingResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			// The request may have been cancelled by the client, the record must still be written
+			ctx := context.WithoutCancel(r.Context())
+			if recorder.status < 200 || recorder.status >= 300 {
+				if err := store.Release(ctx, record.Key); err != nil {
+					log.Warn("Failed to release idempotency key", map[string]interface{}{
+						"error": err.Error(),
+					})
+				}
+				return
+			}
+			record.StatusCode = recorder.status
+			record.ContentType = recorder.Header().Get("Content-Type")
+			record.Body = recorder.body.Bytes()
+			record.ExpiresAt = time.Now().Add(ttl).Unix()
+			if err := store.Complete(ctx, record); err != nil {
+				log.Warn("Failed to store idempotent response", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+		})
+	}
+}
+
+// replayIdempotentResponse answers a request whose key was seen before
+func replayIdempotentResponse(w http.ResponseWriter, record *storage.IdempotencyRecord, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		UnprocessableEntityHandler(w, "Idempotency-Key was already used with a different request body")
+		return
+	}
+	if record.InProgress() {
+		w.Header().Set("Retry-After", "1")
+		ConflictHandler(w, "A request with this Idempotency-Key is still in progress")
+		return
+	}
+
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set(IdempotentReplayHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	w.Write(record.Body)
+}
+
+// idempotencyStoreKey scopes the key to the endpoint and the caller. The /v1 path and its
+// unversioned alias are the same endpoint.
+func idempotencyStoreKey(r *http.Request, idempotencyKey string) string {
+	return callerScope(r, apiPath(r.URL.Path), idempotencyKey)
+}
+
+// callerScope hashes the parts with the caller: the tenant, the API key and the user of a
+// verified token, or the X-Session-Id of anonymous callers. The client IP is left out on
+// purpose, it changes when a phone switches networks between requests.
+func callerScope(r *http.Request, parts ...string) string {
+	caller := auth.UserIdFromContext(r.Context())
+	if caller == "" {
+		caller = "session:" + r.Header.Get("X-Session-Id")
+	}
+	scope := strings.Join(append([]string{
+		r.Header.Get("X-Tenant-Id"),
+		r.Header.Get("X-Api-Key"),
+		caller,
+	}, parts...), "\n")
+	hash := sha256.Sum256([]byte(scope))
+	return hex.EncodeToString(hash[:])
+}
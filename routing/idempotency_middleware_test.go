@@ -0,0 +1,149 @@
+package routing
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/storage"
+)
+
+func TestIdempotencyMiddleware_ReplaysSuccessfulResponses(t *testing.T) {
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"answer":"` + string(body) + `"}`))
+	})
+	handler := IdempotencyMiddleware(storage.NewMemoryIdempotencyStore(), time.Hour)(next)
+
+	send := func(key string, session string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		req.Header.Set("X-Session-Id", session)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("key-1", "widget-1", "fee")
+	retry := send("key-1", "widget-1", "fee")
+	if calls != 1 {
+		t.Fatalf("expected the retry to be replayed, got %d calls", calls)
+	}
+	if retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() || retry.Header().Get(IdempotentReplayHeader) != "true" {
+		t.Errorf("expected the first response, got %d %q", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected the content type to be replayed, got %q", retry.Header().Get("Content-Type"))
+	}
+
+	if w := send("key-1", "widget-1", "rates"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a reused key with another body to answer 422, got %d", w.Code)
+	}
+	if send("key-1", "widget-2", "fee"); calls != 2 {
+		t.Errorf("expected keys to be scoped to the caller, got %d calls", calls)
+	}
+	if send("key-2", "widget-1", "fee"); calls != 3 {
+		t.Errorf("expected a new key to run the request, got %d calls", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_ReplaysRetriesToTheLegacyPath(t *testing.T) {
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := IdempotencyMiddleware(storage.NewMemoryIdempotencyStore(), time.Hour)(next)
+
+	for _, path := range []string{"/api/teletubpax/v1/question-search", "/api/teletubpax/question-search"} {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 1 {
+		t.Errorf("expected the retry to the unversioned path to be replayed, got %d calls", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_RunsFailedRequestsAgain(t *testing.T) {
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			ServiceUnavailableHandler(w, "Model is busy")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := IdempotencyMiddleware(storage.NewMemoryIdempotencyStore(), time.Hour)(next)
+
+	for _, expectedCode := range []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusOK} {
+		req := httptest.NewRequest("POST", "/api/teletubpax/summary-document", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != expectedCode {
+			t.Errorf("expected status %d, got %d", expectedCode, w.Code)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected the failed request to run again and the success to be replayed, got %d calls", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_RejectsConcurrentRetries(t *testing.T) {
+	store := storage.NewMemoryIdempotencyStore()
+	var retry *httptest.ResponseRecorder
+	var handler http.Handler
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retry == nil {
+			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{}`))
+			req.Header.Set(IdempotencyKeyHeader, "key-1")
+			retry = httptest.NewRecorder()
+			handler.ServeHTTP(retry, req)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handler = IdempotencyMiddleware(store, time.Hour)(next)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if retry.Code != http.StatusConflict || retry.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected a retry during the request to answer 409, got %d", retry.Code)
+	}
+}
+
+func TestIdempotencyMiddleware_IgnoresOtherRequests(t *testing.T) {
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := IdempotencyMiddleware(storage.NewMemoryIdempotencyStore(), time.Hour)(next)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/api/teletubpax/related-questions", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 2 {
+		t.Errorf("expected endpoints without idempotency to run every time, got %d calls", calls)
+	}
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyKeyHeader, strings.Repeat("k", maxIdempotencyKeyLength+1))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a key that is too long to answer 400, got %d", w.Code)
+	}
+}
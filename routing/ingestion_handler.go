@@ -0,0 +1,131 @@
+package routing
+
+import (
+	"encoding/json"
+	stdErrors "errors"
+	"net/http"
+	"strings"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+type IngestionJobRequest struct {
+	KnowledgeBaseId string `json:"knowledgeBaseId"`
+	DataSourceId    string `json:"dataSourceId"`
+}
+
+type DataSourcesResponse struct {
+	DataSources []services.DataSourceStatus `json:"dataSources"`
+}
+
+type IngestionJobResponse struct {
+	Job   *aws.IngestionJob `json:"job"`
+	JobId string            `json:"jobId,omitempty"` // Of a started sync, polled at /jobs/{id} when JOBS_TABLE is set
+}
+
+type IngestionHandler struct {
+	service services.IngestionService
+}
+
+func NewIngestionHandler(service services.IngestionService) *IngestionHandler {
+	return &IngestionHandler{
+		service: service,
+	}
+}
+
+// HandleListDataSources returns the data sources of every configured knowledge base, each
+// with its latest ingestion job
+func (h *IngestionHandler) HandleListDataSources(w http.ResponseWriter, r *http.Request) {
+	dataSources, err := h.service.ListDataSources(r.Context())
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to list data sources", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to list data sources")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DataSourcesResponse{DataSources: dataSources})
+}
+
+// HandleStart starts an ingestion job on a data source. The job runs in Bedrock, callers
+// poll HandleStatus until it is COMPLETE or FAILED, or the returned job at /jobs/{id}.
+func (h *IngestionHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
+	request, ok := DecodeJSONRequest(w, r, func(request *IngestionJobRequest) []Rule {
+		return []Rule{
+			Required("knowledgeBaseId", request.KnowledgeBaseId),
+			Required("dataSourceId", request.DataSourceId),
+		}
+	})
+	if !ok {
+		return
+	}
+
+	job, err := h.service.StartSync(r.Context(), request.KnowledgeBaseId, request.DataSourceId)
+	switch {
+	case stdErrors.Is(err, services.ErrDataSourceNotFound):
+		NotFoundHandler(w, r)
+		return
+	case stdErrors.Is(err, aws.ErrIngestionJobRunning):
+		ConflictHandler(w, err.Error())
+		return
+	case err != nil:
+		logger.WithContext(r.Context()).Error("Failed to start ingestion job", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to start ingestion job")
+		return
+	}
+
+	response := IngestionJobResponse{Job: job}
+	tracked, err := h.service.TrackSync(r.Context(), jobOwner(r), job)
+	if err != nil {
+		// The sync has started, it can still be polled from Bedrock
+		logger.WithContext(r.Context()).Warn("Failed to track ingestion job", map[string]interface{}{
+			"ingestion_job_id": job.IngestionJobId,
+			"error":            err.Error(),
+		})
+	} else if tracked != nil {
+		response.JobId = tracked.JobId
+		w.Header().Set("Location", apiV1PathPrefix+"jobs/"+tracked.JobId)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleStatus returns an ingestion job, named by the knowledgeBaseId, dataSourceId and
+// ingestionJobId query parameters
+func (h *IngestionHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ids := map[string]string{}
+	for _, name := range []string{"knowledgeBaseId", "dataSourceId", "ingestionJobId"} {
+		ids[name] = strings.TrimSpace(query.Get(name))
+		if ids[name] == "" {
+			BadRequestHandler(w, name+" query parameter is required")
+			return
+		}
+	}
+
+	job, err := h.service.GetJob(r.Context(), ids["knowledgeBaseId"], ids["dataSourceId"], ids["ingestionJobId"])
+	if stdErrors.Is(err, services.ErrIngestionJobNotFound) {
+		NotFoundHandler(w, r)
+		return
+	}
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to get ingestion job", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to get ingestion job")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(IngestionJobResponse{Job: job})
+}
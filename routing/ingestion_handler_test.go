@@ -0,0 +1,93 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+type mockIngestionService struct{}
+
+func (m *mockIngestionService) ListDataSources(ctx context.Context) ([]services.DataSourceStatus, error) {
+	return []services.DataSourceStatus{{DataSource: aws.DataSource{KnowledgeBaseId: "kb-1", DataSourceId: "ds-1", Status: "AVAILABLE"}}}, nil
+}
+
+func (m *mockIngestionService) StartSync(ctx context.Context, knowledgeBaseId string, dataSourceId string) (*aws.IngestionJob, error) {
+	switch dataSourceId {
+	case "ds-1":
+		return &aws.IngestionJob{KnowledgeBaseId: knowledgeBaseId, DataSourceId: dataSourceId, IngestionJobId: "job-1", Status: "STARTING"}, nil
+	case "ds-busy":
+		return nil, aws.ErrIngestionJobRunning
+	}
+	return nil, services.ErrDataSourceNotFound
+}
+
+func (m *mockIngestionService) GetJob(ctx context.Context, knowledgeBaseId string, dataSourceId string, ingestionJobId string) (*aws.IngestionJob, error) {
+	if ingestionJobId != "job-1" {
+		return nil, services.ErrIngestionJobNotFound
+	}
+	return &aws.IngestionJob{KnowledgeBaseId: knowledgeBaseId, DataSourceId: dataSourceId, IngestionJobId: ingestionJobId, Status: "COMPLETE"}, nil
+}
+
+func (m *mockIngestionService) TrackSync(ctx context.Context, owner string, ingestionJob *aws.IngestionJob) (*storage.Job, error) {
+	return nil, nil
+}
+
+func TestIngestionEndpoints(t *testing.T) {
+	router := SetupRoutes(RouteServices{Ingestion: &mockIngestionService{}}, &config.Config{AdminToken: "secret"})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "list data sources", method: "GET", path: "/api/teletubpax/admin/knowledge-bases/data-sources", expectedStatus: http.StatusOK},
+		{name: "start sync", method: "POST", path: "/api/teletubpax/admin/knowledge-bases/ingestion-jobs", body: `{"knowledgeBaseId": "kb-1", "dataSourceId": "ds-1"}`, expectedStatus: http.StatusAccepted},
+		{name: "sync already running", method: "POST", path: "/api/teletubpax/admin/knowledge-bases/ingestion-jobs", body: `{"knowledgeBaseId": "kb-1", "dataSourceId": "ds-busy"}`, expectedStatus: http.StatusConflict},
+		{name: "unknown data source", method: "POST", path: "/api/teletubpax/admin/knowledge-bases/ingestion-jobs", body: `{"knowledgeBaseId": "kb-1", "dataSourceId": "ds-9"}`, expectedStatus: http.StatusNotFound},
+		{name: "missing data source", method: "POST", path: "/api/teletubpax/admin/knowledge-bases/ingestion-jobs", body: `{"knowledgeBaseId": "kb-1"}`, expectedStatus: http.StatusBadRequest},
+		{name: "job status", method: "GET", path: "/api/teletubpax/admin/knowledge-bases/ingestion-jobs?knowledgeBaseId=kb-1&dataSourceId=ds-1&ingestionJobId=job-1", expectedStatus: http.StatusOK},
+		{name: "unknown job", method: "GET", path: "/api/teletubpax/admin/knowledge-bases/ingestion-jobs?knowledgeBaseId=kb-1&dataSourceId=ds-1&ingestionJobId=job-9", expectedStatus: http.StatusNotFound},
+		{name: "missing job ID", method: "GET", path: "/api/teletubpax/admin/knowledge-bases/ingestion-jobs?knowledgeBaseId=kb-1&dataSourceId=ds-1", expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("X-Admin-Token", "secret")
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/teletubpax/admin/knowledge-bases/data-sources", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	router.ServeHTTP(w, req)
+	var response DataSourcesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || len(response.DataSources) != 1 || response.DataSources[0].DataSourceId != "ds-1" {
+		t.Errorf("expected ds-1 in the data sources, got %s", w.Body.String())
+	}
+}
+
+func TestIngestionEndpointsNeedAdminToken(t *testing.T) {
+	router := SetupRoutes(RouteServices{Ingestion: &mockIngestionService{}}, &config.Config{AdminToken: "secret"})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/teletubpax/admin/knowledge-bases/ingestion-jobs", bytes.NewBufferString(`{"knowledgeBaseId": "kb-1", "dataSourceId": "ds-1"}`)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the admin token, got %d", w.Code)
+	}
+}
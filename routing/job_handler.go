@@ -0,0 +1,89 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// jobRetryAfter is the Retry-After of unfinished jobs, by type, in seconds
+var jobRetryAfter = map[string]string{
+	storage.JobTypeQuestion:  "2",
+	storage.JobTypeSummary:   "10",
+	storage.JobTypeIngestion: "30",
+}
+
+type JobResponse struct {
+	JobId      string          `json:"jobId"`
+	Type       string          `json:"type"`   // "question", "summary" or "ingestion"
+	Status     string          `json:"status"` // "queued", "running", "succeeded", "failed" or "partial"
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+	StatusCode int             `json:"statusCode,omitempty"` // Of a question job, the status of its question-search response once finished
+	Result     json.RawMessage `json:"result,omitempty"`     // The question-search response, the summaries or the Bedrock ingestion job
+	Error      string          `json:"error,omitempty"`
+
+	CallbackSecret string `json:"callbackSecret,omitempty"` // Signs the callback, only returned on submission
+}
+
+type JobHandler struct {
+	jobs services.JobService
+}
+
+func NewJobHandler(jobs services.JobService) *JobHandler {
+	return &JobHandler{
+		jobs: jobs,
+	}
+}
+
+// HandleGet answers a job of any type and, once known, its result. Jobs of other callers
+// answer 404 like unknown ones.
+func (h *JobHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(r.Context(), mux.Vars(r)["id"], jobOwner(r))
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to read job", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to read the job")
+		return
+	}
+	if job == nil {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !job.Finished() {
+		w.Header().Set("Retry-After", jobRetryAfter[job.Type])
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newJobResponse(job))
+}
+
+func newJobResponse(job *storage.Job) JobResponse {
+	response := JobResponse{
+		JobId:      job.JobId,
+		Type:       job.Type,
+		Status:     job.Status,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+		StatusCode: job.StatusCode,
+		Error:      job.Error,
+	}
+	if json.Valid(job.Result) {
+		response.Result = bytes.TrimSpace(job.Result)
+	}
+	return response
+}
+
+// jobOwner scopes jobs of every type to the caller who started them
+func jobOwner(r *http.Request) string {
+	return callerScope(r, "job")
+}
@@ -0,0 +1,61 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+type KnowledgeGapHandler struct {
+	service services.KnowledgeGapService
+}
+
+func NewKnowledgeGapHandler(service services.KnowledgeGapService) *KnowledgeGapHandler {
+	return &KnowledgeGapHandler{
+		service: service,
+	}
+}
+
+// Handle returns unanswered questions clustered into knowledge gaps. Optional query
+// parameters: days (report window) and limit (number of gaps).
+func (h *KnowledgeGapHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	days, ok := optionalIntParam(r, "days")
+	if !ok {
+		BadRequestHandler(w, "days must be a number")
+		return
+	}
+	limit, ok := optionalIntParam(r, "limit")
+	if !ok {
+		BadRequestHandler(w, "limit must be a number")
+		return
+	}
+
+	report, err := h.service.Report(r.Context(), days, limit)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to build knowledge gap report", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to build knowledge gap report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// optionalIntParam parses an integer query parameter, returning 0 when it is absent
+func optionalIntParam(r *http.Request, name string) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, true
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return parsed, true
+}
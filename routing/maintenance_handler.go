@@ -0,0 +1,53 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+)
+
+type MaintenanceHandler struct {
+	mode *config.MaintenanceMode
+}
+
+func NewMaintenanceHandler(mode *config.MaintenanceMode) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		mode: mode,
+	}
+}
+
+func (h *MaintenanceHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	h.writeStatus(w)
+}
+
+// HandleSet replaces the maintenance status for this instance. Omitted messages and
+// Retry-After fall back to the defaults.
+func (h *MaintenanceHandler) HandleSet(w http.ResponseWriter, r *http.Request) {
+	var request config.MaintenanceStatus
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		BadRequestHandler(w, "Invalid JSON format")
+		return
+	}
+	defer r.Body.Close()
+
+	if request.RetryAfterSeconds < 0 {
+		BadRequestHandler(w, "retryAfterSeconds must not be negative")
+		return
+	}
+
+	h.mode.Update(request)
+	logger.WithContext(r.Context()).Warn("Maintenance mode changed", map[string]interface{}{
+		"enabled":     request.Enabled,
+		"remote_addr": r.RemoteAddr,
+	})
+
+	h.writeStatus(w)
+}
+
+func (h *MaintenanceHandler) writeStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.mode.Status())
+}
@@ -0,0 +1,46 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"teletubpax-api/config"
+
+	"github.com/gorilla/mux"
+)
+
+type MaintenanceResponse struct {
+	Error   string `json:"error"`
+	ErrorTh string `json:"errorTh"`
+	Status  int    `json:"status"`
+}
+
+// MaintenanceMiddleware answers 503 with Retry-After while maintenance mode is on. The
+// health check and admin endpoints stay available so maintenance can be switched off.
+func MaintenanceMiddleware(mode *config.MaintenanceMode) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := mode.Status()
+			path := apiPath(r.URL.Path)
+			if !status.Enabled ||
+				isHealthPath(path) ||
+				strings.HasPrefix(path, "/api/teletubpax/admin/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			response := MaintenanceResponse{
+				Error:   status.MessageEn,
+				ErrorTh: status.MessageTh,
+				Status:  503,
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(status.RetryAfterSeconds))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(response)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/config"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	mode := config.NewMaintenanceMode(config.MaintenanceStatus{Enabled: true, RetryAfterSeconds: 120})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := MaintenanceMiddleware(mode)(next)
+
+	tests := []struct {
+		path         string
+		expectedCode int
+	}{
+		{path: "/api/teletubpax/question-search", expectedCode: http.StatusServiceUnavailable},
+		{path: "/api/teletubpax/healthcheck", expectedCode: http.StatusOK},
+		{path: "/api/teletubpax/admin/maintenance", expectedCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tt.expectedCode {
+			t.Fatalf("%s: expected status %d, got %d", tt.path, tt.expectedCode, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Header().Get("Retry-After") != "120" {
+		t.Fatalf("expected Retry-After 120, got '%s'", w.Header().Get("Retry-After"))
+	}
+	var response MaintenanceResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Error == "" || response.ErrorTh == "" {
+		t.Fatalf("expected default English and Thai messages, got %+v", response)
+	}
+
+	mode.Update(config.MaintenanceStatus{Enabled: false})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/teletubpax/question-search", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected requests to pass once maintenance is off, got %d", w.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/normalization"
+	"teletubpax-api/storage"
+)
+
+const maxNormalizationTermLength = 100
+
+type NormalizationTermRequest struct {
+	Term      string `json:"term"`
+	Canonical string `json:"canonical"`
+}
+
+type NormalizationTermsResponse struct {
+	Terms []storage.NormalizationTerm `json:"terms"`
+}
+
+type NormalizationHandler struct {
+	dictionary *normalization.Dictionary
+}
+
+func NewNormalizationHandler(dictionary *normalization.Dictionary) *NormalizationHandler {
+	return &NormalizationHandler{
+		dictionary: dictionary,
+	}
+}
+
+// HandleList returns every mapping with its match count, most matched first
+func (h *NormalizationHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	h.writeTerms(w)
+}
+
+func (h *NormalizationHandler) HandlePut(w http.ResponseWriter, r *http.Request) {
+	var request NormalizationTermRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		BadRequestHandler(w, "Invalid JSON format")
+		return
+	}
+	defer r.Body.Close()
+
+	term := normalization.NormalizeTerm(request.Term)
+	canonical := strings.TrimSpace(request.Canonical)
+	if term == "" || canonical == "" {
+		BadRequestHandler(w, "term and canonical are required")
+		return
+	}
+	if utf8.RuneCountInString(term) > maxNormalizationTermLength || utf8.RuneCountInString(canonical) > maxNormalizationTermLength {
+		BadRequestHandler(w, "term and canonical must not exceed 100 characters")
+		return
+	}
+	if term == normalization.NormalizeTerm(canonical) {
+		BadRequestHandler(w, "canonical must differ from term")
+		return
+	}
+
+	record, err := h.dictionary.PutTerm(r.Context(), term, canonical)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to save normalization term", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to save normalization term")
+		return
+	}
+
+	logger.WithContext(r.Context()).Info("Normalization term saved", map[string]interface{}{
+		"term":      term,
+		"canonical": canonical,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(record)
+}
+
+// HandleDelete removes the mapping named by the term query parameter
+func (h *NormalizationHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	term := r.URL.Query().Get("term")
+	if strings.TrimSpace(term) == "" {
+		BadRequestHandler(w, "term query parameter is required")
+		return
+	}
+
+	deleted, err := h.dictionary.DeleteTerm(r.Context(), term)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to delete normalization term", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to delete normalization term")
+		return
+	}
+	if !deleted {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	logger.WithContext(r.Context()).Info("Normalization term deleted", map[string]interface{}{
+		"term": term,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleReload saves pending match counts and reloads the terms immediately instead of
+// waiting for the refresh interval
+func (h *NormalizationHandler) HandleReload(w http.ResponseWriter, r *http.Request) {
+	if err := h.dictionary.Reload(r.Context()); err != nil {
+		logger.WithContext(r.Context()).Error("Failed to reload normalization terms", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to reload normalization terms")
+		return
+	}
+
+	h.writeTerms(w)
+}
+
+func (h *NormalizationHandler) writeTerms(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(NormalizationTermsResponse{Terms: h.dictionary.Terms()})
+}
@@ -0,0 +1,654 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"teletubpax-api/config"
+	"teletubpax-api/eval"
+	"teletubpax-api/openapi"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	openAPIPath = "/api/teletubpax/openapi.json"
+	apiDocsPath = "/api/teletubpax/docs"
+)
+
+// apiOperation documents one method of a route. Request and response models are Go
+// values whose types are turned into schemas, so the document follows the handlers' types.
+type apiOperation struct {
+	summary         string
+	tag             string
+	parameters      []openapi.Parameter
+	request         interface{} // JSON request body, nil when there is none
+	optionalRequest bool        // The request body may be left out
+	status          int         // Success status, 200 when unset
+	response        interface{} // Success response body, nil when there is none
+	errors          []int       // Error statuses the operation answers, besides those of its middlewares
+	adminToken      bool        // Needs X-Admin-Token outside /api/teletubpax/admin
+}
+
+func queryParam(name string, schemaType string, description string, required bool) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Required: required, Schema: &openapi.Schema{Type: schemaType}}
+}
+
+func pathParam(name string, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "path", Description: description, Required: true, Schema: &openapi.Schema{Type: "string"}}
+}
+
+func headerParam(name string, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "header", Description: description, Schema: &openapi.Schema{Type: "string"}}
+}
+
+// apiOperations documents every route, by "METHOD path". Routes registered without an
+// entry are still listed, without models; routes_test.go keeps the two in sync.
+var apiOperations = map[string]apiOperation{
+	"GET /api/teletubpax/healthcheck": {
+		summary:  "Check that the API is up",
+		tag:      "Health",
+		response: Response{},
+	},
+	"GET " + openAPIPath: {
+		summary: "This OpenAPI document",
+		tag:     "Health",
+	},
+	"GET " + apiDocsPath: {
+		summary: "Swagger UI for this OpenAPI document",
+		tag:     "Health",
+	},
+	"POST /api/teletubpax/question-search": {
+		summary: "Answer a question from the knowledge bases",
+		tag:     "Questions",
+		parameters: []openapi.Parameter{
+			queryParam("enableRelateDocument", "boolean", "Return the documents the answer is based on", false),
+			headerParam("X-Session-Id", "Chat session of the caller, for session limits"),
+			headerParam("X-Tenant-Id", "Tenant choosing the answer backend"),
+			headerParam("Cache-Control", "no-cache generates a fresh answer instead of a cached one"),
+			headerParam("Idempotency-Key", "Replays the response of an earlier request with the same key and body"),
+		},
+		request:  QuestionSearchRequest{},
+		response: QuestionSearchResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"POST /api/teletubpax/question-search/async": {
+		summary: "Queue a question and answer it in the background",
+		tag:     "Questions",
+		parameters: []openapi.Parameter{
+			queryParam("enableRelateDocument", "boolean", "Return the documents the answer is based on", false),
+			headerParam("X-Session-Id", "Chat session of the caller, for session limits"),
+			headerParam("X-Tenant-Id", "Tenant choosing the answer backend"),
+			headerParam("Cache-Control", "no-cache generates a fresh answer instead of a cached one"),
+			headerParam(CallbackUrlHeader, "https:// URL the job is posted to once finished, signed with the returned callbackSecret"),
+		},
+		request:  QuestionSearchRequest{},
+		status:   http.StatusAccepted,
+		response: JobResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/jobs/{id}": {
+		summary:    "Poll a long-running operation: a queued question, a bulk summary or an ingestion sync",
+		tag:        "Jobs",
+		parameters: []openapi.Parameter{pathParam("id", "Job ID returned when the operation was started")},
+		response:   JobResponse{},
+		errors:     []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/chat": {
+		summary: "Open a WebSocket chat, answering questions like question-search with the answer streamed as it is generated",
+		tag:     "Questions",
+		status:  http.StatusSwitchingProtocols,
+		errors:  []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	"POST /api/teletubpax/related-questions": {
+		summary:  "Suggest follow-up questions to a question",
+		tag:      "Questions",
+		request:  RelatedQuestionsRequest{},
+		response: RelatedQuestionsResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/feedback": {
+		summary:  "Rate an answer",
+		tag:      "Questions",
+		request:  FeedbackRequest{},
+		response: Response{},
+		errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/history": {
+		summary: "List the signed-in caller's questions and answers, newest first",
+		tag:     "Questions",
+		parameters: []openapi.Parameter{
+			queryParam("limit", "integer", "Turns per page, 20 by default and at most 100", false),
+			queryParam("before", "string", "nextBefore of the previous page", false),
+		},
+		response: HistoryResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"DELETE /api/teletubpax/history": {
+		summary:  "Delete the signed-in caller's conversation history",
+		tag:      "Questions",
+		response: HistoryDeleteResponse{},
+		errors:   []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/usage": {
+		summary: "Report token usage and cost by tenant",
+		parameters: []openapi.Parameter{
+			queryParam("from", "string", "First UTC day as YYYY-MM-DD, the first of the month by default", false),
+			queryParam("to", "string", "Last UTC day as YYYY-MM-DD, today by default", false),
+			queryParam("tenant", "string", "Only report this X-Tenant-Id", false),
+		},
+		response:   services.UsageReport{},
+		errors:     []int{http.StatusBadRequest, http.StatusInternalServerError},
+		adminToken: true,
+	},
+	"GET /api/teletubpax/last-update-document": {
+		summary:  "List recently updated documents with their change summaries",
+		tag:      "Documents",
+		response: DocumentDetailsResponse{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/document-chunks": {
+		summary: "List the indexed chunks of a document",
+		tag:     "Documents",
+		parameters: []openapi.Parameter{
+			queryParam("uri", "string", "s3:// URI of the document", true),
+			queryParam("language", "string", "Translate the chunks to th or en", false),
+		},
+		response: DocumentChunksResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/documents/preview": {
+		summary: "Preview the beginning of a document, or its chunk most relevant to q",
+		tag:     "Documents",
+		parameters: []openapi.Parameter{
+			queryParam("uri", "string", "s3:// URI or link of the document", true),
+			queryParam("q", "string", "Show the chunk most relevant to this text instead of the beginning", false),
+			queryParam("length", "integer", "Characters of the excerpt, at most 2000", false),
+		},
+		response: DocumentPreviewResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/documents/versions": {
+		summary: "List every version of a topic with its change summary",
+		tag:     "Documents",
+		parameters: []openapi.Parameter{
+			queryParam("topic", "string", "File name of the documents without the version suffix and extension", true),
+		},
+		response: DocumentVersionsResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/summary-document/jobs": {
+		summary: "Start a bulk summary of up to SUMMARY_JOB_MAX_DOCUMENTS documents",
+		tag:     "Documents",
+		parameters: []openapi.Parameter{
+			headerParam("Idempotency-Key", "Replays the response of an earlier request with the same key and body"),
+			headerParam(CallbackUrlHeader, "https:// URL the job is posted to once finished, signed with the returned callbackSecret"),
+		},
+		request:  DocumentSummaryRequest{},
+		status:   http.StatusAccepted,
+		response: SummaryJobResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/summary-document/jobs/{id}": {
+		summary:    "Poll a bulk summary, with its summaries once completed",
+		tag:        "Documents",
+		parameters: []openapi.Parameter{pathParam("id", "Job ID returned when the summary was started")},
+		response:   SummaryJobResponse{},
+		errors:     []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/summary-document": {
+		summary: "Summarize documents",
+		tag:     "Documents",
+		parameters: []openapi.Parameter{
+			headerParam("Idempotency-Key", "Replays the response of an earlier request with the same key and body"),
+		},
+		request:  DocumentSummaryRequest{},
+		response: DocumentSummaryResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/document-compare": {
+		summary:  "Summarize the changes between any two documents",
+		tag:      "Documents",
+		request:  DocumentComparisonRequest{},
+		response: services.DocumentComparison{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusUnprocessableEntity, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+
+	"GET /api/teletubpax/admin/safe-mode": {
+		summary:  "Read the safe mode switch",
+		response: SafeModeResponse{},
+	},
+	"PUT /api/teletubpax/admin/safe-mode": {
+		summary:  "Turn safe mode on or off",
+		request:  SafeModeRequest{},
+		response: SafeModeResponse{},
+		errors:   []int{http.StatusBadRequest},
+	},
+	"GET /api/teletubpax/admin/maintenance": {
+		summary:  "Read the maintenance status",
+		response: config.MaintenanceStatus{},
+	},
+	"PUT /api/teletubpax/admin/maintenance": {
+		summary:  "Turn maintenance mode on or off",
+		request:  config.MaintenanceStatus{},
+		response: config.MaintenanceStatus{},
+		errors:   []int{http.StatusBadRequest},
+	},
+	"GET /api/teletubpax/admin/flags": {
+		summary:  "List the feature flags",
+		response: FeatureFlagsResponse{},
+	},
+	"POST /api/teletubpax/admin/flags/reload": {
+		summary:  "Reload the feature flags",
+		response: FeatureFlagsResponse{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/policies": {
+		summary:  "List the endpoint policies",
+		response: PoliciesResponse{},
+	},
+	"POST /api/teletubpax/admin/policies/reload": {
+		summary:  "Reload the endpoint policies",
+		response: PoliciesResponse{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/normalization": {
+		summary:  "List the normalization terms",
+		response: NormalizationTermsResponse{},
+	},
+	"PUT /api/teletubpax/admin/normalization": {
+		summary:  "Add or replace a normalization term",
+		request:  NormalizationTermRequest{},
+		response: storage.NormalizationTerm{},
+		errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"DELETE /api/teletubpax/admin/normalization": {
+		summary:    "Delete a normalization term",
+		parameters: []openapi.Parameter{queryParam("term", "string", "Term to delete", true)},
+		status:     http.StatusNoContent,
+		errors:     []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/normalization/reload": {
+		summary:  "Reload the normalization terms",
+		response: NormalizationTermsResponse{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/diagnostics/retrieval": {
+		summary:  "Show the chunks each knowledge base retrieves for a question",
+		request:  RetrievalDiagnosticsRequest{},
+		response: services.RetrievalDiagnostics{},
+		errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/knowledge-bases/data-sources": {
+		summary:  "List the data sources of the knowledge bases with their latest ingestion job",
+		response: DataSourcesResponse{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/knowledge-bases/ingestion-jobs": {
+		summary:  "Start an ingestion job that re-syncs a data source",
+		request:  IngestionJobRequest{},
+		status:   http.StatusAccepted,
+		response: IngestionJobResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/knowledge-bases/ingestion-jobs": {
+		summary: "Read the status of an ingestion job",
+		parameters: []openapi.Parameter{
+			queryParam("knowledgeBaseId", "string", "Knowledge base ID", true),
+			queryParam("dataSourceId", "string", "Data source ID", true),
+			queryParam("ingestionJobId", "string", "Ingestion job ID", true),
+		},
+		response: IngestionJobResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/diagnostics/answer-diff": {
+		summary:  "Compare the answers of the answer backends to a question",
+		request:  AnswerDiffRequest{},
+		response: services.AnswerDiff{},
+		errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/eval": {
+		summary:         "Run the golden dataset through question search and score the answers",
+		request:         EvalRunRequest{},
+		optionalRequest: true,
+		response:        eval.Report{},
+		errors:          []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/eval": {
+		summary:    "Read an evaluation report",
+		parameters: []openapi.Parameter{queryParam("runId", "string", "Run ID, the latest run by default", false)},
+		response:   eval.Report{},
+		errors:     []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/experiments": {
+		summary: "Report the answer quality of each experiment variant",
+		parameters: []openapi.Parameter{
+			queryParam("from", "string", "First UTC day as YYYY-MM-DD, 29 days ago by default", false),
+			queryParam("to", "string", "Last UTC day as YYYY-MM-DD, today by default", false),
+		},
+		response: services.ExperimentReport{},
+		errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/prompts": {
+		summary:  "List the prompts in effect",
+		response: PromptsResponse{},
+	},
+	"GET /api/teletubpax/admin/prompts/{name}": {
+		summary:    "Read a prompt with its saved versions",
+		parameters: []openapi.Parameter{pathParam("name", "Env var of the prompt, e.g. SYNTHESIS_INSTRUCTIONS")},
+		response:   services.PromptDetail{},
+		errors:     []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"PUT /api/teletubpax/admin/prompts/{name}": {
+		summary:    "Save the next version of a prompt and apply it without a redeploy",
+		parameters: []openapi.Parameter{pathParam("name", "Env var of the prompt, e.g. SYNTHESIS_INSTRUCTIONS")},
+		request:    PromptRequest{},
+		response:   storage.PromptVersion{},
+		errors:     []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/prompts/{name}/restore": {
+		summary:    "Save an earlier version of a prompt as its next version",
+		parameters: []openapi.Parameter{pathParam("name", "Env var of the prompt, e.g. SYNTHESIS_INSTRUCTIONS")},
+		request:    PromptRestoreRequest{},
+		response:   storage.PromptVersion{},
+		errors:     []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/analytics/knowledge-gaps": {
+		summary: "Report questions the knowledge bases could not answer",
+		parameters: []openapi.Parameter{
+			queryParam("days", "integer", "Days to report on", false),
+			queryParam("limit", "integer", "Questions to list", false),
+		},
+		response: services.KnowledgeGapReport{},
+		errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/analytics/export": {
+		summary:    "Export the analytics of a day to S3",
+		parameters: []openapi.Parameter{queryParam("date", "string", "UTC day as YYYY-MM-DD, yesterday by default", false)},
+		response:   services.AnalyticsExportResult{},
+		errors:     []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/documents/deleted": {
+		summary:  "List soft-deleted documents",
+		response: DeletedDocumentsResponse{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"DELETE /api/teletubpax/documents": {
+		summary:    "Delete a document for good and sync the knowledge bases",
+		tag:        "Documents",
+		parameters: []openapi.Parameter{queryParam("uri", "string", "s3:// URI or document URL", true)},
+		response:   services.DocumentRemoval{},
+		errors:     []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		adminToken: true,
+	},
+	"GET /api/teletubpax/documents/export": {
+		summary:    "Export the metadata of every indexed document, one JSON object per line or as CSV",
+		tag:        "Documents",
+		parameters: []openapi.Parameter{queryParam("format", "string", `"ndjson" (default) or "csv"`, false)},
+		response:   services.ExportedDocument{},
+		errors:     []int{http.StatusBadRequest, http.StatusInternalServerError},
+		adminToken: true,
+	},
+	"POST /api/teletubpax/admin/documents/delete": {
+		summary:  "Soft-delete a document",
+		request:  DocumentDeletionRequest{},
+		response: storage.DeletedDocument{},
+		errors:   []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/documents/restore": {
+		summary:  "Restore a soft-deleted document",
+		request:  DocumentDeletionRequest{},
+		response: Response{},
+		errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/documents/purge": {
+		summary:  "Purge documents past their retention",
+		response: services.PurgeResult{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/webhooks": {
+		summary:  "List the document version webhooks",
+		response: WebhooksResponse{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/webhooks": {
+		summary:  "Register a document version webhook",
+		request:  WebhookRequest{},
+		status:   http.StatusCreated,
+		response: services.RegisteredWebhook{},
+		errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"DELETE /api/teletubpax/admin/webhooks": {
+		summary:    "Delete a webhook",
+		parameters: []openapi.Parameter{queryParam("id", "string", "Webhook ID", true)},
+		status:     http.StatusNoContent,
+		errors:     []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/digest": {
+		summary:    "Preview the document change digest",
+		parameters: []openapi.Parameter{queryParam("days", "integer", "Days the digest covers", false)},
+		response:   services.Digest{},
+		errors:     []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/digest/send": {
+		summary:  "Send the digest to every subscription",
+		response: services.DigestSendResult{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/digest/subscriptions": {
+		summary:  "List the digest subscriptions",
+		response: DigestSubscriptionsResponse{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/digest/subscriptions": {
+		summary:  "Subscribe a team to the digest",
+		request:  DigestSubscriptionRequest{},
+		status:   http.StatusCreated,
+		response: storage.DigestSubscription{},
+		errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"DELETE /api/teletubpax/admin/digest/subscriptions": {
+		summary:    "Delete a digest subscription",
+		parameters: []openapi.Parameter{queryParam("id", "string", "Subscription ID", true)},
+		status:     http.StatusNoContent,
+		errors:     []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/audit/{id}": {
+		summary:    "Read an audit record of a question",
+		parameters: []openapi.Parameter{pathParam("id", "Audit record ID")},
+		response:   storage.AuditRecord{},
+		errors:     []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"DELETE /api/teletubpax/admin/history": {
+		summary:    "Delete the conversation history of a user, for PDPA erasure requests",
+		parameters: []openapi.Parameter{queryParam("userId", "string", "User ID of the bearer tokens, the AUTH_USER_CLAIM claim", true)},
+		response:   HistoryDeleteResponse{},
+		errors:     []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/api-keys": {
+		summary:  "List the API keys",
+		response: ApiKeysResponse{},
+		errors:   []int{http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/api-keys": {
+		summary:  "Create an API key",
+		request:  ApiKeyRequest{},
+		status:   http.StatusCreated,
+		response: services.CreatedApiKey{},
+		errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"DELETE /api/teletubpax/admin/api-keys": {
+		summary:    "Revoke an API key",
+		parameters: []openapi.Parameter{queryParam("id", "string", "API key ID", true)},
+		status:     http.StatusNoContent,
+		errors:     []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"PUT /api/teletubpax/admin/api-keys/quota": {
+		summary: "Set the daily request quota of an API key",
+		request: ApiKeyQuotaRequest{},
+		status:  http.StatusNoContent,
+		errors:  []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GET /api/teletubpax/admin/jobs/resummarize": {
+		summary:  "Read the progress of the re-summarization job",
+		response: DocumentResummarizeResponse{},
+		errors:   []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"POST /api/teletubpax/admin/jobs/resummarize": {
+		summary:         "Run or resume the re-summarization job",
+		request:         DocumentResummarizeRequest{},
+		optionalRequest: true,
+		response:        DocumentResummarizeResponse{},
+		errors:          []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+}
+
+// OpenAPIHandler serves the OpenAPI document of the routes registered on a router, and a
+// Swagger UI for it. The document is built on the first request, once every route is
+// registered, so optional routes that are not configured are left out.
+type OpenAPIHandler struct {
+	router   *mux.Router
+	once     sync.Once
+	document []byte
+}
+
+func NewOpenAPIHandler(router *mux.Router) *OpenAPIHandler {
+	return &OpenAPIHandler{router: router}
+}
+
+func (h *OpenAPIHandler) HandleDocument(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		h.document, _ = json.Marshal(BuildOpenAPIDocument(h.router))
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(h.document)
+}
+
+func (h *OpenAPIHandler) HandleUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(swaggerUIPage))
+}
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at the document next to it
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Teletubpax API</title>
+  <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// BuildOpenAPIDocument documents every route registered on the router
+func BuildOpenAPIDocument(router *mux.Router) *openapi.Document {
+	document := openapi.New(openapi.Info{
+		Title:       "Teletubpax API",
+		Description: "Question search over the Bedrock knowledge bases. Admin endpoints need the X-Admin-Token header.",
+		Version:     "1.0.0",
+	})
+	document.Components.SecuritySchemes["adminToken"] = &openapi.SecurityScheme{Type: "apiKey", Name: "X-Admin-Token", In: "header"}
+	document.Components.SecuritySchemes["bearerAuth"] = &openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	document.Components.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{Type: "apiKey", Name: "X-Api-Key", In: "header"}
+
+	for _, route := range registeredRoutes(router) {
+		method, path, _ := strings.Cut(route, " ")
+		document.AddOperation(method, versionedPath(path), buildOperation(document, method, path, apiOperations[route]))
+	}
+	return document
+}
+
+// registeredRoutes lists the /v1 routes of the router as "METHOD path", with the path
+// without its version and without preflights. The deprecated unversioned aliases are left out.
+func registeredRoutes(router *mux.Router) []string {
+	var routes []string
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, apiV1PathPrefix) {
+			return nil
+		}
+		path = apiPath(path)
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if method != http.MethodOptions {
+				routes = append(routes, method+" "+path)
+			}
+		}
+		return nil
+	})
+	sort.Strings(routes)
+	return routes
+}
+
+func buildOperation(document *openapi.Document, method string, path string, api apiOperation) *openapi.Operation {
+	admin := api.adminToken || strings.HasPrefix(path, "/api/teletubpax/admin/")
+	operation := &openapi.Operation{
+		OperationId: operationId(method, path),
+		Summary:     api.summary,
+		Parameters:  api.parameters,
+		Responses:   map[string]*openapi.Response{},
+	}
+	if admin {
+		operation.Tags = []string{"Admin"}
+		operation.Security = []map[string][]string{{"adminToken": {}}}
+	} else if api.tag != "" {
+		operation.Tags = []string{api.tag}
+	}
+	if api.tag != "Health" && !admin {
+		// Anonymous callers are allowed unless API_KEY_REQUIRED or AUTH_REQUIRED is set
+		operation.Security = []map[string][]string{{}, {"bearerAuth": {}}, {"apiKey": {}}}
+	}
+
+	if api.request != nil {
+		operation.RequestBody = document.JSONBody(api.request)
+		operation.RequestBody.Required = !api.optionalRequest
+	}
+	status := api.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	operation.Responses[strconv.Itoa(status)] = document.JSONResponse(http.StatusText(status), api.response)
+
+	statuses := api.errors
+	if admin {
+		statuses = append([]int{http.StatusUnauthorized, http.StatusForbidden}, statuses...)
+	}
+	for _, status := range statuses {
+		var body interface{} = ErrorResponse{}
+		if status == http.StatusBadRequest {
+			body = ValidationErrorResponse{}
+		}
+		operation.Responses[strconv.Itoa(status)] = document.JSONResponse(http.StatusText(status), body)
+	}
+	return operation
+}
+
+// operationId names an operation after its method and path, e.g. getAdminSafeMode
+func operationId(method string, path string) string {
+	id := strings.ToLower(method)
+	for _, word := range strings.FieldsFunc(strings.TrimPrefix(path, "/api/teletubpax/"), func(r rune) bool {
+		return r == '/' || r == '-' || r == '.' || r == '{' || r == '}'
+	}) {
+		id += strings.ToUpper(word[:1]) + word[1:]
+	}
+	return id
+}
@@ -0,0 +1,156 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/eval"
+	"teletubpax-api/flags"
+	"teletubpax-api/normalization"
+	"teletubpax-api/policy"
+	"teletubpax-api/services"
+)
+
+// allRoutesServices registers every optional route. The services are never called.
+func allRoutesServices() RouteServices {
+	return RouteServices{
+		DocumentResummarize: (*services.BedrockDocumentResummarizeService)(nil),
+		AnswerDiff:          (*services.BedrockAnswerDiffService)(nil),
+		Evaluation:          (*eval.Evaluator)(nil),
+		Experiments:         (*services.StoreExperimentService)(nil),
+		Prompts:             (*services.StorePromptService)(nil),
+		Ingestion:           (*services.BedrockIngestionService)(nil),
+		KnowledgeGaps:       (*services.StoreKnowledgeGapService)(nil),
+		AnalyticsExport:     (*services.S3AnalyticsExportService)(nil),
+		DocumentDeletion:    (*services.StoreDocumentDeletionService)(nil),
+		DocumentRemoval:     (*services.S3DocumentRemovalService)(nil),
+		DocumentExport:      (*services.BedrockDocumentExportService)(nil),
+		Webhooks:            (*services.HTTPWebhookService)(nil),
+		Digest:              (*services.StoreDigestService)(nil),
+		Feedback:            (*services.StoreFeedbackService)(nil),
+		History:             (*services.StoreHistoryService)(nil),
+		Audit:               (*services.StoreAuditService)(nil),
+		Usage:               (*services.StoreUsageService)(nil),
+		ApiKeys:             (*services.StoreApiKeyService)(nil),
+		QuestionJobs:        (*services.QueueQuestionJobService)(nil),
+		Jobs:                (*services.StoreJobService)(nil),
+		SummaryJobs:         (*services.StepFunctionsSummaryWorkflowService)(nil),
+		FeatureFlags:        flags.New(time.Minute),
+		Normalization:       normalization.New(nil, time.Minute),
+		Policies:            policy.New(policy.Defaults(3), time.Minute),
+	}
+}
+
+func allRoutesConfig() *config.Config {
+	return &config.Config{
+		MaxQuestionLength: 1000,
+		SafeMode:          config.NewSafeMode(false),
+		MaintenanceMode:   config.NewMaintenanceMode(config.MaintenanceStatus{}),
+		ChatEnabled:       true,
+	}
+}
+
+func TestOpenAPIOperationsMatchRoutes(t *testing.T) {
+	router := SetupRoutes(allRoutesServices(), allRoutesConfig())
+
+	registered := map[string]bool{}
+	for _, route := range registeredRoutes(router) {
+		registered[route] = true
+		if _, ok := apiOperations[route]; !ok {
+			t.Errorf("route %s is not documented in apiOperations", route)
+		}
+	}
+	for route := range apiOperations {
+		if !registered[route] {
+			t.Errorf("apiOperations documents %s, which is not registered", route)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	router := SetupRoutes(allRoutesServices(), allRoutesConfig())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/teletubpax/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var document struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationId string `json:"operationId"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Ref string `json:"$ref"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &document); err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+	if document.OpenAPI != "3.0.3" {
+		t.Errorf("expected OpenAPI 3.0.3, got %q", document.OpenAPI)
+	}
+
+	questionSearch := document.Paths["/api/teletubpax/v1/question-search"]["post"]
+	if questionSearch.OperationId != "postQuestionSearch" {
+		t.Errorf("unexpected operation id %q", questionSearch.OperationId)
+	}
+	if ref := questionSearch.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/QuestionSearchRequest" {
+		t.Errorf("unexpected question search request schema %q", ref)
+	}
+	for _, status := range []string{"200", "400", "429"} {
+		if _, ok := questionSearch.Responses[status]; !ok {
+			t.Errorf("expected a %s response for question search", status)
+		}
+	}
+	if _, ok := document.Paths["/api/teletubpax/v1/admin/webhooks"]["delete"].Responses["204"]; !ok {
+		t.Error("expected a 204 response for webhook deletion")
+	}
+
+	// Every reference resolves to a component
+	for _, ref := range strings.Split(w.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(ref, `"`)
+		if _, ok := document.Components.Schemas[name]; !ok {
+			t.Errorf("reference to missing schema %s", name)
+		}
+	}
+}
+
+func TestOpenAPIDocument_LeavesOutUnconfiguredRoutes(t *testing.T) {
+	router := SetupRoutes(RouteServices{}, &config.Config{MaxQuestionLength: 1000})
+	document := BuildOpenAPIDocument(router)
+
+	if _, ok := document.Paths["/api/teletubpax/v1/question-search"]; !ok {
+		t.Error("expected question search to be documented")
+	}
+	if _, ok := document.Paths["/api/teletubpax/v1/admin/webhooks"]; ok {
+		t.Error("expected webhooks to be left out without a webhook service")
+	}
+}
+
+func TestSwaggerUI(t *testing.T) {
+	router := SetupRoutes(RouteServices{}, &config.Config{MaxQuestionLength: 1000})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/teletubpax/docs", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected an HTML page, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), `url: "openapi.json"`) {
+		t.Error("expected the page to load the OpenAPI document")
+	}
+}
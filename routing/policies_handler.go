@@ -0,0 +1,47 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/policy"
+)
+
+type PoliciesResponse struct {
+	Policies map[string]policy.Policy `json:"policies"`
+}
+
+type PoliciesHandler struct {
+	policies *policy.Policies
+}
+
+func NewPoliciesHandler(policies *policy.Policies) *PoliciesHandler {
+	return &PoliciesHandler{
+		policies: policies,
+	}
+}
+
+// HandleList returns the effective policy of the default block and every configured endpoint
+func (h *PoliciesHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	h.writePolicies(w)
+}
+
+// HandleReload reloads the policies immediately instead of waiting for the refresh interval
+func (h *PoliciesHandler) HandleReload(w http.ResponseWriter, r *http.Request) {
+	if err := h.policies.Reload(r.Context()); err != nil {
+		logger.WithContext(r.Context()).Error("Failed to reload endpoint policies", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to reload endpoint policies")
+		return
+	}
+
+	h.writePolicies(w)
+}
+
+func (h *PoliciesHandler) writePolicies(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(PoliciesResponse{Policies: h.policies.Snapshot()})
+}
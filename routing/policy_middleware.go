@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/policy"
+
+	"github.com/gorilla/mux"
+)
+
+// PolicyMiddleware applies the matched endpoint's policy: it rejects requests beyond the
+// endpoint's concurrency limit with 429, sets the request deadline and attaches the policy
+// to the request context for the retry and generation settings further down. The health
+// check and preflight requests are exempt.
+func PolicyMiddleware(policies *policy.Policies) mux.MiddlewareFunc {
+	limiter := &concurrencyLimiter{inFlight: map[string]int{}}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endpoint := endpointName(r)
+			if endpoint == "" || isHealthPath(r.URL.Path) || r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			p := policies.For(endpoint)
+			if !limiter.acquire(endpoint, p.MaxConcurrency) {
+				logger.WithContext(r.Context()).Warn("Endpoint concurrency limit reached", map[string]interface{}{
+					"endpoint":        endpoint,
+					"max_concurrency": p.MaxConcurrency,
+				})
+				TooManyRequestsHandler(w, "Too many concurrent requests, please retry later", p.RetryAfterSeconds)
+				return
+			}
+			defer limiter.release(endpoint)
+
+			ctx := policy.WithPolicy(r.Context(), p)
+			if timeout := p.Timeout(); timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// endpointName returns the matched route's path below /api/teletubpax or /api/teletubpax/v1,
+// e.g. "question-search"
+func endpointName(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(apiPath(template), apiPathPrefix)
+}
+
+// concurrencyLimiter counts in-flight requests per endpoint. Limits are read per request so
+// a reloaded policy applies immediately.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+func (l *concurrencyLimiter) acquire(endpoint string, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit > 0 && l.inFlight[endpoint] >= limit {
+		return false
+	}
+	l.inFlight[endpoint]++
+	return true
+}
+
+func (l *concurrencyLimiter) release(endpoint string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight[endpoint]--
+}
@@ -0,0 +1,92 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"teletubpax-api/policy"
+
+	"github.com/gorilla/mux"
+)
+
+func newPolicyTestRouter(policies *policy.Policies, handler http.HandlerFunc) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(PolicyMiddleware(policies))
+	registerRoute(router, "/api/teletubpax/question-search", methodHandlers{"POST": handler})
+	registerRoute(router, "/api/teletubpax/healthcheck", methodHandlers{"GET": handler})
+	return router
+}
+
+func TestPolicyMiddleware_RejectsRequestsAboveConcurrencyLimit(t *testing.T) {
+	policies := policy.New(policy.Defaults(3), 0, policy.NewEnvSource(`{"question-search": {"maxConcurrency": 1, "retryAfterSeconds": 15}}`))
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	router := newPolicyTestRouter(policies, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/teletubpax/question-search" {
+			entered <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/teletubpax/question-search", nil))
+		done <- w.Code
+	}()
+	<-entered
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/teletubpax/question-search", nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "15" {
+		t.Fatalf("expected 429 with Retry-After 15, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// The health check is never limited
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/teletubpax/healthcheck", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected health check to pass, got %d", w.Code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("expected the first request to succeed, got %d", code)
+	}
+
+	// The slot is released once the request completes, release is closed so the handler returns
+	go func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/teletubpax/question-search", nil))
+		done <- w.Code
+	}()
+	<-entered
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("expected the slot to be released, got %d", code)
+	}
+}
+
+func TestPolicyMiddleware_AttachesPolicyAndDeadline(t *testing.T) {
+	policies := policy.New(policy.Defaults(3), 0, policy.NewEnvSource(`{"question-search": {"timeoutSeconds": 5, "maxTokens": 512}}`))
+
+	var attached policy.Policy
+	var deadline time.Time
+	var hasDeadline bool
+	router := newPolicyTestRouter(policies, func(w http.ResponseWriter, r *http.Request) {
+		attached = policy.FromContext(r.Context(), policy.Policy{})
+		deadline, hasDeadline = r.Context().Deadline()
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/teletubpax/question-search", nil))
+
+	if attached.MaxTokens != 512 || attached.Retry.MaxAttempts != 3 {
+		t.Errorf("expected the endpoint policy in the context, got %+v", attached)
+	}
+	if !hasDeadline || time.Until(deadline) > 5*time.Second {
+		t.Errorf("expected a 5s deadline, got %v %v", hasDeadline, deadline)
+	}
+}
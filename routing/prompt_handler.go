@@ -0,0 +1,112 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+
+	"github.com/gorilla/mux"
+)
+
+type PromptRequest struct {
+	Text string `json:"text"`
+	Note string `json:"note"` // Optional, why the prompt was changed
+}
+
+type PromptRestoreRequest struct {
+	Version int64  `json:"version"`
+	Note    string `json:"note"` // Optional, "Restored version N" by default
+}
+
+type PromptsResponse struct {
+	Prompts []services.Prompt `json:"prompts"`
+}
+
+type PromptHandler struct {
+	prompts services.PromptService
+}
+
+func NewPromptHandler(prompts services.PromptService) *PromptHandler {
+	return &PromptHandler{
+		prompts: prompts,
+	}
+}
+
+// HandleList returns the prompts in effect
+func (h *PromptHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(PromptsResponse{Prompts: h.prompts.List(r.Context())})
+}
+
+// HandleGet returns the prompt named in the path with its saved versions
+func (h *PromptHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	prompt, err := h.prompts.Get(r.Context(), mux.Vars(r)["name"])
+	if err == services.ErrUnknownPrompt {
+		NotFoundHandler(w, r)
+		return
+	}
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to read prompt versions", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to read prompt versions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(prompt)
+}
+
+// HandlePut saves the text as the next version of the prompt named in the path
+func (h *PromptHandler) HandlePut(w http.ResponseWriter, r *http.Request) {
+	var request PromptRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		BadRequestHandler(w, "Invalid JSON format")
+		return
+	}
+	defer r.Body.Close()
+
+	version, err := h.prompts.Update(r.Context(), mux.Vars(r)["name"], request.Text, request.Note)
+	h.writeVersion(w, r, version, err)
+}
+
+// HandleRestore saves an earlier version as the next version of the prompt named in the path
+func (h *PromptHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	var request PromptRestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		BadRequestHandler(w, "Invalid JSON format")
+		return
+	}
+	defer r.Body.Close()
+
+	version, err := h.prompts.Restore(r.Context(), mux.Vars(r)["name"], request.Version, request.Note)
+	h.writeVersion(w, r, version, err)
+}
+
+func (h *PromptHandler) writeVersion(w http.ResponseWriter, r *http.Request, version *storage.PromptVersion, err error) {
+	if err == services.ErrUnknownPrompt {
+		NotFoundHandler(w, r)
+		return
+	}
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+		BadRequestHandler(w, bedrockErr.Message)
+		return
+	}
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to save prompt", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to save prompt")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(version)
+}
@@ -0,0 +1,115 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"teletubpax-api/auth"
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+// queuedQuestionHeaders are the request headers that change the answer, sent with the
+// question to the worker. Credentials are not: the caller was authenticated on submit.
+var queuedQuestionHeaders = []string{"Content-Type", "X-Tenant-Id", "X-Session-Id", "Cache-Control", RequestIdHeader, "X-Fault-Injection"}
+
+// CallbackUrlHeader asks for the outcome of an asynchronous job to be posted to the https://
+// URL once finished, instead of polling it
+const CallbackUrlHeader = "X-Callback-Url"
+
+type QuestionJobHandler struct {
+	jobs           services.QuestionJobService
+	questionSearch *QuestionSearchHandler
+}
+
+func NewQuestionJobHandler(jobs services.QuestionJobService, questionSearch *QuestionSearchHandler) *QuestionJobHandler {
+	return &QuestionJobHandler{
+		jobs:           jobs,
+		questionSearch: questionSearch,
+	}
+}
+
+// HandleSubmit validates the question like question-search and queues it, answering 202
+// with the job to poll at /jobs/{id}. With X-Callback-Url, the response is also posted there once
+// finished, signed with the callbackSecret of the 202.
+func (h *QuestionJobHandler) HandleSubmit(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyReadError(w, err)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if _, _, ok := h.questionSearch.decodeRequest(w, r); !ok {
+		return
+	}
+
+	question := &services.QueuedQuestion{
+		Query:    r.URL.RawQuery,
+		Headers:  map[string]string{},
+		Body:     body,
+		Identity: auth.IdentityFromContext(r.Context()),
+	}
+	for _, header := range queuedQuestionHeaders {
+		if value := r.Header.Get(header); value != "" {
+			question.Headers[header] = value
+		}
+	}
+
+	job, err := h.jobs.Submit(r.Context(), jobOwner(r), question, r.Header.Get(CallbackUrlHeader))
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+		BadRequestHandler(w, bedrockErr.Message)
+		return
+	}
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to queue question", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to queue the question")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", apiV1PathPrefix+"jobs/"+job.JobId)
+	w.WriteHeader(http.StatusAccepted)
+	response := newJobResponse(job)
+	response.CallbackSecret = job.CallbackSecret
+	json.NewEncoder(w).Encode(response)
+}
+
+type replayedQuestionKey struct{}
+
+// isReplayedQuestion reports whether the request is a queued question run by the worker,
+// authenticated when it was submitted
+func isReplayedQuestion(ctx context.Context) bool {
+	replayed, _ := ctx.Value(replayedQuestionKey{}).(bool)
+	return replayed
+}
+
+// QuestionJobRunner answers queued questions by serving them as question-search requests
+// through the router, so they get the same middlewares, tenant settings and limits
+func QuestionJobRunner(router http.Handler) services.QuestionJobRunner {
+	return func(ctx context.Context, question *services.QueuedQuestion) (int, []byte) {
+		ctx = context.WithValue(ctx, replayedQuestionKey{}, true)
+		if question.Identity != nil {
+			ctx = auth.WithIdentity(ctx, question.Identity)
+		}
+
+		target := apiV1PathPrefix + "question-search"
+		if question.Query != "" {
+			target += "?" + question.Query
+		}
+		req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(question.Body)).WithContext(ctx)
+		for header, value := range question.Headers {
+			req.Header.Set(header, value)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code, recorder.Body.Bytes()
+	}
+}
@@ -0,0 +1,121 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+type recordingQueueClient struct {
+	messages []string
+}
+
+func (r *recordingQueueClient) SendMessage(ctx context.Context, queueUrl string, body string) error {
+	r.messages = append(r.messages, body)
+	return nil
+}
+
+func TestQuestionJobs_AnswerQueuedQuestions(t *testing.T) {
+	queue := &recordingQueueClient{}
+	store := storage.NewMemoryJobStore()
+	jobs := services.NewQueueQuestionJobService(queue, "https://sqs.example/questions", store, time.Hour, nil)
+	var asked string
+	var related bool
+	router := SetupRoutes(RouteServices{
+		QuestionSearch: &mockQuestionSearchService{
+			searchAnswerFunc: func(ctx context.Context, question string, enableRelateDocument bool) (string, error) {
+				asked, related = question, enableRelateDocument
+				return "The fee is 100 baht", nil
+			},
+		},
+		QuestionJobs: jobs,
+		Jobs:         services.NewStoreJobService(store, nil),
+	}, allRoutesConfig())
+
+	send := func(method string, path string, session string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Session-Id", session)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("POST", "/api/teletubpax/v1/question-search/async", "widget-1", `{"question":""}`); w.Code != http.StatusBadRequest || len(queue.messages) != 0 {
+		t.Fatalf("expected an invalid question to answer 400 without queueing, got %d", w.Code)
+	}
+
+	w := send("POST", "/api/teletubpax/v1/question-search/async?enableRelateDocument=true", "widget-1", `{"question":"fee"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var submitted JobResponse
+	json.Unmarshal(w.Body.Bytes(), &submitted)
+	location := w.Header().Get("Location")
+	if submitted.Status != storage.JobQueued || location != "/api/teletubpax/v1/jobs/"+submitted.JobId {
+		t.Fatalf("expected a queued job and its location, got %+v at %q", submitted, location)
+	}
+
+	pending := send("GET", location, "widget-1", "")
+	if pending.Code != http.StatusOK || pending.Header().Get("Retry-After") == "" {
+		t.Errorf("expected a pending job with Retry-After, got %d", pending.Code)
+	}
+
+	if err := jobs.Process(context.Background(), queue.messages[0], QuestionJobRunner(router), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if asked != "fee" || !related {
+		t.Errorf("expected the queued request to be answered as sent, got %q %v", asked, related)
+	}
+
+	w = send("GET", location, "widget-1", "")
+	var answered JobResponse
+	json.Unmarshal(w.Body.Bytes(), &answered)
+	var result QuestionSearchResponse
+	json.Unmarshal(answered.Result, &result)
+	if answered.Status != storage.JobSucceeded || answered.StatusCode != http.StatusOK || result.Answer != "The fee is 100 baht" {
+		t.Errorf("expected the stored answer, got %+v", answered)
+	}
+
+	if w := send("GET", location, "widget-2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected jobs of other callers to answer 404, got %d", w.Code)
+	}
+}
+
+func TestQuestionJobs_ReturnCallbackSecretOnSubmission(t *testing.T) {
+	queue := &recordingQueueClient{}
+	store := storage.NewMemoryJobStore()
+	jobs := services.NewQueueQuestionJobService(queue, "https://sqs.example/questions", store, time.Hour, nil)
+	router := SetupRoutes(RouteServices{QuestionSearch: &mockQuestionSearchService{}, QuestionJobs: jobs, Jobs: services.NewStoreJobService(store, nil)}, allRoutesConfig())
+
+	submit := func(callbackUrl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/teletubpax/v1/question-search/async", strings.NewReader(`{"question":"fee"}`))
+		req.Header.Set(CallbackUrlHeader, callbackUrl)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := submit("http://portal.example.com/jobs"); w.Code != http.StatusBadRequest || len(queue.messages) != 0 {
+		t.Fatalf("expected a plain http callback URL to answer 400, got %d", w.Code)
+	}
+
+	w := submit("https://portal.example.com/jobs")
+	var submitted JobResponse
+	json.Unmarshal(w.Body.Bytes(), &submitted)
+	if w.Code != http.StatusAccepted || len(submitted.CallbackSecret) != 64 {
+		t.Fatalf("expected the callback secret in the 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/teletubpax/v1/jobs/"+submitted.JobId, nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), submitted.CallbackSecret) {
+		t.Errorf("expected the polled job without its secret, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -1,210 +1,416 @@
-package routing
-
-import (
-	"encoding/json"
-	"io"
-	"net/http"
-	"strings"
-
-	bedrockErrors "teletubpax-api/errors"
-	"teletubpax-api/logger"
-	"teletubpax-api/services"
-)
-
-type QuestionSearchRequest struct {
-	Question string `json:"question"`
-}
-
-type QuestionSearchResponse struct {
-	Answer           string   `json:"answer"`
-	RelatedDocuments []string `json:"relatedDocuments"`
-}
-
-type QuestionSearchHandler struct {
-	service           services.QuestionSearchService
-	maxQuestionLength int
-}
-
-func NewQuestionSearchHandler(service services.QuestionSearchService, maxQuestionLength int) *QuestionSearchHandler {
-	return &QuestionSearchHandler{
-		service:           service,
-		maxQuestionLength: maxQuestionLength,
-	}
-}
-
-func (h *QuestionSearchHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	log := logger.WithContext(r.Context())
-	
-	log.Info("Incoming request", map[string]interface{}{
-		"method":      r.Method,
-		"path":        r.URL.Path,
-		"remote_addr": r.RemoteAddr,
-		"user_agent":  r.Header.Get("User-Agent"),
-	})
-
-	// Validate Content-Type
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "application/json" && contentType != "" {
-		log.Warn("Invalid content type", map[string]interface{}{
-			"content_type": contentType,
-		})
-		BadRequestHandler(w, "Content-Type must be application/json")
-		return
-	}
-
-	// Read and parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Error("Failed to read request body", map[string]interface{}{
-			"error": err.Error(),
-		})
-		BadRequestHandler(w, "Failed to read request body")
-		return
-	}
-	defer r.Body.Close()
-
-	var request QuestionSearchRequest
-	if err := json.Unmarshal(body, &request); err != nil {
-		log.Warn("Invalid JSON format", map[string]interface{}{
-			"error": err.Error(),
-		})
-		BadRequestHandler(w, "Invalid JSON format")
-		return
-	}
-
-	// Validate question field presence
-	if request.Question == "" {
-		log.Warn("Question field is empty")
-		BadRequestHandler(w, "Question field is required")
-		return
-	}
-
-	// Validate question is not whitespace-only
-	if strings.TrimSpace(request.Question) == "" {
-		log.Warn("Question is whitespace-only")
-		BadRequestHandler(w, "Question cannot be empty or whitespace-only")
-		return
-	}
-
-	// Validate question length
-	if len(request.Question) > h.maxQuestionLength {
-		log.Warn("Question exceeds maximum length", map[string]interface{}{
-			"length":     len(request.Question),
-			"max_length": h.maxQuestionLength,
-		})
-		BadRequestHandler(w, "Question exceeds maximum length")
-		return
-	}
-
-	// Parse query parameter for enableRelateDocument
-	enableRelateDocument := false
-	if r.URL.Query().Get("enableRelateDocument") == "true" {
-		enableRelateDocument = true
-	}
-
-	// Call service layer
-	ctx := r.Context()
-	answer, relatedDocuments, err := h.service.SearchAnswer(ctx, request.Question, enableRelateDocument)
-
-	if err != nil {
-		h.handleError(w, r, err)
-		return
-	}
-
-	// Format success response
-	response := QuestionSearchResponse{
-		Answer:           answer,
-		RelatedDocuments: relatedDocuments,
-	}
-
-	log.Info("Request completed successfully", map[string]interface{}{
-		"answer_length":  len(answer),
-		"document_count": len(relatedDocuments),
-	})
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
-}
-
-func (h *QuestionSearchHandler) handleError(w http.ResponseWriter, r *http.Request, err error) {
-	log := logger.WithContext(r.Context())
-	
-	// Check if it's a BedrockError
-	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok {
-		switch bedrockErr.Code {
-		case bedrockErrors.ErrCodeValidation:
-			log.Warn("Validation error", map[string]interface{}{
-				"error": bedrockErr.Message,
-			})
-			BadRequestHandler(w, bedrockErr.Message)
-			return
-		case bedrockErrors.ErrCodeThrottling:
-			h.handleThrottlingError(w, r, bedrockErr.Message)
-			return
-		case bedrockErrors.ErrCodeEmbedding, bedrockErrors.ErrCodeKnowledgeBase:
-			// Check if it's a quota error
-			if strings.Contains(bedrockErr.Message, "quota") || strings.Contains(bedrockErr.Message, "Quota") {
-				h.handleQuotaError(w, r, bedrockErr.Message)
-				return
-			}
-			log.Error("Bedrock service error", map[string]interface{}{
-				"error_code": bedrockErr.Code,
-				"error":      bedrockErr.Message,
-			})
-			InternalServerErrorHandler(w, bedrockErr.Message)
-			return
-		case bedrockErrors.ErrCodeAWSService:
-			// Check if it's a quota error
-			if strings.Contains(bedrockErr.Message, "quota") || strings.Contains(bedrockErr.Message, "Quota") {
-				h.handleQuotaError(w, r, bedrockErr.Message)
-				return
-			}
-			log.Error("AWS service error", map[string]interface{}{
-				"error": bedrockErr.Message,
-			})
-			InternalServerErrorHandler(w, bedrockErr.Message)
-			return
-		}
-	}
-
-	// Default to internal server error
-	log.Error("Unhandled error", map[string]interface{}{
-		"error": err.Error(),
-	})
-	InternalServerErrorHandler(w, "An error occurred processing your request")
-}
-
-func (h *QuestionSearchHandler) handleThrottlingError(w http.ResponseWriter, r *http.Request, message string) {
-	log := logger.WithContext(r.Context())
-	log.Warn("Request throttled", map[string]interface{}{
-		"error":       message,
-		"retry_after": 60,
-	})
-
-	errorResponse := ErrorResponse{
-		Error:  message,
-		Status: 429,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Retry-After", "60")
-	w.WriteHeader(http.StatusTooManyRequests)
-	json.NewEncoder(w).Encode(errorResponse)
-}
-
-func (h *QuestionSearchHandler) handleQuotaError(w http.ResponseWriter, r *http.Request, message string) {
-	log := logger.WithContext(r.Context())
-	log.Error("Quota exceeded", map[string]interface{}{
-		"error": message,
-	})
-
-	errorResponse := ErrorResponse{
-		Error:  message,
-		Status: 503,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusServiceUnavailable)
-	json.NewEncoder(w).Encode(errorResponse)
-}
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"teletubpax-api/aws"
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/policy"
+	"teletubpax-api/services"
+	"teletubpax-api/utils"
+	"teletubpax-api/warnings"
+)
+
+type QuestionSearchRequest struct {
+	Question          string            `json:"question"`
+	TargetLanguage    string            `json:"targetLanguage,omitempty"`    // Optional answer language, "th" or "en"; the answer is translated when needed
+	Language          string            `json:"language,omitempty"`          // Deprecated: Use TargetLanguage, which takes precedence
+	SkipClarification bool              `json:"skipClarification,omitempty"` // Answer as asked, without a clarification prompt
+	SessionId         string            `json:"sessionId,omitempty"`         // Session ID of the previous answer, for follow-up questions
+	Filters           *RetrievalFilters `json:"filters,omitempty"`           // Answer from the documents matching these metadata only
+	SearchType        string            `json:"searchType,omitempty"`        // Optional "HYBRID" or "SEMANTIC", overrides RETRIEVAL_SEARCH_TYPE
+	NumberOfResults   int               `json:"numberOfResults,omitempty"`   // Optional chunks per knowledge base, overrides RETRIEVAL_RESULTS
+	MinScore          *float64          `json:"minScore,omitempty"`          // Optional 0 to 1, overrides RETRIEVAL_MIN_SCORE
+	Temperature       *float64          `json:"temperature,omitempty"`       // Optional 0 to 1, needs GENERATION_OVERRIDES_ENABLED
+	MaxTokens         int               `json:"maxTokens,omitempty"`         // Optional generation limit, needs GENERATION_OVERRIDES_ENABLED
+	InstructionSuffix string            `json:"instructionSuffix,omitempty"` // Optional text added to the prompts, needs GENERATION_OVERRIDES_ENABLED
+}
+
+// retrievalSettings returns the retrieval settings the request asks for
+func (r *QuestionSearchRequest) retrievalSettings() aws.RetrievalSettings {
+	return aws.RetrievalSettings{NumberOfResults: r.NumberOfResults, MinScore: r.MinScore}
+}
+
+// generationSettings returns the generation settings the request asks for
+func (r *QuestionSearchRequest) generationSettings() aws.GenerationSettings {
+	return aws.GenerationSettings{Temperature: r.Temperature, MaxTokens: r.MaxTokens, InstructionSuffix: strings.TrimSpace(r.InstructionSuffix)}
+}
+
+// targetLanguage returns the requested answer language, "" to answer in the language of
+// the question
+func (r *QuestionSearchRequest) targetLanguage() string {
+	if r.TargetLanguage != "" {
+		return r.TargetLanguage
+	}
+	return r.Language
+}
+
+type QuestionSearchResponse struct {
+	Answer           string                    `json:"answer"`
+	RelatedDocuments []string                  `json:"relatedDocuments"`
+	Language         string                    `json:"language,omitempty"`   // Answer language, set when a language was requested
+	SourceText       string                    `json:"sourceText,omitempty"` // Original answer when it was translated
+	SessionId        string                    `json:"sessionId,omitempty"`  // Send with the next question to keep the conversation context
+	AnswerId         string                    `json:"answerId,omitempty"`   // Send with feedback on the answer, set when feedback is enabled
+	Disclaimer       string                    `json:"disclaimer,omitempty"` // Set when disclaimers are returned as a separate field
+	Warnings         []warnings.Warning        `json:"warnings,omitempty"`   // Degraded-mode notices, e.g. a skipped knowledge base
+	Confidence       *float64                  `json:"confidence,omitempty"` // 0 to 1, set when the answer backend scores its answers
+	Citations        []aws.Citation            `json:"citations,omitempty"`  // Parts of the answer and the document chunks they came from
+	RelatedPassages  []RelatedPassage          `json:"relatedPassages,omitempty"`
+	Clarification    *Clarification            `json:"clarification,omitempty"`
+	KnowledgeBases   []aws.KnowledgeBaseStatus `json:"knowledgeBases,omitempty"` // How each knowledge base fared, for answers from several
+	Partial          bool                      `json:"partial,omitempty"`        // A knowledge base was skipped, failed or timed out and the answer comes from the others
+}
+
+// RelatedPassage is a cited chunk of a related document, so clients can highlight the
+// passage instead of only linking to the document. Passages follow relatedDocuments order.
+type RelatedPassage struct {
+	DocumentUrl string `json:"documentUrl"` // As listed in relatedDocuments
+	PageNumber  int    `json:"pageNumber,omitempty"`
+	ChunkId     string `json:"chunkId,omitempty"`
+	Text        string `json:"text"` // The chunk with its whitespace collapsed, up to 500 characters
+}
+
+// Clarification is returned instead of an answer when the question is too broad or asks
+// several things at once. The answer field holds the prompt in the question's language.
+type Clarification struct {
+	Reason      string   `json:"reason"` // "too_long", "multi_part" or "broad"
+	Message     string   `json:"message"`
+	MessageTh   string   `json:"messageTh"`
+	Suggestions []string `json:"suggestions"` // Narrower questions to ask instead
+}
+
+type QuestionSearchHandler struct {
+	service             services.QuestionSearchService
+	translation         services.TranslationService // Optional
+	disclaimers         *services.AnswerDisclaimers // Optional
+	maxQuestionLength   int
+	generationOverrides bool // Requests may set temperature, maxTokens and instructionSuffix
+}
+
+func NewQuestionSearchHandler(service services.QuestionSearchService, translation services.TranslationService, disclaimers *services.AnswerDisclaimers, maxQuestionLength int, generationOverrides bool) *QuestionSearchHandler {
+	return &QuestionSearchHandler{
+		service:             service,
+		translation:         translation,
+		disclaimers:         disclaimers,
+		maxQuestionLength:   maxQuestionLength,
+		generationOverrides: generationOverrides,
+	}
+}
+
+func (h *QuestionSearchHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	request, conversation, ok := h.decodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse query parameter for enableRelateDocument
+	enableRelateDocument := false
+	if r.URL.Query().Get("enableRelateDocument") == "true" {
+		enableRelateDocument = true
+	}
+
+	// Call service layer, with the caller's session for the session limits, the tenant for
+	// its answer backend, the conversation for follow-up questions, the metadata filters, the
+	// search type and the retrieval and generation settings
+	ctx, collected := warnings.WithCollector(services.WithSessionId(r.Context(), sessionKey(r)))
+	ctx = services.WithTenantId(ctx, r.Header.Get("X-Tenant-Id"))
+	ctx = aws.WithConversation(ctx, conversation)
+	ctx = aws.WithMetadataFilter(ctx, request.Filters.metadataFilter())
+	ctx = aws.WithSearchType(ctx, request.SearchType)
+	ctx = aws.WithRetrievalSettings(ctx, request.retrievalSettings())
+	ctx = aws.WithGenerationSettings(ctx, request.generationSettings())
+	if request.SkipClarification {
+		ctx = services.WithoutClarification(ctx)
+	}
+	if strings.Contains(strings.ToLower(r.Header.Get("Cache-Control")), "no-cache") {
+		ctx = services.WithoutAnswerCache(ctx)
+	}
+	ctx, cacheStatus := services.WithAnswerCacheStatus(ctx)
+	ctx, answerRecord := services.WithAnswerRecord(ctx)
+	ctx, confidence := aws.WithConfidenceRecorder(ctx)
+	ctx, citations := aws.WithCitationCollector(ctx)
+	ctx, knowledgeBases := aws.WithKnowledgeBaseStatusRecorder(ctx)
+	answer, relatedDocuments, err := h.service.SearchAnswer(ctx, request.Question, enableRelateDocument)
+
+	if clarification, ok := err.(*services.ClarificationRequiredError); ok {
+		h.handleClarification(w, r, *request, clarification)
+		return
+	}
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	// Format success response
+	response := QuestionSearchResponse{
+		Answer:           answer,
+		RelatedDocuments: relatedDocuments,
+		SessionId:        conversation.SessionId(),
+		AnswerId:         answerRecord.Id(),
+	}
+
+	if language := request.targetLanguage(); language != "" {
+		h.translateAnswer(r.WithContext(ctx), &response, language)
+	}
+	if h.disclaimers != nil {
+		response.Answer, response.Disclaimer = h.disclaimers.Apply(ctx, response.Answer)
+	}
+	response.Warnings = collected.List()
+	if value, ok := confidence.Confidence(); ok {
+		response.Confidence = &value
+	}
+	// Spans are located after translation and disclaimers, in the answer as returned
+	response.Citations = aws.LocateCitations(response.Answer, citations.List())
+	response.RelatedPassages = relatedPassages(relatedDocuments, response.Citations)
+	response.KnowledgeBases = knowledgeBases.List()
+	response.Partial = knowledgeBases.Partial()
+
+	w.Header().Set("Content-Type", "application/json")
+	if status := cacheStatus.Status(); status != "" {
+		w.Header().Set("X-Answer-Cache", status)
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// relatedPassages lists the chunks cited from each related document once. Documents found
+// without a citation, when the answer cited none, have no passages.
+func relatedPassages(relatedDocuments []string, citations []aws.Citation) []RelatedPassage {
+	if len(relatedDocuments) == 0 || len(citations) == 0 {
+		return nil
+	}
+
+	// Pre-signed links are signed per listing, documents are matched by their bucket URL
+	byDocument := make(map[string][]aws.CitationSource)
+	for _, citation := range citations {
+		for _, source := range citation.Sources {
+			document := aws.UnsignedDocumentLink(source.DocumentUrl)
+			byDocument[document] = append(byDocument[document], source)
+		}
+	}
+
+	var passages []RelatedPassage
+	for _, documentUrl := range relatedDocuments {
+		seen := make(map[string]bool)
+		for _, source := range byDocument[aws.UnsignedDocumentLink(documentUrl)] {
+			if source.Excerpt == "" || seen[source.ChunkId+"\x00"+source.Excerpt] {
+				continue
+			}
+			seen[source.ChunkId+"\x00"+source.Excerpt] = true
+			passages = append(passages, RelatedPassage{
+				DocumentUrl: documentUrl,
+				PageNumber:  source.PageNumber,
+				ChunkId:     source.ChunkId,
+				Text:        source.Excerpt,
+			})
+		}
+	}
+	return passages
+}
+
+// translateAnswer translates the answer when it is not in the requested language. The answer
+// is returned untranslated when translation is disabled or fails.
+func (h *QuestionSearchHandler) translateAnswer(r *http.Request, response *QuestionSearchResponse, language string) {
+	response.Language = utils.DetectLanguage(response.Answer)
+	if h.translation == nil {
+		if response.Language != language {
+			warnings.Add(r.Context(), warnings.CodeTranslationFailed, "Translation is disabled, the answer is in its original language")
+		}
+		return
+	}
+
+	translated, err := h.translation.Translate(r.Context(), response.Answer, language)
+	if err != nil {
+		logger.WithContext(r.Context()).Warn("Failed to translate answer", map[string]interface{}{
+			"language": language,
+			"error":    err.Error(),
+		})
+		warnings.Add(r.Context(), warnings.CodeTranslationFailed, "The answer could not be translated and is in its original language")
+		return
+	}
+	response.Answer = translated.Text
+	response.Language = translated.Language
+	response.SourceText = translated.SourceText
+}
+
+// decodeRequest decodes and validates the request, answering 400 when it is invalid, and
+// parses the conversation of its sessionId
+func (h *QuestionSearchHandler) decodeRequest(w http.ResponseWriter, r *http.Request) (*QuestionSearchRequest, *aws.Conversation, bool) {
+	var conversation *aws.Conversation
+	request, ok := DecodeJSONRequest(w, r, func(request *QuestionSearchRequest) []Rule {
+		// Clean up the question before it is validated, cached and searched
+		request.Question = utils.NormalizeThaiText(request.Question)
+		var err error
+		conversation, err = aws.ParseConversation(request.SessionId)
+		return append([]Rule{
+			Required("question", request.Question),
+			MaxLength("question", request.Question, h.maxQuestionLength),
+			OneOf("targetLanguage", request.TargetLanguage, utils.LanguageThai, utils.LanguageEnglish),
+			OneOf("language", request.Language, utils.LanguageThai, utils.LanguageEnglish),
+			OneOf("searchType", request.SearchType, aws.SearchTypeHybrid, aws.SearchTypeSemantic),
+			Between("numberOfResults", float64(request.NumberOfResults), 0, aws.MaxRetrievalResults),
+			Between("minScore", floatOrZero(request.MinScore), 0, 1),
+			Enabled("temperature", request.Temperature != nil, h.generationOverrides, "GENERATION_OVERRIDES_ENABLED"),
+			Between("temperature", floatOrZero(request.Temperature), 0, 1),
+			Enabled("maxTokens", request.MaxTokens != 0, h.generationOverrides, "GENERATION_OVERRIDES_ENABLED"),
+			Between("maxTokens", float64(request.MaxTokens), 0, aws.MaxGenerationTokens),
+			Enabled("instructionSuffix", request.InstructionSuffix != "", h.generationOverrides, "GENERATION_OVERRIDES_ENABLED"),
+			MaxLength("instructionSuffix", request.InstructionSuffix, aws.MaxInstructionSuffixLength),
+			Valid("sessionId", err),
+		}, request.Filters.rules()...)
+	})
+	return request, conversation, ok
+}
+
+// floatOrZero returns an optional number of the request, 0 when it was not sent
+func floatOrZero(value *float64) float64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+func (h *QuestionSearchHandler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	log := logger.WithContext(r.Context())
+	
+	if limitErr, ok := err.(*services.SessionLimitError); ok {
+		h.handleSessionLimitError(w, r, limitErr)
+		return
+	}
+
+	// Check if it's a BedrockError
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok {
+		switch bedrockErr.Code {
+		case bedrockErrors.ErrCodeValidation:
+			log.Warn("Validation error", map[string]interface{}{
+				"error": bedrockErr.Message,
+			})
+			BadRequestHandler(w, bedrockErr.Message)
+			return
+		case bedrockErrors.ErrCodeThrottling:
+			h.handleThrottlingError(w, r, bedrockErr.Message)
+			return
+		case bedrockErrors.ErrCodeEmbedding, bedrockErrors.ErrCodeKnowledgeBase:
+			// Check if it's a quota error
+			if strings.Contains(bedrockErr.Message, "quota") || strings.Contains(bedrockErr.Message, "Quota") {
+				h.handleQuotaError(w, r, bedrockErr.Message)
+				return
+			}
+			log.Error("Bedrock service error", map[string]interface{}{
+				"error_code": bedrockErr.Code,
+				"error":      bedrockErr.Message,
+			})
+			InternalServerErrorHandler(w, bedrockErr.Message)
+			return
+		case bedrockErrors.ErrCodeAWSService:
+			// Check if it's a quota error
+			if strings.Contains(bedrockErr.Message, "quota") || strings.Contains(bedrockErr.Message, "Quota") {
+				h.handleQuotaError(w, r, bedrockErr.Message)
+				return
+			}
+			log.Error("AWS service error", map[string]interface{}{
+				"error": bedrockErr.Message,
+			})
+			InternalServerErrorHandler(w, bedrockErr.Message)
+			return
+		}
+	}
+
+	// Default to internal server error
+	log.Error("Unhandled error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	InternalServerErrorHandler(w, "An error occurred processing your request")
+}
+
+func (h *QuestionSearchHandler) handleThrottlingError(w http.ResponseWriter, r *http.Request, message string) {
+	log := logger.WithContext(r.Context())
+	retryAfter := policy.FromContext(r.Context(), policy.Defaults(0)).RetryAfterSeconds
+	log.Warn("Request throttled", map[string]interface{}{
+		"error":       message,
+		"retry_after": retryAfter,
+	})
+
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 429,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func (h *QuestionSearchHandler) handleQuotaError(w http.ResponseWriter, r *http.Request, message string) {
+	log := logger.WithContext(r.Context())
+	log.Error("Quota exceeded", map[string]interface{}{
+		"error": message,
+	})
+
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 503,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func (h *QuestionSearchHandler) handleSessionLimitError(w http.ResponseWriter, r *http.Request, limitErr *services.SessionLimitError) {
+	response := ThrottleResponse{
+		Error:   "You are sending questions too quickly, please wait a moment and try again.",
+		ErrorTh: "คุณส่งคำถามบ่อยเกินไป กรุณารอสักครู่แล้วลองใหม่อีกครั้ง",
+		Status:  429,
+	}
+	if limitErr.Limit == services.SessionLimitTokens {
+		response.Error = "You have reached the usage limit for this session, please try again later."
+		response.ErrorTh = "คุณใช้งานครบโควตาของเซสชันนี้แล้ว กรุณาลองใหม่อีกครั้งภายหลัง"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(limitErr.RetryAfterSeconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleClarification answers with the clarification prompt, in the requested language or
+// the language of the question
+func (h *QuestionSearchHandler) handleClarification(w http.ResponseWriter, r *http.Request, request QuestionSearchRequest, clarification *services.ClarificationRequiredError) {
+	language := request.targetLanguage()
+	if language == "" {
+		language = utils.DetectLanguage(request.Question)
+	}
+
+	response := QuestionSearchResponse{
+		Answer:           clarification.Message,
+		RelatedDocuments: []string{},
+		SessionId:        request.SessionId,
+		Clarification: &Clarification{
+			Reason:      clarification.Reason,
+			Message:     clarification.Message,
+			MessageTh:   clarification.MessageTh,
+			Suggestions: clarification.Suggestions,
+		},
+	}
+	if language == utils.LanguageThai {
+		response.Answer = clarification.MessageTh
+	}
+	if request.targetLanguage() != "" {
+		response.Language = language
+	}
+
+	logger.WithContext(r.Context()).Info("Asked for clarification", map[string]interface{}{
+		"reason": clarification.Reason,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,478 +1,985 @@
-package routing
-
-import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"net/http"
-	"net/http/httptest"
-	"strings"
-	"testing"
-
-	"github.com/leanovate/gopter"
-	"github.com/leanovate/gopter/gen"
-	"github.com/leanovate/gopter/prop"
-)
-
-// Mock service for testing
-type mockQuestionSearchService struct {
-	searchAnswerFunc func(ctx context.Context, question string, enableRelateDocument bool) (string, error)
-	callCount        int
-}
-
-func (m *mockQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
-	m.callCount++
-	if m.searchAnswerFunc != nil {
-		answer, err := m.searchAnswerFunc(ctx, question, enableRelateDocument)
-		return answer, []string{}, err
-	}
-	return "mock answer", []string{}, nil
-}
-
-// Feature: bedrock-question-search, Property 1: Valid JSON requests are parsed successfully
-// Validates: Requirements 1.2, 7.5
-func TestValidJSONParsing_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("valid JSON with question field is parsed", prop.ForAll(
-		func(question string) bool {
-			mockService := &mockQuestionSearchService{
-				searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-					return "answer for " + q, nil
-				},
-			}
-
-			handler := NewQuestionSearchHandler(mockService, 1000)
-
-			requestBody := map[string]string{"question": question}
-			jsonBody, _ := json.Marshal(requestBody)
-
-			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-			req.Header.Set("Content-Type", "application/json")
-			w := httptest.NewRecorder()
-
-			handler.Handle(w, req)
-
-			// Should return 200 and service should be called
-			return w.Code == http.StatusOK && mockService.callCount == 1
-		},
-		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) <= 1000 }),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-// Feature: bedrock-question-search, Property 2: Invalid JSON returns 400 error
-// Validates: Requirements 1.4
-func TestInvalidJSONRejection_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("malformed JSON returns 400", prop.ForAll(
-		func(invalidJSON string) bool {
-			mockService := &mockQuestionSearchService{}
-			handler := NewQuestionSearchHandler(mockService, 1000)
-
-			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(invalidJSON))
-			req.Header.Set("Content-Type", "application/json")
-			w := httptest.NewRecorder()
-
-			handler.Handle(w, req)
-
-			// Should return 400 and service should not be called
-			return w.Code == http.StatusBadRequest && mockService.callCount == 0
-		},
-		gen.OneConstOf("{invalid", "not json", "{\"key\": }", "[1,2,3"),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-// Feature: bedrock-question-search, Property 3: Whitespace-only questions are rejected
-// Validates: Requirements 1.5
-func TestWhitespaceRejection_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("whitespace-only questions return 400", prop.ForAll(
-		func(whitespaceCount int) bool {
-			mockService := &mockQuestionSearchService{}
-			handler := NewQuestionSearchHandler(mockService, 1000)
-
-			// Generate whitespace-only string
-			whitespace := strings.Repeat(" ", whitespaceCount) + strings.Repeat("\t", whitespaceCount/2)
-			requestBody := map[string]string{"question": whitespace}
-			jsonBody, _ := json.Marshal(requestBody)
-
-			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-			req.Header.Set("Content-Type", "application/json")
-			w := httptest.NewRecorder()
-
-			handler.Handle(w, req)
-
-			// Should return 400 and service should not be called
-			return w.Code == http.StatusBadRequest && mockService.callCount == 0
-		},
-		gen.IntRange(1, 20),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-// Feature: bedrock-question-search, Property 9: Validation failures prevent AWS calls
-// Validates: Requirements 7.1, 7.4
-func TestValidationPreventsAWSCalls_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("invalid requests don't call service", prop.ForAll(
-		func(testCase int) bool {
-			mockService := &mockQuestionSearchService{}
-			handler := NewQuestionSearchHandler(mockService, 100)
-
-			var req *http.Request
-
-			switch testCase % 4 {
-			case 0: // Missing question field
-				jsonBody, _ := json.Marshal(map[string]string{})
-				req = httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-			case 1: // Empty question
-				jsonBody, _ := json.Marshal(map[string]string{"question": ""})
-				req = httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-			case 2: // Whitespace-only
-				jsonBody, _ := json.Marshal(map[string]string{"question": "   "})
-				req = httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-			case 3: // Exceeds length
-				longQuestion := strings.Repeat("a", 200)
-				jsonBody, _ := json.Marshal(map[string]string{"question": longQuestion})
-				req = httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-			}
-
-			req.Header.Set("Content-Type", "application/json")
-			w := httptest.NewRecorder()
-
-			handler.Handle(w, req)
-
-			// Service should never be called for invalid requests
-			return mockService.callCount == 0
-		},
-		gen.IntRange(0, 100),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-// Feature: bedrock-question-search, Property 8: Successful responses have valid JSON structure
-// Validates: Requirements 4.1, 4.2, 4.5
-func TestJSONResponseValidity_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("successful responses have valid JSON with answer field", prop.ForAll(
-		func(answer string) bool {
-			mockService := &mockQuestionSearchService{
-				searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-					return answer, nil
-				},
-			}
-
-			handler := NewQuestionSearchHandler(mockService, 1000)
-
-			requestBody := map[string]string{"question": "test question"}
-			jsonBody, _ := json.Marshal(requestBody)
-
-			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-			req.Header.Set("Content-Type", "application/json")
-			w := httptest.NewRecorder()
-
-			handler.Handle(w, req)
-
-			// Check Content-Type header
-			if w.Header().Get("Content-Type") != "application/json" {
-				return false
-			}
-
-			// Check response is valid JSON
-			var response QuestionSearchResponse
-			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
-				return false
-			}
-
-			// Check answer field matches
-			return response.Answer == answer
-		},
-		gen.AlphaString(),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-// Unit tests for handler
-func TestHandler_ValidRequest(t *testing.T) {
-	mockService := &mockQuestionSearchService{
-		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-			return "This is the answer", nil
-		},
-	}
-
-	handler := NewQuestionSearchHandler(mockService, 1000)
-
-	requestBody := map[string]string{"question": "What is the question?"}
-	jsonBody, _ := json.Marshal(requestBody)
-
-	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	handler.Handle(w, req)
-
-	if w.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", w.Code)
-	}
-
-	var response QuestionSearchResponse
-	json.Unmarshal(w.Body.Bytes(), &response)
-
-	if response.Answer != "This is the answer" {
-		t.Fatalf("expected 'This is the answer', got '%s'", response.Answer)
-	}
-}
-
-func TestHandler_MissingQuestion(t *testing.T) {
-	mockService := &mockQuestionSearchService{}
-	handler := NewQuestionSearchHandler(mockService, 1000)
-
-	requestBody := map[string]string{}
-	jsonBody, _ := json.Marshal(requestBody)
-
-	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	handler.Handle(w, req)
-
-	if w.Code != http.StatusBadRequest {
-		t.Fatalf("expected status 400, got %d", w.Code)
-	}
-}
-
-func TestHandler_EmptyQuestion(t *testing.T) {
-	mockService := &mockQuestionSearchService{}
-	handler := NewQuestionSearchHandler(mockService, 1000)
-
-	requestBody := map[string]string{"question": ""}
-	jsonBody, _ := json.Marshal(requestBody)
-
-	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	handler.Handle(w, req)
-
-	if w.Code != http.StatusBadRequest {
-		t.Fatalf("expected status 400, got %d", w.Code)
-	}
-}
-
-func TestHandler_WhitespaceOnlyQuestion(t *testing.T) {
-	mockService := &mockQuestionSearchService{}
-	handler := NewQuestionSearchHandler(mockService, 1000)
-
-	requestBody := map[string]string{"question": "   \t\n  "}
-	jsonBody, _ := json.Marshal(requestBody)
-
-	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	handler.Handle(w, req)
-
-	if w.Code != http.StatusBadRequest {
-		t.Fatalf("expected status 400, got %d", w.Code)
-	}
-}
-
-func TestHandler_QuestionExceedsMaxLength(t *testing.T) {
-	mockService := &mockQuestionSearchService{}
-	handler := NewQuestionSearchHandler(mockService, 100)
-
-	longQuestion := strings.Repeat("a", 150)
-	requestBody := map[string]string{"question": longQuestion}
-	jsonBody, _ := json.Marshal(requestBody)
-
-	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	handler.Handle(w, req)
-
-	if w.Code != http.StatusBadRequest {
-		t.Fatalf("expected status 400, got %d", w.Code)
-	}
-}
-
-func TestHandler_InvalidContentType(t *testing.T) {
-	mockService := &mockQuestionSearchService{}
-	handler := NewQuestionSearchHandler(mockService, 1000)
-
-	requestBody := map[string]string{"question": "test"}
-	jsonBody, _ := json.Marshal(requestBody)
-
-	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-	req.Header.Set("Content-Type", "text/plain")
-	w := httptest.NewRecorder()
-
-	handler.Handle(w, req)
-
-	if w.Code != http.StatusBadRequest {
-		t.Fatalf("expected status 400, got %d", w.Code)
-	}
-}
-
-func TestHandler_MalformedJSON(t *testing.T) {
-	mockService := &mockQuestionSearchService{}
-	handler := NewQuestionSearchHandler(mockService, 1000)
-
-	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader("{invalid json"))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	handler.Handle(w, req)
-
-	if w.Code != http.StatusBadRequest {
-		t.Fatalf("expected status 400, got %d", w.Code)
-	}
-}
-
-func TestHandler_EmptyAnswer(t *testing.T) {
-	mockService := &mockQuestionSearchService{
-		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-			return "", nil
-		},
-	}
-
-	handler := NewQuestionSearchHandler(mockService, 1000)
-
-	requestBody := map[string]string{"question": "test question"}
-	jsonBody, _ := json.Marshal(requestBody)
-
-	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	handler.Handle(w, req)
-
-	if w.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", w.Code)
-	}
-
-	var response QuestionSearchResponse
-	json.Unmarshal(w.Body.Bytes(), &response)
-
-	if response.Answer != "" {
-		t.Fatalf("expected empty answer, got '%s'", response.Answer)
-	}
-}
-
-// Feature: bedrock-question-search, Property 14: Throttling events are logged
-// Validates: Requirements 8.3
-func TestThrottlingLogging_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("throttling errors return 429 status", prop.ForAll(
-		func(errorMsg string) bool {
-			mockService := &mockQuestionSearchService{
-				searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-					return "", &BedrockError{
-						Code:    "THROTTLING_ERROR",
-						Message: errorMsg,
-					}
-				},
-			}
-
-			handler := NewQuestionSearchHandler(mockService, 1000)
-
-			requestBody := map[string]string{"question": "test question"}
-			jsonBody, _ := json.Marshal(requestBody)
-
-			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-			req.Header.Set("Content-Type", "application/json")
-			w := httptest.NewRecorder()
-
-			handler.Handle(w, req)
-
-			// Should return 429 and have Retry-After header
-			return w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != ""
-		},
-		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 }),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-// Mock BedrockError for testing
-type BedrockError struct {
-	Code    string
-	Message string
-	Cause   error
-}
-
-func (e *BedrockError) Error() string {
-	if e.Cause != nil {
-		return e.Message + ": " + e.Cause.Error()
-	}
-	return e.Message
-}
-
-// Unit tests for throttling and quota handling
-func TestHandler_ThrottlingError(t *testing.T) {
-	mockService := &mockQuestionSearchService{
-		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-			return "", &BedrockError{
-				Code:    "THROTTLING_ERROR",
-				Message: "throttled",
-			}
-		},
-	}
-
-	handler := NewQuestionSearchHandler(mockService, 1000)
-
-	requestBody := map[string]string{"question": "test question"}
-	jsonBody, _ := json.Marshal(requestBody)
-
-	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	handler.Handle(w, req)
-
-	if w.Code != http.StatusTooManyRequests {
-		t.Fatalf("expected status 429, got %d", w.Code)
-	}
-
-	if w.Header().Get("Retry-After") == "" {
-		t.Fatal("expected Retry-After header")
-	}
-}
-
-func TestHandler_QuotaError(t *testing.T) {
-	mockService := &mockQuestionSearchService{
-		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-			return "", &BedrockError{
-				Code:    "AWS_SERVICE_ERROR",
-				Message: "AWS quota exceeded",
-			}
-		},
-	}
-
-	handler := NewQuestionSearchHandler(mockService, 1000)
-
-	requestBody := map[string]string{"question": "test question"}
-	jsonBody, _ := json.Marshal(requestBody)
-
-	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	handler.Handle(w, req)
-
-	if w.Code != http.StatusServiceUnavailable {
-		t.Fatalf("expected status 503, got %d", w.Code)
-	}
-}
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/flags"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+	"teletubpax-api/warnings"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+)
+
+// Mock service for testing
+type mockQuestionSearchService struct {
+	searchAnswerFunc func(ctx context.Context, question string, enableRelateDocument bool) (string, error)
+	relatedDocuments []string
+	callCount        int
+}
+
+func (m *mockQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	m.callCount++
+	relatedDocuments := m.relatedDocuments
+	if relatedDocuments == nil {
+		relatedDocuments = []string{}
+	}
+	if m.searchAnswerFunc != nil {
+		answer, err := m.searchAnswerFunc(ctx, question, enableRelateDocument)
+		return answer, relatedDocuments, err
+	}
+	return "mock answer", relatedDocuments, nil
+}
+
+// Feature: bedrock-question-search, Property 1: Valid JSON requests are parsed successfully
+// Validates: Requirements 1.2, 7.5
+func TestValidJSONParsing_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("valid JSON with question field is parsed", prop.ForAll(
+		func(question string) bool {
+			mockService := &mockQuestionSearchService{
+				searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+					return "answer for " + q, nil
+				},
+			}
+
+			handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+			requestBody := map[string]string{"question": question}
+			jsonBody, _ := json.Marshal(requestBody)
+
+			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Handle(w, req)
+
+			// Should return 200 and service should be called
+			return w.Code == http.StatusOK && mockService.callCount == 1
+		},
+		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) <= 1000 }),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// Feature: bedrock-question-search, Property 2: Invalid JSON returns 400 error
+// Validates: Requirements 1.4
+func TestInvalidJSONRejection_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("malformed JSON returns 400", prop.ForAll(
+		func(invalidJSON string) bool {
+			mockService := &mockQuestionSearchService{}
+			handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(invalidJSON))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Handle(w, req)
+
+			// Should return 400 and service should not be called
+			return w.Code == http.StatusBadRequest && mockService.callCount == 0
+		},
+		gen.OneConstOf("{invalid", "not json", "{\"key\": }", "[1,2,3"),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// Feature: bedrock-question-search, Property 3: Whitespace-only questions are rejected
+// Validates: Requirements 1.5
+func TestWhitespaceRejection_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("whitespace-only questions return 400", prop.ForAll(
+		func(whitespaceCount int) bool {
+			mockService := &mockQuestionSearchService{}
+			handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+			// Generate whitespace-only string
+			whitespace := strings.Repeat(" ", whitespaceCount) + strings.Repeat("\t", whitespaceCount/2)
+			requestBody := map[string]string{"question": whitespace}
+			jsonBody, _ := json.Marshal(requestBody)
+
+			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Handle(w, req)
+
+			// Should return 400 and service should not be called
+			return w.Code == http.StatusBadRequest && mockService.callCount == 0
+		},
+		gen.IntRange(1, 20),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// Feature: bedrock-question-search, Property 9: Validation failures prevent AWS calls
+// Validates: Requirements 7.1, 7.4
+func TestValidationPreventsAWSCalls_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("invalid requests don't call service", prop.ForAll(
+		func(testCase int) bool {
+			mockService := &mockQuestionSearchService{}
+			handler := NewQuestionSearchHandler(mockService, nil, nil, 100, false)
+
+			var req *http.Request
+
+			switch testCase % 4 {
+			case 0: // Missing question field
+				jsonBody, _ := json.Marshal(map[string]string{})
+				req = httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+			case 1: // Empty question
+				jsonBody, _ := json.Marshal(map[string]string{"question": ""})
+				req = httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+			case 2: // Whitespace-only
+				jsonBody, _ := json.Marshal(map[string]string{"question": "   "})
+				req = httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+			case 3: // Exceeds length
+				longQuestion := strings.Repeat("a", 200)
+				jsonBody, _ := json.Marshal(map[string]string{"question": longQuestion})
+				req = httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+			}
+
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Handle(w, req)
+
+			// Service should never be called for invalid requests
+			return mockService.callCount == 0
+		},
+		gen.IntRange(0, 100),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// Feature: bedrock-question-search, Property 8: Successful responses have valid JSON structure
+// Validates: Requirements 4.1, 4.2, 4.5
+func TestJSONResponseValidity_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("successful responses have valid JSON with answer field", prop.ForAll(
+		func(answer string) bool {
+			mockService := &mockQuestionSearchService{
+				searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+					return answer, nil
+				},
+			}
+
+			handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+			requestBody := map[string]string{"question": "test question"}
+			jsonBody, _ := json.Marshal(requestBody)
+
+			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Handle(w, req)
+
+			// Check Content-Type header
+			if w.Header().Get("Content-Type") != "application/json" {
+				return false
+			}
+
+			// Check response is valid JSON
+			var response QuestionSearchResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				return false
+			}
+
+			// Check answer field matches
+			return response.Answer == answer
+		},
+		gen.AlphaString(),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// Unit tests for handler
+func TestHandler_ValidRequest(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "This is the answer", nil
+		},
+	}
+
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	requestBody := map[string]string{"question": "What is the question?"}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	if response.Answer != "This is the answer" {
+		t.Fatalf("expected 'This is the answer', got '%s'", response.Answer)
+	}
+}
+
+func TestHandler_MissingQuestion(t *testing.T) {
+	mockService := &mockQuestionSearchService{}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	requestBody := map[string]string{}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestHandler_EmptyQuestion(t *testing.T) {
+	mockService := &mockQuestionSearchService{}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	requestBody := map[string]string{"question": ""}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestHandler_WhitespaceOnlyQuestion(t *testing.T) {
+	mockService := &mockQuestionSearchService{}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	requestBody := map[string]string{"question": "   \t\n  "}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestHandler_QuestionExceedsMaxLength(t *testing.T) {
+	mockService := &mockQuestionSearchService{}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 100, false)
+
+	longQuestion := strings.Repeat("a", 150)
+	requestBody := map[string]string{"question": longQuestion}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestHandler_InvalidContentType(t *testing.T) {
+	mockService := &mockQuestionSearchService{}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	requestBody := map[string]string{"question": "test"}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestHandler_MalformedJSON(t *testing.T) {
+	mockService := &mockQuestionSearchService{}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader("{invalid json"))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestHandler_EmptyAnswer(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "", nil
+		},
+	}
+
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	requestBody := map[string]string{"question": "test question"}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	if response.Answer != "" {
+		t.Fatalf("expected empty answer, got '%s'", response.Answer)
+	}
+}
+
+// Feature: bedrock-question-search, Property 14: Throttling events are logged
+// Validates: Requirements 8.3
+func TestThrottlingLogging_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("throttling errors return 429 status", prop.ForAll(
+		func(errorMsg string) bool {
+			mockService := &mockQuestionSearchService{
+				searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+					return "", &BedrockError{
+						Code:    "THROTTLING_ERROR",
+						Message: errorMsg,
+					}
+				},
+			}
+
+			handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+			requestBody := map[string]string{"question": "test question"}
+			jsonBody, _ := json.Marshal(requestBody)
+
+			req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Handle(w, req)
+
+			// Should return 429 and have Retry-After header
+			return w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != ""
+		},
+		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 }),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// BedrockError aliases the real error type so the handler's type checks apply
+type BedrockError = bedrockErrors.BedrockError
+
+// Unit tests for throttling and quota handling
+func TestHandler_ThrottlingError(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "", &BedrockError{
+				Code:    "THROTTLING_ERROR",
+				Message: "throttled",
+			}
+		},
+	}
+
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	requestBody := map[string]string{"question": "test question"}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", w.Code)
+	}
+
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header")
+	}
+}
+
+func TestHandler_QuotaError(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "", &BedrockError{
+				Code:    "AWS_SERVICE_ERROR",
+				Message: "AWS quota exceeded",
+			}
+		},
+	}
+
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	requestBody := map[string]string{"question": "test question"}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", w.Code)
+	}
+}
+
+type mockTranslationService struct{}
+
+func (m *mockTranslationService) Translate(ctx context.Context, text string, targetLanguage string) (*services.TranslatedText, error) {
+	return &services.TranslatedText{Text: "translated answer", Language: targetLanguage, SourceText: text}, nil
+}
+
+func (m *mockTranslationService) TranslateAll(ctx context.Context, texts []string, targetLanguage string) []services.TranslatedText {
+	return nil
+}
+
+func TestQuestionSearchHandler_TranslatesAnswerToRequestedLanguage(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "คำตอบภาษาไทย", nil
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, &mockTranslationService{}, nil, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?","language":"en"}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK || response.Answer != "translated answer" || response.SourceText != "คำตอบภาษาไทย" || response.Language != "en" {
+		t.Fatalf("unexpected response %d %+v", w.Code, response)
+	}
+
+	req = httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?","language":"jp"}`))
+	w = httptest.NewRecorder()
+	handler.Handle(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unsupported language, got %d", w.Code)
+	}
+}
+
+func TestQuestionSearchHandler_TranslatesAnswerToTargetLanguage(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "คำตอบภาษาไทย", nil
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, &mockTranslationService{}, nil, 1000, false)
+
+	// targetLanguage takes precedence over the deprecated language field
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"ค่าธรรมเนียม","targetLanguage":"en","language":"th"}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK || response.Answer != "translated answer" || response.SourceText != "คำตอบภาษาไทย" || response.Language != "en" {
+		t.Fatalf("unexpected response %d %+v", w.Code, response)
+	}
+
+	req = httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?","targetLanguage":"jp"}`))
+	w = httptest.NewRecorder()
+	handler.Handle(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unsupported target language, got %d", w.Code)
+	}
+}
+
+func TestQuestionSearchHandler_SessionLimitReturnsPoliteThrottle(t *testing.T) {
+	var sessionId string
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			sessionId = services.SessionIdFromContext(ctx)
+			return "", &services.SessionLimitError{Limit: services.SessionLimitQuestions, RetryAfterSeconds: 42}
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?"}`))
+	req.Header.Set("X-Session-Id", "widget-123")
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	var response ThrottleResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "42" || response.ErrorTh == "" {
+		t.Fatalf("unexpected response %d %q %+v", w.Code, w.Header().Get("Retry-After"), response)
+	}
+	if sessionId != "id:widget-123" {
+		t.Errorf("expected session from X-Session-Id, got %q", sessionId)
+	}
+}
+
+func TestQuestionSearchHandler_ReturnsPipelineWarnings(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			warnings.Add(ctx, warnings.CodeKnowledgeBaseSkipped, "Knowledge base KB2 skipped due to timeout, the answer may be incomplete")
+			warnings.Add(ctx, warnings.CodeKnowledgeBaseSkipped, "Knowledge base KB2 skipped due to timeout, the answer may be incomplete")
+			return "คำตอบภาษาไทย", nil
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?","language":"en"}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK || len(response.Warnings) != 2 {
+		t.Fatalf("expected the skipped knowledge base and the missing translation, got %d %+v", w.Code, response.Warnings)
+	}
+	if response.Warnings[0].Code != warnings.CodeKnowledgeBaseSkipped || response.Warnings[1].Code != warnings.CodeTranslationFailed {
+		t.Errorf("unexpected warnings %+v", response.Warnings)
+	}
+
+	// A healthy response has no warnings field
+	req = httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?"}`))
+	w = httptest.NewRecorder()
+	NewQuestionSearchHandler(&mockQuestionSearchService{}, nil, nil, 1000, false).Handle(w, req)
+	if strings.Contains(w.Body.String(), "warnings") {
+		t.Errorf("expected no warnings field, got %s", w.Body.String())
+	}
+}
+
+func TestQuestionSearchHandler_ReturnsClarification(t *testing.T) {
+	flags.Initialize(flags.New(time.Hour, flags.NewEnvSource(services.ClarificationFlag)))
+	defer flags.Initialize(nil)
+
+	mockService := &mockQuestionSearchService{}
+	service, err := services.NewClarifyingQuestionSearchService(mockService, &config.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := NewQuestionSearchHandler(service, nil, nil, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"สินเชื่อดอกเบี้ยเท่าไหร่"}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK || response.Clarification == nil || mockService.callCount != 0 {
+		t.Fatalf("expected a clarification response, got %d %s", w.Code, w.Body.String())
+	}
+	if response.Answer != response.Clarification.MessageTh || response.Clarification.Reason != services.ClarificationBroad || len(response.Clarification.Suggestions) != 3 {
+		t.Errorf("expected the Thai prompt with suggestions, got %+v", response)
+	}
+
+	// The user can insist on an answer to the question as asked
+	req = httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"สินเชื่อดอกเบี้ยเท่าไหร่","skipClarification":true}`))
+	w = httptest.NewRecorder()
+	handler.Handle(w, req)
+	if w.Code != http.StatusOK || mockService.callCount != 1 || strings.Contains(w.Body.String(), "clarification") {
+		t.Errorf("expected an answer without clarification, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestQuestionSearchHandler_AddsDisclaimerAfterTranslation(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "คำตอบภาษาไทย", nil
+		},
+	}
+	disclaimers, _ := services.NewAnswerDisclaimers(&config.Config{
+		DisclaimerTenants:   `{"branch-app": "ข้อมูลนี้ใช้สำหรับพนักงานภายในเท่านั้น"}`,
+		DisclaimerPlacement: services.DisclaimerPlacementField,
+	})
+	handler := NewQuestionSearchHandler(mockService, &mockTranslationService{}, disclaimers, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?","language":"en"}`))
+	req.Header.Set("X-Tenant-Id", "branch-app")
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Answer != "translated answer" || response.Disclaimer != "ข้อมูลนี้ใช้สำหรับพนักงานภายในเท่านั้น" {
+		t.Fatalf("expected the untranslated disclaimer as a separate field, got %+v", response)
+	}
+}
+
+func TestQuestionSearchHandler_ContinuesConversation(t *testing.T) {
+	var received *aws.Conversation
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			received = aws.ConversationFromContext(ctx)
+			return "answer", nil
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	// A session ID from an earlier answer is passed on and returned unchanged when no
+	// knowledge base session was started
+	previous := "eyJLQjEiOiJzZXNzaW9uLTEifQ"
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"and for students?","sessionId":"`+previous+`"}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK || received == nil || response.SessionId != previous {
+		t.Fatalf("expected the conversation to be continued, got %d %+v", w.Code, response)
+	}
+
+	req = httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?","sessionId":"not a session"}`))
+	w = httptest.NewRecorder()
+	handler.Handle(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "sessionId is invalid") {
+		t.Fatalf("expected 400 for an invalid session ID, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestQuestionSearchHandler_ReportsAnswerCache(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "answer", nil
+		},
+	}
+	cached := services.NewCachingQuestionSearchService(mockService, storage.NewMemoryAnswerCache(10), &config.Config{AnswerCacheTTLSeconds: 60})
+	handler := NewQuestionSearchHandler(cached, nil, nil, 1000, false)
+
+	ask := func(cacheControl string) string {
+		req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?"}`))
+		if cacheControl != "" {
+			req.Header.Set("Cache-Control", cacheControl)
+		}
+		w := httptest.NewRecorder()
+		handler.Handle(w, req)
+		return w.Header().Get("X-Answer-Cache")
+	}
+
+	if status := ask(""); status != services.AnswerCacheMiss {
+		t.Errorf("expected a miss for the first question, got %q", status)
+	}
+	if status := ask(""); status != services.AnswerCacheHit {
+		t.Errorf("expected a hit for the repeated question, got %q", status)
+	}
+	if status := ask("no-cache"); status != services.AnswerCacheBypass {
+		t.Errorf("expected Cache-Control: no-cache to bypass the cache, got %q", status)
+	}
+}
+
+func TestQuestionSearchHandler_PassesRetrievalFilters(t *testing.T) {
+	var filter *aws.MetadataFilter
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			filter = aws.MetadataFilterFromContext(ctx)
+			return "answer", nil
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	body := `{"question": "ค่าธรรมเนียมโอนเงิน", "filters": {"department": ["retail"], "effectiveFrom": "2024-01-01"}}`
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if filter.Key() != "department=retail;effective_date>=20240101" {
+		t.Errorf("expected the filters on the context, got %q", filter.Key())
+	}
+
+	for _, invalid := range []string{
+		`{"question": "q", "filters": {"effectiveFrom": "01/02/2024"}}`,
+		`{"question": "q", "filters": {"effectiveFrom": "2024-06-01", "effectiveTo": "2024-01-01"}}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(invalid))
+		w := httptest.NewRecorder()
+		handler.Handle(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", invalid, w.Code)
+		}
+	}
+	if mockService.callCount != 1 {
+		t.Errorf("expected invalid filters to be rejected before the service, got %d calls", mockService.callCount)
+	}
+}
+
+func TestQuestionSearchHandler_PassesSearchType(t *testing.T) {
+	var searchType string
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			searchType = aws.SearchTypeFromContext(ctx)
+			return "answer", nil
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question": "เงื่อนไข WAIVE-03", "searchType": "HYBRID"}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if searchType != aws.SearchTypeHybrid {
+		t.Errorf("expected the search type on the context, got %q", searchType)
+	}
+
+	req = httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question": "q", "searchType": "KEYWORD"}`))
+	w = httptest.NewRecorder()
+	handler.Handle(w, req)
+	if w.Code != http.StatusBadRequest || mockService.callCount != 1 {
+		t.Errorf("expected an unknown search type to be rejected, got %d after %d calls", w.Code, mockService.callCount)
+	}
+}
+
+func TestQuestionSearchHandler_PassesRetrievalSettings(t *testing.T) {
+	var settings aws.RetrievalSettings
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			settings = aws.RetrievalSettingsFromContext(ctx)
+			return "answer", nil
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question": "ค่าธรรมเนียมรายปี", "numberOfResults": 10, "minScore": 0.4}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if settings.NumberOfResults != 10 || settings.MinScore == nil || *settings.MinScore != 0.4 {
+		t.Errorf("expected the retrieval settings on the context, got %+v", settings)
+	}
+
+	for _, invalid := range []string{
+		`{"question": "q", "numberOfResults": 101}`,
+		`{"question": "q", "minScore": 1.5}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(invalid))
+		w := httptest.NewRecorder()
+		handler.Handle(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", invalid, w.Code)
+		}
+	}
+}
+
+func TestQuestionSearchHandler_PassesGenerationSettings(t *testing.T) {
+	var settings aws.GenerationSettings
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			settings = aws.GenerationSettingsFromContext(ctx)
+			return "answer", nil
+		},
+	}
+	body := `{"question": "ค่าธรรมเนียมรายปี", "temperature": 0, "maxTokens": 500, "instructionSuffix": " Answer in one sentence. "}`
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	NewQuestionSearchHandler(mockService, nil, nil, 1000, false).Handle(w, req)
+	if w.Code != http.StatusBadRequest || mockService.callCount != 0 {
+		t.Fatalf("expected overrides to be rejected while disabled, got %d after %d calls", w.Code, mockService.callCount)
+	}
+
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, true)
+	req = httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	handler.Handle(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if settings.Temperature == nil || *settings.Temperature != 0 || settings.MaxTokens != 500 || settings.InstructionSuffix != "Answer in one sentence." {
+		t.Errorf("expected the generation settings on the context, got %+v", settings)
+	}
+
+	for _, invalid := range []string{
+		`{"question": "q", "temperature": 1.5}`,
+		`{"question": "q", "maxTokens": 5000}`,
+		`{"question": "q", "instructionSuffix": "` + strings.Repeat("a", aws.MaxInstructionSuffixLength+1) + `"}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(invalid))
+		w := httptest.NewRecorder()
+		handler.Handle(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %.60s, got %d", invalid, w.Code)
+		}
+	}
+}
+
+func TestQuestionSearchHandler_NormalizesQuestion(t *testing.T) {
+	var question string
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			question = q
+			return "answer", nil
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	body := `{"question": " \u0e40\u0e40ก้ไข\u200bบัตร "}`
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if question != "แก้ไขบัตร" {
+		t.Errorf("expected the normalized question, got %q", question)
+	}
+
+	// A question of only zero-width characters is empty
+	req = httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question": "\u200b\u200b"}`))
+	w = httptest.NewRecorder()
+	handler.Handle(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invisible question, got %d", w.Code)
+	}
+}
+
+func TestQuestionSearchHandler_ReturnsConfidence(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			aws.RecordConfidence(ctx, 0.35, true)
+			return "คำตอบ", nil
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?"}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Confidence == nil || *response.Confidence != 0.35 {
+		t.Fatalf("expected a confidence of 0.35, got %s", w.Body.String())
+	}
+
+	// Answers that were not scored have no confidence field
+	req = httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?"}`))
+	w = httptest.NewRecorder()
+	NewQuestionSearchHandler(&mockQuestionSearchService{}, nil, nil, 1000, false).Handle(w, req)
+	if strings.Contains(w.Body.String(), "confidence") {
+		t.Errorf("expected no confidence field, got %s", w.Body.String())
+	}
+}
+
+func TestQuestionSearchHandler_ReturnsCitations(t *testing.T) {
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			aws.RecordCitations(ctx, []aws.Citation{{
+				Text:    "ค่าธรรมเนียม 200 บาท",
+				Sources: []aws.CitationSource{{DocumentUrl: "https://docs.s3.ap-southeast-1.amazonaws.com/fees.pdf", PageNumber: 3, Excerpt: "ค่าธรรมเนียมรายปี 200 บาท"}},
+			}})
+			return "บัตรเดบิต: ค่าธรรมเนียม 200 บาท", nil
+		},
+	}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", strings.NewReader(`{"question":"fee?"}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if len(response.Citations) != 1 || len(response.Citations[0].Sources) != 1 {
+		t.Fatalf("expected one citation, got %s", w.Body.String())
+	}
+	if span := response.Citations[0].Span; span == nil || *span != (aws.CitationSpan{Start: 11, End: 31}) {
+		t.Errorf("expected the span of the cited text in the answer, got %+v", span)
+	}
+}
+
+func TestQuestionSearchHandler_ReturnsRelatedPassages(t *testing.T) {
+	fees := "https://docs.s3.ap-southeast-1.amazonaws.com/fees.pdf"
+	mockService := &mockQuestionSearchService{
+		searchAnswerFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			aws.RecordCitations(ctx, []aws.Citation{
+				{Text: "ค่าธรรมเนียม 200 บาท", Sources: []aws.CitationSource{
+					{DocumentUrl: fees + "?X-Amz-Signature=first", PageNumber: 3, ChunkId: "c1", Excerpt: "ค่าธรรมเนียมรายปี 200 บาท"},
+				}},
+				{Text: "ยกเว้นปีแรก", Sources: []aws.CitationSource{
+					{DocumentUrl: fees + "?X-Amz-Signature=second", PageNumber: 3, ChunkId: "c1", Excerpt: "ค่าธรรมเนียมรายปี 200 บาท"},
+					{DocumentUrl: fees + "?X-Amz-Signature=second", PageNumber: 4, ChunkId: "c2", Excerpt: "ยกเว้นค่าธรรมเนียมปีแรก"},
+				}},
+			})
+			return "ค่าธรรมเนียม 200 บาท ยกเว้นปีแรก", nil
+		},
+		relatedDocuments: []string{fees + "?X-Amz-Signature=listed", "https://docs.s3.ap-southeast-1.amazonaws.com/other.pdf"},
+	}
+	handler := NewQuestionSearchHandler(mockService, nil, nil, 1000, false)
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search?enableRelateDocument=true", strings.NewReader(`{"question":"fee?"}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	var response QuestionSearchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if len(response.RelatedPassages) != 2 {
+		t.Fatalf("expected the two cited chunks once each, got %s", w.Body.String())
+	}
+	for _, passage := range response.RelatedPassages {
+		if passage.DocumentUrl != fees+"?X-Amz-Signature=listed" {
+			t.Errorf("expected passages under the related document link, got %+v", passage)
+		}
+	}
+	if response.RelatedPassages[1].PageNumber != 4 || response.RelatedPassages[1].Text != "ยกเว้นค่าธรรมเนียมปีแรก" {
+		t.Errorf("unexpected second passage %+v", response.RelatedPassages[1])
+	}
+}
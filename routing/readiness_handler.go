@@ -0,0 +1,75 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"teletubpax-api/logger"
+)
+
+const (
+	livenessPath  = "/livez"
+	readinessPath = "/readyz"
+
+	// readinessTimeout bounds all checks of one probe, probes time out after a few seconds
+	readinessTimeout = 2 * time.Second
+)
+
+// ReadinessCheck is one condition the service needs to take traffic. Check returns nil when
+// it holds.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type ReadinessResponse struct {
+	Status string            `json:"status"` // "ready" or "not ready"
+	Checks map[string]string `json:"checks"` // "ok", or why the check failed
+}
+
+// LivenessHandler answers 200 while the process serves requests. It checks nothing else,
+// so a failing dependency takes the instance out of rotation through /readyz instead of
+// restarting it.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{Message: "alive", Status: 200})
+}
+
+// ReadinessHandler answers 200 when every check passes and 503 otherwise, listing the result
+// of each check
+type ReadinessHandler struct {
+	checks []ReadinessCheck
+}
+
+func NewReadinessHandler(checks []ReadinessCheck) *ReadinessHandler {
+	return &ReadinessHandler{checks: checks}
+}
+
+func (h *ReadinessHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	response := ReadinessResponse{Status: "ready", Checks: map[string]string{}}
+	status := http.StatusOK
+	for _, check := range h.checks {
+		if err := check.Check(ctx); err != nil {
+			logger.WithContext(r.Context()).Warn("Readiness check failed", map[string]interface{}{
+				"check": check.Name,
+				"error": err.Error(),
+			})
+			response.Checks[check.Name] = err.Error()
+			response.Status = "not ready"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		response.Checks[check.Name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
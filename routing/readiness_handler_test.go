@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/config"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	credentialsErr := errors.New("no EC2 IMDS role found")
+	tests := []struct {
+		name           string
+		checks         []ReadinessCheck
+		expectedCode   int
+		expectedChecks map[string]string
+	}{
+		{
+			name:           "every check passes",
+			checks:         []ReadinessCheck{{Name: "config", Check: func(context.Context) error { return nil }}},
+			expectedCode:   http.StatusOK,
+			expectedChecks: map[string]string{"config": "ok"},
+		},
+		{
+			name: "a check fails",
+			checks: []ReadinessCheck{
+				{Name: "config", Check: func(context.Context) error { return nil }},
+				{Name: "aws_credentials", Check: func(context.Context) error { return credentialsErr }},
+			},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"config": "ok", "aws_credentials": credentialsErr.Error()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			NewReadinessHandler(tt.checks).Handle(w, httptest.NewRequest("GET", readinessPath, nil))
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("expected status %d, got %d", tt.expectedCode, w.Code)
+			}
+			var response ReadinessResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			for name, result := range tt.expectedChecks {
+				if response.Checks[name] != result {
+					t.Errorf("expected check %s to be %q, got %q", name, result, response.Checks[name])
+				}
+			}
+		})
+	}
+}
+
+func TestProbeRoutes(t *testing.T) {
+	router := SetupRoutes(RouteServices{}, &config.Config{
+		MaxQuestionLength: 1000,
+		MaintenanceMode:   config.NewMaintenanceMode(config.MaintenanceStatus{Enabled: true}),
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/livez", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected /livez to answer 200 during maintenance, got %d", w.Code)
+	}
+
+	// The router has no services, so it is alive but not ready
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	var response ReadinessResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusServiceUnavailable || response.Checks["services"] == "ok" {
+		t.Errorf("expected /readyz to answer 503 without services, got %d %v", w.Code, response.Checks)
+	}
+}
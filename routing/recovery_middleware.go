@@ -0,0 +1,47 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"teletubpax-api/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// RecoveryMiddleware turns a panic in a handler or service into a 500, logging the panic and
+// its stack trace with the request ID, so one bad request does not fail the whole Lambda
+// invocation or kill the connection. Panics in goroutines a service starts are not caught.
+func RecoveryMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusResponseWriter{ResponseWriter: w}
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// The server aborts the response on this sentinel, it is not a failure
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				logger.WithContext(r.Context()).Error("Recovered from panic", map[string]interface{}{
+					"panic":      fmt.Sprint(recovered),
+					"stack":      string(debug.Stack()),
+					"request_id": RequestIdFromContext(r.Context()),
+					"method":     r.Method,
+					"path":       r.URL.Path,
+				})
+
+				// A response that has started cannot be replaced, the client sees it cut short
+				if recorder.status != 0 {
+					return
+				}
+				InternalServerErrorHandler(w, "Internal server error")
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
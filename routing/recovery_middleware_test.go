@@ -0,0 +1,77 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	var requestId string
+	handler := RequestIdMiddleware()(RecoveryMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId = RequestIdFromContext(r.Context())
+		var summary *struct{ Text string }
+		w.Write([]byte(summary.Text))
+	})))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/teletubpax/v1/summary-document", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected a panic to answer 500, got %d", w.Code)
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Status != 500 {
+		t.Errorf("expected a JSON error, got %q", w.Body.String())
+	}
+	if requestId == "" || w.Header().Get(RequestIdHeader) != requestId {
+		t.Errorf("expected the response to carry the request ID %q, got %q", requestId, w.Header().Get(RequestIdHeader))
+	}
+}
+
+func TestRecoveryMiddleware_KeepsAStartedResponse(t *testing.T) {
+	handler := RecoveryMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"answer":`))
+		panic("stream broke")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/teletubpax/v1/question-search", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"answer":` {
+		t.Errorf("expected the started response to be left as is, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRequestIdMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{name: "caller's ID is kept", header: "req-42.a:b_c", expected: "req-42.a:b_c"},
+		{name: "unsafe ID is replaced", header: "bad id\n", expected: ""},
+		{name: "missing ID is generated", header: "", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestId string
+			handler := RequestIdMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestId = RequestIdFromContext(r.Context())
+			}))
+			req := httptest.NewRequest("GET", "/api/teletubpax/v1/healthcheck", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIdHeader, tt.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tt.expected != "" && requestId != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, requestId)
+			}
+			if tt.expected == "" && (len(requestId) != 32 || requestId == tt.header) {
+				t.Errorf("expected a generated ID, got %q", requestId)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/utils"
+	"teletubpax-api/warnings"
+)
+
+type RelatedQuestionsRequest struct {
+	Question string            `json:"question"`
+	Filters  *RetrievalFilters `json:"filters,omitempty"` // Suggest from the documents matching these metadata only
+}
+
+type RelatedQuestionsResponse struct {
+	Questions []string           `json:"questions"` // 3 to 5 follow-up questions, none when no document matches
+	Warnings  []warnings.Warning `json:"warnings,omitempty"`
+}
+
+type RelatedQuestionsHandler struct {
+	service           services.RelatedQuestionsService
+	maxQuestionLength int
+}
+
+func NewRelatedQuestionsHandler(service services.RelatedQuestionsService, maxQuestionLength int) *RelatedQuestionsHandler {
+	return &RelatedQuestionsHandler{
+		service:           service,
+		maxQuestionLength: maxQuestionLength,
+	}
+}
+
+// Handle suggests follow-up questions to a question, for "people also asked" chips under
+// its answer
+func (h *RelatedQuestionsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithContext(r.Context())
+
+	request, ok := DecodeJSONRequest(w, r, func(request *RelatedQuestionsRequest) []Rule {
+		request.Question = utils.NormalizeThaiText(request.Question)
+		return append([]Rule{
+			Required("question", request.Question),
+			MaxLength("question", request.Question, h.maxQuestionLength),
+		}, request.Filters.rules()...)
+	})
+	if !ok {
+		return
+	}
+
+	ctx, collected := warnings.WithCollector(aws.WithMetadataFilter(r.Context(), request.Filters.metadataFilter()))
+	questions, err := h.service.Suggest(ctx, request.Question)
+	if err != nil {
+		log.Error("Failed to suggest related questions", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to suggest related questions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RelatedQuestionsResponse{
+		Questions: questions,
+		Warnings:  collected.List(),
+	})
+}
@@ -0,0 +1,66 @@
+package routing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/gorilla/mux"
+)
+
+// RequestIdHeader carries the ID of a request, sent back on every response so a caller can
+// quote it when reporting a problem
+const RequestIdHeader = "X-Request-Id"
+
+const maxRequestIdLength = 128
+
+type requestIdKey struct{}
+
+// RequestIdFromContext returns the ID RequestIdMiddleware gave the request, empty outside it
+func RequestIdFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// RequestIdMiddleware gives every request an ID: the caller's X-Request-Id when it is a
+// plain token, the API Gateway request ID on Lambda, or a random one otherwise.
+func RequestIdMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestId := r.Header.Get(RequestIdHeader)
+			if !isRequestIdToken(requestId) {
+				requestId = ""
+			}
+			if requestId == "" {
+				if gateway, ok := core.GetAPIGatewayV2ContextFromContext(r.Context()); ok {
+					requestId = gateway.RequestID
+				}
+			}
+			if requestId == "" {
+				buf := make([]byte, 16)
+				rand.Read(buf)
+				requestId = hex.EncodeToString(buf)
+			}
+
+			w.Header().Set(RequestIdHeader, requestId)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIdKey{}, requestId)))
+		})
+	}
+}
+
+// isRequestIdToken keeps caller supplied IDs to characters that are safe in logs and headers
+func isRequestIdToken(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for _, c := range requestId {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
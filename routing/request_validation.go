@@ -0,0 +1,194 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"teletubpax-api/logger"
+)
+
+// FieldError describes why one request field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is the 400 response of a request that failed validation. Error
+// repeats the first field error, for clients that only show a single message.
+type ValidationErrorResponse struct {
+	Error  string       `json:"error"`
+	Status int          `json:"status"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// Rule checks one field of a decoded request and returns nil when it is valid
+type Rule func() *FieldError
+
+// DecodeJSONRequest reads a JSON request body into a new T and checks it against the rules
+// returned for it. The content type must be JSON when it is set. On failure the 400
+// response has been written, listing every invalid field, and ok is false. Bodies over the
+// BodyLimitMiddleware limit answer 413.
+func DecodeJSONRequest[T any](w http.ResponseWriter, r *http.Request, rules func(request *T) []Rule) (request *T, ok bool) {
+	log := logger.WithContext(r.Context())
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+			log.Warn("Invalid content type", map[string]interface{}{
+				"content_type": contentType,
+			})
+			BadRequestHandler(w, "Content-Type must be application/json")
+			return nil, false
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		log.Warn("Failed to read request body", map[string]interface{}{
+			"error": err.Error(),
+		})
+		writeBodyReadError(w, err)
+		return nil, false
+	}
+
+	request = new(T)
+	if err := json.Unmarshal(body, request); err != nil {
+		log.Warn("Invalid JSON format", map[string]interface{}{
+			"error": err.Error(),
+		})
+		BadRequestHandler(w, "Invalid JSON format")
+		return nil, false
+	}
+
+	var fieldErrors []FieldError
+	if rules != nil {
+		for _, rule := range rules(request) {
+			if fieldError := rule(); fieldError != nil {
+				fieldErrors = append(fieldErrors, *fieldError)
+			}
+		}
+	}
+	if len(fieldErrors) > 0 {
+		log.Warn("Request validation failed", map[string]interface{}{
+			"fields": fieldErrors,
+		})
+		ValidationErrorHandler(w, fieldErrors)
+		return nil, false
+	}
+	return request, true
+}
+
+// ValidationErrorHandler writes a 400 response listing the invalid fields
+func ValidationErrorHandler(w http.ResponseWriter, fieldErrors []FieldError) {
+	response := ValidationErrorResponse{
+		Error:  fieldErrors[0].Message,
+		Status: 400,
+		Fields: fieldErrors,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Required rejects strings that are empty or only whitespace
+func Required(field string, value string) Rule {
+	return func() *FieldError {
+		if strings.TrimSpace(value) == "" {
+			return &FieldError{Field: field, Message: field + " field is required"}
+		}
+		return nil
+	}
+}
+
+// MaxLength rejects strings longer than max bytes
+func MaxLength(field string, value string, max int) Rule {
+	return func() *FieldError {
+		if len(value) > max {
+			return &FieldError{Field: field, Message: fmt.Sprintf("%s exceeds maximum length of %d", field, max)}
+		}
+		return nil
+	}
+}
+
+// NotEmpty rejects lists without items
+func NotEmpty[E any](field string, values []E) Rule {
+	return func() *FieldError {
+		if len(values) == 0 {
+			return &FieldError{Field: field, Message: field + " field is required and must not be empty"}
+		}
+		return nil
+	}
+}
+
+// NonNegative rejects negative numbers
+func NonNegative(field string, value int) Rule {
+	return func() *FieldError {
+		if value < 0 {
+			return &FieldError{Field: field, Message: field + " cannot be negative"}
+		}
+		return nil
+	}
+}
+
+// Between rejects numbers outside min to max, both included
+func Between(field string, value float64, min float64, max float64) Rule {
+	return func() *FieldError {
+		if value < min || value > max {
+			return &FieldError{Field: field, Message: fmt.Sprintf("%s must be between %g and %g", field, min, max)}
+		}
+		return nil
+	}
+}
+
+// Valid rejects a field that failed to parse, with err the parse error or nil
+func Valid(field string, err error) Rule {
+	return func() *FieldError {
+		if err != nil {
+			return &FieldError{Field: field, Message: field + " is invalid"}
+		}
+		return nil
+	}
+}
+
+// Enabled rejects a field that was sent while the setting it needs is off
+func Enabled(field string, sent bool, enabled bool, setting string) Rule {
+	return func() *FieldError {
+		if sent && !enabled {
+			return &FieldError{Field: field, Message: fmt.Sprintf("%s requires %s to be enabled", field, setting)}
+		}
+		return nil
+	}
+}
+
+// OneOf rejects strings other than the allowed values. An empty string is accepted, as the
+// field is then left at its default.
+func OneOf(field string, value string, allowed ...string) Rule {
+	return func() *FieldError {
+		if value == "" {
+			return nil
+		}
+		for _, candidate := range allowed {
+			if value == candidate {
+				return nil
+			}
+		}
+		return &FieldError{Field: field, Message: fmt.Sprintf("%s must be %s", field, joinQuoted(allowed))}
+	}
+}
+
+// joinQuoted lists values as `"a", "b" or "c"`
+func joinQuoted(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
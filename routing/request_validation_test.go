@@ -0,0 +1,92 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testValidatedRequest struct {
+	Question        string   `json:"question"`
+	Language        string   `json:"language"`
+	Documents       []string `json:"documents"`
+	NumberOfResults int      `json:"numberOfResults"`
+}
+
+func decodeTestRequest(w http.ResponseWriter, r *http.Request) (*testValidatedRequest, bool) {
+	return DecodeJSONRequest(w, r, func(request *testValidatedRequest) []Rule {
+		return []Rule{
+			Required("question", request.Question),
+			MaxLength("question", request.Question, 10),
+			OneOf("language", request.Language, "th", "en"),
+			NotEmpty("documents", request.Documents),
+			NonNegative("numberOfResults", request.NumberOfResults),
+		}
+	})
+}
+
+func TestDecodeJSONRequest_Valid(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"question":"fee?","language":"th","documents":["a"]}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	w := httptest.NewRecorder()
+
+	request, ok := decodeTestRequest(w, req)
+	if !ok || request.Question != "fee?" || len(request.Documents) != 1 {
+		t.Fatalf("expected the decoded request, got %+v %v", request, ok)
+	}
+}
+
+func TestDecodeJSONRequest_ListsEveryInvalidField(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"question":"   ","language":"jp","numberOfResults":-1}`))
+	w := httptest.NewRecorder()
+
+	if _, ok := decodeTestRequest(w, req); ok {
+		t.Fatal("expected validation to fail")
+	}
+
+	var response ValidationErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusBadRequest || len(response.Fields) != 4 {
+		t.Fatalf("expected four field errors, got %d %s", w.Code, w.Body.String())
+	}
+	expected := []string{"question", "language", "documents", "numberOfResults"}
+	for i, field := range expected {
+		if response.Fields[i].Field != field {
+			t.Errorf("expected field %s, got %+v", field, response.Fields[i])
+		}
+	}
+	if response.Error != response.Fields[0].Message || response.Fields[1].Message != `language must be "th" or "en"` {
+		t.Errorf("unexpected messages %+v", response)
+	}
+}
+
+func TestDecodeJSONRequest_RejectsMalformedRequests(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		message     string
+	}{
+		{"content type", "text/plain", `{"question":"fee?"}`, "Content-Type must be application/json"},
+		{"invalid JSON", "application/json", `{"question":`, "Invalid JSON format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			if _, ok := decodeTestRequest(w, req); ok {
+				t.Fatal("expected the request to be rejected")
+			}
+			var response ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if w.Code != http.StatusBadRequest || response.Error != tt.message {
+				t.Errorf("expected 400 %q, got %d %s", tt.message, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseSigningMiddleware(t *testing.T) {
+	key := []byte("test-signing-key")
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"answer":"ok"}`))
+	})
+	handler := ResponseSigningMiddleware(key)(next)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/teletubpax/question-search", nil))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status to be preserved, got %d", w.Code)
+	}
+	if w.Body.String() != `{"answer":"ok"}` {
+		t.Fatalf("expected body to be preserved, got %s", w.Body.String())
+	}
+
+	timestamp := w.Header().Get(SignatureTimestampHeader)
+	if timestamp == "" {
+		t.Fatal("expected signature timestamp header")
+	}
+	expected := "sha256=" + SignResponse(key, timestamp, w.Body.Bytes())
+	if w.Header().Get(SignatureHeader) != expected {
+		t.Fatalf("expected signature %s, got %s", expected, w.Header().Get(SignatureHeader))
+	}
+
+	if SignResponse([]byte("other-key"), timestamp, w.Body.Bytes()) == SignResponse(key, timestamp, w.Body.Bytes()) {
+		t.Fatal("expected a different key to produce a different signature")
+	}
+}
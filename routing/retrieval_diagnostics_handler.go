@@ -0,0 +1,61 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/utils"
+)
+
+type RetrievalDiagnosticsRequest struct {
+	Question        string            `json:"question"`
+	NumberOfResults int               `json:"numberOfResults"`
+	Filters         *RetrievalFilters `json:"filters,omitempty"`    // Retrieve the documents matching these metadata only
+	SearchType      string            `json:"searchType,omitempty"` // Optional "HYBRID" or "SEMANTIC", to compare the two on a question
+}
+
+type RetrievalDiagnosticsHandler struct {
+	service           services.RetrievalDiagnosticsService
+	maxQuestionLength int
+}
+
+func NewRetrievalDiagnosticsHandler(service services.RetrievalDiagnosticsService, maxQuestionLength int) *RetrievalDiagnosticsHandler {
+	return &RetrievalDiagnosticsHandler{
+		service:           service,
+		maxQuestionLength: maxQuestionLength,
+	}
+}
+
+func (h *RetrievalDiagnosticsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithContext(r.Context())
+
+	request, ok := DecodeJSONRequest(w, r, func(request *RetrievalDiagnosticsRequest) []Rule {
+		request.Question = utils.NormalizeThaiText(request.Question)
+		return append([]Rule{
+			Required("question", request.Question),
+			MaxLength("question", request.Question, h.maxQuestionLength),
+			NonNegative("numberOfResults", request.NumberOfResults),
+			OneOf("searchType", request.SearchType, aws.SearchTypeHybrid, aws.SearchTypeSemantic),
+		}, request.Filters.rules()...)
+	})
+	if !ok {
+		return
+	}
+
+	ctx := aws.WithSearchType(aws.WithMetadataFilter(r.Context(), request.Filters.metadataFilter()), request.SearchType)
+	diagnostics, err := h.service.Diagnose(ctx, request.Question, request.NumberOfResults)
+	if err != nil {
+		log.Error("Failed to run retrieval diagnostics", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to run retrieval diagnostics")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(diagnostics)
+}
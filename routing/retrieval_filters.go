@@ -0,0 +1,82 @@
+package routing
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"teletubpax-api/aws"
+)
+
+// Metadata attributes of the knowledge base documents that retrieval can be filtered by.
+// The effective date is a number in YYYYMMDD form, as Bedrock only compares numbers.
+const (
+	departmentMetadataKey    = "department"
+	documentTypeMetadataKey  = "document_type"
+	effectiveDateMetadataKey = "effective_date"
+)
+
+// RetrievalFilters restricts the documents a question is answered from by their metadata.
+// Each list accepts any of its values; documents without a filtered attribute are left out.
+type RetrievalFilters struct {
+	Department    []string `json:"department,omitempty"`
+	DocumentType  []string `json:"documentType,omitempty"`
+	EffectiveFrom string   `json:"effectiveFrom,omitempty"` // YYYY-MM-DD, documents effective on or after the date
+	EffectiveTo   string   `json:"effectiveTo,omitempty"`   // YYYY-MM-DD, documents effective on or before the date
+}
+
+// rules validates the filters, which are optional
+func (f *RetrievalFilters) rules() []Rule {
+	if f == nil {
+		return nil
+	}
+	from, fromErr := parseFilterDate(f.EffectiveFrom)
+	to, toErr := parseFilterDate(f.EffectiveTo)
+	return []Rule{
+		Valid("filters.effectiveFrom", fromErr),
+		Valid("filters.effectiveTo", toErr),
+		func() *FieldError {
+			if fromErr == nil && toErr == nil && from > 0 && to > 0 && from > to {
+				return &FieldError{Field: "filters.effectiveTo", Message: "filters.effectiveTo must not be before filters.effectiveFrom"}
+			}
+			return nil
+		},
+	}
+}
+
+// metadataFilter converts validated filters, nil when there are none
+func (f *RetrievalFilters) metadataFilter() *aws.MetadataFilter {
+	if f == nil {
+		return nil
+	}
+	filter := &aws.MetadataFilter{
+		In: map[string][]string{
+			departmentMetadataKey:   f.Department,
+			documentTypeMetadataKey: f.DocumentType,
+		},
+		AtLeast: map[string]float64{},
+		AtMost:  map[string]float64{},
+	}
+	if from, _ := parseFilterDate(f.EffectiveFrom); from > 0 {
+		filter.AtLeast[effectiveDateMetadataKey] = float64(from)
+	}
+	if to, _ := parseFilterDate(f.EffectiveTo); to > 0 {
+		filter.AtMost[effectiveDateMetadataKey] = float64(to)
+	}
+	if filter.Empty() {
+		return nil
+	}
+	return filter
+}
+
+// parseFilterDate returns a YYYY-MM-DD date as the number YYYYMMDD, 0 for an empty date
+func parseFilterDate(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q: %w", value, err)
+	}
+	return strconv.Atoi(date.Format("20060102"))
+}
@@ -1,117 +1,727 @@
-package routing
-
-import (
-	"encoding/json"
-	"net/http"
-
-	"teletubpax-api/logger"
-	"teletubpax-api/services"
-
-	"github.com/gorilla/mux"
-)
-
-// CORS middleware
-func CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		w.Header().Set("Access-Control-Max-Age", "3600")
-
-		// Handle preflight OPTIONS request
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
-type Response struct {
-	Message string `json:"message"`
-	Status  int    `json:"status"`
-}
-
-type ErrorResponse struct {
-	Error  string `json:"error"`
-	Status int    `json:"status"`
-}
-
-func SetupRoutes(questionSearchService services.QuestionSearchService, documentDetailsService services.DocumentDetailsService, documentSummaryService services.DocumentSummaryService, maxQuestionLength int) *mux.Router {
-	router := mux.NewRouter()
-
-	// Apply CORS middleware to all routes
-	router.Use(CORSMiddleware)
-
-	// Health check endpoint
-	router.HandleFunc("/api/teletubpax/healthcheck", HealthCheckHandler).Methods("GET", "OPTIONS")
-
-	// Question search endpoint
-	questionSearchHandler := NewQuestionSearchHandler(questionSearchService, maxQuestionLength)
-	router.HandleFunc("/api/teletubpax/question-search", questionSearchHandler.Handle).Methods("POST", "OPTIONS")
-
-	// Document details endpoint
-	documentDetailsHandler := NewDocumentDetailsHandler(documentDetailsService)
-	router.HandleFunc("/api/teletubpax/last-update-document", documentDetailsHandler.Handle).Methods("GET", "OPTIONS")
-
-	// Document summary endpoint
-	documentSummaryHandler := NewDocumentSummaryHandler(documentSummaryService)
-	router.HandleFunc("/api/teletubpax/summary-document", documentSummaryHandler.Handle).Methods("POST", "OPTIONS")
-
-	// 404 handler
-	router.NotFoundHandler = http.HandlerFunc(NotFoundHandler)
-
-	return router
-}
-
-func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	response := Response{
-		Message: "I'm OK",
-		Status:  200,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
-}
-
-func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
-	log := logger.WithContext(r.Context())
-	log.Warn("Resource not found", map[string]interface{}{
-		"path":   r.URL.Path,
-		"method": r.Method,
-	})
-
-	errorResponse := ErrorResponse{
-		Error:  "Resource not found",
-		Status: 404,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotFound)
-	json.NewEncoder(w).Encode(errorResponse)
-}
-
-func BadRequestHandler(w http.ResponseWriter, message string) {
-	errorResponse := ErrorResponse{
-		Error:  message,
-		Status: 400,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(errorResponse)
-}
-
-func InternalServerErrorHandler(w http.ResponseWriter, message string) {
-	errorResponse := ErrorResponse{
-		Error:  message,
-		Status: 500,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusInternalServerError)
-	json.NewEncoder(w).Encode(errorResponse)
-}
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"teletubpax-api/alerting"
+	"teletubpax-api/auth"
+	"teletubpax-api/config"
+	"teletubpax-api/eval"
+	"teletubpax-api/flags"
+	"teletubpax-api/logger"
+	"teletubpax-api/normalization"
+	"teletubpax-api/policy"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// CORS middleware
+func CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Set CORS headers
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Admin-Token, X-Api-Key, X-Iam-Authorization, X-Iam-Date, X-Iam-Security-Token, X-Session-Id, X-Tenant-Id, Cache-Control, Idempotency-Key, X-Callback-Url, X-Request-Id, traceparent, tracestate")
+		w.Header().Set("Access-Control-Max-Age", "3600")
+
+		// Handle preflight OPTIONS request with the methods registered for the matched route
+		if r.Method == "OPTIONS" {
+			if route := mux.CurrentRoute(r); route != nil {
+				if methods, err := route.GetMethods(); err == nil {
+					w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+					w.Header().Set("Allow", strings.Join(methods, ", "))
+				}
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// methodHandlers maps each HTTP method served on a path to its handler
+type methodHandlers map[string]http.HandlerFunc
+
+// registerRoute registers every method of a path as a single route that also accepts
+// OPTIONS. CORSMiddleware answers preflights from the route's methods, so this map is the
+// only place the allowed methods of a path are declared.
+func registerRoute(router *mux.Router, path string, handlers methodHandlers) {
+	methods := make([]string, 0, len(handlers)+1)
+	for method := range handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	methods = append(methods, "OPTIONS")
+
+	router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		handlers[r.Method](w, r)
+	}).Methods(methods...)
+}
+
+const (
+	apiPathPrefix   = "/api/teletubpax/"
+	apiV1PathPrefix = "/api/teletubpax/v1/"
+)
+
+// routeGroup registers each route under /api/teletubpax/v1 and under the unversioned
+// /api/teletubpax prefix it replaces, which stays as a deprecated alias. A response schema
+// can change under /v1 by registering a different handler on v1 only.
+type routeGroup struct {
+	v1     *mux.Router
+	legacy *mux.Router
+}
+
+// newRouteGroup adds the /v1 and unversioned prefixes to the router. The /v1 prefix is
+// added first, the unversioned one would match its paths too.
+func newRouteGroup(router *mux.Router, sunset time.Time) routeGroup {
+	group := routeGroup{
+		v1:     router.PathPrefix(strings.TrimSuffix(apiV1PathPrefix, "/")).Subrouter(),
+		legacy: router.PathPrefix(strings.TrimSuffix(apiPathPrefix, "/")).Subrouter(),
+	}
+	group.legacy.Use(DeprecationMiddleware(sunset))
+	return group
+}
+
+// PathPrefix returns the group of the routes below prefix, on both versions
+func (g routeGroup) PathPrefix(prefix string) routeGroup {
+	return routeGroup{
+		v1:     g.v1.PathPrefix(prefix).Subrouter(),
+		legacy: g.legacy.PathPrefix(prefix).Subrouter(),
+	}
+}
+
+func (g routeGroup) Use(middleware mux.MiddlewareFunc) {
+	g.v1.Use(middleware)
+	g.legacy.Use(middleware)
+}
+
+// register registers the path, relative to the group, on both versions
+func (g routeGroup) register(path string, handlers methodHandlers) {
+	registerRoute(g.v1, path, handlers)
+	registerRoute(g.legacy, path, handlers)
+}
+
+// apiPath returns the path without its version, so checks on paths cover /v1 and the
+// unversioned aliases alike
+func apiPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, apiV1PathPrefix); ok {
+		return apiPathPrefix + rest
+	}
+	return path
+}
+
+// versionedPath returns the /v1 path of an unversioned path
+func versionedPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, apiPathPrefix); ok && !strings.HasPrefix(path, apiV1PathPrefix) {
+		return apiV1PathPrefix + rest
+	}
+	return path
+}
+
+// isHealthPath reports whether a path is the health check or a liveness or readiness probe,
+// which are served without authentication, maintenance mode, tracing or access logs
+func isHealthPath(path string) bool {
+	switch apiPath(path) {
+	case "/api/teletubpax/healthcheck", livenessPath, readinessPath:
+		return true
+	}
+	return false
+}
+
+type Response struct {
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
+
+type ErrorResponse struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// RouteServices groups the services the router dispatches to. Optional services left nil
+// keep their routes unregistered.
+type RouteServices struct {
+	QuestionSearch       services.QuestionSearchService
+	DocumentDetails      services.DocumentDetailsService
+	DocumentSummary      services.DocumentSummaryService
+	DocumentResummarize  services.DocumentResummarizeService // Optional
+	RetrievalDiagnostics services.RetrievalDiagnosticsService
+	RelatedQuestions     services.RelatedQuestionsService
+	Ingestion            services.IngestionService        // Optional
+	AnswerDiff           services.AnswerDiffService       // Optional
+	Evaluation           eval.Service                     // Optional
+	Experiments          services.ExperimentService       // Optional
+	Prompts              services.PromptService           // Optional, prompts only change through SSM when nil
+	KnowledgeGaps        services.KnowledgeGapService     // Optional
+	AnalyticsExport      services.AnalyticsExportService  // Optional
+	DocumentDeletion     services.DocumentDeletionService // Optional
+	DocumentRemoval      services.DocumentRemovalService  // Optional
+	DocumentExport       services.DocumentExportService   // Optional
+	Webhooks             services.WebhookService          // Optional
+	Digest               services.DigestService           // Optional
+	Feedback             services.FeedbackService         // Optional, answers carry no answer ID when nil
+	History              services.HistoryService          // Optional, no conversation history is kept when nil
+	Audit                services.AuditService            // Optional, questions are not audited when nil
+	Usage                services.UsageService            // Optional, token usage is not recorded when nil
+	ApiKeys              services.ApiKeyService           // Optional, X-Api-Key is ignored when nil
+	QuestionJobs         services.QuestionJobService      // Optional, questions can only be answered synchronously when nil
+	Jobs                 services.JobService              // Optional, /jobs/{id} is not served when nil
+	SummaryJobs          services.SummaryWorkflowService  // Optional, documents can only be summarized synchronously when nil
+	Idempotency          storage.IdempotencyStore         // Optional, Idempotency-Key is ignored when nil
+	Translation          services.TranslationService      // Optional, answers and snippets are not translated when nil
+	Disclaimers          *services.AnswerDisclaimers      // Optional, answers get no disclaimer when nil
+	FeatureFlags         *flags.Flags                     // Optional
+	Normalization        *normalization.Dictionary        // Optional
+	Policies             *policy.Policies                 // Optional, per-endpoint timeouts, limits and retries
+	Authenticator        *auth.Authenticator              // Optional, bearer tokens are only used for access control when nil
+	IamVerifier          *auth.IamVerifier                // Optional, SigV4 signed callers are anonymous when nil
+	AccessControl        *auth.AccessControl              // Optional, every caller sees every document when nil
+	ResponseSigningKey   []byte                           // Optional, responses are signed when set
+	ReadinessChecks      []ReadinessCheck                 // Optional, /readyz checks beyond the configuration and services
+	Alerts               *alerting.Monitor                // Optional, no error rate or throttling alerts are published when nil
+}
+
+// missingServices fails readiness when a service every route needs was not initialized
+func (svc RouteServices) missingServices() error {
+	var missing []string
+	if svc.QuestionSearch == nil {
+		missing = append(missing, "question search")
+	}
+	if svc.DocumentDetails == nil {
+		missing = append(missing, "document details")
+	}
+	if svc.DocumentSummary == nil {
+		missing = append(missing, "document summary")
+	}
+	if svc.RetrievalDiagnostics == nil {
+		missing = append(missing, "retrieval diagnostics")
+	}
+	if svc.RelatedQuestions == nil {
+		missing = append(missing, "related questions")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("services not initialized: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func SetupRoutes(svc RouteServices, cfg *config.Config) *mux.Router {
+	router := mux.NewRouter()
+
+	// Trace requests first, so the spans cover the other middlewares
+	if cfg.TracingExporter != "" {
+		router.Use(TracingMiddleware())
+	}
+	router.Use(RequestIdMiddleware())
+	router.Use(AccessLogMiddleware())
+	if svc.Alerts != nil {
+		router.Use(AlertingMiddleware(svc.Alerts))
+	}
+	// Inside the trace, the access log and the alerts, so they record the 500 of a recovered panic
+	router.Use(RecoveryMiddleware())
+
+	// Apply CORS middleware to all routes
+	router.Use(CORSMiddleware)
+	if cfg.MaxRequestBodyBytes > 0 {
+		router.Use(BodyLimitMiddleware(int64(cfg.MaxRequestBodyBytes)))
+	}
+	if len(svc.ResponseSigningKey) > 0 {
+		router.Use(ResponseSigningMiddleware(svc.ResponseSigningKey))
+	}
+	if cfg.MaintenanceMode != nil {
+		router.Use(MaintenanceMiddleware(cfg.MaintenanceMode))
+	}
+	if svc.IamVerifier != nil {
+		router.Use(IamAuthMiddleware(svc.IamVerifier))
+	}
+	if svc.ApiKeys != nil {
+		router.Use(ApiKeyMiddleware(svc.ApiKeys, cfg.ApiKeyRequired))
+	}
+	if svc.Policies != nil {
+		router.Use(PolicyMiddleware(svc.Policies))
+	}
+	// After the policy, so an endpoint's own timeoutSeconds takes precedence
+	if cfg.RequestTimeoutSeconds > 0 {
+		router.Use(RequestTimeoutMiddleware(time.Duration(cfg.RequestTimeoutSeconds) * time.Second))
+	}
+	if cfg.FaultInjectionEnabled {
+		router.Use(FaultInjectionMiddleware())
+	}
+	if svc.Authenticator != nil {
+		router.Use(AuthMiddleware(svc.Authenticator))
+	}
+	if svc.AccessControl != nil {
+		router.Use(AccessControlMiddleware(svc.AccessControl))
+	}
+	if svc.Usage != nil {
+		router.Use(UsageMiddleware(svc.Usage))
+	}
+	// Replay responses last, after the caller's identity and tenant are known
+	if svc.Idempotency != nil {
+		router.Use(IdempotencyMiddleware(svc.Idempotency, time.Duration(cfg.IdempotencyTTLSeconds)*time.Second))
+	}
+
+	// Every route is served under /api/teletubpax/v1 and, deprecated, under /api/teletubpax
+	api := newRouteGroup(router, cfg.LegacyPathsSunset)
+
+	// Health check endpoint
+	api.register("/healthcheck", methodHandlers{"GET": HealthCheckHandler})
+
+	// Liveness and readiness probes for ECS and Kubernetes, outside the versioned API
+	readinessHandler := NewReadinessHandler(append([]ReadinessCheck{
+		{Name: "config", Check: func(context.Context) error { return cfg.Validate() }},
+		{Name: "services", Check: func(context.Context) error { return svc.missingServices() }},
+	}, svc.ReadinessChecks...))
+	registerRoute(router, livenessPath, methodHandlers{"GET": LivenessHandler})
+	registerRoute(router, readinessPath, methodHandlers{"GET": readinessHandler.Handle})
+
+	// Question search endpoint
+	questionSearchHandler := NewQuestionSearchHandler(svc.QuestionSearch, svc.Translation, svc.Disclaimers, cfg.MaxQuestionLength, cfg.GenerationOverridesEnabled)
+	api.register("/question-search", methodHandlers{"POST": questionSearchHandler.Handle})
+
+	// Asynchronous question search, for answers that take longer than API Gateway's timeout
+	if svc.QuestionJobs != nil {
+		questionJobHandler := NewQuestionJobHandler(svc.QuestionJobs, questionSearchHandler)
+		api.register("/question-search/async", methodHandlers{"POST": questionJobHandler.HandleSubmit})
+	}
+
+	// Long-running operations of every type: asynchronous questions, bulk summaries and
+	// ingestion syncs
+	if svc.Jobs != nil {
+		jobHandler := NewJobHandler(svc.Jobs)
+		api.register("/jobs/{id}", methodHandlers{"GET": jobHandler.HandleGet})
+	}
+
+	// WebSocket chat, questions answered like question-search with the answer streamed
+	if cfg.ChatEnabled {
+		chatHandler := NewChatHandler(router, time.Duration(cfg.ChatPingSeconds)*time.Second, int64(cfg.MaxRequestBodyBytes))
+		api.register("/chat", methodHandlers{"GET": chatHandler.Handle})
+	}
+
+	// Related questions endpoint
+	relatedQuestionsHandler := NewRelatedQuestionsHandler(svc.RelatedQuestions, cfg.MaxQuestionLength)
+	api.register("/related-questions", methodHandlers{"POST": relatedQuestionsHandler.Handle})
+
+	// Document details endpoint
+	documentDetailsHandler := NewDocumentDetailsHandler(svc.DocumentDetails)
+	api.register("/last-update-document", methodHandlers{"GET": documentDetailsHandler.Handle})
+
+	// Document chunks endpoint
+	documentChunksHandler := NewDocumentChunksHandler(svc.DocumentDetails, svc.Translation)
+	api.register("/document-chunks", methodHandlers{"GET": documentChunksHandler.Handle})
+
+	// Document preview endpoint, for preview tooltips
+	documentPreviewHandler := NewDocumentPreviewHandler(svc.DocumentDetails, cfg.MaxQuestionLength)
+	api.register("/documents/preview", methodHandlers{"GET": documentPreviewHandler.Handle})
+
+	// Document version history endpoint
+	documentVersionsHandler := NewDocumentVersionsHandler(svc.DocumentDetails)
+	api.register("/documents/versions", methodHandlers{"GET": documentVersionsHandler.Handle})
+
+	// Document summary endpoint
+	documentSummaryHandler := NewDocumentSummaryHandler(svc.DocumentSummary)
+	api.register("/summary-document", methodHandlers{"POST": documentSummaryHandler.Handle})
+
+	// Document comparison endpoint, for any two documents
+	documentComparisonHandler := NewDocumentComparisonHandler(svc.DocumentDetails)
+	api.register("/document-compare", methodHandlers{"POST": documentComparisonHandler.Handle})
+
+	// Bulk document summaries, run by a Step Functions workflow
+	if svc.SummaryJobs != nil {
+		summaryJobHandler := NewSummaryJobHandler(svc.SummaryJobs)
+		api.register("/summary-document/jobs", methodHandlers{"POST": summaryJobHandler.HandleSubmit})
+		api.register("/summary-document/jobs/{id}", methodHandlers{"GET": summaryJobHandler.HandleGet})
+	}
+
+	// Answer feedback endpoint
+	if svc.Feedback != nil {
+		feedbackHandler := NewFeedbackHandler(svc.Feedback, cfg.MaxQuestionLength)
+		api.register("/feedback", methodHandlers{"POST": feedbackHandler.Handle})
+	}
+
+	// Conversation history of signed-in callers
+	if svc.History != nil {
+		historyHandler := NewHistoryHandler(svc.History)
+		api.register("/history", methodHandlers{
+			"GET":    historyHandler.HandleList,
+			"DELETE": historyHandler.HandleDelete,
+		})
+	}
+
+	// Token usage endpoint, for cost chargeback (requires the X-Admin-Token header)
+	if svc.Usage != nil {
+		usageHandler := NewUsageHandler(svc.Usage)
+		api.register("/usage", methodHandlers{"GET": AdminAuthMiddleware(cfg.AdminToken)(http.HandlerFunc(usageHandler.Handle)).ServeHTTP})
+	}
+
+	// Permanent document deletion (requires the X-Admin-Token header)
+	if svc.DocumentRemoval != nil {
+		documentRemovalHandler := NewDocumentRemovalHandler(svc.DocumentRemoval)
+		api.register("/documents", methodHandlers{"DELETE": AdminAuthMiddleware(cfg.AdminToken)(http.HandlerFunc(documentRemovalHandler.Handle)).ServeHTTP})
+	}
+
+	// Metadata export of every indexed document, for reporting (requires the X-Admin-Token header)
+	if svc.DocumentExport != nil {
+		documentExportHandler := NewDocumentExportHandler(svc.DocumentExport)
+		api.register("/documents/export", methodHandlers{"GET": AdminAuthMiddleware(cfg.AdminToken)(http.HandlerFunc(documentExportHandler.Handle)).ServeHTTP})
+	}
+
+	// Admin endpoints (require the X-Admin-Token header)
+	admin := api.PathPrefix("/admin")
+	admin.Use(AdminAuthMiddleware(cfg.AdminToken))
+
+	if cfg.SafeMode != nil {
+		safeModeHandler := NewSafeModeHandler(cfg.SafeMode)
+		admin.register("/safe-mode", methodHandlers{
+			"GET": safeModeHandler.HandleStatus,
+			"PUT": safeModeHandler.HandleSet,
+		})
+	}
+
+	if cfg.MaintenanceMode != nil {
+		maintenanceHandler := NewMaintenanceHandler(cfg.MaintenanceMode)
+		admin.register("/maintenance", methodHandlers{
+			"GET": maintenanceHandler.HandleStatus,
+			"PUT": maintenanceHandler.HandleSet,
+		})
+	}
+
+	if svc.FeatureFlags != nil {
+		featureFlagsHandler := NewFeatureFlagsHandler(svc.FeatureFlags)
+		admin.register("/flags", methodHandlers{"GET": featureFlagsHandler.HandleList})
+		admin.register("/flags/reload", methodHandlers{"POST": featureFlagsHandler.HandleReload})
+	}
+
+	if svc.Policies != nil {
+		policiesHandler := NewPoliciesHandler(svc.Policies)
+		admin.register("/policies", methodHandlers{"GET": policiesHandler.HandleList})
+		admin.register("/policies/reload", methodHandlers{"POST": policiesHandler.HandleReload})
+	}
+
+	if svc.Normalization != nil {
+		normalizationHandler := NewNormalizationHandler(svc.Normalization)
+		admin.register("/normalization", methodHandlers{
+			"GET":    normalizationHandler.HandleList,
+			"PUT":    normalizationHandler.HandlePut,
+			"DELETE": normalizationHandler.HandleDelete,
+		})
+		admin.register("/normalization/reload", methodHandlers{"POST": normalizationHandler.HandleReload})
+	}
+
+	retrievalDiagnosticsHandler := NewRetrievalDiagnosticsHandler(svc.RetrievalDiagnostics, cfg.MaxQuestionLength)
+	admin.register("/diagnostics/retrieval", methodHandlers{"POST": retrievalDiagnosticsHandler.Handle})
+
+	if svc.Ingestion != nil {
+		ingestionHandler := NewIngestionHandler(svc.Ingestion)
+		admin.register("/knowledge-bases/data-sources", methodHandlers{"GET": ingestionHandler.HandleListDataSources})
+		admin.register("/knowledge-bases/ingestion-jobs", methodHandlers{
+			"GET":  ingestionHandler.HandleStatus,
+			"POST": ingestionHandler.HandleStart,
+		})
+	}
+
+	if svc.AnswerDiff != nil {
+		answerDiffHandler := NewAnswerDiffHandler(svc.AnswerDiff, cfg.MaxQuestionLength)
+		admin.register("/diagnostics/answer-diff", methodHandlers{"POST": answerDiffHandler.Handle})
+	}
+
+	if svc.Evaluation != nil {
+		evalHandler := NewEvalHandler(svc.Evaluation, EvalAnswerer(router))
+		admin.register("/eval", methodHandlers{
+			"GET":  evalHandler.HandleReport,
+			"POST": evalHandler.HandleRun,
+		})
+	}
+
+	if svc.Experiments != nil {
+		experimentHandler := NewExperimentHandler(svc.Experiments)
+		admin.register("/experiments", methodHandlers{"GET": experimentHandler.Handle})
+	}
+
+	if svc.Prompts != nil {
+		promptHandler := NewPromptHandler(svc.Prompts)
+		admin.register("/prompts", methodHandlers{"GET": promptHandler.HandleList})
+		admin.register("/prompts/{name}", methodHandlers{
+			"GET": promptHandler.HandleGet,
+			"PUT": promptHandler.HandlePut,
+		})
+		admin.register("/prompts/{name}/restore", methodHandlers{"POST": promptHandler.HandleRestore})
+	}
+
+	if svc.KnowledgeGaps != nil {
+		knowledgeGapHandler := NewKnowledgeGapHandler(svc.KnowledgeGaps)
+		admin.register("/analytics/knowledge-gaps", methodHandlers{"GET": knowledgeGapHandler.Handle})
+	}
+
+	if svc.AnalyticsExport != nil {
+		analyticsExportHandler := NewAnalyticsExportHandler(svc.AnalyticsExport)
+		admin.register("/analytics/export", methodHandlers{"POST": analyticsExportHandler.HandleExport})
+	}
+
+	if svc.DocumentDeletion != nil {
+		documentDeletionHandler := NewDocumentDeletionHandler(svc.DocumentDeletion)
+		admin.register("/documents/deleted", methodHandlers{"GET": documentDeletionHandler.HandleList})
+		admin.register("/documents/delete", methodHandlers{"POST": documentDeletionHandler.HandleDelete})
+		admin.register("/documents/restore", methodHandlers{"POST": documentDeletionHandler.HandleRestore})
+		admin.register("/documents/purge", methodHandlers{"POST": documentDeletionHandler.HandlePurge})
+	}
+
+	if svc.Webhooks != nil {
+		webhooksHandler := NewWebhooksHandler(svc.Webhooks)
+		admin.register("/webhooks", methodHandlers{
+			"GET":    webhooksHandler.HandleList,
+			"POST":   webhooksHandler.HandleRegister,
+			"DELETE": webhooksHandler.HandleDelete,
+		})
+	}
+
+	if svc.Digest != nil {
+		digestHandler := NewDigestHandler(svc.Digest)
+		admin.register("/digest", methodHandlers{"GET": digestHandler.HandlePreview})
+		admin.register("/digest/send", methodHandlers{"POST": digestHandler.HandleSend})
+		admin.register("/digest/subscriptions", methodHandlers{
+			"GET":    digestHandler.HandleListSubscriptions,
+			"POST":   digestHandler.HandleSubscribe,
+			"DELETE": digestHandler.HandleUnsubscribe,
+		})
+	}
+
+	if svc.ApiKeys != nil {
+		apiKeysHandler := NewApiKeysHandler(svc.ApiKeys)
+		admin.register("/api-keys", methodHandlers{
+			"GET":    apiKeysHandler.HandleList,
+			"POST":   apiKeysHandler.HandleCreate,
+			"DELETE": apiKeysHandler.HandleRevoke,
+		})
+		admin.register("/api-keys/quota", methodHandlers{"PUT": apiKeysHandler.HandleSetQuota})
+	}
+
+	if svc.Audit != nil {
+		auditHandler := NewAuditHandler(svc.Audit)
+		admin.register("/audit/{id}", methodHandlers{"GET": auditHandler.Handle})
+	}
+
+	if svc.History != nil {
+		historyHandler := NewHistoryHandler(svc.History)
+		admin.register("/history", methodHandlers{"DELETE": historyHandler.HandleAdminDelete})
+	}
+
+	if svc.DocumentResummarize != nil {
+		documentResummarizeHandler := NewDocumentResummarizeHandler(svc.DocumentResummarize)
+		admin.register("/jobs/resummarize", methodHandlers{
+			"GET":  documentResummarizeHandler.HandleStatus,
+			"POST": documentResummarizeHandler.HandleRun,
+		})
+	}
+
+	// OpenAPI document of the routes above, and a Swagger UI to browse it
+	openAPIHandler := NewOpenAPIHandler(router)
+	api.register("/openapi.json", methodHandlers{"GET": openAPIHandler.HandleDocument})
+	api.register("/docs", methodHandlers{"GET": openAPIHandler.HandleUI})
+
+	// 404 and 405 handlers. mux loses the method mismatch of a route in a nested subrouter
+	// when a later prefix matches, so both cases go through the same check of the path.
+	router.NotFoundHandler = MethodNotAllowedHandler(router, http.HandlerFunc(NotFoundHandler))
+	router.MethodNotAllowedHandler = router.NotFoundHandler
+
+	return router
+}
+
+func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	response := Response{
+		Message: "I'm OK",
+		Status:  200,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithContext(r.Context())
+	log.Warn("Resource not found", map[string]interface{}{
+		"path":   r.URL.Path,
+		"method": r.Method,
+	})
+
+	errorResponse := ErrorResponse{
+		Error:  "Resource not found",
+		Status: 404,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+// MethodNotAllowedHandler answers requests to a registered path with a method it does not
+// serve with 405, listing the methods it does in the Allow header, and passes requests to
+// unknown paths to notFound. It reads the routes once, so it is set after every route is
+// registered.
+func MethodNotAllowedHandler(router *mux.Router, notFound http.Handler) http.Handler {
+	type pathMethods struct {
+		path    *regexp.Regexp
+		methods []string
+	}
+	var routes []pathMethods
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		pattern, err := route.GetPathRegexp()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		routes = append(routes, pathMethods{path: regexp.MustCompile(pattern), methods: methods})
+		return nil
+	})
+
+	// The router's middlewares do not run for these handlers, so the 405 adds the CORS headers itself
+	methodNotAllowed := CORSMiddleware(http.HandlerFunc(writeMethodNotAllowed))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, route := range routes {
+			if route.path.MatchString(r.URL.Path) {
+				allowed = append(allowed, route.methods...)
+			}
+		}
+		if len(allowed) == 0 {
+			notFound.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		methodNotAllowed.ServeHTTP(w, r)
+	})
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	allowed := w.Header().Get("Allow")
+	logger.WithContext(r.Context()).Warn("Method not allowed", map[string]interface{}{
+		"path":    r.URL.Path,
+		"method":  r.Method,
+		"allowed": allowed,
+	})
+
+	errorResponse := ErrorResponse{
+		Error:  fmt.Sprintf("Method %s is not allowed, use %s", r.Method, allowed),
+		Status: 405,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func BadRequestHandler(w http.ResponseWriter, message string) {
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 400,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func UnauthorizedHandler(w http.ResponseWriter, message string) {
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 401,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func ForbiddenHandler(w http.ResponseWriter, message string) {
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 403,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func ConflictHandler(w http.ResponseWriter, message string) {
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 409,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func UnprocessableEntityHandler(w http.ResponseWriter, message string) {
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 422,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func RequestEntityTooLargeHandler(w http.ResponseWriter, message string) {
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 413,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func InternalServerErrorHandler(w http.ResponseWriter, message string) {
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 500,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func ServiceUnavailableHandler(w http.ResponseWriter, message string) {
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 503,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+func TooManyRequestsHandler(w http.ResponseWriter, message string, retryAfterSeconds int) {
+	errorResponse := ErrorResponse{
+		Error:  message,
+		Status: 429,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(errorResponse)
+}
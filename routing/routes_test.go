@@ -0,0 +1,152 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+)
+
+func TestPreflightAdvertisesRegisteredMethods(t *testing.T) {
+	cfg := &config.Config{
+		MaxQuestionLength: 1000,
+		SafeMode:          config.NewSafeMode(false),
+	}
+	router := SetupRoutes(RouteServices{}, cfg)
+
+	tests := []struct {
+		path            string
+		expectedMethods string
+	}{
+		{path: "/api/teletubpax/healthcheck", expectedMethods: "GET, OPTIONS"},
+		{path: "/api/teletubpax/question-search", expectedMethods: "POST, OPTIONS"},
+		{path: "/api/teletubpax/summary-document", expectedMethods: "POST, OPTIONS"},
+		// Preflights carry no admin token and must not be rejected by the admin middleware
+		{path: "/api/teletubpax/admin/safe-mode", expectedMethods: "GET, PUT, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("OPTIONS", tt.path, nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected preflight status 200, got %d", tt.path, w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Methods"); got != tt.expectedMethods {
+			t.Fatalf("%s: expected allowed methods '%s', got '%s'", tt.path, tt.expectedMethods, got)
+		}
+		if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Fatalf("%s: expected CORS origin header", tt.path)
+		}
+	}
+}
+
+func TestCORSHeadersOnRegularResponses(t *testing.T) {
+	router := SetupRoutes(RouteServices{}, &config.Config{MaxQuestionLength: 1000})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/teletubpax/healthcheck", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatal("expected CORS origin header on regular responses")
+	}
+}
+
+func TestUnversionedPathsAreDeprecatedAliases(t *testing.T) {
+	router := SetupRoutes(RouteServices{}, &config.Config{
+		MaxQuestionLength: 1000,
+		AdminToken:        "secret",
+		SafeMode:          config.NewSafeMode(false),
+		LegacyPathsSunset: time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC),
+	})
+
+	for _, path := range []string{"/api/teletubpax/v1/healthcheck", "/api/teletubpax/v1/admin/safe-mode"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Admin-Token", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "" {
+			t.Errorf("%s: expected 200 without deprecation, got %d %q", path, w.Code, w.Header().Get("Deprecation"))
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/teletubpax/admin/safe-mode", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the unversioned path to be served, got %d", w.Code)
+	}
+	if w.Header().Get("Deprecation") != "@1792022400" || w.Header().Get("Sunset") != "Wed, 30 Jun 2027 00:00:00 GMT" {
+		t.Errorf("expected deprecation and sunset headers, got %v", w.Header())
+	}
+	if link := w.Header().Get("Link"); link != `</api/teletubpax/v1/admin/safe-mode>; rel="successor-version"` {
+		t.Errorf("expected a link to the /v1 path, got %q", link)
+	}
+
+	// The admin token is still required under /v1
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/teletubpax/v1/admin/safe-mode", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the admin token, got %d", w.Code)
+	}
+}
+
+func TestUnsupportedMethodsAnswer405(t *testing.T) {
+	router := SetupRoutes(RouteServices{}, &config.Config{MaxQuestionLength: 1000})
+
+	for _, path := range []string{"/api/teletubpax/v1/question-search", "/api/teletubpax/question-search"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Origin", "https://widget.example.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("%s: expected 405, got %d", path, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != "POST, OPTIONS" {
+			t.Errorf("%s: expected Allow: POST, OPTIONS, got %q", path, allow)
+		}
+		if w.Header().Get("Access-Control-Allow-Origin") == "" {
+			t.Errorf("%s: expected CORS headers on the 405", path)
+		}
+		var response ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Status != 405 {
+			t.Errorf("%s: expected a JSON error, got %q", path, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/teletubpax/v1/no-such-route", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown paths to stay 404, got %d", w.Code)
+	}
+}
+
+func BenchmarkQuestionSearchRoute(b *testing.B) {
+	router := SetupRoutes(RouteServices{
+		QuestionSearch: &mockQuestionSearchService{},
+	}, &config.Config{MaxQuestionLength: 1000, SafeMode: config.NewSafeMode(false)})
+	body := []byte(`{"question": "ค่าธรรมเนียมโอนเงินต่างธนาคารเท่าไหร่"}`)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest("POST", "/api/teletubpax/question-search?enableRelateDocument=true", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("expected status 200, got %d", w.Code)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+)
+
+type SafeModeRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type SafeModeResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+type SafeModeHandler struct {
+	safeMode *config.SafeMode
+}
+
+func NewSafeModeHandler(safeMode *config.SafeMode) *SafeModeHandler {
+	return &SafeModeHandler{
+		safeMode: safeMode,
+	}
+}
+
+func (h *SafeModeHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	h.writeStatus(w)
+}
+
+// HandleSet toggles safe mode for this instance. The SAFE_MODE env var only sets the
+// value a fresh instance starts with.
+func (h *SafeModeHandler) HandleSet(w http.ResponseWriter, r *http.Request) {
+	var request SafeModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		BadRequestHandler(w, "Invalid JSON format")
+		return
+	}
+	defer r.Body.Close()
+
+	if request.Enabled == nil {
+		BadRequestHandler(w, "enabled field is required")
+		return
+	}
+
+	h.safeMode.Set(*request.Enabled)
+	logger.WithContext(r.Context()).Warn("Safe mode changed", map[string]interface{}{
+		"enabled":     *request.Enabled,
+		"remote_addr": r.RemoteAddr,
+	})
+
+	h.writeStatus(w)
+}
+
+func (h *SafeModeHandler) writeStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SafeModeResponse{Enabled: h.safeMode.Enabled()})
+}
@@ -0,0 +1,48 @@
+package routing
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"teletubpax-api/auth"
+)
+
+// maxSessionIdLength bounds client-supplied session ids used as counter keys
+const maxSessionIdLength = 128
+
+// ThrottleResponse is a polite 429 with Thai and English messages for the chat widget
+type ThrottleResponse struct {
+	Error   string `json:"error"`
+	ErrorTh string `json:"errorTh"`
+	Status  int    `json:"status"`
+}
+
+// sessionKey identifies the caller for session limits: the user of a verified bearer token,
+// the X-Session-Id header sent by the chat widget, or the client IP when it is missing so
+// dropping the header does not lift the limits
+func sessionKey(r *http.Request) string {
+	if userId := auth.UserIdFromContext(r.Context()); userId != "" {
+		return "user:" + userId
+	}
+	if sessionId := strings.TrimSpace(r.Header.Get("X-Session-Id")); sessionId != "" {
+		if len(sessionId) > maxSessionIdLength {
+			sessionId = sessionId[:maxSessionIdLength]
+		}
+		return "id:" + sessionId
+	}
+	return "ip:" + clientIP(r)
+}
+
+// clientIP returns the first X-Forwarded-For address set by API Gateway, or the remote address
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
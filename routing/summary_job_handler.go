@@ -0,0 +1,115 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+
+	"github.com/gorilla/mux"
+)
+
+type SummaryJobResponse struct {
+	JobId            string                     `json:"jobId"`
+	Status           string                     `json:"status"` // "queued", "running", "completed" or "failed"
+	CreatedAt        time.Time                  `json:"createdAt"`
+	UpdatedAt        time.Time                  `json:"updatedAt"`
+	Documents        int                        `json:"documents"` // Valid documents being summarized
+	InvalidDocuments []services.InvalidDocument `json:"invalidDocuments"`
+	Error            string                     `json:"error,omitempty"`
+	Result           json.RawMessage            `json:"result,omitempty"` // The summaries, shaped like a summary-document response, once completed
+
+	CallbackSecret string `json:"callbackSecret,omitempty"` // Signs the callback, only returned on submission
+}
+
+type SummaryJobHandler struct {
+	jobs services.SummaryWorkflowService
+}
+
+func NewSummaryJobHandler(jobs services.SummaryWorkflowService) *SummaryJobHandler {
+	return &SummaryJobHandler{
+		jobs: jobs,
+	}
+}
+
+// HandleSubmit starts a bulk summary of the documents, answering 202 with the job to poll.
+// With X-Callback-Url, the job is also posted there once finished, signed with the
+// callbackSecret of the 202.
+func (h *SummaryJobHandler) HandleSubmit(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithContext(r.Context())
+
+	request, ok := DecodeJSONRequest(w, r, func(request *DocumentSummaryRequest) []Rule {
+		return []Rule{
+			NotEmpty("relatedDocuments", request.RelatedDocuments),
+		}
+	})
+	if !ok {
+		return
+	}
+
+	job, err := h.jobs.Submit(r.Context(), jobOwner(r), request.RelatedDocuments, r.Header.Get(CallbackUrlHeader))
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+		BadRequestHandler(w, bedrockErr.Message)
+		return
+	}
+	if err != nil {
+		log.Error("Failed to start summary job", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to start the summary job")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", apiV1PathPrefix+"summary-document/jobs/"+job.JobId)
+	w.WriteHeader(http.StatusAccepted)
+	response := newSummaryJobResponse(job)
+	response.CallbackSecret = job.CallbackSecret
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleGet answers the job and, once completed, its summaries. Jobs of other callers
+// answer 404 like unknown ones.
+func (h *SummaryJobHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(r.Context(), mux.Vars(r)["id"], jobOwner(r))
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to read summary job", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to read the job")
+		return
+	}
+	if job == nil {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !job.Finished() {
+		w.Header().Set("Retry-After", "10")
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newSummaryJobResponse(job))
+}
+
+func newSummaryJobResponse(job *storage.SummaryJob) SummaryJobResponse {
+	response := SummaryJobResponse{
+		JobId:            job.JobId,
+		Status:           job.Status,
+		CreatedAt:        job.CreatedAt,
+		UpdatedAt:        job.UpdatedAt,
+		Documents:        len(job.Documents),
+		InvalidDocuments: []services.InvalidDocument{},
+		Error:            job.Error,
+	}
+	json.Unmarshal(job.Rejected, &response.InvalidDocuments)
+	if json.Valid(job.Result) {
+		response.Result = bytes.TrimSpace(job.Result)
+	}
+	return response
+}
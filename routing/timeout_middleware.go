@@ -0,0 +1,26 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestTimeoutMiddleware bounds every request by timeout, so a hung Bedrock or AWS call
+// cannot hold a connection forever. Requests that already have a deadline keep it: the
+// endpoint policy's timeoutSeconds, or the invocation deadline on Lambda.
+func RequestTimeoutMiddleware(timeout time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
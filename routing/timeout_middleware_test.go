@@ -0,0 +1,31 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeoutMiddleware(t *testing.T) {
+	var deadline time.Time
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+	})
+	handler := RequestTimeoutMiddleware(time.Minute)(next)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/teletubpax/question-search", nil))
+	if remaining := time.Until(deadline); remaining <= 50*time.Second || remaining > time.Minute {
+		t.Errorf("expected a one minute deadline, got %v", remaining)
+	}
+
+	// The endpoint policy's deadline is kept, even when it is longer
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	policyDeadline, _ := ctx.Deadline()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/teletubpax/question-search", nil).WithContext(ctx))
+	if !deadline.Equal(policyDeadline) {
+		t.Errorf("expected the existing deadline %v, got %v", policyDeadline, deadline)
+	}
+}
@@ -0,0 +1,90 @@
+package routing
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"teletubpax-api/tracing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// TraceIdHeader returns the trace of a request, to look it up in the tracing backend
+const TraceIdHeader = "X-Trace-Id"
+
+// statusResponseWriter remembers the status code written through it
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Hijack hands the connection to a WebSocket upgrade
+func (w *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// TracingMiddleware starts a server span per request, named after the route, continuing the
+// trace of the caller's traceparent or X-Amzn-Trace-Id header. Services and AWS clients add
+// their spans below it through the request context. Health checks are not traced.
+func TracingMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHealthPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracing.StartServer(ctx, r.Method+" "+route)
+			span.SetAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+			)
+			if span.SpanContext().IsValid() {
+				w.Header().Set(TraceIdHeader, span.SpanContext().TraceID().String())
+			}
+
+			recorder := &statusResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+			if recorder.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(recorder.status))
+			}
+			span.End()
+		})
+	}
+}
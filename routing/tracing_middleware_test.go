@@ -0,0 +1,55 @@
+package routing
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	router := SetupRoutes(RouteServices{
+		QuestionSearch: &mockQuestionSearchService{},
+	}, &config.Config{MaxQuestionLength: 1000, TracingExporter: "otlp"})
+
+	req := httptest.NewRequest("POST", "/api/teletubpax/question-search", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/teletubpax/healthcheck", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span without the health check, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "POST /api/teletubpax/question-search" {
+		t.Errorf("expected the span named after the route, got %s", span.Name())
+	}
+	if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the caller's trace to continue, got %s", span.SpanContext().TraceID())
+	}
+	if w.Header().Get(TraceIdHeader) != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the trace ID in the response, got %q", w.Header().Get(TraceIdHeader))
+	}
+	for _, kv := range span.Attributes() {
+		if kv.Key == "http.response.status_code" && kv.Value.AsInt64() != int64(w.Code) {
+			t.Errorf("expected status %d on the span, got %d", w.Code, kv.Value.AsInt64())
+		}
+	}
+}
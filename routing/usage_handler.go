@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+)
+
+type UsageHandler struct {
+	service services.UsageService
+}
+
+func NewUsageHandler(service services.UsageService) *UsageHandler {
+	return &UsageHandler{
+		service: service,
+	}
+}
+
+// Handle returns the token usage and cost by tenant. Optional query parameters: from and to
+// (YYYY-MM-DD in UTC, inclusive, from the first of the month to today by default) and tenant.
+func (h *UsageHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, ok := optionalDateParam(r, "from", today.AddDate(0, 0, 1-today.Day()))
+	if !ok {
+		BadRequestHandler(w, "from must be a date in YYYY-MM-DD format")
+		return
+	}
+	to, ok := optionalDateParam(r, "to", today)
+	if !ok {
+		BadRequestHandler(w, "to must be a date in YYYY-MM-DD format")
+		return
+	}
+	if to.Before(from) {
+		BadRequestHandler(w, "to must not be before from")
+		return
+	}
+	if to.Sub(from) >= services.MaxUsageReportDays*24*time.Hour {
+		BadRequestHandler(w, fmt.Sprintf("the report may cover at most %d days", services.MaxUsageReportDays))
+		return
+	}
+
+	report, err := h.service.Report(r.Context(), from, to, r.URL.Query().Get("tenant"))
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to build usage report", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to build usage report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// optionalDateParam parses a YYYY-MM-DD query parameter, returning defaultValue when it is
+// absent
+func optionalDateParam(r *http.Request, name string, defaultValue time.Time) (time.Time, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, true
+	}
+	parsed, err := time.Parse(services.UsageDateLayout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return parsed, true
+}
@@ -0,0 +1,83 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+// usageQuestionSearchService answers like a knowledge base call that used tokens
+type usageQuestionSearchService struct{}
+
+func (s *usageQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	aws.RecordUsage(ctx, "anthropic.claude-haiku-4-5-20251001-v1:0", 1000, 200, false)
+	return "answer", nil, nil
+}
+
+func TestUsage_RecordsRequestsByTenant(t *testing.T) {
+	cfg := &config.Config{MaxQuestionLength: 1000, AdminToken: "secret"}
+	usage, err := services.NewStoreUsageService(storage.NewMemoryUsageStore(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	router := SetupRoutes(RouteServices{
+		QuestionSearch: &usageQuestionSearchService{},
+		Usage:          usage,
+	}, cfg)
+
+	for _, tenantId := range []string{"hr", "hr", "branch-app"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/teletubpax/question-search", bytes.NewBufferString(`{"question": "ค่าธรรมเนียมบัตรเดบิต"}`))
+		req.Header.Set("X-Tenant-Id", tenantId)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/teletubpax/usage", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the admin token to be required, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/teletubpax/usage?tenant=hr", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report services.UsageReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if len(report.Tenants) != 1 || report.InputTokens != 2000 || report.Cost != 0.004 {
+		t.Fatalf("expected the usage of the tenant's two questions, got %+v", report)
+	}
+	if item := report.Tenants[0].Usage[0]; item.Endpoint != "question-search" || item.Calls != 2 {
+		t.Errorf("expected the usage by endpoint, got %+v", item)
+	}
+}
+
+func TestUsageHandler_RejectsInvalidDays(t *testing.T) {
+	cfg := &config.Config{MaxQuestionLength: 1000, AdminToken: "secret"}
+	usage, _ := services.NewStoreUsageService(storage.NewMemoryUsageStore(), cfg)
+	router := SetupRoutes(RouteServices{Usage: usage}, cfg)
+
+	for _, query := range []string{"from=2026-13-01", "from=2026-02-01&to=2026-01-31", "from=2024-01-01&to=2026-01-01"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/teletubpax/usage?"+query, nil)
+		req.Header.Set("X-Admin-Token", "secret")
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/services"
+
+	"github.com/gorilla/mux"
+)
+
+// UsageMiddleware records the Bedrock tokens each request used, by the X-Tenant-Id of the
+// caller and the matched endpoint, once the request is served
+func UsageMiddleware(usage services.UsageService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endpoint := endpointName(r)
+			if endpoint == "" || r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, recorder := aws.WithUsageRecorder(r.Context())
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			if used := recorder.List(); len(used) > 0 {
+				// The tokens are spent even when the caller went away or the deadline passed
+				usage.Record(context.WithoutCancel(ctx), r.Header.Get("X-Tenant-Id"), endpoint, used)
+			}
+		})
+	}
+}
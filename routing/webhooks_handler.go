@@ -0,0 +1,112 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/services"
+	"teletubpax-api/storage"
+)
+
+const maxWebhookDescriptionLength = 200
+
+type WebhookRequest struct {
+	Url         string `json:"url"`
+	Description string `json:"description"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []storage.Webhook `json:"webhooks"`
+}
+
+type WebhooksHandler struct {
+	service services.WebhookService
+}
+
+func NewWebhooksHandler(service services.WebhookService) *WebhooksHandler {
+	return &WebhooksHandler{
+		service: service,
+	}
+}
+
+// HandleList returns the registered webhooks with their last delivery, without secrets
+func (h *WebhooksHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.service.List(r.Context())
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to list webhooks", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to list webhooks")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(WebhooksResponse{Webhooks: webhooks})
+}
+
+// HandleRegister registers a webhook and returns its signing secret, which is not shown again
+func (h *WebhooksHandler) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	var request WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		BadRequestHandler(w, "Invalid JSON format")
+		return
+	}
+	defer r.Body.Close()
+
+	if strings.TrimSpace(request.Url) == "" {
+		BadRequestHandler(w, "url is required")
+		return
+	}
+	if len(request.Description) > maxWebhookDescriptionLength {
+		BadRequestHandler(w, "description must not exceed 200 characters")
+		return
+	}
+
+	webhook, err := h.service.Register(r.Context(), request.Url, request.Description)
+	if bedrockErr, ok := err.(*bedrockErrors.BedrockError); ok && bedrockErr.Code == bedrockErrors.ErrCodeValidation {
+		BadRequestHandler(w, bedrockErr.Message)
+		return
+	}
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to register webhook", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to register webhook")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// HandleDelete removes the webhook named by the id query parameter
+func (h *WebhooksHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" {
+		BadRequestHandler(w, "id query parameter is required")
+		return
+	}
+
+	deleted, err := h.service.Delete(r.Context(), id)
+	if err != nil {
+		logger.WithContext(r.Context()).Error("Failed to delete webhook", map[string]interface{}{
+			"error": err.Error(),
+		})
+		InternalServerErrorHandler(w, "Failed to delete webhook")
+		return
+	}
+	if !deleted {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	logger.WithContext(r.Context()).Info("Webhook deleted", map[string]interface{}{
+		"id": id,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+const (
+	AnalyticsDatasetUnansweredQuestions = "unanswered_questions"
+	AnalyticsDatasetDeletedDocuments    = "deleted_documents"
+)
+
+// athenaTimestampFormat is the timestamp format the Athena JSON SerDe reads as a timestamp
+const athenaTimestampFormat = "2006-01-02 15:04:05"
+
+// UnansweredQuestionRow is an unanswered question in the analytics export
+type UnansweredQuestionRow struct {
+	Id       string                    `json:"id"`
+	Question string                    `json:"question"`
+	TopScore float64                   `json:"top_score"`
+	Scores   []UnansweredQuestionScore `json:"scores"`
+	AskedAt  string                    `json:"asked_at"`
+}
+
+type UnansweredQuestionScore struct {
+	KnowledgeBaseId string  `json:"knowledge_base_id"`
+	Score           float64 `json:"score"`
+	SourceUrl       string  `json:"source_url"`
+}
+
+// DeletedDocumentRow is a soft-deleted document in the analytics export
+type DeletedDocumentRow struct {
+	SourceUri  string  `json:"source_uri"`
+	Link       string  `json:"link"`
+	DeletedAt  string  `json:"deleted_at"`
+	PurgeAfter string  `json:"purge_after"`
+	PurgedAt   *string `json:"purged_at"`
+}
+
+// AnalyticsExportDataset reports one exported object
+type AnalyticsExportDataset struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Rows int    `json:"rows"`
+}
+
+type AnalyticsExportResult struct {
+	Date     string                   `json:"date"`
+	Bucket   string                   `json:"bucket"`
+	Datasets []AnalyticsExportDataset `json:"datasets"`
+}
+
+type AnalyticsExportService interface {
+	// Export writes the datasets of one UTC day, replacing an earlier export of the day
+	Export(ctx context.Context, day time.Time) (*AnalyticsExportResult, error)
+}
+
+// S3AnalyticsExportService writes daily gzipped JSON-lines snapshots of the analytics stores
+// to S3, one object per dataset under <prefix>/<dataset>/dt=YYYY-MM-DD/, so Athena can query
+// them with date partitions long after the DynamoDB TTLs removed the items. Unanswered
+// questions are exported for the day they were asked, deleted documents as the state of
+// the registry on the export day. Stores that are not configured are skipped.
+type S3AnalyticsExportService struct {
+	notFoundStore        storage.NotFoundStore        // Optional
+	deletedDocumentStore storage.DeletedDocumentStore // Optional
+	writer               aws.ObjectWriterClient
+	config               *config.Config
+}
+
+func NewS3AnalyticsExportService(notFoundStore storage.NotFoundStore, deletedDocumentStore storage.DeletedDocumentStore, writer aws.ObjectWriterClient, cfg *config.Config) *S3AnalyticsExportService {
+	return &S3AnalyticsExportService{
+		notFoundStore:        notFoundStore,
+		deletedDocumentStore: deletedDocumentStore,
+		writer:               writer,
+		config:               cfg,
+	}
+}
+
+func (s *S3AnalyticsExportService) Export(ctx context.Context, day time.Time) (*AnalyticsExportResult, error) {
+	since := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 0, 1)
+	if since.After(time.Now().UTC()) {
+		return nil, errors.NewValidationError("date must not be in the future")
+	}
+
+	result := &AnalyticsExportResult{
+		Date:     since.Format("2006-01-02"),
+		Bucket:   s.config.AnalyticsExportBucket,
+		Datasets: []AnalyticsExportDataset{},
+	}
+
+	if s.notFoundStore != nil {
+		events, err := s.notFoundStore.ListNotFound(ctx, since)
+		if err != nil {
+			return nil, err
+		}
+		// Stable order, so a re-export of the day produces the same object
+		sort.Slice(events, func(i, j int) bool {
+			if !events[i].AskedAt.Equal(events[j].AskedAt) {
+				return events[i].AskedAt.Before(events[j].AskedAt)
+			}
+			return events[i].Id < events[j].Id
+		})
+		var rows []UnansweredQuestionRow
+		for _, event := range events {
+			if !event.AskedAt.Before(until) {
+				continue
+			}
+			rows = append(rows, unansweredQuestionRow(event))
+		}
+		dataset, err := writeAnalyticsDataset(ctx, s, AnalyticsDatasetUnansweredQuestions, result.Date, rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Datasets = append(result.Datasets, *dataset)
+	}
+
+	if s.deletedDocumentStore != nil {
+		documents, err := s.deletedDocumentStore.ListDeleted(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(documents, func(i, j int) bool {
+			return documents[i].SourceUri < documents[j].SourceUri
+		})
+		var rows []DeletedDocumentRow
+		for _, document := range documents {
+			if !document.DeletedAt.Before(until) {
+				continue
+			}
+			rows = append(rows, deletedDocumentRow(document))
+		}
+		dataset, err := writeAnalyticsDataset(ctx, s, AnalyticsDatasetDeletedDocuments, result.Date, rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Datasets = append(result.Datasets, *dataset)
+	}
+
+	logger.WithContext(ctx).Info("Analytics export completed", map[string]interface{}{
+		"date":     result.Date,
+		"datasets": result.Datasets,
+	})
+	return result, nil
+}
+
+// writeAnalyticsDataset uploads the rows of a dataset as gzipped JSON lines. Days without
+// rows get an empty object, so a re-export also clears rows that were removed since.
+func writeAnalyticsDataset[T any](ctx context.Context, s *S3AnalyticsExportService, dataset string, date string, rows []T) (*AnalyticsExportDataset, error) {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	encoder := json.NewEncoder(gz)
+	encoder.SetEscapeHTML(false)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return nil, fmt.Errorf("failed to encode %s row: %w", dataset, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress %s: %w", dataset, err)
+	}
+
+	key := analyticsExportKey(s.config.AnalyticsExportPrefix, dataset, date)
+	if err := s.writer.PutObject(ctx, s.config.AnalyticsExportBucket, key, buffer.Bytes(), "application/x-ndjson"); err != nil {
+		return nil, err
+	}
+	return &AnalyticsExportDataset{Name: dataset, Key: key, Rows: len(rows)}, nil
+}
+
+// analyticsExportKey is the Hive-style partitioned key of a dataset export, e.g.
+// analytics/unanswered_questions/dt=2025-01-31/unanswered_questions.json.gz
+func analyticsExportKey(prefix string, dataset string, date string) string {
+	return path.Join(strings.Trim(prefix, "/"), dataset, "dt="+date, dataset+".json.gz")
+}
+
+func unansweredQuestionRow(event storage.NotFoundEvent) UnansweredQuestionRow {
+	scores := make([]UnansweredQuestionScore, 0, len(event.Scores))
+	for _, score := range event.Scores {
+		scores = append(scores, UnansweredQuestionScore{
+			KnowledgeBaseId: score.KnowledgeBaseId,
+			Score:           score.Score,
+			SourceUrl:       score.SourceUrl,
+		})
+	}
+	return UnansweredQuestionRow{
+		Id:       event.Id,
+		Question: event.Question,
+		TopScore: event.TopScore,
+		Scores:   scores,
+		AskedAt:  event.AskedAt.UTC().Format(athenaTimestampFormat),
+	}
+}
+
+func deletedDocumentRow(document storage.DeletedDocument) DeletedDocumentRow {
+	row := DeletedDocumentRow{
+		SourceUri:  document.SourceUri,
+		Link:       document.Link,
+		DeletedAt:  document.DeletedAt.UTC().Format(athenaTimestampFormat),
+		PurgeAfter: document.PurgeAfter.UTC().Format(athenaTimestampFormat),
+	}
+	if document.PurgedAt != nil {
+		purgedAt := document.PurgedAt.UTC().Format(athenaTimestampFormat)
+		row.PurgedAt = &purgedAt
+	}
+	return row
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type mockObjectWriterClient struct {
+	objects map[string][]byte
+}
+
+func (m *mockObjectWriterClient) PutObject(ctx context.Context, bucket string, key string, body []byte, contentType string) error {
+	if m.objects == nil {
+		m.objects = map[string][]byte{}
+	}
+	m.objects[bucket+"/"+key] = body
+	return nil
+}
+
+// readJSONLines decodes a gzipped JSON-lines object into one map per line
+func readJSONLines(t *testing.T, body []byte) []map[string]interface{} {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("expected a gzip object: %v", err)
+	}
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestAnalyticsExport_WritesPartitionedDatasets(t *testing.T) {
+	day := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	notFound := &mockNotFoundStore{events: []storage.NotFoundEvent{
+		{Id: "b", Question: "วิธีขอคืนเงินค่าธรรมเนียม", TopScore: 0.3, AskedAt: day.Add(9 * time.Hour),
+			Scores: []storage.NotFoundScore{{KnowledgeBaseId: "KB1", Score: 0.3, SourceUrl: "https://docs/fees.pdf"}}},
+		{Id: "a", Question: "How do I change my PIN", TopScore: 0.2, AskedAt: day.Add(time.Hour)},
+		{Id: "c", Question: "asked the next day", AskedAt: day.Add(25 * time.Hour)},
+	}}
+	purgedAt := day.Add(2 * time.Hour)
+	deleted := newMemoryDeletedDocumentStore(
+		storage.DeletedDocument{SourceUri: "s3://docs/old.pdf", DeletedAt: day.AddDate(0, 0, -40), PurgeAfter: day.AddDate(0, 0, -10), PurgedAt: &purgedAt},
+		storage.DeletedDocument{SourceUri: "s3://docs/later.pdf", DeletedAt: day.AddDate(0, 0, 2)},
+	)
+	writer := &mockObjectWriterClient{}
+	cfg := &config.Config{AnalyticsExportBucket: "analytics-bucket", AnalyticsExportPrefix: "/exports/"}
+	service := NewS3AnalyticsExportService(notFound, deleted, writer, cfg)
+
+	result, err := service.Export(context.Background(), day.Add(15*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Date != "2025-01-31" || len(result.Datasets) != 2 || !notFound.since.Equal(day) {
+		t.Fatalf("unexpected result %+v, since %v", result, notFound.since)
+	}
+
+	questions := readJSONLines(t, writer.objects["analytics-bucket/exports/unanswered_questions/dt=2025-01-31/unanswered_questions.json.gz"])
+	if len(questions) != 2 || questions[0]["id"] != "a" || questions[1]["asked_at"] != "2025-01-31 09:00:00" {
+		t.Fatalf("expected the day's questions in order, got %v", questions)
+	}
+	scores := questions[1]["scores"].([]interface{})
+	if len(scores) != 1 || scores[0].(map[string]interface{})["knowledge_base_id"] != "KB1" {
+		t.Errorf("expected the retrieval scores, got %v", scores)
+	}
+
+	documents := readJSONLines(t, writer.objects["analytics-bucket/exports/deleted_documents/dt=2025-01-31/deleted_documents.json.gz"])
+	if len(documents) != 1 || documents[0]["source_uri"] != "s3://docs/old.pdf" || documents[0]["purged_at"] != "2025-01-31 02:00:00" {
+		t.Errorf("expected the documents deleted by the export day, got %v", documents)
+	}
+}
+
+func TestAnalyticsExport_SkipsMissingStoresAndRejectsFutureDates(t *testing.T) {
+	writer := &mockObjectWriterClient{}
+	service := NewS3AnalyticsExportService(&mockNotFoundStore{}, nil, writer, &config.Config{AnalyticsExportBucket: "b", AnalyticsExportPrefix: "analytics"})
+
+	result, err := service.Export(context.Background(), time.Now().UTC())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Datasets) != 1 || result.Datasets[0].Rows != 0 || len(writer.objects) != 1 {
+		t.Errorf("expected one empty dataset, got %+v", result.Datasets)
+	}
+
+	if _, err := service.Export(context.Background(), time.Now().UTC().AddDate(0, 0, 2)); err == nil {
+		t.Error("expected future dates to be rejected")
+	}
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/policy"
+	"teletubpax-api/stages"
+	"teletubpax-api/warnings"
+)
+
+const (
+	AnswerBackendKnowledgeBase     = "knowledge-base"     // RetrieveAndGenerate per knowledge base, merged by synthesis
+	AnswerBackendRetrievalConverse = "retrieval-converse" // Merged retrieval of every knowledge base, one Converse call
+	AnswerBackendAgent             = "agent"              // Bedrock Agent
+	AnswerBackendStub              = "stub"               // Fixed answer without AWS calls, for load tests and local development
+
+	// maxMergedChunks bounds the context of a retrieval-converse answer
+	maxMergedChunks = 10
+)
+
+// AnswerBackend produces the answer to an already normalized question, with the public URLs
+// of the documents it is based on
+type AnswerBackend interface {
+	Answer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error)
+}
+
+type tenantIdKey struct{}
+
+// WithTenantId attaches the tenant of a request to its context, for per-tenant backends
+func WithTenantId(ctx context.Context, tenantId string) context.Context {
+	return context.WithValue(ctx, tenantIdKey{}, tenantId)
+}
+
+// TenantIdFromContext returns the tenant attached to the context, empty when there is none
+func TenantIdFromContext(ctx context.Context) string {
+	tenantId, _ := ctx.Value(tenantIdKey{}).(string)
+	return tenantId
+}
+
+// AnswerBackends selects the backend answering a request: the tenant's backend from
+// ANSWER_BACKEND_TENANTS first, then the answerBackend of the endpoint policy, then
+// ANSWER_BACKEND
+type AnswerBackends struct {
+	backends    map[string]AnswerBackend
+	defaultName string
+	tenants     map[string]string
+}
+
+// NewAnswerBackends registers the backends the configuration allows. Retrieval-converse
+// needs a generation client and agent an agent client, either may be nil. Every backend
+// named by the configuration must be registered.
+func NewAnswerBackends(cfg *config.Config, kbClient aws.KnowledgeBaseClient, generationClient aws.GenerationClient, agentClient aws.AgentClient) (*AnswerBackends, error) {
+	b := &AnswerBackends{
+		backends:    map[string]AnswerBackend{},
+		defaultName: cfg.AnswerBackend,
+		tenants:     map[string]string{},
+	}
+	if b.defaultName == "" {
+		b.defaultName = AnswerBackendKnowledgeBase
+	}
+	if strings.TrimSpace(cfg.AnswerBackendTenants) != "" {
+		if err := json.Unmarshal([]byte(cfg.AnswerBackendTenants), &b.tenants); err != nil {
+			return nil, fmt.Errorf("invalid ANSWER_BACKEND_TENANTS: %w", err)
+		}
+	}
+
+	b.Register(AnswerBackendKnowledgeBase, NewKnowledgeBaseAnswerBackend(kbClient, cfg))
+	b.Register(AnswerBackendStub, NewStubAnswerBackend(cfg.StubAnswer))
+	if generationClient != nil {
+		b.Register(AnswerBackendRetrievalConverse, NewRetrievalConverseAnswerBackend(kbClient, generationClient, cfg))
+	}
+	if agentClient != nil {
+		b.Register(AnswerBackendAgent, NewAgentAnswerBackend(agentClient))
+	}
+
+	if _, ok := b.backends[b.defaultName]; !ok {
+		return nil, fmt.Errorf("ANSWER_BACKEND %q is not available, available backends: %s", b.defaultName, strings.Join(b.Names(), ", "))
+	}
+	for tenantId, name := range b.tenants {
+		if _, ok := b.backends[name]; !ok {
+			return nil, fmt.Errorf("answer backend %q of tenant %s is not available", name, tenantId)
+		}
+	}
+	return b, nil
+}
+
+// Register adds or replaces a backend
+func (b *AnswerBackends) Register(name string, backend AnswerBackend) {
+	b.backends[name] = backend
+}
+
+// Names returns the registered backends in alphabetical order
+func (b *AnswerBackends) Names() []string {
+	names := make([]string, 0, len(b.backends))
+	for name := range b.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select returns the backend for the request. Policies are reloaded at runtime, so a policy
+// naming an unavailable backend falls back to the default instead of failing requests.
+func (b *AnswerBackends) Select(ctx context.Context) (string, AnswerBackend) {
+	if name, ok := b.tenants[TenantIdFromContext(ctx)]; ok {
+		return name, b.backends[name]
+	}
+	if name := policy.FromContext(ctx, policy.Policy{}).AnswerBackend; name != "" {
+		if backend, ok := b.backends[name]; ok {
+			return name, backend
+		}
+		logger.WithContext(ctx).Warn("Answer backend of endpoint policy is not available", map[string]interface{}{
+			"answer_backend": name,
+		})
+	}
+	return b.defaultName, b.backends[b.defaultName]
+}
+
+// KnowledgeBaseAnswerBackend queries every knowledge base with RetrieveAndGenerate and
+// synthesizes their answers, or queries only the first one in safe mode
+type KnowledgeBaseAnswerBackend struct {
+	client aws.KnowledgeBaseClient
+	config *config.Config
+}
+
+func NewKnowledgeBaseAnswerBackend(client aws.KnowledgeBaseClient, cfg *config.Config) *KnowledgeBaseAnswerBackend {
+	return &KnowledgeBaseAnswerBackend{
+		client: client,
+		config: cfg,
+	}
+}
+
+func (b *KnowledgeBaseAnswerBackend) Answer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	if b.config.SafeMode.Enabled() {
+		warnings.Add(ctx, warnings.CodeSafeMode, "Safe mode is on, the answer comes from a single knowledge base without synthesis")
+		return b.client.QueryKnowledgeBase(ctx, question, enableRelateDocument)
+	}
+	return b.client.QueryMultipleKnowledgeBases(ctx, question, enableRelateDocument)
+}
+
+// RetrievalConverseAnswerBackend retrieves from every knowledge base, merges the chunks by
+// score and generates one answer from the best of them. It costs a single generation
+// instead of one per knowledge base plus synthesis.
+type RetrievalConverseAnswerBackend struct {
+	kbClient         aws.KnowledgeBaseClient
+	generationClient aws.GenerationClient
+	config           *config.Config
+}
+
+func NewRetrievalConverseAnswerBackend(kbClient aws.KnowledgeBaseClient, generationClient aws.GenerationClient, cfg *config.Config) *RetrievalConverseAnswerBackend {
+	return &RetrievalConverseAnswerBackend{
+		kbClient:         kbClient,
+		generationClient: generationClient,
+		config:           cfg,
+	}
+}
+
+func (b *RetrievalConverseAnswerBackend) Answer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	// The request's retrieval settings, else the configured ones with the backend's own
+	// number of chunks
+	settings := aws.RetrievalSettingsFromContext(ctx).Or(aws.RetrievalSettings{
+		NumberOfResults: b.config.MergedRetrievalResults,
+		MinScore:        &b.config.RetrievalMinScore,
+	}).Or(aws.RetrievalSettings{NumberOfResults: 5})
+	endRetrieval := stages.Start(ctx, stages.Retrieval)
+	retrievals, err := b.kbClient.RetrieveFromKnowledgeBases(ctx, question, settings.NumberOfResults)
+	endRetrieval()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var chunks []aws.RetrievedChunk
+	failed := 0
+	for _, retrieval := range retrievals {
+		if retrieval.Error != "" {
+			failed++
+			warnings.Add(ctx, warnings.CodeKnowledgeBaseSkipped, fmt.Sprintf("Knowledge base %s skipped due to query failed, the answer may be incomplete", retrieval.KnowledgeBaseId))
+			continue
+		}
+		chunks = append(chunks, settings.Filter(retrieval.Chunks)...)
+	}
+	if failed > 0 && failed == len(retrievals) {
+		return "", nil, fmt.Errorf("all knowledge base retrievals failed: %s", retrievals[0].Error)
+	}
+	if len(chunks) == 0 {
+		return aws.NoAnswerFor(aws.QuestionLanguageFromContext(ctx)), []string{}, nil
+	}
+
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].Score > chunks[j].Score
+	})
+	if len(chunks) > maxMergedChunks {
+		chunks = chunks[:maxMergedChunks]
+	}
+
+	var prompt strings.Builder
+	documents := []string{}
+	documentSet := make(map[string]bool)
+	for i, chunk := range chunks {
+		fmt.Fprintf(&prompt, "[%d] Source: %s\n%s\n\n", i+1, chunk.SourceUrl, chunk.Content)
+		if chunk.SourceUrl != "" && !documentSet[chunk.SourceUrl] {
+			documentSet[chunk.SourceUrl] = true
+			documents = append(documents, chunk.SourceUrl)
+		}
+	}
+
+	generation := aws.GenerationSettingsFromContext(ctx)
+	endGeneration := stages.Start(ctx, stages.Generation)
+	answer, err := b.generationClient.Generate(ctx, generation.Instructions(b.config.Current().QuestionSearchInstructions), fmt.Sprintf("Question: %s\n\nContext:\n%s", question, prompt.String()), generation.MaxTokensFor(ctx))
+	endGeneration()
+	if err != nil {
+		return "", nil, err
+	}
+	if !enableRelateDocument {
+		documents = []string{}
+	}
+	return answer, documents, nil
+}
+
+// AgentAnswerBackend asks a Bedrock Agent. The chat session is passed on so the agent keeps
+// the conversation history between questions.
+type AgentAnswerBackend struct {
+	client aws.AgentClient
+}
+
+func NewAgentAnswerBackend(client aws.AgentClient) *AgentAnswerBackend {
+	return &AgentAnswerBackend{
+		client: client,
+	}
+}
+
+func (b *AgentAnswerBackend) Answer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	answer, documents, err := b.client.InvokeAgent(ctx, agentSessionId(ctx), question)
+	if err != nil {
+		return "", nil, err
+	}
+	if !enableRelateDocument || documents == nil {
+		documents = []string{}
+	}
+	return answer, documents, nil
+}
+
+// agentSessionId derives an agent session from the chat session. Session keys such as
+// "ip:10.0.0.1" contain characters agents do not accept, so they are hashed.
+func agentSessionId(ctx context.Context) string {
+	sessionId := SessionIdFromContext(ctx)
+	if sessionId == "" {
+		return randomSuffix() + randomSuffix()
+	}
+	sum := sha256.Sum256([]byte(sessionId))
+	return hex.EncodeToString(sum[:16])
+}
+
+// StubAnswerBackend returns a fixed answer without calling AWS
+type StubAnswerBackend struct {
+	answer string
+}
+
+func NewStubAnswerBackend(answer string) *StubAnswerBackend {
+	return &StubAnswerBackend{
+		answer: answer,
+	}
+}
+
+func (b *StubAnswerBackend) Answer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	return b.answer, []string{}, nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/policy"
+)
+
+type recordingGenerationClient struct {
+	answer      string
+	userMessage string
+}
+
+func (c *recordingGenerationClient) Generate(ctx context.Context, systemPrompt string, userMessage string, maxTokens int) (string, error) {
+	c.userMessage = userMessage
+	return c.answer, nil
+}
+
+type recordingAgentClient struct {
+	sessionIds []string
+}
+
+func (c *recordingAgentClient) InvokeAgent(ctx context.Context, sessionId string, question string) (string, []string, error) {
+	c.sessionIds = append(c.sessionIds, sessionId)
+	return "agent answer", []string{"https://bucket.s3.us-east-1.amazonaws.com/fees.pdf"}, nil
+}
+
+func TestAnswerBackends_Select(t *testing.T) {
+	cfg := &config.Config{
+		SafeMode:             config.NewSafeMode(false),
+		AnswerBackendTenants: `{"branch-app": "stub"}`,
+	}
+	backends, err := NewAnswerBackends(cfg, &mockKnowledgeBaseClient{}, &recordingGenerationClient{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected string
+	}{
+		{"default", context.Background(), AnswerBackendKnowledgeBase},
+		{"endpoint policy", policy.WithPolicy(context.Background(), policy.Policy{AnswerBackend: AnswerBackendRetrievalConverse}), AnswerBackendRetrievalConverse},
+		{"unavailable policy backend", policy.WithPolicy(context.Background(), policy.Policy{AnswerBackend: AnswerBackendAgent}), AnswerBackendKnowledgeBase},
+		{"tenant over policy", WithTenantId(policy.WithPolicy(context.Background(), policy.Policy{AnswerBackend: AnswerBackendRetrievalConverse}), "branch-app"), AnswerBackendStub},
+		{"unknown tenant", WithTenantId(context.Background(), "other"), AnswerBackendKnowledgeBase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if name, backend := backends.Select(tt.ctx); name != tt.expected || backend == nil {
+				t.Errorf("expected %s, got %s", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestAnswerBackends_RejectsUnavailableBackends(t *testing.T) {
+	for _, cfg := range []*config.Config{
+		{AnswerBackend: AnswerBackendAgent},
+		{AnswerBackend: "unknown"},
+		{AnswerBackendTenants: `{"branch-app": "agent"}`},
+		{AnswerBackendTenants: `not json`},
+	} {
+		if _, err := NewAnswerBackends(cfg, &mockKnowledgeBaseClient{}, nil, nil); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+
+	if _, err := NewAnswerBackends(&config.Config{AnswerBackend: AnswerBackendAgent}, &mockKnowledgeBaseClient{}, nil, &recordingAgentClient{}); err != nil {
+		t.Errorf("expected the agent backend with an agent client, got %v", err)
+	}
+}
+
+func TestRetrievalConverseAnswerBackend_MergesChunksByScore(t *testing.T) {
+	kbClient := &mockKnowledgeBaseClient{
+		retrieveFunc: func(ctx context.Context, question string, numberOfResults int) ([]aws.KnowledgeBaseRetrieval, error) {
+			var retrievals []aws.KnowledgeBaseRetrieval
+			for kb := 0; kb < 2; kb++ {
+				retrieval := aws.KnowledgeBaseRetrieval{KnowledgeBaseId: fmt.Sprintf("KB%d", kb+1)}
+				for i := 0; i < numberOfResults; i++ {
+					retrieval.Chunks = append(retrieval.Chunks, aws.RetrievedChunk{
+						Content:   fmt.Sprintf("chunk %d-%d", kb+1, i),
+						Score:     float64(kb+1) / float64(i+1),
+						SourceUrl: fmt.Sprintf("https://bucket.s3.us-east-1.amazonaws.com/kb%d-%d.pdf", kb+1, i%2),
+					})
+				}
+				retrievals = append(retrievals, retrieval)
+			}
+			retrievals = append(retrievals, aws.KnowledgeBaseRetrieval{KnowledgeBaseId: "KB3", Error: "throttled"})
+			return retrievals, nil
+		},
+	}
+	generation := &recordingGenerationClient{answer: "merged answer"}
+	backend := NewRetrievalConverseAnswerBackend(kbClient, generation, &config.Config{MergedRetrievalResults: 6})
+
+	answer, documents, err := backend.Answer(context.Background(), "ค่าธรรมเนียม", true)
+	if err != nil || answer != "merged answer" {
+		t.Fatalf("expected the generated answer, got %q %v", answer, err)
+	}
+	if !strings.HasPrefix(generation.userMessage, "Question: ค่าธรรมเนียม") || !strings.Contains(generation.userMessage, "[1] Source: https://bucket.s3.us-east-1.amazonaws.com/kb2-0.pdf\nchunk 2-0") {
+		t.Errorf("expected the best chunk first, got %s", generation.userMessage)
+	}
+	if strings.Contains(generation.userMessage, "[11]") {
+		t.Errorf("expected at most %d chunks, got %s", maxMergedChunks, generation.userMessage)
+	}
+	if len(documents) != 4 || documents[0] != "https://bucket.s3.us-east-1.amazonaws.com/kb2-0.pdf" {
+		t.Errorf("expected unique documents best first, got %v", documents)
+	}
+
+	if _, documents, _ := backend.Answer(context.Background(), "ค่าธรรมเนียม", false); len(documents) != 0 {
+		t.Errorf("expected no documents when they were not requested, got %v", documents)
+	}
+}
+
+func TestRetrievalConverseAnswerBackend_AppliesRetrievalSettings(t *testing.T) {
+	var requested int
+	kbClient := &mockKnowledgeBaseClient{
+		retrieveFunc: func(ctx context.Context, question string, numberOfResults int) ([]aws.KnowledgeBaseRetrieval, error) {
+			requested = numberOfResults
+			return []aws.KnowledgeBaseRetrieval{{KnowledgeBaseId: "KB1", Chunks: []aws.RetrievedChunk{
+				{Content: "WAIVE-03 waives the annual fee", Score: 0.72, SourceUrl: "https://bucket.s3.us-east-1.amazonaws.com/waivers.pdf"},
+				{Content: "branch opening hours", Score: 0.18, SourceUrl: "https://bucket.s3.us-east-1.amazonaws.com/branches.pdf"},
+			}}}, nil
+		},
+	}
+	generation := &recordingGenerationClient{answer: "answer"}
+	backend := NewRetrievalConverseAnswerBackend(kbClient, generation, &config.Config{MergedRetrievalResults: 6, RetrievalMinScore: 0.3})
+
+	_, documents, err := backend.Answer(context.Background(), "WAIVE-03", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requested != 6 || len(documents) != 1 || strings.Contains(generation.userMessage, "opening hours") {
+		t.Errorf("expected 6 chunks requested and the low-score chunk dropped, got %d %v %s", requested, documents, generation.userMessage)
+	}
+
+	// The request's settings take precedence
+	minScore := 0.0
+	ctx := aws.WithRetrievalSettings(context.Background(), aws.RetrievalSettings{NumberOfResults: 20, MinScore: &minScore})
+	if _, documents, _ = backend.Answer(ctx, "WAIVE-03", true); requested != 20 || len(documents) != 2 {
+		t.Errorf("expected the request's settings, got %d %v", requested, documents)
+	}
+}
+
+func TestRetrievalConverseAnswerBackend_NoChunks(t *testing.T) {
+	backend := NewRetrievalConverseAnswerBackend(&mockKnowledgeBaseClient{}, &recordingGenerationClient{}, &config.Config{})
+	if answer, _, err := backend.Answer(context.Background(), "question", false); err != nil || answer != aws.NoAnswerText {
+		t.Errorf("expected the no answer text, got %q %v", answer, err)
+	}
+}
+
+func TestAgentAnswerBackend_KeepsSessionPerChat(t *testing.T) {
+	client := &recordingAgentClient{}
+	backend := NewAgentAnswerBackend(client)
+
+	ctx := WithSessionId(context.Background(), "ip:10.0.0.1")
+	backend.Answer(ctx, "first", true)
+	backend.Answer(ctx, "second", true)
+	backend.Answer(WithSessionId(context.Background(), "id:other"), "third", true)
+
+	if client.sessionIds[0] != client.sessionIds[1] || client.sessionIds[0] == client.sessionIds[2] {
+		t.Errorf("expected one agent session per chat session, got %v", client.sessionIds)
+	}
+	if strings.ContainsAny(client.sessionIds[0], ":.") {
+		t.Errorf("expected a session id agents accept, got %s", client.sessionIds[0])
+	}
+}
+
+func TestSearchAnswer_UsesSelectedBackend(t *testing.T) {
+	cfg := &config.Config{RetryAttempts: 1, SafeMode: config.NewSafeMode(false), AnswerBackend: AnswerBackendStub, StubAnswer: "stub"}
+	mockKB := &mockKnowledgeBaseClient{}
+	backends, err := NewAnswerBackends(cfg, mockKB, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	service := NewBedrockQuestionSearchService(nil, mockKB, nil, backends, cfg)
+
+	answer, _, err := service.SearchAnswer(context.Background(), "question", false)
+	if err != nil || answer != "stub" {
+		t.Fatalf("expected the stub answer, got %q %v", answer, err)
+	}
+	if mockKB.callCount != 0 {
+		t.Errorf("expected no knowledge base queries, got %d", mockKB.callCount)
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/experiments"
+	"teletubpax-api/logger"
+	"teletubpax-api/normalization"
+	"teletubpax-api/policy"
+	"teletubpax-api/stages"
+	"teletubpax-api/storage"
+	"teletubpax-api/warnings"
+)
+
+const (
+	AnswerCacheHit    = "hit"    // The answer was served from the cache
+	AnswerCacheMiss   = "miss"   // The answer was generated, and cached when it could be
+	AnswerCacheBypass = "bypass" // The cache was skipped, e.g. for a follow-up question
+)
+
+// politeEndings are Thai polite particles that do not change a question
+var politeEndings = []string{"ครับผม", "ครับ", "ค่ะ", "คะ", "นะ"}
+
+// AnswerCacheStatus records how the answer cache served a request
+type AnswerCacheStatus struct {
+	mu     sync.Mutex
+	status string
+}
+
+// Status returns the cache status, empty when the cache was not involved
+func (s *AnswerCacheStatus) Status() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *AnswerCacheStatus) set(status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+type answerCacheStatusKey struct{}
+
+// WithAnswerCacheStatus attaches a cache status to the context of a request, for the
+// handler to report whether the answer came from the cache
+func WithAnswerCacheStatus(ctx context.Context) (context.Context, *AnswerCacheStatus) {
+	status := &AnswerCacheStatus{}
+	return context.WithValue(ctx, answerCacheStatusKey{}, status), status
+}
+
+func setAnswerCacheStatus(ctx context.Context, status string) {
+	if s, ok := ctx.Value(answerCacheStatusKey{}).(*AnswerCacheStatus); ok {
+		s.set(status)
+	}
+}
+
+type skipAnswerCacheKey struct{}
+
+// WithoutAnswerCache makes a request generate a fresh answer, which then replaces the
+// cached one
+func WithoutAnswerCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipAnswerCacheKey{}, true)
+}
+
+// CachingQuestionSearchService answers repeated questions from a cache instead of
+// generating the same answer again. Questions are keyed after normalization, case and
+// spacing are ignored. The lifetime is the cacheTtlSeconds of the endpoint policy, or
+// ANSWER_CACHE_TTL_SECONDS; 0 disables caching. No-answer and degraded answers are never
+// cached, and follow-up questions of a conversation always go to the model. Cache failures
+// are logged and the question is answered as if there were no cache.
+type CachingQuestionSearchService struct {
+	next   QuestionSearchService
+	cache  storage.AnswerCache
+	config *config.Config
+}
+
+func NewCachingQuestionSearchService(next QuestionSearchService, cache storage.AnswerCache, cfg *config.Config) *CachingQuestionSearchService {
+	return &CachingQuestionSearchService{
+		next:   next,
+		cache:  cache,
+		config: cfg,
+	}
+}
+
+func (s *CachingQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	ttl := s.ttl(ctx)
+	if ttl <= 0 {
+		return s.next.SearchAnswer(ctx, question, enableRelateDocument)
+	}
+	if conversation := aws.ConversationFromContext(ctx); conversation != nil && conversation.SessionId() != "" {
+		setAnswerCacheStatus(ctx, AnswerCacheBypass)
+		return s.next.SearchAnswer(ctx, question, enableRelateDocument)
+	}
+
+	log := logger.WithContext(ctx)
+	key := answerCacheKey(ctx, question, enableRelateDocument)
+	skip, _ := ctx.Value(skipAnswerCacheKey{}).(bool)
+	if !skip {
+		endLookup := stages.Start(ctx, stages.AnswerCache)
+		cached, err := s.cache.Get(ctx, key)
+		endLookup()
+		if err != nil {
+			log.Warn("Failed to read answer cache", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		if cached != nil {
+			log.Info("Answer served from cache", map[string]interface{}{
+				"cached_at": cached.CachedAt,
+			})
+			setAnswerCacheStatus(ctx, AnswerCacheHit)
+			if cached.Confidence != nil {
+				aws.RecordConfidence(ctx, *cached.Confidence, true)
+			}
+			aws.RecordCitations(ctx, cached.Citations)
+			return cached.Answer, cached.RelatedDocuments, nil
+		}
+	}
+
+	// Collect the warnings of this answer separately, degraded answers are not cached
+	answerCtx, collected := warnings.WithCollector(ctx)
+	// and its confidence and citations, to be cached with it
+	var confidence *aws.ConfidenceRecorder
+	if aws.ConfidenceRecorderFromContext(ctx) != nil {
+		answerCtx, confidence = aws.WithConfidenceRecorder(answerCtx)
+	}
+	var citations *aws.CitationCollector
+	if aws.CitationCollectorFromContext(ctx) != nil {
+		answerCtx, citations = aws.WithCitationCollector(answerCtx)
+	}
+	answer, relatedDocuments, err := s.next.SearchAnswer(answerCtx, question, enableRelateDocument)
+	for _, warning := range collected.List() {
+		warnings.Add(ctx, warning.Code, warning.Message)
+	}
+	var answerConfidence *float64
+	if confidence != nil {
+		if value, ok := confidence.Confidence(); ok {
+			aws.RecordConfidence(ctx, value, true)
+			answerConfidence = &value
+		}
+	}
+	var answerCitations []aws.Citation
+	if citations != nil {
+		answerCitations = citations.List()
+		aws.RecordCitations(ctx, answerCitations)
+	}
+	if skip {
+		setAnswerCacheStatus(ctx, AnswerCacheBypass)
+	} else {
+		setAnswerCacheStatus(ctx, AnswerCacheMiss)
+	}
+	if err != nil || aws.IsNoAnswer(answer) || len(collected.List()) > 0 {
+		return answer, relatedDocuments, err
+	}
+
+	cached := &storage.CachedAnswer{
+		Answer:           answer,
+		RelatedDocuments: relatedDocuments,
+		Confidence:       answerConfidence,
+		Citations:        answerCitations,
+		CachedAt:         time.Now().UTC().Truncate(time.Second),
+	}
+	endStore := stages.Start(ctx, stages.AnswerCache)
+	err = s.cache.Set(ctx, key, cached, ttl)
+	endStore()
+	if err != nil {
+		log.Warn("Failed to cache answer", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return answer, relatedDocuments, nil
+}
+
+func (s *CachingQuestionSearchService) ttl(ctx context.Context) time.Duration {
+	if seconds := policy.FromContext(ctx, policy.Policy{}).CacheTTLSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Duration(s.config.AnswerCacheTTLSeconds) * time.Second
+}
+
+// answerCacheKey identifies the answer to a question. The tenant, the answer backend of
+// the endpoint, the caller's document access, the request's metadata filter, search type,
+// retrieval and generation settings and its experiment variant are part of the key, as they
+// can change the answer.
+func answerCacheKey(ctx context.Context, question string, enableRelateDocument bool) string {
+	backend := policy.FromContext(ctx, policy.Policy{}).AnswerBackend
+	access := aws.DocumentAccessFromContext(ctx).Key()
+	filter := aws.MetadataFilterFromContext(ctx).Key()
+	retrieval := aws.SearchTypeFromContext(ctx) + "/" + aws.RetrievalSettingsFromContext(ctx).Key() + "/" + aws.GenerationSettingsFromContext(ctx).Key()
+	variant := experiments.FromContext(ctx).Key()
+	raw := fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n%s\n%t\n%s", TenantIdFromContext(ctx), backend, access, filter, retrieval, variant, enableRelateDocument, normalizeCacheQuestion(question))
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeCacheQuestion reduces a question to the form it is cached under: normalized
+// wording, lower case, single spaces and no trailing punctuation or polite particles
+func normalizeCacheQuestion(question string) string {
+	normalized, _ := normalization.Normalize(question)
+	normalized = strings.Join(strings.Fields(strings.ToLower(normalized)), " ")
+	for {
+		trimmed := strings.TrimRight(normalized, "?!.。 ")
+		for _, ending := range politeEndings {
+			trimmed = strings.TrimSuffix(trimmed, ending)
+		}
+		if trimmed == normalized {
+			return normalized
+		}
+		normalized = trimmed
+	}
+}
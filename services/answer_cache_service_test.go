@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/experiments"
+	"teletubpax-api/policy"
+	"teletubpax-api/storage"
+	"teletubpax-api/warnings"
+)
+
+// warningQuestionSearchService answers like stubQuestionSearchService and raises a warning
+type warningQuestionSearchService struct {
+	stubQuestionSearchService
+}
+
+func (s *warningQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	warnings.Add(ctx, warnings.CodeSessionExpired, "degraded")
+	return s.stubQuestionSearchService.SearchAnswer(ctx, question, enableRelateDocument)
+}
+
+func TestAnswerCache_ServesRepeatedQuestions(t *testing.T) {
+	next := &stubQuestionSearchService{answer: "ค่าธรรมเนียม 10 บาท"}
+	service := NewCachingQuestionSearchService(next, storage.NewMemoryAnswerCache(10), &config.Config{AnswerCacheTTLSeconds: 60})
+
+	ctx, status := WithAnswerCacheStatus(context.Background())
+	if answer, _, err := service.SearchAnswer(ctx, "ค่าธรรมเนียมโอนเงินเท่าไหร่", false); err != nil || answer != next.answer {
+		t.Fatalf("unexpected answer %q: %v", answer, err)
+	}
+	if status.Status() != AnswerCacheMiss {
+		t.Errorf("expected a miss, got %q", status.Status())
+	}
+
+	// Case, spacing, punctuation and polite particles do not change the question
+	ctx, status = WithAnswerCacheStatus(context.Background())
+	if answer, _, err := service.SearchAnswer(ctx, "  ค่าธรรมเนียมโอนเงินเท่าไหร่ ครับ?", false); err != nil || answer != next.answer {
+		t.Fatalf("unexpected answer %q: %v", answer, err)
+	}
+	if status.Status() != AnswerCacheHit || next.callCount != 1 {
+		t.Errorf("expected a cache hit, got %q with %d calls", status.Status(), next.callCount)
+	}
+
+	// Related documents, tenants, experiment variants and generation settings are cached separately
+	service.SearchAnswer(context.Background(), "ค่าธรรมเนียมโอนเงินเท่าไหร่", true)
+	service.SearchAnswer(WithTenantId(context.Background(), "branch-app"), "ค่าธรรมเนียมโอนเงินเท่าไหร่", false)
+	variant := experiments.WithAssignment(context.Background(), &experiments.Assignment{Experiment: "synthesis-v2", Variant: &experiments.Variant{Name: "new-synthesis"}})
+	service.SearchAnswer(variant, "ค่าธรรมเนียมโอนเงินเท่าไหร่", false)
+	service.SearchAnswer(aws.WithGenerationSettings(context.Background(), aws.GenerationSettings{MaxTokens: 200}), "ค่าธรรมเนียมโอนเงินเท่าไหร่", false)
+	if next.callCount != 5 {
+		t.Errorf("expected separate entries, got %d calls", next.callCount)
+	}
+}
+
+func BenchmarkAnswerCacheKey(b *testing.B) {
+	ctx := WithTenantId(context.Background(), "branch-app")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		answerCacheKey(ctx, "  ค่าธรรมเนียมโอนเงินเท่าไหร่ ครับ?", true)
+	}
+}
+
+func TestAnswerCache_Bypass(t *testing.T) {
+	next := &stubQuestionSearchService{answer: "old"}
+	service := NewCachingQuestionSearchService(next, storage.NewMemoryAnswerCache(10), &config.Config{AnswerCacheTTLSeconds: 60})
+	service.SearchAnswer(context.Background(), "question", false)
+
+	next.answer = "new"
+	ctx, status := WithAnswerCacheStatus(WithoutAnswerCache(context.Background()))
+	if answer, _, _ := service.SearchAnswer(ctx, "question", false); answer != "new" || status.Status() != AnswerCacheBypass {
+		t.Errorf("expected a fresh answer, got %q (%q)", answer, status.Status())
+	}
+	if answer, _, _ := service.SearchAnswer(context.Background(), "question", false); answer != "new" || next.callCount != 2 {
+		t.Errorf("expected the bypass to refresh the cache, got %q with %d calls", answer, next.callCount)
+	}
+
+	// Follow-up questions of a conversation are never cached
+	conversation, _ := aws.ParseConversation("eyJrYi0xIjoic2Vzc2lvbi0xIn0")
+	ctx, status = WithAnswerCacheStatus(aws.WithConversation(context.Background(), conversation))
+	service.SearchAnswer(ctx, "question", false)
+	if next.callCount != 3 || status.Status() != AnswerCacheBypass {
+		t.Errorf("expected the follow-up question to skip the cache, got %q with %d calls", status.Status(), next.callCount)
+	}
+}
+
+func TestAnswerCache_SkipsUncacheableAnswers(t *testing.T) {
+	cfg := &config.Config{AnswerCacheTTLSeconds: 60}
+
+	noAnswer := &stubQuestionSearchService{answer: aws.NoAnswerText}
+	service := NewCachingQuestionSearchService(noAnswer, storage.NewMemoryAnswerCache(10), cfg)
+	service.SearchAnswer(context.Background(), "question", false)
+	service.SearchAnswer(context.Background(), "question", false)
+	if noAnswer.callCount != 2 {
+		t.Errorf("expected no-answer responses not to be cached, got %d calls", noAnswer.callCount)
+	}
+
+	degraded := &warningQuestionSearchService{stubQuestionSearchService{answer: "answer"}}
+	service = NewCachingQuestionSearchService(degraded, storage.NewMemoryAnswerCache(10), cfg)
+	ctx, collected := warnings.WithCollector(context.Background())
+	service.SearchAnswer(ctx, "question", false)
+	service.SearchAnswer(context.Background(), "question", false)
+	if degraded.callCount != 2 {
+		t.Errorf("expected answers with warnings not to be cached, got %d calls", degraded.callCount)
+	}
+	if len(collected.List()) != 1 {
+		t.Errorf("expected the warning to reach the request, got %v", collected.List())
+	}
+}
+
+func TestAnswerCache_TTLFromPolicy(t *testing.T) {
+	next := &stubQuestionSearchService{answer: "answer"}
+	service := NewCachingQuestionSearchService(next, storage.NewMemoryAnswerCache(10), &config.Config{})
+
+	// Disabled without a TTL
+	service.SearchAnswer(context.Background(), "question", false)
+	service.SearchAnswer(context.Background(), "question", false)
+	if next.callCount != 2 {
+		t.Errorf("expected no caching without a TTL, got %d calls", next.callCount)
+	}
+
+	ctx := policy.WithPolicy(context.Background(), policy.Policy{CacheTTLSeconds: 60})
+	service.SearchAnswer(ctx, "question", false)
+	service.SearchAnswer(ctx, "question", false)
+	if next.callCount != 3 {
+		t.Errorf("expected the policy TTL to enable caching, got %d calls", next.callCount)
+	}
+}
+
+// scoredQuestionSearchService answers like stubQuestionSearchService with a confidence and
+// a citation
+type scoredQuestionSearchService struct {
+	stubQuestionSearchService
+	confidence float64
+}
+
+func (s *scoredQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	aws.RecordConfidence(ctx, s.confidence, true)
+	aws.RecordCitations(ctx, []aws.Citation{{Text: s.answer}})
+	return s.stubQuestionSearchService.SearchAnswer(ctx, question, enableRelateDocument)
+}
+
+func TestAnswerCache_KeepsConfidenceAndCitations(t *testing.T) {
+	next := &scoredQuestionSearchService{stubQuestionSearchService{answer: "answer"}, 0.42}
+	service := NewCachingQuestionSearchService(next, storage.NewMemoryAnswerCache(10), &config.Config{AnswerCacheTTLSeconds: 60})
+
+	for i := 0; i < 2; i++ {
+		ctx, recorder := aws.WithConfidenceRecorder(context.Background())
+		ctx, citations := aws.WithCitationCollector(ctx)
+		service.SearchAnswer(ctx, "question", false)
+		if confidence, ok := recorder.Confidence(); !ok || confidence != 0.42 {
+			t.Errorf("request %d: expected the confidence of the answer, got %v %v", i+1, confidence, ok)
+		}
+		if got := citations.List(); len(got) != 1 || got[0].Text != "answer" {
+			t.Errorf("request %d: expected the citation of the answer, got %+v", i+1, got)
+		}
+	}
+	if next.callCount != 1 {
+		t.Errorf("expected the second answer from the cache, got %d calls", next.callCount)
+	}
+}
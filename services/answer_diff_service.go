@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/redaction"
+	"teletubpax-api/utils"
+)
+
+const identicalAnswersSummary = "No material difference"
+
+// AnswerVariantResult is the answer one prompt/model variant gave to the question
+type AnswerVariantResult struct {
+	ModelId          string   `json:"modelId"`
+	Answer           string   `json:"answer"`
+	RelatedDocuments []string `json:"relatedDocuments"`
+	DurationMs       int64    `json:"durationMs"`
+	Error            string   `json:"error,omitempty"`
+}
+
+type AnswerDiff struct {
+	Question    string              `json:"question"`
+	Current     AnswerVariantResult `json:"current"`
+	Candidate   AnswerVariantResult `json:"candidate"`
+	Identical   bool                `json:"identical"`
+	DiffSummary string              `json:"diffSummary"`
+}
+
+type AnswerDiffService interface {
+	Diff(ctx context.Context, question string) (*AnswerDiff, error)
+}
+
+// BedrockAnswerDiffService runs a question through the current and the candidate question
+// search variants and summarizes how the answers differ
+type BedrockAnswerDiffService struct {
+	currentClient    aws.KnowledgeBaseClient
+	candidateClient  aws.KnowledgeBaseClient
+	comparisonClient aws.AnswerComparisonClient
+	config           *config.Config
+}
+
+func NewBedrockAnswerDiffService(
+	currentClient aws.KnowledgeBaseClient,
+	candidateClient aws.KnowledgeBaseClient,
+	comparisonClient aws.AnswerComparisonClient,
+	cfg *config.Config,
+) *BedrockAnswerDiffService {
+	return &BedrockAnswerDiffService{
+		currentClient:    currentClient,
+		candidateClient:  candidateClient,
+		comparisonClient: comparisonClient,
+		config:           cfg,
+	}
+}
+
+func (s *BedrockAnswerDiffService) Diff(ctx context.Context, question string) (*AnswerDiff, error) {
+	log := logger.WithContext(ctx)
+	startTime := time.Now()
+
+	// Both variants prompt in the language of the question, as question search does
+	ctx = aws.WithQuestionLanguage(ctx, utils.DetectLanguage(question))
+
+	settings := s.config.Current()
+	diff := &AnswerDiff{
+		Question:  question,
+		Current:   AnswerVariantResult{ModelId: settings.GenerativeModelId},
+		Candidate: AnswerVariantResult{ModelId: settings.CandidateModelId},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.runVariant(ctx, s.currentClient, question, &diff.Current)
+	}()
+	go func() {
+		defer wg.Done()
+		s.runVariant(ctx, s.candidateClient, question, &diff.Candidate)
+	}()
+	wg.Wait()
+
+	switch {
+	case diff.Current.Error != "" || diff.Candidate.Error != "":
+		diff.DiffSummary = "Unable to compare answers, at least one variant failed"
+	case strings.TrimSpace(diff.Current.Answer) == strings.TrimSpace(diff.Candidate.Answer):
+		diff.Identical = true
+		diff.DiffSummary = identicalAnswersSummary
+	default:
+		summary, err := s.comparisonClient.CompareAnswers(ctx, question, diff.Current.Answer, diff.Candidate.Answer)
+		if err != nil {
+			log.Warn("Failed to compare answers", map[string]interface{}{
+				"error": err.Error(),
+			})
+			diff.DiffSummary = "Unable to compare answers"
+		} else {
+			diff.DiffSummary = summary
+		}
+	}
+
+	log.Info("Answer diff completed", map[string]interface{}{
+		"question":    redaction.Redact(ctx, question),
+		"identical":   diff.Identical,
+		"duration_ms": time.Since(startTime).Milliseconds(),
+	})
+
+	return diff, nil
+}
+
+// runVariant queries one variant the same way question search does, so the diff reflects
+// what users would see
+func (s *BedrockAnswerDiffService) runVariant(ctx context.Context, client aws.KnowledgeBaseClient, question string, result *AnswerVariantResult) {
+	startTime := time.Now()
+
+	var answer string
+	var documents []string
+	var err error
+	if s.config.SafeMode.Enabled() {
+		answer, documents, err = client.QueryKnowledgeBase(ctx, question, true)
+	} else {
+		answer, documents, err = client.QueryMultipleKnowledgeBases(ctx, question, true)
+	}
+
+	result.DurationMs = time.Since(startTime).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	result.Answer = answer
+	result.RelatedDocuments = documents
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"teletubpax-api/config"
+)
+
+type mockAnswerComparisonClient struct {
+	summary   string
+	err       error
+	callCount int
+}
+
+func (m *mockAnswerComparisonClient) CompareAnswers(ctx context.Context, question, answerA, answerB string) (string, error) {
+	m.callCount++
+	return m.summary, m.err
+}
+
+func newAnswerDiffTestClient(answer string, err error) *mockKnowledgeBaseClient {
+	return &mockKnowledgeBaseClient{
+		queryKnowledgeBaseFunc: func(ctx context.Context, question string, enableRelateDocument bool) (string, error) {
+			return answer, err
+		},
+	}
+}
+
+func TestAnswerDiff_SummarizesDifferentAnswers(t *testing.T) {
+	comparer := &mockAnswerComparisonClient{summary: "Candidate omits the fee"}
+	cfg := &config.Config{GenerativeModelId: "model-a", CandidateModelId: "model-b"}
+	service := NewBedrockAnswerDiffService(
+		newAnswerDiffTestClient("Fee is 10 baht", nil),
+		newAnswerDiffTestClient("No fee information", nil),
+		comparer,
+		cfg,
+	)
+
+	diff, err := service.Diff(context.Background(), "What is the fee?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff.Current.ModelId != "model-a" || diff.Candidate.ModelId != "model-b" {
+		t.Errorf("unexpected model ids: %s, %s", diff.Current.ModelId, diff.Candidate.ModelId)
+	}
+	if diff.Identical || diff.DiffSummary != "Candidate omits the fee" {
+		t.Errorf("unexpected diff: identical=%v summary=%q", diff.Identical, diff.DiffSummary)
+	}
+	if comparer.callCount != 1 {
+		t.Errorf("expected comparer to be called once, got %d", comparer.callCount)
+	}
+}
+
+func TestAnswerDiff_SkipsComparisonForIdenticalAnswersOrFailures(t *testing.T) {
+	tests := []struct {
+		name          string
+		candidateErr  error
+		wantIdentical bool
+	}{
+		{name: "identical answers", wantIdentical: true},
+		{name: "candidate failure", candidateErr: errors.New("throttled")},
+	}
+
+	for _, tt := range tests {
+		comparer := &mockAnswerComparisonClient{summary: "should not be used"}
+		service := NewBedrockAnswerDiffService(
+			newAnswerDiffTestClient("Same answer", nil),
+			newAnswerDiffTestClient("Same answer", tt.candidateErr),
+			comparer,
+			&config.Config{},
+		)
+
+		diff, err := service.Diff(context.Background(), "question")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if comparer.callCount != 0 {
+			t.Errorf("%s: expected comparer not to be called", tt.name)
+		}
+		if diff.Identical != tt.wantIdentical {
+			t.Errorf("%s: expected identical=%v, got %v", tt.name, tt.wantIdentical, diff.Identical)
+		}
+		if tt.candidateErr != nil && diff.Candidate.Error != tt.candidateErr.Error() {
+			t.Errorf("%s: expected candidate error to be reported, got %q", tt.name, diff.Candidate.Error)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"teletubpax-api/config"
+	"teletubpax-api/policy"
+)
+
+const (
+	DisclaimerPlacementAppend = "append" // Added below the answer text
+	DisclaimerPlacementField  = "field"  // Returned separately, for the widget to style
+)
+
+// AnswerDisclaimers picks the disclaimer of a request: the tenant's text from
+// DISCLAIMER_TENANTS first, then the disclaimer of the endpoint policy, then
+// ANSWER_DISCLAIMER. Disclaimers are added after the answer is generated and translated,
+// never through the prompts, so they are always returned word for word.
+type AnswerDisclaimers struct {
+	defaultText string
+	tenants     map[string]string
+	placement   string
+}
+
+// NewAnswerDisclaimers validates the disclaimer settings. A tenant mapped to an empty text
+// gets no disclaimer even when the endpoint or default has one.
+func NewAnswerDisclaimers(cfg *config.Config) (*AnswerDisclaimers, error) {
+	d := &AnswerDisclaimers{
+		defaultText: strings.TrimSpace(cfg.AnswerDisclaimer),
+		tenants:     map[string]string{},
+		placement:   cfg.DisclaimerPlacement,
+	}
+	if d.placement == "" {
+		d.placement = DisclaimerPlacementAppend
+	}
+	if d.placement != DisclaimerPlacementAppend && d.placement != DisclaimerPlacementField {
+		return nil, fmt.Errorf("DISCLAIMER_PLACEMENT must be %q or %q", DisclaimerPlacementAppend, DisclaimerPlacementField)
+	}
+
+	if strings.TrimSpace(cfg.DisclaimerTenants) != "" {
+		if err := json.Unmarshal([]byte(cfg.DisclaimerTenants), &d.tenants); err != nil {
+			return nil, fmt.Errorf("invalid DISCLAIMER_TENANTS: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// For returns the disclaimer text of the request, empty when it has none
+func (d *AnswerDisclaimers) For(ctx context.Context) string {
+	if text, ok := d.tenants[TenantIdFromContext(ctx)]; ok {
+		return strings.TrimSpace(text)
+	}
+	if text := strings.TrimSpace(policy.FromContext(ctx, policy.Policy{}).Disclaimer); text != "" {
+		return text
+	}
+	return d.defaultText
+}
+
+// Apply adds the disclaimer of the request to the answer. With the field placement the
+// answer is returned as is along with the disclaimer, otherwise the disclaimer is appended
+// to the answer and the returned disclaimer is empty.
+func (d *AnswerDisclaimers) Apply(ctx context.Context, answer string) (string, string) {
+	text := d.For(ctx)
+	if text == "" {
+		return answer, ""
+	}
+	if d.placement == DisclaimerPlacementField {
+		return answer, text
+	}
+	return answer + "\n\n" + text, ""
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"teletubpax-api/config"
+	"teletubpax-api/policy"
+)
+
+func TestAnswerDisclaimers_For(t *testing.T) {
+	disclaimers, err := NewAnswerDisclaimers(&config.Config{
+		AnswerDisclaimer:  "Default disclaimer",
+		DisclaimerTenants: `{"branch-app": "ข้อมูลนี้ใช้สำหรับพนักงานภายในเท่านั้น", "public-site": ""}`,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	withPolicy := policy.WithPolicy(context.Background(), policy.Policy{Disclaimer: "Endpoint disclaimer"})
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected string
+	}{
+		{"default", context.Background(), "Default disclaimer"},
+		{"endpoint policy", withPolicy, "Endpoint disclaimer"},
+		{"tenant over policy", WithTenantId(withPolicy, "branch-app"), "ข้อมูลนี้ใช้สำหรับพนักงานภายในเท่านั้น"},
+		{"tenant without disclaimer", WithTenantId(withPolicy, "public-site"), ""},
+		{"unknown tenant", WithTenantId(context.Background(), "other"), "Default disclaimer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if text := disclaimers.For(tt.ctx); text != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, text)
+			}
+		})
+	}
+}
+
+func TestAnswerDisclaimers_Apply(t *testing.T) {
+	appended, _ := NewAnswerDisclaimers(&config.Config{AnswerDisclaimer: "Internal use only"})
+	if answer, disclaimer := appended.Apply(context.Background(), "The fee is 100 THB."); answer != "The fee is 100 THB.\n\nInternal use only" || disclaimer != "" {
+		t.Errorf("expected the disclaimer appended, got %q %q", answer, disclaimer)
+	}
+
+	separate, _ := NewAnswerDisclaimers(&config.Config{AnswerDisclaimer: "Internal use only", DisclaimerPlacement: DisclaimerPlacementField})
+	if answer, disclaimer := separate.Apply(context.Background(), "The fee is 100 THB."); answer != "The fee is 100 THB." || disclaimer != "Internal use only" {
+		t.Errorf("expected a separate disclaimer, got %q %q", answer, disclaimer)
+	}
+
+	none, _ := NewAnswerDisclaimers(&config.Config{})
+	if answer, disclaimer := none.Apply(context.Background(), "The fee is 100 THB."); answer != "The fee is 100 THB." || disclaimer != "" {
+		t.Errorf("expected no disclaimer, got %q %q", answer, disclaimer)
+	}
+}
+
+func TestNewAnswerDisclaimers_RejectsInvalidSettings(t *testing.T) {
+	for _, cfg := range []*config.Config{
+		{DisclaimerPlacement: "footer"},
+		{DisclaimerTenants: `not json`},
+	} {
+		if _, err := NewAnswerDisclaimers(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	stdErrors "errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+const (
+	// ApiKeyPrefix starts every API key: "tpx_<id>_<secret>"
+	ApiKeyPrefix = "tpx_"
+
+	apiKeyIdBytes     = 8
+	apiKeySecretBytes = 32
+
+	// apiKeyCacheTTL bounds how long other instances accept a revoked key or keep an old quota
+	apiKeyCacheTTL = 30 * time.Second
+)
+
+// ErrInvalidApiKey is returned for keys that are malformed, unknown or revoked
+var ErrInvalidApiKey = stdErrors.New("invalid or revoked API key")
+
+// ApiKeyQuotaError is returned when a key has used its daily quota
+type ApiKeyQuotaError struct {
+	DailyQuota        int64
+	RetryAfterSeconds int // Until the quota resets at midnight UTC
+}
+
+func (e *ApiKeyQuotaError) Error() string {
+	return fmt.Sprintf("daily quota of %d requests exceeded", e.DailyQuota)
+}
+
+// CreatedApiKey is returned once on creation, the only time the key is shown
+type CreatedApiKey struct {
+	storage.ApiKey
+	Key string `json:"key"`
+}
+
+type ApiKeyService interface {
+	Create(ctx context.Context, name string, tenantId string, dailyQuota int64) (*CreatedApiKey, error)
+	List(ctx context.Context) ([]storage.ApiKey, error)
+	Revoke(ctx context.Context, id string) (bool, error)
+	SetQuota(ctx context.Context, id string, dailyQuota int64) (bool, error)
+	// Authenticate returns the key's record and counts the request against its daily quota
+	Authenticate(ctx context.Context, key string) (*storage.ApiKey, error)
+}
+
+type cachedApiKey struct {
+	key      *storage.ApiKey
+	loadedAt time.Time
+}
+
+// StoreApiKeyService manages API keys in an ApiKeyStore and counts their daily requests in a
+// CounterStore. Keys are cached for a short time, so a revocation or quota change made on
+// another instance applies within apiKeyCacheTTL. Counter failures are logged and let the
+// request through, like session limits.
+type StoreApiKeyService struct {
+	store    storage.ApiKeyStore
+	counters storage.CounterStore
+
+	mu    sync.Mutex
+	cache map[string]cachedApiKey
+}
+
+func NewStoreApiKeyService(store storage.ApiKeyStore, counters storage.CounterStore) *StoreApiKeyService {
+	return &StoreApiKeyService{
+		store:    store,
+		counters: counters,
+		cache:    map[string]cachedApiKey{},
+	}
+}
+
+func (s *StoreApiKeyService) Create(ctx context.Context, name string, tenantId string, dailyQuota int64) (*CreatedApiKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.NewValidationError("name is required")
+	}
+	if dailyQuota < 0 {
+		return nil, errors.NewValidationError("dailyQuota must not be negative")
+	}
+
+	id := make([]byte, apiKeyIdBytes)
+	secret := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	key := storage.ApiKey{
+		Id:         hex.EncodeToString(id),
+		Name:       name,
+		TenantId:   strings.TrimSpace(tenantId),
+		SecretHash: hashApiKeySecret(hex.EncodeToString(secret)),
+		DailyQuota: dailyQuota,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.store.PutApiKey(ctx, &key); err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).Info("API key created", map[string]interface{}{
+		"id":          key.Id,
+		"name":        key.Name,
+		"tenant_id":   key.TenantId,
+		"daily_quota": key.DailyQuota,
+	})
+	return &CreatedApiKey{ApiKey: key, Key: ApiKeyPrefix + key.Id + "_" + hex.EncodeToString(secret)}, nil
+}
+
+// List returns every key, revoked ones included, oldest first
+func (s *StoreApiKeyService) List(ctx context.Context) ([]storage.ApiKey, error) {
+	keys, err := s.store.ListApiKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []storage.ApiKey{}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (s *StoreApiKeyService) Revoke(ctx context.Context, id string) (bool, error) {
+	revoked, err := s.store.RevokeApiKey(ctx, id, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	s.forget(id)
+	if revoked {
+		logger.WithContext(ctx).Info("API key revoked", map[string]interface{}{
+			"id": id,
+		})
+	}
+	return revoked, nil
+}
+
+func (s *StoreApiKeyService) SetQuota(ctx context.Context, id string, dailyQuota int64) (bool, error) {
+	if dailyQuota < 0 {
+		return false, errors.NewValidationError("dailyQuota must not be negative")
+	}
+	updated, err := s.store.SetApiKeyQuota(ctx, id, dailyQuota)
+	if err != nil {
+		return false, err
+	}
+	s.forget(id)
+	if updated {
+		logger.WithContext(ctx).Info("API key quota set", map[string]interface{}{
+			"id":          id,
+			"daily_quota": dailyQuota,
+		})
+	}
+	return updated, nil
+}
+
+func (s *StoreApiKeyService) Authenticate(ctx context.Context, key string) (*storage.ApiKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, ApiKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(key, ApiKeyPrefix) || id == "" || secret == "" {
+		return nil, ErrInvalidApiKey
+	}
+	apiKey, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil || apiKey.RevokedAt != nil ||
+		subtle.ConstantTimeCompare([]byte(hashApiKeySecret(secret)), []byte(apiKey.SecretHash)) != 1 {
+		return nil, ErrInvalidApiKey
+	}
+
+	if apiKey.DailyQuota > 0 {
+		now := time.Now().UTC()
+		day := now.Truncate(24 * time.Hour)
+		counterKey := fmt.Sprintf("api-key-requests#%s#%s", id, day.Format("2006-01-02"))
+		count, err := s.counters.Increment(ctx, counterKey, 1, day.Add(48*time.Hour))
+		if err != nil {
+			logger.WithContext(ctx).Warn("Failed to count API key requests", map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else if count > apiKey.DailyQuota {
+			return nil, &ApiKeyQuotaError{
+				DailyQuota:        apiKey.DailyQuota,
+				RetryAfterSeconds: int(day.Add(24*time.Hour).Sub(now).Seconds()) + 1,
+			}
+		}
+	}
+	return apiKey, nil
+}
+
+// load returns the key from the cache, reading it from the store when it is missing or stale.
+// Unknown IDs are not cached, so made-up keys cannot grow the cache.
+func (s *StoreApiKeyService) load(ctx context.Context, id string) (*storage.ApiKey, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[id]
+	s.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < apiKeyCacheTTL {
+		return cached.key, nil
+	}
+
+	key, err := s.store.GetApiKey(ctx, id)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.cache[id] = cachedApiKey{key: key, loadedAt: time.Now()}
+	s.mu.Unlock()
+	return key, nil
+}
+
+func (s *StoreApiKeyService) forget(id string) {
+	s.mu.Lock()
+	delete(s.cache, id)
+	s.mu.Unlock()
+}
+
+func hashApiKeySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"teletubpax-api/storage"
+)
+
+type memoryApiKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]storage.ApiKey
+	gets int
+}
+
+func newMemoryApiKeyStore() *memoryApiKeyStore {
+	return &memoryApiKeyStore{keys: map[string]storage.ApiKey{}}
+}
+
+func (m *memoryApiKeyStore) GetApiKey(ctx context.Context, id string) (*storage.ApiKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gets++
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+func (m *memoryApiKeyStore) ListApiKeys(ctx context.Context) ([]storage.ApiKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []storage.ApiKey
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *memoryApiKeyStore) PutApiKey(ctx context.Context, key *storage.ApiKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key.Id] = *key
+	return nil
+}
+
+func (m *memoryApiKeyStore) RevokeApiKey(ctx context.Context, id string, revokedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if ok && key.RevokedAt == nil {
+		key.RevokedAt = &revokedAt
+		m.keys[id] = key
+	}
+	return ok, nil
+}
+
+func (m *memoryApiKeyStore) SetApiKeyQuota(ctx context.Context, id string, dailyQuota int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if ok {
+		key.DailyQuota = dailyQuota
+		m.keys[id] = key
+	}
+	return ok, nil
+}
+
+func TestApiKeyService_CreateAndAuthenticate(t *testing.T) {
+	store := newMemoryApiKeyStore()
+	service := NewStoreApiKeyService(store, storage.NewMemoryCounterStore())
+	ctx := context.Background()
+
+	created, err := service.Create(ctx, " treasury portal ", "treasury", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, ApiKeyPrefix+created.Id+"_") || created.Name != "treasury portal" {
+		t.Fatalf("unexpected key %+v", created)
+	}
+	if strings.Contains(store.keys[created.Id].SecretHash, strings.TrimPrefix(created.Key, ApiKeyPrefix+created.Id+"_")) {
+		t.Error("expected only the hash of the secret to be stored")
+	}
+
+	key, err := service.Authenticate(ctx, created.Key)
+	if err != nil || key.TenantId != "treasury" {
+		t.Fatalf("expected the key to authenticate, got %+v, %v", key, err)
+	}
+	if _, err := service.Authenticate(ctx, created.Key); err != nil || store.gets != 1 {
+		t.Errorf("expected the key to be cached, got %v after %d reads", err, store.gets)
+	}
+
+	for _, invalid := range []string{"", "tpx_", created.Key + "x", "tpx_unknown_secret", strings.TrimPrefix(created.Key, ApiKeyPrefix)} {
+		if _, err := service.Authenticate(ctx, invalid); !stdErrors.Is(err, ErrInvalidApiKey) {
+			t.Errorf("expected %q to be rejected, got %v", invalid, err)
+		}
+	}
+
+	if _, err := service.Create(ctx, " ", "", 0); err == nil {
+		t.Error("expected a key without a name to be rejected")
+	}
+	if _, err := service.Create(ctx, "negative", "", -1); err == nil {
+		t.Error("expected a negative quota to be rejected")
+	}
+}
+
+func TestApiKeyService_Revoke(t *testing.T) {
+	service := NewStoreApiKeyService(newMemoryApiKeyStore(), storage.NewMemoryCounterStore())
+	ctx := context.Background()
+	created, _ := service.Create(ctx, "batch", "", 0)
+	if _, err := service.Authenticate(ctx, created.Key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if revoked, err := service.Revoke(ctx, created.Id); err != nil || !revoked {
+		t.Fatalf("expected the key to be revoked, got %t, %v", revoked, err)
+	}
+	if _, err := service.Authenticate(ctx, created.Key); !stdErrors.Is(err, ErrInvalidApiKey) {
+		t.Errorf("expected the revoked key to be rejected at once, got %v", err)
+	}
+	if revoked, _ := service.Revoke(ctx, "unknown"); revoked {
+		t.Error("expected an unknown key not to be revoked")
+	}
+
+	keys, _ := service.List(ctx)
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("expected the revoked key to stay listed, got %+v", keys)
+	}
+}
+
+func TestApiKeyService_DailyQuota(t *testing.T) {
+	service := NewStoreApiKeyService(newMemoryApiKeyStore(), storage.NewMemoryCounterStore())
+	ctx := context.Background()
+	created, _ := service.Create(ctx, "chatbot", "", 2)
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.Authenticate(ctx, created.Key); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i+1, err)
+		}
+	}
+	_, err := service.Authenticate(ctx, created.Key)
+	var quotaErr *ApiKeyQuotaError
+	if !stdErrors.As(err, &quotaErr) || quotaErr.DailyQuota != 2 || quotaErr.RetryAfterSeconds <= 0 || quotaErr.RetryAfterSeconds > 86401 {
+		t.Fatalf("expected the third request to exceed the quota, got %v", err)
+	}
+
+	if updated, err := service.SetQuota(ctx, created.Id, 0); err != nil || !updated {
+		t.Fatalf("expected the quota to be removed, got %t, %v", updated, err)
+	}
+	if _, err := service.Authenticate(ctx, created.Key); err != nil {
+		t.Errorf("expected the key without a quota to authenticate, got %v", err)
+	}
+	if _, err := service.SetQuota(ctx, created.Id, -5); err == nil {
+		t.Error("expected a negative quota to be rejected")
+	}
+	if updated, _ := service.SetQuota(ctx, "unknown", 10); updated {
+		t.Error("expected an unknown key not to be updated")
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+const (
+	AuditOutcomeAnswered      = "answered"
+	AuditOutcomeClarification = "clarification"
+	AuditOutcomeDeleted       = "deleted"
+	AuditOutcomeError         = "error"
+)
+
+// AuditActionDocumentDeletion marks the records of deleted documents, question records have
+// no action
+const AuditActionDocumentDeletion = "document-deletion"
+
+type AuditService interface {
+	// Get returns nil without an error for unknown records
+	Get(ctx context.Context, id string) (*storage.AuditRecord, error)
+}
+
+// StoreAuditService reads the audit trail of an AuditStore
+type StoreAuditService struct {
+	store storage.AuditStore
+}
+
+func NewStoreAuditService(store storage.AuditStore) *StoreAuditService {
+	return &StoreAuditService{
+		store: store,
+	}
+}
+
+func (s *StoreAuditService) Get(ctx context.Context, id string) (*storage.AuditRecord, error) {
+	return s.store.Get(ctx, id)
+}
+
+// AuditQuestionSearchService writes an audit record of every question, answered or not: the
+// caller, the knowledge bases and models used, the documents retrieved and a hash of the
+// answer. A failure to write is logged as an error and the answer is returned anyway.
+type AuditQuestionSearchService struct {
+	next  QuestionSearchService
+	store storage.AuditStore
+}
+
+func NewAuditQuestionSearchService(next QuestionSearchService, store storage.AuditStore) *AuditQuestionSearchService {
+	return &AuditQuestionSearchService{
+		next:  next,
+		store: store,
+	}
+}
+
+func (s *AuditQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	askedAt := time.Now().UTC()
+	ctx, knowledgeBases := aws.WithKnowledgeBaseTrace(ctx)
+	usage := aws.UsageRecorderFromContext(ctx)
+	if usage == nil {
+		ctx, usage = aws.WithUsageRecorder(ctx)
+	}
+	citations := aws.CitationCollectorFromContext(ctx)
+	if citations == nil {
+		ctx, citations = aws.WithCitationCollector(ctx)
+	}
+
+	answer, relatedDocuments, err := s.next.SearchAnswer(ctx, question, enableRelateDocument)
+
+	record := &storage.AuditRecord{
+		Id:                 auditRecordId(askedAt),
+		Timestamp:          askedAt,
+		Caller:             auditCaller(ctx),
+		Question:           question,
+		KnowledgeBases:     knowledgeBases.List(),
+		ModelIds:           []string{},
+		RetrievedDocuments: []string{},
+		Outcome:            AuditOutcomeAnswered,
+	}
+	if record.KnowledgeBases == nil {
+		record.KnowledgeBases = []string{}
+	}
+	for _, modelUsage := range usage.List() {
+		record.ModelIds = append(record.ModelIds, modelUsage.ModelId)
+	}
+	seen := map[string]bool{}
+	addDocument := func(document string) {
+		if document != "" && !seen[document] {
+			seen[document] = true
+			record.RetrievedDocuments = append(record.RetrievedDocuments, document)
+		}
+	}
+	for _, document := range relatedDocuments {
+		addDocument(document)
+	}
+	for _, citation := range citations.List() {
+		for _, source := range citation.Sources {
+			addDocument(source.DocumentUrl)
+		}
+	}
+	switch err.(type) {
+	case nil:
+		sum := sha256.Sum256([]byte(answer))
+		record.AnswerSha256 = hex.EncodeToString(sum[:])
+	case *ClarificationRequiredError:
+		record.Outcome = AuditOutcomeClarification
+	default:
+		record.Outcome = AuditOutcomeError
+	}
+
+	if appendErr := s.store.Append(ctx, record); appendErr != nil {
+		logger.WithContext(ctx).Error("Failed to write audit record", map[string]interface{}{
+			"audit_id": record.Id,
+			"error":    appendErr.Error(),
+		})
+	}
+	return answer, relatedDocuments, err
+}
+
+// auditRecordId starts with the time of the record, which partitions the bucket by day
+func auditRecordId(at time.Time) string {
+	return at.Format("20060102T150405Z") + "-" + randomSuffix() + randomSuffix()
+}
+
+func auditCaller(ctx context.Context) storage.AuditCaller {
+	caller := storage.AuditCaller{
+		TenantId:  TenantIdFromContext(ctx),
+		SessionId: SessionIdFromContext(ctx),
+	}
+	if identity := auth.IdentityFromContext(ctx); identity != nil {
+		caller.UserId = identity.UserId
+		caller.Username = identity.Username
+		caller.Email = identity.Email
+	}
+	return caller
+}
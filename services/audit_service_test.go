@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/storage"
+)
+
+type failingQuestionSearchService struct {
+	err error
+}
+
+func (s *failingQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	return "", nil, s.err
+}
+
+// recordingAuditStore keeps the appended records in order
+type recordingAuditStore struct {
+	*storage.MemoryAuditStore
+	records []storage.AuditRecord
+}
+
+func (s *recordingAuditStore) Append(ctx context.Context, record *storage.AuditRecord) error {
+	s.records = append(s.records, *record)
+	return s.MemoryAuditStore.Append(ctx, record)
+}
+
+func auditQuestion(t *testing.T, store *recordingAuditStore, next QuestionSearchService, ctx context.Context, question string) storage.AuditRecord {
+	t.Helper()
+	NewAuditQuestionSearchService(next, store).SearchAnswer(ctx, question, true)
+	if len(store.records) == 0 || store.records[len(store.records)-1].Question != question {
+		t.Fatalf("expected an audit record of %q", question)
+	}
+	return store.records[len(store.records)-1]
+}
+
+func TestAudit_RecordsEveryQuestion(t *testing.T) {
+	store := &recordingAuditStore{MemoryAuditStore: storage.NewMemoryAuditStore()}
+	ctx := auth.WithIdentity(WithTenantId(context.Background(), "branch"), &auth.Identity{UserId: "user-1", Email: "staff@example.com"})
+
+	answered := auditQuestion(t, store, &citingQuestionSearchService{}, ctx, "fees")
+	sum := sha256.Sum256([]byte("answer to fees"))
+	if answered.Outcome != AuditOutcomeAnswered || answered.AnswerSha256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected the answer hash, got %+v", answered)
+	}
+	if answered.Caller.UserId != "user-1" || answered.Caller.Email != "staff@example.com" || answered.Caller.TenantId != "branch" {
+		t.Errorf("expected the caller identity, got %+v", answered.Caller)
+	}
+	if len(answered.RetrievedDocuments) != 1 || answered.RetrievedDocuments[0] != "https://b/fees.pdf" {
+		t.Errorf("expected the cited document, got %v", answered.RetrievedDocuments)
+	}
+
+	failed := auditQuestion(t, store, &failingQuestionSearchService{err: errors.New("throttled")}, ctx, "rates")
+	if failed.Outcome != AuditOutcomeError || failed.AnswerSha256 != "" {
+		t.Errorf("expected a failed question without an answer hash, got %+v", failed)
+	}
+
+	clarified := auditQuestion(t, store, &failingQuestionSearchService{err: &ClarificationRequiredError{}}, ctx, "card")
+	if clarified.Outcome != AuditOutcomeClarification {
+		t.Errorf("expected a clarification, got %+v", clarified)
+	}
+
+	if stored, _ := NewStoreAuditService(store).Get(context.Background(), answered.Id); stored == nil || stored.Question != "fees" {
+		t.Errorf("expected the record by its ID, got %+v", stored)
+	}
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+const (
+	defaultDigestDays = 7
+	maxDigestDays     = 90
+)
+
+// snsTopicArnPattern matches standard SNS topic ARNs, FIFO topics need a message group and
+// are not supported
+var snsTopicArnPattern = regexp.MustCompile(`^arn:aws[a-z-]*:sns:[a-z0-9-]+:[0-9]{12}:[A-Za-z0-9_-]{1,256}$`)
+
+// DigestChange is one new document version in a digest
+type DigestChange struct {
+	Link             string    `json:"link"`
+	Topic            string    `json:"topic"`
+	Version          int       `json:"version"`
+	PreviousLink     string    `json:"previousLink,omitempty"`
+	ChangeSummary    string    `json:"changeSummary,omitempty"`
+	ChangeDetectedAt time.Time `json:"changeDetectedAt"`
+}
+
+// Digest is the compiled list of document changes in a window, with the rendered message
+type Digest struct {
+	Since   time.Time      `json:"since"`
+	Until   time.Time      `json:"until"`
+	Changes []DigestChange `json:"changes"`
+	Subject string         `json:"subject"`
+	Body    string         `json:"body"`
+}
+
+// DigestSendResult reports a delivery run by subscription id
+type DigestSendResult struct {
+	Changes int      `json:"changes"`
+	Sent    []string `json:"sent"`
+	Failed  []string `json:"failed"`
+}
+
+type DigestService interface {
+	Preview(ctx context.Context, days int) (*Digest, error)
+	Send(ctx context.Context) (*DigestSendResult, error)
+	Subscribe(ctx context.Context, team string, channel string, target string) (*storage.DigestSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]storage.DigestSubscription, error)
+	Unsubscribe(ctx context.Context, id string) (bool, error)
+}
+
+// StoreDigestService compiles the documents change detection found as new versions into a
+// plain-text digest and delivers it to every subscribed team, by email through SES or to an
+// SNS topic. Nothing is sent when there were no changes in the window.
+type StoreDigestService struct {
+	summaryStore  storage.DocumentSummaryStore
+	subscriptions storage.DigestSubscriptionStore
+	notifications aws.NotificationClient
+	config        *config.Config
+}
+
+func NewStoreDigestService(summaryStore storage.DocumentSummaryStore, subscriptions storage.DigestSubscriptionStore, notifications aws.NotificationClient, cfg *config.Config) *StoreDigestService {
+	return &StoreDigestService{
+		summaryStore:  summaryStore,
+		subscriptions: subscriptions,
+		notifications: notifications,
+		config:        cfg,
+	}
+}
+
+// Preview compiles the digest of the last days without sending it, days defaults to the
+// configured digest window
+func (s *StoreDigestService) Preview(ctx context.Context, days int) (*Digest, error) {
+	if days <= 0 {
+		days = s.config.DigestDays
+	}
+	if days <= 0 {
+		days = defaultDigestDays
+	}
+	if days > maxDigestDays {
+		days = maxDigestDays
+	}
+
+	until := time.Now().UTC().Truncate(time.Second)
+	since := until.AddDate(0, 0, -days)
+
+	records, err := s.summaryStore.ListChanges(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]DigestChange, 0, len(records))
+	for _, record := range records {
+		if record.ChangeDetectedAt == nil {
+			continue
+		}
+		changes = append(changes, DigestChange{
+			Link:             record.Link,
+			Topic:            record.Topic,
+			Version:          record.Version,
+			PreviousLink:     record.PreviousLink,
+			ChangeSummary:    record.ChangeSummary,
+			ChangeDetectedAt: record.ChangeDetectedAt.UTC(),
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ChangeDetectedAt.After(changes[j].ChangeDetectedAt)
+	})
+
+	digest := &Digest{Since: since, Until: until, Changes: changes}
+	digest.Subject, digest.Body = renderDigest(digest)
+	return digest, nil
+}
+
+// Send delivers the digest of the configured window to every subscription. A failed
+// delivery is logged and reported, the remaining subscriptions are still sent.
+func (s *StoreDigestService) Send(ctx context.Context) (*DigestSendResult, error) {
+	log := logger.WithContext(ctx)
+
+	digest, err := s.Preview(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DigestSendResult{Changes: len(digest.Changes), Sent: []string{}, Failed: []string{}}
+	if len(digest.Changes) == 0 {
+		log.Info("No document changes for the digest", map[string]interface{}{
+			"since": digest.Since,
+		})
+		return result, nil
+	}
+
+	subscriptions, err := s.subscriptions.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subscription := range subscriptions {
+		if err := s.deliver(ctx, subscription, digest); err != nil {
+			log.Warn("Digest delivery failed", map[string]interface{}{
+				"subscription_id": subscription.Id,
+				"team":            subscription.Team,
+				"channel":         subscription.Channel,
+				"error":           err.Error(),
+			})
+			result.Failed = append(result.Failed, subscription.Id)
+			continue
+		}
+
+		if err := s.subscriptions.MarkSent(ctx, subscription.Id, time.Now()); err != nil {
+			log.Warn("Failed to record digest delivery", map[string]interface{}{
+				"subscription_id": subscription.Id,
+				"error":           err.Error(),
+			})
+		}
+		result.Sent = append(result.Sent, subscription.Id)
+	}
+
+	log.Info("Digest sent", map[string]interface{}{
+		"changes": result.Changes,
+		"sent":    len(result.Sent),
+		"failed":  len(result.Failed),
+	})
+	return result, nil
+}
+
+func (s *StoreDigestService) deliver(ctx context.Context, subscription storage.DigestSubscription, digest *Digest) error {
+	switch subscription.Channel {
+	case storage.DigestChannelEmail:
+		if s.config.DigestSenderEmail == "" {
+			return fmt.Errorf("no digest sender email configured")
+		}
+		return s.notifications.SendEmail(ctx, s.config.DigestSenderEmail, []string{subscription.Target}, digest.Subject, digest.Body)
+	case storage.DigestChannelSNS:
+		return s.notifications.PublishToTopic(ctx, subscription.Target, digest.Subject, digest.Body)
+	default:
+		return fmt.Errorf("unknown digest channel %q", subscription.Channel)
+	}
+}
+
+func (s *StoreDigestService) Subscribe(ctx context.Context, team string, channel string, target string) (*storage.DigestSubscription, error) {
+	team = strings.TrimSpace(team)
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	target = strings.TrimSpace(target)
+
+	if team == "" {
+		return nil, errors.NewValidationError("team is required")
+	}
+	switch channel {
+	case storage.DigestChannelEmail:
+		if s.config.DigestSenderEmail == "" {
+			return nil, errors.NewValidationError("email subscriptions require DIGEST_SENDER_EMAIL to be configured")
+		}
+		if address, err := mail.ParseAddress(target); err != nil || address.Address != target {
+			return nil, errors.NewValidationError("target must be an email address for the email channel")
+		}
+	case storage.DigestChannelSNS:
+		if !snsTopicArnPattern.MatchString(target) {
+			return nil, errors.NewValidationError("target must be a standard SNS topic ARN for the sns channel")
+		}
+	default:
+		return nil, errors.NewValidationError("channel must be \"email\" or \"sns\"")
+	}
+
+	now := time.Now().UTC()
+	subscription := storage.DigestSubscription{
+		Id:        now.Format("20060102T150405Z") + "-" + randomSuffix(),
+		Team:      team,
+		Channel:   channel,
+		Target:    target,
+		CreatedAt: now,
+	}
+	if err := s.subscriptions.PutSubscription(ctx, &subscription); err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).Info("Digest subscription created", map[string]interface{}{
+		"id":      subscription.Id,
+		"team":    subscription.Team,
+		"channel": subscription.Channel,
+	})
+	return &subscription, nil
+}
+
+// ListSubscriptions returns the subscriptions grouped by team, oldest first within a team
+func (s *StoreDigestService) ListSubscriptions(ctx context.Context) ([]storage.DigestSubscription, error) {
+	subscriptions, err := s.subscriptions.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if subscriptions == nil {
+		subscriptions = []storage.DigestSubscription{}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if subscriptions[i].Team != subscriptions[j].Team {
+			return subscriptions[i].Team < subscriptions[j].Team
+		}
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+	return subscriptions, nil
+}
+
+func (s *StoreDigestService) Unsubscribe(ctx context.Context, id string) (bool, error) {
+	return s.subscriptions.DeleteSubscription(ctx, id)
+}
+
+// renderDigest builds the subject and plain-text body shared by email and SNS deliveries.
+// The subject stays ASCII because SNS rejects anything else.
+func renderDigest(digest *Digest) (string, string) {
+	since := digest.Since.Format("2006-01-02")
+	until := digest.Until.Format("2006-01-02")
+	subject := fmt.Sprintf("Document changes %s to %s", since, until)
+
+	var body strings.Builder
+	if len(digest.Changes) == 0 {
+		fmt.Fprintf(&body, "No document changes between %s and %s.\n", since, until)
+		return subject, body.String()
+	}
+
+	fmt.Fprintf(&body, "%d document change(s) between %s and %s.\n", len(digest.Changes), since, until)
+	for i, change := range digest.Changes {
+		fmt.Fprintf(&body, "\n%d. %s (version %d, detected %s)\n", i+1, change.Topic, change.Version, change.ChangeDetectedAt.Format("2006-01-02"))
+		fmt.Fprintf(&body, "   New: %s\n", change.Link)
+		if change.PreviousLink != "" {
+			fmt.Fprintf(&body, "   Previous: %s\n", change.PreviousLink)
+		}
+		if change.ChangeSummary != "" {
+			for _, line := range strings.Split(strings.TrimSpace(change.ChangeSummary), "\n") {
+				fmt.Fprintf(&body, "   %s\n", line)
+			}
+		}
+	}
+	return subject, body.String()
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type memoryDigestSubscriptionStore struct {
+	subscriptions map[string]storage.DigestSubscription
+}
+
+func newMemoryDigestSubscriptionStore(subscriptions ...storage.DigestSubscription) *memoryDigestSubscriptionStore {
+	store := &memoryDigestSubscriptionStore{subscriptions: map[string]storage.DigestSubscription{}}
+	for _, subscription := range subscriptions {
+		store.subscriptions[subscription.Id] = subscription
+	}
+	return store
+}
+
+func (m *memoryDigestSubscriptionStore) ListSubscriptions(ctx context.Context) ([]storage.DigestSubscription, error) {
+	subscriptions := make([]storage.DigestSubscription, 0, len(m.subscriptions))
+	for _, subscription := range m.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+func (m *memoryDigestSubscriptionStore) PutSubscription(ctx context.Context, subscription *storage.DigestSubscription) error {
+	m.subscriptions[subscription.Id] = *subscription
+	return nil
+}
+
+func (m *memoryDigestSubscriptionStore) DeleteSubscription(ctx context.Context, id string) (bool, error) {
+	_, ok := m.subscriptions[id]
+	delete(m.subscriptions, id)
+	return ok, nil
+}
+
+func (m *memoryDigestSubscriptionStore) MarkSent(ctx context.Context, id string, sentAt time.Time) error {
+	if subscription, ok := m.subscriptions[id]; ok {
+		subscription.LastSentAt = &sentAt
+		m.subscriptions[id] = subscription
+	}
+	return nil
+}
+
+type recordingNotificationClient struct {
+	emails    []string
+	topics    []string
+	failTopic string
+}
+
+func (r *recordingNotificationClient) SendEmail(ctx context.Context, from string, to []string, subject string, body string) error {
+	r.emails = append(r.emails, strings.Join(to, ",")+"|"+subject+"|"+body)
+	return nil
+}
+
+func (r *recordingNotificationClient) PublishToTopic(ctx context.Context, topicArn string, subject string, message string) error {
+	if topicArn == r.failTopic {
+		return fmt.Errorf("topic not found")
+	}
+	r.topics = append(r.topics, topicArn)
+	return nil
+}
+
+func digestSummaryStore() *memorySummaryStore {
+	twoDaysAgo := time.Now().UTC().AddDate(0, 0, -2)
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	lastMonth := time.Now().UTC().AddDate(0, 0, -30)
+	return &memorySummaryStore{records: map[string]*storage.DocumentSummaryRecord{
+		"https://b/waive-2.pdf": {
+			Link: "https://b/waive-2.pdf", Topic: "waive", Version: 2, PreviousLink: "https://b/waive-1.pdf",
+			ChangeSummary: "fee waived for students", ChangeDetectedAt: &twoDaysAgo,
+		},
+		"https://b/horaland-3.pdf": {
+			Link: "https://b/horaland-3.pdf", Topic: "horaland", Version: 3, PreviousLink: "https://b/horaland-2.pdf",
+			ChangeSummary: "new branch list", ChangeDetectedAt: &yesterday,
+		},
+		"https://b/promo-2.pdf": {
+			Link: "https://b/promo-2.pdf", Topic: "promo", Version: 2, ChangeDetectedAt: &lastMonth,
+		},
+		"https://b/faq.pdf": {Link: "https://b/faq.pdf", Topic: "faq", Summary: "faq"},
+	}}
+}
+
+func digestConfig() *config.Config {
+	return &config.Config{DigestDays: 7, DigestSenderEmail: "digest@example.com"}
+}
+
+func TestDigestPreview_ListsChangesInWindowNewestFirst(t *testing.T) {
+	service := NewStoreDigestService(digestSummaryStore(), newMemoryDigestSubscriptionStore(), &recordingNotificationClient{}, digestConfig())
+
+	digest, err := service.Preview(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(digest.Changes) != 2 {
+		t.Fatalf("expected 2 changes in the last 7 days, got %+v", digest.Changes)
+	}
+	if digest.Changes[0].Topic != "horaland" || digest.Changes[1].Topic != "waive" {
+		t.Errorf("expected newest change first, got %+v", digest.Changes)
+	}
+	if !strings.Contains(digest.Body, "Previous: https://b/waive-1.pdf") || !strings.Contains(digest.Body, "fee waived for students") {
+		t.Errorf("expected links and change summary in the body, got %q", digest.Body)
+	}
+
+	digest, _ = service.Preview(context.Background(), 60)
+	if len(digest.Changes) != 3 {
+		t.Errorf("expected 3 changes in the last 60 days, got %d", len(digest.Changes))
+	}
+}
+
+func TestDigestSubscribe_ValidatesTargets(t *testing.T) {
+	service := NewStoreDigestService(digestSummaryStore(), newMemoryDigestSubscriptionStore(), &recordingNotificationClient{}, digestConfig())
+
+	invalid := []struct{ team, channel, target string }{
+		{"", "email", "ops@example.com"},
+		{"ops", "slack", "ops@example.com"},
+		{"ops", "email", "not an email"},
+		{"ops", "email", "Ops <ops@example.com>"},
+		{"ops", "sns", "ops@example.com"},
+		{"ops", "sns", "arn:aws:sns:ap-southeast-1:123456789012:digest.fifo"},
+	}
+	for _, c := range invalid {
+		if _, err := service.Subscribe(context.Background(), c.team, c.channel, c.target); err == nil {
+			t.Errorf("expected %+v to be rejected", c)
+		}
+	}
+
+	if _, err := service.Subscribe(context.Background(), "ops", "EMAIL", "ops@example.com"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := service.Subscribe(context.Background(), "branch", "sns", "arn:aws:sns:ap-southeast-1:123456789012:digest"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	noSender := NewStoreDigestService(digestSummaryStore(), newMemoryDigestSubscriptionStore(), &recordingNotificationClient{}, &config.Config{DigestDays: 7})
+	if _, err := noSender.Subscribe(context.Background(), "ops", "email", "ops@example.com"); err == nil {
+		t.Error("expected email subscriptions to require a sender")
+	}
+}
+
+func TestDigestSend_DeliversToEverySubscription(t *testing.T) {
+	subscriptions := newMemoryDigestSubscriptionStore(
+		storage.DigestSubscription{Id: "ops", Team: "ops", Channel: storage.DigestChannelEmail, Target: "ops@example.com"},
+		storage.DigestSubscription{Id: "branch", Team: "branch", Channel: storage.DigestChannelSNS, Target: "arn:aws:sns:ap-southeast-1:123456789012:digest"},
+		storage.DigestSubscription{Id: "gone", Team: "gone", Channel: storage.DigestChannelSNS, Target: "arn:aws:sns:ap-southeast-1:123456789012:gone"},
+	)
+	notifications := &recordingNotificationClient{failTopic: "arn:aws:sns:ap-southeast-1:123456789012:gone"}
+	service := NewStoreDigestService(digestSummaryStore(), subscriptions, notifications, digestConfig())
+
+	result, err := service.Send(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Changes != 2 || len(result.Sent) != 2 || len(result.Failed) != 1 || result.Failed[0] != "gone" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if len(notifications.emails) != 1 || !strings.HasPrefix(notifications.emails[0], "ops@example.com|Document changes") {
+		t.Errorf("unexpected emails %v", notifications.emails)
+	}
+	if len(notifications.topics) != 1 {
+		t.Errorf("expected one topic publish, got %v", notifications.topics)
+	}
+	if subscriptions.subscriptions["ops"].LastSentAt == nil || subscriptions.subscriptions["gone"].LastSentAt != nil {
+		t.Error("expected only delivered subscriptions to be marked sent")
+	}
+}
+
+func TestDigestSend_SkipsEmptyDigest(t *testing.T) {
+	subscriptions := newMemoryDigestSubscriptionStore(
+		storage.DigestSubscription{Id: "ops", Team: "ops", Channel: storage.DigestChannelEmail, Target: "ops@example.com"},
+	)
+	notifications := &recordingNotificationClient{}
+	service := NewStoreDigestService(&memorySummaryStore{}, subscriptions, notifications, digestConfig())
+
+	result, err := service.Send(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Changes != 0 || len(result.Sent) != 0 || len(notifications.emails) != 0 {
+		t.Errorf("expected nothing sent without changes, got %+v", result)
+	}
+}
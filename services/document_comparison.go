@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+)
+
+var ErrComparisonsPaused = stdErrors.New("document comparisons are paused while safe mode is enabled")
+
+// DocumentComparison is the change summary of two documents, parsed from the JSON the
+// document comparison instructions ask for
+type DocumentComparison struct {
+	OlderDocument string   `json:"olderDocument"`
+	NewerDocument string   `json:"newerDocument"`
+	ChangeSummary string   `json:"changeSummary"`
+	KeyChanges    []string `json:"keyChanges"`
+	Cached        bool     `json:"cached"` // Served from the comparison cache, without Bedrock
+}
+
+// CompareDocuments summarizes the changes from one document to another, which need not be
+// versions of the same topic. It uses the comparison cache of GetLastUpdateDocuments, so
+// pairs of adjacent versions are not compared again. An unknown document, or one the
+// caller may not see, is reported as ErrDocumentNotFound.
+func (s *OpenSearchDocumentService) CompareDocuments(ctx context.Context, olderUri string, newerUri string) (*DocumentComparison, error) {
+	log := logger.WithContext(ctx)
+	startTime := time.Now()
+
+	comparison := versionComparison{
+		topic:     comparisonTopic(olderUri, newerUri),
+		olderLink: olderUri,
+		newerLink: newerUri,
+	}
+	for _, content := range []struct {
+		uri  string
+		text *string
+	}{{olderUri, &comparison.olderContent}, {newerUri, &comparison.newerContent}} {
+		text, err := s.openSearchClient.GetDocumentContent(ctx, content.uri)
+		if err != nil {
+			return nil, err
+		}
+		if text == "" {
+			return nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, content.uri)
+		}
+		*content.text = text
+	}
+
+	contentHash := comparison.contentHash()
+	changeSummary := s.cachedComparison(ctx, comparison, contentHash)
+	cached := changeSummary != ""
+	if !cached {
+		if s.config.SafeMode.Enabled() {
+			return nil, ErrComparisonsPaused
+		}
+		var err error
+		changeSummary, err = s.openSearchClient.CompareDocumentVersions(ctx, comparison.newerContent, comparison.olderContent, comparison.topic)
+		if err != nil {
+			log.Error("Failed to compare documents", map[string]interface{}{
+				"older_uri":   olderUri,
+				"newer_uri":   newerUri,
+				"error":       err.Error(),
+				"duration_ms": time.Since(startTime).Milliseconds(),
+			})
+			return nil, err
+		}
+		s.cacheComparison(ctx, comparison, contentHash, changeSummary)
+	}
+
+	result := parseDocumentComparison(changeSummary)
+	result.OlderDocument = olderUri
+	result.NewerDocument = newerUri
+	result.Cached = cached
+
+	log.Info("Documents compared", map[string]interface{}{
+		"older_uri":   olderUri,
+		"newer_uri":   newerUri,
+		"cached":      cached,
+		"key_changes": len(result.KeyChanges),
+		"duration_ms": time.Since(startTime).Milliseconds(),
+	})
+	return result, nil
+}
+
+// comparisonTopic names the compared documents by their file names for the model
+func comparisonTopic(olderUri string, newerUri string) string {
+	olderName := path.Base(aws.UnsignedDocumentLink(olderUri))
+	newerName := path.Base(aws.UnsignedDocumentLink(newerUri))
+	if olderName == newerName {
+		return newerName
+	}
+	return olderName + " / " + newerName
+}
+
+// parseDocumentComparison reads the JSON object in the model output. Output that is not
+// JSON is kept whole as the change summary.
+func parseDocumentComparison(output string) *DocumentComparison {
+	comparison := &DocumentComparison{KeyChanges: []string{}}
+	start, end := strings.Index(output, "{"), strings.LastIndex(output, "}")
+	if start >= 0 && end > start && json.Unmarshal([]byte(output[start:end+1]), comparison) == nil && comparison.ChangeSummary != "" {
+		if comparison.KeyChanges == nil {
+			comparison.KeyChanges = []string{}
+		}
+		return comparison
+	}
+	return &DocumentComparison{ChangeSummary: strings.TrimSpace(output), KeyChanges: []string{}}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"testing"
+
+	"teletubpax-api/config"
+)
+
+type jsonComparisonClient struct {
+	*mockOpenSearchClient
+}
+
+func (m *jsonComparisonClient) CompareDocumentVersions(ctx context.Context, newerContent, olderContent, topic string) (string, error) {
+	m.compareCalls++
+	return `{"version": "v2", "changeSummary": "ค่าธรรมเนียมเพิ่มขึ้น", "keyChanges": ["ค่าธรรมเนียมรายปีเพิ่มจาก 150 บาทเป็น 200 บาท"]}`, nil
+}
+
+func comparedDocuments() *mockOpenSearchClient {
+	return &mockOpenSearchClient{contents: map[string]string{
+		"s3://bucket/content/2025/01/debit-fees.pdf":  "annual fee 150 baht",
+		"s3://bucket/content/2025/05/credit-fees.pdf": "annual fee 200 baht",
+	}}
+}
+
+func TestCompareDocuments_ParsesStructuredSummary(t *testing.T) {
+	client := &jsonComparisonClient{comparedDocuments()}
+	service := NewOpenSearchDocumentService(client, nil, &memoryComparisonStore{}, nil, &config.Config{})
+
+	comparison, err := service.CompareDocuments(context.Background(), "s3://bucket/content/2025/01/debit-fees.pdf", "s3://bucket/content/2025/05/credit-fees.pdf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if comparison.ChangeSummary != "ค่าธรรมเนียมเพิ่มขึ้น" || len(comparison.KeyChanges) != 1 || comparison.Cached {
+		t.Errorf("unexpected comparison %+v", comparison)
+	}
+	if comparison.OlderDocument != "s3://bucket/content/2025/01/debit-fees.pdf" || comparison.NewerDocument != "s3://bucket/content/2025/05/credit-fees.pdf" {
+		t.Errorf("expected the compared documents, got %+v", comparison)
+	}
+
+	// The same pair is served from the comparison cache
+	comparison, err = service.CompareDocuments(context.Background(), "s3://bucket/content/2025/01/debit-fees.pdf", "s3://bucket/content/2025/05/credit-fees.pdf")
+	if err != nil || !comparison.Cached || client.compareCalls != 1 {
+		t.Errorf("expected a cached comparison, got %+v %v after %d calls", comparison, err, client.compareCalls)
+	}
+}
+
+func TestCompareDocuments_KeepsUnstructuredSummary(t *testing.T) {
+	service := NewOpenSearchDocumentService(comparedDocuments(), nil, nil, nil, &config.Config{})
+
+	comparison, err := service.CompareDocuments(context.Background(), "s3://bucket/content/2025/01/debit-fees.pdf", "s3://bucket/content/2025/05/credit-fees.pdf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if comparison.ChangeSummary != "changed from annual fee 150 baht to annual fee 200 baht" || comparison.KeyChanges == nil {
+		t.Errorf("expected the output kept as the change summary, got %+v", comparison)
+	}
+}
+
+func TestCompareDocuments_UnknownDocument(t *testing.T) {
+	client := comparedDocuments()
+	service := NewOpenSearchDocumentService(client, nil, nil, nil, &config.Config{})
+
+	_, err := service.CompareDocuments(context.Background(), "s3://bucket/content/2025/01/debit-fees.pdf", "s3://bucket/content/missing.pdf")
+	if !stdErrors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("expected ErrDocumentNotFound, got %v", err)
+	}
+	if client.compareCalls != 0 {
+		t.Errorf("expected no comparison, got %d", client.compareCalls)
+	}
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+const (
+	// deletedDocumentsLoadTimeout bounds a reload triggered from a retrieval path
+	deletedDocumentsLoadTimeout = 2 * time.Second
+
+	// purgedRecordRetention keeps purged documents excluded until the knowledge base has been
+	// synced and no longer returns their chunks
+	purgedRecordRetention = 30 * 24 * time.Hour
+)
+
+var (
+	ErrDocumentAlreadyDeleted = stdErrors.New("document is already deleted")
+	ErrDocumentNotDeleted     = stdErrors.New("document is not deleted")
+	ErrDocumentPurged         = stdErrors.New("document has already been purged")
+)
+
+// PurgeResult reports a purge run
+type PurgeResult struct {
+	Purged  []string `json:"purged"`
+	Failed  []string `json:"failed"`
+	Pending int      `json:"pending"` // Deleted documents still inside their retention period
+}
+
+type DocumentDeletionService interface {
+	aws.SourceFilter
+	SoftDelete(ctx context.Context, documentUri string) (*storage.DeletedDocument, error)
+	Restore(ctx context.Context, documentUri string) error
+	ListDeleted(ctx context.Context) ([]storage.DeletedDocument, error)
+	Purge(ctx context.Context) (*PurgeResult, error)
+}
+
+// StoreDocumentDeletionService soft-deletes documents by marking them in the store. Marked
+// documents are excluded from retrieval and hidden from listings through ExcludedSourceUris,
+// and their source objects are deleted by Purge once the retention period has passed. The
+// marks are cached and reloaded once they are older than the refresh interval, so deletes
+// reach every instance without a redeploy.
+type StoreDocumentDeletionService struct {
+	store    storage.DeletedDocumentStore
+	objects  aws.ObjectStorageClient
+	config   *config.Config
+	mu       sync.RWMutex
+	excluded []string
+	loadedAt time.Time
+}
+
+func NewStoreDocumentDeletionService(store storage.DeletedDocumentStore, objects aws.ObjectStorageClient, cfg *config.Config) *StoreDocumentDeletionService {
+	return &StoreDocumentDeletionService{
+		store:   store,
+		objects: objects,
+		config:  cfg,
+	}
+}
+
+// SoftDelete marks a document deleted. Accepts either an s3:// URI or the public URL.
+func (s *StoreDocumentDeletionService) SoftDelete(ctx context.Context, documentUri string) (*storage.DeletedDocument, error) {
+	sourceUri, err := documentSourceUri(documentUri)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	document := &storage.DeletedDocument{
+		SourceUri:  sourceUri,
+		Link:       s.publicUrl(sourceUri),
+		DeletedAt:  now,
+		PurgeAfter: now.AddDate(0, 0, s.config.DeletedDocumentRetentionDays),
+	}
+	marked, err := s.store.MarkDeleted(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		return nil, ErrDocumentAlreadyDeleted
+	}
+
+	logger.WithContext(ctx).Info("Document soft-deleted", map[string]interface{}{
+		"source_uri":  sourceUri,
+		"purge_after": document.PurgeAfter,
+	})
+	s.Reload(ctx)
+	return document, nil
+}
+
+// Restore removes the deleted mark of a document that has not been purged yet
+func (s *StoreDocumentDeletionService) Restore(ctx context.Context, documentUri string) error {
+	sourceUri, err := documentSourceUri(documentUri)
+	if err != nil {
+		return err
+	}
+
+	restored, err := s.store.Restore(ctx, sourceUri)
+	if err != nil {
+		return err
+	}
+	if !restored {
+		existing, err := s.store.GetDeleted(ctx, sourceUri)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.PurgedAt != nil {
+			return ErrDocumentPurged
+		}
+		return ErrDocumentNotDeleted
+	}
+
+	logger.WithContext(ctx).Info("Document restored", map[string]interface{}{
+		"source_uri": sourceUri,
+	})
+	s.Reload(ctx)
+	return nil
+}
+
+// ListDeleted returns the deleted documents, most recently deleted first
+func (s *StoreDocumentDeletionService) ListDeleted(ctx context.Context) ([]storage.DeletedDocument, error) {
+	documents, err := s.store.ListDeleted(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if documents == nil {
+		documents = []storage.DeletedDocument{}
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		return documents[i].DeletedAt.After(documents[j].DeletedAt)
+	})
+	return documents, nil
+}
+
+// Purge deletes the source objects of documents past their retention period. Purged
+// documents stay excluded until their record expires, a failed document is retried on the
+// next run.
+func (s *StoreDocumentDeletionService) Purge(ctx context.Context) (*PurgeResult, error) {
+	log := logger.WithContext(ctx)
+
+	documents, err := s.store.ListDeleted(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := &PurgeResult{Purged: []string{}, Failed: []string{}}
+	for _, document := range documents {
+		if document.PurgedAt != nil {
+			continue
+		}
+		if now.Before(document.PurgeAfter) {
+			result.Pending++
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		if err := s.objects.DeleteDocument(ctx, document.SourceUri); err != nil {
+			log.Warn("Failed to delete document object", map[string]interface{}{
+				"source_uri": document.SourceUri,
+				"error":      err.Error(),
+			})
+			result.Failed = append(result.Failed, document.SourceUri)
+			continue
+		}
+		if err := s.store.MarkPurged(ctx, document.SourceUri, now, now.Add(purgedRecordRetention)); err != nil {
+			log.Warn("Failed to mark document purged", map[string]interface{}{
+				"source_uri": document.SourceUri,
+				"error":      err.Error(),
+			})
+			result.Failed = append(result.Failed, document.SourceUri)
+			continue
+		}
+		result.Purged = append(result.Purged, document.SourceUri)
+	}
+
+	log.Info("Deleted document purge finished", map[string]interface{}{
+		"purged":  len(result.Purged),
+		"failed":  len(result.Failed),
+		"pending": result.Pending,
+	})
+	return result, nil
+}
+
+// ExcludedSourceUris returns the s3:// URIs of every deleted document, including purged
+// documents the knowledge base may still return until its next sync
+func (s *StoreDocumentDeletionService) ExcludedSourceUris(ctx context.Context) []string {
+	s.reloadIfStale()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.excluded
+}
+
+// Reload loads the deleted documents again. When the store fails the previous list is kept
+// and the next attempt happens after the refresh interval.
+func (s *StoreDocumentDeletionService) Reload(ctx context.Context) error {
+	documents, err := s.store.ListDeleted(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Now()
+	if err != nil {
+		logger.WithContext(ctx).Warn("Failed to load deleted documents", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	excluded := make([]string, 0, len(documents))
+	for _, document := range documents {
+		excluded = append(excluded, document.SourceUri)
+	}
+	sort.Strings(excluded)
+	s.excluded = excluded
+	return nil
+}
+
+func (s *StoreDocumentDeletionService) reloadIfStale() {
+	ttl := time.Duration(s.config.DeletedDocumentsRefreshSeconds) * time.Second
+
+	s.mu.RLock()
+	stale := s.loadedAt.IsZero() || (ttl > 0 && time.Since(s.loadedAt) > ttl)
+	s.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deletedDocumentsLoadTimeout)
+	defer cancel()
+	s.Reload(ctx)
+}
+
+// documentSourceUri converts the URL of a document to its s3:// URI and validates it
+func documentSourceUri(documentUri string) (string, error) {
+	documentUri = aws.UnsignedDocumentLink(strings.TrimSpace(documentUri))
+
+	re := regexp.MustCompile(`^https://([^.]+)\.s3\.[^.]+\.amazonaws\.com/(.+)$`)
+	if matches := re.FindStringSubmatch(documentUri); len(matches) >= 3 {
+		documentUri = fmt.Sprintf("s3://%s/%s", matches[1], matches[2])
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(documentUri, "s3://"), "/", 2)
+	if !strings.HasPrefix(documentUri, "s3://") || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", errors.NewValidationError("uri must be an s3:// URI or a document URL of the knowledge base bucket")
+	}
+	return documentUri, nil
+}
+
+// publicUrl converts an s3:// URI to the public bucket URL shown in listings
+func (s *StoreDocumentDeletionService) publicUrl(sourceUri string) string {
+	parts := strings.SplitN(strings.TrimPrefix(sourceUri, "s3://"), "/", 2)
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", parts[0], s.config.AWSRegion, parts[1])
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type memoryDeletedDocumentStore struct {
+	documents map[string]storage.DeletedDocument
+}
+
+func newMemoryDeletedDocumentStore(documents ...storage.DeletedDocument) *memoryDeletedDocumentStore {
+	store := &memoryDeletedDocumentStore{documents: map[string]storage.DeletedDocument{}}
+	for _, document := range documents {
+		store.documents[document.SourceUri] = document
+	}
+	return store
+}
+
+func (m *memoryDeletedDocumentStore) ListDeleted(ctx context.Context) ([]storage.DeletedDocument, error) {
+	documents := make([]storage.DeletedDocument, 0, len(m.documents))
+	for _, document := range m.documents {
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+func (m *memoryDeletedDocumentStore) GetDeleted(ctx context.Context, sourceUri string) (*storage.DeletedDocument, error) {
+	document, ok := m.documents[sourceUri]
+	if !ok {
+		return nil, nil
+	}
+	return &document, nil
+}
+
+func (m *memoryDeletedDocumentStore) MarkDeleted(ctx context.Context, document *storage.DeletedDocument) (bool, error) {
+	if _, ok := m.documents[document.SourceUri]; ok {
+		return false, nil
+	}
+	m.documents[document.SourceUri] = *document
+	return true, nil
+}
+
+func (m *memoryDeletedDocumentStore) Restore(ctx context.Context, sourceUri string) (bool, error) {
+	document, ok := m.documents[sourceUri]
+	if !ok || document.PurgedAt != nil {
+		return false, nil
+	}
+	delete(m.documents, sourceUri)
+	return true, nil
+}
+
+func (m *memoryDeletedDocumentStore) MarkPurged(ctx context.Context, sourceUri string, purgedAt time.Time, expiresAt time.Time) error {
+	document := m.documents[sourceUri]
+	document.PurgedAt = &purgedAt
+	document.ExpiresAt = expiresAt.Unix()
+	m.documents[sourceUri] = document
+	return nil
+}
+
+type mockObjectStorageClient struct {
+	deleted []string
+	err     error
+}
+
+func (m *mockObjectStorageClient) DeleteDocument(ctx context.Context, s3Uri string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.deleted = append(m.deleted, s3Uri)
+	return nil
+}
+
+func deletionConfig() *config.Config {
+	return &config.Config{AWSRegion: "us-east-1", DeletedDocumentRetentionDays: 30, DeletedDocumentsRefreshSeconds: 60}
+}
+
+func TestSoftDelete_ExcludesDocumentUntilRestored(t *testing.T) {
+	ctx := context.Background()
+	service := NewStoreDocumentDeletionService(newMemoryDeletedDocumentStore(), &mockObjectStorageClient{}, deletionConfig())
+
+	if excluded := service.ExcludedSourceUris(ctx); len(excluded) != 0 {
+		t.Fatalf("expected nothing excluded, got %v", excluded)
+	}
+
+	document, err := service.SoftDelete(ctx, "https://docs.s3.us-east-1.amazonaws.com/policies/fees-2.pdf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if document.SourceUri != "s3://docs/policies/fees-2.pdf" {
+		t.Errorf("expected the public URL converted to an s3 URI, got %q", document.SourceUri)
+	}
+	if got := document.PurgeAfter.Sub(document.DeletedAt); got != 30*24*time.Hour {
+		t.Errorf("expected a 30 day retention, got %v", got)
+	}
+
+	excluded := service.ExcludedSourceUris(ctx)
+	if len(excluded) != 1 || excluded[0] != "s3://docs/policies/fees-2.pdf" {
+		t.Fatalf("expected the deleted document excluded right away, got %v", excluded)
+	}
+
+	if _, err := service.SoftDelete(ctx, "s3://docs/policies/fees-2.pdf"); !stdErrors.Is(err, ErrDocumentAlreadyDeleted) {
+		t.Errorf("expected ErrDocumentAlreadyDeleted, got %v", err)
+	}
+
+	if err := service.Restore(ctx, "s3://docs/policies/fees-2.pdf"); err != nil {
+		t.Fatalf("unexpected restore error: %v", err)
+	}
+	if excluded := service.ExcludedSourceUris(ctx); len(excluded) != 0 {
+		t.Errorf("expected the restored document back in retrieval, got %v", excluded)
+	}
+	if err := service.Restore(ctx, "s3://docs/policies/fees-2.pdf"); !stdErrors.Is(err, ErrDocumentNotDeleted) {
+		t.Errorf("expected ErrDocumentNotDeleted, got %v", err)
+	}
+}
+
+func TestSoftDelete_RejectsUnknownUri(t *testing.T) {
+	service := NewStoreDocumentDeletionService(newMemoryDeletedDocumentStore(), &mockObjectStorageClient{}, deletionConfig())
+
+	for _, uri := range []string{"https://example.com/fees.pdf", "s3://docs", "fees.pdf"} {
+		if _, err := service.SoftDelete(context.Background(), uri); err == nil {
+			t.Errorf("expected %q to be rejected", uri)
+		}
+	}
+}
+
+func TestPurge_DeletesDocumentsPastRetention(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	purgedAt := now.Add(-time.Hour)
+	store := newMemoryDeletedDocumentStore(
+		storage.DeletedDocument{SourceUri: "s3://docs/old.pdf", DeletedAt: now.AddDate(0, 0, -31), PurgeAfter: now.AddDate(0, 0, -1)},
+		storage.DeletedDocument{SourceUri: "s3://docs/new.pdf", DeletedAt: now, PurgeAfter: now.AddDate(0, 0, 30)},
+		storage.DeletedDocument{SourceUri: "s3://docs/gone.pdf", DeletedAt: now.AddDate(0, 0, -40), PurgeAfter: now.AddDate(0, 0, -10), PurgedAt: &purgedAt},
+	)
+	objects := &mockObjectStorageClient{}
+	service := NewStoreDocumentDeletionService(store, objects, deletionConfig())
+
+	result, err := service.Purge(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Purged) != 1 || result.Purged[0] != "s3://docs/old.pdf" {
+		t.Errorf("expected only the expired document purged, got %v", result.Purged)
+	}
+	if result.Pending != 1 {
+		t.Errorf("expected 1 pending document, got %d", result.Pending)
+	}
+	if len(objects.deleted) != 1 || objects.deleted[0] != "s3://docs/old.pdf" {
+		t.Errorf("expected one object deleted, got %v", objects.deleted)
+	}
+	if store.documents["s3://docs/old.pdf"].PurgedAt == nil {
+		t.Error("expected the purged document to be marked purged")
+	}
+
+	// Purged documents stay excluded and can no longer be restored
+	if excluded := service.ExcludedSourceUris(ctx); len(excluded) != 3 {
+		t.Errorf("expected purged documents to stay excluded, got %v", excluded)
+	}
+	if err := service.Restore(ctx, "s3://docs/old.pdf"); !stdErrors.Is(err, ErrDocumentPurged) {
+		t.Errorf("expected ErrDocumentPurged, got %v", err)
+	}
+}
+
+func TestPurge_KeepsFailedDocumentsForNextRun(t *testing.T) {
+	now := time.Now().UTC()
+	store := newMemoryDeletedDocumentStore(
+		storage.DeletedDocument{SourceUri: "s3://docs/old.pdf", DeletedAt: now.AddDate(0, 0, -31), PurgeAfter: now.AddDate(0, 0, -1)},
+	)
+	service := NewStoreDocumentDeletionService(store, &mockObjectStorageClient{err: stdErrors.New("access denied")}, deletionConfig())
+
+	result, err := service.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Failed) != 1 || len(result.Purged) != 0 {
+		t.Errorf("expected the document to fail, got %+v", result)
+	}
+	if store.documents["s3://docs/old.pdf"].PurgedAt != nil {
+		t.Error("expected a failed document to stay unpurged")
+	}
+}
@@ -1,126 +1,342 @@
-package services
-
-import (
-	"context"
-	"time"
-
-	"teletubpax-api/aws"
-	"teletubpax-api/config"
-	"teletubpax-api/logger"
-)
-
-type DocumentDetailsService interface {
-	GetLastUpdateDocuments(ctx context.Context) ([]map[string]interface{}, error)
-}
-
-type OpenSearchDocumentService struct {
-	openSearchClient aws.OpenSearchClient
-	config           *config.Config
-}
-
-func NewOpenSearchDocumentService(
-	openSearchClient aws.OpenSearchClient,
-	cfg *config.Config,
-) *OpenSearchDocumentService {
-	return &OpenSearchDocumentService{
-		openSearchClient: openSearchClient,
-		config:           cfg,
-	}
-}
-
-func (s *OpenSearchDocumentService) GetLastUpdateDocuments(ctx context.Context) ([]map[string]interface{}, error) {
-	log := logger.WithContext(ctx)
-	log.Info("Fetching last updated documents from OpenSearch", map[string]interface{}{})
-	startTime := time.Now()
-
-	// Query OpenSearch for documents
-	documents, err := s.openSearchClient.GetLastUpdateDocuments(ctx)
-	if err != nil {
-		duration := time.Since(startTime)
-		log.Error("Failed to fetch documents from OpenSearch", map[string]interface{}{
-			"error":       err.Error(),
-			"duration_ms": duration.Milliseconds(),
-		})
-		return nil, err
-	}
-
-	// For each document, check if there's an older version and compare
-	for i, doc := range documents {
-		topic, _ := doc["topic"].(string)
-		currentVersion, _ := doc["version"].(int)
-
-		// Find older version with same topic
-		olderDoc := s.findOlderVersion(documents, topic, currentVersion, i)
-
-		if olderDoc != nil {
-			olderVersion, _ := olderDoc["version"].(int)
-			log.Info("Found older version for comparison", map[string]interface{}{
-				"topic":           topic,
-				"current_version": currentVersion,
-				"older_version":   olderVersion,
-			})
-
-			// Compare versions using Bedrock
-			newerContent, _ := doc["content"].(string)
-			olderContent, _ := olderDoc["content"].(string)
-
-			if newerContent != "" && olderContent != "" {
-				log.Info("Comparing document versions", map[string]interface{}{
-					"topic":                topic,
-					"newer_content_length": len(newerContent),
-					"older_content_length": len(olderContent),
-				})
-
-				changeSummary, err := s.openSearchClient.CompareDocumentVersions(ctx, newerContent, olderContent, topic)
-				if err != nil {
-					log.Warn("Failed to compare document versions", map[string]interface{}{
-						"topic": topic,
-						"error": err.Error(),
-					})
-					documents[i]["changeSummary"] = "Unable to compare versions"
-				} else {
-					log.Info("Version comparison successful", map[string]interface{}{
-						"topic":          topic,
-						"summary_length": len(changeSummary),
-					})
-					documents[i]["changeSummary"] = changeSummary
-				}
-			} else {
-				log.Warn("Missing content for version comparison", map[string]interface{}{
-					"topic":             topic,
-					"has_newer_content": newerContent != "",
-					"has_older_content": olderContent != "",
-				})
-			}
-		}
-
-		// Remove content field from final response (not needed in API response)
-		delete(documents[i], "content")
-	}
-
-	duration := time.Since(startTime)
-	log.Info("Documents retrieved successfully", map[string]interface{}{
-		"duration_ms":    duration.Milliseconds(),
-		"document_count": len(documents),
-	})
-
-	return documents, nil
-}
-
-// findOlderVersion finds an older version of the same topic
-func (s *OpenSearchDocumentService) findOlderVersion(documents []map[string]interface{}, topic string, currentVersion int, currentIndex int) map[string]interface{} {
-	for i, doc := range documents {
-		if i == currentIndex {
-			continue // Skip the current document
-		}
-
-		docTopic, _ := doc["topic"].(string)
-		docVersion, _ := doc["version"].(int)
-
-		// Same topic but older version
-		if docTopic == topic && docVersion < currentVersion {
-			return doc
-		}
-	}
-	return nil
-}
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+	"teletubpax-api/warnings"
+)
+
+const (
+	defaultComparisonWorkers = 4                   // Applies when ComparisonWorkers is not configured
+	comparisonCacheTTL       = 90 * 24 * time.Hour // Cached comparisons of versions no longer listed expire
+)
+
+type DocumentDetailsService interface {
+	GetLastUpdateDocuments(ctx context.Context) ([]map[string]interface{}, error)
+	GetDocumentChunks(ctx context.Context, documentUri string) ([]aws.DocumentChunk, error)
+	GetDocumentPreview(ctx context.Context, documentUri string, query string, length int) (*DocumentPreview, error)
+	GetDocumentVersions(ctx context.Context, topic string) ([]DocumentVersion, error)
+	CompareDocuments(ctx context.Context, olderUri string, newerUri string) (*DocumentComparison, error)
+}
+
+type OpenSearchDocumentService struct {
+	openSearchClient aws.OpenSearchClient
+	summaryStore     storage.DocumentSummaryStore   // Optional precomputed summaries
+	comparisonStore  storage.VersionComparisonStore // Optional cache of Bedrock version comparisons
+	events           DocumentEventPublisher         // Optional, told about new Bedrock comparisons
+	config           *config.Config
+}
+
+func NewOpenSearchDocumentService(
+	openSearchClient aws.OpenSearchClient,
+	summaryStore storage.DocumentSummaryStore,
+	comparisonStore storage.VersionComparisonStore,
+	events DocumentEventPublisher,
+	cfg *config.Config,
+) *OpenSearchDocumentService {
+	return &OpenSearchDocumentService{
+		openSearchClient: openSearchClient,
+		summaryStore:     summaryStore,
+		comparisonStore:  comparisonStore,
+		events:           events,
+		config:           cfg,
+	}
+}
+
+func (s *OpenSearchDocumentService) GetLastUpdateDocuments(ctx context.Context) ([]map[string]interface{}, error) {
+	log := logger.WithContext(ctx)
+	log.Info("Fetching last updated documents from OpenSearch", map[string]interface{}{})
+	startTime := time.Now()
+
+	// Query OpenSearch for documents
+	documents, err := s.openSearchClient.GetLastUpdateDocuments(ctx)
+	if err != nil {
+		duration := time.Since(startTime)
+		log.Error("Failed to fetch documents from OpenSearch", map[string]interface{}{
+			"error":       err.Error(),
+			"duration_ms": duration.Milliseconds(),
+		})
+		return nil, err
+	}
+
+	// Pair each document with its older version. The comparisons run in parallel below.
+	var comparisons []versionComparison
+	for i, doc := range documents {
+		topic, _ := doc["topic"].(string)
+		currentVersion, _ := doc["version"].(int)
+
+		// Prefer the change summary precomputed by the re-summarization job
+		if changeSummary := s.precomputedChangeSummary(ctx, doc); changeSummary != "" {
+			documents[i]["changeSummary"] = changeSummary
+			continue
+		}
+
+		// Find older version with same topic
+		olderDoc := s.findOlderVersion(documents, topic, currentVersion, i)
+		if olderDoc == nil {
+			continue
+		}
+
+		olderVersion, _ := olderDoc["version"].(int)
+		log.Info("Found older version for comparison", map[string]interface{}{
+			"topic":           topic,
+			"current_version": currentVersion,
+			"older_version":   olderVersion,
+		})
+
+		newerContent, _ := doc["content"].(string)
+		olderContent, _ := olderDoc["content"].(string)
+		if newerContent == "" || olderContent == "" {
+			log.Warn("Missing content for version comparison", map[string]interface{}{
+				"topic":             topic,
+				"has_newer_content": newerContent != "",
+				"has_older_content": olderContent != "",
+			})
+			continue
+		}
+
+		newerLink, _ := doc["link"].(string)
+		olderLink, _ := olderDoc["link"].(string)
+		comparisons = append(comparisons, versionComparison{
+			index:        i,
+			topic:        topic,
+			newerLink:    newerLink,
+			olderLink:    olderLink,
+			newerContent: newerContent,
+			olderContent: olderContent,
+		})
+	}
+
+	for i, changeSummary := range s.compareVersions(ctx, comparisons) {
+		if changeSummary != "" {
+			documents[comparisons[i].index]["changeSummary"] = changeSummary
+		}
+	}
+
+	// Remove content field from final response (not needed in API response)
+	for i := range documents {
+		delete(documents[i], "content")
+	}
+
+	duration := time.Since(startTime)
+	log.Info("Documents retrieved successfully", map[string]interface{}{
+		"duration_ms":    duration.Milliseconds(),
+		"document_count": len(documents),
+	})
+
+	return documents, nil
+}
+
+// GetDocumentChunks returns how a single document was chunked in the knowledge base
+func (s *OpenSearchDocumentService) GetDocumentChunks(ctx context.Context, documentUri string) ([]aws.DocumentChunk, error) {
+	log := logger.WithContext(ctx)
+	startTime := time.Now()
+
+	chunks, err := s.openSearchClient.GetDocumentChunks(ctx, documentUri)
+	if err != nil {
+		log.Error("Failed to fetch document chunks", map[string]interface{}{
+			"uri":         documentUri,
+			"error":       err.Error(),
+			"duration_ms": time.Since(startTime).Milliseconds(),
+		})
+		return nil, err
+	}
+
+	log.Info("Document chunks retrieved successfully", map[string]interface{}{
+		"uri":         documentUri,
+		"chunk_count": len(chunks),
+		"duration_ms": time.Since(startTime).Milliseconds(),
+	})
+
+	return chunks, nil
+}
+
+// precomputedChangeSummary returns the stored change summary for a document, or "" when
+// no store is configured or nothing has been precomputed yet
+func (s *OpenSearchDocumentService) precomputedChangeSummary(ctx context.Context, doc map[string]interface{}) string {
+	if s.summaryStore == nil {
+		return ""
+	}
+
+	link, _ := doc["link"].(string)
+	record, err := s.summaryStore.GetSummary(ctx, aws.UnsignedDocumentLink(link))
+	if err != nil {
+		logger.WithContext(ctx).Warn("Failed to read precomputed summary", map[string]interface{}{
+			"link":  link,
+			"error": err.Error(),
+		})
+		return ""
+	}
+	if record == nil {
+		return ""
+	}
+	return record.ChangeSummary
+}
+
+// versionComparison is a document to be compared with the older version of its topic
+type versionComparison struct {
+	index        int // Of the newer document in the listing
+	topic        string
+	newerLink    string
+	olderLink    string
+	newerContent string
+	olderContent string
+}
+
+// contentHash identifies the compared contents, so a cached comparison is only reused for
+// the same versions
+func (c versionComparison) contentHash() string {
+	sum := sha256.Sum256([]byte(c.olderContent + "\x00" + c.newerContent))
+	return hex.EncodeToString(sum[:])
+}
+
+// compareVersions returns the change summary of every comparison, "" when there is none,
+// comparing with at most ComparisonWorkers goroutines
+func (s *OpenSearchDocumentService) compareVersions(ctx context.Context, comparisons []versionComparison) []string {
+	workers := s.config.ComparisonWorkers
+	if workers <= 0 {
+		workers = defaultComparisonWorkers
+	}
+
+	changeSummaries := make([]string, len(comparisons))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(comparisons); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				changeSummaries[i] = s.compareVersion(ctx, comparisons[i])
+			}
+		}()
+	}
+	for i := range comparisons {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return changeSummaries
+}
+
+// compareVersion serves a cached comparison of the same contents, and otherwise compares
+// the versions with Bedrock, caches the result and announces it to the document events. Safe
+// mode skips Bedrock comparisons, so only precomputed and cached change summaries are served.
+func (s *OpenSearchDocumentService) compareVersion(ctx context.Context, comparison versionComparison) string {
+	log := logger.WithContext(ctx)
+	contentHash := comparison.contentHash()
+
+	if changeSummary := s.cachedComparison(ctx, comparison, contentHash); changeSummary != "" {
+		return changeSummary
+	}
+
+	if s.config.SafeMode.Enabled() {
+		warnings.Add(ctx, warnings.CodeSafeMode, "Safe mode is on, only precomputed change summaries are shown")
+		return ""
+	}
+
+	log.Info("Comparing document versions", map[string]interface{}{
+		"topic":                comparison.topic,
+		"newer_content_length": len(comparison.newerContent),
+		"older_content_length": len(comparison.olderContent),
+	})
+
+	changeSummary, err := s.openSearchClient.CompareDocumentVersions(ctx, comparison.newerContent, comparison.olderContent, comparison.topic)
+	if err != nil {
+		log.Warn("Failed to compare document versions", map[string]interface{}{
+			"topic": comparison.topic,
+			"error": err.Error(),
+		})
+		warnings.Add(ctx, warnings.CodeComparisonFailed, fmt.Sprintf("Changes of %s could not be summarized", comparison.topic))
+		return "Unable to compare versions"
+	}
+
+	log.Info("Version comparison successful", map[string]interface{}{
+		"topic":          comparison.topic,
+		"summary_length": len(changeSummary),
+	})
+	s.cacheComparison(ctx, comparison, contentHash, changeSummary)
+	if s.events != nil {
+		s.events.ComparisonCompleted(ctx, DocumentComparisonEvent{
+			Topic:         comparison.topic,
+			Link:          comparison.newerLink,
+			PreviousLink:  comparison.olderLink,
+			ChangeSummary: changeSummary,
+		})
+	}
+	return changeSummary
+}
+
+// cachedComparison returns the cached change summary of the versions, or "" when no cache
+// is configured or the versions were not compared with these contents
+func (s *OpenSearchDocumentService) cachedComparison(ctx context.Context, comparison versionComparison, contentHash string) string {
+	if s.comparisonStore == nil {
+		return ""
+	}
+
+	// Pre-signed links change on every listing, the comparison is kept under the bucket URLs
+	olderLink, newerLink := aws.UnsignedDocumentLink(comparison.olderLink), aws.UnsignedDocumentLink(comparison.newerLink)
+	record, err := s.comparisonStore.GetComparison(ctx, olderLink, newerLink)
+	if err != nil {
+		logger.WithContext(ctx).Warn("Failed to read cached version comparison", map[string]interface{}{
+			"topic": comparison.topic,
+			"error": err.Error(),
+		})
+		return ""
+	}
+	if record == nil || record.ContentHash != contentHash {
+		return ""
+	}
+	return record.ChangeSummary
+}
+
+// cacheComparison stores a change summary, a failed write only costs a later comparison
+func (s *OpenSearchDocumentService) cacheComparison(ctx context.Context, comparison versionComparison, contentHash string, changeSummary string) {
+	if s.comparisonStore == nil {
+		return
+	}
+
+	olderLink, newerLink := aws.UnsignedDocumentLink(comparison.olderLink), aws.UnsignedDocumentLink(comparison.newerLink)
+	now := time.Now().UTC()
+	err := s.comparisonStore.PutComparison(ctx, &storage.VersionComparisonRecord{
+		Key:           storage.VersionComparisonKey(olderLink, newerLink),
+		OlderLink:     olderLink,
+		NewerLink:     newerLink,
+		ContentHash:   contentHash,
+		ChangeSummary: changeSummary,
+		ComparedAt:    now,
+		ExpiresAt:     now.Add(comparisonCacheTTL).Unix(),
+	})
+	if err != nil {
+		logger.WithContext(ctx).Warn("Failed to cache version comparison", map[string]interface{}{
+			"topic": comparison.topic,
+			"error": err.Error(),
+		})
+	}
+}
+
+// findOlderVersion finds an older version of the same topic
+func (s *OpenSearchDocumentService) findOlderVersion(documents []map[string]interface{}, topic string, currentVersion int, currentIndex int) map[string]interface{} {
+	for i, doc := range documents {
+		if i == currentIndex {
+			continue // Skip the current document
+		}
+
+		docTopic, _ := doc["topic"].(string)
+		docVersion, _ := doc["version"].(int)
+
+		// Same topic but older version
+		if docTopic == topic && docVersion < currentVersion {
+			return doc
+		}
+	}
+	return nil
+}
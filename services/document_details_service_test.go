@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type memoryComparisonStore struct {
+	mu      sync.Mutex
+	records map[string]*storage.VersionComparisonRecord
+}
+
+func (m *memoryComparisonStore) GetComparison(ctx context.Context, olderLink, newerLink string) (*storage.VersionComparisonRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.records[storage.VersionComparisonKey(olderLink, newerLink)], nil
+}
+
+func (m *memoryComparisonStore) PutComparison(ctx context.Context, record *storage.VersionComparisonRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.records == nil {
+		m.records = make(map[string]*storage.VersionComparisonRecord)
+	}
+	m.records[record.Key] = record
+	return nil
+}
+
+// lastUpdatedDocuments returns a new listing on every call, as the service edits it
+func lastUpdatedDocuments(waiveContent string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{"link": "https://b/waive-2.pdf", "topic": "waive", "version": 2, "content": waiveContent},
+		{"link": "https://b/card-2.pdf", "topic": "card", "version": 2, "content": "card v2"},
+		{"link": "https://b/waive-1.pdf", "topic": "waive", "version": 1, "content": "waive v1"},
+		{"link": "https://b/card-1.pdf", "topic": "card", "version": 1, "content": "card v1"},
+	}
+}
+
+func TestGetLastUpdateDocuments_CachesComparisons(t *testing.T) {
+	client := &mockOpenSearchClient{documents: lastUpdatedDocuments("waive v2")}
+	store := &memoryComparisonStore{}
+	service := NewOpenSearchDocumentService(client, nil, store, nil, &config.Config{ComparisonWorkers: 2})
+
+	documents, err := service.GetLastUpdateDocuments(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.compareCalls != 2 {
+		t.Errorf("expected both topics to be compared, got %d comparisons", client.compareCalls)
+	}
+	if documents[0]["changeSummary"] != "changed from waive v1 to waive v2" || documents[1]["changeSummary"] != "changed from card v1 to card v2" {
+		t.Errorf("expected each change summary on its newer document, got %v", documents)
+	}
+	for _, doc := range documents {
+		if _, ok := doc["content"]; ok {
+			t.Errorf("expected the content to be removed, got %v", doc)
+		}
+	}
+
+	client.documents = lastUpdatedDocuments("waive v2")
+	documents, _ = service.GetLastUpdateDocuments(context.Background())
+	if client.compareCalls != 2 || documents[0]["changeSummary"] != "changed from waive v1 to waive v2" {
+		t.Errorf("expected the cached comparisons, got %d comparisons and %v", client.compareCalls, documents[0])
+	}
+
+	// A document replaced under the same link is compared again
+	client.documents = lastUpdatedDocuments("waive v2 corrected")
+	documents, _ = service.GetLastUpdateDocuments(context.Background())
+	if client.compareCalls != 3 || documents[0]["changeSummary"] != "changed from waive v1 to waive v2 corrected" {
+		t.Errorf("expected changed contents to be compared again, got %d comparisons and %v", client.compareCalls, documents[0])
+	}
+}
+
+func TestGetLastUpdateDocuments_SafeModeServesCachedComparisons(t *testing.T) {
+	client := &mockOpenSearchClient{documents: lastUpdatedDocuments("waive v2")}
+	store := &memoryComparisonStore{}
+	cfg := &config.Config{SafeMode: config.NewSafeMode(false)}
+	service := NewOpenSearchDocumentService(client, nil, store, nil, cfg)
+	if _, err := service.GetLastUpdateDocuments(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.SafeMode.Set(true)
+	client.documents = lastUpdatedDocuments("waive v2 corrected")
+	documents, _ := service.GetLastUpdateDocuments(context.Background())
+	if client.compareCalls != 2 {
+		t.Errorf("expected no comparisons in safe mode, got %d", client.compareCalls)
+	}
+	if _, ok := documents[0]["changeSummary"]; ok {
+		t.Errorf("expected no change summary for uncached contents, got %v", documents[0])
+	}
+	if documents[1]["changeSummary"] != "changed from card v1 to card v2" {
+		t.Errorf("expected the cached comparison in safe mode, got %v", documents[1])
+	}
+}
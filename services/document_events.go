@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+)
+
+const (
+	DocumentEventSource = "teletubpax.documents"
+
+	DetailTypeDocumentIngested            = "Document Ingested"
+	DetailTypeDocumentVersionDetected     = "Document Version Detected"
+	DetailTypeDocumentComparisonCompleted = "Document Comparison Completed"
+)
+
+// DocumentIngestedEvent describes a document summarized by change detection for the first time
+type DocumentIngestedEvent struct {
+	Link    string `json:"link"`
+	Topic   string `json:"topic"`
+	Version int    `json:"version"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// DocumentComparisonEvent describes a change summary newly generated by Bedrock for two
+// versions of a topic
+type DocumentComparisonEvent struct {
+	Topic         string `json:"topic"`
+	Link          string `json:"link"`
+	PreviousLink  string `json:"previousLink"`
+	ChangeSummary string `json:"changeSummary"`
+}
+
+// DocumentEventPublisher announces document lifecycle events to downstream systems. Like
+// webhooks, publishing is best effort: failures are logged and never fail the caller.
+type DocumentEventPublisher interface {
+	DocumentVersionNotifier
+	DocumentIngested(ctx context.Context, event DocumentIngestedEvent)
+	ComparisonCompleted(ctx context.Context, event DocumentComparisonEvent)
+}
+
+// EventBridgeDocumentEvents puts document lifecycle events on an EventBridge bus with the
+// source DocumentEventSource. The detail is the event itself plus the time it occurred.
+type EventBridgeDocumentEvents struct {
+	client  aws.EventBusClient
+	busName string
+}
+
+func NewEventBridgeDocumentEvents(client aws.EventBusClient, busName string) *EventBridgeDocumentEvents {
+	return &EventBridgeDocumentEvents{
+		client:  client,
+		busName: busName,
+	}
+}
+
+func (e *EventBridgeDocumentEvents) DocumentIngested(ctx context.Context, event DocumentIngestedEvent) {
+	e.publish(ctx, DetailTypeDocumentIngested, event)
+}
+
+func (e *EventBridgeDocumentEvents) NotifyNewVersion(ctx context.Context, event DocumentVersionEvent) {
+	e.publish(ctx, DetailTypeDocumentVersionDetected, event)
+}
+
+func (e *EventBridgeDocumentEvents) ComparisonCompleted(ctx context.Context, event DocumentComparisonEvent) {
+	e.publish(ctx, DetailTypeDocumentComparisonCompleted, event)
+}
+
+func (e *EventBridgeDocumentEvents) publish(ctx context.Context, detailType string, event interface{}) {
+	log := logger.WithContext(ctx)
+
+	detail, err := json.Marshal(struct {
+		OccurredAt time.Time   `json:"occurredAt"`
+		Document   interface{} `json:"document"`
+	}{
+		OccurredAt: time.Now().UTC(),
+		Document:   event,
+	})
+	if err != nil {
+		log.Warn("Failed to marshal document event", map[string]interface{}{
+			"detail_type": detailType,
+			"error":       err.Error(),
+		})
+		return
+	}
+
+	if err := e.client.PutEvent(ctx, e.busName, DocumentEventSource, detailType, string(detail)); err != nil {
+		log.Warn("Failed to publish document event", map[string]interface{}{
+			"detail_type": detailType,
+			"bus":         e.busName,
+			"error":       err.Error(),
+		})
+		return
+	}
+
+	log.Info("Document event published", map[string]interface{}{
+		"detail_type": detailType,
+		"bus":         e.busName,
+	})
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"testing"
+
+	"teletubpax-api/config"
+)
+
+type putEvent struct {
+	busName    string
+	source     string
+	detailType string
+	detail     string
+}
+
+type recordingEventBus struct {
+	mu     sync.Mutex
+	events []putEvent
+}
+
+func (r *recordingEventBus) PutEvent(ctx context.Context, busName string, source string, detailType string, detail string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, putEvent{busName, source, detailType, detail})
+	return nil
+}
+
+func (r *recordingEventBus) detailTypes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := make([]string, 0, len(r.events))
+	for _, event := range r.events {
+		types = append(types, event.detailType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func TestResummarize_PublishesDocumentEventsOnce(t *testing.T) {
+	bus := &recordingEventBus{}
+	events := NewEventBridgeDocumentEvents(bus, "documents")
+	client := &mockOpenSearchClient{documents: testInventory()}
+	service := NewBedrockDocumentResummarizeService(client, &memorySummaryStore{}, &memoryCheckpointStore{}, nil, events, &config.Config{ResummarizeConcurrency: 1})
+
+	if _, err := service.Run(context.Background(), ResummarizeOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := bus.detailTypes()
+	want := []string{DetailTypeDocumentIngested, DetailTypeDocumentIngested, DetailTypeDocumentIngested, DetailTypeDocumentVersionDetected}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	for _, event := range bus.events {
+		if event.busName != "documents" || event.source != DocumentEventSource {
+			t.Errorf("unexpected bus or source %+v", event)
+		}
+		if event.detailType != DetailTypeDocumentVersionDetected {
+			continue
+		}
+		var detail struct {
+			Document DocumentVersionEvent `json:"document"`
+		}
+		if err := json.Unmarshal([]byte(event.detail), &detail); err != nil {
+			t.Fatalf("invalid detail %q: %v", event.detail, err)
+		}
+		if detail.Document.Link != "https://b/content/2025/05/waive-2.pdf" || detail.Document.ChangeSummary == "" {
+			t.Errorf("unexpected version detail %+v", detail.Document)
+		}
+	}
+
+	// A restarted run regenerates summaries without announcing the documents again
+	if _, err := service.Run(context.Background(), ResummarizeOptions{Restart: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bus.events) != len(want) {
+		t.Errorf("expected no new events on a re-run, got %v", bus.detailTypes())
+	}
+}
+
+func TestGetLastUpdateDocuments_PublishesNewComparisons(t *testing.T) {
+	bus := &recordingEventBus{}
+	client := &mockOpenSearchClient{documents: lastUpdatedDocuments("waive v2")}
+	service := NewOpenSearchDocumentService(client, nil, &memoryComparisonStore{}, NewEventBridgeDocumentEvents(bus, "documents"), &config.Config{ComparisonWorkers: 2})
+
+	if _, err := service.GetLastUpdateDocuments(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := bus.detailTypes(); len(got) != 2 || got[0] != DetailTypeDocumentComparisonCompleted {
+		t.Fatalf("expected a comparison event per topic, got %v", got)
+	}
+
+	// Cached comparisons are not announced again
+	client.documents = lastUpdatedDocuments("waive v2")
+	if _, err := service.GetLastUpdateDocuments(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := bus.detailTypes(); len(got) != 2 {
+		t.Errorf("expected no events for cached comparisons, got %v", got)
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"teletubpax-api/aws"
+)
+
+// indexedDocumentStatuses are the statuses of documents whose chunks can be retrieved
+var indexedDocumentStatuses = map[string]bool{
+	"INDEXED":                    true,
+	"PARTIALLY_INDEXED":          true,
+	"METADATA_PARTIALLY_INDEXED": true,
+	"METADATA_UPDATE_FAILED":     true,
+}
+
+// ExportedDocument is the metadata of an indexed document in the export
+type ExportedDocument struct {
+	Link            string `json:"link"`
+	Topic           string `json:"topic"`
+	Version         int    `json:"version"`
+	YearMonth       string `json:"yearMonth"`    // From the key, "0000/00" when it has no YYYY/MM/ folders
+	LastModified    string `json:"lastModified"` // RFC 3339 time of its last ingestion, "" when unknown
+	KnowledgeBaseId string `json:"knowledgeBaseId"`
+	DataSourceId    string `json:"dataSourceId"`
+}
+
+type DocumentExportService interface {
+	// Export calls write with every document indexed in the configured knowledge bases, a
+	// page of documents at a time so they are never all held in memory, and stops at the
+	// first error
+	Export(ctx context.Context, write func(ExportedDocument) error) error
+}
+
+// BedrockDocumentExportService lists the documents of the data sources of the knowledge
+// bases in KNOWLEDGE_BASE_IDS, for reporting on the whole corpus instead of the 100 most
+// relevant chunks a listing retrieves
+type BedrockDocumentExportService struct {
+	client           aws.IngestionClient
+	documentLinker   aws.DocumentLinker
+	sourceFilter     aws.SourceFilter // Optional, excluded documents are left out
+	knowledgeBaseIds func() []string
+}
+
+func NewBedrockDocumentExportService(client aws.IngestionClient, documentLinker aws.DocumentLinker, sourceFilter aws.SourceFilter, knowledgeBaseIds func() []string) *BedrockDocumentExportService {
+	return &BedrockDocumentExportService{
+		client:           client,
+		documentLinker:   documentLinker,
+		sourceFilter:     sourceFilter,
+		knowledgeBaseIds: knowledgeBaseIds,
+	}
+}
+
+func (s *BedrockDocumentExportService) Export(ctx context.Context, write func(ExportedDocument) error) error {
+	excluded := map[string]bool{}
+	if s.sourceFilter != nil {
+		for _, uri := range s.sourceFilter.ExcludedSourceUris(ctx) {
+			excluded[uri] = true
+		}
+	}
+
+	for _, knowledgeBaseId := range s.knowledgeBaseIds() {
+		dataSources, err := s.client.ListDataSources(ctx, knowledgeBaseId)
+		if err != nil {
+			return err
+		}
+		for _, dataSource := range dataSources {
+			nextToken := ""
+			for {
+				documents, next, err := s.client.ListDocuments(ctx, knowledgeBaseId, dataSource.DataSourceId, nextToken)
+				if err != nil {
+					return err
+				}
+				for _, document := range documents {
+					if document.Uri == "" || !indexedDocumentStatuses[document.Status] || excluded[document.Uri] {
+						continue
+					}
+					if err := write(s.exportedDocument(ctx, document)); err != nil {
+						return err
+					}
+				}
+				if next == "" {
+					break
+				}
+				nextToken = next
+			}
+		}
+	}
+	return nil
+}
+
+func (s *BedrockDocumentExportService) exportedDocument(ctx context.Context, document aws.KnowledgeBaseDocument) ExportedDocument {
+	topic, version, yearMonth := aws.DocumentKeyMetadata(document.Uri)
+	exported := ExportedDocument{
+		Link:            s.documentLinker.DocumentLink(ctx, document.Uri),
+		Topic:           topic,
+		Version:         version,
+		YearMonth:       yearMonth,
+		KnowledgeBaseId: document.KnowledgeBaseId,
+		DataSourceId:    document.DataSourceId,
+	}
+	if document.UpdatedAt != nil {
+		exported.LastModified = document.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return exported
+}
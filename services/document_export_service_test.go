@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teletubpax-api/aws"
+)
+
+type staticSourceFilter []string
+
+func (f staticSourceFilter) ExcludedSourceUris(ctx context.Context) []string {
+	return f
+}
+
+func TestDocumentExport_ListsIndexedDocuments(t *testing.T) {
+	updatedAt := time.Date(2025, 5, 20, 3, 0, 0, 0, time.UTC)
+	client := newMockIngestionClient()
+	client.documents = map[string][]aws.KnowledgeBaseDocument{
+		"ds-1": {
+			{KnowledgeBaseId: "kb-1", DataSourceId: "ds-1", Uri: "s3://docs/content/2025/05/fee-2.pdf", Status: "INDEXED", UpdatedAt: &updatedAt},
+			{KnowledgeBaseId: "kb-1", DataSourceId: "ds-1", Uri: "s3://docs/content/2025/04/fee-1.pdf", Status: "INDEXED"},
+			{KnowledgeBaseId: "kb-1", DataSourceId: "ds-1", Uri: "s3://docs/content/2025/05/draft.pdf", Status: "FAILED"},
+			{KnowledgeBaseId: "kb-1", DataSourceId: "ds-1", Uri: "s3://docs/content/2025/03/retired.pdf", Status: "INDEXED"},
+			{KnowledgeBaseId: "kb-1", DataSourceId: "ds-1", Uri: "s3://docs/policy.pdf", Status: "PARTIALLY_INDEXED"},
+		},
+		"ds-2": {
+			{KnowledgeBaseId: "kb-2", DataSourceId: "ds-2", Uri: "s3://products/loan.pdf", Status: "INDEXED"},
+		},
+	}
+	service := NewBedrockDocumentExportService(client, aws.NewPublicDocumentLinker("ap-southeast-1"), staticSourceFilter{"s3://docs/content/2025/03/retired.pdf"}, knowledgeBaseIds("kb-1", "kb-2"))
+
+	var exported []ExportedDocument
+	err := service.Export(context.Background(), func(document ExportedDocument) error {
+		exported = append(exported, document)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Every page of every data source, without failed and excluded documents
+	if len(exported) != 4 {
+		t.Fatalf("expected 4 documents, got %+v", exported)
+	}
+	first := exported[0]
+	if first.Link != "https://docs.s3.ap-southeast-1.amazonaws.com/content/2025/05/fee-2.pdf" || first.Topic != "fee" || first.Version != 2 || first.YearMonth != "2025/05" || first.LastModified != "2025-05-20T03:00:00Z" {
+		t.Errorf("expected the metadata derived from the key, got %+v", first)
+	}
+	if exported[2].YearMonth != "0000/00" || exported[2].LastModified != "" {
+		t.Errorf("expected a document without date folders or update time, got %+v", exported[2])
+	}
+	if exported[3].KnowledgeBaseId != "kb-2" || exported[3].DataSourceId != "ds-2" {
+		t.Errorf("expected the documents of the second knowledge base, got %+v", exported[3])
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"teletubpax-api/logger"
+)
+
+const (
+	DocumentPreviewStart    = "start"    // The beginning of the document
+	DocumentPreviewRelevant = "relevant" // The chunk that best matches the query
+)
+
+// DocumentPreview is a short excerpt of a document, for a preview tooltip
+type DocumentPreview struct {
+	Mode       string
+	Excerpt    string
+	PageNumber int     // Page of the excerpt, 0 when the document was parsed without pages
+	Score      float64 // Retrieval score of the relevant chunk, 0 for the beginning
+	Truncated  bool
+}
+
+// GetDocumentPreview returns up to length characters of a document: the chunk most relevant
+// to the query, or the beginning of the document without one. It returns nil when the
+// knowledge base has no chunks of the document.
+func (s *OpenSearchDocumentService) GetDocumentPreview(ctx context.Context, documentUri string, query string, length int) (*DocumentPreview, error) {
+	log := logger.WithContext(ctx)
+	startTime := time.Now()
+
+	var preview *DocumentPreview
+	var err error
+	if query != "" {
+		preview, err = s.relevantPreview(ctx, documentUri, query, length)
+	} else {
+		preview, err = s.startPreview(ctx, documentUri, length)
+	}
+	if err != nil {
+		log.Error("Failed to preview document", map[string]interface{}{
+			"uri":         documentUri,
+			"error":       err.Error(),
+			"duration_ms": time.Since(startTime).Milliseconds(),
+		})
+		return nil, err
+	}
+
+	log.Info("Document preview retrieved", map[string]interface{}{
+		"uri":         documentUri,
+		"found":       preview != nil,
+		"with_query":  query != "",
+		"duration_ms": time.Since(startTime).Milliseconds(),
+	})
+	return preview, nil
+}
+
+// relevantPreview retrieves only the best chunk of the document for the query
+func (s *OpenSearchDocumentService) relevantPreview(ctx context.Context, documentUri string, query string, length int) (*DocumentPreview, error) {
+	chunks, err := s.openSearchClient.SearchDocumentChunks(ctx, documentUri, query, 1)
+	if err != nil || len(chunks) == 0 {
+		return nil, err
+	}
+
+	excerpt, truncated := previewExcerpt(chunks[0].Content, length)
+	return &DocumentPreview{
+		Mode:       DocumentPreviewRelevant,
+		Excerpt:    excerpt,
+		PageNumber: chunks[0].PageNumber,
+		Score:      chunks[0].Score,
+		Truncated:  truncated,
+	}, nil
+}
+
+// startPreview joins the chunks in document order until the excerpt is long enough
+func (s *OpenSearchDocumentService) startPreview(ctx context.Context, documentUri string, length int) (*DocumentPreview, error) {
+	chunks, err := s.openSearchClient.GetDocumentChunks(ctx, documentUri)
+	if err != nil || len(chunks) == 0 {
+		return nil, err
+	}
+
+	var text strings.Builder
+	for _, chunk := range chunks {
+		if text.Len() > 0 {
+			text.WriteString(" ")
+		}
+		text.WriteString(chunk.Content)
+		if utf8.RuneCountInString(text.String()) > length {
+			break
+		}
+	}
+
+	excerpt, truncated := previewExcerpt(text.String(), length)
+	return &DocumentPreview{
+		Mode:       DocumentPreviewStart,
+		Excerpt:    excerpt,
+		PageNumber: chunks[0].PageNumber,
+		Truncated:  truncated,
+	}, nil
+}
+
+// previewExcerpt collapses whitespace and cuts the text to length characters, at the last
+// space when one is close to the cut. Thai runs words together, so it is often cut mid-word.
+func previewExcerpt(text string, length int) (string, bool) {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text, false
+	}
+
+	cut := runes[:length]
+	for i := len(cut) - 1; i > length*3/4; i-- {
+		if cut[i] == ' ' {
+			cut = cut[:i]
+			break
+		}
+	}
+	return string(cut) + "…", true
+}
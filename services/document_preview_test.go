@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+)
+
+func TestGetDocumentPreview_StartJoinsChunksInOrder(t *testing.T) {
+	client := &mockOpenSearchClient{chunks: []aws.DocumentChunk{
+		{Content: "Debit card fees", PageNumber: 1},
+		{Content: "annual fee 200 baht,\n waived in the first year", PageNumber: 2},
+		{Content: "never read", PageNumber: 3},
+	}}
+	service := NewOpenSearchDocumentService(client, nil, nil, nil, &config.Config{})
+
+	preview, err := service.GetDocumentPreview(context.Background(), "s3://bucket/fees.pdf", "", 30)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if preview.Mode != DocumentPreviewStart || preview.PageNumber != 1 {
+		t.Errorf("expected a start preview from page 1, got %+v", preview)
+	}
+	if preview.Excerpt != "Debit card fees annual fee…" || !preview.Truncated {
+		t.Errorf("expected the excerpt cut at a space, got %q (truncated %v)", preview.Excerpt, preview.Truncated)
+	}
+	if len(client.searchQueries) != 0 {
+		t.Errorf("expected no targeted retrieval without a query, got %v", client.searchQueries)
+	}
+}
+
+func TestGetDocumentPreview_RelevantUsesBestChunk(t *testing.T) {
+	client := &mockOpenSearchClient{chunks: []aws.DocumentChunk{
+		{Content: "annual fee 200 baht", PageNumber: 4, Score: 0.8},
+		{Content: "other chunk", PageNumber: 1, Score: 0.3},
+	}}
+	service := NewOpenSearchDocumentService(client, nil, nil, nil, &config.Config{})
+
+	preview, err := service.GetDocumentPreview(context.Background(), "s3://bucket/fees.pdf", "annual fee", 300)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if preview.Mode != DocumentPreviewRelevant || preview.PageNumber != 4 || preview.Score != 0.8 {
+		t.Errorf("expected the best chunk, got %+v", preview)
+	}
+	if preview.Excerpt != "annual fee 200 baht" || preview.Truncated {
+		t.Errorf("expected the whole chunk, got %q (truncated %v)", preview.Excerpt, preview.Truncated)
+	}
+	if len(client.searchQueries) != 1 || client.searchQueries[0] != "annual fee" {
+		t.Errorf("expected one retrieval for the query, got %v", client.searchQueries)
+	}
+}
+
+func TestGetDocumentPreview_NoChunks(t *testing.T) {
+	service := NewOpenSearchDocumentService(&mockOpenSearchClient{}, nil, nil, nil, &config.Config{})
+
+	for _, query := range []string{"", "annual fee"} {
+		preview, err := service.GetDocumentPreview(context.Background(), "s3://bucket/missing.pdf", query, 300)
+		if err != nil || preview != nil {
+			t.Errorf("expected no preview for query %q, got %+v %v", query, preview, err)
+		}
+	}
+}
+
+func TestPreviewExcerpt_CutsThaiByCharacter(t *testing.T) {
+	excerpt, truncated := previewExcerpt(strings.Repeat("ค่าธรรมเนียม", 10), 20)
+	if !truncated || len([]rune(excerpt)) != 21 {
+		t.Errorf("expected 20 characters and an ellipsis, got %q", excerpt)
+	}
+}
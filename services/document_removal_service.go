@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"strings"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+var ErrDocumentNotFound = stdErrors.New("document not found")
+
+// DocumentRemoval reports a deleted document
+type DocumentRemoval struct {
+	SourceUri     string             `json:"sourceUri"`
+	ArchiveUri    string             `json:"archiveUri,omitempty"` // The copy in DOCUMENT_ARCHIVE_BUCKET, empty without one
+	AuditId       string             `json:"auditId,omitempty"`    // The audit record of the deletion, empty without AUDIT_BUCKET
+	IngestionJobs []aws.IngestionJob `json:"ingestionJobs"`        // Syncs started to remove the document from the knowledge bases
+	SyncPending   []string           `json:"syncPending"`          // <knowledge base ID>/<data source ID> that could not be synced now
+}
+
+type DocumentRemovalService interface {
+	// Remove deletes the source object of a document right away and syncs the knowledge
+	// bases, so the document stops being retrieved. Accepts an s3:// URI or a document URL.
+	Remove(ctx context.Context, documentUri string) (*DocumentRemoval, error)
+}
+
+// documentObjects reads, archives and deletes source documents, like S3ObjectStorageClient
+type documentObjects interface {
+	aws.ObjectReaderClient
+	aws.ObjectStorageClient
+	aws.ObjectArchiveClient
+}
+
+// S3DocumentRemovalService deletes retired documents for good, unlike the soft delete of
+// DocumentDeletionService. The deletion is written to the audit trail before the object is
+// deleted, so no deletion goes unrecorded, and the object is copied to the archive bucket
+// first. Every data source of the knowledge bases is then synced, since any of them may
+// have indexed the document; with the deleted-document registry the document is excluded
+// from retrieval until then.
+type S3DocumentRemovalService struct {
+	objects       documentObjects
+	archiveBucket string // Optional, documents are not archived when empty
+	ingestion     IngestionService
+	audit         storage.AuditStore           // Optional, deletions are not audited when nil
+	deleted       storage.DeletedDocumentStore // Optional
+	config        *config.Config
+}
+
+func NewS3DocumentRemovalService(
+	objects documentObjects,
+	archiveBucket string,
+	ingestion IngestionService,
+	audit storage.AuditStore,
+	deleted storage.DeletedDocumentStore,
+	cfg *config.Config,
+) *S3DocumentRemovalService {
+	return &S3DocumentRemovalService{
+		objects:       objects,
+		archiveBucket: archiveBucket,
+		ingestion:     ingestion,
+		audit:         audit,
+		deleted:       deleted,
+		config:        cfg,
+	}
+}
+
+func (s *S3DocumentRemovalService) Remove(ctx context.Context, documentUri string) (*DocumentRemoval, error) {
+	log := logger.WithContext(ctx)
+
+	sourceUri, err := documentSourceUri(documentUri)
+	if err != nil {
+		return nil, err
+	}
+	bucket, key := splitSourceUri(sourceUri)
+	modified, err := s.objects.ObjectLastModified(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if modified == nil {
+		return nil, ErrDocumentNotFound
+	}
+
+	removal := &DocumentRemoval{SourceUri: sourceUri, IngestionJobs: []aws.IngestionJob{}, SyncPending: []string{}}
+	if s.audit != nil {
+		removal.AuditId, err = s.auditDeletion(ctx, sourceUri, AuditOutcomeDeleted)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.deleteDocument(ctx, sourceUri, removal); err != nil {
+		// The deletion is already on the audit trail, which is write-once, so its failure is
+		// recorded next to it
+		if s.audit != nil {
+			if _, auditErr := s.auditDeletion(ctx, sourceUri, AuditOutcomeError); auditErr != nil {
+				log.Error("Failed to write audit record", map[string]interface{}{
+					"source_uri": sourceUri,
+					"error":      auditErr.Error(),
+				})
+			}
+		}
+		return nil, err
+	}
+
+	if s.deleted != nil {
+		s.excludeDocument(ctx, sourceUri)
+	}
+	s.syncKnowledgeBases(ctx, removal)
+
+	log.Info("Document removed", map[string]interface{}{
+		"source_uri":     sourceUri,
+		"archive_uri":    removal.ArchiveUri,
+		"audit_id":       removal.AuditId,
+		"ingestion_jobs": len(removal.IngestionJobs),
+		"sync_pending":   removal.SyncPending,
+	})
+	return removal, nil
+}
+
+func (s *S3DocumentRemovalService) deleteDocument(ctx context.Context, sourceUri string, removal *DocumentRemoval) error {
+	if s.archiveBucket != "" {
+		archiveUri, err := s.objects.ArchiveDocument(ctx, sourceUri, s.archiveBucket)
+		if err != nil {
+			return err
+		}
+		removal.ArchiveUri = archiveUri
+	}
+	return s.objects.DeleteDocument(ctx, sourceUri)
+}
+
+func (s *S3DocumentRemovalService) auditDeletion(ctx context.Context, sourceUri string, outcome string) (string, error) {
+	now := time.Now().UTC()
+	record := &storage.AuditRecord{
+		Id:                 auditRecordId(now),
+		Timestamp:          now,
+		Action:             AuditActionDocumentDeletion,
+		Caller:             auditCaller(ctx),
+		Document:           sourceUri,
+		KnowledgeBases:     []string{},
+		ModelIds:           []string{},
+		RetrievedDocuments: []string{},
+		Outcome:            outcome,
+	}
+	if err := s.audit.Append(ctx, record); err != nil {
+		return "", err
+	}
+	return record.Id, nil
+}
+
+// excludeDocument registers the document as deleted and purged, which keeps it out of
+// retrieval until the syncs have removed its chunks. A failure only delays that.
+func (s *S3DocumentRemovalService) excludeDocument(ctx context.Context, sourceUri string) {
+	now := time.Now().UTC()
+	document := &storage.DeletedDocument{
+		SourceUri:  sourceUri,
+		Link:       aws.NewPublicDocumentLinker(s.config.AWSRegion).DocumentLink(ctx, sourceUri),
+		DeletedAt:  now,
+		PurgeAfter: now,
+	}
+	// A document that was already soft-deleted is marked purged all the same
+	_, err := s.deleted.MarkDeleted(ctx, document)
+	if err == nil {
+		err = s.deleted.MarkPurged(ctx, sourceUri, now, now.Add(purgedRecordRetention))
+	}
+	if err != nil {
+		logger.WithContext(ctx).Warn("Failed to exclude removed document", map[string]interface{}{
+			"source_uri": sourceUri,
+			"error":      err.Error(),
+		})
+	}
+}
+
+// syncKnowledgeBases starts a sync of every data source. One already syncing may have
+// listed the bucket before the deletion, so it is reported pending with those that failed
+// to start; their next sync removes the document.
+func (s *S3DocumentRemovalService) syncKnowledgeBases(ctx context.Context, removal *DocumentRemoval) {
+	log := logger.WithContext(ctx)
+
+	dataSources, err := s.ingestion.ListDataSources(ctx)
+	if err != nil {
+		log.Warn("Failed to list data sources to sync", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	for _, dataSource := range dataSources {
+		job, err := s.ingestion.StartSync(ctx, dataSource.KnowledgeBaseId, dataSource.DataSourceId)
+		if err != nil {
+			if !stdErrors.Is(err, aws.ErrIngestionJobRunning) {
+				log.Warn("Failed to sync data source", map[string]interface{}{
+					"knowledge_base_id": dataSource.KnowledgeBaseId,
+					"data_source_id":    dataSource.DataSourceId,
+					"error":             err.Error(),
+				})
+			}
+			removal.SyncPending = append(removal.SyncPending, dataSource.KnowledgeBaseId+"/"+dataSource.DataSourceId)
+			continue
+		}
+		removal.IngestionJobs = append(removal.IngestionJobs, *job)
+	}
+}
+
+// splitSourceUri splits a validated s3:// URI into its bucket and key
+func splitSourceUri(sourceUri string) (string, string) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(sourceUri, "s3://"), "/")
+	return bucket, key
+}
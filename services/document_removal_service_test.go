@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/storage"
+)
+
+// removableObjects is a bucket whose documents can be archived and deleted
+type removableObjects struct {
+	memoryObjects
+	deleteErr error
+}
+
+func (m *removableObjects) DeleteDocument(ctx context.Context, s3Uri string) error {
+	if m.deleteErr != nil {
+		return m.deleteErr
+	}
+	key := strings.TrimPrefix(s3Uri, "s3://")
+	delete(m.objects, key)
+	delete(m.objects, key+".metadata.json")
+	return nil
+}
+
+func (m *removableObjects) ArchiveDocument(ctx context.Context, s3Uri string, archiveBucket string) (string, error) {
+	bucket, key := splitSourceUri(s3Uri)
+	for _, objectKey := range []string{key, key + ".metadata.json"} {
+		if object, ok := m.objects[bucket+"/"+objectKey]; ok {
+			m.objects[archiveBucket+"/"+objectKey] = object
+		}
+	}
+	return "s3://" + archiveBucket + "/" + key, nil
+}
+
+func retiredPolicy() *removableObjects {
+	return &removableObjects{memoryObjects: memoryObjects{objects: map[string]aws.StoredObject{
+		"docs/policies/2024/01/fees-1.pdf":               {Body: []byte("%PDF"), LastModified: time.Now()},
+		"docs/policies/2024/01/fees-1.pdf.metadata.json": {Body: []byte(`{}`), LastModified: time.Now()},
+	}}}
+}
+
+func TestRemove_ArchivesDeletesAndSyncs(t *testing.T) {
+	objects := retiredPolicy()
+	audit := &recordingAuditStore{MemoryAuditStore: storage.NewMemoryAuditStore()}
+	deleted := newMemoryDeletedDocumentStore()
+	ingestion := NewBedrockIngestionService(newMockIngestionClient(), knowledgeBaseIds("kb-1", "kb-2"), nil, 0)
+	service := NewS3DocumentRemovalService(objects, "archive", ingestion, audit, deleted, deletionConfig())
+
+	removal, err := service.Remove(context.Background(), "https://docs.s3.us-east-1.amazonaws.com/policies/2024/01/fees-1.pdf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removal.SourceUri != "s3://docs/policies/2024/01/fees-1.pdf" || removal.ArchiveUri != "s3://archive/policies/2024/01/fees-1.pdf" {
+		t.Errorf("unexpected removal %+v", removal)
+	}
+	if _, ok := objects.objects["docs/policies/2024/01/fees-1.pdf"]; ok {
+		t.Error("expected the document to be deleted")
+	}
+	if _, ok := objects.objects["archive/policies/2024/01/fees-1.pdf.metadata.json"]; !ok {
+		t.Error("expected the metadata file to be archived")
+	}
+
+	// ds-1 is already syncing, ds-2 is synced now
+	if len(removal.IngestionJobs) != 1 || removal.IngestionJobs[0].DataSourceId != "ds-2" {
+		t.Errorf("expected a sync of ds-2, got %+v", removal.IngestionJobs)
+	}
+	if len(removal.SyncPending) != 1 || removal.SyncPending[0] != "kb-1/ds-1" {
+		t.Errorf("expected ds-1 pending, got %v", removal.SyncPending)
+	}
+
+	if len(audit.records) != 1 || audit.records[0].Id != removal.AuditId {
+		t.Fatalf("expected one audit record, got %+v", audit.records)
+	}
+	record := audit.records[0]
+	if record.Action != AuditActionDocumentDeletion || record.Document != removal.SourceUri || record.Outcome != AuditOutcomeDeleted {
+		t.Errorf("unexpected audit record %+v", record)
+	}
+
+	excluded, _ := deleted.GetDeleted(context.Background(), removal.SourceUri)
+	if excluded == nil || excluded.PurgedAt == nil {
+		t.Errorf("expected the document excluded as purged, got %+v", excluded)
+	}
+}
+
+func TestRemove_UnknownDocument(t *testing.T) {
+	audit := &recordingAuditStore{MemoryAuditStore: storage.NewMemoryAuditStore()}
+	ingestion := NewBedrockIngestionService(newMockIngestionClient(), knowledgeBaseIds("kb-2"), nil, 0)
+	service := NewS3DocumentRemovalService(retiredPolicy(), "", ingestion, audit, nil, deletionConfig())
+
+	if _, err := service.Remove(context.Background(), "s3://docs/policies/missing.pdf"); !stdErrors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("expected ErrDocumentNotFound, got %v", err)
+	}
+	if _, err := service.Remove(context.Background(), "policies/fees-1.pdf"); err == nil {
+		t.Error("expected a validation error for a relative path")
+	}
+	if len(audit.records) != 0 {
+		t.Errorf("expected nothing audited, got %+v", audit.records)
+	}
+}
+
+func TestRemove_RecordsFailedDeletion(t *testing.T) {
+	objects := retiredPolicy()
+	objects.deleteErr = stdErrors.New("access denied")
+	audit := &recordingAuditStore{MemoryAuditStore: storage.NewMemoryAuditStore()}
+	ingestionClient := newMockIngestionClient()
+	service := NewS3DocumentRemovalService(objects, "", NewBedrockIngestionService(ingestionClient, knowledgeBaseIds("kb-2"), nil, 0), audit, nil, deletionConfig())
+
+	if _, err := service.Remove(context.Background(), "s3://docs/policies/2024/01/fees-1.pdf"); err == nil {
+		t.Fatal("expected the deletion to fail")
+	}
+	if len(audit.records) != 2 || audit.records[0].Outcome != AuditOutcomeDeleted || audit.records[1].Outcome != AuditOutcomeError {
+		t.Errorf("expected the deletion and its failure audited, got %+v", audit.records)
+	}
+	if len(ingestionClient.started) != 0 {
+		t.Errorf("expected no sync, got %v", ingestionClient.started)
+	}
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+const resummarizeJobName = "document-resummarize"
+
+// ErrSafeModeEnabled is returned by Run while safe mode is on, since the job would
+// otherwise overwrite stored change summaries without comparing versions
+var ErrSafeModeEnabled = errors.New("document re-summarization is paused while safe mode is enabled")
+
+type ResummarizeOptions struct {
+	Restart      bool // Ignore any existing checkpoint and start from the first document
+	MaxDocuments int  // Stop after this many documents in this run (0 = no limit)
+}
+
+type DocumentResummarizeService interface {
+	Run(ctx context.Context, options ResummarizeOptions) (*storage.JobCheckpoint, error)
+	Status(ctx context.Context) (*storage.JobCheckpoint, error)
+}
+
+// BedrockDocumentResummarizeService regenerates summaries and change summaries for every
+// document in the inventory and writes them to the precomputed summary store. A document
+// with an older version and no stored summary yet is a new version, which is passed to the
+// notifier. Any document without a stored summary yet is announced as ingested to the
+// document events.
+type BedrockDocumentResummarizeService struct {
+	openSearchClient aws.OpenSearchClient
+	summaryStore     storage.DocumentSummaryStore
+	checkpointStore  storage.JobCheckpointStore
+	notifier         DocumentVersionNotifier // Optional
+	events           DocumentEventPublisher  // Optional
+	config           *config.Config
+}
+
+func NewBedrockDocumentResummarizeService(
+	openSearchClient aws.OpenSearchClient,
+	summaryStore storage.DocumentSummaryStore,
+	checkpointStore storage.JobCheckpointStore,
+	notifier DocumentVersionNotifier,
+	events DocumentEventPublisher,
+	cfg *config.Config,
+) *BedrockDocumentResummarizeService {
+	return &BedrockDocumentResummarizeService{
+		openSearchClient: openSearchClient,
+		summaryStore:     summaryStore,
+		checkpointStore:  checkpointStore,
+		notifier:         notifier,
+		events:           events,
+		config:           cfg,
+	}
+}
+
+func (s *BedrockDocumentResummarizeService) Status(ctx context.Context) (*storage.JobCheckpoint, error) {
+	return s.checkpointStore.GetCheckpoint(ctx, resummarizeJobName)
+}
+
+// Run processes documents in link order, resuming after the checkpoint cursor. The
+// checkpoint is saved after every batch, so a run cut short by the context deadline
+// can simply be triggered again. Documents the deadline interrupted are not counted and
+// the cursor stays before them, so the next run processes them.
+func (s *BedrockDocumentResummarizeService) Run(ctx context.Context, options ResummarizeOptions) (*storage.JobCheckpoint, error) {
+	log := logger.WithContext(ctx)
+	startTime := time.Now()
+	// The checkpoint is still saved once the deadline has passed
+	saveCtx := context.WithoutCancel(ctx)
+
+	if s.config.SafeMode.Enabled() {
+		return nil, ErrSafeModeEnabled
+	}
+
+	checkpoint, err := s.checkpointStore.GetCheckpoint(ctx, resummarizeJobName)
+	if err != nil {
+		return nil, err
+	}
+	if options.Restart || checkpoint == nil || checkpoint.Status == storage.JobStatusCompleted {
+		checkpoint = &storage.JobCheckpoint{
+			JobName:   resummarizeJobName,
+			StartedAt: time.Now().UTC(),
+		}
+	}
+	checkpoint.Status = storage.JobStatusRunning
+
+	documents, err := s.openSearchClient.ListDocuments(ctx)
+	if err != nil {
+		log.Error("Failed to list documents for re-summarization", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		linkI, _ := documents[i]["link"].(string)
+		linkJ, _ := documents[j]["link"].(string)
+		return aws.UnsignedDocumentLink(linkI) < aws.UnsignedDocumentLink(linkJ)
+	})
+	checkpoint.Total = len(documents)
+
+	pending := make([]map[string]interface{}, 0, len(documents))
+	for _, doc := range documents {
+		if link, _ := doc["link"].(string); aws.UnsignedDocumentLink(link) > checkpoint.Cursor {
+			pending = append(pending, doc)
+		}
+	}
+	remaining := len(pending)
+	if options.MaxDocuments > 0 && len(pending) > options.MaxDocuments {
+		pending = pending[:options.MaxDocuments]
+	}
+
+	concurrency := s.config.ResummarizeConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	log.Info("Starting document re-summarization", map[string]interface{}{
+		"total":       checkpoint.Total,
+		"pending":     len(pending),
+		"cursor":      checkpoint.Cursor,
+		"concurrency": concurrency,
+	})
+
+	for start := 0; start < len(pending); start += concurrency {
+		if ctx.Err() != nil {
+			log.Warn("Re-summarization interrupted, progress saved", map[string]interface{}{
+				"cursor": checkpoint.Cursor,
+			})
+			return checkpoint, nil
+		}
+
+		end := start + concurrency
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+
+		errs := make([]error, len(batch))
+		var wg sync.WaitGroup
+		for i, doc := range batch {
+			wg.Add(1)
+			go func(i int, doc map[string]interface{}) {
+				defer wg.Done()
+				errs[i] = s.resummarizeDocument(ctx, doc, documents)
+			}(i, doc)
+		}
+		wg.Wait()
+
+		interrupted := false
+		for i, doc := range batch {
+			link, _ := doc["link"].(string)
+			link = aws.UnsignedDocumentLink(link)
+			if errs[i] != nil && ctx.Err() != nil {
+				interrupted = true
+				break
+			}
+			if errs[i] != nil {
+				log.Warn("Failed to re-summarize document", map[string]interface{}{
+					"link":  link,
+					"error": errs[i].Error(),
+				})
+				checkpoint.Failed++
+				checkpoint.FailedItems = append(checkpoint.FailedItems, link)
+			} else {
+				checkpoint.Succeeded++
+			}
+			checkpoint.Cursor = link
+		}
+
+		checkpoint.UpdatedAt = time.Now().UTC()
+		if err := s.checkpointStore.SaveCheckpoint(saveCtx, checkpoint); err != nil {
+			return nil, err
+		}
+		if interrupted {
+			log.Warn("Re-summarization interrupted, progress saved", map[string]interface{}{
+				"cursor": checkpoint.Cursor,
+			})
+			return checkpoint, nil
+		}
+	}
+
+	if len(pending) == remaining {
+		checkpoint.Status = storage.JobStatusCompleted
+	}
+	checkpoint.UpdatedAt = time.Now().UTC()
+	if err := s.checkpointStore.SaveCheckpoint(saveCtx, checkpoint); err != nil {
+		return nil, err
+	}
+
+	log.Info("Document re-summarization run finished", map[string]interface{}{
+		"duration_ms": time.Since(startTime).Milliseconds(),
+		"status":      checkpoint.Status,
+		"succeeded":   checkpoint.Succeeded,
+		"failed":      checkpoint.Failed,
+	})
+
+	return checkpoint, nil
+}
+
+// resummarizeDocument generates the summary and, when an older version of the same topic
+// exists in the inventory, the change summary for one document
+func (s *BedrockDocumentResummarizeService) resummarizeDocument(ctx context.Context, doc map[string]interface{}, inventory []map[string]interface{}) error {
+	// Summaries are kept under the bucket URL, which a pre-signed link changes on every listing
+	link, _ := doc["link"].(string)
+	link = aws.UnsignedDocumentLink(link)
+	topic, _ := doc["topic"].(string)
+	version, _ := doc["version"].(int)
+	content, _ := doc["content"].(string)
+
+	if content == "" {
+		return fmt.Errorf("no content retrieved for document")
+	}
+
+	summary, err := s.openSearchClient.SummarizeDocument(ctx, content, topic)
+	if err != nil {
+		return err
+	}
+
+	record := &storage.DocumentSummaryRecord{
+		Link:      link,
+		Topic:     topic,
+		Version:   version,
+		Summary:   summary,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	olderDoc := findPreviousVersion(inventory, topic, version)
+	if olderDoc != nil {
+		olderContent, _ := olderDoc["content"].(string)
+		if olderContent != "" {
+			changeSummary, err := s.openSearchClient.CompareDocumentVersions(ctx, content, olderContent, topic)
+			if err != nil {
+				return err
+			}
+			record.ChangeSummary = changeSummary
+		}
+	}
+
+	// Only the first summary of a document announces it, and only the first summary of a
+	// newer version is a new version. Re-runs keep the time the change was detected.
+	var existing *storage.DocumentSummaryRecord
+	if olderDoc != nil || s.events != nil {
+		existing, err = s.summaryStore.GetSummary(ctx, link)
+		if err != nil {
+			return err
+		}
+	}
+	firstSummary := existing == nil
+	if olderDoc != nil {
+		previousLink, _ := olderDoc["link"].(string)
+		record.PreviousLink = aws.UnsignedDocumentLink(previousLink)
+		if existing != nil && existing.ChangeDetectedAt != nil {
+			record.ChangeDetectedAt = existing.ChangeDetectedAt
+		} else {
+			record.ChangeDetectedAt = &record.UpdatedAt
+		}
+	}
+
+	if err := s.summaryStore.PutSummary(ctx, record); err != nil {
+		return err
+	}
+
+	if !firstSummary {
+		return nil
+	}
+	if s.events != nil {
+		s.events.DocumentIngested(ctx, DocumentIngestedEvent{
+			Link:    link,
+			Topic:   topic,
+			Version: version,
+			Summary: record.Summary,
+		})
+	}
+	if olderDoc != nil {
+		previousVersion, _ := olderDoc["version"].(int)
+		event := DocumentVersionEvent{
+			Link:            link,
+			Topic:           topic,
+			Version:         version,
+			PreviousLink:    record.PreviousLink,
+			PreviousVersion: previousVersion,
+			Summary:         record.Summary,
+			ChangeSummary:   record.ChangeSummary,
+		}
+		if s.notifier != nil {
+			s.notifier.NotifyNewVersion(ctx, event)
+		}
+		if s.events != nil {
+			s.events.NotifyNewVersion(ctx, event)
+		}
+	}
+	return nil
+}
+
+// findPreviousVersion finds the highest version of the topic that is older than currentVersion
+func findPreviousVersion(documents []map[string]interface{}, topic string, currentVersion int) map[string]interface{} {
+	var previous map[string]interface{}
+	previousVersion := -1
+	for _, doc := range documents {
+		docTopic, _ := doc["topic"].(string)
+		docVersion, _ := doc["version"].(int)
+		if docTopic == topic && docVersion < currentVersion && docVersion > previousVersion {
+			previous = doc
+			previousVersion = docVersion
+		}
+	}
+	return previous
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type mockOpenSearchClient struct {
+	documents      []map[string]interface{}
+	summarizeErr   map[string]error
+	mu             sync.Mutex
+	summarizeCalls map[string]int
+	compareCalls   int
+	chunks         []aws.DocumentChunk // Of every document
+	searchQueries  []string
+	versions       []map[string]interface{} // Of every topic
+	contents       map[string]string        // By document URI
+	onSummarize    func(content string)
+}
+
+func (m *mockOpenSearchClient) GetLastUpdateDocuments(ctx context.Context) ([]map[string]interface{}, error) {
+	return m.documents, nil
+}
+
+func (m *mockOpenSearchClient) ListDocuments(ctx context.Context) ([]map[string]interface{}, error) {
+	return m.documents, nil
+}
+
+func (m *mockOpenSearchClient) ListTopicVersions(ctx context.Context, topic string) ([]map[string]interface{}, error) {
+	return m.versions, nil
+}
+
+func (m *mockOpenSearchClient) CompareDocumentVersions(ctx context.Context, newerContent, olderContent, topic string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.compareCalls++
+	return fmt.Sprintf("changed from %s to %s", olderContent, newerContent), nil
+}
+
+func (m *mockOpenSearchClient) SummarizeDocument(ctx context.Context, content, topic string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.summarizeCalls == nil {
+		m.summarizeCalls = make(map[string]int)
+	}
+	m.summarizeCalls[content]++
+	if m.onSummarize != nil {
+		m.onSummarize(content)
+	}
+	if err := m.summarizeErr[content]; err != nil {
+		return "", err
+	}
+	return "summary of " + content, nil
+}
+
+func (m *mockOpenSearchClient) GetDocumentChunks(ctx context.Context, documentUri string) ([]aws.DocumentChunk, error) {
+	return m.chunks, nil
+}
+
+func (m *mockOpenSearchClient) GetDocumentContent(ctx context.Context, documentUri string) (string, error) {
+	return m.contents[documentUri], nil
+}
+
+func (m *mockOpenSearchClient) SearchDocumentChunks(ctx context.Context, documentUri string, query string, numberOfResults int) ([]aws.DocumentChunk, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.searchQueries = append(m.searchQueries, query)
+	if len(m.chunks) > numberOfResults {
+		return m.chunks[:numberOfResults], nil
+	}
+	return m.chunks, nil
+}
+
+type memorySummaryStore struct {
+	mu      sync.Mutex
+	records map[string]*storage.DocumentSummaryRecord
+}
+
+func (m *memorySummaryStore) GetSummary(ctx context.Context, link string) (*storage.DocumentSummaryRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.records[link], nil
+}
+
+func (m *memorySummaryStore) ListChanges(ctx context.Context, since time.Time) ([]storage.DocumentSummaryRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var records []storage.DocumentSummaryRecord
+	for _, record := range m.records {
+		if record.ChangeDetectedAt != nil && !record.ChangeDetectedAt.Before(since) {
+			records = append(records, *record)
+		}
+	}
+	return records, nil
+}
+
+func (m *memorySummaryStore) PutSummary(ctx context.Context, record *storage.DocumentSummaryRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.records == nil {
+		m.records = make(map[string]*storage.DocumentSummaryRecord)
+	}
+	m.records[record.Link] = record
+	return nil
+}
+
+type memoryCheckpointStore struct {
+	checkpoints map[string]storage.JobCheckpoint
+}
+
+func (m *memoryCheckpointStore) GetCheckpoint(ctx context.Context, jobName string) (*storage.JobCheckpoint, error) {
+	checkpoint, ok := m.checkpoints[jobName]
+	if !ok {
+		return nil, nil
+	}
+	return &checkpoint, nil
+}
+
+func (m *memoryCheckpointStore) SaveCheckpoint(ctx context.Context, checkpoint *storage.JobCheckpoint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if m.checkpoints == nil {
+		m.checkpoints = make(map[string]storage.JobCheckpoint)
+	}
+	m.checkpoints[checkpoint.JobName] = *checkpoint
+	return nil
+}
+
+func testInventory() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"link": "https://b/content/2025/05/waive-2.pdf", "topic": "waive", "version": 2, "content": "waive v2"},
+		{"link": "https://b/content/2025/01/waive-1.pdf", "topic": "waive", "version": 1, "content": "waive v1"},
+		{"link": "https://b/content/2025/03/horaland.pdf", "topic": "horaland", "version": 0, "content": "horaland"},
+	}
+}
+
+func TestResummarize_FullRun(t *testing.T) {
+	client := &mockOpenSearchClient{documents: testInventory()}
+	summaries := &memorySummaryStore{}
+	checkpoints := &memoryCheckpointStore{}
+	service := NewBedrockDocumentResummarizeService(client, summaries, checkpoints, nil, nil, &config.Config{ResummarizeConcurrency: 2})
+
+	checkpoint, err := service.Run(context.Background(), ResummarizeOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if checkpoint.Status != storage.JobStatusCompleted {
+		t.Fatalf("expected status completed, got %s", checkpoint.Status)
+	}
+	if checkpoint.Succeeded != 3 || checkpoint.Failed != 0 {
+		t.Fatalf("expected 3 succeeded and 0 failed, got %d and %d", checkpoint.Succeeded, checkpoint.Failed)
+	}
+
+	newest := summaries.records["https://b/content/2025/05/waive-2.pdf"]
+	if newest == nil || newest.Summary != "summary of waive v2" {
+		t.Fatalf("expected summary for newest version, got %+v", newest)
+	}
+	if newest.ChangeSummary != "changed from waive v1 to waive v2" {
+		t.Fatalf("expected change summary against version 1, got '%s'", newest.ChangeSummary)
+	}
+	if client.compareCalls != 1 {
+		t.Fatalf("expected 1 comparison, got %d", client.compareCalls)
+	}
+}
+
+func TestResummarize_ResumesFromCheckpoint(t *testing.T) {
+	client := &mockOpenSearchClient{documents: testInventory()}
+	summaries := &memorySummaryStore{}
+	checkpoints := &memoryCheckpointStore{}
+	service := NewBedrockDocumentResummarizeService(client, summaries, checkpoints, nil, nil, &config.Config{ResummarizeConcurrency: 1})
+
+	first, err := service.Run(context.Background(), ResummarizeOptions{MaxDocuments: 1})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if first.Status != storage.JobStatusRunning || first.Succeeded != 1 {
+		t.Fatalf("expected running job with 1 document done, got %+v", first)
+	}
+
+	second, err := service.Run(context.Background(), ResummarizeOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if second.Status != storage.JobStatusCompleted || second.Succeeded != 3 {
+		t.Fatalf("expected completed job with 3 documents done, got %+v", second)
+	}
+
+	for content, calls := range client.summarizeCalls {
+		if calls != 1 {
+			t.Fatalf("expected '%s' to be summarized once, got %d", content, calls)
+		}
+	}
+}
+
+func TestResummarize_RecordsFailures(t *testing.T) {
+	client := &mockOpenSearchClient{
+		documents:    testInventory(),
+		summarizeErr: map[string]error{"horaland": fmt.Errorf("model error")},
+	}
+	checkpoints := &memoryCheckpointStore{}
+	service := NewBedrockDocumentResummarizeService(client, &memorySummaryStore{}, checkpoints, nil, nil, &config.Config{ResummarizeConcurrency: 3})
+
+	checkpoint, err := service.Run(context.Background(), ResummarizeOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if checkpoint.Failed != 1 || len(checkpoint.FailedItems) != 1 || checkpoint.FailedItems[0] != "https://b/content/2025/03/horaland.pdf" {
+		t.Fatalf("expected horaland to be recorded as failed, got %+v", checkpoint)
+	}
+	if checkpoint.Status != storage.JobStatusCompleted {
+		t.Fatalf("expected job to complete despite failures, got %s", checkpoint.Status)
+	}
+}
+
+func TestResummarize_DeadlineKeepsInterruptedDocumentsPending(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &mockOpenSearchClient{
+		documents:    testInventory(),
+		summarizeErr: map[string]error{"horaland": context.Canceled, "waive v2": context.Canceled},
+		onSummarize: func(content string) {
+			if content == "horaland" {
+				cancel()
+			}
+		},
+	}
+	checkpoints := &memoryCheckpointStore{}
+	service := NewBedrockDocumentResummarizeService(client, &memorySummaryStore{}, checkpoints, nil, nil, &config.Config{ResummarizeConcurrency: 3})
+
+	checkpoint, err := service.Run(ctx, ResummarizeOptions{})
+	if err != nil {
+		t.Fatalf("expected the progress to be saved, got %v", err)
+	}
+	if checkpoint.Succeeded != 1 || checkpoint.Failed != 0 || checkpoint.Cursor != "https://b/content/2025/01/waive-1.pdf" {
+		t.Fatalf("expected the cursor before the interrupted documents, got %+v", checkpoint)
+	}
+	if saved := checkpoints.checkpoints[resummarizeJobName]; saved.Cursor != checkpoint.Cursor || saved.Status != storage.JobStatusRunning {
+		t.Fatalf("expected the checkpoint to be saved, got %+v", saved)
+	}
+
+	client.summarizeErr = nil
+	client.onSummarize = nil
+	second, err := service.Run(context.Background(), ResummarizeOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if second.Status != storage.JobStatusCompleted || second.Succeeded != 3 || second.Failed != 0 {
+		t.Errorf("expected the next run to process the interrupted documents, got %+v", second)
+	}
+}
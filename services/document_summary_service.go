@@ -1,307 +1,549 @@
-package services
-
-import (
-	"context"
-	"fmt"
-	"regexp"
-	"sort"
-	"strings"
-	"time"
-
-	"teletubpax-api/aws"
-	"teletubpax-api/config"
-	"teletubpax-api/logger"
-)
-
-type DocumentSummaryItem struct {
-	Order                    int    `json:"order"`
-	Link                     string `json:"link"`
-	Summary                  string `json:"summary"`
-	DifferenceFromOldVersion string `json:"differenceFromOldVersion"`
-}
-
-type DocumentSummaryService interface {
-	AnalyzeDocuments(ctx context.Context, documentUrls []string) ([]DocumentSummaryItem, error)
-}
-
-type BedrockDocumentSummaryService struct {
-	openSearchClient aws.OpenSearchClient
-	kbClient         aws.KnowledgeBaseClient
-	config           *config.Config
-}
-
-func NewBedrockDocumentSummaryService(
-	openSearchClient aws.OpenSearchClient,
-	kbClient aws.KnowledgeBaseClient,
-	cfg *config.Config,
-) *BedrockDocumentSummaryService {
-	return &BedrockDocumentSummaryService{
-		openSearchClient: openSearchClient,
-		kbClient:         kbClient,
-		config:           cfg,
-	}
-}
-
-type documentInfo struct {
-	url          string
-	topic        string
-	version      int
-	yearMonth    string
-	sortKey      string
-	order        int
-	summary      string
-	difference   string
-	content      string
-	lastModified time.Time
-}
-
-func (s *BedrockDocumentSummaryService) AnalyzeDocuments(ctx context.Context, documentUrls []string) ([]DocumentSummaryItem, error) {
-	log := logger.WithContext(ctx)
-	log.Info("Starting document analysis", map[string]interface{}{
-		"document_count": len(documentUrls),
-	})
-	startTime := time.Now()
-
-	// Step 1: Parse and extract metadata from URLs
-	documents := make([]documentInfo, 0, len(documentUrls))
-	for _, url := range documentUrls {
-		doc := documentInfo{
-			url:       url,
-			topic:     s.extractTopicFromUrl(url),
-			version:   s.extractVersionNumber(url),
-			yearMonth: s.extractYearMonthFromUrl(url),
-		}
-		doc.sortKey = s.createSortKey(doc.yearMonth, doc.version)
-		documents = append(documents, doc)
-	}
-
-	log.Info("Extracted metadata from URLs", map[string]interface{}{
-		"document_count": len(documents),
-	})
-
-	// Step 2: Sort documents by date (newest first), then by version (highest first)
-	sort.Slice(documents, func(i, j int) bool {
-		// Primary: Sort by year/month (newest first)
-		if documents[i].yearMonth != documents[j].yearMonth {
-			return documents[i].yearMonth > documents[j].yearMonth
-		}
-		// Secondary: Sort by version (highest first)
-		return documents[i].version > documents[j].version
-	})
-
-	// Step 3: Assign order numbers
-	for i := range documents {
-		documents[i].order = i + 1
-	}
-
-	// Step 4: For now, skip content retrieval to avoid the loop issue
-	// Content retrieval will be added in a future optimization
-	// The summaries will be generated based on topic names only
-	log.Info("Skipping content retrieval (optimization needed)", map[string]interface{}{})
-
-	// Step 5: Generate summaries based on topic and metadata
-	log.Info("Generating summaries based on metadata", map[string]interface{}{})
-	for i := range documents {
-		// Generate summary from topic name and metadata
-		documents[i].summary = s.generateSummaryFromMetadata(documents[i].topic, documents[i].yearMonth, documents[i].version)
-
-		// Find older version for comparison
-		olderDoc := s.findOlderVersion(documents, documents[i].topic, documents[i].version, i)
-		if olderDoc != nil {
-			documents[i].difference = fmt.Sprintf("เวอร์ชัน %d (อัปเดตจากเวอร์ชัน %d)", documents[i].version, olderDoc.version)
-		} else {
-			if documents[i].version > 0 {
-				documents[i].difference = fmt.Sprintf("เวอร์ชัน %d (เวอร์ชันแรก)", documents[i].version)
-			} else {
-				documents[i].difference = "เอกสารฉบับเดียว"
-			}
-		}
-	}
-
-	// Step 6: Convert to response format
-	result := make([]DocumentSummaryItem, 0, len(documents))
-	for _, doc := range documents {
-		result = append(result, DocumentSummaryItem{
-			Order:                    doc.order,
-			Link:                     doc.url,
-			Summary:                  doc.summary,
-			DifferenceFromOldVersion: doc.difference,
-		})
-	}
-
-	duration := time.Since(startTime)
-	log.Info("Document analysis completed", map[string]interface{}{
-		"duration_ms":    duration.Milliseconds(),
-		"document_count": len(result),
-	})
-
-	return result, nil
-}
-
-// retrieveDocumentContent retrieves the content of a document from the Knowledge Base
-func (s *BedrockDocumentSummaryService) retrieveDocumentContent(ctx context.Context, documentUrl string) (string, error) {
-	log := logger.WithContext(ctx)
-
-	// Extract topic to use as search query
-	topic := s.extractTopicFromUrl(documentUrl)
-
-	log.Info("Retrieving document content", map[string]interface{}{
-		"url":   documentUrl,
-		"topic": topic,
-	})
-
-	// Use OpenSearch client to retrieve document content directly
-	// This is more efficient than querying all knowledge bases
-	docs, err := s.openSearchClient.GetLastUpdateDocuments(ctx)
-	if err != nil {
-		log.Warn("Failed to retrieve documents from OpenSearch", map[string]interface{}{
-			"error": err.Error(),
-		})
-		return "", err
-	}
-
-	// Find the matching document by URL
-	for _, doc := range docs {
-		if link, ok := doc["link"].(string); ok && link == documentUrl {
-			if content, ok := doc["content"].(string); ok {
-				log.Info("Found document content", map[string]interface{}{
-					"url":            documentUrl,
-					"content_length": len(content),
-				})
-				return content, nil
-			}
-		}
-	}
-
-	log.Warn("Document not found in OpenSearch results", map[string]interface{}{
-		"url": documentUrl,
-	})
-
-	// Fallback: return empty content
-	return "", fmt.Errorf("document not found: %s", documentUrl)
-}
-
-// generateSummaryFromMetadata generates a summary based on document metadata
-func (s *BedrockDocumentSummaryService) generateSummaryFromMetadata(topic string, yearMonth string, version int) string {
-	// Clean up topic name for better readability
-	cleanTopic := strings.ReplaceAll(topic, "-_-", " - ")
-	cleanTopic = strings.ReplaceAll(cleanTopic, "_", " ")
-	cleanTopic = strings.ReplaceAll(cleanTopic, "-", " ")
-
-	// Format date
-	var dateStr string
-	if yearMonth != "0000/00" {
-		parts := strings.Split(yearMonth, "/")
-		if len(parts) == 2 {
-			dateStr = fmt.Sprintf(" (อัปเดต: %s/%s)", parts[1], parts[0])
-		}
-	}
-
-	// Format version
-	var versionStr string
-	if version > 0 {
-		versionStr = fmt.Sprintf(" [เวอร์ชัน %d]", version)
-	}
-
-	return fmt.Sprintf("เอกสาร: %s%s%s", cleanTopic, versionStr, dateStr)
-}
-
-// findOlderVersion finds an older version of the same topic
-func (s *BedrockDocumentSummaryService) findOlderVersion(documents []documentInfo, topic string, currentVersion int, currentIndex int) *documentInfo {
-	for i := range documents {
-		if i == currentIndex {
-			continue
-		}
-
-		// Same topic but older version
-		if documents[i].topic == topic && documents[i].version < currentVersion {
-			return &documents[i]
-		}
-	}
-	return nil
-}
-
-// extractYearMonthFromUrl extracts year/month from URL path
-func (s *BedrockDocumentSummaryService) extractYearMonthFromUrl(url string) string {
-	re := regexp.MustCompile(`/(\d{4})/(\d{2})/`)
-	matches := re.FindStringSubmatch(url)
-
-	if len(matches) >= 3 {
-		return fmt.Sprintf("%s/%s", matches[1], matches[2])
-	}
-
-	return "0000/00"
-}
-
-// createSortKey creates a sortable key from year/month and version
-func (s *BedrockDocumentSummaryService) createSortKey(yearMonth string, version int) string {
-	ym := strings.ReplaceAll(yearMonth, "/", "")
-	return fmt.Sprintf("%s-%03d", ym, version)
-}
-
-// extractVersionNumber extracts version number from filename
-func (s *BedrockDocumentSummaryService) extractVersionNumber(url string) int {
-	parts := strings.Split(url, "/")
-	if len(parts) == 0 {
-		return 0
-	}
-	filename := parts[len(parts)-1]
-
-	// Remove file extension
-	filename = strings.TrimSuffix(filename, ".pdf")
-	filename = strings.TrimSuffix(filename, ".PDF")
-	filename = strings.TrimSuffix(filename, ".doc")
-	filename = strings.TrimSuffix(filename, ".docx")
-	filename = strings.TrimSuffix(filename, ".txt")
-
-	// Pattern to match version number at the end
-	re := regexp.MustCompile(`-(\d+)$`)
-	matches := re.FindStringSubmatch(filename)
-
-	if len(matches) >= 2 {
-		var version int
-		fmt.Sscanf(matches[1], "%d", &version)
-		return version
-	}
-
-	return 0
-}
-
-// extractTopicFromUrl extracts the topic/title from the filename
-func (s *BedrockDocumentSummaryService) extractTopicFromUrl(url string) string {
-	parts := strings.Split(url, "/")
-	if len(parts) == 0 {
-		return ""
-	}
-	filename := parts[len(parts)-1]
-
-	// Remove file extension
-	filename = strings.TrimSuffix(filename, ".pdf")
-	filename = strings.TrimSuffix(filename, ".PDF")
-	filename = strings.TrimSuffix(filename, ".doc")
-	filename = strings.TrimSuffix(filename, ".docx")
-	filename = strings.TrimSuffix(filename, ".txt")
-
-	// Remove version number suffix
-	re := regexp.MustCompile(`-(\d+)$`)
-	filename = re.ReplaceAllString(filename, "")
-
-	return filename
-}
-
-// convertPublicUrlToS3Uri converts public URL to S3 URI
-func (s *BedrockDocumentSummaryService) convertPublicUrlToS3Uri(publicUrl string) string {
-	// Example: https://bucket.s3.region.amazonaws.com/path/to/file.pdf
-	// Convert to: s3://bucket/path/to/file.pdf
-
-	re := regexp.MustCompile(`https://([^.]+)\.s3\.[^.]+\.amazonaws\.com/(.+)`)
-	matches := re.FindStringSubmatch(publicUrl)
-
-	if len(matches) >= 3 {
-		bucket := matches[1]
-		key := matches[2]
-		return fmt.Sprintf("s3://%s/%s", bucket, key)
-	}
-
-	return publicUrl
-}
+package services
+
+import (
+	"context"
+	"fmt"
+	neturl "net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/errors"
+	"teletubpax-api/flags"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+	"teletubpax-api/warnings"
+)
+
+type DocumentSummaryItem struct {
+	Order                    int    `json:"order"`
+	Link                     string `json:"link"`
+	Summary                  string `json:"summary"`
+	DifferenceFromOldVersion string `json:"differenceFromOldVersion"`
+	Error                    string `json:"error,omitempty"`
+}
+
+// InvalidDocument is a requested URL that was rejected before analysis
+type InvalidDocument struct {
+	Link  string `json:"link"`
+	Error string `json:"error"`
+}
+
+type DocumentSummaryResult struct {
+	Documents        []DocumentSummaryItem
+	InvalidDocuments []InvalidDocument
+}
+
+type DocumentSummaryService interface {
+	AnalyzeDocuments(ctx context.Context, documentUrls []string) (*DocumentSummaryResult, error)
+}
+
+const (
+	defaultMaxSummaryDocuments    = 20               // Applies when MaxSummaryDocuments is not configured
+	defaultSummaryWorkers         = 4                // Applies when SummaryWorkers is not configured
+	defaultSummaryDocumentTimeout = 10 * time.Second // Applies when SummaryDocumentTimeoutSeconds is not configured
+	contentSummaryFlag            = "document-summary-content"
+)
+
+type BedrockDocumentSummaryService struct {
+	openSearchClient aws.OpenSearchClient
+	kbClient         aws.KnowledgeBaseClient
+	summaryStore     storage.DocumentSummaryStore // Optional precomputed summaries
+	config           *config.Config
+}
+
+func NewBedrockDocumentSummaryService(
+	openSearchClient aws.OpenSearchClient,
+	kbClient aws.KnowledgeBaseClient,
+	summaryStore storage.DocumentSummaryStore,
+	cfg *config.Config,
+) *BedrockDocumentSummaryService {
+	return &BedrockDocumentSummaryService{
+		openSearchClient: openSearchClient,
+		kbClient:         kbClient,
+		summaryStore:     summaryStore,
+		config:           cfg,
+	}
+}
+
+type documentInfo struct {
+	url          string
+	topic        string
+	version      int
+	yearMonth    string
+	sortKey      string
+	order        int
+	summary      string
+	difference   string
+	content      string
+	lastModified time.Time
+	err          string
+	changeStored bool // The difference is a precomputed change summary
+}
+
+func (s *BedrockDocumentSummaryService) AnalyzeDocuments(ctx context.Context, documentUrls []string) (*DocumentSummaryResult, error) {
+	log := logger.WithContext(ctx)
+	log.Info("Starting document analysis", map[string]interface{}{
+		"document_count": len(documentUrls),
+	})
+	startTime := time.Now()
+
+	maxDocuments := s.config.MaxSummaryDocuments
+	if maxDocuments <= 0 {
+		maxDocuments = defaultMaxSummaryDocuments
+	}
+	if len(documentUrls) > maxDocuments {
+		return nil, errors.NewValidationError(fmt.Sprintf("relatedDocuments must not contain more than %d URLs", maxDocuments))
+	}
+
+	// Step 1: Validate and dedupe URLs, then extract metadata
+	validUrls, invalidDocuments := s.validateDocumentUrls(documentUrls)
+	if aws.DocumentAccessFromContext(ctx).Restricted() {
+		var err error
+		validUrls, invalidDocuments, err = s.keepAccessibleDocuments(ctx, validUrls, invalidDocuments)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(invalidDocuments) > 0 {
+		log.Warn("Rejected invalid document URLs", map[string]interface{}{
+			"invalid_count": len(invalidDocuments),
+		})
+	}
+
+	documents := s.describeDocuments(validUrls)
+	log.Info("Extracted metadata from URLs", map[string]interface{}{
+		"document_count": len(documents),
+	})
+
+	// Step 5: Replace them with precomputed or content-based summaries using a bounded
+	// worker pool. A failing document keeps its metadata summary and reports an error.
+	s.summarizeDocuments(ctx, documents)
+
+	// Step 6: Convert to response format
+	result := make([]DocumentSummaryItem, 0, len(documents))
+	for _, doc := range documents {
+		result = append(result, doc.item())
+	}
+
+	duration := time.Since(startTime)
+	log.Info("Document analysis completed", map[string]interface{}{
+		"duration_ms":    duration.Milliseconds(),
+		"document_count": len(result),
+	})
+
+	return &DocumentSummaryResult{
+		Documents:        result,
+		InvalidDocuments: invalidDocuments,
+	}, nil
+}
+
+func (d documentInfo) item() DocumentSummaryItem {
+	return DocumentSummaryItem{
+		Order:                    d.order,
+		Link:                     d.url,
+		Summary:                  d.summary,
+		DifferenceFromOldVersion: d.difference,
+		Error:                    d.err,
+	}
+}
+
+// describeDocuments extracts the metadata of validated URLs, orders the documents newest
+// first and describes them from their metadata
+func (s *BedrockDocumentSummaryService) describeDocuments(validUrls []string) []documentInfo {
+	documents := make([]documentInfo, 0, len(validUrls))
+	for _, url := range validUrls {
+		doc := documentInfo{
+			url:       url,
+			topic:     s.extractTopicFromUrl(url),
+			version:   s.extractVersionNumber(url),
+			yearMonth: s.extractYearMonthFromUrl(url),
+		}
+		doc.sortKey = s.createSortKey(doc.yearMonth, doc.version)
+		documents = append(documents, doc)
+	}
+
+	// Step 2: Sort documents by date (newest first), then by version (highest first)
+	sort.Slice(documents, func(i, j int) bool {
+		// Primary: Sort by year/month (newest first)
+		if documents[i].yearMonth != documents[j].yearMonth {
+			return documents[i].yearMonth > documents[j].yearMonth
+		}
+		// Secondary: Sort by version (highest first)
+		return documents[i].version > documents[j].version
+	})
+
+	// Step 3: Assign order numbers
+	for i := range documents {
+		documents[i].order = i + 1
+	}
+
+	// Step 4: Generate metadata-based summaries and version differences
+	for i := range documents {
+		// Generate summary from topic name and metadata
+		documents[i].summary = s.generateSummaryFromMetadata(documents[i].topic, documents[i].yearMonth, documents[i].version)
+
+		// Find older version for comparison
+		olderDoc := s.findOlderVersion(documents, documents[i].topic, documents[i].version, i)
+		if olderDoc != nil {
+			documents[i].difference = fmt.Sprintf("เวอร์ชัน %d (อัปเดตจากเวอร์ชัน %d)", documents[i].version, olderDoc.version)
+		} else {
+			if documents[i].version > 0 {
+				documents[i].difference = fmt.Sprintf("เวอร์ชัน %d (เวอร์ชันแรก)", documents[i].version)
+			} else {
+				documents[i].difference = "เอกสารฉบับเดียว"
+			}
+		}
+	}
+	return documents
+}
+
+// keepAccessibleDocuments drops the documents the caller cannot retrieve, so precomputed
+// summaries of restricted documents are not returned. They are reported like unknown
+// documents, without revealing that they exist.
+func (s *BedrockDocumentSummaryService) keepAccessibleDocuments(ctx context.Context, urls []string, invalid []InvalidDocument) ([]string, []InvalidDocument, error) {
+	accessible := make([]string, 0, len(urls))
+	for _, url := range urls {
+		chunks, err := s.openSearchClient.GetDocumentChunks(ctx, url)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(chunks) == 0 {
+			invalid = append(invalid, InvalidDocument{Link: url, Error: "document not found"})
+			continue
+		}
+		accessible = append(accessible, url)
+	}
+	return accessible, invalid, nil
+}
+
+// validateDocumentUrls keeps the first occurrence of every well-formed https URL on an
+// allowed host and reports why the others were rejected. Pre-signed links are reduced to
+// their bucket URL, which identifies the document.
+func (s *BedrockDocumentSummaryService) validateDocumentUrls(documentUrls []string) ([]string, []InvalidDocument) {
+	valid := make([]string, 0, len(documentUrls))
+	invalid := []InvalidDocument{}
+	seen := make(map[string]bool)
+
+	for _, rawUrl := range documentUrls {
+		documentUrl := aws.UnsignedDocumentLink(strings.TrimSpace(rawUrl))
+		if reason := s.validateDocumentUrl(documentUrl); reason != "" {
+			invalid = append(invalid, InvalidDocument{Link: rawUrl, Error: reason})
+			continue
+		}
+		if seen[documentUrl] {
+			continue
+		}
+		seen[documentUrl] = true
+		valid = append(valid, documentUrl)
+	}
+
+	return valid, invalid
+}
+
+// validateDocumentUrl returns the rejection reason, or an empty string for a valid URL
+func (s *BedrockDocumentSummaryService) validateDocumentUrl(documentUrl string) string {
+	if documentUrl == "" {
+		return "URL is empty"
+	}
+
+	parsed, err := neturl.Parse(documentUrl)
+	if err != nil || parsed.Host == "" {
+		return "URL is malformed"
+	}
+	if parsed.Scheme != "https" {
+		return "URL must use https"
+	}
+	if parsed.User != nil || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "URL must not contain credentials, a query or a fragment"
+	}
+	if strings.Trim(parsed.Path, "/") == "" {
+		return "URL does not point to a document"
+	}
+	if !s.isAllowedHost(parsed.Hostname()) {
+		return "URL host is not an allowed document domain"
+	}
+	return ""
+}
+
+// isAllowedHost matches exact hosts and "*.suffix" wildcards. An empty allow-list allows
+// no host.
+func (s *BedrockDocumentSummaryService) isAllowedHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range s.config.DocumentAllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeDocuments processes every document with at most SummaryWorkers goroutines and
+// a per-document timeout. Content-based summaries need the document-summary-content flag
+// and are skipped in safe mode.
+func (s *BedrockDocumentSummaryService) summarizeDocuments(ctx context.Context, documents []documentInfo) {
+	log := logger.WithContext(ctx)
+
+	workers := s.config.SummaryWorkers
+	if workers <= 0 {
+		workers = defaultSummaryWorkers
+	}
+	timeout := time.Duration(s.config.SummaryDocumentTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultSummaryDocumentTimeout
+	}
+
+	var contents map[string]string
+	if flags.Enabled(contentSummaryFlag) {
+		if s.config.SafeMode.Enabled() {
+			warnings.Add(ctx, warnings.CodeSafeMode, "Safe mode is on, documents without a precomputed summary are described from their metadata")
+		} else {
+			var err error
+			contents, err = s.retrieveDocumentContents(ctx)
+			if err != nil {
+				log.Warn("Failed to retrieve document contents, using metadata summaries", map[string]interface{}{
+					"error": err.Error(),
+				})
+				warnings.Add(ctx, warnings.CodeContentUnavailable, "Document contents could not be retrieved, documents without a precomputed summary are described from their metadata")
+			}
+		}
+	}
+
+	log.Info("Summarizing documents", map[string]interface{}{
+		"document_count":  len(documents),
+		"workers":         workers,
+		"timeout_ms":      timeout.Milliseconds(),
+		"content_summary": contents != nil,
+	})
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(documents); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				docCtx, cancel := context.WithTimeout(ctx, timeout)
+				s.summarizeDocument(docCtx, &documents[i], contents)
+				cancel()
+			}
+		}()
+	}
+	for i := range documents {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// summarizeDocument prefers the precomputed summary, then a content-based summary when
+// contents were retrieved, and otherwise keeps the metadata summary
+func (s *BedrockDocumentSummaryService) summarizeDocument(ctx context.Context, doc *documentInfo, contents map[string]string) {
+	if s.applyPrecomputedSummary(ctx, doc) || contents == nil {
+		return
+	}
+
+	content := contents[doc.url]
+	if content == "" {
+		doc.err = "document content not found"
+		return
+	}
+
+	summary, err := s.openSearchClient.SummarizeDocument(ctx, content, doc.topic)
+	if err != nil {
+		logger.WithContext(ctx).Warn("Failed to summarize document", map[string]interface{}{
+			"url":   doc.url,
+			"error": err.Error(),
+		})
+		if ctx.Err() == context.DeadlineExceeded {
+			doc.err = "summarization timed out"
+		} else {
+			doc.err = "summarization failed"
+		}
+		return
+	}
+	doc.summary = summary
+}
+
+// retrieveDocumentContents retrieves the content of every document in the Knowledge Base
+// once per request, keyed by public link
+func (s *BedrockDocumentSummaryService) retrieveDocumentContents(ctx context.Context) (map[string]string, error) {
+	docs, err := s.openSearchClient.ListDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := make(map[string]string, len(docs))
+	for _, doc := range docs {
+		link, _ := doc["link"].(string)
+		content, _ := doc["content"].(string)
+		if link != "" && content != "" {
+			contents[aws.UnsignedDocumentLink(link)] = content
+		}
+	}
+	return contents, nil
+}
+
+// applyPrecomputedSummary overrides the metadata-based summary and difference with the
+// stored ones when the re-summarization job has already processed the document. It reports
+// whether a stored summary was applied.
+func (s *BedrockDocumentSummaryService) applyPrecomputedSummary(ctx context.Context, doc *documentInfo) bool {
+	if s.summaryStore == nil {
+		return false
+	}
+
+	record, err := s.summaryStore.GetSummary(ctx, doc.url)
+	if err != nil {
+		logger.WithContext(ctx).Warn("Failed to read precomputed summary", map[string]interface{}{
+			"url":   doc.url,
+			"error": err.Error(),
+		})
+		return false
+	}
+	if record == nil {
+		return false
+	}
+
+	if record.Summary != "" {
+		doc.summary = record.Summary
+	}
+	if record.ChangeSummary != "" {
+		doc.difference = record.ChangeSummary
+		doc.changeStored = true
+	}
+	return record.Summary != ""
+}
+
+// generateSummaryFromMetadata generates a summary based on document metadata
+func (s *BedrockDocumentSummaryService) generateSummaryFromMetadata(topic string, yearMonth string, version int) string {
+	// Clean up topic name for better readability
+	cleanTopic := strings.ReplaceAll(topic, "-_-", " - ")
+	cleanTopic = strings.ReplaceAll(cleanTopic, "_", " ")
+	cleanTopic = strings.ReplaceAll(cleanTopic, "-", " ")
+
+	// Format date
+	var dateStr string
+	if yearMonth != "0000/00" {
+		parts := strings.Split(yearMonth, "/")
+		if len(parts) == 2 {
+			dateStr = fmt.Sprintf(" (อัปเดต: %s/%s)", parts[1], parts[0])
+		}
+	}
+
+	// Format version
+	var versionStr string
+	if version > 0 {
+		versionStr = fmt.Sprintf(" [เวอร์ชัน %d]", version)
+	}
+
+	return fmt.Sprintf("เอกสาร: %s%s%s", cleanTopic, versionStr, dateStr)
+}
+
+// findOlderVersion finds an older version of the same topic
+func (s *BedrockDocumentSummaryService) findOlderVersion(documents []documentInfo, topic string, currentVersion int, currentIndex int) *documentInfo {
+	for i := range documents {
+		if i == currentIndex {
+			continue
+		}
+
+		// Same topic but older version
+		if documents[i].topic == topic && documents[i].version < currentVersion {
+			return &documents[i]
+		}
+	}
+	return nil
+}
+
+// extractYearMonthFromUrl extracts year/month from URL path
+func (s *BedrockDocumentSummaryService) extractYearMonthFromUrl(url string) string {
+	re := regexp.MustCompile(`/(\d{4})/(\d{2})/`)
+	matches := re.FindStringSubmatch(url)
+
+	if len(matches) >= 3 {
+		return fmt.Sprintf("%s/%s", matches[1], matches[2])
+	}
+
+	return "0000/00"
+}
+
+// createSortKey creates a sortable key from year/month and version
+func (s *BedrockDocumentSummaryService) createSortKey(yearMonth string, version int) string {
+	ym := strings.ReplaceAll(yearMonth, "/", "")
+	return fmt.Sprintf("%s-%03d", ym, version)
+}
+
+// extractVersionNumber extracts version number from filename
+func (s *BedrockDocumentSummaryService) extractVersionNumber(url string) int {
+	parts := strings.Split(url, "/")
+	if len(parts) == 0 {
+		return 0
+	}
+	filename := parts[len(parts)-1]
+
+	// Remove file extension
+	filename = strings.TrimSuffix(filename, ".pdf")
+	filename = strings.TrimSuffix(filename, ".PDF")
+	filename = strings.TrimSuffix(filename, ".doc")
+	filename = strings.TrimSuffix(filename, ".docx")
+	filename = strings.TrimSuffix(filename, ".txt")
+
+	// Pattern to match version number at the end
+	re := regexp.MustCompile(`-(\d+)$`)
+	matches := re.FindStringSubmatch(filename)
+
+	if len(matches) >= 2 {
+		var version int
+		fmt.Sscanf(matches[1], "%d", &version)
+		return version
+	}
+
+	return 0
+}
+
+// extractTopicFromUrl extracts the topic/title from the filename
+func (s *BedrockDocumentSummaryService) extractTopicFromUrl(url string) string {
+	parts := strings.Split(url, "/")
+	if len(parts) == 0 {
+		return ""
+	}
+	filename := parts[len(parts)-1]
+
+	// Remove file extension
+	filename = strings.TrimSuffix(filename, ".pdf")
+	filename = strings.TrimSuffix(filename, ".PDF")
+	filename = strings.TrimSuffix(filename, ".doc")
+	filename = strings.TrimSuffix(filename, ".docx")
+	filename = strings.TrimSuffix(filename, ".txt")
+
+	// Remove version number suffix
+	re := regexp.MustCompile(`-(\d+)$`)
+	filename = re.ReplaceAllString(filename, "")
+
+	return filename
+}
+
+// convertPublicUrlToS3Uri converts public URL to S3 URI
+func (s *BedrockDocumentSummaryService) convertPublicUrlToS3Uri(publicUrl string) string {
+	// Example: https://bucket.s3.region.amazonaws.com/path/to/file.pdf
+	// Convert to: s3://bucket/path/to/file.pdf
+
+	re := regexp.MustCompile(`https://([^.]+)\.s3\.[^.]+\.amazonaws\.com/(.+)`)
+	matches := re.FindStringSubmatch(publicUrl)
+
+	if len(matches) >= 3 {
+		bucket := matches[1]
+		key := matches[2]
+		return fmt.Sprintf("s3://%s/%s", bucket, key)
+	}
+
+	return publicUrl
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	bedrockErrors "teletubpax-api/errors"
+	"teletubpax-api/flags"
+)
+
+func TestAnalyzeDocuments_ValidatesAndDedupesUrls(t *testing.T) {
+	cfg := &config.Config{
+		DocumentAllowedHosts: []string{"*.s3.us-east-1.amazonaws.com", "cdn.example.com"},
+	}
+	service := NewBedrockDocumentSummaryService(&mockOpenSearchClient{}, &mockKnowledgeBaseClient{}, nil, cfg)
+
+	result, err := service.AnalyzeDocuments(context.Background(), []string{
+		"https://docs.s3.us-east-1.amazonaws.com/content/2025/05/ประกาศ ค่าธรรมเนียม-2.pdf",
+		" https://docs.s3.us-east-1.amazonaws.com/content/2025/05/ประกาศ ค่าธรรมเนียม-2.pdf",
+		"https://cdn.example.com/content/2025/01/waive.pdf",
+		"http://cdn.example.com/content/2025/01/waive.pdf",
+		"https://evil.example.org/content/2025/01/waive.pdf",
+		"https://cdn.example.com/",
+		"not a url",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(result.Documents) != 2 {
+		t.Fatalf("expected 2 valid deduplicated documents, got %d: %+v", len(result.Documents), result.Documents)
+	}
+	if len(result.InvalidDocuments) != 4 {
+		t.Fatalf("expected 4 invalid documents, got %d: %+v", len(result.InvalidDocuments), result.InvalidDocuments)
+	}
+	for _, invalid := range result.InvalidDocuments {
+		if invalid.Error == "" {
+			t.Fatalf("expected a rejection reason for %s", invalid.Link)
+		}
+	}
+}
+
+func TestAnalyzeDocuments_AcceptsPresignedLinks(t *testing.T) {
+	cfg := &config.Config{
+		DocumentAllowedHosts: []string{"*.s3.us-east-1.amazonaws.com"},
+	}
+	service := NewBedrockDocumentSummaryService(&mockOpenSearchClient{}, &mockKnowledgeBaseClient{}, nil, cfg)
+
+	result, err := service.AnalyzeDocuments(context.Background(), []string{
+		"https://docs.s3.us-east-1.amazonaws.com/content/2025/05/waive-2.pdf?X-Amz-Expires=3600&X-Amz-Signature=abc",
+		"https://docs.s3.us-east-1.amazonaws.com/content/2025/05/waive-2.pdf",
+		"https://docs.s3.us-east-1.amazonaws.com/content/2025/05/waive-2.pdf?version=2",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// The signed and unsigned links are the same document, other queries are still refused
+	if len(result.Documents) != 1 || result.Documents[0].Link != "https://docs.s3.us-east-1.amazonaws.com/content/2025/05/waive-2.pdf" {
+		t.Fatalf("expected the document once under its bucket URL, got %+v", result.Documents)
+	}
+	if len(result.InvalidDocuments) != 1 {
+		t.Errorf("expected the link with another query to be rejected, got %+v", result.InvalidDocuments)
+	}
+}
+
+func TestAnalyzeDocuments_RejectsOtherBuckets(t *testing.T) {
+	cfg := &config.Config{
+		DocumentAllowedHosts: []string{"docs.s3.us-east-1.amazonaws.com", "cdn.example.com"},
+	}
+	service := NewBedrockDocumentSummaryService(&mockOpenSearchClient{}, &mockKnowledgeBaseClient{}, nil, cfg)
+
+	result, err := service.AnalyzeDocuments(context.Background(), []string{
+		"https://docs.s3.us-east-1.amazonaws.com/content/2025/05/waive-2.pdf",
+		"https://cdn.example.com/content/2025/05/waive-2.pdf",
+		"https://attacker.s3.us-east-1.amazonaws.com/content/2025/05/waive-2.pdf",
+		"https://docs.s3.us-east-1.amazonaws.com.attacker.example/waive-2.pdf",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(result.Documents) != 2 || len(result.InvalidDocuments) != 2 {
+		t.Fatalf("expected the foreign buckets to be rejected, got %+v and %+v", result.Documents, result.InvalidDocuments)
+	}
+	if result.InvalidDocuments[0].Link != "https://attacker.s3.us-east-1.amazonaws.com/content/2025/05/waive-2.pdf" {
+		t.Errorf("expected the other bucket to be rejected, got %+v", result.InvalidDocuments)
+	}
+
+	// Without allowed hosts no document is summarized
+	service = NewBedrockDocumentSummaryService(&mockOpenSearchClient{}, &mockKnowledgeBaseClient{}, nil, &config.Config{})
+	result, _ = service.AnalyzeDocuments(context.Background(), []string{"https://docs.s3.us-east-1.amazonaws.com/content/2025/05/waive-2.pdf"})
+	if len(result.Documents) != 0 || len(result.InvalidDocuments) != 1 {
+		t.Errorf("expected every document to be rejected without allowed hosts, got %+v", result.Documents)
+	}
+}
+
+func TestAnalyzeDocuments_RejectsTooManyUrls(t *testing.T) {
+	service := NewBedrockDocumentSummaryService(&mockOpenSearchClient{}, &mockKnowledgeBaseClient{}, nil, &config.Config{MaxSummaryDocuments: 2})
+
+	_, err := service.AnalyzeDocuments(context.Background(), []string{
+		"https://a.example.com/1.pdf",
+		"https://a.example.com/2.pdf",
+		"https://a.example.com/3.pdf",
+	})
+
+	bedrockErr, ok := err.(*bedrockErrors.BedrockError)
+	if !ok || bedrockErr.Code != bedrockErrors.ErrCodeValidation {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+func TestAnalyzeDocuments_ContentSummariesReturnPartialResults(t *testing.T) {
+	flags.Initialize(flags.New(time.Hour, flags.NewEnvSource("document-summary-content")))
+	defer flags.Initialize(nil)
+
+	client := &mockOpenSearchClient{
+		documents: []map[string]interface{}{
+			{"link": "https://b.example.com/content/2025/05/waive-2.pdf", "content": "waive v2"},
+			{"link": "https://b.example.com/content/2025/03/horaland.pdf", "content": "horaland"},
+		},
+		summarizeErr: map[string]error{"horaland": fmt.Errorf("model error")},
+	}
+	cfg := &config.Config{SummaryWorkers: 2, DocumentAllowedHosts: []string{"b.example.com"}}
+	service := NewBedrockDocumentSummaryService(client, &mockKnowledgeBaseClient{}, nil, cfg)
+
+	result, err := service.AnalyzeDocuments(context.Background(), []string{
+		"https://b.example.com/content/2025/05/waive-2.pdf",
+		"https://b.example.com/content/2025/03/horaland.pdf",
+		"https://b.example.com/content/2025/01/missing.pdf",
+	})
+	if err != nil {
+		t.Fatalf("expected partial results instead of an error, got %v", err)
+	}
+
+	byLink := make(map[string]DocumentSummaryItem)
+	for _, item := range result.Documents {
+		byLink[item.Link] = item
+	}
+	if item := byLink["https://b.example.com/content/2025/05/waive-2.pdf"]; item.Summary != "summary of waive v2" || item.Error != "" {
+		t.Fatalf("expected content summary for waive-2, got %+v", item)
+	}
+	if item := byLink["https://b.example.com/content/2025/03/horaland.pdf"]; item.Error == "" || item.Summary == "" {
+		t.Fatalf("expected metadata summary with an error for horaland, got %+v", item)
+	}
+	if item := byLink["https://b.example.com/content/2025/01/missing.pdf"]; item.Error != "document content not found" {
+		t.Fatalf("expected missing content error, got %+v", item)
+	}
+}
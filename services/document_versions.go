@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"teletubpax-api/logger"
+)
+
+// DocumentVersion is one version of a topic, e.g. content/2025/05/fees-2.pdf of "fees"
+type DocumentVersion struct {
+	Link           string `json:"link"`
+	Version        int    `json:"version"`        // From the -N suffix of the file name, 0 without one
+	YearMonth      string `json:"yearMonth"`      // From the key, "0000/00" when it has no YYYY/MM/ folders
+	LastModifyDate string `json:"lastModifyDate"` // As in last-update-document
+	ChangeSummary  string `json:"changeSummary"`  // Changes from the next older version, "" for the oldest
+}
+
+// GetDocumentVersions returns every known version of a topic, highest version first, each
+// with its changes from the next older one. Change summaries come from the re-summarization
+// job, the comparison cache or Bedrock, like those of GetLastUpdateDocuments. It returns
+// none when the knowledge base has no document of the topic.
+func (s *OpenSearchDocumentService) GetDocumentVersions(ctx context.Context, topic string) ([]DocumentVersion, error) {
+	log := logger.WithContext(ctx)
+	startTime := time.Now()
+
+	documents, err := s.openSearchClient.ListTopicVersions(ctx, topic)
+	if err != nil {
+		log.Error("Failed to fetch document versions", map[string]interface{}{
+			"topic":       topic,
+			"error":       err.Error(),
+			"duration_ms": time.Since(startTime).Milliseconds(),
+		})
+		return nil, err
+	}
+
+	sort.SliceStable(documents, func(i, j int) bool {
+		versionI, _ := documents[i]["version"].(int)
+		versionJ, _ := documents[j]["version"].(int)
+		if versionI != versionJ {
+			return versionI > versionJ
+		}
+		yearMonthI, _ := documents[i]["yearMonth"].(string)
+		yearMonthJ, _ := documents[j]["yearMonth"].(string)
+		return yearMonthI > yearMonthJ
+	})
+
+	versions := make([]DocumentVersion, len(documents))
+	var comparisons []versionComparison
+	for i, doc := range documents {
+		versions[i].Link, _ = doc["link"].(string)
+		versions[i].Version, _ = doc["version"].(int)
+		versions[i].YearMonth, _ = doc["yearMonth"].(string)
+		versions[i].LastModifyDate, _ = doc["lastModifyDate"].(string)
+
+		if changeSummary := s.precomputedChangeSummary(ctx, doc); changeSummary != "" {
+			versions[i].ChangeSummary = changeSummary
+			continue
+		}
+		if i+1 == len(documents) {
+			continue
+		}
+		olderDoc := documents[i+1]
+		if olderVersion, _ := olderDoc["version"].(int); olderVersion >= versions[i].Version {
+			continue
+		}
+
+		newerContent, _ := doc["content"].(string)
+		olderContent, _ := olderDoc["content"].(string)
+		if newerContent == "" || olderContent == "" {
+			continue
+		}
+		olderLink, _ := olderDoc["link"].(string)
+		comparisons = append(comparisons, versionComparison{
+			index:        i,
+			topic:        topic,
+			newerLink:    versions[i].Link,
+			olderLink:    olderLink,
+			newerContent: newerContent,
+			olderContent: olderContent,
+		})
+	}
+
+	for i, changeSummary := range s.compareVersions(ctx, comparisons) {
+		versions[comparisons[i].index].ChangeSummary = changeSummary
+	}
+
+	log.Info("Document versions retrieved", map[string]interface{}{
+		"topic":         topic,
+		"version_count": len(versions),
+		"compared":      len(comparisons),
+		"duration_ms":   time.Since(startTime).Milliseconds(),
+	})
+	return versions, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+func feesVersions() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"link": "https://bucket.s3.us-east-1.amazonaws.com/content/2025/04/fees-1.pdf", "version": 1, "yearMonth": "2025/04", "lastModifyDate": "2025/04", "content": "v1"},
+		{"link": "https://bucket.s3.us-east-1.amazonaws.com/content/2025/01/fees.pdf", "version": 0, "yearMonth": "2025/01", "lastModifyDate": "2025/01", "content": "v0"},
+		{"link": "https://bucket.s3.us-east-1.amazonaws.com/content/2025/05/fees-2.pdf", "version": 2, "yearMonth": "2025/05", "lastModifyDate": "2025-05-30T07:45:51Z", "content": "v2"},
+	}
+}
+
+func TestGetDocumentVersions_ComparesConsecutiveVersions(t *testing.T) {
+	client := &mockOpenSearchClient{versions: feesVersions()}
+	service := NewOpenSearchDocumentService(client, nil, nil, nil, &config.Config{})
+
+	versions, err := service.GetDocumentVersions(context.Background(), "fees")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(versions) != 3 || versions[0].Version != 2 || versions[1].Version != 1 || versions[2].Version != 0 {
+		t.Fatalf("expected versions 2, 1, 0, got %+v", versions)
+	}
+	if versions[0].YearMonth != "2025/05" || versions[0].Link != "https://bucket.s3.us-east-1.amazonaws.com/content/2025/05/fees-2.pdf" {
+		t.Errorf("unexpected newest version %+v", versions[0])
+	}
+	if versions[0].ChangeSummary != "changed from v1 to v2" || versions[1].ChangeSummary != "changed from v0 to v1" {
+		t.Errorf("expected each version compared with the next older one, got %+v", versions)
+	}
+	if versions[2].ChangeSummary != "" {
+		t.Errorf("expected no change summary for the oldest version, got %q", versions[2].ChangeSummary)
+	}
+}
+
+func TestGetDocumentVersions_PrefersPrecomputedSummaries(t *testing.T) {
+	client := &mockOpenSearchClient{versions: feesVersions()}
+	summaries := &memorySummaryStore{records: map[string]*storage.DocumentSummaryRecord{
+		"https://bucket.s3.us-east-1.amazonaws.com/content/2025/05/fees-2.pdf": {ChangeSummary: "precomputed"},
+	}}
+	service := NewOpenSearchDocumentService(client, summaries, nil, nil, &config.Config{})
+
+	versions, err := service.GetDocumentVersions(context.Background(), "fees")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if versions[0].ChangeSummary != "precomputed" {
+		t.Errorf("expected the precomputed summary, got %q", versions[0].ChangeSummary)
+	}
+	if client.compareCalls != 1 {
+		t.Errorf("expected only version 1 compared, got %d comparisons", client.compareCalls)
+	}
+}
+
+func TestGetDocumentVersions_UnknownTopic(t *testing.T) {
+	service := NewOpenSearchDocumentService(&mockOpenSearchClient{}, nil, nil, nil, &config.Config{})
+
+	versions, err := service.GetDocumentVersions(context.Background(), "missing")
+	if err != nil || len(versions) != 0 {
+		t.Errorf("expected no versions, got %+v %v", versions, err)
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+	"teletubpax-api/utils"
+)
+
+// CachingEmbeddingClient embeds each text once per EMBEDDING_CACHE_TTL_SECONDS instead of
+// on every call, as the same questions and document titles are embedded again and again.
+// Texts are normalized before they are embedded and keyed, so spacing and look-alike Thai
+// characters do not make a new embedding. The key includes the embedding model and its
+// dimensions, so vectors of a replaced model are not served. A TTL of 0 disables caching. Cache failures are
+// logged and the text is embedded as if there were no cache.
+type CachingEmbeddingClient struct {
+	next    aws.EmbeddingClient
+	cache   storage.EmbeddingCache
+	modelId func() string
+	config  *config.Config
+}
+
+func NewCachingEmbeddingClient(next aws.EmbeddingClient, cache storage.EmbeddingCache, modelId func() string, cfg *config.Config) *CachingEmbeddingClient {
+	return &CachingEmbeddingClient{
+		next:    next,
+		cache:   cache,
+		modelId: modelId,
+		config:  cfg,
+	}
+}
+
+func (c *CachingEmbeddingClient) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	text = utils.NormalizeThaiText(text)
+	ttl := time.Duration(c.config.EmbeddingCacheTTLSeconds) * time.Second
+	if ttl <= 0 {
+		return c.next.GenerateEmbedding(ctx, text)
+	}
+
+	log := logger.WithContext(ctx)
+	key := embeddingCacheKey(c.model(), text)
+	cached, err := c.cache.Get(ctx, key)
+	if err != nil {
+		log.Warn("Failed to read embedding cache", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if len(cached) > 0 {
+		return cached, nil
+	}
+
+	embedding, err := c.next.GenerateEmbedding(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cache.Set(ctx, key, embedding, ttl); err != nil {
+		log.Warn("Failed to cache embedding", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return embedding, nil
+}
+
+// GenerateEmbeddings embeds only the texts without a cached embedding, in one batch
+func (c *CachingEmbeddingClient) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	normalized := make([]string, len(texts))
+	for i, text := range texts {
+		normalized[i] = utils.NormalizeThaiText(text)
+	}
+	ttl := time.Duration(c.config.EmbeddingCacheTTLSeconds) * time.Second
+	if ttl <= 0 {
+		return c.next.GenerateEmbeddings(ctx, normalized)
+	}
+
+	log := logger.WithContext(ctx)
+	model := c.model()
+	embeddings := make([][]float64, len(texts))
+	var missing []int // Indexes of the texts to embed
+	for i, text := range normalized {
+		cached, err := c.cache.Get(ctx, embeddingCacheKey(model, text))
+		if err != nil {
+			log.Warn("Failed to read embedding cache", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		if len(cached) > 0 {
+			embeddings[i] = cached
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+
+	missingTexts := make([]string, len(missing))
+	for j, i := range missing {
+		missingTexts[j] = normalized[i]
+	}
+	generated, err := c.next.GenerateEmbeddings(ctx, missingTexts)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range missing {
+		embeddings[i] = generated[j]
+		if err := c.cache.Set(ctx, embeddingCacheKey(model, normalized[i]), generated[j], ttl); err != nil {
+			log.Warn("Failed to cache embedding", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+	return embeddings, nil
+}
+
+// model identifies the embedding model with the options that change its vectors
+func (c *CachingEmbeddingClient) model() string {
+	return fmt.Sprintf("%s dimensions=%d normalize=%t", c.modelId(), c.config.EmbeddingDimensions, c.config.EmbeddingNormalize)
+}
+
+// embeddingCacheKey identifies the embedding of a normalized text by a model
+func embeddingCacheKey(model string, text string) string {
+	sum := sha256.Sum256([]byte(model + "\n" + text))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type countingEmbeddingClient struct {
+	texts []string
+}
+
+func (c *countingEmbeddingClient) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	c.texts = append(c.texts, text)
+	return []float64{0.25, -0.5, float64(len(c.texts))}, nil
+}
+
+func (c *countingEmbeddingClient) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, len(texts))
+	for i, text := range texts {
+		embeddings[i], _ = c.GenerateEmbedding(ctx, text)
+	}
+	return embeddings, nil
+}
+
+func TestCachingEmbeddingClient_EmbedsNormalizedTextOnce(t *testing.T) {
+	next := &countingEmbeddingClient{}
+	modelId := "amazon.titan-embed-text-v2:0"
+	client := NewCachingEmbeddingClient(next, storage.NewMemoryEmbeddingCache(10), func() string { return modelId }, &config.Config{EmbeddingCacheTTLSeconds: 60})
+
+	first, err := client.GenerateEmbedding(context.Background(), "ค่าธรรมเนียม  บัตรเดบิต")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := client.GenerateEmbedding(context.Background(), " ค่าธรรมเนียม บัตรเดบิต​")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(next.texts) != 1 || next.texts[0] != "ค่าธรรมเนียม บัตรเดบิต" {
+		t.Errorf("expected one embedding of the normalized text, got %q", next.texts)
+	}
+	if len(second) != 3 || second[0] != first[0] || second[1] != first[1] || second[2] != first[2] {
+		t.Errorf("expected the cached embedding %v, got %v", first, second)
+	}
+
+	// Another model embeds the text again
+	modelId = "cohere.embed-multilingual-v3"
+	if _, err := client.GenerateEmbedding(context.Background(), "ค่าธรรมเนียม บัตรเดบิต"); err != nil || len(next.texts) != 2 {
+		t.Errorf("expected a new embedding for another model, got %d calls %v", len(next.texts), err)
+	}
+}
+
+func TestCachingEmbeddingClient_BatchEmbedsMissingTexts(t *testing.T) {
+	next := &countingEmbeddingClient{}
+	client := NewCachingEmbeddingClient(next, storage.NewMemoryEmbeddingCache(10), func() string { return "model" }, &config.Config{EmbeddingCacheTTLSeconds: 60})
+
+	cached, _ := client.GenerateEmbedding(context.Background(), "วิธีสมัครบัตรเครดิต")
+	embeddings, err := client.GenerateEmbeddings(context.Background(), []string{"ค่าธรรมเนียมรายปี", "วิธีสมัครบัตรเครดิต ", "อัตราดอกเบี้ย"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(next.texts) != 3 || next.texts[1] != "ค่าธรรมเนียมรายปี" || next.texts[2] != "อัตราดอกเบี้ย" {
+		t.Errorf("expected only the texts without a cached embedding embedded, got %q", next.texts)
+	}
+	if len(embeddings) != 3 || embeddings[1][2] != cached[2] || embeddings[0][2] != 2 || embeddings[2][2] != 3 {
+		t.Errorf("expected the embeddings in the order of the texts, got %v", embeddings)
+	}
+}
+
+func TestCachingEmbeddingClient_Disabled(t *testing.T) {
+	next := &countingEmbeddingClient{}
+	client := NewCachingEmbeddingClient(next, storage.NewMemoryEmbeddingCache(10), func() string { return "model" }, &config.Config{})
+
+	client.GenerateEmbedding(context.Background(), "question")
+	client.GenerateEmbedding(context.Background(), "question")
+	if len(next.texts) != 2 {
+		t.Errorf("expected every call embedded without a TTL, got %d", len(next.texts))
+	}
+}
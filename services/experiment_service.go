@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/experiments"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+// MaxExperimentReportDays bounds the days an experiment report covers
+const MaxExperimentReportDays = 92
+
+// VariantMetrics are the quality metrics of one experiment variant over a report's days
+type VariantMetrics struct {
+	Variant        string   `json:"variant"`
+	Percent        int      `json:"percent"` // Current share of sessions, 0 for a variant no longer configured
+	Answers        int64    `json:"answers"`
+	NoAnswers      int64    `json:"noAnswers"` // Answers that found nothing, counted in answers
+	Errors         int64    `json:"errors"`
+	FeedbackUp     int64    `json:"feedbackUp"`
+	FeedbackDown   int64    `json:"feedbackDown"`
+	NoAnswerRate   *float64 `json:"noAnswerRate,omitempty"`   // Share of answers that found nothing, unset without answers
+	ErrorRate      *float64 `json:"errorRate,omitempty"`      // Share of questions that failed, unset without questions
+	Satisfaction   *float64 `json:"satisfaction,omitempty"`   // Share of up ratings, unset without feedback
+	MeanDurationMs *float64 `json:"meanDurationMs,omitempty"` // Unset without answers
+}
+
+// ExperimentMetrics are the metrics of the variants of one experiment
+type ExperimentMetrics struct {
+	Experiment string           `json:"experiment"`
+	Running    bool             `json:"running"`  // The experiment is the configured one
+	Variants   []VariantMetrics `json:"variants"` // In configured order, then by name
+}
+
+type ExperimentReport struct {
+	From        string              `json:"from"` // First day, YYYY-MM-DD in UTC
+	To          string              `json:"to"`   // Last day, inclusive
+	Experiments []ExperimentMetrics `json:"experiments"`
+}
+
+type ExperimentService interface {
+	// Assign attaches the variant of the request's session to its context. The context is
+	// returned unchanged when no experiment is running.
+	Assign(ctx context.Context) context.Context
+	// RecordAnswer counts the answer, or the error, of a request assigned to a variant
+	RecordAnswer(ctx context.Context, answer string, err error, duration time.Duration)
+	// RecordFeedback counts a rating of an answer given by a variant
+	RecordFeedback(ctx context.Context, experiment string, variant string, rating string)
+	// Report returns the metrics of every variant answering in the days from from to to,
+	// inclusive
+	Report(ctx context.Context, from time.Time, to time.Time) (*ExperimentReport, error)
+}
+
+// StoreExperimentService assigns sessions to the variants of the EXPERIMENT and counts the
+// answers and feedback of each variant by day in an ExperimentStore
+type StoreExperimentService struct {
+	experiment    *experiments.Experiment // Optional, requests are not assigned when nil
+	store         storage.ExperimentStore
+	retentionDays int
+}
+
+func NewStoreExperimentService(experiment *experiments.Experiment, store storage.ExperimentStore, cfg *config.Config) *StoreExperimentService {
+	return &StoreExperimentService{
+		experiment:    experiment,
+		store:         store,
+		retentionDays: cfg.ExperimentRetentionDays,
+	}
+}
+
+func (s *StoreExperimentService) Assign(ctx context.Context) context.Context {
+	variant := s.experiment.Assign(SessionIdFromContext(ctx))
+	if variant == nil {
+		return ctx
+	}
+	return experiments.WithAssignment(ctx, &experiments.Assignment{Experiment: s.experiment.Name, Variant: variant})
+}
+
+// RecordAnswer logs the outcome with the variant, so CloudWatch Insights can break answers
+// down by variant, and adds it to the store. Store failures are logged, experiments must not
+// fail requests.
+func (s *StoreExperimentService) RecordAnswer(ctx context.Context, answer string, err error, duration time.Duration) {
+	assignment := experiments.FromContext(ctx)
+	if assignment == nil {
+		return
+	}
+
+	record := s.record(assignment.Experiment, assignment.Variant.Name)
+	outcome := "answered"
+	switch {
+	case err != nil:
+		outcome = "error"
+		record.Errors = 1
+	case aws.IsNoAnswer(answer):
+		outcome = "no_answer"
+		record.Answers = 1
+		record.NoAnswers = 1
+		record.DurationMs = duration.Milliseconds()
+	default:
+		record.Answers = 1
+		record.DurationMs = duration.Milliseconds()
+	}
+
+	log := logger.WithContext(ctx)
+	log.Info("Experiment answer", map[string]interface{}{
+		"experiment":  assignment.Experiment,
+		"variant":     assignment.Variant.Name,
+		"outcome":     outcome,
+		"duration_ms": duration.Milliseconds(),
+	})
+	if err := s.store.AddExperimentCounts(ctx, record); err != nil {
+		log.Warn("Failed to record experiment answer", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+func (s *StoreExperimentService) RecordFeedback(ctx context.Context, experiment string, variant string, rating string) {
+	record := s.record(experiment, variant)
+	switch rating {
+	case FeedbackUp:
+		record.FeedbackUp = 1
+	case FeedbackDown:
+		record.FeedbackDown = 1
+	default:
+		return
+	}
+
+	if err := s.store.AddExperimentCounts(ctx, record); err != nil {
+		logger.WithContext(ctx).Warn("Failed to record experiment feedback", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// record returns an empty aggregate of today for a variant
+func (s *StoreExperimentService) record(experiment string, variant string) *storage.ExperimentRecord {
+	now := time.Now().UTC()
+	date := now.Format(UsageDateLayout)
+	return &storage.ExperimentRecord{
+		Id:         storage.ExperimentId(date, experiment, variant),
+		Date:       date,
+		Experiment: experiment,
+		Variant:    variant,
+		ExpiresAt:  now.AddDate(0, 0, s.retentionDays).Unix(),
+	}
+}
+
+func (s *StoreExperimentService) Report(ctx context.Context, from time.Time, to time.Time) (*ExperimentReport, error) {
+	report := &ExperimentReport{
+		From:        from.UTC().Format(UsageDateLayout),
+		To:          to.UTC().Format(UsageDateLayout),
+		Experiments: []ExperimentMetrics{},
+	}
+	records, err := s.store.ListExperimentCounts(ctx, report.From, report.To)
+	if err != nil {
+		return nil, err
+	}
+
+	// The configured variants are listed even before they answered
+	type variantKey struct{ experiment, variant string }
+	metrics := map[variantKey]*VariantMetrics{}
+	durations := map[variantKey]int64{}
+	var keys []variantKey
+	order := map[variantKey]int{}
+	if s.experiment != nil {
+		for i, variant := range s.experiment.Variants {
+			key := variantKey{s.experiment.Name, variant.Name}
+			metrics[key] = &VariantMetrics{Variant: variant.Name, Percent: variant.Percent}
+			keys = append(keys, key)
+			order[key] = i
+		}
+	}
+	for _, record := range records {
+		key := variantKey{record.Experiment, record.Variant}
+		variant, ok := metrics[key]
+		if !ok {
+			variant = &VariantMetrics{Variant: record.Variant}
+			metrics[key] = variant
+			keys = append(keys, key)
+			order[key] = len(order)
+		}
+		variant.Answers += record.Answers
+		variant.NoAnswers += record.NoAnswers
+		variant.Errors += record.Errors
+		variant.FeedbackUp += record.FeedbackUp
+		variant.FeedbackDown += record.FeedbackDown
+		durations[key] += record.DurationMs
+	}
+
+	// The running experiment first, then the others by name
+	running := ""
+	if s.experiment != nil {
+		running = s.experiment.Name
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if (keys[i].experiment == running) != (keys[j].experiment == running) {
+			return keys[i].experiment == running
+		}
+		if keys[i].experiment != keys[j].experiment {
+			return keys[i].experiment < keys[j].experiment
+		}
+		if keys[i].experiment == running {
+			return order[keys[i]] < order[keys[j]]
+		}
+		return keys[i].variant < keys[j].variant
+	})
+
+	for _, key := range keys {
+		variant := metrics[key]
+		variant.NoAnswerRate = ratio(variant.NoAnswers, variant.Answers)
+		variant.ErrorRate = ratio(variant.Errors, variant.Answers+variant.Errors)
+		variant.Satisfaction = ratio(variant.FeedbackUp, variant.FeedbackUp+variant.FeedbackDown)
+		variant.MeanDurationMs = ratio(durations[key], variant.Answers)
+
+		last := len(report.Experiments) - 1
+		if last < 0 || report.Experiments[last].Experiment != key.experiment {
+			report.Experiments = append(report.Experiments, ExperimentMetrics{Experiment: key.experiment, Running: key.experiment == running})
+			last++
+		}
+		report.Experiments[last].Variants = append(report.Experiments[last].Variants, *variant)
+	}
+	return report, nil
+}
+
+// ratio divides a total by a count, nil without a count
+func ratio(total int64, count int64) *float64 {
+	if count == 0 {
+		return nil
+	}
+	value := float64(total) / float64(count)
+	return &value
+}
+
+// ExperimentQuestionSearchService answers each question with the experiment variant of its
+// session and counts the outcome for the variant. It wraps the answer cache, which keeps
+// answers per variant, and the feedback service, which tags recorded answers with the
+// variant. Clarification prompts and session limits are not counted, the variant played no
+// part in them.
+type ExperimentQuestionSearchService struct {
+	next        QuestionSearchService
+	experiments ExperimentService
+}
+
+func NewExperimentQuestionSearchService(next QuestionSearchService, experiments ExperimentService) *ExperimentQuestionSearchService {
+	return &ExperimentQuestionSearchService{
+		next:        next,
+		experiments: experiments,
+	}
+}
+
+func (s *ExperimentQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	ctx = s.experiments.Assign(ctx)
+	startTime := time.Now()
+	answer, relatedDocuments, err := s.next.SearchAnswer(ctx, question, enableRelateDocument)
+	switch err.(type) {
+	case *ClarificationRequiredError, *SessionLimitError:
+	default:
+		s.experiments.RecordAnswer(ctx, answer, err, time.Since(startTime))
+	}
+	return answer, relatedDocuments, err
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/experiments"
+	"teletubpax-api/storage"
+)
+
+// variantQuestionSearchService answers from a table and remembers the variant of each question
+type variantQuestionSearchService struct {
+	variants map[string]string
+}
+
+func (s *variantQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	s.variants[question] = experiments.FromContext(ctx).Key()
+	switch question {
+	case "broken":
+		return "", nil, fmt.Errorf("knowledge base unavailable")
+	case "broad":
+		return "", nil, &ClarificationRequiredError{Reason: "broad"}
+	case "unknown":
+		return aws.NoAnswerText, []string{}, nil
+	}
+	return "answer to " + question, []string{}, nil
+}
+
+func TestExperiment_AssignsAndReportsVariants(t *testing.T) {
+	experiment, err := experiments.Parse(`{"name": "synthesis-v2", "variants": [{"name": "control", "percent": 0}, {"name": "new-synthesis", "percent": 100}]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store := storage.NewMemoryExperimentStore()
+	today := time.Now().UTC()
+	// Counts of an earlier experiment are reported after the running one
+	store.AddExperimentCounts(context.Background(), &storage.ExperimentRecord{
+		Id:         storage.ExperimentId(today.Format(UsageDateLayout), "model-v1", "sonnet"),
+		Date:       today.Format(UsageDateLayout),
+		Experiment: "model-v1",
+		Variant:    "sonnet",
+		Answers:    4,
+		DurationMs: 2000,
+	})
+
+	cfg := &config.Config{ExperimentRetentionDays: 90, FeedbackRetentionDays: 30}
+	experimentService := NewStoreExperimentService(experiment, store, cfg)
+	feedbackStore := newMemoryFeedbackStore()
+	feedback := NewStoreFeedbackService(feedbackStore, experimentService, cfg)
+	next := &variantQuestionSearchService{variants: map[string]string{}}
+	service := NewExperimentQuestionSearchService(NewFeedbackQuestionSearchService(next, feedback), experimentService)
+
+	ctx, record := WithAnswerRecord(WithSessionId(context.Background(), "session-1"))
+	if _, _, err := service.SearchAnswer(ctx, "fee", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if next.variants["fee"] != "synthesis-v2/new-synthesis" {
+		t.Errorf("expected the question to be answered by the new-synthesis variant, got %q", next.variants["fee"])
+	}
+	if recorded := feedbackStore.answers[record.Id()]; recorded.Experiment != "synthesis-v2" || recorded.Variant != "new-synthesis" {
+		t.Errorf("expected the recorded answer to be tagged with its variant, got %+v", recorded)
+	}
+	if err := feedback.Submit(context.Background(), record.Id(), "fee", FeedbackUp, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, question := range []string{"unknown", "broken", "broad"} {
+		service.SearchAnswer(WithSessionId(context.Background(), "session-2"), question, false)
+	}
+
+	report, err := experimentService.Report(context.Background(), today, today)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Experiments) != 2 || report.Experiments[0].Experiment != "synthesis-v2" || !report.Experiments[0].Running || report.Experiments[1].Running {
+		t.Fatalf("expected the running experiment first, got %+v", report.Experiments)
+	}
+	variants := report.Experiments[0].Variants
+	if len(variants) != 2 || variants[0].Variant != "control" || variants[0].Answers != 0 || variants[0].Satisfaction != nil {
+		t.Fatalf("expected the control variant listed without answers, got %+v", variants)
+	}
+	candidate := variants[1]
+	// The clarification prompt is not counted
+	if candidate.Percent != 100 || candidate.Answers != 2 || candidate.NoAnswers != 1 || candidate.Errors != 1 || candidate.FeedbackUp != 1 {
+		t.Errorf("unexpected variant metrics %+v", candidate)
+	}
+	if *candidate.NoAnswerRate != 0.5 || *candidate.Satisfaction != 1 || *candidate.ErrorRate != 1.0/3 {
+		t.Errorf("unexpected variant rates %+v", candidate)
+	}
+	if earlier := report.Experiments[1].Variants[0]; earlier.Percent != 0 || *earlier.MeanDurationMs != 500 {
+		t.Errorf("expected the earlier variant with its mean duration, got %+v", earlier)
+	}
+}
+
+func TestExperiment_LeavesRequestsWithoutExperiment(t *testing.T) {
+	next := &variantQuestionSearchService{variants: map[string]string{}}
+	experimentService := NewStoreExperimentService(nil, storage.NewMemoryExperimentStore(), &config.Config{ExperimentRetentionDays: 90})
+	service := NewExperimentQuestionSearchService(next, experimentService)
+
+	if _, _, err := service.SearchAnswer(WithSessionId(context.Background(), "session-1"), "fee", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if next.variants["fee"] != "" {
+		t.Errorf("expected no variant without an experiment, got %q", next.variants["fee"])
+	}
+	report, err := experimentService.Report(context.Background(), time.Now(), time.Now())
+	if err != nil || len(report.Experiments) != 0 {
+		t.Errorf("expected an empty report, got %+v %v", report, err)
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/experiments"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+const (
+	FeedbackUp   = "up"
+	FeedbackDown = "down"
+)
+
+// ErrAnswerNotFound is returned for feedback on an answer that was not given, was given to
+// another question or has expired
+var ErrAnswerNotFound = stdErrors.New("answer not found")
+
+type FeedbackService interface {
+	// RecordAnswer saves an answer so feedback can be sent on it, and returns its answer ID
+	RecordAnswer(ctx context.Context, question string, answer string, relatedDocuments []string) (string, error)
+	Submit(ctx context.Context, answerId string, question string, rating string, comment string) error
+}
+
+// StoreFeedbackService keeps answers and their feedback in a FeedbackStore for
+// FEEDBACK_RETENTION_DAYS, counted from the answer and again from its latest feedback.
+// Answers are tagged with their experiment variant, whose ratings are counted for the
+// experiment report.
+type StoreFeedbackService struct {
+	store       storage.FeedbackStore
+	experiments ExperimentService // Optional, ratings are not counted per variant when nil
+	config      *config.Config
+}
+
+func NewStoreFeedbackService(store storage.FeedbackStore, experiments ExperimentService, cfg *config.Config) *StoreFeedbackService {
+	return &StoreFeedbackService{
+		store:       store,
+		experiments: experiments,
+		config:      cfg,
+	}
+}
+
+func (s *StoreFeedbackService) RecordAnswer(ctx context.Context, question string, answer string, relatedDocuments []string) (string, error) {
+	if relatedDocuments == nil {
+		relatedDocuments = []string{}
+	}
+	answeredAt := time.Now().UTC().Truncate(time.Second)
+	record := &storage.AnswerFeedback{
+		Id:               answeredAt.Format("20060102T150405Z") + "-" + randomSuffix() + randomSuffix(),
+		TenantId:         TenantIdFromContext(ctx),
+		Question:         question,
+		Answer:           answer,
+		RelatedDocuments: relatedDocuments,
+		AnsweredAt:       answeredAt,
+		ExpiresAt:        answeredAt.AddDate(0, 0, s.config.FeedbackRetentionDays).Unix(),
+	}
+	if assignment := experiments.FromContext(ctx); assignment != nil {
+		record.Experiment = assignment.Experiment
+		record.Variant = assignment.Variant.Name
+	}
+	if err := s.store.PutAnswer(ctx, record); err != nil {
+		return "", err
+	}
+	return record.Id, nil
+}
+
+// Submit saves a rating and comment on an answer, replacing earlier feedback on it
+func (s *StoreFeedbackService) Submit(ctx context.Context, answerId string, question string, rating string, comment string) error {
+	feedbackAt := time.Now().UTC().Truncate(time.Second)
+	answer, err := s.store.SetFeedback(ctx, &storage.AnswerFeedback{
+		Id:         answerId,
+		Question:   question,
+		Rating:     rating,
+		Comment:    strings.TrimSpace(comment),
+		FeedbackAt: &feedbackAt,
+		ExpiresAt:  feedbackAt.AddDate(0, 0, s.config.FeedbackRetentionDays).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	if answer == nil {
+		return ErrAnswerNotFound
+	}
+	if answer.Variant != "" && s.experiments != nil {
+		s.experiments.RecordFeedback(ctx, answer.Experiment, answer.Variant, rating)
+	}
+
+	logger.WithContext(ctx).Info("Answer feedback saved", map[string]interface{}{
+		"answer_id":  answerId,
+		"rating":     rating,
+		"experiment": answer.Experiment,
+		"variant":    answer.Variant,
+	})
+	return nil
+}
+
+// AnswerRecord holds the answer ID under which the answer of a request was recorded
+type AnswerRecord struct {
+	mu sync.Mutex
+	id string
+}
+
+// Id returns the answer ID, empty when the answer was not recorded
+func (r *AnswerRecord) Id() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.id
+}
+
+func (r *AnswerRecord) set(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.id = id
+}
+
+type answerRecordKey struct{}
+
+// WithAnswerRecord attaches an answer record to the context of a request, for the handler
+// to return the answer ID feedback is sent with
+func WithAnswerRecord(ctx context.Context) (context.Context, *AnswerRecord) {
+	record := &AnswerRecord{}
+	return context.WithValue(ctx, answerRecordKey{}, record), record
+}
+
+// FeedbackQuestionSearchService records every answer, cached ones included, so callers can
+// send feedback on it with the answer ID. Only the related documents the request asked for
+// are known and recorded. A failure to record is logged and the answer is returned without
+// an answer ID.
+type FeedbackQuestionSearchService struct {
+	next     QuestionSearchService
+	feedback FeedbackService
+}
+
+func NewFeedbackQuestionSearchService(next QuestionSearchService, feedback FeedbackService) *FeedbackQuestionSearchService {
+	return &FeedbackQuestionSearchService{
+		next:     next,
+		feedback: feedback,
+	}
+}
+
+func (s *FeedbackQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	answer, relatedDocuments, err := s.next.SearchAnswer(ctx, question, enableRelateDocument)
+	if err != nil {
+		return answer, relatedDocuments, err
+	}
+
+	answerId, recordErr := s.feedback.RecordAnswer(ctx, question, answer, relatedDocuments)
+	if recordErr != nil {
+		logger.WithContext(ctx).Warn("Failed to record answer for feedback", map[string]interface{}{
+			"error": recordErr.Error(),
+		})
+		return answer, relatedDocuments, nil
+	}
+	if record, ok := ctx.Value(answerRecordKey{}).(*AnswerRecord); ok {
+		record.set(answerId)
+	}
+	return answer, relatedDocuments, nil
+}
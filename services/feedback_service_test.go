@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type memoryFeedbackStore struct {
+	mu      sync.Mutex
+	answers map[string]storage.AnswerFeedback
+	err     error
+}
+
+func newMemoryFeedbackStore() *memoryFeedbackStore {
+	return &memoryFeedbackStore{answers: map[string]storage.AnswerFeedback{}}
+}
+
+func (m *memoryFeedbackStore) PutAnswer(ctx context.Context, answer *storage.AnswerFeedback) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.answers[answer.Id] = *answer
+	return nil
+}
+
+func (m *memoryFeedbackStore) SetFeedback(ctx context.Context, feedback *storage.AnswerFeedback) (*storage.AnswerFeedback, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	answer, ok := m.answers[feedback.Id]
+	if !ok || answer.Question != feedback.Question {
+		return nil, nil
+	}
+	answer.Rating = feedback.Rating
+	answer.Comment = feedback.Comment
+	answer.FeedbackAt = feedback.FeedbackAt
+	answer.ExpiresAt = feedback.ExpiresAt
+	m.answers[feedback.Id] = answer
+	return &answer, nil
+}
+
+type documentsQuestionSearchService struct {
+	documents            []string
+	enableRelateDocument bool
+}
+
+func (s *documentsQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	s.enableRelateDocument = enableRelateDocument
+	return "answer to " + question, s.documents, nil
+}
+
+func TestFeedback_RecordsAnswersAndFeedback(t *testing.T) {
+	store := newMemoryFeedbackStore()
+	feedback := NewStoreFeedbackService(store, nil, &config.Config{FeedbackRetentionDays: 30})
+	next := &documentsQuestionSearchService{documents: []string{"https://docs.example.com/fees.pdf"}}
+	service := NewFeedbackQuestionSearchService(next, feedback)
+
+	ctx, record := WithAnswerRecord(WithTenantId(context.Background(), "branch"))
+	answer, documents, err := service.SearchAnswer(ctx, "ค่าธรรมเนียม", true)
+	if err != nil || answer != "answer to ค่าธรรมเนียม" || len(documents) != 1 || !next.enableRelateDocument {
+		t.Fatalf("expected the answer of the next service, got %q %v %v", answer, documents, err)
+	}
+	answerId := record.Id()
+	if answerId == "" {
+		t.Fatal("expected an answer ID")
+	}
+	recorded := store.answers[answerId]
+	if recorded.Question != "ค่าธรรมเนียม" || recorded.Answer != answer || recorded.TenantId != "branch" || len(recorded.RelatedDocuments) != 1 {
+		t.Errorf("unexpected recorded answer %+v", recorded)
+	}
+	if days := time.Unix(recorded.ExpiresAt, 0).Sub(recorded.AnsweredAt).Hours() / 24; days != 30 {
+		t.Errorf("expected the answer to expire after 30 days, got %.1f", days)
+	}
+
+	if err := feedback.Submit(context.Background(), answerId, "ค่าธรรมเนียม", FeedbackDown, "  outdated fee  "); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rated := store.answers[answerId]
+	if rated.Rating != FeedbackDown || rated.Comment != "outdated fee" || rated.FeedbackAt == nil {
+		t.Errorf("unexpected feedback %+v", rated)
+	}
+
+	if err := feedback.Submit(context.Background(), answerId, "another question", FeedbackUp, ""); err != ErrAnswerNotFound {
+		t.Errorf("expected feedback with another question to find no answer, got %v", err)
+	}
+	if err := feedback.Submit(context.Background(), "unknown", "ค่าธรรมเนียม", FeedbackUp, ""); err != ErrAnswerNotFound {
+		t.Errorf("expected an unknown answer ID to find no answer, got %v", err)
+	}
+}
+
+func TestFeedback_RecordsAnswersWithoutDocuments(t *testing.T) {
+	store := newMemoryFeedbackStore()
+	service := NewFeedbackQuestionSearchService(&documentsQuestionSearchService{}, NewStoreFeedbackService(store, nil, &config.Config{FeedbackRetentionDays: 30}))
+
+	ctx, record := WithAnswerRecord(context.Background())
+	if _, _, err := service.SearchAnswer(ctx, "question", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if documents := store.answers[record.Id()].RelatedDocuments; documents == nil || len(documents) != 0 {
+		t.Errorf("expected an empty document list, got %v", documents)
+	}
+}
+
+func TestFeedback_StoreFailureKeepsTheAnswer(t *testing.T) {
+	store := newMemoryFeedbackStore()
+	store.err = fmt.Errorf("table unavailable")
+	service := NewFeedbackQuestionSearchService(&documentsQuestionSearchService{}, NewStoreFeedbackService(store, nil, &config.Config{FeedbackRetentionDays: 30}))
+
+	ctx, record := WithAnswerRecord(context.Background())
+	answer, _, err := service.SearchAnswer(ctx, "question", false)
+	if err != nil || answer != "answer to question" {
+		t.Fatalf("expected the answer despite the store failure, got %q %v", answer, err)
+	}
+	if record.Id() != "" {
+		t.Errorf("expected no answer ID, got %q", record.Id())
+	}
+}
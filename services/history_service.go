@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+type HistoryService interface {
+	RecordTurn(ctx context.Context, userId string, question string, answer string, citations []aws.Citation) error
+	// List returns up to limit turns of the user, newest first, older than the turn with
+	// the ID before when it is set
+	List(ctx context.Context, userId string, before string, limit int) ([]storage.ConversationTurn, error)
+	// Delete removes the whole history of the user, for PDPA erasure requests
+	Delete(ctx context.Context, userId string) (int, error)
+}
+
+// StoreHistoryService keeps the turns of signed-in users in a
+// ConversationHistoryStore for CONVERSATION_HISTORY_RETENTION_DAYS
+type StoreHistoryService struct {
+	store  storage.ConversationHistoryStore
+	config *config.Config
+}
+
+func NewStoreHistoryService(store storage.ConversationHistoryStore, cfg *config.Config) *StoreHistoryService {
+	return &StoreHistoryService{
+		store:  store,
+		config: cfg,
+	}
+}
+
+func (s *StoreHistoryService) RecordTurn(ctx context.Context, userId string, question string, answer string, citations []aws.Citation) error {
+	createdAt := time.Now().UTC()
+	turn := &storage.ConversationTurn{
+		UserId:    userId,
+		TurnId:    createdAt.Format("20060102T150405.000000000Z") + "-" + randomSuffix(),
+		Question:  question,
+		Answer:    answer,
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.AddDate(0, 0, s.config.HistoryRetentionDays).Unix(),
+	}
+	if len(citations) > 0 {
+		turn.Citations, _ = json.Marshal(citations)
+	}
+	return s.store.AddTurn(ctx, turn)
+}
+
+func (s *StoreHistoryService) List(ctx context.Context, userId string, before string, limit int) ([]storage.ConversationTurn, error) {
+	return s.store.ListTurns(ctx, userId, before, limit)
+}
+
+func (s *StoreHistoryService) Delete(ctx context.Context, userId string) (int, error) {
+	deleted, err := s.store.DeleteTurns(ctx, userId)
+	if err != nil {
+		return deleted, err
+	}
+
+	logger.WithContext(ctx).Info("Conversation history deleted", map[string]interface{}{
+		"user_id": userId,
+		"turns":   deleted,
+	})
+	return deleted, nil
+}
+
+// HistoryQuestionSearchService records the answers given to signed-in callers, with the
+// citations collected for the request. Anonymous callers have no history. A failure to
+// record is logged and the answer is returned anyway.
+type HistoryQuestionSearchService struct {
+	next    QuestionSearchService
+	history HistoryService
+}
+
+func NewHistoryQuestionSearchService(next QuestionSearchService, history HistoryService) *HistoryQuestionSearchService {
+	return &HistoryQuestionSearchService{
+		next:    next,
+		history: history,
+	}
+}
+
+func (s *HistoryQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	answer, relatedDocuments, err := s.next.SearchAnswer(ctx, question, enableRelateDocument)
+	userId := auth.UserIdFromContext(ctx)
+	if err != nil || userId == "" {
+		return answer, relatedDocuments, err
+	}
+
+	var citations []aws.Citation
+	if collector := aws.CitationCollectorFromContext(ctx); collector != nil {
+		citations = collector.List()
+	}
+	if recordErr := s.history.RecordTurn(ctx, userId, question, answer, citations); recordErr != nil {
+		logger.WithContext(ctx).Warn("Failed to record conversation turn", map[string]interface{}{
+			"error": recordErr.Error(),
+		})
+	}
+	return answer, relatedDocuments, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+// citingQuestionSearchService answers with a citation of the question's document
+type citingQuestionSearchService struct{}
+
+func (s *citingQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	aws.RecordCitations(ctx, []aws.Citation{{Text: "answer", Sources: []aws.CitationSource{{DocumentUrl: "https://b/fees.pdf"}}}})
+	return "answer to " + question, nil, nil
+}
+
+func TestHistory_RecordsTurnsOfSignedInCallers(t *testing.T) {
+	store := storage.NewMemoryConversationHistoryStore()
+	history := NewStoreHistoryService(store, &config.Config{HistoryRetentionDays: 90})
+	service := NewHistoryQuestionSearchService(&citingQuestionSearchService{}, history)
+
+	signedIn := auth.WithIdentity(context.Background(), &auth.Identity{UserId: "user-1"})
+	for _, question := range []string{"first", "second", "third"} {
+		ctx, _ := aws.WithCitationCollector(signedIn)
+		if _, _, err := service.SearchAnswer(ctx, question, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// Anonymous callers have no history
+	service.SearchAnswer(context.Background(), "anonymous", false)
+
+	turns, _ := history.List(context.Background(), "user-1", "", 2)
+	if len(turns) != 2 || turns[0].Question != "third" || turns[1].Question != "second" {
+		t.Fatalf("expected the newest two turns first, got %+v", turns)
+	}
+	var citations []aws.Citation
+	if err := json.Unmarshal(turns[0].Citations, &citations); err != nil || len(citations) != 1 || citations[0].Sources[0].DocumentUrl != "https://b/fees.pdf" {
+		t.Errorf("expected the citations to be recorded, got %s", turns[0].Citations)
+	}
+
+	older, _ := history.List(context.Background(), "user-1", turns[1].TurnId, 2)
+	if len(older) != 1 || older[0].Question != "first" {
+		t.Errorf("expected the oldest turn before the page, got %+v", older)
+	}
+
+	deleted, err := history.Delete(context.Background(), "user-1")
+	if err != nil || deleted != 3 {
+		t.Fatalf("expected 3 turns deleted, got %d %v", deleted, err)
+	}
+	if remaining, _ := history.List(context.Background(), "user-1", "", 10); len(remaining) != 0 {
+		t.Errorf("expected no history left, got %+v", remaining)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+var (
+	// ErrDataSourceNotFound is returned for a data source that does not exist or belongs to a
+	// knowledge base outside KNOWLEDGE_BASE_IDS
+	ErrDataSourceNotFound   = stdErrors.New("data source not found")
+	ErrIngestionJobNotFound = stdErrors.New("ingestion job not found")
+)
+
+// DataSourceStatus is a data source with its latest ingestion job, nil when it was never synced
+type DataSourceStatus struct {
+	aws.DataSource
+	LatestJob *aws.IngestionJob `json:"latestJob"`
+}
+
+type IngestionService interface {
+	// ListDataSources returns the data sources of every configured knowledge base
+	ListDataSources(ctx context.Context) ([]DataSourceStatus, error)
+	// StartSync starts an ingestion job that re-syncs a data source into its knowledge base
+	StartSync(ctx context.Context, knowledgeBaseId string, dataSourceId string) (*aws.IngestionJob, error)
+	GetJob(ctx context.Context, knowledgeBaseId string, dataSourceId string, ingestionJobId string) (*aws.IngestionJob, error)
+	// TrackSync stores a started ingestion job as a job owned by owner, polled at /jobs/{id}.
+	// It returns nil without an error when jobs are not stored.
+	TrackSync(ctx context.Context, owner string, ingestionJob *aws.IngestionJob) (*storage.Job, error)
+}
+
+// BedrockIngestionService syncs the data sources of the knowledge bases in
+// KNOWLEDGE_BASE_IDS, so operators can re-sync after document updates without the console
+type BedrockIngestionService struct {
+	client           aws.IngestionClient
+	knowledgeBaseIds func() []string
+	jobs             storage.JobStore // Optional, syncs are only polled from Bedrock without
+	jobTTL           time.Duration
+}
+
+func NewBedrockIngestionService(client aws.IngestionClient, knowledgeBaseIds func() []string, jobs storage.JobStore, jobTTL time.Duration) *BedrockIngestionService {
+	return &BedrockIngestionService{
+		client:           client,
+		knowledgeBaseIds: knowledgeBaseIds,
+		jobs:             jobs,
+		jobTTL:           jobTTL,
+	}
+}
+
+func (s *BedrockIngestionService) ListDataSources(ctx context.Context) ([]DataSourceStatus, error) {
+	statuses := []DataSourceStatus{}
+	for _, knowledgeBaseId := range s.knowledgeBaseIds() {
+		dataSources, err := s.client.ListDataSources(ctx, knowledgeBaseId)
+		if err != nil {
+			return nil, err
+		}
+		for _, dataSource := range dataSources {
+			jobs, err := s.client.ListIngestionJobs(ctx, knowledgeBaseId, dataSource.DataSourceId, 1)
+			if err != nil {
+				return nil, err
+			}
+			status := DataSourceStatus{DataSource: dataSource}
+			if len(jobs) > 0 {
+				status.LatestJob = &jobs[0]
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+// StartSync returns aws.ErrIngestionJobRunning when the data source is already syncing
+func (s *BedrockIngestionService) StartSync(ctx context.Context, knowledgeBaseId string, dataSourceId string) (*aws.IngestionJob, error) {
+	if !s.configured(knowledgeBaseId) {
+		return nil, ErrDataSourceNotFound
+	}
+
+	job, err := s.client.StartIngestionJob(ctx, knowledgeBaseId, dataSourceId)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrDataSourceNotFound
+	}
+
+	logger.WithContext(ctx).Info("Knowledge base ingestion job started", map[string]interface{}{
+		"knowledge_base_id": knowledgeBaseId,
+		"data_source_id":    dataSourceId,
+		"ingestion_job_id":  job.IngestionJobId,
+	})
+	return job, nil
+}
+
+func (s *BedrockIngestionService) GetJob(ctx context.Context, knowledgeBaseId string, dataSourceId string, ingestionJobId string) (*aws.IngestionJob, error) {
+	if !s.configured(knowledgeBaseId) {
+		return nil, ErrIngestionJobNotFound
+	}
+
+	job, err := s.client.GetIngestionJob(ctx, knowledgeBaseId, dataSourceId, ingestionJobId)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrIngestionJobNotFound
+	}
+	return job, nil
+}
+
+func (s *BedrockIngestionService) TrackSync(ctx context.Context, owner string, ingestionJob *aws.IngestionJob) (*storage.Job, error) {
+	if s.jobs == nil {
+		return nil, nil
+	}
+
+	job, err := newJob(storage.JobTypeIngestion, owner, s.jobTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := updateIngestionJob(job, ingestionJob); err != nil {
+		return nil, err
+	}
+	if err := s.jobs.SaveJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// RefreshJob reads the ingestion job of a tracked sync from Bedrock, which does not report
+// its progress
+func (s *BedrockIngestionService) RefreshJob(ctx context.Context, job *storage.Job) (bool, error) {
+	var tracked aws.IngestionJob
+	if err := json.Unmarshal(job.Result, &tracked); err != nil {
+		return false, fmt.Errorf("failed to parse tracked ingestion job: %w", err)
+	}
+
+	ingestionJob, err := s.GetJob(ctx, tracked.KnowledgeBaseId, tracked.DataSourceId, tracked.IngestionJobId)
+	if err != nil {
+		return false, err
+	}
+	before := string(job.Result)
+	if err := updateIngestionJob(job, ingestionJob); err != nil {
+		return false, err
+	}
+	return string(job.Result) != before, nil
+}
+
+// updateIngestionJob stores the ingestion job as the job's result, with its status: partial
+// when some documents failed to index
+func updateIngestionJob(job *storage.Job, ingestionJob *aws.IngestionJob) error {
+	result, err := json.Marshal(ingestionJob)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ingestion job: %w", err)
+	}
+	job.Result = result
+	job.Error = ""
+
+	switch ingestionJob.Status {
+	case "COMPLETE":
+		job.Status = storage.JobSucceeded
+		if ingestionJob.Statistics != nil && ingestionJob.Statistics.DocumentsFailed > 0 {
+			job.Status = storage.JobPartial
+		}
+	case "FAILED":
+		job.Status = storage.JobFailed
+		job.Error = "The ingestion job failed"
+	case "STOPPED":
+		job.Status = storage.JobFailed
+		job.Error = "The ingestion job was stopped"
+	default:
+		job.Status = storage.JobRunning
+	}
+	return nil
+}
+
+func (s *BedrockIngestionService) configured(knowledgeBaseId string) bool {
+	for _, id := range s.knowledgeBaseIds() {
+		if id == knowledgeBaseId {
+			return true
+		}
+	}
+	return false
+}
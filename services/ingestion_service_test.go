@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"teletubpax-api/aws"
+)
+
+type mockIngestionClient struct {
+	dataSources map[string][]aws.DataSource            // By knowledge base ID
+	jobs        map[string][]aws.IngestionJob          // By data source ID, newest first
+	documents   map[string][]aws.KnowledgeBaseDocument // By data source ID, listed two per page
+	started     []string
+}
+
+func (m *mockIngestionClient) ListDataSources(ctx context.Context, knowledgeBaseId string) ([]aws.DataSource, error) {
+	return m.dataSources[knowledgeBaseId], nil
+}
+
+func (m *mockIngestionClient) StartIngestionJob(ctx context.Context, knowledgeBaseId string, dataSourceId string) (*aws.IngestionJob, error) {
+	for _, dataSource := range m.dataSources[knowledgeBaseId] {
+		if dataSource.DataSourceId != dataSourceId {
+			continue
+		}
+		if jobs := m.jobs[dataSourceId]; len(jobs) > 0 && jobs[0].Status == "IN_PROGRESS" {
+			return nil, aws.ErrIngestionJobRunning
+		}
+		m.started = append(m.started, dataSourceId)
+		return &aws.IngestionJob{KnowledgeBaseId: knowledgeBaseId, DataSourceId: dataSourceId, IngestionJobId: "job-new", Status: "STARTING"}, nil
+	}
+	return nil, nil
+}
+
+func (m *mockIngestionClient) GetIngestionJob(ctx context.Context, knowledgeBaseId string, dataSourceId string, ingestionJobId string) (*aws.IngestionJob, error) {
+	for _, job := range m.jobs[dataSourceId] {
+		if job.IngestionJobId == ingestionJobId {
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockIngestionClient) ListIngestionJobs(ctx context.Context, knowledgeBaseId string, dataSourceId string, maxResults int) ([]aws.IngestionJob, error) {
+	jobs := m.jobs[dataSourceId]
+	if len(jobs) > maxResults {
+		jobs = jobs[:maxResults]
+	}
+	return jobs, nil
+}
+
+func (m *mockIngestionClient) ListDocuments(ctx context.Context, knowledgeBaseId string, dataSourceId string, nextToken string) ([]aws.KnowledgeBaseDocument, string, error) {
+	documents := m.documents[dataSourceId]
+	start, _ := strconv.Atoi(nextToken)
+	if start+2 >= len(documents) {
+		return documents[start:], "", nil
+	}
+	return documents[start : start+2], strconv.Itoa(start + 2), nil
+}
+
+func knowledgeBaseIds(ids ...string) func() []string {
+	return func() []string { return ids }
+}
+
+func newMockIngestionClient() *mockIngestionClient {
+	return &mockIngestionClient{
+		dataSources: map[string][]aws.DataSource{
+			"kb-1":     {{KnowledgeBaseId: "kb-1", DataSourceId: "ds-1", Name: "policies", Status: "AVAILABLE"}},
+			"kb-2":     {{KnowledgeBaseId: "kb-2", DataSourceId: "ds-2", Name: "products", Status: "AVAILABLE"}},
+			"kb-other": {{KnowledgeBaseId: "kb-other", DataSourceId: "ds-3", Name: "other", Status: "AVAILABLE"}},
+		},
+		jobs: map[string][]aws.IngestionJob{
+			"ds-1": {
+				{KnowledgeBaseId: "kb-1", DataSourceId: "ds-1", IngestionJobId: "job-2", Status: "IN_PROGRESS"},
+				{KnowledgeBaseId: "kb-1", DataSourceId: "ds-1", IngestionJobId: "job-1", Status: "COMPLETE"},
+			},
+		},
+	}
+}
+
+func TestIngestion_ListsDataSourcesOfConfiguredKnowledgeBases(t *testing.T) {
+	service := NewBedrockIngestionService(newMockIngestionClient(), knowledgeBaseIds("kb-1", "kb-2"), nil, 0)
+
+	dataSources, err := service.ListDataSources(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(dataSources) != 2 || dataSources[0].DataSourceId != "ds-1" || dataSources[1].DataSourceId != "ds-2" {
+		t.Fatalf("expected the data sources of kb-1 and kb-2, got %+v", dataSources)
+	}
+	if dataSources[0].LatestJob == nil || dataSources[0].LatestJob.IngestionJobId != "job-2" {
+		t.Errorf("expected the latest job of ds-1 to be job-2, got %+v", dataSources[0].LatestJob)
+	}
+	if dataSources[1].LatestJob != nil {
+		t.Errorf("expected ds-2 to have no job, got %+v", dataSources[1].LatestJob)
+	}
+}
+
+func TestIngestion_StartSync(t *testing.T) {
+	client := newMockIngestionClient()
+	service := NewBedrockIngestionService(client, knowledgeBaseIds("kb-1", "kb-2"), nil, 0)
+
+	job, err := service.StartSync(context.Background(), "kb-2", "ds-2")
+	if err != nil || job.IngestionJobId != "job-new" {
+		t.Fatalf("expected a started job, got %+v %v", job, err)
+	}
+	if _, err := service.StartSync(context.Background(), "kb-1", "ds-1"); err != aws.ErrIngestionJobRunning {
+		t.Errorf("expected a running job to be reported, got %v", err)
+	}
+	if _, err := service.StartSync(context.Background(), "kb-2", "ds-unknown"); err != ErrDataSourceNotFound {
+		t.Errorf("expected an unknown data source to be not found, got %v", err)
+	}
+	if _, err := service.StartSync(context.Background(), "kb-other", "ds-3"); err != ErrDataSourceNotFound {
+		t.Errorf("expected a knowledge base outside the configuration to be not found, got %v", err)
+	}
+	if len(client.started) != 1 {
+		t.Errorf("expected one started job, got %v", client.started)
+	}
+}
+
+func TestIngestion_GetJob(t *testing.T) {
+	service := NewBedrockIngestionService(newMockIngestionClient(), knowledgeBaseIds("kb-1"), nil, 0)
+
+	job, err := service.GetJob(context.Background(), "kb-1", "ds-1", "job-1")
+	if err != nil || job.Status != "COMPLETE" {
+		t.Fatalf("expected job-1, got %+v %v", job, err)
+	}
+	if _, err := service.GetJob(context.Background(), "kb-1", "ds-1", "job-9"); err != ErrIngestionJobNotFound {
+		t.Errorf("expected an unknown job to be not found, got %v", err)
+	}
+}
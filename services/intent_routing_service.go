@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+)
+
+// IntentClassifier names the topic of a question, such as "hr", "credit" or "it". Empty means
+// the question has no clear topic.
+type IntentClassifier interface {
+	Classify(ctx context.Context, question string) string
+}
+
+// KeywordIntentClassifier classifies questions by the keywords of each intent they contain.
+// The intent with the most matching keywords wins; a tie has no clear topic.
+type KeywordIntentClassifier struct {
+	intents  []string            // Sorted, so ties are decided the same way every time
+	keywords map[string][]string // Lower-cased keywords by intent
+}
+
+// NewKeywordIntentClassifier parses INTENT_KEYWORDS, a JSON object mapping an intent to its
+// keywords, e.g. {"hr": ["ลาพักร้อน", "เงินเดือน", "leave"], "it": ["รหัสผ่าน", "vpn"]}
+func NewKeywordIntentClassifier(value string) (*KeywordIntentClassifier, error) {
+	var parsed map[string][]string
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid INTENT_KEYWORDS: %w", err)
+	}
+
+	classifier := &KeywordIntentClassifier{keywords: map[string][]string{}}
+	for intent, keywords := range parsed {
+		intent = strings.ToLower(strings.TrimSpace(intent))
+		for _, keyword := range keywords {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+				classifier.keywords[intent] = append(classifier.keywords[intent], keyword)
+			}
+		}
+		if intent == "" || len(classifier.keywords[intent]) == 0 {
+			return nil, fmt.Errorf("invalid INTENT_KEYWORDS: intent %q needs a name and keywords", intent)
+		}
+		classifier.intents = append(classifier.intents, intent)
+	}
+	sort.Strings(classifier.intents)
+	return classifier, nil
+}
+
+func (c *KeywordIntentClassifier) Classify(ctx context.Context, question string) string {
+	question = strings.ToLower(question)
+	best, bestMatches, tie := "", 0, false
+	for _, intent := range c.intents {
+		matches := 0
+		for _, keyword := range c.keywords[intent] {
+			if strings.Contains(question, keyword) {
+				matches++
+			}
+		}
+		switch {
+		case matches > bestMatches:
+			best, bestMatches, tie = intent, matches, false
+		case matches > 0 && matches == bestMatches:
+			tie = true
+		}
+	}
+	if tie {
+		return ""
+	}
+	return best
+}
+
+// IntentRoutingQuestionSearchService classifies a question before question search, so only
+// the knowledge bases listing its intent, and those without intents, are searched. Questions
+// without a clear intent search every knowledge base. Routing an HR question away from the
+// credit and IT knowledge bases saves their generation and keeps their answers out of the
+// synthesis.
+type IntentRoutingQuestionSearchService struct {
+	next       QuestionSearchService
+	classifier IntentClassifier
+}
+
+func NewIntentRoutingQuestionSearchService(next QuestionSearchService, classifier IntentClassifier) *IntentRoutingQuestionSearchService {
+	return &IntentRoutingQuestionSearchService{
+		next:       next,
+		classifier: classifier,
+	}
+}
+
+func (s *IntentRoutingQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	if intent := s.classifier.Classify(ctx, question); intent != "" {
+		logger.WithContext(ctx).Info("Question routed by intent", map[string]interface{}{
+			"intent": intent,
+		})
+		ctx = aws.WithIntent(ctx, intent)
+	}
+	return s.next.SearchAnswer(ctx, question, enableRelateDocument)
+}
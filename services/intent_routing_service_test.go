@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"teletubpax-api/aws"
+)
+
+func TestKeywordIntentClassifier(t *testing.T) {
+	classifier, err := NewKeywordIntentClassifier(`{
+		"HR": ["ลาพักร้อน", "เงินเดือน", "Leave"],
+		"credit": ["บัตรเครดิต", "สินเชื่อ", "ดอกเบี้ย"],
+		"it": ["รหัสผ่าน", "vpn"]
+	}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		question string
+		want     string
+	}{
+		{"ลาพักร้อนได้กี่วัน", "hr"},
+		{"How many days of annual LEAVE do I get?", "hr"},
+		{"ดอกเบี้ยสินเชื่อบ้านเท่าไหร่", "credit"},
+		{"ลืมรหัสผ่าน VPN", "it"},
+		{"สินเชื่อสวัสดิการหักเงินเดือน", ""},         // One keyword of each, no clear topic
+		{"สินเชื่อดอกเบี้ยต่ำหักเงินเดือน", "credit"}, // Most keywords win
+		{"สาขาเปิดกี่โมง", ""},
+	}
+	for _, tt := range tests {
+		if got := classifier.Classify(context.Background(), tt.question); got != tt.want {
+			t.Errorf("Classify(%q) = %q, want %q", tt.question, got, tt.want)
+		}
+	}
+}
+
+func TestKeywordIntentClassifier_Invalid(t *testing.T) {
+	for _, value := range []string{`["hr"]`, `{"hr": []}`, `{"": ["leave"]}`, `{"hr": [" "]}`} {
+		if _, err := NewKeywordIntentClassifier(value); err == nil {
+			t.Errorf("expected %s to be rejected", value)
+		}
+	}
+}
+
+// intentRecordingService records the intent each question was searched with
+type intentRecordingService struct {
+	intents []string
+}
+
+func (s *intentRecordingService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	s.intents = append(s.intents, aws.IntentFromContext(ctx))
+	return "answer", nil, nil
+}
+
+func TestIntentRouting_SetsIntent(t *testing.T) {
+	next := &intentRecordingService{}
+	classifier, _ := NewKeywordIntentClassifier(`{"hr": ["ลาพักร้อน"]}`)
+	service := NewIntentRoutingQuestionSearchService(next, classifier)
+
+	service.SearchAnswer(context.Background(), "ลาพักร้อนได้กี่วัน", false)
+	service.SearchAnswer(context.Background(), "สาขาเปิดกี่โมง", false)
+	if len(next.intents) != 2 || next.intents[0] != "hr" || next.intents[1] != "" {
+		t.Errorf("expected the HR intent only on the HR question, got %q", next.intents)
+	}
+}
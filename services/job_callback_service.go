@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/errors"
+	"teletubpax-api/logger"
+)
+
+const (
+	EventQuestionJobFinished = "question.job.finished"
+	EventSummaryJobFinished  = "summary.job.finished"
+)
+
+// JobCallbackPayload is the signed JSON body posted to the callback URL of a finished job,
+// with what polling the job would answer
+type JobCallbackPayload struct {
+	Id         string          `json:"id"`
+	Event      string          `json:"event"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	JobId      string          `json:"jobId"`
+	Status     string          `json:"status"`               // "completed" or "failed"
+	StatusCode int             `json:"statusCode,omitempty"` // Of the question-search response, for question jobs
+	Error      string          `json:"error,omitempty"`      // Of a failed summary job
+	Result     json.RawMessage `json:"result,omitempty"`     // The question-search response or the summaries
+}
+
+// JobCallbackNotifier is told about jobs finished with a callback URL
+type JobCallbackNotifier interface {
+	// NotifyJobFinished delivers the payload to the job's callback URL, signed with its
+	// secret, and waits for the delivery to finish. Failures are only logged: the job is
+	// stored and can still be polled.
+	NotifyJobFinished(ctx context.Context, callbackUrl string, secret string, payload JobCallbackPayload)
+}
+
+// HTTPJobCallbackService posts the outcome of asynchronous jobs to the callback URL they were
+// submitted with, so callers do not have to poll them. Payloads are signed and retried like
+// webhook payloads, with the secret returned when the job was submitted. Callback URLs come
+// from any caller of the API, so callbacks are only delivered to public addresses, checked
+// once the host is resolved so a DNS name cannot point them into the VPC or at the instance
+// metadata endpoint, and redirects are not followed.
+type HTTPJobCallbackService struct {
+	client       *http.Client
+	config       *config.Config
+	backoff      time.Duration
+	allowPrivate bool // Tests deliver to servers on the loopback address
+}
+
+func NewHTTPJobCallbackService(cfg *config.Config) *HTTPJobCallbackService {
+	s := &HTTPJobCallbackService{
+		config:  cfg,
+		backoff: webhookInitialBackoff,
+	}
+	timeout := time.Duration(cfg.WebhookTimeoutSeconds) * time.Second
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed instead of the callback's host
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: timeout, Control: s.checkCallbackAddress}).DialContext
+	s.client = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return s
+}
+
+// checkCallbackAddress refuses connections to addresses that are not public
+func (s *HTTPJobCallbackService) checkCallbackAddress(network string, address string, _ syscall.RawConn) error {
+	if s.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !isPublicAddr(ip) {
+		return fmt.Errorf("callback address %s is not public", host)
+	}
+	return nil
+}
+
+// sharedAddressSpace is carrier-grade NAT, not routed on the internet (RFC 6598)
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublicAddr reports whether an address is routed on the internet: not loopback,
+// link-local, private, unspecified or multicast
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+func (s *HTTPJobCallbackService) NotifyJobFinished(ctx context.Context, callbackUrl string, secret string, payload JobCallbackPayload) {
+	log := logger.WithContext(ctx)
+
+	payload.Id = time.Now().UTC().Format("20060102T150405Z") + "-" + randomSuffix()
+	payload.OccurredAt = time.Now().UTC()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Warn("Failed to marshal job callback payload", map[string]interface{}{
+			"job_id": payload.JobId,
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	status, err := sendWithRetries(ctx, s.config.WebhookMaxAttempts, s.backoff, func() (int, error) {
+		return postWebhookPayload(ctx, s.client, callbackUrl, secret, payload.Event, payload.Id, body)
+	})
+	if err != nil {
+		log.Warn("Job callback delivery failed", map[string]interface{}{
+			"job_id":   payload.JobId,
+			"delivery": payload.Id,
+			"status":   status,
+			"error":    err.Error(),
+		})
+		return
+	}
+	log.Info("Job callback delivered", map[string]interface{}{
+		"job_id":   payload.JobId,
+		"delivery": payload.Id,
+		"status":   status,
+	})
+}
+
+// newJobCallback validates the callback URL of a submitted job and generates the secret its
+// callback is signed with. An empty URL asks for no callback. Hosts that are addresses must
+// be public; names are checked when the callback is delivered.
+func newJobCallback(callbackUrl string) (string, string, error) {
+	if callbackUrl == "" {
+		return "", "", nil
+	}
+	callbackUrl, err := validateHttpsUrl("callbackUrl", callbackUrl)
+	if err != nil {
+		return "", "", err
+	}
+	parsed, _ := url.Parse(callbackUrl)
+	if ip, err := netip.ParseAddr(parsed.Hostname()); (err == nil && !isPublicAddr(ip)) || strings.EqualFold(parsed.Hostname(), "localhost") {
+		return "", "", errors.NewValidationError("callbackUrl must be a public https:// URL")
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return "", "", err
+	}
+	return callbackUrl, secret, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type jobCallback struct {
+	url     string
+	secret  string
+	payload JobCallbackPayload
+}
+
+type recordingJobCallbacks struct {
+	callbacks []jobCallback
+}
+
+func (r *recordingJobCallbacks) NotifyJobFinished(ctx context.Context, callbackUrl string, secret string, payload JobCallbackPayload) {
+	r.callbacks = append(r.callbacks, jobCallback{url: callbackUrl, secret: secret, payload: payload})
+}
+
+func TestJobCallback_PostsSignedPayloadWithRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(WebhookSignatureHeader) != "sha256="+SignWebhookPayload("secret", r.Header.Get(WebhookTimestampHeader), body) {
+			t.Errorf("invalid signature %q", r.Header.Get(WebhookSignatureHeader))
+		}
+		if r.Header.Get(WebhookEventHeader) != EventQuestionJobFinished {
+			t.Errorf("unexpected event header %q", r.Header.Get(WebhookEventHeader))
+		}
+
+		var payload JobCallbackPayload
+		json.Unmarshal(body, &payload)
+		if payload.Id == "" || payload.Id != r.Header.Get(WebhookDeliveryHeader) || payload.JobId != "job-1" || string(payload.Result) != `{"answer":"100 baht"}` {
+			t.Errorf("unexpected payload %s", body)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	service := NewHTTPJobCallbackService(webhookConfig())
+	service.backoff = time.Millisecond
+	service.allowPrivate = true
+	service.NotifyJobFinished(context.Background(), server.URL, "secret", JobCallbackPayload{
+		Event:      EventQuestionJobFinished,
+		JobId:      "job-1",
+		Status:     "completed",
+		StatusCode: http.StatusOK,
+		Result:     json.RawMessage(`{"answer":"100 baht"}`),
+	})
+	if attempts.Load() != 2 {
+		t.Errorf("expected the 502 to be retried, got %d attempts", attempts.Load())
+	}
+}
+
+func TestJobCallback_DeliversOnlyToPublicAddressesWithoutRedirects(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	service := NewHTTPJobCallbackService(webhookConfig())
+	service.backoff = time.Millisecond
+	payload := JobCallbackPayload{Event: EventQuestionJobFinished, JobId: "job-1", Status: "completed"}
+
+	// A name resolving to the loopback address is refused when it is dialed
+	service.NotifyJobFinished(context.Background(), strings.Replace(server.URL, "127.0.0.1", "localhost", 1), "secret", payload)
+	if attempts.Load() != 0 {
+		t.Errorf("expected the loopback address to be refused, got %d attempts", attempts.Load())
+	}
+
+	// Redirects are answered with, not followed
+	service.allowPrivate = true
+	service.NotifyJobFinished(context.Background(), server.URL, "secret", payload)
+	if attempts.Load() != 1 {
+		t.Errorf("expected a single attempt without following the redirect, got %d", attempts.Load())
+	}
+}
+
+func TestNewJobCallback_RejectsPrivateAddresses(t *testing.T) {
+	for _, callbackUrl := range []string{"https://127.0.0.1/hook", "https://169.254.169.254/latest", "https://10.0.1.5/hook", "https://[::1]/hook", "https://localhost:8443/hook", "http://portal.example.com/hook"} {
+		if _, _, err := newJobCallback(callbackUrl); err == nil {
+			t.Errorf("expected %q to be rejected", callbackUrl)
+		}
+	}
+	if url, secret, err := newJobCallback("https://portal.example.com/hook"); err != nil || url == "" || secret == "" {
+		t.Errorf("expected a public URL to be accepted, got %q %v", url, err)
+	}
+}
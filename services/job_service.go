@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+// JobRefresher brings an unfinished job up to date when it is read, for jobs run by a
+// service that does not report back, e.g. Bedrock ingestion
+type JobRefresher interface {
+	// RefreshJob updates the job in place and reports whether it changed
+	RefreshJob(ctx context.Context, job *storage.Job) (bool, error)
+}
+
+type JobService interface {
+	// Get returns nil for unknown and expired jobs, and for jobs of another owner
+	Get(ctx context.Context, jobId string, owner string) (*storage.Job, error)
+}
+
+// StoreJobService reads the jobs of every long-running operation from the jobs table:
+// asynchronous questions, bulk summaries and ingestion syncs
+type StoreJobService struct {
+	store      storage.JobStore
+	refreshers map[string]JobRefresher // By job type
+}
+
+func NewStoreJobService(store storage.JobStore, refreshers map[string]JobRefresher) *StoreJobService {
+	return &StoreJobService{
+		store:      store,
+		refreshers: refreshers,
+	}
+}
+
+func (s *StoreJobService) Get(ctx context.Context, jobId string, owner string) (*storage.Job, error) {
+	job, err := s.store.GetJob(ctx, jobId)
+	if err != nil || job == nil || job.Owner != owner {
+		return nil, err
+	}
+
+	refresher := s.refreshers[job.Type]
+	if job.Finished() || refresher == nil {
+		return job, nil
+	}
+	changed, err := refresher.RefreshJob(ctx, job)
+	if err != nil {
+		// The stored state is still the best known
+		logger.WithContext(ctx).Warn("Failed to refresh job", map[string]interface{}{
+			"job_id": job.JobId,
+			"type":   job.Type,
+			"error":  err.Error(),
+		})
+		return job, nil
+	}
+	if changed {
+		job.UpdatedAt = time.Now().UTC()
+		if err := s.store.SaveJob(ctx, job); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// newJob is a queued job of the type, owned by owner and kept for ttl
+func newJob(jobType string, owner string, ttl time.Duration) (*storage.Job, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	now := time.Now().UTC()
+	return &storage.Job{
+		JobId:     hex.EncodeToString(id),
+		Type:      jobType,
+		Owner:     owner,
+		Status:    storage.JobQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(ttl).Unix(),
+	}, nil
+}
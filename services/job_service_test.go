@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/storage"
+)
+
+func TestJobService_RefreshesIngestionJobs(t *testing.T) {
+	client := newMockIngestionClient()
+	store := storage.NewMemoryJobStore()
+	ingestion := NewBedrockIngestionService(client, knowledgeBaseIds("kb-1", "kb-2"), store, time.Hour)
+	jobs := NewStoreJobService(store, map[string]JobRefresher{storage.JobTypeIngestion: ingestion})
+	ctx := context.Background()
+
+	started, err := ingestion.StartSync(ctx, "kb-2", "ds-2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tracked, err := ingestion.TrackSync(ctx, "owner-1", started)
+	if err != nil || tracked == nil || tracked.Type != storage.JobTypeIngestion || tracked.Status != storage.JobRunning {
+		t.Fatalf("expected a running ingestion job, got %+v, %v", tracked, err)
+	}
+	if other, _ := jobs.Get(ctx, tracked.JobId, "owner-2"); other != nil {
+		t.Errorf("expected jobs to be hidden from other callers")
+	}
+
+	// Bedrock finishes the sync with a document it could not index
+	client.jobs["ds-2"] = []aws.IngestionJob{{KnowledgeBaseId: "kb-2", DataSourceId: "ds-2", IngestionJobId: "job-new", Status: "COMPLETE",
+		Statistics: &aws.IngestionJobStatistics{DocumentsScanned: 3, NewDocumentsIndexed: 2, DocumentsFailed: 1}}}
+	job, err := jobs.Get(ctx, tracked.JobId, "owner-1")
+	if err != nil || job == nil || job.Status != storage.JobPartial {
+		t.Fatalf("expected a partial job, got %+v, %v", job, err)
+	}
+	if stored, _ := store.GetJob(ctx, tracked.JobId); stored.Status != storage.JobPartial {
+		t.Errorf("expected the refreshed status to be stored, got %q", stored.Status)
+	}
+
+	// Finished jobs are no longer read from Bedrock
+	client.jobs["ds-2"] = nil
+	if job, err := jobs.Get(ctx, tracked.JobId, "owner-1"); err != nil || job.Status != storage.JobPartial {
+		t.Errorf("expected the finished job as stored, got %+v, %v", job, err)
+	}
+}
+
+func TestJobService_KeepsStoredStateWhenRefreshFails(t *testing.T) {
+	client := newMockIngestionClient()
+	store := storage.NewMemoryJobStore()
+	ingestion := NewBedrockIngestionService(client, knowledgeBaseIds("kb-1"), store, time.Hour)
+	jobs := NewStoreJobService(store, map[string]JobRefresher{storage.JobTypeIngestion: ingestion})
+	ctx := context.Background()
+
+	tracked, _ := ingestion.TrackSync(ctx, "owner-1", &aws.IngestionJob{KnowledgeBaseId: "kb-1", DataSourceId: "ds-1", IngestionJobId: "job-gone", Status: "STARTING"})
+	job, err := jobs.Get(ctx, tracked.JobId, "owner-1")
+	if err != nil || job == nil || job.Status != storage.JobRunning {
+		t.Errorf("expected the stored job when Bedrock no longer knows it, got %+v, %v", job, err)
+	}
+}
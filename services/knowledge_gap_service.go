@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+const (
+	defaultGapReportDays  = 30
+	maxGapReportDays      = 365
+	defaultGapReportLimit = 20
+	maxGapReportLimit     = 100
+
+	// gapSimilarityThreshold is the minimum character-bigram similarity for a question to join
+	// a cluster. Bigrams work for Thai, which is written without spaces between words.
+	gapSimilarityThreshold = 0.5
+	maxGapSampleQuestions  = 5
+	maxGapSources          = 3
+)
+
+// KnowledgeGap is a cluster of similar unanswered questions
+type KnowledgeGap struct {
+	Question        string    `json:"question"` // Most asked wording in the cluster
+	Count           int       `json:"count"`
+	SampleQuestions []string  `json:"sampleQuestions"`
+	AverageTopScore float64   `json:"averageTopScore"`
+	NearestSources  []string  `json:"nearestSources"` // Closest documents the retrieval found, most frequent first
+	FirstAskedAt    time.Time `json:"firstAskedAt"`
+	LastAskedAt     time.Time `json:"lastAskedAt"`
+}
+
+type KnowledgeGapReport struct {
+	Since           time.Time      `json:"since"`
+	TotalUnanswered int            `json:"totalUnanswered"`
+	Gaps            []KnowledgeGap `json:"gaps"`
+}
+
+type KnowledgeGapService interface {
+	Report(ctx context.Context, days int, limit int) (*KnowledgeGapReport, error)
+}
+
+type StoreKnowledgeGapService struct {
+	store storage.NotFoundStore
+}
+
+func NewStoreKnowledgeGapService(store storage.NotFoundStore) *StoreKnowledgeGapService {
+	return &StoreKnowledgeGapService{
+		store: store,
+	}
+}
+
+// Report clusters the questions that went unanswered in the last days into knowledge gaps,
+// largest first
+func (s *StoreKnowledgeGapService) Report(ctx context.Context, days int, limit int) (*KnowledgeGapReport, error) {
+	if days <= 0 {
+		days = defaultGapReportDays
+	}
+	if days > maxGapReportDays {
+		days = maxGapReportDays
+	}
+	if limit <= 0 {
+		limit = defaultGapReportLimit
+	}
+	if limit > maxGapReportLimit {
+		limit = maxGapReportLimit
+	}
+
+	since := time.Now().UTC().Truncate(time.Second).AddDate(0, 0, -days)
+	events, err := s.store.ListNotFound(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	gaps := clusterNotFoundEvents(events)
+	if len(gaps) > limit {
+		gaps = gaps[:limit]
+	}
+
+	logger.WithContext(ctx).Info("Knowledge gap report generated", map[string]interface{}{
+		"days":       days,
+		"unanswered": len(events),
+		"gaps":       len(gaps),
+	})
+
+	return &KnowledgeGapReport{
+		Since:           since,
+		TotalUnanswered: len(events),
+		Gaps:            gaps,
+	}, nil
+}
+
+type gapCluster struct {
+	bigrams        map[string]bool // Bigrams of the first question, used as the cluster centroid
+	events         []storage.NotFoundEvent
+	questionCounts map[string]int
+	sourceCounts   map[string]int
+}
+
+// clusterNotFoundEvents greedily groups events whose normalized questions are similar. Events
+// are visited by question frequency so the most asked wording seeds each cluster.
+func clusterNotFoundEvents(events []storage.NotFoundEvent) []KnowledgeGap {
+	frequency := make(map[string]int)
+	for _, event := range events {
+		frequency[normalizeGapQuestion(event.Question)]++
+	}
+
+	ordered := make([]storage.NotFoundEvent, len(events))
+	copy(ordered, events)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		fi := frequency[normalizeGapQuestion(ordered[i].Question)]
+		fj := frequency[normalizeGapQuestion(ordered[j].Question)]
+		if fi != fj {
+			return fi > fj
+		}
+		return ordered[i].AskedAt.Before(ordered[j].AskedAt)
+	})
+
+	var clusters []*gapCluster
+	for _, event := range ordered {
+		normalized := normalizeGapQuestion(event.Question)
+		bigrams := questionBigrams(normalized)
+
+		var target *gapCluster
+		for _, cluster := range clusters {
+			if bigramSimilarity(bigrams, cluster.bigrams) >= gapSimilarityThreshold {
+				target = cluster
+				break
+			}
+		}
+		if target == nil {
+			target = &gapCluster{
+				bigrams:        bigrams,
+				questionCounts: make(map[string]int),
+				sourceCounts:   make(map[string]int),
+			}
+			clusters = append(clusters, target)
+		}
+
+		target.events = append(target.events, event)
+		target.questionCounts[strings.TrimSpace(event.Question)]++
+		for _, score := range event.Scores {
+			if score.SourceUrl != "" {
+				target.sourceCounts[score.SourceUrl]++
+			}
+		}
+	}
+
+	gaps := make([]KnowledgeGap, 0, len(clusters))
+	for _, cluster := range clusters {
+		gaps = append(gaps, cluster.toGap())
+	}
+	sort.SliceStable(gaps, func(i, j int) bool {
+		return gaps[i].Count > gaps[j].Count
+	})
+	return gaps
+}
+
+func (c *gapCluster) toGap() KnowledgeGap {
+	gap := KnowledgeGap{Count: len(c.events)}
+
+	var scoreTotal float64
+	for i, event := range c.events {
+		scoreTotal += event.TopScore
+		if i == 0 || event.AskedAt.Before(gap.FirstAskedAt) {
+			gap.FirstAskedAt = event.AskedAt
+		}
+		if event.AskedAt.After(gap.LastAskedAt) {
+			gap.LastAskedAt = event.AskedAt
+		}
+	}
+	gap.AverageTopScore = scoreTotal / float64(len(c.events))
+
+	questions := sortedByCount(c.questionCounts)
+	gap.Question = questions[0]
+	if len(questions) > maxGapSampleQuestions {
+		questions = questions[:maxGapSampleQuestions]
+	}
+	gap.SampleQuestions = questions
+
+	sources := sortedByCount(c.sourceCounts)
+	if len(sources) > maxGapSources {
+		sources = sources[:maxGapSources]
+	}
+	gap.NearestSources = sources
+
+	return gap
+}
+
+// sortedByCount returns the keys by descending count, ties in alphabetical order
+func sortedByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// normalizeGapQuestion lowercases the question and drops punctuation and whitespace so
+// trivially different wordings compare equal
+func normalizeGapQuestion(question string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(question) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func questionBigrams(normalized string) map[string]bool {
+	runes := []rune(normalized)
+	bigrams := make(map[string]bool)
+	if len(runes) == 1 {
+		bigrams[normalized] = true
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		bigrams[string(runes[i:i+2])] = true
+	}
+	return bigrams
+}
+
+// bigramSimilarity is the Jaccard index of two bigram sets
+func bigramSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	intersection := 0
+	for bigram := range a {
+		if b[bigram] {
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	return float64(intersection) / float64(union)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type mockNotFoundStore struct {
+	events []storage.NotFoundEvent
+	since  time.Time
+}
+
+func (m *mockNotFoundStore) RecordNotFound(ctx context.Context, event *storage.NotFoundEvent) error {
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *mockNotFoundStore) ListNotFound(ctx context.Context, since time.Time) ([]storage.NotFoundEvent, error) {
+	m.since = since
+	return m.events, nil
+}
+
+func TestKnowledgeGapReport_ClustersSimilarQuestions(t *testing.T) {
+	now := time.Now().UTC()
+	store := &mockNotFoundStore{events: []storage.NotFoundEvent{
+		{Question: "วิธีขอคืนเงินค่าธรรมเนียม", TopScore: 0.3, AskedAt: now.Add(-3 * time.Hour),
+			Scores: []storage.NotFoundScore{{SourceUrl: "https://docs/fees.pdf"}}},
+		{Question: "วิธีขอคืนเงินค่าธรรมเนียม?", TopScore: 0.5, AskedAt: now.Add(-2 * time.Hour)},
+		{Question: "วิธีขอคืนเงินค่าธรรมเนียมบัตร", TopScore: 0.4, AskedAt: now.Add(-1 * time.Hour)},
+		{Question: "How do I change my PIN", TopScore: 0.2, AskedAt: now},
+	}}
+
+	report, err := NewStoreKnowledgeGapService(store).Report(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.TotalUnanswered != 4 {
+		t.Errorf("expected 4 unanswered questions, got %d", report.TotalUnanswered)
+	}
+	if got := now.Sub(store.since); got < 29*24*time.Hour || got > 31*24*time.Hour {
+		t.Errorf("expected the default 30 day window, got %v", got)
+	}
+	if len(report.Gaps) != 2 {
+		t.Fatalf("expected 2 gaps, got %d: %+v", len(report.Gaps), report.Gaps)
+	}
+
+	gap := report.Gaps[0]
+	if gap.Count != 3 {
+		t.Errorf("expected the largest gap first with 3 questions, got %d", gap.Count)
+	}
+	if gap.Question != "วิธีขอคืนเงินค่าธรรมเนียม" && gap.Question != "วิธีขอคืนเงินค่าธรรมเนียม?" {
+		t.Errorf("unexpected representative question %q", gap.Question)
+	}
+	if len(gap.NearestSources) != 1 || gap.NearestSources[0] != "https://docs/fees.pdf" {
+		t.Errorf("unexpected nearest sources %v", gap.NearestSources)
+	}
+	if gap.AverageTopScore < 0.39 || gap.AverageTopScore > 0.41 {
+		t.Errorf("expected average top score 0.4, got %f", gap.AverageTopScore)
+	}
+}
+
+func TestQuestionSearch_RecordsUnansweredQuestions(t *testing.T) {
+	tests := []struct {
+		answer       string
+		wantRecorded bool
+	}{
+		{answer: aws.NoAnswerText, wantRecorded: true},
+		{answer: "ไม่พบข้อมูลในระบบ", wantRecorded: true},
+		{answer: "Apply at any branch", wantRecorded: false},
+	}
+
+	for _, tt := range tests {
+		store := &mockNotFoundStore{}
+		mockKB := &mockKnowledgeBaseClient{
+			queryKnowledgeBaseFunc: func(ctx context.Context, question string, enableRelateDocument bool) (string, error) {
+				return tt.answer, nil
+			},
+			retrieveFunc: func(ctx context.Context, question string, numberOfResults int) ([]aws.KnowledgeBaseRetrieval, error) {
+				return []aws.KnowledgeBaseRetrieval{
+					{KnowledgeBaseId: "kb-1", Chunks: []aws.RetrievedChunk{{Score: 0.42, SourceUrl: "https://docs/a.pdf"}}},
+					{KnowledgeBaseId: "kb-2", Chunks: []aws.RetrievedChunk{}},
+				}, nil
+			},
+		}
+		cfg := &config.Config{RetryAttempts: 1, NotFoundRetentionDays: 90}
+
+		service := NewBedrockQuestionSearchService(nil, mockKB, store, nil, cfg)
+		if _, _, err := service.SearchAnswer(context.Background(), "question", false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if recorded := len(store.events) == 1; recorded != tt.wantRecorded {
+			t.Fatalf("answer %q: expected recorded=%v, got %d events", tt.answer, tt.wantRecorded, len(store.events))
+		}
+		if !tt.wantRecorded {
+			continue
+		}
+		event := store.events[0]
+		if event.TopScore != 0.42 || len(event.Scores) != 1 || event.Scores[0].KnowledgeBaseId != "kb-1" {
+			t.Errorf("unexpected scores: top=%f scores=%+v", event.TopScore, event.Scores)
+		}
+		if event.Id == "" || event.ExpiresAt <= event.AskedAt.Unix() {
+			t.Errorf("expected id and expiry to be set, got %+v", event)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"teletubpax-api/config"
+	"teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+const (
+	// MaxPromptBytes is the largest value of an advanced tier SSM parameter
+	MaxPromptBytes = 8192
+	// MaxPromptNoteLength is the longest description of an SSM parameter
+	MaxPromptNoteLength = 1024
+)
+
+// ErrUnknownPrompt is returned for a name that is not in config.PromptNames
+var ErrUnknownPrompt = stdErrors.New("unknown prompt")
+
+// Prompt is a prompt in effect, named after the env var it replaces
+type Prompt struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+// PromptDetail is a prompt in effect with its saved versions, newest first. The prompt in
+// effect lags the latest version until a reload succeeds.
+type PromptDetail struct {
+	Prompt
+	Versions []storage.PromptVersion `json:"versions"`
+}
+
+type PromptService interface {
+	// List returns the prompts in effect, in the order of config.PromptNames
+	List(ctx context.Context) []Prompt
+	Get(ctx context.Context, name string) (*PromptDetail, error)
+	// Update saves text as the next version of a prompt and reloads the settings, so this
+	// instance answers with it right away and the others within CONFIG_REFRESH_SECONDS
+	Update(ctx context.Context, name string, text string, note string) (*storage.PromptVersion, error)
+	// Restore saves the text of an earlier version as the next version of a prompt
+	Restore(ctx context.Context, name string, version int64, note string) (*storage.PromptVersion, error)
+}
+
+// StorePromptService saves prompts in a PromptStore whose versions the live settings read
+type StorePromptService struct {
+	store    storage.PromptStore
+	settings *config.LiveSettings
+}
+
+func NewStorePromptService(store storage.PromptStore, settings *config.LiveSettings) *StorePromptService {
+	return &StorePromptService{
+		store:    store,
+		settings: settings,
+	}
+}
+
+func (s *StorePromptService) List(ctx context.Context) []Prompt {
+	settings := s.settings.Get()
+	prompts := make([]Prompt, 0, len(config.PromptNames))
+	for _, name := range config.PromptNames {
+		text, _ := settings.Prompt(name)
+		prompts = append(prompts, Prompt{Name: name, Text: text})
+	}
+	return prompts
+}
+
+func (s *StorePromptService) Get(ctx context.Context, name string) (*PromptDetail, error) {
+	text, ok := s.settings.Get().Prompt(name)
+	if !ok {
+		return nil, ErrUnknownPrompt
+	}
+	versions, err := s.store.ListPromptVersions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &PromptDetail{Prompt: Prompt{Name: name, Text: text}, Versions: versions}, nil
+}
+
+func (s *StorePromptService) Update(ctx context.Context, name string, text string, note string) (*storage.PromptVersion, error) {
+	if !slices.Contains(config.PromptNames, name) {
+		return nil, ErrUnknownPrompt
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.NewValidationError("text is required")
+	}
+	if len(text) > MaxPromptBytes {
+		return nil, errors.NewValidationError(fmt.Sprintf("text must not exceed %d bytes", MaxPromptBytes))
+	}
+	return s.save(ctx, name, text, note)
+}
+
+func (s *StorePromptService) Restore(ctx context.Context, name string, version int64, note string) (*storage.PromptVersion, error) {
+	if !slices.Contains(config.PromptNames, name) {
+		return nil, ErrUnknownPrompt
+	}
+	versions, err := s.store.ListPromptVersions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, saved := range versions {
+		if saved.Version == version {
+			if note == "" {
+				note = fmt.Sprintf("Restored version %d", version)
+			}
+			return s.save(ctx, name, saved.Text, note)
+		}
+	}
+	return nil, errors.NewValidationError(fmt.Sprintf("version %d of %s does not exist", version, name))
+}
+
+// save stores the version and reloads the settings. A failed reload is logged, the version
+// is saved and applies with the next reload.
+func (s *StorePromptService) save(ctx context.Context, name string, text string, note string) (*storage.PromptVersion, error) {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxPromptNoteLength {
+		return nil, errors.NewValidationError(fmt.Sprintf("note must not exceed %d characters", MaxPromptNoteLength))
+	}
+	saved, err := s.store.PutPrompt(ctx, name, text, note)
+	if err != nil {
+		return nil, err
+	}
+
+	log := logger.WithContext(ctx)
+	log.Info("Prompt saved", map[string]interface{}{
+		"name":    name,
+		"version": saved.Version,
+		"note":    note,
+	})
+	if err := s.settings.Reload(ctx); err != nil {
+		log.Warn("Failed to reload the saved prompt", map[string]interface{}{
+			"name":  name,
+			"error": err.Error(),
+		})
+	}
+	return saved, nil
+}
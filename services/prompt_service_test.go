@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+// promptParameterSource reads the latest saved prompts like the CONFIG_SSM_PREFIX parameters
+type promptParameterSource struct {
+	store *storage.MemoryPromptStore
+}
+
+func (s *promptParameterSource) Name() string {
+	return "prompts"
+}
+
+func (s *promptParameterSource) Load(ctx context.Context) (map[string]string, error) {
+	parameters := map[string]string{"BEDROCK_KB_ID": "KB1"}
+	for _, name := range config.PromptNames {
+		versions, _ := s.store.ListPromptVersions(ctx, name)
+		if len(versions) > 0 {
+			parameters[name] = versions[0].Text
+		}
+	}
+	return parameters, nil
+}
+
+func TestPrompts_UpdateAppliesWithoutRestart(t *testing.T) {
+	store := storage.NewMemoryPromptStore()
+	settings := config.NewLiveSettings(config.Settings{SynthesisInstructions: "Merge the answers."}, &promptParameterSource{store: store}, time.Hour)
+	service := NewStorePromptService(store, settings)
+
+	prompts := service.List(context.Background())
+	if len(prompts) != len(config.PromptNames) || prompts[2].Name != "SYNTHESIS_INSTRUCTIONS" || prompts[2].Text != "Merge the answers." {
+		t.Fatalf("expected every prompt in effect, got %+v", prompts)
+	}
+
+	if _, err := service.Update(context.Background(), "SYNTHESIS_INSTRUCTIONS", " Keep the newest answer. ", "prefer recent documents"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	version, err := service.Update(context.Background(), "SYNTHESIS_INSTRUCTIONS", "Keep every answer.", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version.Version != 2 || settings.SynthesisInstructions() != "Keep every answer." {
+		t.Errorf("expected version 2 in effect right away, got %+v and %q", version, settings.SynthesisInstructions())
+	}
+
+	restored, err := service.Restore(context.Background(), "SYNTHESIS_INSTRUCTIONS", 1, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restored.Version != 3 || restored.Note != "Restored version 1" || settings.SynthesisInstructions() != "Keep the newest answer." {
+		t.Errorf("expected version 1 restored as version 3, got %+v and %q", restored, settings.SynthesisInstructions())
+	}
+
+	detail, err := service.Get(context.Background(), "SYNTHESIS_INSTRUCTIONS")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if detail.Text != "Keep the newest answer." || len(detail.Versions) != 3 || detail.Versions[0].Version != 3 || detail.Versions[2].Note != "prefer recent documents" {
+		t.Errorf("expected the prompt with its versions newest first, got %+v", detail)
+	}
+}
+
+func TestPrompts_RejectsInvalidUpdates(t *testing.T) {
+	store := storage.NewMemoryPromptStore()
+	service := NewStorePromptService(store, config.NewLiveSettings(config.Settings{}, nil, 0))
+
+	if _, err := service.Get(context.Background(), "LOG_LEVEL"); err != ErrUnknownPrompt {
+		t.Errorf("expected ErrUnknownPrompt for another env var, got %v", err)
+	}
+	if _, err := service.Update(context.Background(), "LOG_LEVEL", "debug", ""); err != ErrUnknownPrompt {
+		t.Errorf("expected ErrUnknownPrompt for another env var, got %v", err)
+	}
+	for _, text := range []string{" ", string(make([]byte, MaxPromptBytes+1))} {
+		if _, err := service.Update(context.Background(), "SYNTHESIS_INSTRUCTIONS", text, ""); err == nil {
+			t.Errorf("expected an error for a text of %d bytes", len(text))
+		}
+	}
+	if _, err := service.Restore(context.Background(), "SYNTHESIS_INSTRUCTIONS", 4, ""); err == nil {
+		t.Error("expected an error for a version that does not exist")
+	}
+	if versions, _ := store.ListPromptVersions(context.Background(), "SYNTHESIS_INSTRUCTIONS"); len(versions) != 0 {
+		t.Errorf("expected nothing saved, got %+v", versions)
+	}
+}
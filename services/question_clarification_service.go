@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"teletubpax-api/config"
+	"teletubpax-api/flags"
+	"teletubpax-api/logger"
+	"teletubpax-api/stages"
+)
+
+const (
+	// ClarificationFlag turns the question complexity guard on without a redeploy
+	ClarificationFlag = "question-clarification"
+
+	ClarificationTooLong   = "too_long"   // The question is longer than the configured limit
+	ClarificationMultiPart = "multi_part" // The question asks several things at once
+	ClarificationBroad     = "broad"      // The question names a product family, not a product
+
+	maxClarificationSuggestions = 4
+)
+
+// defaultClarificationTopics are the broad terms asked about without naming a product, with
+// the products they could mean. CLARIFICATION_TOPICS replaces them.
+var defaultClarificationTopics = map[string][]string{
+	"สินเชื่อ": {"สินเชื่อบ้าน", "สินเชื่อรถ", "สินเชื่อส่วนบุคคล"},
+	"loan":     {"home loan", "car loan", "personal loan"},
+}
+
+// questionPartSeparator splits a question into the questions it asks: question marks, and
+// Thai and English phrases that start another question. "หรือ" is left out on purpose, Thai
+// yes/no questions end with "หรือไม่".
+var questionPartSeparator = regexp.MustCompile(`(?i)[?？]|\s*(?:และ|รวมถึง|อีกทั้ง|นอกจากนี้|แล้วก็|อีกเรื่อง|อีกข้อ)\s*|\s+(?:and also|as well as)\s+|;`)
+
+// ClarificationRequiredError is returned instead of an answer when a question is too broad
+// or asks several things at once, with refined questions the user can pick from
+type ClarificationRequiredError struct {
+	Reason      string
+	Message     string
+	MessageTh   string
+	Suggestions []string
+}
+
+func (e *ClarificationRequiredError) Error() string {
+	return fmt.Sprintf("question needs clarification: %s", e.Reason)
+}
+
+type skipClarificationKey struct{}
+
+// WithoutClarification lets a question through the complexity guard, for users who insist
+// on an answer after a clarification prompt
+func WithoutClarification(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipClarificationKey{}, true)
+}
+
+// ClarifyingQuestionSearchService asks for a narrower question before question search
+// synthesizes a vague answer. Questions are checked in order for their length, the number
+// of questions they ask and broad product terms; the first check that fails returns a
+// ClarificationRequiredError. The guard runs only while the question-clarification flag is
+// on.
+type ClarifyingQuestionSearchService struct {
+	next   QuestionSearchService
+	topics map[string][]string
+	terms  []string // Topic terms, longest first so the most specific term matches
+	config *config.Config
+}
+
+// NewClarifyingQuestionSearchService parses CLARIFICATION_TOPICS, a JSON object mapping a
+// broad term to the products it could mean, and falls back to the built-in topics when it
+// is empty
+func NewClarifyingQuestionSearchService(next QuestionSearchService, cfg *config.Config) (*ClarifyingQuestionSearchService, error) {
+	topics := defaultClarificationTopics
+	if strings.TrimSpace(cfg.ClarificationTopics) != "" {
+		topics = map[string][]string{}
+		if err := json.Unmarshal([]byte(cfg.ClarificationTopics), &topics); err != nil {
+			return nil, fmt.Errorf("invalid CLARIFICATION_TOPICS: %w", err)
+		}
+	}
+
+	terms := make([]string, 0, len(topics))
+	for term, refinements := range topics {
+		if len(refinements) < 2 {
+			return nil, fmt.Errorf("invalid CLARIFICATION_TOPICS: %q needs at least two refinements", term)
+		}
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if len(terms[i]) != len(terms[j]) {
+			return len(terms[i]) > len(terms[j])
+		}
+		return terms[i] < terms[j]
+	})
+
+	return &ClarifyingQuestionSearchService{
+		next:   next,
+		topics: topics,
+		terms:  terms,
+		config: cfg,
+	}, nil
+}
+
+func (s *ClarifyingQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	if skip, _ := ctx.Value(skipClarificationKey{}).(bool); skip || !flags.Enabled(ClarificationFlag) {
+		return s.next.SearchAnswer(ctx, question, enableRelateDocument)
+	}
+
+	endClarification := stages.Start(ctx, stages.Clarification)
+	clarification := s.Check(question)
+	endClarification()
+	if clarification != nil {
+		logger.WithContext(ctx).Info("Question needs clarification", map[string]interface{}{
+			"reason":      clarification.Reason,
+			"suggestions": len(clarification.Suggestions),
+		})
+		return "", nil, clarification
+	}
+	return s.next.SearchAnswer(ctx, question, enableRelateDocument)
+}
+
+// Check returns the clarification a question needs, nil when it can be answered as is
+func (s *ClarifyingQuestionSearchService) Check(question string) *ClarificationRequiredError {
+	question = strings.TrimSpace(question)
+	parts := splitQuestionParts(question)
+
+	if limit := s.config.ClarificationMaxQuestionLength; limit > 0 && utf8.RuneCountInString(question) > limit {
+		return &ClarificationRequiredError{
+			Reason:      ClarificationTooLong,
+			Message:     "Your question is quite long. Please ask about one thing at a time in a short question.",
+			MessageTh:   "คำถามของคุณยาวเกินไป กรุณาถามทีละเรื่องด้วยคำถามที่สั้นลง",
+			Suggestions: limitSuggestions(parts),
+		}
+	}
+
+	if limit := s.config.ClarificationMaxParts; limit > 0 && len(parts) >= limit {
+		return &ClarificationRequiredError{
+			Reason:      ClarificationMultiPart,
+			Message:     "Your question asks several things at once. Which one would you like to ask first?",
+			MessageTh:   "คำถามของคุณมีหลายเรื่อง ต้องการถามเรื่องใดก่อน?",
+			Suggestions: limitSuggestions(parts),
+		}
+	}
+
+	return s.checkBroad(question)
+}
+
+// checkBroad matches the most specific topic term in the question. A question that already
+// names one of the term's products is specific enough.
+func (s *ClarifyingQuestionSearchService) checkBroad(question string) *ClarificationRequiredError {
+	lower := strings.ToLower(question)
+	for _, term := range s.terms {
+		index := strings.Index(lower, strings.ToLower(term))
+		if index < 0 {
+			continue
+		}
+
+		refinements := s.topics[term]
+		for _, refinement := range refinements {
+			if strings.Contains(lower, strings.ToLower(refinement)) {
+				return nil
+			}
+		}
+
+		suggestions := make([]string, 0, len(refinements))
+		for _, refinement := range refinements {
+			suggestions = append(suggestions, question[:index]+refinement+question[index+len(term):])
+		}
+		return &ClarificationRequiredError{
+			Reason:      ClarificationBroad,
+			Message:     "Which do you mean: " + joinChoices(refinements, "or") + "?",
+			MessageTh:   "คุณหมายถึง" + joinChoices(refinements, "หรือ") + "?",
+			Suggestions: limitSuggestions(suggestions),
+		}
+	}
+	return nil
+}
+
+// splitQuestionParts returns the non-empty questions a question is made of
+func splitQuestionParts(question string) []string {
+	var parts []string
+	for _, part := range questionPartSeparator.Split(question, -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func limitSuggestions(suggestions []string) []string {
+	if len(suggestions) > maxClarificationSuggestions {
+		return suggestions[:maxClarificationSuggestions]
+	}
+	if suggestions == nil {
+		return []string{}
+	}
+	return suggestions
+}
+
+// joinChoices lists choices as "a, b or c"
+func joinChoices(choices []string, or string) string {
+	if len(choices) == 1 {
+		return choices[0]
+	}
+	return strings.Join(choices[:len(choices)-1], ", ") + " " + or + " " + choices[len(choices)-1]
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/flags"
+)
+
+func newTestClarifyingService(t *testing.T, next QuestionSearchService, cfg *config.Config) *ClarifyingQuestionSearchService {
+	t.Helper()
+	service, err := NewClarifyingQuestionSearchService(next, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return service
+}
+
+func TestClarification_Check(t *testing.T) {
+	service := newTestClarifyingService(t, &stubQuestionSearchService{}, &config.Config{
+		ClarificationMaxQuestionLength: 100,
+		ClarificationMaxParts:          3,
+	})
+
+	tests := []struct {
+		name     string
+		question string
+		reason   string // Empty when the question needs no clarification
+	}{
+		{"specific Thai question", "สินเชื่อบ้านดอกเบี้ยเท่าไหร่", ""},
+		{"yes/no question", "ยกเว้นค่าธรรมเนียมได้หรือไม่", ""},
+		{"broad Thai term", "สินเชื่อดอกเบี้ยเท่าไหร่", ClarificationBroad},
+		{"broad English term", "What is the loan interest rate?", ClarificationBroad},
+		{"specific English question", "What is the home loan interest rate?", ""},
+		{"multi-part question", "ค่าธรรมเนียมโอนเงินเท่าไหร่? ถอนเงินต่างประเทศได้ไหม? เปิดบัญชีใช้เอกสารอะไร?", ClarificationMultiPart},
+		{"conjunctions", "ค่าธรรมเนียมโอนเงิน และวงเงินถอน รวมถึงเอกสารเปิดบัญชี", ClarificationMultiPart},
+		{"two parts", "ค่าธรรมเนียมโอนเงินและวงเงินถอน", ""},
+		{"too long", strings.Repeat("ค่าธรรมเนียม", 10), ClarificationTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clarification := service.Check(tt.question)
+			if tt.reason == "" {
+				if clarification != nil {
+					t.Errorf("expected no clarification, got %+v", clarification)
+				}
+				return
+			}
+			if clarification == nil || clarification.Reason != tt.reason {
+				t.Fatalf("expected reason %s, got %+v", tt.reason, clarification)
+			}
+			if clarification.Message == "" || clarification.MessageTh == "" {
+				t.Errorf("expected messages in both languages, got %+v", clarification)
+			}
+		})
+	}
+}
+
+func TestClarification_BroadTermSuggestions(t *testing.T) {
+	service := newTestClarifyingService(t, &stubQuestionSearchService{}, &config.Config{})
+
+	clarification := service.Check("สินเชื่อดอกเบี้ยเท่าไหร่")
+	if clarification == nil {
+		t.Fatal("expected a clarification")
+	}
+	if clarification.MessageTh != "คุณหมายถึงสินเชื่อบ้าน, สินเชื่อรถ หรือ สินเชื่อส่วนบุคคล?" {
+		t.Errorf("unexpected message %q", clarification.MessageTh)
+	}
+	expected := []string{"สินเชื่อบ้านดอกเบี้ยเท่าไหร่", "สินเชื่อรถดอกเบี้ยเท่าไหร่", "สินเชื่อส่วนบุคคลดอกเบี้ยเท่าไหร่"}
+	if strings.Join(clarification.Suggestions, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected suggestions %v, got %v", expected, clarification.Suggestions)
+	}
+}
+
+func TestClarification_ConfiguredTopics(t *testing.T) {
+	service := newTestClarifyingService(t, &stubQuestionSearchService{}, &config.Config{
+		ClarificationTopics: `{"บัตร": ["บัตรเครดิต", "บัตรเดบิต"]}`,
+	})
+	if clarification := service.Check("บัตรหายต้องทำอย่างไร"); clarification == nil || len(clarification.Suggestions) != 2 {
+		t.Errorf("expected the configured topic to match, got %+v", clarification)
+	}
+	if clarification := service.Check("สินเชื่อดอกเบี้ยเท่าไหร่"); clarification != nil {
+		t.Errorf("expected configured topics to replace the built-in ones, got %+v", clarification)
+	}
+
+	for _, topics := range []string{`not json`, `{"บัตร": ["บัตรเครดิต"]}`} {
+		if _, err := NewClarifyingQuestionSearchService(&stubQuestionSearchService{}, &config.Config{ClarificationTopics: topics}); err == nil {
+			t.Errorf("expected an error for topics %s", topics)
+		}
+	}
+}
+
+func TestClarification_FlagAndSkip(t *testing.T) {
+	next := &stubQuestionSearchService{answer: "answer"}
+	service := newTestClarifyingService(t, next, &config.Config{})
+	question := "สินเชื่อดอกเบี้ยเท่าไหร่"
+
+	// Without the flag every question is answered
+	if answer, _, err := service.SearchAnswer(context.Background(), question, false); err != nil || answer != "answer" {
+		t.Fatalf("expected an answer with the flag off, got %q %v", answer, err)
+	}
+
+	flags.Initialize(flags.New(time.Hour, flags.NewEnvSource(ClarificationFlag)))
+	defer flags.Initialize(nil)
+
+	_, _, err := service.SearchAnswer(context.Background(), question, false)
+	if _, ok := err.(*ClarificationRequiredError); !ok {
+		t.Fatalf("expected a clarification error, got %v", err)
+	}
+	if next.callCount != 1 {
+		t.Errorf("expected the clarified question not to reach question search, got %d calls", next.callCount)
+	}
+
+	if _, _, err := service.SearchAnswer(WithoutClarification(context.Background()), question, false); err != nil {
+		t.Errorf("expected a skipped clarification to be answered, got %v", err)
+	}
+	if next.callCount != 2 {
+		t.Errorf("expected the skipped clarification to reach question search, got %d calls", next.callCount)
+	}
+}
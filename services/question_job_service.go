@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+// QueuedQuestion is the queue message of a question answered in the background: the
+// question-search request as the caller sent it, and the identity it was authenticated as
+type QueuedQuestion struct {
+	JobId    string            `json:"jobId"`
+	Query    string            `json:"query,omitempty"` // Raw query string, e.g. enableRelateDocument=true
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     json.RawMessage   `json:"body"`
+	Identity *auth.Identity    `json:"identity,omitempty"`
+}
+
+// QuestionJobRunner answers a queued question and returns the status code and body of the
+// question-search response
+type QuestionJobRunner func(ctx context.Context, question *QueuedQuestion) (int, []byte)
+
+type QuestionJobService interface {
+	// Submit stores a queued job for the question, owned by owner, and sends it to the queue.
+	// With a callbackUrl, the job gets the secret its callback is signed with. The job is
+	// polled through the JobService.
+	Submit(ctx context.Context, owner string, question *QueuedQuestion, callbackUrl string) (*storage.Job, error)
+	// Process answers the queued question of a message and stores its response. An error asks
+	// the queue to deliver the message again; on the last attempt the job fails instead.
+	Process(ctx context.Context, message string, run QuestionJobRunner, lastAttempt bool) error
+}
+
+// QueueQuestionJobService answers questions in the background, so answers that take longer
+// than API Gateway's 30 second limit still reach the caller
+type QueueQuestionJobService struct {
+	queue     aws.QueueClient
+	queueUrl  string
+	store     storage.JobStore
+	ttl       time.Duration
+	callbacks JobCallbackNotifier // Optional, posts jobs submitted with a callback URL once finished
+}
+
+func NewQueueQuestionJobService(queue aws.QueueClient, queueUrl string, store storage.JobStore, ttl time.Duration, callbacks JobCallbackNotifier) *QueueQuestionJobService {
+	return &QueueQuestionJobService{
+		queue:     queue,
+		queueUrl:  queueUrl,
+		store:     store,
+		ttl:       ttl,
+		callbacks: callbacks,
+	}
+}
+
+func (s *QueueQuestionJobService) Submit(ctx context.Context, owner string, question *QueuedQuestion, callbackUrl string) (*storage.Job, error) {
+	callbackUrl, callbackSecret, err := newJobCallback(callbackUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := newJob(storage.JobTypeQuestion, owner, s.ttl)
+	if err != nil {
+		return nil, err
+	}
+	job.CallbackUrl = callbackUrl
+	job.CallbackSecret = callbackSecret
+	// Stored before it is sent, so the worker always finds the job of a message
+	if err := s.store.SaveJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	queued := *question
+	queued.JobId = job.JobId
+	message, err := json.Marshal(queued)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal queued question: %w", err)
+	}
+	if err := s.queue.SendMessage(ctx, s.queueUrl, string(message)); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *QueueQuestionJobService) Process(ctx context.Context, message string, run QuestionJobRunner, lastAttempt bool) error {
+	log := logger.WithContext(ctx)
+
+	var question QueuedQuestion
+	if err := json.Unmarshal([]byte(message), &question); err != nil || question.JobId == "" {
+		// Redelivering a malformed message cannot help, drop it
+		log.Error("Dropped malformed queued question", map[string]interface{}{
+			"error": fmt.Sprint(err),
+		})
+		return nil
+	}
+
+	job, err := s.store.GetJob(ctx, question.JobId)
+	if err != nil {
+		return err
+	}
+	if job == nil || job.Finished() {
+		// Expired, or a duplicate delivery of a job already answered
+		return nil
+	}
+
+	job.Status = storage.JobRunning
+	job.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveJob(ctx, job); err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	statusCode, body := run(ctx, &question)
+	if (statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable) && !lastAttempt {
+		log.Warn("Queued question throttled, retrying later", map[string]interface{}{
+			"job_id": job.JobId,
+			"status": statusCode,
+		})
+		return fmt.Errorf("question job %s answered %d", job.JobId, statusCode)
+	}
+
+	job.Status = storage.JobSucceeded
+	if statusCode < 200 || statusCode >= 300 {
+		job.Status = storage.JobFailed
+	}
+	job.StatusCode = statusCode
+	job.Result = body
+	job.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveJob(ctx, job); err != nil {
+		return err
+	}
+
+	log.Info("Queued question answered", map[string]interface{}{
+		"job_id":      job.JobId,
+		"status":      statusCode,
+		"duration_ms": time.Since(startTime).Milliseconds(),
+	})
+
+	if job.CallbackUrl != "" && s.callbacks != nil {
+		payload := JobCallbackPayload{
+			Event:      EventQuestionJobFinished,
+			JobId:      job.JobId,
+			Status:     job.Status,
+			StatusCode: job.StatusCode,
+		}
+		if json.Valid(job.Result) {
+			payload.Result = job.Result
+		}
+		s.callbacks.NotifyJobFinished(ctx, job.CallbackUrl, job.CallbackSecret, payload)
+	}
+	return nil
+}
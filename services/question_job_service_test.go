@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/storage"
+)
+
+type recordingQueueClient struct {
+	messages []string
+}
+
+func (r *recordingQueueClient) SendMessage(ctx context.Context, queueUrl string, body string) error {
+	r.messages = append(r.messages, body)
+	return nil
+}
+
+func TestQueueQuestionJobService_SubmitsAndAnswersQuestions(t *testing.T) {
+	queue := &recordingQueueClient{}
+	store := storage.NewMemoryJobStore()
+	service := NewQueueQuestionJobService(queue, "https://sqs.example/questions", store, time.Hour, nil)
+	ctx := context.Background()
+
+	job, err := service.Submit(ctx, "owner-1", &QueuedQuestion{
+		Body:     json.RawMessage(`{"question":"fee"}`),
+		Identity: &auth.Identity{UserId: "user-1"},
+	}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.Type != storage.JobTypeQuestion || job.Status != storage.JobQueued || len(queue.messages) != 1 {
+		t.Fatalf("expected a queued job and one message, got %q and %d messages", job.Status, len(queue.messages))
+	}
+
+	var ran *QueuedQuestion
+	run := func(ctx context.Context, question *QueuedQuestion) (int, []byte) {
+		ran = question
+		return http.StatusOK, []byte(`{"answer":"100 baht"}`)
+	}
+	if err := service.Process(ctx, queue.messages[0], run, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ran == nil || ran.JobId != job.JobId || string(ran.Body) != `{"question":"fee"}` || ran.Identity.UserId != "user-1" {
+		t.Fatalf("expected the queued request to run, got %+v", ran)
+	}
+
+	answered, _ := store.GetJob(ctx, job.JobId)
+	if answered == nil || answered.Status != storage.JobSucceeded || string(answered.Result) != `{"answer":"100 baht"}` {
+		t.Fatalf("expected the stored answer, got %+v", answered)
+	}
+
+	// A duplicate delivery does not answer the question again
+	ran = nil
+	if err := service.Process(ctx, queue.messages[0], run, false); err != nil || ran != nil {
+		t.Errorf("expected a finished job to be skipped, got %v", err)
+	}
+}
+
+func TestQueueQuestionJobService_RetriesThrottledQuestions(t *testing.T) {
+	queue := &recordingQueueClient{}
+	store := storage.NewMemoryJobStore()
+	service := NewQueueQuestionJobService(queue, "https://sqs.example/questions", store, time.Hour, nil)
+	ctx := context.Background()
+
+	job, _ := service.Submit(ctx, "owner-1", &QueuedQuestion{Body: json.RawMessage(`{}`)}, "")
+	throttled := func(ctx context.Context, question *QueuedQuestion) (int, []byte) {
+		return http.StatusServiceUnavailable, []byte(`{"error":"busy"}`)
+	}
+
+	if err := service.Process(ctx, queue.messages[0], throttled, false); err == nil {
+		t.Fatalf("expected a throttled question to be redelivered")
+	}
+	if pending, _ := store.GetJob(ctx, job.JobId); pending.Finished() {
+		t.Errorf("expected the job to stay pending, got %q", pending.Status)
+	}
+
+	if err := service.Process(ctx, queue.messages[0], throttled, true); err != nil {
+		t.Fatalf("expected the last attempt to store the failure, got %v", err)
+	}
+	failed, _ := store.GetJob(ctx, job.JobId)
+	if failed.Status != storage.JobFailed || failed.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected a failed job, got %q %d", failed.Status, failed.StatusCode)
+	}
+
+	if err := service.Process(ctx, "not json", throttled, false); err != nil {
+		t.Errorf("expected malformed messages to be dropped, got %v", err)
+	}
+}
+
+func TestQueueQuestionJobService_PostsCallbacks(t *testing.T) {
+	queue := &recordingQueueClient{}
+	callbacks := &recordingJobCallbacks{}
+	service := NewQueueQuestionJobService(queue, "https://sqs.example/questions", storage.NewMemoryJobStore(), time.Hour, callbacks)
+	ctx := context.Background()
+
+	if _, err := service.Submit(ctx, "owner-1", &QueuedQuestion{Body: json.RawMessage(`{}`)}, "http://portal.example.com/jobs"); err == nil || len(queue.messages) != 0 {
+		t.Fatalf("expected a plain http callback URL to be rejected, got %v", err)
+	}
+
+	job, err := service.Submit(ctx, "owner-1", &QueuedQuestion{Body: json.RawMessage(`{}`)}, " https://portal.example.com/jobs ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.CallbackUrl != "https://portal.example.com/jobs" || len(job.CallbackSecret) != 64 {
+		t.Fatalf("expected the callback URL and a 32 byte hex secret, got %q %q", job.CallbackUrl, job.CallbackSecret)
+	}
+
+	answer := func(ctx context.Context, question *QueuedQuestion) (int, []byte) {
+		return http.StatusOK, []byte(`{"answer":"100 baht"}`)
+	}
+	service.Process(ctx, queue.messages[0], answer, false)
+	service.Process(ctx, queue.messages[0], answer, false)
+	if len(callbacks.callbacks) != 1 {
+		t.Fatalf("expected a single callback, got %+v", callbacks.callbacks)
+	}
+	callback := callbacks.callbacks[0]
+	if callback.url != job.CallbackUrl || callback.secret != job.CallbackSecret || callback.payload.JobId != job.JobId ||
+		callback.payload.Status != storage.JobSucceeded || string(callback.payload.Result) != `{"answer":"100 baht"}` {
+		t.Errorf("expected the answered job to be posted, got %+v", callback)
+	}
+}
@@ -1,87 +1,192 @@
-package services
-
-import (
-	"context"
-	"time"
-
-	"teletubpax-api/aws"
-	"teletubpax-api/config"
-	"teletubpax-api/logger"
-	"teletubpax-api/utils"
-)
-
-type QuestionSearchService interface {
-	SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error)
-}
-
-type BedrockQuestionSearchService struct {
-	embeddingClient     aws.EmbeddingClient
-	knowledgeBaseClient aws.KnowledgeBaseClient
-	config              *config.Config
-}
-
-func NewBedrockQuestionSearchService(
-	embeddingClient aws.EmbeddingClient,
-	knowledgeBaseClient aws.KnowledgeBaseClient,
-	cfg *config.Config,
-) *BedrockQuestionSearchService {
-	return &BedrockQuestionSearchService{
-		embeddingClient:     embeddingClient,
-		knowledgeBaseClient: knowledgeBaseClient,
-		config:              cfg,
-	}
-}
-
-func (s *BedrockQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
-	// Log incoming request for audit
-	log := logger.WithContext(ctx)
-	log.Info("Question search request received", map[string]interface{}{
-		"question_length": len(question),
-		"question":        question,
-	})
-	startTime := time.Now()
-
-	// Query knowledge base with retry logic
-	var answer string
-	var relatedDocuments []string
-	retryConfig := utils.RetryConfig{
-		MaxAttempts:       s.config.RetryAttempts,
-		InitialBackoff:    100 * time.Millisecond,
-		BackoffMultiplier: 2.0,
-		MaxBackoff:        2 * time.Second,
-	}
-
-	err := utils.RetryWithBackoff(ctx, retryConfig, func() error {
-		// Query multiple knowledge bases in parallel
-		ans, docs, err := s.knowledgeBaseClient.QueryMultipleKnowledgeBases(ctx, question, enableRelateDocument)
-		if err != nil {
-			log.Error("Knowledge base query failed", map[string]interface{}{
-				"error": err.Error(),
-			})
-			return err
-		}
-		answer = ans
-		relatedDocuments = docs
-		return nil
-	})
-
-	if err != nil {
-		duration := time.Since(startTime)
-		log.Error("Question search failed after retries", map[string]interface{}{
-			"error":       err.Error(),
-			"duration_ms": duration.Milliseconds(),
-			"retry_count": s.config.RetryAttempts,
-		})
-		return "", nil, err
-	}
-
-	// Log successful response
-	duration := time.Since(startTime)
-	log.Info("Question search completed successfully", map[string]interface{}{
-		"duration_ms":    duration.Milliseconds(),
-		"answer_length":  len(answer),
-		"document_count": len(relatedDocuments),
-	})
-
-	return answer, relatedDocuments, nil
-}
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/normalization"
+	"teletubpax-api/policy"
+	"teletubpax-api/redaction"
+	"teletubpax-api/stages"
+	"teletubpax-api/storage"
+	"teletubpax-api/tracing"
+	"teletubpax-api/utils"
+)
+
+// notFoundRetrievalResults is how many chunks per knowledge base are retrieved to score an
+// unanswered question
+const notFoundRetrievalResults = 3
+
+type QuestionSearchService interface {
+	SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error)
+}
+
+type BedrockQuestionSearchService struct {
+	embeddingClient     aws.EmbeddingClient
+	knowledgeBaseClient aws.KnowledgeBaseClient
+	notFoundStore       storage.NotFoundStore // Optional, records unanswered questions
+	backends            *AnswerBackends       // Optional, defaults to the knowledge base backend
+	config              *config.Config
+}
+
+func NewBedrockQuestionSearchService(
+	embeddingClient aws.EmbeddingClient,
+	knowledgeBaseClient aws.KnowledgeBaseClient,
+	notFoundStore storage.NotFoundStore,
+	backends *AnswerBackends,
+	cfg *config.Config,
+) *BedrockQuestionSearchService {
+	if backends == nil {
+		backends = &AnswerBackends{
+			backends:    map[string]AnswerBackend{AnswerBackendKnowledgeBase: NewKnowledgeBaseAnswerBackend(knowledgeBaseClient, cfg)},
+			defaultName: AnswerBackendKnowledgeBase,
+		}
+	}
+	return &BedrockQuestionSearchService{
+		embeddingClient:     embeddingClient,
+		knowledgeBaseClient: knowledgeBaseClient,
+		notFoundStore:       notFoundStore,
+		backends:            backends,
+		config:              cfg,
+	}
+}
+
+func (s *BedrockQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (_ string, _ []string, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionSearchService.SearchAnswer")
+	defer func() { tracing.End(span, err) }()
+
+	// Prompt and answer "nothing found" in the language of the question
+	language := utils.DetectLanguage(question)
+	ctx = aws.WithQuestionLanguage(ctx, language)
+
+	// Log incoming request for audit
+	log := logger.WithContext(ctx)
+	log.Info("Question search request received", map[string]interface{}{
+		"question_length": len(question),
+		"question":        redaction.Redact(ctx, question),
+		"language":        language,
+	})
+	startTime := time.Now()
+
+	// Rewrite bank jargon and misspellings to the wording used in the documents
+	endNormalization := stages.Start(ctx, stages.Normalization)
+	searchQuestion, matchedTerms := normalization.Normalize(question)
+	endNormalization()
+	if len(matchedTerms) > 0 {
+		log.Info("Question normalized", map[string]interface{}{
+			"matched_terms":       matchedTerms,
+			"normalized_question": redaction.Redact(ctx, searchQuestion),
+		})
+	}
+
+	// Answer with the backend selected for the tenant or endpoint, with retry logic
+	var answer string
+	var relatedDocuments []string
+	retryConfig := policy.FromContext(ctx, policy.Defaults(s.config.RetryAttempts)).RetryConfig()
+	// Throttled Bedrock calls were already retried by the SDK, paced by the adaptive throttle
+	retryConfig.SkipThrottling = s.config.BedrockAdaptiveThrottle
+	backendName, backend := s.backends.Select(ctx)
+	span.SetAttributes(tracing.AttrAnswerBackend.String(backendName))
+
+	endAnswerBackend := stages.Start(ctx, stages.AnswerBackend)
+	err = utils.RetryWithBackoff(ctx, retryConfig, func(ctx context.Context) error {
+		ans, docs, err := backend.Answer(ctx, searchQuestion, enableRelateDocument)
+		if err != nil {
+			log.Error("Answer backend query failed", map[string]interface{}{
+				"answer_backend": backendName,
+				"error":          err.Error(),
+			})
+			return err
+		}
+		answer = ans
+		relatedDocuments = docs
+		return nil
+	})
+	endAnswerBackend()
+
+	if err != nil {
+		duration := time.Since(startTime)
+		log.Error("Question search failed after retries", map[string]interface{}{
+			"answer_backend": backendName,
+			"error":          err.Error(),
+			"duration_ms":    duration.Milliseconds(),
+			"retry_count":    retryConfig.MaxAttempts,
+		})
+		return "", nil, err
+	}
+
+	// Log successful response
+	duration := time.Since(startTime)
+	log.Info("Question search completed successfully", map[string]interface{}{
+		"answer_backend": backendName,
+		"duration_ms":    duration.Milliseconds(),
+		"answer_length":  len(answer),
+		"document_count": len(relatedDocuments),
+	})
+
+	if s.notFoundStore != nil && aws.IsNoAnswer(answer) {
+		s.recordNotFound(ctx, question, searchQuestion)
+	}
+
+	return answer, relatedDocuments, nil
+}
+
+// recordNotFound stores an unanswered question with its retrieval scores for the knowledge
+// gap report, scored with the normalized question that was actually searched. Failures are
+// only logged, analytics must never fail a user request.
+func (s *BedrockQuestionSearchService) recordNotFound(ctx context.Context, question string, searchQuestion string) {
+	log := logger.WithContext(ctx)
+	askedAt := time.Now().UTC().Truncate(time.Second)
+
+	event := &storage.NotFoundEvent{
+		Id:        askedAt.Format("20060102T150405Z") + "-" + randomSuffix(),
+		Question:  question,
+		Scores:    []storage.NotFoundScore{},
+		AskedAt:   askedAt,
+		ExpiresAt: askedAt.AddDate(0, 0, s.config.NotFoundRetentionDays).Unix(),
+	}
+
+	retrievals, err := s.knowledgeBaseClient.RetrieveFromKnowledgeBases(ctx, searchQuestion, notFoundRetrievalResults)
+	if err != nil {
+		log.Warn("Failed to score unanswered question", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	for _, retrieval := range retrievals {
+		if len(retrieval.Chunks) == 0 {
+			continue
+		}
+		// Chunks are returned best first
+		best := retrieval.Chunks[0]
+		event.Scores = append(event.Scores, storage.NotFoundScore{
+			KnowledgeBaseId: retrieval.KnowledgeBaseId,
+			Score:           best.Score,
+			SourceUrl:       best.SourceUrl,
+		})
+		if best.Score > event.TopScore {
+			event.TopScore = best.Score
+		}
+	}
+
+	if err := s.notFoundStore.RecordNotFound(ctx, event); err != nil {
+		log.Warn("Failed to record unanswered question", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	log.Info("Unanswered question recorded", map[string]interface{}{
+		"top_score": event.TopScore,
+	})
+}
+
+func randomSuffix() string {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -1,207 +1,337 @@
-package services
-
-import (
-	"context"
-	"testing"
-
-	"github.com/leanovate/gopter"
-	"github.com/leanovate/gopter/gen"
-	"github.com/leanovate/gopter/prop"
-	"teletubpax-api/config"
-)
-
-// Mock clients for testing
-type mockEmbeddingClient struct {
-	generateEmbeddingFunc func(ctx context.Context, text string) ([]float64, error)
-	callCount             int
-}
-
-func (m *mockEmbeddingClient) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
-	m.callCount++
-	if m.generateEmbeddingFunc != nil {
-		return m.generateEmbeddingFunc(ctx, text)
-	}
-	return []float64{0.1, 0.2, 0.3}, nil
-}
-
-type mockKnowledgeBaseClient struct {
-	queryKnowledgeBaseFunc func(ctx context.Context, question string, enableRelateDocument bool) (string, error)
-	callCount              int
-}
-
-func (m *mockKnowledgeBaseClient) QueryKnowledgeBase(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
-	m.callCount++
-	if m.queryKnowledgeBaseFunc != nil {
-		answer, err := m.queryKnowledgeBaseFunc(ctx, question, enableRelateDocument)
-		return answer, []string{}, err
-	}
-	return "mock answer", []string{}, nil
-}
-
-// Feature: bedrock-question-search, Property 5: Embedding vectors are sent to knowledge base
-// Validates: Requirements 3.1
-func TestEmbeddingToKBWorkflow_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("KB is queried for all valid questions", prop.ForAll(
-		func(question string) bool {
-			mockKB := &mockKnowledgeBaseClient{
-				queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-					return "answer for " + q, nil
-				},
-			}
-
-			cfg := &config.Config{
-				RetryAttempts: 3,
-			}
-
-			service := NewBedrockQuestionSearchService(nil, mockKB, cfg)
-
-			_, _, err := service.SearchAnswer(context.Background(), question, false)
-
-			// KB should be called exactly once for successful queries
-			return err == nil && mockKB.callCount == 1
-		},
-		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) <= 1000 }),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-// Feature: bedrock-question-search, Property 12: Requests are logged for audit
-// Validates: Requirements 5.4, 5.5
-func TestAuditLogging_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("all requests are processed", prop.ForAll(
-		func(question string) bool {
-			mockKB := &mockKnowledgeBaseClient{
-				queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-					return "answer", nil
-				},
-			}
-
-			cfg := &config.Config{
-				RetryAttempts: 3,
-			}
-
-			service := NewBedrockQuestionSearchService(nil, mockKB, cfg)
-
-			_, _, err := service.SearchAnswer(context.Background(), question, false)
-
-			// Service should process the request (logging happens internally)
-			return err == nil
-		},
-		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) <= 1000 }),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-// Feature: bedrock-question-search, Property 11: Errors are logged with context
-// Validates: Requirements 5.1, 5.2
-func TestErrorLogging_Property(t *testing.T) {
-	properties := gopter.NewProperties(nil)
-
-	properties.Property("errors are handled and logged", prop.ForAll(
-		func(errorMsg string) bool {
-			mockKB := &mockKnowledgeBaseClient{
-				queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-					return "", &testError{msg: errorMsg}
-				},
-			}
-
-			cfg := &config.Config{
-				RetryAttempts: 1,
-			}
-
-			service := NewBedrockQuestionSearchService(nil, mockKB, cfg)
-
-			_, _, err := service.SearchAnswer(context.Background(), "test question", false)
-
-			// Error should be returned (logging happens internally)
-			return err != nil
-		},
-		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 }),
-	))
-
-	properties.TestingRun(t, gopter.ConsoleReporter(false))
-}
-
-type testError struct {
-	msg string
-}
-
-func (e *testError) Error() string {
-	return e.msg
-}
-
-// Unit tests for service orchestration
-func TestService_SuccessfulFlow(t *testing.T) {
-	mockKB := &mockKnowledgeBaseClient{
-		queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-			return "This is the answer", nil
-		},
-	}
-
-	cfg := &config.Config{
-		RetryAttempts: 3,
-	}
-
-	service := NewBedrockQuestionSearchService(nil, mockKB, cfg)
-
-	answer, _, err := service.SearchAnswer(context.Background(), "What is the question?", false)
-
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
-	}
-	if answer != "This is the answer" {
-		t.Fatalf("expected 'This is the answer', got '%s'", answer)
-	}
-	if mockKB.callCount != 1 {
-		t.Fatalf("expected KB to be called once, got %d calls", mockKB.callCount)
-	}
-}
-
-func TestService_KBError(t *testing.T) {
-	mockKB := &mockKnowledgeBaseClient{
-		queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-			return "", &testError{msg: "KB error"}
-		},
-	}
-
-	cfg := &config.Config{
-		RetryAttempts: 1,
-	}
-
-	service := NewBedrockQuestionSearchService(nil, mockKB, cfg)
-
-	_, _, err := service.SearchAnswer(context.Background(), "test question", false)
-
-	if err == nil {
-		t.Fatal("expected error from KB, got nil")
-	}
-}
-
-func TestService_EmptyAnswer(t *testing.T) {
-	mockKB := &mockKnowledgeBaseClient{
-		queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
-			return "", nil
-		},
-	}
-
-	cfg := &config.Config{
-		RetryAttempts: 3,
-	}
-
-	service := NewBedrockQuestionSearchService(nil, mockKB, cfg)
-
-	answer, _, err := service.SearchAnswer(context.Background(), "test question", false)
-
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
-	}
-	if answer != "" {
-		t.Fatalf("expected empty answer, got '%s'", answer)
-	}
-}
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/stages"
+	"teletubpax-api/storage"
+	"teletubpax-api/utils"
+)
+
+// Mock clients for testing
+type mockEmbeddingClient struct {
+	generateEmbeddingFunc func(ctx context.Context, text string) ([]float64, error)
+	callCount             int
+}
+
+func (m *mockEmbeddingClient) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	m.callCount++
+	if m.generateEmbeddingFunc != nil {
+		return m.generateEmbeddingFunc(ctx, text)
+	}
+	return []float64{0.1, 0.2, 0.3}, nil
+}
+
+func (m *mockEmbeddingClient) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, len(texts))
+	for i, text := range texts {
+		embedding, err := m.GenerateEmbedding(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+type mockKnowledgeBaseClient struct {
+	queryKnowledgeBaseFunc func(ctx context.Context, question string, enableRelateDocument bool) (string, error)
+	retrieveFunc           func(ctx context.Context, question string, numberOfResults int) ([]aws.KnowledgeBaseRetrieval, error)
+	callCount              int
+	multiCallCount         int
+}
+
+func (m *mockKnowledgeBaseClient) QueryKnowledgeBase(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	m.callCount++
+	if m.queryKnowledgeBaseFunc != nil {
+		answer, err := m.queryKnowledgeBaseFunc(ctx, question, enableRelateDocument)
+		return answer, []string{}, err
+	}
+	return "mock answer", []string{}, nil
+}
+
+func (m *mockKnowledgeBaseClient) QueryMultipleKnowledgeBases(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	m.multiCallCount++
+	return m.QueryKnowledgeBase(ctx, question, enableRelateDocument)
+}
+
+func (m *mockKnowledgeBaseClient) RetrieveFromKnowledgeBases(ctx context.Context, question string, numberOfResults int) ([]aws.KnowledgeBaseRetrieval, error) {
+	if m.retrieveFunc != nil {
+		return m.retrieveFunc(ctx, question, numberOfResults)
+	}
+	return nil, nil
+}
+
+// Feature: bedrock-question-search, Property 5: Embedding vectors are sent to knowledge base
+// Validates: Requirements 3.1
+func TestEmbeddingToKBWorkflow_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("KB is queried for all valid questions", prop.ForAll(
+		func(question string) bool {
+			mockKB := &mockKnowledgeBaseClient{
+				queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+					return "answer for " + q, nil
+				},
+			}
+
+			cfg := &config.Config{
+				RetryAttempts: 3,
+			}
+
+			service := NewBedrockQuestionSearchService(nil, mockKB, nil, nil, cfg)
+
+			_, _, err := service.SearchAnswer(context.Background(), question, false)
+
+			// KB should be called exactly once for successful queries
+			return err == nil && mockKB.callCount == 1
+		},
+		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) <= 1000 }),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// Feature: bedrock-question-search, Property 12: Requests are logged for audit
+// Validates: Requirements 5.4, 5.5
+func TestAuditLogging_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("all requests are processed", prop.ForAll(
+		func(question string) bool {
+			mockKB := &mockKnowledgeBaseClient{
+				queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+					return "answer", nil
+				},
+			}
+
+			cfg := &config.Config{
+				RetryAttempts: 3,
+			}
+
+			service := NewBedrockQuestionSearchService(nil, mockKB, nil, nil, cfg)
+
+			_, _, err := service.SearchAnswer(context.Background(), question, false)
+
+			// Service should process the request (logging happens internally)
+			return err == nil
+		},
+		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 && len(s) <= 1000 }),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// Feature: bedrock-question-search, Property 11: Errors are logged with context
+// Validates: Requirements 5.1, 5.2
+func TestErrorLogging_Property(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("errors are handled and logged", prop.ForAll(
+		func(errorMsg string) bool {
+			mockKB := &mockKnowledgeBaseClient{
+				queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+					return "", &testError{msg: errorMsg}
+				},
+			}
+
+			cfg := &config.Config{
+				RetryAttempts: 1,
+			}
+
+			service := NewBedrockQuestionSearchService(nil, mockKB, nil, nil, cfg)
+
+			_, _, err := service.SearchAnswer(context.Background(), "test question", false)
+
+			// Error should be returned (logging happens internally)
+			return err != nil
+		},
+		gen.AlphaString().SuchThat(func(s string) bool { return len(s) > 0 }),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+type testError struct {
+	msg string
+}
+
+func (e *testError) Error() string {
+	return e.msg
+}
+
+// Unit tests for service orchestration
+func TestService_SuccessfulFlow(t *testing.T) {
+	mockKB := &mockKnowledgeBaseClient{
+		queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "This is the answer", nil
+		},
+	}
+
+	cfg := &config.Config{
+		RetryAttempts: 3,
+	}
+
+	service := NewBedrockQuestionSearchService(nil, mockKB, nil, nil, cfg)
+
+	answer, _, err := service.SearchAnswer(context.Background(), "What is the question?", false)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if answer != "This is the answer" {
+		t.Fatalf("expected 'This is the answer', got '%s'", answer)
+	}
+	if mockKB.callCount != 1 {
+		t.Fatalf("expected KB to be called once, got %d calls", mockKB.callCount)
+	}
+}
+
+func TestService_KBError(t *testing.T) {
+	mockKB := &mockKnowledgeBaseClient{
+		queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "", &testError{msg: "KB error"}
+		},
+	}
+
+	cfg := &config.Config{
+		RetryAttempts: 1,
+	}
+
+	service := NewBedrockQuestionSearchService(nil, mockKB, nil, nil, cfg)
+
+	_, _, err := service.SearchAnswer(context.Background(), "test question", false)
+
+	if err == nil {
+		t.Fatal("expected error from KB, got nil")
+	}
+}
+
+func TestService_EmptyAnswer(t *testing.T) {
+	mockKB := &mockKnowledgeBaseClient{
+		queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			return "", nil
+		},
+	}
+
+	cfg := &config.Config{
+		RetryAttempts: 3,
+	}
+
+	service := NewBedrockQuestionSearchService(nil, mockKB, nil, nil, cfg)
+
+	answer, _, err := service.SearchAnswer(context.Background(), "test question", false)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if answer != "" {
+		t.Fatalf("expected empty answer, got '%s'", answer)
+	}
+}
+
+func TestSearchAnswer_SafeModeQueriesSingleKnowledgeBase(t *testing.T) {
+	mockKB := &mockKnowledgeBaseClient{}
+	cfg := &config.Config{
+		RetryAttempts: 1,
+		SafeMode:      config.NewSafeMode(true),
+	}
+	service := NewBedrockQuestionSearchService(&mockEmbeddingClient{}, mockKB, nil, nil, cfg)
+
+	if _, _, err := service.SearchAnswer(context.Background(), "question", false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if mockKB.multiCallCount != 0 || mockKB.callCount != 1 {
+		t.Fatalf("expected a single-KB query in safe mode, got %d multi-KB and %d total queries", mockKB.multiCallCount, mockKB.callCount)
+	}
+
+	cfg.SafeMode.Set(false)
+	if _, _, err := service.SearchAnswer(context.Background(), "question", false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if mockKB.multiCallCount != 1 {
+		t.Fatalf("expected multi-KB query once safe mode is off, got %d", mockKB.multiCallCount)
+	}
+}
+
+// newStubPipeline builds the question search decorators of main.go around the stub answer
+// backend, so benchmarks measure the pipeline without AWS calls
+func newStubPipeline(b *testing.B, cfg *config.Config) QuestionSearchService {
+	b.Helper()
+	cfg.AnswerBackend = AnswerBackendStub
+	cfg.StubAnswer = "ค่าธรรมเนียมโอนเงินต่างธนาคาร 10 บาทต่อรายการ"
+	cfg.RetryAttempts = 1
+	backends, err := NewAnswerBackends(cfg, &mockKnowledgeBaseClient{}, nil, nil)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	var service QuestionSearchService = NewBedrockQuestionSearchService(&mockEmbeddingClient{}, &mockKnowledgeBaseClient{}, nil, backends, cfg)
+	service = NewCachingQuestionSearchService(service, storage.NewMemoryAnswerCache(1000), cfg)
+	clarifying, err := NewClarifyingQuestionSearchService(service, cfg)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	return clarifying
+}
+
+func BenchmarkQuestionSearch_StubBackend(b *testing.B) {
+	questions := []string{
+		"ค่าธรรมเนียมโอนเงินต่างธนาคารเท่าไหร่",
+		"เปิดบัญชีออมทรัพย์ต้องใช้เอกสารอะไรบ้าง",
+		"What is the interest rate of a fixed deposit account?",
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		service := newStubPipeline(b, &config.Config{})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			service.SearchAnswer(context.Background(), questions[i%len(questions)], true)
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		service := newStubPipeline(b, &config.Config{AnswerCacheTTLSeconds: 3600})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			service.SearchAnswer(context.Background(), questions[i%len(questions)], true)
+		}
+	})
+
+	b.Run("stage-timings", func(b *testing.B) {
+		service := newStubPipeline(b, &config.Config{})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ctx, _ := stages.WithRecorder(context.Background())
+			service.SearchAnswer(ctx, questions[i%len(questions)], true)
+		}
+	})
+}
+
+func TestSearchAnswer_DetectsQuestionLanguage(t *testing.T) {
+	var language string
+	mockKB := &mockKnowledgeBaseClient{
+		queryKnowledgeBaseFunc: func(ctx context.Context, q string, enableRelateDocument bool) (string, error) {
+			language = aws.QuestionLanguageFromContext(ctx)
+			return "answer", nil
+		},
+	}
+	service := NewBedrockQuestionSearchService(nil, mockKB, nil, nil, &config.Config{RetryAttempts: 1})
+
+	for question, want := range map[string]string{
+		"What is the annual fee of the KBank credit card?": utils.LanguageEnglish,
+		"ค่าธรรมเนียมรายปีของบัตร KBank เท่าไหร่":          utils.LanguageThai,
+	} {
+		if _, _, err := service.SearchAnswer(context.Background(), question, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if language != want {
+			t.Errorf("expected %q for %q, got %q", want, question, language)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/utils"
+	"teletubpax-api/warnings"
+)
+
+const (
+	maxRelatedQuestions          = 5   // Suggestions returned at most, the prompt asks for 3 to 5
+	relatedQuestionsResultsPerKB = 3   // Chunks retrieved from each knowledge base
+	relatedQuestionsMaxChunks    = 6   // Chunks the suggestions are generated from
+	relatedQuestionsMaxTokens    = 400 // Enough for 5 short questions in Thai
+)
+
+type RelatedQuestionsService interface {
+	// Suggest returns follow-up questions to a question in its language, generated from the
+	// documents retrieved for it. There are none when no document matches.
+	Suggest(ctx context.Context, question string) ([]string, error)
+}
+
+// BedrockRelatedQuestionsService suggests "people also asked" questions from the chunks the
+// knowledge bases retrieve for a question, with a single Converse call
+type BedrockRelatedQuestionsService struct {
+	kbClient         aws.KnowledgeBaseClient
+	generationClient aws.GenerationClient
+	config           *config.Config
+}
+
+func NewBedrockRelatedQuestionsService(kbClient aws.KnowledgeBaseClient, generationClient aws.GenerationClient, cfg *config.Config) *BedrockRelatedQuestionsService {
+	return &BedrockRelatedQuestionsService{
+		kbClient:         kbClient,
+		generationClient: generationClient,
+		config:           cfg,
+	}
+}
+
+func (s *BedrockRelatedQuestionsService) Suggest(ctx context.Context, question string) ([]string, error) {
+	log := logger.WithContext(ctx)
+
+	// Suggestions are optional, spare Bedrock during capacity incidents
+	if s.config.SafeMode.Enabled() {
+		warnings.Add(ctx, warnings.CodeSafeMode, "Safe mode is on, related questions are not suggested")
+		return []string{}, nil
+	}
+
+	retrievals, err := s.kbClient.RetrieveFromKnowledgeBases(ctx, question, relatedQuestionsResultsPerKB)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []aws.RetrievedChunk
+	failed := 0
+	for _, retrieval := range retrievals {
+		if retrieval.Error != "" {
+			failed++
+			warnings.Add(ctx, warnings.CodeKnowledgeBaseSkipped, fmt.Sprintf("Knowledge base %s skipped due to query failed, the suggestions may be incomplete", retrieval.KnowledgeBaseId))
+			continue
+		}
+		chunks = append(chunks, retrieval.Chunks...)
+	}
+	if failed > 0 && failed == len(retrievals) {
+		return nil, fmt.Errorf("all knowledge base retrievals failed: %s", retrievals[0].Error)
+	}
+	if len(chunks) == 0 {
+		return []string{}, nil
+	}
+
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].Score > chunks[j].Score
+	})
+	if len(chunks) > relatedQuestionsMaxChunks {
+		chunks = chunks[:relatedQuestionsMaxChunks]
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Question: %s\n\nLanguage: %s\n\nContext:\n", question, utils.DetectLanguage(question))
+	for i, chunk := range chunks {
+		fmt.Fprintf(&prompt, "[%d] %s\n\n", i+1, chunk.Content)
+	}
+
+	output, err := s.generationClient.Generate(ctx, s.config.Current().RelatedQuestionsInstructions, prompt.String(), relatedQuestionsMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	questions, err := parseRelatedQuestions(output, question)
+	if err != nil {
+		// A malformed suggestion list is not worth failing the request for, the chips are
+		// simply not shown
+		log.Warn("Failed to parse related questions", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return []string{}, nil
+	}
+	return questions, nil
+}
+
+// parseRelatedQuestions reads the JSON array of questions in the model output. Empty
+// questions, repeats and the original question are dropped.
+func parseRelatedQuestions(output string, question string) ([]string, error) {
+	start, end := strings.Index(output, "["), strings.LastIndex(output, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in %q", output)
+	}
+	var suggested []string
+	if err := json.Unmarshal([]byte(output[start:end+1]), &suggested); err != nil {
+		return nil, fmt.Errorf("invalid JSON array: %w", err)
+	}
+
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(question)): true}
+	questions := []string{}
+	for _, suggestion := range suggested {
+		suggestion = strings.TrimSpace(suggestion)
+		key := strings.ToLower(suggestion)
+		if suggestion == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		questions = append(questions, suggestion)
+		if len(questions) == maxRelatedQuestions {
+			break
+		}
+	}
+	return questions, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/warnings"
+)
+
+func TestRelatedQuestions_Suggest(t *testing.T) {
+	kbClient := &mockKnowledgeBaseClient{
+		retrieveFunc: func(ctx context.Context, question string, numberOfResults int) ([]aws.KnowledgeBaseRetrieval, error) {
+			return []aws.KnowledgeBaseRetrieval{
+				{KnowledgeBaseId: "KB1", Chunks: []aws.RetrievedChunk{{Content: "ค่าธรรมเนียมรายปี 200 บาท", Score: 0.4}}},
+				{KnowledgeBaseId: "KB2", Chunks: []aws.RetrievedChunk{{Content: "ยกเว้นค่าธรรมเนียมปีแรก", Score: 0.9}}},
+			}, nil
+		},
+	}
+	generation := &recordingGenerationClient{answer: `Suggestions: ["บัตรเดบิตยกเว้นค่าธรรมเนียมปีแรกไหม", " ", "ค่าธรรมเนียมบัตรเดบิตเท่าไหร่", "บัตรเดบิตยกเว้นค่าธรรมเนียมปีแรกไหม", "ขอใบแทนบัตรต้องใช้อะไร"]`}
+	service := NewBedrockRelatedQuestionsService(kbClient, generation, &config.Config{SafeMode: config.NewSafeMode(false)})
+
+	questions, err := service.Suggest(context.Background(), "ค่าธรรมเนียมบัตรเดบิตเท่าไหร่")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"บัตรเดบิตยกเว้นค่าธรรมเนียมปีแรกไหม", "ขอใบแทนบัตรต้องใช้อะไร"}
+	if !reflect.DeepEqual(questions, want) {
+		t.Errorf("expected blanks, repeats and the question itself dropped, got %q", questions)
+	}
+	if !strings.Contains(generation.userMessage, "Language: th") {
+		t.Errorf("expected the question's language in the prompt, got %q", generation.userMessage)
+	}
+	if strings.Index(generation.userMessage, "ยกเว้น") > strings.Index(generation.userMessage, "200 บาท") {
+		t.Errorf("expected the best chunk first, got %q", generation.userMessage)
+	}
+}
+
+func TestRelatedQuestions_WithoutSuggestions(t *testing.T) {
+	retrieved := []aws.KnowledgeBaseRetrieval{{KnowledgeBaseId: "KB1", Chunks: []aws.RetrievedChunk{{Content: "content"}}}}
+	kbClient := &mockKnowledgeBaseClient{
+		retrieveFunc: func(ctx context.Context, question string, numberOfResults int) ([]aws.KnowledgeBaseRetrieval, error) {
+			return retrieved, nil
+		},
+	}
+	generation := &recordingGenerationClient{answer: "I cannot suggest anything"}
+	cfg := &config.Config{SafeMode: config.NewSafeMode(false)}
+	service := NewBedrockRelatedQuestionsService(kbClient, generation, cfg)
+
+	// Output without a question list
+	if questions, err := service.Suggest(context.Background(), "question"); err != nil || len(questions) != 0 {
+		t.Errorf("expected no suggestions, got %q %v", questions, err)
+	}
+
+	// No document matches
+	retrieved = []aws.KnowledgeBaseRetrieval{{KnowledgeBaseId: "KB1"}}
+	generation.userMessage = ""
+	if questions, err := service.Suggest(context.Background(), "question"); err != nil || len(questions) != 0 || generation.userMessage != "" {
+		t.Errorf("expected no suggestions without generation, got %q %v", questions, err)
+	}
+
+	// Every knowledge base failed
+	retrieved = []aws.KnowledgeBaseRetrieval{{KnowledgeBaseId: "KB1", Error: "timeout"}}
+	if _, err := service.Suggest(context.Background(), "question"); err == nil {
+		t.Error("expected an error when every retrieval failed")
+	}
+
+	// Safe mode
+	cfg.SafeMode.Set(true)
+	ctx, collected := warnings.WithCollector(context.Background())
+	if questions, err := service.Suggest(ctx, "question"); err != nil || len(questions) != 0 {
+		t.Errorf("expected no suggestions in safe mode, got %q %v", questions, err)
+	}
+	if list := collected.List(); len(list) != 1 || list[0].Code != warnings.CodeSafeMode {
+		t.Errorf("expected a safe mode warning, got %+v", list)
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/redaction"
+)
+
+const (
+	defaultDiagnosticsResults = 5   // Same depth as the related-documents lookup
+	maxDiagnosticsResults     = 100 // Retrieve API maximum
+)
+
+// RetrievalDiagnostics is the retrieval stage output for a question, without generation
+type RetrievalDiagnostics struct {
+	Question        string                       `json:"question"`
+	NumberOfResults int                          `json:"numberOfResults"`
+	KnowledgeBases  []aws.KnowledgeBaseRetrieval `json:"knowledgeBases"`
+	DurationMs      int64                        `json:"durationMs"`
+}
+
+type RetrievalDiagnosticsService interface {
+	Diagnose(ctx context.Context, question string, numberOfResults int) (*RetrievalDiagnostics, error)
+}
+
+type BedrockRetrievalDiagnosticsService struct {
+	knowledgeBaseClient aws.KnowledgeBaseClient
+	config              *config.Config
+}
+
+func NewBedrockRetrievalDiagnosticsService(knowledgeBaseClient aws.KnowledgeBaseClient, cfg *config.Config) *BedrockRetrievalDiagnosticsService {
+	return &BedrockRetrievalDiagnosticsService{
+		knowledgeBaseClient: knowledgeBaseClient,
+		config:              cfg,
+	}
+}
+
+func (s *BedrockRetrievalDiagnosticsService) Diagnose(ctx context.Context, question string, numberOfResults int) (*RetrievalDiagnostics, error) {
+	log := logger.WithContext(ctx)
+	startTime := time.Now()
+
+	if numberOfResults <= 0 {
+		numberOfResults = defaultDiagnosticsResults
+	}
+	if numberOfResults > maxDiagnosticsResults {
+		numberOfResults = maxDiagnosticsResults
+	}
+
+	retrievals, err := s.knowledgeBaseClient.RetrieveFromKnowledgeBases(ctx, question, numberOfResults)
+	if err != nil {
+		log.Error("Retrieval diagnostics failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	duration := time.Since(startTime)
+	for _, retrieval := range retrievals {
+		if retrieval.Error != "" {
+			log.Warn("Knowledge base retrieval failed during diagnostics", map[string]interface{}{
+				"knowledge_base_id": retrieval.KnowledgeBaseId,
+				"error":             retrieval.Error,
+			})
+		}
+	}
+	log.Info("Retrieval diagnostics completed", map[string]interface{}{
+		"question":        redaction.Redact(ctx, question),
+		"knowledge_bases": len(retrievals),
+		"duration_ms":     duration.Milliseconds(),
+	})
+
+	return &RetrievalDiagnostics{
+		Question:        question,
+		NumberOfResults: numberOfResults,
+		KnowledgeBases:  retrievals,
+		DurationMs:      duration.Milliseconds(),
+	}, nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+)
+
+func TestRetrievalDiagnostics_ClampsNumberOfResults(t *testing.T) {
+	tests := []struct {
+		requested int
+		expected  int
+	}{
+		{requested: 0, expected: 5},
+		{requested: 20, expected: 20},
+		{requested: 500, expected: 100},
+	}
+
+	for _, tt := range tests {
+		var received int
+		mockKB := &mockKnowledgeBaseClient{
+			retrieveFunc: func(ctx context.Context, question string, numberOfResults int) ([]aws.KnowledgeBaseRetrieval, error) {
+				received = numberOfResults
+				return []aws.KnowledgeBaseRetrieval{{KnowledgeBaseId: "kb-1"}}, nil
+			},
+		}
+		service := NewBedrockRetrievalDiagnosticsService(mockKB, &config.Config{})
+
+		diagnostics, err := service.Diagnose(context.Background(), "question", tt.requested)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if received != tt.expected || diagnostics.NumberOfResults != tt.expected {
+			t.Fatalf("requested %d: expected %d results, got %d (reported %d)", tt.requested, tt.expected, received, diagnostics.NumberOfResults)
+		}
+		if len(diagnostics.KnowledgeBases) != 1 {
+			t.Fatalf("expected 1 knowledge base in diagnostics, got %d", len(diagnostics.KnowledgeBases))
+		}
+	}
+}
+
+func TestRetrievalDiagnostics_KeepsPerKnowledgeBaseErrors(t *testing.T) {
+	mockKB := &mockKnowledgeBaseClient{
+		retrieveFunc: func(ctx context.Context, question string, numberOfResults int) ([]aws.KnowledgeBaseRetrieval, error) {
+			return []aws.KnowledgeBaseRetrieval{
+				{KnowledgeBaseId: "kb-1", Chunks: []aws.RetrievedChunk{{Content: "chunk", Score: 0.8}}},
+				{KnowledgeBaseId: "kb-2", Error: "access denied"},
+			}, nil
+		},
+	}
+	service := NewBedrockRetrievalDiagnosticsService(mockKB, &config.Config{})
+
+	diagnostics, err := service.Diagnose(context.Background(), "question", 5)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if diagnostics.KnowledgeBases[1].Error != "access denied" {
+		t.Fatalf("expected kb-2 error to be reported, got %+v", diagnostics.KnowledgeBases[1])
+	}
+}
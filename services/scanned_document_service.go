@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/utils"
+)
+
+// maxScannedPdfBytes bounds the download of a PDF checked for a text layer
+const maxScannedPdfBytes = 50 << 20
+
+// Outcomes of processing an uploaded object
+const (
+	ScannedDocumentSkipped   = "skipped"    // Not a PDF, or a PDF with a text layer
+	ScannedDocumentUpToDate  = "up-to-date" // The text was already extracted after the PDF was written
+	ScannedDocumentExtracted = "extracted"
+)
+
+// ScannedDocumentResult is what was done with an uploaded object
+type ScannedDocumentResult struct {
+	Status         string
+	TextUri        string // The s3:// URI of the extracted text, empty when skipped
+	Pages          int    // Pages with text found by OCR
+	IngestionJobId string // The sync started after the text was written, empty without one
+}
+
+type ScannedDocumentService interface {
+	// ProcessObject runs OCR on an uploaded PDF without a text layer, writes the text next to
+	// it and syncs the knowledge base. Other objects are skipped.
+	ProcessObject(ctx context.Context, bucket string, key string) (*ScannedDocumentResult, error)
+}
+
+// TextractScannedDocumentService makes scanned PDFs retrievable: the knowledge base finds no
+// text in them, so their text is extracted with Textract and written as <name>.txt next to
+// the PDF before the data source is synced. The PDF's metadata file is copied for the text,
+// with a sourceDocument attribute linking back to the PDF, so metadata filters and access
+// rules apply to both alike.
+type TextractScannedDocumentService struct {
+	reader            aws.ObjectReaderClient
+	writer            aws.ObjectWriterClient
+	textDetection     aws.TextDetectionClient
+	ingestion         IngestionService // Optional, the next scheduled sync picks the text up when nil
+	knowledgeBaseId   string
+	dataSourceId      string
+	minTextCharacters int
+}
+
+// NewTextractScannedDocumentService syncs syncDataSource, <knowledge base ID>/<data source ID>,
+// after writing a text; empty for none
+func NewTextractScannedDocumentService(
+	reader aws.ObjectReaderClient,
+	writer aws.ObjectWriterClient,
+	textDetection aws.TextDetectionClient,
+	ingestion IngestionService,
+	syncDataSource string,
+	minTextCharacters int,
+) *TextractScannedDocumentService {
+	knowledgeBaseId, dataSourceId, _ := strings.Cut(syncDataSource, "/")
+	return &TextractScannedDocumentService{
+		reader:            reader,
+		writer:            writer,
+		textDetection:     textDetection,
+		ingestion:         ingestion,
+		knowledgeBaseId:   knowledgeBaseId,
+		dataSourceId:      dataSourceId,
+		minTextCharacters: minTextCharacters,
+	}
+}
+
+func (s *TextractScannedDocumentService) ProcessObject(ctx context.Context, bucket string, key string) (*ScannedDocumentResult, error) {
+	log := logger.WithContext(ctx)
+	startTime := time.Now()
+	s3Uri := "s3://" + bucket + "/" + key
+
+	if !strings.EqualFold(path.Ext(key), ".pdf") {
+		return &ScannedDocumentResult{Status: ScannedDocumentSkipped}, nil
+	}
+	textKey := strings.TrimSuffix(key, path.Ext(key)) + ".txt"
+	result := &ScannedDocumentResult{TextUri: "s3://" + bucket + "/" + textKey}
+
+	pdf, err := s.reader.GetObject(ctx, bucket, key, maxScannedPdfBytes)
+	if err != nil {
+		return nil, err
+	}
+	if pdf == nil {
+		// Deleted since it was uploaded
+		return &ScannedDocumentResult{Status: ScannedDocumentSkipped}, nil
+	}
+
+	// A retried event finds the text already written, and only the sync is left to do
+	textModified, err := s.reader.ObjectLastModified(ctx, bucket, textKey)
+	if err != nil {
+		return nil, err
+	}
+	if textModified != nil && !textModified.Before(pdf.LastModified) {
+		result.Status = ScannedDocumentUpToDate
+	} else {
+		// A PDF the parser cannot open may still be a readable scan, so it is sent to OCR
+		text, err := utils.ExtractDocumentText(key, pdf.Body)
+		if err == nil && utf8.RuneCountInString(strings.Join(strings.Fields(text), "")) >= s.minTextCharacters {
+			return &ScannedDocumentResult{Status: ScannedDocumentSkipped}, nil
+		}
+
+		pages, err := s.textDetection.DetectDocumentText(ctx, s3Uri)
+		if err != nil {
+			log.Error("Failed to extract the text of a scanned PDF", map[string]interface{}{
+				"uri":         s3Uri,
+				"error":       err.Error(),
+				"duration_ms": time.Since(startTime).Milliseconds(),
+			})
+			return nil, err
+		}
+		for _, page := range pages {
+			if strings.TrimSpace(page) != "" {
+				result.Pages++
+			}
+		}
+		if result.Pages == 0 {
+			// A blank or photo-only PDF, there is nothing to index
+			return &ScannedDocumentResult{Status: ScannedDocumentSkipped}, nil
+		}
+		if err := s.writeText(ctx, bucket, key, textKey, pages); err != nil {
+			return nil, err
+		}
+		result.Status = ScannedDocumentExtracted
+	}
+
+	if s.ingestion != nil && s.knowledgeBaseId != "" {
+		// A sync already running may have listed the bucket before the text was written, so
+		// the error fails the event for Lambda to retry it
+		job, err := s.ingestion.StartSync(ctx, s.knowledgeBaseId, s.dataSourceId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sync the text of %s: %w", s3Uri, err)
+		}
+		result.IngestionJobId = job.IngestionJobId
+	}
+
+	log.Info("Scanned PDF processed", map[string]interface{}{
+		"uri":              s3Uri,
+		"status":           result.Status,
+		"text_uri":         result.TextUri,
+		"pages":            result.Pages,
+		"ingestion_job_id": result.IngestionJobId,
+		"duration_ms":      time.Since(startTime).Milliseconds(),
+	})
+	return result, nil
+}
+
+// writeText writes the metadata file of the text, and then the text with its pages separated
+// by a blank line. The text goes last, so it is never synced without its access attributes
+// and a text that exists is complete; the knowledge base skips a metadata file on its own.
+func (s *TextractScannedDocumentService) writeText(ctx context.Context, bucket string, key string, textKey string, pages []string) error {
+	attributes := map[string]json.RawMessage{}
+	metadata, err := s.reader.GetObject(ctx, bucket, key+".metadata.json", maxScannedPdfBytes)
+	if err != nil {
+		return err
+	}
+	if metadata != nil {
+		var file struct {
+			MetadataAttributes map[string]json.RawMessage `json:"metadataAttributes"`
+		}
+		if err := json.Unmarshal(metadata.Body, &file); err != nil {
+			return fmt.Errorf("invalid metadata file of s3://%s/%s: %w", bucket, key, err)
+		}
+		if file.MetadataAttributes != nil {
+			attributes = file.MetadataAttributes
+		}
+	}
+	source, err := json.Marshal("s3://" + bucket + "/" + key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal source document: %w", err)
+	}
+	attributes["sourceDocument"] = source
+
+	body, err := json.Marshal(map[string]interface{}{"metadataAttributes": attributes})
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata file: %w", err)
+	}
+	if err := s.writer.PutObject(ctx, bucket, textKey+".metadata.json", body, "application/json"); err != nil {
+		return err
+	}
+
+	text := []string{}
+	for _, page := range pages {
+		if page = strings.TrimSpace(page); page != "" {
+			text = append(text, page)
+		}
+	}
+	return s.writer.PutObject(ctx, bucket, textKey, []byte(strings.Join(text, "\n\n")), "text/plain; charset=utf-8")
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"teletubpax-api/aws"
+)
+
+type memoryObjects struct {
+	objects map[string]aws.StoredObject // By bucket/key
+}
+
+func (m *memoryObjects) GetObject(ctx context.Context, bucket string, key string, maxBytes int64) (*aws.StoredObject, error) {
+	object, ok := m.objects[bucket+"/"+key]
+	if !ok {
+		return nil, nil
+	}
+	return &object, nil
+}
+
+func (m *memoryObjects) ObjectLastModified(ctx context.Context, bucket string, key string) (*time.Time, error) {
+	object, ok := m.objects[bucket+"/"+key]
+	if !ok {
+		return nil, nil
+	}
+	return &object.LastModified, nil
+}
+
+func (m *memoryObjects) PutObject(ctx context.Context, bucket string, key string, body []byte, contentType string) error {
+	m.objects[bucket+"/"+key] = aws.StoredObject{Body: body, LastModified: time.Now()}
+	return nil
+}
+
+type mockTextDetection struct {
+	pages    []string
+	detected []string
+}
+
+func (m *mockTextDetection) DetectDocumentText(ctx context.Context, s3Uri string) ([]string, error) {
+	m.detected = append(m.detected, s3Uri)
+	return m.pages, nil
+}
+
+// scannedUpload is a bucket holding a scanned PDF the text parser cannot read, with its
+// metadata file
+func scannedUpload() *memoryObjects {
+	uploaded := time.Now().Add(-time.Minute)
+	return &memoryObjects{objects: map[string]aws.StoredObject{
+		"docs/circulars/2025/05/branch-hours-1.pdf": {Body: []byte("%PDF-1.4 scanned"), LastModified: uploaded},
+		"docs/circulars/2025/05/branch-hours-1.pdf.metadata.json": {
+			Body:         []byte(`{"metadataAttributes":{"tenant":"retail"}}`),
+			LastModified: uploaded,
+		},
+	}}
+}
+
+func TestProcessObject_ExtractsScannedPdf(t *testing.T) {
+	objects := scannedUpload()
+	textDetection := &mockTextDetection{pages: []string{"เวลาทำการสาขา\n08:30 - 15:30", "", "ยกเว้นวันหยุดนักขัตฤกษ์"}}
+	ingestionClient := newMockIngestionClient()
+	service := NewTextractScannedDocumentService(objects, objects, textDetection,
+		NewBedrockIngestionService(ingestionClient, knowledgeBaseIds("kb-2"), nil, 0), "kb-2/ds-2", 100)
+
+	result, err := service.ProcessObject(context.Background(), "docs", "circulars/2025/05/branch-hours-1.pdf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != ScannedDocumentExtracted || result.Pages != 2 || result.IngestionJobId != "job-new" {
+		t.Errorf("expected the text extracted from 2 pages and synced, got %+v", result)
+	}
+	if result.TextUri != "s3://docs/circulars/2025/05/branch-hours-1.txt" {
+		t.Errorf("expected the text next to the PDF, got %s", result.TextUri)
+	}
+
+	text := string(objects.objects["docs/circulars/2025/05/branch-hours-1.txt"].Body)
+	if text != "เวลาทำการสาขา\n08:30 - 15:30\n\nยกเว้นวันหยุดนักขัตฤกษ์" {
+		t.Errorf("unexpected text %q", text)
+	}
+	metadata := string(objects.objects["docs/circulars/2025/05/branch-hours-1.txt.metadata.json"].Body)
+	if !strings.Contains(metadata, `"tenant":"retail"`) || !strings.Contains(metadata, `"sourceDocument":"s3://docs/circulars/2025/05/branch-hours-1.pdf"`) {
+		t.Errorf("expected the PDF's attributes and its URI in the metadata, got %s", metadata)
+	}
+	if len(ingestionClient.started) != 1 {
+		t.Errorf("expected one sync, got %v", ingestionClient.started)
+	}
+}
+
+func TestProcessObject_SkipsOtherObjects(t *testing.T) {
+	objects := scannedUpload()
+	textDetection := &mockTextDetection{pages: []string{"text"}}
+	service := NewTextractScannedDocumentService(objects, objects, textDetection, nil, "", 100)
+
+	for _, key := range []string{"circulars/2025/05/branch-hours-1.pdf.metadata.json", "circulars/notes.txt", "circulars/missing.pdf"} {
+		result, err := service.ProcessObject(context.Background(), "docs", key)
+		if err != nil || result.Status != ScannedDocumentSkipped {
+			t.Errorf("expected %s to be skipped, got %+v %v", key, result, err)
+		}
+	}
+	if len(textDetection.detected) != 0 {
+		t.Errorf("expected no OCR, got %v", textDetection.detected)
+	}
+}
+
+func TestProcessObject_SkipsBlankScans(t *testing.T) {
+	objects := scannedUpload()
+	service := NewTextractScannedDocumentService(objects, objects, &mockTextDetection{pages: []string{" ", ""}}, nil, "", 100)
+
+	result, err := service.ProcessObject(context.Background(), "docs", "circulars/2025/05/branch-hours-1.pdf")
+	if err != nil || result.Status != ScannedDocumentSkipped {
+		t.Errorf("expected a blank scan to be skipped, got %+v %v", result, err)
+	}
+	if _, ok := objects.objects["docs/circulars/2025/05/branch-hours-1.txt"]; ok {
+		t.Error("expected no text to be written")
+	}
+}
+
+func TestProcessObject_RetryOnlySyncs(t *testing.T) {
+	objects := scannedUpload()
+	textDetection := &mockTextDetection{pages: []string{"เวลาทำการสาขา"}}
+	ingestionClient := newMockIngestionClient() // ds-1 is syncing
+	service := NewTextractScannedDocumentService(objects, objects, textDetection,
+		NewBedrockIngestionService(ingestionClient, knowledgeBaseIds("kb-1"), nil, 0), "kb-1/ds-1", 100)
+
+	// The running sync may have missed the text, so the event fails to be retried
+	_, err := service.ProcessObject(context.Background(), "docs", "circulars/2025/05/branch-hours-1.pdf")
+	if !stdErrors.Is(err, aws.ErrIngestionJobRunning) {
+		t.Fatalf("expected the running sync to fail the event, got %v", err)
+	}
+
+	ingestionClient.jobs["ds-1"][0].Status = "COMPLETE"
+	result, err := service.ProcessObject(context.Background(), "docs", "circulars/2025/05/branch-hours-1.pdf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != ScannedDocumentUpToDate || result.IngestionJobId != "job-new" {
+		t.Errorf("expected the retry to only sync, got %+v", result)
+	}
+	if len(textDetection.detected) != 1 {
+		t.Errorf("expected a single OCR, got %v", textDetection.detected)
+	}
+}
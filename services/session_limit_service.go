@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+	"teletubpax-api/utils"
+)
+
+const (
+	SessionLimitQuestions = "questions" // Too many questions in the current minute
+	SessionLimitTokens    = "tokens"    // Session token budget used up
+)
+
+// SessionLimitError is returned when a session exceeds its question rate or token budget
+type SessionLimitError struct {
+	Limit             string
+	RetryAfterSeconds int
+}
+
+func (e *SessionLimitError) Error() string {
+	return fmt.Sprintf("session %s limit exceeded", e.Limit)
+}
+
+type sessionIdKey struct{}
+
+// WithSessionId attaches the caller's session to the context for session limits
+func WithSessionId(ctx context.Context, sessionId string) context.Context {
+	return context.WithValue(ctx, sessionIdKey{}, sessionId)
+}
+
+// SessionIdFromContext returns the caller's session, empty when there is none
+func SessionIdFromContext(ctx context.Context) string {
+	sessionId, _ := ctx.Value(sessionIdKey{}).(string)
+	return sessionId
+}
+
+// SessionLimitedQuestionSearchService enforces per-session limits in front of question search:
+// a maximum number of questions per minute and a token budget per session window, counting
+// the estimated tokens of questions and answers. Counter failures are logged and let the
+// request through, the limits must not take question search down.
+type SessionLimitedQuestionSearchService struct {
+	next     QuestionSearchService
+	counters storage.CounterStore
+	config   *config.Config
+}
+
+func NewSessionLimitedQuestionSearchService(next QuestionSearchService, counters storage.CounterStore, cfg *config.Config) *SessionLimitedQuestionSearchService {
+	return &SessionLimitedQuestionSearchService{
+		next:     next,
+		counters: counters,
+		config:   cfg,
+	}
+}
+
+func (s *SessionLimitedQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	sessionId := SessionIdFromContext(ctx)
+	if sessionId == "" {
+		return s.next.SearchAnswer(ctx, question, enableRelateDocument)
+	}
+	log := logger.WithContext(ctx)
+	now := time.Now()
+
+	if limit := s.config.SessionMaxQuestionsPerMinute; limit > 0 {
+		minute := now.Truncate(time.Minute)
+		key := fmt.Sprintf("session-questions#%s#%d", sessionId, minute.Unix())
+		count, err := s.counters.Increment(ctx, key, 1, minute.Add(2*time.Minute))
+		if err != nil {
+			log.Warn("Failed to count session questions", map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else if count > int64(limit) {
+			log.Warn("Session question rate limit exceeded", map[string]interface{}{
+				"session_id": sessionId,
+				"count":      count,
+			})
+			return "", nil, &SessionLimitError{
+				Limit:             SessionLimitQuestions,
+				RetryAfterSeconds: int(minute.Add(time.Minute).Sub(now).Seconds()) + 1,
+			}
+		}
+	}
+
+	tokenKey := "session-tokens#" + sessionId
+	window := time.Duration(s.config.SessionWindowMinutes) * time.Minute
+	if limit := s.config.SessionMaxTokens; limit > 0 {
+		used, err := s.counters.Increment(ctx, tokenKey, 0, now.Add(window))
+		if err != nil {
+			log.Warn("Failed to read session token usage", map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else if used >= int64(limit) {
+			log.Warn("Session token budget exceeded", map[string]interface{}{
+				"session_id": sessionId,
+				"tokens":     used,
+			})
+			return "", nil, &SessionLimitError{
+				Limit:             SessionLimitTokens,
+				RetryAfterSeconds: int(window.Seconds()),
+			}
+		}
+	}
+
+	answer, relatedDocuments, err := s.next.SearchAnswer(ctx, question, enableRelateDocument)
+	if err != nil {
+		return answer, relatedDocuments, err
+	}
+
+	if s.config.SessionMaxTokens > 0 {
+		tokens := utils.EstimateTokens(question) + utils.EstimateTokens(answer)
+		if _, err := s.counters.Increment(ctx, tokenKey, int64(tokens), now.Add(window)); err != nil {
+			log.Warn("Failed to record session token usage", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	return answer, relatedDocuments, nil
+}
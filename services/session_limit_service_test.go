@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type stubQuestionSearchService struct {
+	answer    string
+	callCount int
+}
+
+func (s *stubQuestionSearchService) SearchAnswer(ctx context.Context, question string, enableRelateDocument bool) (string, []string, error) {
+	s.callCount++
+	return s.answer, nil, nil
+}
+
+func TestSessionLimits_QuestionsPerMinute(t *testing.T) {
+	next := &stubQuestionSearchService{answer: "answer"}
+	cfg := &config.Config{SessionMaxQuestionsPerMinute: 2, SessionWindowMinutes: 60}
+	service := NewSessionLimitedQuestionSearchService(next, storage.NewMemoryCounterStore(), cfg)
+
+	ctx := WithSessionId(context.Background(), "id:abc")
+	for i := 0; i < 2; i++ {
+		if _, _, err := service.SearchAnswer(ctx, "question", false); err != nil {
+			t.Fatalf("question %d: unexpected error: %v", i+1, err)
+		}
+	}
+
+	_, _, err := service.SearchAnswer(ctx, "question", false)
+	limitErr, ok := err.(*SessionLimitError)
+	if !ok || limitErr.Limit != SessionLimitQuestions || limitErr.RetryAfterSeconds <= 0 || limitErr.RetryAfterSeconds > 61 {
+		t.Fatalf("expected a question rate limit error, got %v", err)
+	}
+	if next.callCount != 2 {
+		t.Errorf("expected the limited question not to reach question search, got %d calls", next.callCount)
+	}
+
+	// Other sessions and requests without a session are not affected
+	if _, _, err := service.SearchAnswer(WithSessionId(context.Background(), "id:other"), "question", false); err != nil {
+		t.Errorf("expected another session to pass, got %v", err)
+	}
+	if _, _, err := service.SearchAnswer(context.Background(), "question", false); err != nil {
+		t.Errorf("expected a request without session to pass, got %v", err)
+	}
+}
+
+func TestSessionLimits_TokenBudget(t *testing.T) {
+	next := &stubQuestionSearchService{answer: strings.Repeat("ก", 300)} // About 100 tokens
+	cfg := &config.Config{SessionMaxTokens: 150, SessionWindowMinutes: 60}
+	service := NewSessionLimitedQuestionSearchService(next, storage.NewMemoryCounterStore(), cfg)
+
+	ctx := WithSessionId(context.Background(), "id:abc")
+	for i := 0; i < 2; i++ {
+		if _, _, err := service.SearchAnswer(ctx, "question", false); err != nil {
+			t.Fatalf("question %d: unexpected error: %v", i+1, err)
+		}
+	}
+
+	_, _, err := service.SearchAnswer(ctx, "question", false)
+	limitErr, ok := err.(*SessionLimitError)
+	if !ok || limitErr.Limit != SessionLimitTokens || limitErr.RetryAfterSeconds != 3600 {
+		t.Fatalf("expected a token budget error, got %v", err)
+	}
+}
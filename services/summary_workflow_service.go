@@ -0,0 +1,552 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+// Tasks of the bulk summarization state machine: fetch, then summarize and compare every
+// chunk, then aggregate. Fail is the state machine's catch.
+const (
+	SummaryTaskFetch     = "fetch"
+	SummaryTaskSummarize = "summarize"
+	SummaryTaskCompare   = "compare"
+	SummaryTaskAggregate = "aggregate"
+	SummaryTaskFail      = "fail"
+)
+
+const (
+	defaultMaxSummaryJobDocuments = 200 // Applies when SummaryJobMaxDocuments is not configured
+	defaultSummaryJobChunkSize    = 10  // Applies when SummaryJobChunkSize is not configured
+)
+
+// SummaryWorkflowTask is the input of every task of the state machine, and the output of
+// the summarize and compare tasks
+type SummaryWorkflowTask struct {
+	Task  string `json:"summaryWorkflowTask"`
+	JobId string `json:"jobId"`
+	Chunk int    `json:"chunk"`
+	Error string `json:"error,omitempty"` // Of the fail task, the cause caught by the state machine
+}
+
+// SummaryWorkflowPlan is the output of the fetch task, the state machine maps over its chunks
+type SummaryWorkflowPlan struct {
+	JobId  string `json:"jobId"`
+	Chunks []int  `json:"chunks"`
+}
+
+// SummaryJobResult is the stored result of a completed job, shaped like a summary-document
+// response
+type SummaryJobResult struct {
+	Documents        []DocumentSummaryItem `json:"documents"`
+	Total            int                   `json:"total"`
+	InvalidDocuments []InvalidDocument     `json:"invalidDocuments"`
+}
+
+type SummaryWorkflowService interface {
+	// Submit validates the links, stores a queued job owned by owner and starts the workflow.
+	// With a callbackUrl, the job gets the secret its callback is signed with.
+	Submit(ctx context.Context, owner string, documentUrls []string, callbackUrl string) (*storage.SummaryJob, error)
+	// Get returns nil for unknown and expired jobs, and for jobs of another owner
+	Get(ctx context.Context, jobId string, owner string) (*storage.SummaryJob, error)
+	// RunTask runs one task of the state machine and returns its output. An error fails the
+	// task, which the state machine retries before failing the job.
+	RunTask(ctx context.Context, task SummaryWorkflowTask) (interface{}, error)
+}
+
+// StepFunctionsSummaryWorkflowService summarizes batches too large for summary-document
+// with a Step Functions state machine. Documents are chunked by topic, so every version of
+// a topic is compared within its chunk, and the chunks are summarized in parallel by the
+// state machine's map. Summaries follow the summary-document rules: precomputed, then
+// content-based, then from the metadata.
+type StepFunctionsSummaryWorkflowService struct {
+	workflow        aws.WorkflowClient
+	stateMachineArn string
+	store           storage.SummaryJobStore
+	jobs            storage.JobStore // Optional, mirrors the jobs for /jobs/{id}
+	summaries       *BedrockDocumentSummaryService
+	callbacks       JobCallbackNotifier // Optional, posts jobs submitted with a callback URL once finished
+	config          *config.Config
+}
+
+func NewStepFunctionsSummaryWorkflowService(
+	workflow aws.WorkflowClient,
+	stateMachineArn string,
+	store storage.SummaryJobStore,
+	jobs storage.JobStore,
+	summaries *BedrockDocumentSummaryService,
+	callbacks JobCallbackNotifier,
+	cfg *config.Config,
+) *StepFunctionsSummaryWorkflowService {
+	return &StepFunctionsSummaryWorkflowService{
+		workflow:        workflow,
+		stateMachineArn: stateMachineArn,
+		store:           store,
+		jobs:            jobs,
+		summaries:       summaries,
+		callbacks:       callbacks,
+		config:          cfg,
+	}
+}
+
+func (s *StepFunctionsSummaryWorkflowService) Submit(ctx context.Context, owner string, documentUrls []string, callbackUrl string) (*storage.SummaryJob, error) {
+	maxDocuments := s.config.SummaryJobMaxDocuments
+	if maxDocuments <= 0 {
+		maxDocuments = defaultMaxSummaryJobDocuments
+	}
+	if len(documentUrls) > maxDocuments {
+		return nil, errors.NewValidationError(fmt.Sprintf("relatedDocuments must not contain more than %d URLs", maxDocuments))
+	}
+	callbackUrl, callbackSecret, err := newJobCallback(callbackUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	valid, invalid := s.summaries.validateDocumentUrls(documentUrls)
+	rejected, err := json.Marshal(invalid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal rejected documents: %w", err)
+	}
+
+	now := time.Now().UTC()
+	job := &storage.SummaryJob{
+		JobId:          hex.EncodeToString(id),
+		Owner:          owner,
+		Status:         storage.SummaryJobQueued,
+		Documents:      valid,
+		Rejected:       rejected,
+		CallbackUrl:    callbackUrl,
+		CallbackSecret: callbackSecret,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ExpiresAt:      now.Add(time.Duration(s.config.SummaryJobTTLSeconds) * time.Second).Unix(),
+	}
+	// The tasks run without the caller's request, so they check access with the stored one
+	if access := aws.DocumentAccessFromContext(ctx); access.Restricted() {
+		job.Access = access.Denied
+	}
+	// Stored before the workflow starts, so its tasks always find the job
+	if err := s.store.SaveJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	input, err := json.Marshal(map[string]string{"jobId": job.JobId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal workflow input: %w", err)
+	}
+	s.trackJob(ctx, job)
+	if err := s.workflow.StartExecution(ctx, s.stateMachineArn, job.JobId, string(input)); err != nil {
+		if s.failJob(ctx, job, "The summarization workflow could not be started") {
+			s.trackJob(ctx, job)
+		}
+		return nil, err
+	}
+
+	logger.WithContext(ctx).Info("Summary job submitted", map[string]interface{}{
+		"job_id":         job.JobId,
+		"document_count": len(valid),
+		"rejected_count": len(invalid),
+	})
+	return job, nil
+}
+
+func (s *StepFunctionsSummaryWorkflowService) Get(ctx context.Context, jobId string, owner string) (*storage.SummaryJob, error) {
+	job, err := s.store.GetJob(ctx, jobId)
+	if err != nil || job == nil || job.Owner != owner {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *StepFunctionsSummaryWorkflowService) RunTask(ctx context.Context, task SummaryWorkflowTask) (interface{}, error) {
+	job, err := s.store.GetJob(ctx, task.JobId)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("summary job %s not found", task.JobId)
+	}
+	if len(job.Access) > 0 {
+		ctx = aws.WithDocumentAccess(ctx, &aws.DocumentAccess{Denied: job.Access})
+	}
+
+	switch task.Task {
+	case SummaryTaskFetch:
+		return s.fetch(ctx, job)
+	case SummaryTaskSummarize:
+		return task, s.summarizeChunk(ctx, job, task.Chunk)
+	case SummaryTaskCompare:
+		return task, s.compareChunk(ctx, job, task.Chunk)
+	case SummaryTaskAggregate:
+		return task, s.aggregate(ctx, job)
+	case SummaryTaskFail:
+		logger.WithContext(ctx).Error("Summary workflow failed", map[string]interface{}{
+			"job_id": job.JobId,
+			"error":  task.Error,
+		})
+		if s.failJob(ctx, job, "The summarization workflow failed") {
+			s.trackJob(ctx, job)
+			s.notifyFinished(ctx, job)
+		}
+		return task, nil
+	default:
+		return nil, fmt.Errorf("unknown summary workflow task %q", task.Task)
+	}
+}
+
+// fetch drops the documents the submitter cannot retrieve, describes the rest from their
+// metadata and stores them in chunks
+func (s *StepFunctionsSummaryWorkflowService) fetch(ctx context.Context, job *storage.SummaryJob) (*SummaryWorkflowPlan, error) {
+	valid := job.Documents
+	if aws.DocumentAccessFromContext(ctx).Restricted() {
+		var invalid []InvalidDocument
+		if err := json.Unmarshal(job.Rejected, &invalid); err != nil {
+			return nil, fmt.Errorf("failed to parse rejected documents: %w", err)
+		}
+		var err error
+		valid, invalid, err = s.summaries.keepAccessibleDocuments(ctx, valid, invalid)
+		if err != nil {
+			return nil, err
+		}
+		job.Documents = valid
+		if job.Rejected, err = json.Marshal(invalid); err != nil {
+			return nil, fmt.Errorf("failed to marshal rejected documents: %w", err)
+		}
+	}
+
+	chunkSize := s.config.SummaryJobChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultSummaryJobChunkSize
+	}
+	chunks := chunkByTopic(s.summaries.describeDocuments(valid), chunkSize)
+
+	plan := &SummaryWorkflowPlan{JobId: job.JobId, Chunks: make([]int, len(chunks))}
+	for i, documents := range chunks {
+		if err := s.saveChunk(ctx, job, i, documents); err != nil {
+			return nil, err
+		}
+		plan.Chunks[i] = i
+	}
+
+	job.Chunks = len(chunks)
+	job.Status = storage.SummaryJobRunning
+	job.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	s.trackJob(ctx, job)
+
+	logger.WithContext(ctx).Info("Summary job chunked", map[string]interface{}{
+		"job_id":         job.JobId,
+		"document_count": len(valid),
+		"chunk_count":    len(chunks),
+	})
+	return plan, nil
+}
+
+func (s *StepFunctionsSummaryWorkflowService) summarizeChunk(ctx context.Context, job *storage.SummaryJob, index int) error {
+	documents, err := s.loadChunk(ctx, job, index)
+	if err != nil {
+		return err
+	}
+	s.summaries.summarizeDocuments(ctx, documents)
+	return s.saveChunk(ctx, job, index, documents)
+}
+
+// compareChunk replaces the metadata difference of every document with an older version
+// in its chunk by a Bedrock comparison of their contents. Precomputed change summaries are
+// kept, and safe mode skips the comparisons like it does for last-update-document.
+func (s *StepFunctionsSummaryWorkflowService) compareChunk(ctx context.Context, job *storage.SummaryJob, index int) error {
+	log := logger.WithContext(ctx)
+	if s.config.SafeMode.Enabled() {
+		return nil
+	}
+
+	documents, err := s.loadChunk(ctx, job, index)
+	if err != nil {
+		return err
+	}
+
+	// Paired like findOlderVersion pairs them for the metadata difference
+	type pair struct{ newer, older int }
+	var pairs []pair
+	for i := range documents {
+		if documents[i].changeStored {
+			continue
+		}
+		for j := range documents {
+			if j != i && documents[j].topic == documents[i].topic && documents[j].version < documents[i].version {
+				pairs = append(pairs, pair{newer: i, older: j})
+				break
+			}
+		}
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	contents, err := s.summaries.retrieveDocumentContents(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		newer, older := &documents[p.newer], &documents[p.older]
+		newerContent, olderContent := contents[newer.url], contents[older.url]
+		if newerContent == "" || olderContent == "" {
+			continue
+		}
+
+		changeSummary, err := s.summaries.openSearchClient.CompareDocumentVersions(ctx, newerContent, olderContent, newer.topic)
+		if err != nil {
+			// The document keeps its metadata difference
+			log.Warn("Failed to compare document versions", map[string]interface{}{
+				"job_id": job.JobId,
+				"url":    newer.url,
+				"error":  err.Error(),
+			})
+			continue
+		}
+		newer.difference = changeSummary
+	}
+	return s.saveChunk(ctx, job, index, documents)
+}
+
+// aggregate collects the chunks into the job's result, in the summary-document order
+func (s *StepFunctionsSummaryWorkflowService) aggregate(ctx context.Context, job *storage.SummaryJob) error {
+	result := SummaryJobResult{Documents: []DocumentSummaryItem{}, InvalidDocuments: []InvalidDocument{}}
+	for i := 0; i < job.Chunks; i++ {
+		documents, err := s.loadChunk(ctx, job, i)
+		if err != nil {
+			return err
+		}
+		for _, doc := range documents {
+			result.Documents = append(result.Documents, doc.item())
+		}
+	}
+	sort.Slice(result.Documents, func(i, j int) bool {
+		return result.Documents[i].Order < result.Documents[j].Order
+	})
+	result.Total = len(result.Documents)
+	if err := json.Unmarshal(job.Rejected, &result.InvalidDocuments); err != nil {
+		return fmt.Errorf("failed to parse rejected documents: %w", err)
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal summary job result: %w", err)
+	}
+	job.Result = body
+	job.Status = storage.SummaryJobCompleted
+	job.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveJob(ctx, job); err != nil {
+		return err
+	}
+
+	logger.WithContext(ctx).Info("Summary job completed", map[string]interface{}{
+		"job_id":         job.JobId,
+		"document_count": result.Total,
+		"duration_ms":    job.UpdatedAt.Sub(job.CreatedAt).Milliseconds(),
+	})
+	s.trackJob(ctx, job)
+	s.notifyFinished(ctx, job)
+	return nil
+}
+
+// failJob stores the failure and reports whether it did, a failed write only leaves the
+// job running until it expires
+func (s *StepFunctionsSummaryWorkflowService) failJob(ctx context.Context, job *storage.SummaryJob, message string) bool {
+	if job.Finished() {
+		return false
+	}
+	job.Status = storage.SummaryJobFailed
+	job.Error = message
+	job.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveJob(ctx, job); err != nil {
+		logger.WithContext(ctx).Warn("Failed to store summary job failure", map[string]interface{}{
+			"job_id": job.JobId,
+			"error":  err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// trackJob mirrors the job to the jobs table, a failed write only leaves /jobs/{id} behind
+// the summary-document/jobs endpoint
+func (s *StepFunctionsSummaryWorkflowService) trackJob(ctx context.Context, job *storage.SummaryJob) {
+	if s.jobs == nil {
+		return
+	}
+	tracked := &storage.Job{
+		JobId:     job.JobId,
+		Type:      storage.JobTypeSummary,
+		Owner:     job.Owner,
+		Status:    summaryJobStatus(job),
+		Result:    job.Result,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+		ExpiresAt: job.ExpiresAt,
+	}
+	if err := s.jobs.SaveJob(ctx, tracked); err != nil {
+		logger.WithContext(ctx).Warn("Failed to track summary job", map[string]interface{}{
+			"job_id": job.JobId,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// summaryJobStatus is the status of a summary job as a job: partial once completed when
+// some of its documents could not be summarized
+func summaryJobStatus(job *storage.SummaryJob) string {
+	switch job.Status {
+	case storage.SummaryJobCompleted:
+		var result SummaryJobResult
+		if err := json.Unmarshal(job.Result, &result); err == nil {
+			for _, document := range result.Documents {
+				if document.Error != "" {
+					return storage.JobPartial
+				}
+			}
+		}
+		return storage.JobSucceeded
+	case storage.SummaryJobFailed:
+		return storage.JobFailed
+	case storage.SummaryJobRunning:
+		return storage.JobRunning
+	default:
+		return storage.JobQueued
+	}
+}
+
+// notifyFinished posts a finished job to its callback URL, with its summaries once completed
+func (s *StepFunctionsSummaryWorkflowService) notifyFinished(ctx context.Context, job *storage.SummaryJob) {
+	if job.CallbackUrl == "" || s.callbacks == nil {
+		return
+	}
+	payload := JobCallbackPayload{
+		Event:  EventSummaryJobFinished,
+		JobId:  job.JobId,
+		Status: summaryJobStatus(job),
+		Error:  job.Error,
+	}
+	if json.Valid(job.Result) {
+		payload.Result = job.Result
+	}
+	s.callbacks.NotifyJobFinished(ctx, job.CallbackUrl, job.CallbackSecret, payload)
+}
+
+// summaryJobDocument is a documentInfo stored in a chunk between tasks
+type summaryJobDocument struct {
+	Order        int    `json:"order"`
+	Link         string `json:"link"`
+	Topic        string `json:"topic"`
+	Version      int    `json:"version"`
+	YearMonth    string `json:"yearMonth"`
+	Summary      string `json:"summary"`
+	Difference   string `json:"difference"`
+	Error        string `json:"error,omitempty"`
+	ChangeStored bool   `json:"changeStored,omitempty"`
+}
+
+func (s *StepFunctionsSummaryWorkflowService) loadChunk(ctx context.Context, job *storage.SummaryJob, index int) ([]documentInfo, error) {
+	chunk, err := s.store.GetChunk(ctx, job.JobId, index)
+	if err != nil {
+		return nil, err
+	}
+	if chunk == nil {
+		return nil, fmt.Errorf("chunk %d of summary job %s not found", index, job.JobId)
+	}
+
+	var stored []summaryJobDocument
+	if err := json.Unmarshal(chunk.Documents, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse summary job chunk: %w", err)
+	}
+	documents := make([]documentInfo, len(stored))
+	for i, doc := range stored {
+		documents[i] = documentInfo{
+			url:          doc.Link,
+			topic:        doc.Topic,
+			version:      doc.Version,
+			yearMonth:    doc.YearMonth,
+			order:        doc.Order,
+			summary:      doc.Summary,
+			difference:   doc.Difference,
+			err:          doc.Error,
+			changeStored: doc.ChangeStored,
+		}
+	}
+	return documents, nil
+}
+
+func (s *StepFunctionsSummaryWorkflowService) saveChunk(ctx context.Context, job *storage.SummaryJob, index int, documents []documentInfo) error {
+	stored := make([]summaryJobDocument, len(documents))
+	for i, doc := range documents {
+		stored[i] = summaryJobDocument{
+			Order:        doc.order,
+			Link:         doc.url,
+			Topic:        doc.topic,
+			Version:      doc.version,
+			YearMonth:    doc.yearMonth,
+			Summary:      doc.summary,
+			Difference:   doc.difference,
+			Error:        doc.err,
+			ChangeStored: doc.changeStored,
+		}
+	}
+	body, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal summary job chunk: %w", err)
+	}
+	return s.store.SaveChunk(ctx, &storage.SummaryJobChunk{
+		JobId:     job.JobId,
+		Index:     index,
+		Documents: body,
+		ExpiresAt: job.ExpiresAt,
+	})
+}
+
+// chunkByTopic packs the documents into chunks of about size documents, keeping every
+// topic in one chunk. A topic with more versions than size gets a larger chunk of its own.
+func chunkByTopic(documents []documentInfo, size int) [][]documentInfo {
+	var topics []string
+	groups := map[string][]documentInfo{}
+	for _, doc := range documents {
+		if _, ok := groups[doc.topic]; !ok {
+			topics = append(topics, doc.topic)
+		}
+		groups[doc.topic] = append(groups[doc.topic], doc)
+	}
+
+	var chunks [][]documentInfo
+	var current []documentInfo
+	for _, topic := range topics {
+		group := groups[topic]
+		if len(current) > 0 && len(current)+len(group) > size {
+			chunks = append(chunks, current)
+			current = nil
+		}
+		current = append(current, group...)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
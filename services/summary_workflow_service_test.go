@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type recordingWorkflowClient struct {
+	names  []string
+	inputs []string
+}
+
+func (r *recordingWorkflowClient) StartExecution(ctx context.Context, stateMachineArn string, name string, input string) error {
+	r.names = append(r.names, name)
+	r.inputs = append(r.inputs, input)
+	return nil
+}
+
+// runSummaryWorkflow runs the tasks of a job in the order the state machine does
+func runSummaryWorkflow(t *testing.T, service *StepFunctionsSummaryWorkflowService, jobId string) {
+	t.Helper()
+	ctx := context.Background()
+
+	output, err := service.RunTask(ctx, SummaryWorkflowTask{Task: SummaryTaskFetch, JobId: jobId})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	for _, chunk := range output.(*SummaryWorkflowPlan).Chunks {
+		for _, task := range []string{SummaryTaskSummarize, SummaryTaskCompare} {
+			if _, err := service.RunTask(ctx, SummaryWorkflowTask{Task: task, JobId: jobId, Chunk: chunk}); err != nil {
+				t.Fatalf("%s of chunk %d failed: %v", task, chunk, err)
+			}
+		}
+	}
+	if _, err := service.RunTask(ctx, SummaryWorkflowTask{Task: SummaryTaskAggregate, JobId: jobId}); err != nil {
+		t.Fatalf("aggregate failed: %v", err)
+	}
+}
+
+func TestSummaryWorkflow_SummarizesInChunks(t *testing.T) {
+	client := &mockOpenSearchClient{documents: []map[string]interface{}{
+		{"link": "https://b/content/2025/05/waive-2.pdf", "content": "waive v2"},
+		{"link": "https://b/content/2025/01/waive-1.pdf", "content": "waive v1"},
+		{"link": "https://b/content/2025/03/horaland.pdf", "content": "horaland"},
+		{"link": "https://b/content/2025/04/card.pdf", "content": "card"},
+	}}
+	cfg := &config.Config{SummaryJobTTLSeconds: 3600, SummaryJobChunkSize: 2, DocumentAllowedHosts: []string{"b"}}
+	workflow := &recordingWorkflowClient{}
+	store := storage.NewMemorySummaryJobStore()
+	jobs := storage.NewMemoryJobStore()
+	service := NewStepFunctionsSummaryWorkflowService(workflow, "arn:aws:states:ap-southeast-1:123456789012:stateMachine:summaries", store, jobs,
+		NewBedrockDocumentSummaryService(client, nil, nil, cfg), nil, cfg)
+
+	job, err := service.Submit(context.Background(), "owner", []string{
+		"https://b/content/2025/01/waive-1.pdf",
+		"https://b/content/2025/03/horaland.pdf",
+		"http://b/content/2025/02/plain.pdf",
+		"https://b/content/2025/05/waive-2.pdf",
+		"https://b/content/2025/04/card.pdf",
+	}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(workflow.names) != 1 || workflow.names[0] != job.JobId || workflow.inputs[0] != `{"jobId":"`+job.JobId+`"}` {
+		t.Fatalf("expected one execution named after the job, got %v %v", workflow.names, workflow.inputs)
+	}
+
+	runSummaryWorkflow(t, service, job.JobId)
+
+	stored, _ := service.Get(context.Background(), job.JobId, "owner")
+	if stored == nil || stored.Status != storage.SummaryJobCompleted {
+		t.Fatalf("expected a completed job, got %+v", stored)
+	}
+	// Waive has two versions and fills a chunk, horaland and card share the other
+	if stored.Chunks != 2 {
+		t.Errorf("expected 2 chunks, got %d", stored.Chunks)
+	}
+	if tracked, _ := jobs.GetJob(context.Background(), job.JobId); tracked == nil || tracked.Type != storage.JobTypeSummary || tracked.Status != storage.JobSucceeded || string(tracked.Result) != string(stored.Result) {
+		t.Errorf("expected the job mirrored with its result, got %+v", tracked)
+	}
+
+	var result SummaryJobResult
+	if err := json.Unmarshal(stored.Result, &result); err != nil {
+		t.Fatalf("invalid result %q: %v", stored.Result, err)
+	}
+	if result.Total != 4 || len(result.InvalidDocuments) != 1 {
+		t.Fatalf("expected 4 documents and 1 rejected, got %+v", result)
+	}
+	for i, doc := range result.Documents {
+		if doc.Order != i+1 {
+			t.Errorf("expected the documents in order, got %+v", result.Documents)
+		}
+	}
+	if result.Documents[0].Link != "https://b/content/2025/05/waive-2.pdf" || result.Documents[0].DifferenceFromOldVersion != "changed from waive v1 to waive v2" {
+		t.Errorf("expected the newest waive compared with its older version, got %+v", result.Documents[0])
+	}
+	if client.compareCalls != 1 {
+		t.Errorf("expected a single comparison, got %d", client.compareCalls)
+	}
+
+	if other, _ := service.Get(context.Background(), job.JobId, "someone else"); other != nil {
+		t.Error("expected jobs of other owners to be hidden")
+	}
+}
+
+func TestSummaryWorkflow_FailMarksJobFailed(t *testing.T) {
+	cfg := &config.Config{SummaryJobTTLSeconds: 3600}
+	store := storage.NewMemorySummaryJobStore()
+	jobs := storage.NewMemoryJobStore()
+	callbacks := &recordingJobCallbacks{}
+	service := NewStepFunctionsSummaryWorkflowService(&recordingWorkflowClient{}, "arn", store, jobs,
+		NewBedrockDocumentSummaryService(&mockOpenSearchClient{}, nil, nil, cfg), callbacks, cfg)
+	ctx := context.Background()
+
+	job, err := service.Submit(ctx, "owner", []string{"https://b/content/2025/05/waive-2.pdf"}, "https://portal.example.com/jobs")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := service.RunTask(ctx, SummaryWorkflowTask{Task: SummaryTaskFail, JobId: job.JobId, Error: "Lambda timed out"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	stored, _ := store.GetJob(ctx, job.JobId)
+	if stored.Status != storage.SummaryJobFailed || stored.Error == "" {
+		t.Errorf("expected a failed job, got %+v", stored)
+	}
+	if stored.Error == "Lambda timed out" {
+		t.Error("expected the internal cause not to be shown to the caller")
+	}
+	if tracked, _ := jobs.GetJob(ctx, job.JobId); tracked == nil || tracked.Status != storage.JobFailed || tracked.Error != stored.Error {
+		t.Errorf("expected the failure mirrored, got %+v", tracked)
+	}
+	// A failure caught again is not posted again
+	if len(callbacks.callbacks) != 1 || callbacks.callbacks[0].payload.Status != storage.SummaryJobFailed || callbacks.callbacks[0].payload.Error != stored.Error {
+		t.Errorf("expected the failure to be posted once, got %+v", callbacks.callbacks)
+	}
+
+	if _, err := service.RunTask(ctx, SummaryWorkflowTask{Task: SummaryTaskFetch, JobId: "unknown"}); err == nil {
+		t.Error("expected an error for an unknown job")
+	}
+}
+
+func TestSummaryWorkflow_RejectsTooManyDocuments(t *testing.T) {
+	cfg := &config.Config{SummaryJobTTLSeconds: 3600, SummaryJobMaxDocuments: 1}
+	service := NewStepFunctionsSummaryWorkflowService(&recordingWorkflowClient{}, "arn", storage.NewMemorySummaryJobStore(), nil,
+		NewBedrockDocumentSummaryService(&mockOpenSearchClient{}, nil, nil, cfg), nil, cfg)
+
+	_, err := service.Submit(context.Background(), "owner", []string{"https://b/a.pdf", "https://b/b.pdf"}, "")
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+}
+
+func TestSummaryWorkflow_StoresSubmitterAccess(t *testing.T) {
+	cfg := &config.Config{SummaryJobTTLSeconds: 3600, DocumentAllowedHosts: []string{"b"}}
+	store := storage.NewMemorySummaryJobStore()
+	service := NewStepFunctionsSummaryWorkflowService(&recordingWorkflowClient{}, "arn", store, nil,
+		NewBedrockDocumentSummaryService(&mockOpenSearchClient{}, nil, nil, cfg), nil, cfg)
+	ctx := aws.WithDocumentAccess(context.Background(), &aws.DocumentAccess{Denied: map[string][]string{"confidentiality": {"restricted"}}})
+
+	job, err := service.Submit(ctx, "owner", []string{"https://b/content/2025/05/waive-2.pdf"}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The mock knows no chunks of the document, so the submitter cannot retrieve it
+	runSummaryWorkflow(t, service, job.JobId)
+	stored, _ := store.GetJob(context.Background(), job.JobId)
+	var result SummaryJobResult
+	json.Unmarshal(stored.Result, &result)
+	if result.Total != 0 || len(result.InvalidDocuments) != 1 || result.InvalidDocuments[0].Error != "document not found" {
+		t.Errorf("expected the inaccessible document rejected, got %+v", result)
+	}
+}
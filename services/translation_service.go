@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/logger"
+	"teletubpax-api/utils"
+	"teletubpax-api/warnings"
+)
+
+// translationWorkers bounds parallel translation requests for multiple snippets
+const translationWorkers = 4
+
+// TranslatedText is text in the requested language. SourceText holds the original when the
+// text had to be translated and is empty when it was already in that language.
+type TranslatedText struct {
+	Text       string
+	Language   string
+	SourceText string
+}
+
+type TranslationService interface {
+	Translate(ctx context.Context, text string, targetLanguage string) (*TranslatedText, error)
+	// TranslateAll translates texts in parallel, keeping their order. Texts that fail to
+	// translate are returned unchanged.
+	TranslateAll(ctx context.Context, texts []string, targetLanguage string) []TranslatedText
+}
+
+type ClientTranslationService struct {
+	client aws.TranslationClient
+}
+
+func NewClientTranslationService(client aws.TranslationClient) *ClientTranslationService {
+	return &ClientTranslationService{
+		client: client,
+	}
+}
+
+func (s *ClientTranslationService) Translate(ctx context.Context, text string, targetLanguage string) (*TranslatedText, error) {
+	sourceLanguage := utils.DetectLanguage(text)
+	if sourceLanguage == targetLanguage || strings.TrimSpace(text) == "" {
+		return &TranslatedText{Text: text, Language: sourceLanguage}, nil
+	}
+
+	translated, err := s.client.TranslateText(ctx, text, sourceLanguage, targetLanguage)
+	if err != nil {
+		return nil, err
+	}
+	return &TranslatedText{Text: translated, Language: targetLanguage, SourceText: text}, nil
+}
+
+func (s *ClientTranslationService) TranslateAll(ctx context.Context, texts []string, targetLanguage string) []TranslatedText {
+	results := make([]TranslatedText, len(texts))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < translationWorkers && w < len(texts); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result, err := s.Translate(ctx, texts[i], targetLanguage)
+				if err != nil {
+					logger.WithContext(ctx).Warn("Failed to translate text", map[string]interface{}{
+						"error": err.Error(),
+					})
+					warnings.Add(ctx, warnings.CodeTranslationFailed, "Some snippets could not be translated and are shown in their original language")
+					results[i] = TranslatedText{Text: texts[i], Language: utils.DetectLanguage(texts[i])}
+					continue
+				}
+				results[i] = *result
+			}
+		}()
+	}
+
+	for i := range texts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
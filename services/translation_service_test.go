@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type mockTranslationClient struct {
+	failOn    string
+	callCount atomic.Int32 // TranslateAll translates concurrently
+}
+
+func (m *mockTranslationClient) TranslateText(ctx context.Context, text string, sourceLanguage string, targetLanguage string) (string, error) {
+	m.callCount.Add(1)
+	if m.failOn != "" && strings.Contains(text, m.failOn) {
+		return "", errors.New("translate failed")
+	}
+	return "[" + sourceLanguage + "->" + targetLanguage + "] " + text, nil
+}
+
+func TestTranslate_OnlyWhenLanguageDiffers(t *testing.T) {
+	client := &mockTranslationClient{}
+	service := NewClientTranslationService(client)
+
+	same, err := service.Translate(context.Background(), "สมัครบัตรเครดิตได้ที่สาขา", "th")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if same.SourceText != "" || same.Language != "th" || client.callCount.Load() != 0 {
+		t.Errorf("expected Thai text to be returned untranslated, got %+v", same)
+	}
+
+	translated, err := service.Translate(context.Background(), "สมัครบัตรเครดิตได้ที่สาขา", "en")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if translated.Text != "[th->en] สมัครบัตรเครดิตได้ที่สาขา" || translated.SourceText != "สมัครบัตรเครดิตได้ที่สาขา" || translated.Language != "en" {
+		t.Errorf("unexpected translation %+v", translated)
+	}
+}
+
+func TestTranslateAll_KeepsOrderAndOriginalOnFailure(t *testing.T) {
+	service := NewClientTranslationService(&mockTranslationClient{failOn: "broken"})
+	texts := []string{"first snippet", "broken snippet", "third snippet", "ข้อความภาษาไทย"}
+
+	results := service.TranslateAll(context.Background(), texts, "th")
+
+	if len(results) != len(texts) {
+		t.Fatalf("expected %d results, got %d", len(texts), len(results))
+	}
+	if results[0].Text != "[en->th] first snippet" || results[2].Text != "[en->th] third snippet" {
+		t.Errorf("expected results in input order, got %+v", results)
+	}
+	if results[1].Text != "broken snippet" || results[1].SourceText != "" {
+		t.Errorf("expected failed snippet to be returned unchanged, got %+v", results[1])
+	}
+	if results[3].Text != "ข้อความภาษาไทย" || results[3].SourceText != "" {
+		t.Errorf("expected Thai snippet to be kept, got %+v", results[3])
+	}
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+// UsageDateLayout is the layout of the days usage is aggregated and reported by, in UTC
+const UsageDateLayout = "2006-01-02"
+
+// MaxUsageReportDays bounds the days a usage report covers
+const MaxUsageReportDays = 366
+
+// ModelPrice is the on-demand price of a model in USD per 1,000 tokens
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// defaultModelPrices are the Bedrock on-demand prices of the default models, MODEL_PRICES
+// adds other models and overrides these
+var defaultModelPrices = map[string]ModelPrice{
+	"anthropic.claude-haiku-4-5-20251001-v1:0":  {Input: 0.001, Output: 0.005},
+	"anthropic.claude-sonnet-4-5-20250929-v1:0": {Input: 0.003, Output: 0.015},
+	"amazon.titan-embed-text-v2:0":              {Input: 0.00002},
+}
+
+// UsageItem is the usage of a tenant on one endpoint and model over a report's days
+type UsageItem struct {
+	Endpoint       string  `json:"endpoint"`
+	ModelId        string  `json:"modelId"`
+	Calls          int64   `json:"calls"`
+	InputTokens    int64   `json:"inputTokens"`
+	OutputTokens   int64   `json:"outputTokens"`
+	EstimatedCalls int64   `json:"estimatedCalls,omitempty"` // Calls whose tokens are estimated from text length
+	Cost           float64 `json:"cost"`
+}
+
+// TenantUsage is the usage of the requests of one X-Tenant-Id over a report's days
+type TenantUsage struct {
+	TenantId     string      `json:"tenantId"` // Empty for requests without X-Tenant-Id
+	InputTokens  int64       `json:"inputTokens"`
+	OutputTokens int64       `json:"outputTokens"`
+	Cost         float64     `json:"cost"`
+	Usage        []UsageItem `json:"usage"` // Highest cost first
+}
+
+type UsageReport struct {
+	From         string        `json:"from"` // First day, YYYY-MM-DD in UTC
+	To           string        `json:"to"`   // Last day, inclusive
+	Currency     string        `json:"currency"`
+	InputTokens  int64         `json:"inputTokens"`
+	OutputTokens int64         `json:"outputTokens"`
+	Cost         float64       `json:"cost"`
+	Tenants      []TenantUsage `json:"tenants"` // Highest cost first
+}
+
+type UsageService interface {
+	// Record adds the token usage of a request to the daily usage of its tenant and endpoint
+	Record(ctx context.Context, tenantId string, endpoint string, usage []aws.TokenUsage)
+	// Report sums the usage of the days from from to to, inclusive, of every tenant or only
+	// of tenantId when it is set
+	Report(ctx context.Context, from time.Time, to time.Time, tenantId string) (*UsageReport, error)
+}
+
+// StoreUsageService prices the tokens of each request when it is recorded, so reports keep
+// the prices the tokens were used at, and aggregates them by day in a UsageStore
+type StoreUsageService struct {
+	store         storage.UsageStore
+	prices        map[string]ModelPrice
+	retentionDays int
+}
+
+func NewStoreUsageService(store storage.UsageStore, cfg *config.Config) (*StoreUsageService, error) {
+	prices := make(map[string]ModelPrice, len(defaultModelPrices))
+	for modelId, price := range defaultModelPrices {
+		prices[modelId] = price
+	}
+	if strings.TrimSpace(cfg.ModelPrices) != "" {
+		var configured map[string]ModelPrice
+		if err := json.Unmarshal([]byte(cfg.ModelPrices), &configured); err != nil {
+			return nil, fmt.Errorf("invalid MODEL_PRICES: %w", err)
+		}
+		for modelId, price := range configured {
+			if price.Input < 0 || price.Output < 0 {
+				return nil, fmt.Errorf("price of model %s must not be negative", modelId)
+			}
+			prices[modelId] = price
+		}
+	}
+
+	return &StoreUsageService{
+		store:         store,
+		prices:        prices,
+		retentionDays: cfg.UsageRetentionDays,
+	}, nil
+}
+
+// Record logs the usage of the request, so CloudWatch Insights can sum it, and adds it to the
+// store. Store failures are logged, usage accounting must not fail requests.
+func (s *StoreUsageService) Record(ctx context.Context, tenantId string, endpoint string, usage []aws.TokenUsage) {
+	log := logger.WithContext(ctx)
+	now := time.Now().UTC()
+	date := now.Format(UsageDateLayout)
+
+	var inputTokens, outputTokens int64
+	var cost float64
+	var unpriced []string
+	for _, modelUsage := range usage {
+		price, ok := s.prices[modelUsage.ModelId]
+		if !ok {
+			unpriced = append(unpriced, modelUsage.ModelId)
+		}
+		record := &storage.UsageRecord{
+			Id:             storage.UsageId(date, tenantId, endpoint, modelUsage.ModelId),
+			Date:           date,
+			TenantId:       tenantId,
+			Endpoint:       endpoint,
+			ModelId:        modelUsage.ModelId,
+			Calls:          modelUsage.Calls,
+			InputTokens:    modelUsage.InputTokens,
+			OutputTokens:   modelUsage.OutputTokens,
+			EstimatedCalls: modelUsage.EstimatedCalls,
+			Cost:           price.cost(modelUsage.InputTokens, modelUsage.OutputTokens),
+			ExpiresAt:      now.AddDate(0, 0, s.retentionDays).Unix(),
+		}
+		inputTokens += record.InputTokens
+		outputTokens += record.OutputTokens
+		cost += record.Cost
+
+		if err := s.store.AddUsage(ctx, record); err != nil {
+			log.Warn("Failed to record token usage", map[string]interface{}{
+				"model_id": modelUsage.ModelId,
+				"error":    err.Error(),
+			})
+		}
+	}
+
+	fields := map[string]interface{}{
+		"tenant_id":     tenantId,
+		"endpoint":      endpoint,
+		"input_tokens":  inputTokens,
+		"output_tokens": outputTokens,
+		"cost_usd":      roundCost(cost),
+	}
+	if len(unpriced) > 0 {
+		// Set their prices in MODEL_PRICES, their tokens are counted without a cost
+		fields["unpriced_models"] = unpriced
+	}
+	log.Info("Token usage", fields)
+}
+
+func (s *StoreUsageService) Report(ctx context.Context, from time.Time, to time.Time, tenantId string) (*UsageReport, error) {
+	report := &UsageReport{
+		From:     from.UTC().Format(UsageDateLayout),
+		To:       to.UTC().Format(UsageDateLayout),
+		Currency: "USD",
+		Tenants:  []TenantUsage{},
+	}
+	records, err := s.store.ListUsage(ctx, report.From, report.To)
+	if err != nil {
+		return nil, err
+	}
+
+	type itemKey struct{ tenantId, endpoint, modelId string }
+	tenants := map[string]*TenantUsage{}
+	items := map[itemKey]*UsageItem{}
+	for _, record := range records {
+		if tenantId != "" && record.TenantId != tenantId {
+			continue
+		}
+		tenant, ok := tenants[record.TenantId]
+		if !ok {
+			tenant = &TenantUsage{TenantId: record.TenantId}
+			tenants[record.TenantId] = tenant
+		}
+		key := itemKey{record.TenantId, record.Endpoint, record.ModelId}
+		item, ok := items[key]
+		if !ok {
+			item = &UsageItem{Endpoint: record.Endpoint, ModelId: record.ModelId}
+			items[key] = item
+		}
+
+		item.Calls += record.Calls
+		item.InputTokens += record.InputTokens
+		item.OutputTokens += record.OutputTokens
+		item.EstimatedCalls += record.EstimatedCalls
+		item.Cost += record.Cost
+		tenant.InputTokens += record.InputTokens
+		tenant.OutputTokens += record.OutputTokens
+		tenant.Cost += record.Cost
+		report.InputTokens += record.InputTokens
+		report.OutputTokens += record.OutputTokens
+		report.Cost += record.Cost
+	}
+
+	for key, item := range items {
+		item.Cost = roundCost(item.Cost)
+		tenants[key.tenantId].Usage = append(tenants[key.tenantId].Usage, *item)
+	}
+	for _, tenant := range tenants {
+		tenant.Cost = roundCost(tenant.Cost)
+		sort.Slice(tenant.Usage, func(i, j int) bool {
+			if tenant.Usage[i].Cost != tenant.Usage[j].Cost {
+				return tenant.Usage[i].Cost > tenant.Usage[j].Cost
+			}
+			return tenant.Usage[i].Endpoint+tenant.Usage[i].ModelId < tenant.Usage[j].Endpoint+tenant.Usage[j].ModelId
+		})
+		report.Tenants = append(report.Tenants, *tenant)
+	}
+	sort.Slice(report.Tenants, func(i, j int) bool {
+		if report.Tenants[i].Cost != report.Tenants[j].Cost {
+			return report.Tenants[i].Cost > report.Tenants[j].Cost
+		}
+		return report.Tenants[i].TenantId < report.Tenants[j].TenantId
+	})
+	report.Cost = roundCost(report.Cost)
+	return report, nil
+}
+
+// cost returns the price of the tokens in USD
+func (p ModelPrice) cost(inputTokens int64, outputTokens int64) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1000
+}
+
+// roundCost rounds a cost to a millionth of a dollar, hiding float sums like 0.30000000000000004
+func roundCost(cost float64) float64 {
+	return math.Round(cost*1e6) / 1e6
+}
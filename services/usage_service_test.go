@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+func TestUsage_RecordsAndReportsByTenant(t *testing.T) {
+	service, err := NewStoreUsageService(storage.NewMemoryUsageStore(), &config.Config{
+		ModelPrices:        `{"us.amazon.nova-lite-v1:0": {"input": 0.00006, "output": 0.00024}}`,
+		UsageRetentionDays: 400,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	haiku := "anthropic.claude-haiku-4-5-20251001-v1:0"
+	service.Record(ctx, "hr", "question-search", []aws.TokenUsage{{ModelId: haiku, Calls: 1, InputTokens: 2000, OutputTokens: 400, EstimatedCalls: 1}})
+	service.Record(ctx, "hr", "question-search", []aws.TokenUsage{{ModelId: haiku, Calls: 1, InputTokens: 1000, OutputTokens: 200}})
+	service.Record(ctx, "hr", "related-questions", []aws.TokenUsage{{ModelId: "us.amazon.nova-lite-v1:0", Calls: 1, InputTokens: 1000, OutputTokens: 1000}})
+	service.Record(ctx, "", "question-search", []aws.TokenUsage{{ModelId: "unpriced-model", Calls: 1, InputTokens: 500, OutputTokens: 50}})
+
+	today := time.Now().UTC()
+	report, err := service.Report(ctx, today, today, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.InputTokens != 4500 || report.OutputTokens != 1650 {
+		t.Errorf("expected the tokens of every request, got %d in and %d out", report.InputTokens, report.OutputTokens)
+	}
+	// Haiku: 3,000 in at $0.001 and 600 out at $0.005 per 1,000 tokens, Nova Lite: $0.0003
+	if report.Cost != 0.0063 || len(report.Tenants) != 2 {
+		t.Fatalf("expected $0.0063 over two tenants, got %+v", report)
+	}
+
+	hr := report.Tenants[0]
+	if hr.TenantId != "hr" || hr.Cost != 0.0063 || len(hr.Usage) != 2 {
+		t.Fatalf("expected the costliest tenant first with its usage by endpoint and model, got %+v", hr)
+	}
+	if item := hr.Usage[0]; item.Endpoint != "question-search" || item.Calls != 2 || item.EstimatedCalls != 1 || item.Cost != 0.006 {
+		t.Errorf("expected the question search usage summed, got %+v", item)
+	}
+	if anonymous := report.Tenants[1]; anonymous.TenantId != "" || anonymous.InputTokens != 500 || anonymous.Cost != 0 {
+		t.Errorf("expected the unpriced usage of callers without a tenant counted without a cost, got %+v", anonymous)
+	}
+
+	filtered, err := service.Report(ctx, today.AddDate(0, 0, -7), today, "hr")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(filtered.Tenants) != 1 || filtered.InputTokens != 4000 {
+		t.Errorf("expected only the usage of the tenant, got %+v", filtered)
+	}
+
+	yesterday, err := service.Report(ctx, today.AddDate(0, 0, -1), today.AddDate(0, 0, -1), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(yesterday.Tenants) != 0 || yesterday.Cost != 0 {
+		t.Errorf("expected no usage outside the report days, got %+v", yesterday)
+	}
+}
+
+func TestUsage_RejectsInvalidPrices(t *testing.T) {
+	for _, prices := range []string{`not json`, `{"model": {"input": -1}}`} {
+		if _, err := NewStoreUsageService(storage.NewMemoryUsageStore(), &config.Config{ModelPrices: prices}); err == nil {
+			t.Errorf("expected MODEL_PRICES %s to be rejected", prices)
+		}
+	}
+}
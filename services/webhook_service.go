@@ -0,0 +1,336 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/errors"
+	"teletubpax-api/logger"
+	"teletubpax-api/storage"
+)
+
+const (
+	EventDocumentVersionCreated = "document.version.created"
+
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+
+	webhookInitialBackoff = 500 * time.Millisecond
+	webhookSecretBytes    = 32
+)
+
+// DocumentVersionEvent describes a new document version found by change detection
+type DocumentVersionEvent struct {
+	Link            string `json:"link"`
+	Topic           string `json:"topic"`
+	Version         int    `json:"version"`
+	PreviousLink    string `json:"previousLink,omitempty"`
+	PreviousVersion int    `json:"previousVersion,omitempty"`
+	Summary         string `json:"summary,omitempty"`
+	ChangeSummary   string `json:"changeSummary,omitempty"`
+}
+
+// WebhookPayload is the signed JSON body sent to every webhook
+type WebhookPayload struct {
+	Id         string               `json:"id"`
+	Event      string               `json:"event"`
+	OccurredAt time.Time            `json:"occurredAt"`
+	Document   DocumentVersionEvent `json:"document"`
+}
+
+// RegisteredWebhook is returned once on registration, the only time the secret is shown
+type RegisteredWebhook struct {
+	storage.Webhook
+	Secret string `json:"secret"`
+}
+
+// DocumentVersionNotifier is told about new document versions found by change detection
+type DocumentVersionNotifier interface {
+	NotifyNewVersion(ctx context.Context, event DocumentVersionEvent)
+}
+
+type WebhookService interface {
+	DocumentVersionNotifier
+	Register(ctx context.Context, webhookUrl string, description string) (*RegisteredWebhook, error)
+	List(ctx context.Context) ([]storage.Webhook, error)
+	Delete(ctx context.Context, id string) (bool, error)
+}
+
+// HTTPWebhookService delivers signed event payloads to registered webhooks. Each payload is
+// signed like API responses: the hex HMAC-SHA256 of "<timestamp>.<body>" with the webhook
+// secret, sent as "sha256=<signature>". Network errors, 429 and 5xx responses are retried
+// with exponential backoff; the outcome of the last attempt is recorded on the webhook.
+// Deliveries run in the background, so a slow or failing receiver never holds up change
+// detection.
+type HTTPWebhookService struct {
+	store      storage.WebhookStore
+	client     *http.Client
+	config     *config.Config
+	backoff    time.Duration
+	deliveries sync.WaitGroup // Notifications still being delivered
+}
+
+func NewHTTPWebhookService(store storage.WebhookStore, cfg *config.Config) *HTTPWebhookService {
+	return &HTTPWebhookService{
+		store:   store,
+		client:  &http.Client{Timeout: time.Duration(cfg.WebhookTimeoutSeconds) * time.Second},
+		config:  cfg,
+		backoff: webhookInitialBackoff,
+	}
+}
+
+func (s *HTTPWebhookService) Register(ctx context.Context, webhookUrl string, description string) (*RegisteredWebhook, error) {
+	webhookUrl, err := validateHttpsUrl("url", webhookUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	webhook := storage.Webhook{
+		Id:          now.Format("20060102T150405Z") + "-" + randomSuffix(),
+		Url:         webhookUrl,
+		Secret:      secret,
+		Description: strings.TrimSpace(description),
+		CreatedAt:   now,
+	}
+	if err := s.store.PutWebhook(ctx, &webhook); err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).Info("Webhook registered", map[string]interface{}{
+		"id":  webhook.Id,
+		"url": webhook.Url,
+	})
+	return &RegisteredWebhook{Webhook: webhook, Secret: webhook.Secret}, nil
+}
+
+// List returns the registered webhooks, oldest first
+func (s *HTTPWebhookService) List(ctx context.Context) ([]storage.Webhook, error) {
+	webhooks, err := s.store.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = []storage.Webhook{}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+func (s *HTTPWebhookService) Delete(ctx context.Context, id string) (bool, error) {
+	return s.store.DeleteWebhook(ctx, id)
+}
+
+// NotifyNewVersion delivers the event to every webhook in parallel in the background and
+// returns right away. The deliveries outlive the request, bounded by deliveryTimeout.
+// Failures are logged, change detection must not fail because a consumer is down.
+func (s *HTTPWebhookService) NotifyNewVersion(ctx context.Context, event DocumentVersionEvent) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.deliveryTimeout())
+	s.deliveries.Add(1)
+	go func() {
+		defer s.deliveries.Done()
+		defer cancel()
+		s.notify(ctx, event)
+	}()
+}
+
+// Wait blocks until the notifications sent so far are delivered or given up on
+func (s *HTTPWebhookService) Wait() {
+	s.deliveries.Wait()
+}
+
+// deliveryTimeout bounds the delivery of a notification: every attempt timing out and the
+// backoffs between them
+func (s *HTTPWebhookService) deliveryTimeout() time.Duration {
+	attempts := s.config.WebhookMaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	attemptTimeout := s.client.Timeout
+	if attemptTimeout <= 0 {
+		attemptTimeout = time.Minute
+	}
+	return time.Duration(attempts)*attemptTimeout + s.backoff<<(attempts-1)
+}
+
+func (s *HTTPWebhookService) notify(ctx context.Context, event DocumentVersionEvent) {
+	log := logger.WithContext(ctx)
+
+	webhooks, err := s.store.ListWebhooks(ctx)
+	if err != nil {
+		log.Warn("Failed to load webhooks", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	payload := WebhookPayload{
+		Id:         time.Now().UTC().Format("20060102T150405Z") + "-" + randomSuffix(),
+		Event:      EventDocumentVersionCreated,
+		OccurredAt: time.Now().UTC(),
+		Document:   event,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Warn("Failed to marshal webhook payload", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, webhook := range webhooks {
+		wg.Add(1)
+		go func(webhook storage.Webhook) {
+			defer wg.Done()
+			s.deliver(ctx, webhook, payload, body)
+		}(webhook)
+	}
+	wg.Wait()
+}
+
+// deliver sends one payload to one webhook with retries and records the outcome
+func (s *HTTPWebhookService) deliver(ctx context.Context, webhook storage.Webhook, payload WebhookPayload, body []byte) {
+	log := logger.WithContext(ctx)
+
+	status, lastErr := sendWithRetries(ctx, s.config.WebhookMaxAttempts, s.backoff, func() (int, error) {
+		return postWebhookPayload(ctx, s.client, webhook.Url, webhook.Secret, payload.Event, payload.Id, body)
+	})
+
+	succeeded := lastErr == nil
+	if succeeded {
+		log.Info("Webhook delivered", map[string]interface{}{
+			"webhook_id": webhook.Id,
+			"delivery":   payload.Id,
+			"status":     status,
+		})
+	} else {
+		log.Warn("Webhook delivery failed", map[string]interface{}{
+			"webhook_id": webhook.Id,
+			"delivery":   payload.Id,
+			"status":     status,
+			"error":      lastErr.Error(),
+		})
+	}
+
+	if err := s.store.RecordDelivery(ctx, webhook.Id, status, succeeded, time.Now()); err != nil {
+		log.Warn("Failed to record webhook delivery", map[string]interface{}{
+			"webhook_id": webhook.Id,
+			"error":      err.Error(),
+		})
+	}
+}
+
+// sendWithRetries calls send up to attempts times, with exponential backoff between
+// retryable failures, and returns the outcome of the last attempt
+func sendWithRetries(ctx context.Context, attempts int, backoff time.Duration, send func() (int, error)) (int, error) {
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	status := 0
+	var lastErr error
+retry:
+	for attempt := 1; attempt <= attempts; attempt++ {
+		status, lastErr = send()
+		if lastErr == nil || !retryableWebhookStatus(status) || attempt == attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			break retry
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return status, lastErr
+}
+
+// postWebhookPayload makes a single signed delivery attempt, returning the response status
+// (0 when the request failed) and an error unless the receiver answered with a 2xx status
+func postWebhookPayload(ctx context.Context, client *http.Client, targetUrl string, secret string, event string, deliveryId string, body []byte) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, targetUrl, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventHeader, event)
+	request.Header.Set(WebhookDeliveryHeader, deliveryId)
+	request.Header.Set(WebhookTimestampHeader, timestamp)
+	request.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(secret, timestamp, body))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("webhook returned status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// retryableWebhookStatus reports whether a failed attempt is worth retrying: network
+// errors (status 0), throttling and server errors
+func retryableWebhookStatus(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
+
+// validateHttpsUrl trims a URL to deliver to and checks it is an absolute https:// URL
+func validateHttpsUrl(field string, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return "", errors.NewValidationError(field + " must be an absolute https:// URL")
+	}
+	return value, nil
+}
+
+// newWebhookSecret generates the hex secret payloads are signed with
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// SignWebhookPayload computes the hex HMAC-SHA256 of "<timestamp>.<body>" with the webhook
+// secret, receivers recompute it and compare it to the "sha256=" header value
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"teletubpax-api/config"
+	"teletubpax-api/storage"
+)
+
+type memoryWebhookStore struct {
+	mu         sync.Mutex
+	webhooks   map[string]storage.Webhook
+	deliveries map[string][]int
+}
+
+func newMemoryWebhookStore(webhooks ...storage.Webhook) *memoryWebhookStore {
+	store := &memoryWebhookStore{webhooks: map[string]storage.Webhook{}, deliveries: map[string][]int{}}
+	for _, webhook := range webhooks {
+		store.webhooks[webhook.Id] = webhook
+	}
+	return store
+}
+
+func (m *memoryWebhookStore) ListWebhooks(ctx context.Context) ([]storage.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	webhooks := make([]storage.Webhook, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+func (m *memoryWebhookStore) PutWebhook(ctx context.Context, webhook *storage.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhooks[webhook.Id] = *webhook
+	return nil
+}
+
+func (m *memoryWebhookStore) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.webhooks[id]
+	delete(m.webhooks, id)
+	return ok, nil
+}
+
+func (m *memoryWebhookStore) RecordDelivery(ctx context.Context, id string, status int, succeeded bool, deliveredAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[id] = append(m.deliveries[id], status)
+	return nil
+}
+
+type recordingNotifier struct {
+	events []DocumentVersionEvent
+}
+
+func (r *recordingNotifier) NotifyNewVersion(ctx context.Context, event DocumentVersionEvent) {
+	r.events = append(r.events, event)
+}
+
+func webhookConfig() *config.Config {
+	return &config.Config{WebhookMaxAttempts: 3, WebhookTimeoutSeconds: 5}
+}
+
+func TestWebhookRegister_ValidatesUrlAndReturnsSecret(t *testing.T) {
+	service := NewHTTPWebhookService(newMemoryWebhookStore(), webhookConfig())
+
+	for _, url := range []string{"http://portal.example.com/hook", "portal.example.com", "https://"} {
+		if _, err := service.Register(context.Background(), url, ""); err == nil {
+			t.Errorf("expected %q to be rejected", url)
+		}
+	}
+
+	webhook, err := service.Register(context.Background(), "https://portal.example.com/hook", "What's new")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(webhook.Secret) != 64 {
+		t.Errorf("expected a 32 byte hex secret, got %q", webhook.Secret)
+	}
+
+	encoded, _ := json.Marshal(webhook)
+	var decoded map[string]interface{}
+	json.Unmarshal(encoded, &decoded)
+	if decoded["secret"] != webhook.Secret {
+		t.Errorf("expected the secret in the registration response, got %s", encoded)
+	}
+
+	listed, _ := service.List(context.Background())
+	encoded, _ = json.Marshal(listed)
+	if len(listed) != 1 || strings.Contains(string(encoded), webhook.Secret) {
+		t.Errorf("expected the listed webhook without its secret, got %s", encoded)
+	}
+}
+
+func TestWebhookNotify_SignsPayload(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(WebhookTimestampHeader)
+		if r.Header.Get(WebhookSignatureHeader) != "sha256="+SignWebhookPayload("secret", timestamp, body) {
+			t.Errorf("invalid signature %q", r.Header.Get(WebhookSignatureHeader))
+		}
+		if r.Header.Get(WebhookEventHeader) != EventDocumentVersionCreated {
+			t.Errorf("unexpected event header %q", r.Header.Get(WebhookEventHeader))
+		}
+
+		var payload WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		if payload.Document.Link != "https://b/waive-2.pdf" || payload.Document.PreviousVersion != 1 {
+			t.Errorf("unexpected payload document %+v", payload.Document)
+		}
+		received.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := newMemoryWebhookStore(storage.Webhook{Id: "portal", Url: server.URL, Secret: "secret"})
+	service := NewHTTPWebhookService(store, webhookConfig())
+
+	service.NotifyNewVersion(context.Background(), DocumentVersionEvent{
+		Link: "https://b/waive-2.pdf", Topic: "waive", Version: 2, PreviousLink: "https://b/waive-1.pdf", PreviousVersion: 1,
+	})
+	service.Wait()
+
+	if received.Load() != 1 {
+		t.Fatalf("expected one delivery, got %d", received.Load())
+	}
+	if got := store.deliveries["portal"]; len(got) != 1 || got[0] != http.StatusNoContent {
+		t.Errorf("expected a recorded 204 delivery, got %v", got)
+	}
+}
+
+func TestWebhookNotify_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	store := newMemoryWebhookStore(storage.Webhook{Id: "portal", Url: server.URL, Secret: "secret"})
+	service := NewHTTPWebhookService(store, webhookConfig())
+	service.backoff = time.Millisecond
+
+	service.NotifyNewVersion(context.Background(), DocumentVersionEvent{Link: "https://b/waive-2.pdf"})
+	service.Wait()
+
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+	if got := store.deliveries["portal"]; len(got) != 1 || got[0] != http.StatusOK {
+		t.Errorf("expected the final 200 recorded, got %v", got)
+	}
+}
+
+func TestWebhookNotify_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	store := newMemoryWebhookStore(storage.Webhook{Id: "portal", Url: server.URL, Secret: "secret"})
+	service := NewHTTPWebhookService(store, webhookConfig())
+	service.backoff = time.Millisecond
+
+	service.NotifyNewVersion(context.Background(), DocumentVersionEvent{Link: "https://b/waive-2.pdf"})
+	service.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected a single attempt, got %d", calls.Load())
+	}
+	if got := store.deliveries["portal"]; len(got) != 1 || got[0] != http.StatusGone {
+		t.Errorf("expected the 410 recorded, got %v", got)
+	}
+}
+
+func TestWebhookNotify_DeliversInBackground(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	store := newMemoryWebhookStore(storage.Webhook{Id: "portal", Url: server.URL, Secret: "secret"})
+	service := NewHTTPWebhookService(store, webhookConfig())
+
+	// The request that found the version is done before the receiver answers
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	service.NotifyNewVersion(ctx, DocumentVersionEvent{Link: "https://b/waive-2.pdf"})
+	cancel()
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected the notification not to wait for the receiver, took %v", elapsed)
+	}
+
+	close(release)
+	service.Wait()
+	if got := store.deliveries["portal"]; len(got) != 1 || got[0] != http.StatusOK {
+		t.Errorf("expected the delivery to outlive the request, got %v", got)
+	}
+}
+
+func TestResummarize_NotifiesNewVersionsOnce(t *testing.T) {
+	client := &mockOpenSearchClient{documents: testInventory()}
+	summaries := &memorySummaryStore{}
+	notifier := &recordingNotifier{}
+	service := NewBedrockDocumentResummarizeService(client, summaries, &memoryCheckpointStore{}, notifier, nil, &config.Config{ResummarizeConcurrency: 1})
+
+	if _, err := service.Run(context.Background(), ResummarizeOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notifier.events) != 1 {
+		t.Fatalf("expected one new version, got %+v", notifier.events)
+	}
+	event := notifier.events[0]
+	if event.Link != "https://b/content/2025/05/waive-2.pdf" || event.PreviousLink != "https://b/content/2025/01/waive-1.pdf" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if event.ChangeSummary == "" {
+		t.Error("expected the change summary in the event")
+	}
+
+	// A restarted run regenerates summaries without notifying again
+	if _, err := service.Run(context.Background(), ResummarizeOptions{Restart: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notifier.events) != 1 {
+		t.Errorf("expected no new notifications on a re-run, got %d", len(notifier.events))
+	}
+}
@@ -0,0 +1,60 @@
+package stages
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Stages of the answer pipeline
+const (
+	Normalization = "normalization"  // Rewriting the question with the normalization dictionary
+	AnswerCache   = "answer_cache"   // Reading and writing the answer cache
+	Clarification = "clarification"  // Checking whether the question needs a clarification prompt
+	AnswerBackend = "answer_backend" // Answering with the selected backend, including retries
+	Retrieval     = "retrieval"      // Retrieving chunks from the knowledge bases (retrieval-converse)
+	Generation    = "generation"     // Generating the answer from the retrieved chunks (retrieval-converse)
+)
+
+// Recorder gathers the time spent in each stage of the pipeline while serving one request,
+// for the load test and benchmarks to report where the time goes
+type Recorder struct {
+	mu        sync.Mutex
+	durations map[string]time.Duration
+}
+
+type contextKey struct{}
+
+// WithRecorder attaches a new recorder to the context of a request
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	recorder := &Recorder{durations: map[string]time.Duration{}}
+	return context.WithValue(ctx, contextKey{}, recorder), recorder
+}
+
+// Start starts timing a stage and returns the function ending it, usually deferred. It does
+// nothing when the context has no recorder, so requests served normally pay no more than the
+// context lookup. A stage entered several times, e.g. on retries, adds up.
+func Start(ctx context.Context, stage string) func() {
+	recorder, ok := ctx.Value(contextKey{}).(*Recorder)
+	if !ok {
+		return func() {}
+	}
+	startTime := time.Now()
+	return func() {
+		elapsed := time.Since(startTime)
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		recorder.durations[stage] += elapsed
+	}
+}
+
+// Durations returns the time spent in each stage that was entered
+func (r *Recorder) Durations() map[string]time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	durations := make(map[string]time.Duration, len(r.durations))
+	for stage, duration := range r.durations {
+		durations[stage] = duration
+	}
+	return durations
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"teletubpax-api/aws"
+	"teletubpax-api/cache"
+)
+
+// answerKeyPrefix keeps cached answers apart from the other values of a shared cache.Store
+const answerKeyPrefix = "answer:"
+
+// CachedAnswer is a question search answer kept for repeated questions
+type CachedAnswer struct {
+	Answer           string         `json:"answer"`
+	RelatedDocuments []string       `json:"relatedDocuments"`
+	Confidence       *float64       `json:"confidence,omitempty"` // Set when the answer was scored
+	Citations        []aws.Citation `json:"citations,omitempty"`
+	CachedAt         time.Time      `json:"cachedAt"`
+}
+
+// AnswerCache keeps answers by question key for a limited time
+type AnswerCache interface {
+	// Get returns the cached answer, nil when there is none or it expired
+	Get(ctx context.Context, key string) (*CachedAnswer, error)
+	Set(ctx context.Context, key string, answer *CachedAnswer, ttl time.Duration) error
+}
+
+// StoreAnswerCache keeps answers as JSON in a cache.Store, under "answer:" keys
+type StoreAnswerCache struct {
+	store cache.Store
+}
+
+func NewStoreAnswerCache(store cache.Store) *StoreAnswerCache {
+	return &StoreAnswerCache{
+		store: store,
+	}
+}
+
+func (c *StoreAnswerCache) Get(ctx context.Context, key string) (*CachedAnswer, error) {
+	data, err := c.store.Get(ctx, answerKeyPrefix+key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached answer: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var answer CachedAnswer
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return nil, fmt.Errorf("failed to parse cached answer: %w", err)
+	}
+	return &answer, nil
+}
+
+func (c *StoreAnswerCache) Set(ctx context.Context, key string, answer *CachedAnswer, ttl time.Duration) error {
+	data, err := json.Marshal(answer)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached answer: %w", err)
+	}
+	if err := c.store.Set(ctx, answerKeyPrefix+key, data, ttl); err != nil {
+		return fmt.Errorf("failed to cache answer: %w", err)
+	}
+	return nil
+}
+
+// MemoryAnswerCache keeps answers in the instance's memory, evicting the least recently used
+// answer beyond maxEntries
+type MemoryAnswerCache struct {
+	*StoreAnswerCache
+	store *cache.MemoryStore
+}
+
+func NewMemoryAnswerCache(maxEntries int) *MemoryAnswerCache {
+	store := cache.NewMemoryStore(maxEntries)
+	return &MemoryAnswerCache{
+		StoreAnswerCache: NewStoreAnswerCache(store),
+		store:            store,
+	}
+}
+
+// Len returns the number of cached answers, including expired ones not yet evicted
+func (c *MemoryAnswerCache) Len() int {
+	return c.store.Len()
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryAnswerCache_GetAndSet(t *testing.T) {
+	cache := NewMemoryAnswerCache(10)
+	ctx := context.Background()
+
+	if answer, err := cache.Get(ctx, "fees"); err != nil || answer != nil {
+		t.Fatalf("expected a miss on an empty cache, got %v %v", answer, err)
+	}
+
+	cache.Set(ctx, "fees", &CachedAnswer{Answer: "ค่าธรรมเนียม 10 บาท", RelatedDocuments: []string{"fees.pdf"}}, time.Minute)
+	answer, err := cache.Get(ctx, "fees")
+	if err != nil || answer == nil || answer.Answer != "ค่าธรรมเนียม 10 บาท" || len(answer.RelatedDocuments) != 1 {
+		t.Errorf("expected the cached answer, got %+v %v", answer, err)
+	}
+}
+
+func TestMemoryAnswerCache_Expires(t *testing.T) {
+	cache := NewMemoryAnswerCache(10)
+	ctx := context.Background()
+
+	cache.Set(ctx, "fees", &CachedAnswer{Answer: "answer"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if answer, _ := cache.Get(ctx, "fees"); answer != nil {
+		t.Errorf("expected the expired answer to be gone, got %+v", answer)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected the expired answer to be removed, %d left", cache.Len())
+	}
+}
+
+func TestMemoryAnswerCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryAnswerCache(2)
+	ctx := context.Background()
+
+	cache.Set(ctx, "a", &CachedAnswer{Answer: "a"}, time.Minute)
+	cache.Set(ctx, "b", &CachedAnswer{Answer: "b"}, time.Minute)
+	cache.Get(ctx, "a")
+	cache.Set(ctx, "c", &CachedAnswer{Answer: "c"}, time.Minute)
+
+	if answer, _ := cache.Get(ctx, "b"); answer != nil {
+		t.Error("expected the least recently used answer to be evicted")
+	}
+	if answer, _ := cache.Get(ctx, "a"); answer == nil {
+		t.Error("expected the recently read answer to be kept")
+	}
+	if answer, _ := cache.Get(ctx, "c"); answer == nil {
+		t.Error("expected the new answer to be kept")
+	}
+
+	// Replacing an answer does not grow the cache
+	cache.Set(ctx, "c", &CachedAnswer{Answer: "c2"}, time.Minute)
+	if answer, _ := cache.Get(ctx, "c"); answer == nil || answer.Answer != "c2" || cache.Len() != 2 {
+		t.Errorf("expected the answer to be replaced in place, got %+v with %d entries", answer, cache.Len())
+	}
+}
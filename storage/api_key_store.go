@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	stdErrors "errors"
+	"strconv"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ApiKey identifies an internal consumer of the API. Only the hash of the key's secret is
+// stored, the key itself is returned once when it is created.
+type ApiKey struct {
+	Id         string     `dynamodbav:"id" json:"id"`
+	Name       string     `dynamodbav:"name" json:"name"`
+	TenantId   string     `dynamodbav:"tenantId" json:"tenantId,omitempty"` // X-Tenant-Id of the key's requests
+	SecretHash string     `dynamodbav:"secretHash" json:"-"`                // Hex SHA-256 of the secret
+	DailyQuota int64      `dynamodbav:"dailyQuota" json:"dailyQuota"`       // Requests per UTC day, 0 for no limit
+	CreatedAt  time.Time  `dynamodbav:"createdAt" json:"createdAt"`
+	RevokedAt  *time.Time `dynamodbav:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+type ApiKeyStore interface {
+	// GetApiKey returns nil without an error when the key does not exist
+	GetApiKey(ctx context.Context, id string) (*ApiKey, error)
+	ListApiKeys(ctx context.Context) ([]ApiKey, error)
+	PutApiKey(ctx context.Context, key *ApiKey) error
+	// RevokeApiKey keeps the first revocation time of a key revoked twice. It returns false
+	// without an error when the key does not exist.
+	RevokeApiKey(ctx context.Context, id string, revokedAt time.Time) (bool, error)
+	// SetApiKeyQuota returns false without an error when the key does not exist
+	SetApiKeyQuota(ctx context.Context, id string, dailyQuota int64) (bool, error)
+}
+
+type DynamoDBApiKeyStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBApiKeyStore(cfg aws.Config, tableName string) *DynamoDBApiKeyStore {
+	return &DynamoDBApiKeyStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBApiKeyStore) GetApiKey(ctx context.Context, id string) (*ApiKey, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read API key", err)
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	var key ApiKey
+	if err := attributevalue.UnmarshalMap(output.Item, &key); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse API key", err)
+	}
+	return &key, nil
+}
+
+func (s *DynamoDBApiKeyStore) ListApiKeys(ctx context.Context) ([]ApiKey, error) {
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:      aws.String(s.tableName),
+		ConsistentRead: aws.Bool(true),
+	})
+
+	var keys []ApiKey
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to scan API keys", err)
+		}
+
+		var pageKeys []ApiKey
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageKeys); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse API keys", err)
+		}
+		keys = append(keys, pageKeys...)
+	}
+	return keys, nil
+}
+
+func (s *DynamoDBApiKeyStore) PutApiKey(ctx context.Context, key *ApiKey) error {
+	item, err := attributevalue.MarshalMap(key)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal API key", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write API key", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBApiKeyStore) RevokeApiKey(ctx context.Context, id string, revokedAt time.Time) (bool, error) {
+	return s.update(ctx, id, "SET revokedAt = if_not_exists(revokedAt, :revokedAt)", map[string]types.AttributeValue{
+		":revokedAt": &types.AttributeValueMemberS{Value: revokedAt.UTC().Format(time.RFC3339Nano)},
+	}, "failed to revoke API key")
+}
+
+func (s *DynamoDBApiKeyStore) SetApiKeyQuota(ctx context.Context, id string, dailyQuota int64) (bool, error) {
+	return s.update(ctx, id, "SET dailyQuota = :dailyQuota", map[string]types.AttributeValue{
+		":dailyQuota": &types.AttributeValueMemberN{Value: strconv.FormatInt(dailyQuota, 10)},
+	}, "failed to set API key quota")
+}
+
+// update applies the update expression to an existing key, reporting false when there is none
+func (s *DynamoDBApiKeyStore) update(ctx context.Context, id string, updateExpression string, values map[string]types.AttributeValue, message string) (bool, error) {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: values,
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if stdErrors.As(err, &conditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.NewAWSServiceError(message, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// AuditRecord is what the bot told a caller in answer to one question, or a document an
+// admin deleted, kept for compliance
+type AuditRecord struct {
+	Id                 string      `json:"id"`
+	Timestamp          time.Time   `json:"timestamp"`
+	Action             string      `json:"action,omitempty"` // Empty for questions, "document-deletion" for deleted documents
+	Caller             AuditCaller `json:"caller"`
+	Document           string      `json:"document,omitempty"` // s3:// URI of the deleted document
+	Question           string      `json:"question"`
+	KnowledgeBases     []string    `json:"knowledgeBases"` // IDs of the knowledge bases queried
+	ModelIds           []string    `json:"modelIds"`       // Models called while answering, embeddings included
+	RetrievedDocuments []string    `json:"retrievedDocuments"`
+	AnswerSha256       string      `json:"answerSha256,omitempty"` // Hex SHA-256 of the answer, empty when none was given
+	Outcome            string      `json:"outcome"`                // "answered", "clarification" or "error", "deleted" or "error" for deletions
+}
+
+// AuditCaller identifies who asked the question
+type AuditCaller struct {
+	UserId    string `json:"userId,omitempty"` // Empty for anonymous callers
+	Username  string `json:"username,omitempty"`
+	Email     string `json:"email,omitempty"`
+	TenantId  string `json:"tenantId,omitempty"`
+	SessionId string `json:"sessionId,omitempty"` // The session limit key: user, X-Session-Id or client IP
+}
+
+type AuditStore interface {
+	// Append writes a record once, a record with the same ID is never replaced
+	Append(ctx context.Context, record *AuditRecord) error
+	// Get returns nil without an error for unknown records
+	Get(ctx context.Context, id string) (*AuditRecord, error)
+}
+
+// S3AuditStore writes every record as its own object under <prefix>/YYYY/MM/DD/, for Athena.
+// The bucket must have S3 Object Lock enabled: every object is locked in compliance mode for
+// the retention period, so not even the account root user can change or delete it, and a
+// conditional write refuses to replace an existing key.
+type S3AuditStore struct {
+	client    *s3.Client
+	bucket    string
+	prefix    string
+	retention time.Duration
+}
+
+func NewS3AuditStore(cfg aws.Config, bucket string, prefix string, retentionDays int) *S3AuditStore {
+	return &S3AuditStore{
+		client:    s3.NewFromConfig(cfg),
+		bucket:    bucket,
+		prefix:    strings.Trim(prefix, "/"),
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+	}
+}
+
+func (s *S3AuditStore) Append(ctx context.Context, record *AuditRecord) error {
+	key, ok := auditRecordKey(s.prefix, record.Id)
+	if !ok {
+		return fmt.Errorf("invalid audit record ID %q", record.Id)
+	}
+	body, err := json.Marshal(record)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal audit record", err)
+	}
+
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:                    aws.String(s.bucket),
+		Key:                       aws.String(key),
+		Body:                      bytes.NewReader(body),
+		ContentType:               aws.String("application/json"),
+		IfNoneMatch:               aws.String("*"),
+		ObjectLockMode:            types.ObjectLockModeCompliance,
+		ObjectLockRetainUntilDate: aws.Time(record.Timestamp.Add(s.retention)),
+		ChecksumAlgorithm:         types.ChecksumAlgorithmSha256,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write audit record", err)
+	}
+	return nil
+}
+
+func (s *S3AuditStore) Get(ctx context.Context, id string) (*AuditRecord, error) {
+	key, ok := auditRecordKey(s.prefix, id)
+	if !ok {
+		return nil, nil
+	}
+
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if stdErrors.As(err, &noSuchKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read audit record", err)
+	}
+	defer output.Body.Close()
+
+	body, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read audit record", err)
+	}
+	var record AuditRecord
+	if err := json.Unmarshal(body, &record); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse audit record", err)
+	}
+	return &record, nil
+}
+
+// auditRecordKey is the key of a record, partitioned by the day in its ID, e.g.
+// audit/2026/10/15/20261015T093000Z-1a2b3c4d5e6f7a8b.json
+func auditRecordKey(prefix string, id string) (string, bool) {
+	day, err := time.Parse("20060102", strings.SplitN(id, "T", 2)[0])
+	if err != nil || strings.ContainsAny(id, "/\\") {
+		return "", false
+	}
+	key := day.Format("2006/01/02") + "/" + id + ".json"
+	if prefix != "" {
+		key = prefix + "/" + key
+	}
+	return key, true
+}
+
+// MemoryAuditStore keeps records in the instance's memory, for tests
+type MemoryAuditStore struct {
+	mu      sync.Mutex
+	records map[string]AuditRecord
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{
+		records: map[string]AuditRecord{},
+	}
+}
+
+func (s *MemoryAuditStore) Append(ctx context.Context, record *AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.records[record.Id]; ok {
+		return fmt.Errorf("audit record %s already exists", record.Id)
+	}
+	s.records[record.Id] = *record
+	return nil
+}
+
+func (s *MemoryAuditStore) Get(ctx context.Context, id string) (*AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
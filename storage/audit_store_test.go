@@ -0,0 +1,23 @@
+package storage
+
+import "testing"
+
+func TestAuditRecordKey(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		id       string
+		expected string
+		ok       bool
+	}{
+		{prefix: "audit", id: "20261015T093000Z-1a2b3c4d", expected: "audit/2026/10/15/20261015T093000Z-1a2b3c4d.json", ok: true},
+		{prefix: "", id: "20261015T093000Z-1a2b3c4d", expected: "2026/10/15/20261015T093000Z-1a2b3c4d.json", ok: true},
+		{prefix: "audit", id: "not-an-id"},
+		{prefix: "audit", id: "20261015T093000Z-../../other"},
+	}
+	for _, test := range tests {
+		key, ok := auditRecordKey(test.prefix, test.id)
+		if ok != test.ok || key != test.expected {
+			t.Errorf("%q: expected %q %v, got %q %v", test.id, test.expected, test.ok, key, ok)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"teletubpax-api/auth"
+	"teletubpax-api/cache"
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ChatConnection is an open API Gateway WebSocket chat connection. Every message is a
+// separate invocation, so the caller authenticated on connect and the conversation its
+// questions continue are kept here between them.
+type ChatConnection struct {
+	ConnectionId string            `dynamodbav:"connectionId" json:"connectionId"`
+	Identity     *auth.Identity    `dynamodbav:"identity,omitempty" json:"identity,omitempty"`
+	ApiKey       string            `dynamodbav:"apiKey,omitempty" json:"apiKey,omitempty"`       // Of the connection request, authenticated again for each question
+	Headers      map[string]string `dynamodbav:"headers,omitempty" json:"headers,omitempty"`     // Request headers that change the answer, e.g. X-Tenant-Id
+	SessionId    string            `dynamodbav:"sessionId,omitempty" json:"sessionId,omitempty"` // Of the last answer, continued by the next question
+	ExpiresAt    int64             `dynamodbav:"expiresAt" json:"expiresAt"`                     // Unix seconds
+}
+
+type ChatConnectionStore interface {
+	// GetConnection returns nil without an error for unknown and expired connections
+	GetConnection(ctx context.Context, connectionId string) (*ChatConnection, error)
+	SaveConnection(ctx context.Context, connection *ChatConnection) error
+	DeleteConnection(ctx context.Context, connectionId string) error
+}
+
+// DynamoDBChatConnectionStore shares connections between Lambda instances. Expired items are
+// removed by the table's TTL on expiresAt, which can lag, so expiry is also checked on read.
+type DynamoDBChatConnectionStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBChatConnectionStore(cfg aws.Config, tableName string) *DynamoDBChatConnectionStore {
+	return &DynamoDBChatConnectionStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBChatConnectionStore) GetConnection(ctx context.Context, connectionId string) (*ChatConnection, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"connectionId": &types.AttributeValueMemberS{Value: connectionId},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read chat connection", err)
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	var connection ChatConnection
+	if err := attributevalue.UnmarshalMap(output.Item, &connection); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse chat connection", err)
+	}
+	if connection.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+	return &connection, nil
+}
+
+func (s *DynamoDBChatConnectionStore) SaveConnection(ctx context.Context, connection *ChatConnection) error {
+	item, err := attributevalue.MarshalMap(connection)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal chat connection", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write chat connection", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBChatConnectionStore) DeleteConnection(ctx context.Context, connectionId string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"connectionId": &types.AttributeValueMemberS{Value: connectionId},
+		},
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to delete chat connection", err)
+	}
+	return nil
+}
+
+// chatConnectionKeyPrefix keeps chat connections apart from the other values of a shared cache.Store
+const chatConnectionKeyPrefix = "chat-connection:"
+
+// CacheChatConnectionStore keeps connections as JSON in a cache.Store, which expires them on
+// their ExpiresAt, for deployments sharing a Redis instead of a table. With a
+// cache.MemoryStore a message reaching another Lambda instance than its connection fails.
+type CacheChatConnectionStore struct {
+	store cache.Store
+}
+
+func NewCacheChatConnectionStore(store cache.Store) *CacheChatConnectionStore {
+	return &CacheChatConnectionStore{
+		store: store,
+	}
+}
+
+func (s *CacheChatConnectionStore) GetConnection(ctx context.Context, connectionId string) (*ChatConnection, error) {
+	data, err := s.store.Get(ctx, chatConnectionKeyPrefix+connectionId)
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read chat connection", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var connection ChatConnection
+	if err := json.Unmarshal(data, &connection); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse chat connection", err)
+	}
+	return &connection, nil
+}
+
+func (s *CacheChatConnectionStore) SaveConnection(ctx context.Context, connection *ChatConnection) error {
+	data, err := json.Marshal(connection)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal chat connection", err)
+	}
+	if err := s.store.Set(ctx, chatConnectionKeyPrefix+connection.ConnectionId, data, time.Until(time.Unix(connection.ExpiresAt, 0))); err != nil {
+		return errors.NewAWSServiceError("failed to write chat connection", err)
+	}
+	return nil
+}
+
+func (s *CacheChatConnectionStore) DeleteConnection(ctx context.Context, connectionId string) error {
+	if err := s.store.Delete(ctx, chatConnectionKeyPrefix+connectionId); err != nil {
+		return errors.NewAWSServiceError("failed to delete chat connection", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	contentCacheFileSuffix = ".txt"
+
+	// contentCacheFreeSpaceShare caps the cache at this share of the free space of its
+	// filesystem, so a small Lambda /tmp or a memory-backed tmpfs is not filled up
+	contentCacheFreeSpaceShare = 0.5
+)
+
+type contentCacheEntry struct {
+	size     int64
+	lastUsed time.Time
+}
+
+// DiskContentCache keeps extracted document text as files in a local directory, bounded by
+// total size with least recently used eviction. Entries written by an earlier process are
+// picked up on start, so a warm Lambda sandbox keeps its /tmp cache between invocations.
+type DiskContentCache struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+	entries  map[string]*contentCacheEntry // By file name
+	size     int64
+}
+
+// NewDiskContentCache creates the cache directory and indexes existing entries. maxBytes is
+// lowered to half of the free space of the filesystem when that is smaller.
+func NewDiskContentCache(dir string, maxBytes int64) (*DiskContentCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create content cache directory: %w", err)
+	}
+
+	cache := &DiskContentCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*contentCacheEntry),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content cache directory: %w", err)
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), contentCacheFileSuffix) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		cache.entries[file.Name()] = &contentCacheEntry{size: info.Size(), lastUsed: info.ModTime()}
+		cache.size += info.Size()
+	}
+
+	// Free space excludes what the cache already holds, which stays usable by the cache
+	if available, ok := availableDiskBytes(dir); ok {
+		if limit := int64(float64(available)*contentCacheFreeSpaceShare) + cache.size; limit < cache.maxBytes {
+			cache.maxBytes = limit
+		}
+	}
+
+	cache.mu.Lock()
+	cache.evict()
+	cache.mu.Unlock()
+	return cache, nil
+}
+
+// MaxBytes returns the effective size limit after the free space check
+func (c *DiskContentCache) MaxBytes() int64 {
+	return c.maxBytes
+}
+
+// Get returns the cached text for the key
+func (c *DiskContentCache) Get(key string) (string, bool) {
+	name := contentCacheFileName(key)
+
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	if ok {
+		entry.lastUsed = time.Now()
+	}
+	c.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		c.mu.Lock()
+		c.remove(name)
+		c.mu.Unlock()
+		return "", false
+	}
+	return string(data), true
+}
+
+// Put stores the text for the key, evicting the least recently used entries to stay within
+// the size limit. Text larger than the whole cache is not stored.
+func (c *DiskContentCache) Put(key string, text string) {
+	size := int64(len(text))
+	if size > c.maxBytes {
+		return
+	}
+
+	name := contentCacheFileName(key)
+	file, err := os.CreateTemp(c.dir, "put-*")
+	if err != nil {
+		return
+	}
+	_, writeErr := file.WriteString(text)
+	closeErr := file.Close()
+	if writeErr != nil || closeErr != nil || os.Rename(file.Name(), filepath.Join(c.dir, name)) != nil {
+		os.Remove(file.Name())
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if existing, ok := c.entries[name]; ok {
+		c.size -= existing.size
+	}
+	c.entries[name] = &contentCacheEntry{size: size, lastUsed: time.Now()}
+	c.size += size
+	c.evict()
+}
+
+// evict removes least recently used entries until the cache fits, c.mu must be held
+func (c *DiskContentCache) evict() {
+	if c.size <= c.maxBytes {
+		return
+	}
+
+	names := make([]string, 0, len(c.entries))
+	for name := range c.entries {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return c.entries[names[i]].lastUsed.Before(c.entries[names[j]].lastUsed)
+	})
+
+	for _, name := range names {
+		if c.size <= c.maxBytes {
+			return
+		}
+		os.Remove(filepath.Join(c.dir, name))
+		c.remove(name)
+	}
+}
+
+// remove drops an entry from the index, c.mu must be held
+func (c *DiskContentCache) remove(name string) {
+	if entry, ok := c.entries[name]; ok {
+		c.size -= entry.size
+		delete(c.entries, name)
+	}
+}
+
+// contentCacheFileName hashes the key, S3 keys contain slashes and non-ASCII characters
+func contentCacheFileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + contentCacheFileSuffix
+}
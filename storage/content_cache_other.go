@@ -0,0 +1,8 @@
+//go:build !unix
+
+package storage
+
+// availableDiskBytes is not supported on this platform, the configured limit applies as is
+func availableDiskBytes(dir string) (int64, bool) {
+	return 0, false
+}
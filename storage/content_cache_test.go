@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskContentCache_GetAndPut(t *testing.T) {
+	cache, err := NewDiskContentCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := cache.Get("bucket/content/fees-2.pdf@abc"); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+
+	cache.Put("bucket/content/fees-2.pdf@abc", "ค่าธรรมเนียม")
+	if text, ok := cache.Get("bucket/content/fees-2.pdf@abc"); !ok || text != "ค่าธรรมเนียม" {
+		t.Errorf("expected the cached text, got %q %v", text, ok)
+	}
+	if _, ok := cache.Get("bucket/content/fees-2.pdf@def"); ok {
+		t.Error("expected a miss for another ETag")
+	}
+}
+
+func TestDiskContentCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache, err := NewDiskContentCache(t.TempDir(), 25)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cache.Put("a", strings.Repeat("a", 10))
+	time.Sleep(time.Millisecond)
+	cache.Put("b", strings.Repeat("b", 10))
+	time.Sleep(time.Millisecond)
+	cache.Get("a")
+	time.Sleep(time.Millisecond)
+	cache.Put("c", strings.Repeat("c", 10))
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("expected the recently read entry to be kept")
+	}
+	if _, ok := cache.Get("c"); !ok {
+		t.Error("expected the new entry to be kept")
+	}
+
+	cache.Put("huge", strings.Repeat("x", 26))
+	if _, ok := cache.Get("huge"); ok {
+		t.Error("expected text larger than the cache not to be stored")
+	}
+}
+
+func TestDiskContentCache_ReusesExistingEntries(t *testing.T) {
+	dir := t.TempDir()
+	first, err := NewDiskContentCache(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first.Put("bucket/waive-1.pdf@abc", "waive v1")
+
+	second, err := NewDiskContentCache(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, ok := second.Get("bucket/waive-1.pdf@abc"); !ok || text != "waive v1" {
+		t.Errorf("expected the entry written by the earlier cache, got %q %v", text, ok)
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("expected a single cache file without leftovers, got %d", len(files))
+	}
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package storage
+
+import "syscall"
+
+// availableDiskBytes reports the free space of the filesystem holding dir
+func availableDiskBytes(dir string) (int64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, false
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), true
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// batchWriteLimit is the most requests DynamoDB accepts in one BatchWriteItem call
+const batchWriteLimit = 25
+
+// ConversationTurn is one question a user asked and the answer they were given
+type ConversationTurn struct {
+	UserId    string    `dynamodbav:"userId"`
+	TurnId    string    `dynamodbav:"turnId"` // Starts with the time it was asked, so turns sort by time
+	Question  string    `dynamodbav:"question"`
+	Answer    string    `dynamodbav:"answer"`
+	Citations []byte    `dynamodbav:"citations,omitempty"` // JSON of the answer's citations
+	CreatedAt time.Time `dynamodbav:"createdAt"`
+	ExpiresAt int64     `dynamodbav:"expiresAt"` // DynamoDB TTL, epoch seconds
+}
+
+type ConversationHistoryStore interface {
+	AddTurn(ctx context.Context, turn *ConversationTurn) error
+	// ListTurns returns up to limit unexpired turns of the user, newest first. With before
+	// set, only the turns older than the turn with that ID are returned.
+	ListTurns(ctx context.Context, userId string, before string, limit int) ([]ConversationTurn, error)
+	// DeleteTurns deletes every turn of the user, expired ones included, and returns how
+	// many were deleted
+	DeleteTurns(ctx context.Context, userId string) (int, error)
+}
+
+// DynamoDBConversationHistoryStore keeps turns in a table keyed by userId and turnId, so a
+// user's history is one query. Expired turns are removed by the table's TTL on expiresAt,
+// which can lag, so expiry is also checked on read.
+type DynamoDBConversationHistoryStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBConversationHistoryStore(cfg aws.Config, tableName string) *DynamoDBConversationHistoryStore {
+	return &DynamoDBConversationHistoryStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBConversationHistoryStore) AddTurn(ctx context.Context, turn *ConversationTurn) error {
+	item, err := attributevalue.MarshalMap(turn)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal conversation turn", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write conversation turn", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBConversationHistoryStore) ListTurns(ctx context.Context, userId string, before string, limit int) ([]ConversationTurn, error) {
+	keyCondition := "userId = :userId"
+	values := map[string]types.AttributeValue{
+		":userId": &types.AttributeValueMemberS{Value: userId},
+	}
+	if before != "" {
+		keyCondition = "userId = :userId AND turnId < :before"
+		values[":before"] = &types.AttributeValueMemberS{Value: before}
+	}
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		KeyConditionExpression:    aws.String(keyCondition),
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(int32(limit)),
+	})
+
+	now := time.Now().Unix()
+	turns := []ConversationTurn{}
+	for paginator.HasMorePages() && len(turns) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to query conversation history", err)
+		}
+
+		var pageTurns []ConversationTurn
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageTurns); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse conversation history", err)
+		}
+		for _, turn := range pageTurns {
+			if turn.ExpiresAt > now && len(turns) < limit {
+				turns = append(turns, turn)
+			}
+		}
+	}
+	return turns, nil
+}
+
+func (s *DynamoDBConversationHistoryStore) DeleteTurns(ctx context.Context, userId string) (int, error) {
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String("userId = :userId"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userId": &types.AttributeValueMemberS{Value: userId},
+		},
+		ProjectionExpression: aws.String("userId, turnId"),
+		ConsistentRead:       aws.Bool(true),
+	})
+
+	deleted := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return deleted, errors.NewAWSServiceError("failed to query conversation history", err)
+		}
+		for start := 0; start < len(page.Items); start += batchWriteLimit {
+			end := min(start+batchWriteLimit, len(page.Items))
+			if err := s.deleteKeys(ctx, page.Items[start:end]); err != nil {
+				return deleted, err
+			}
+			deleted += end - start
+		}
+	}
+	return deleted, nil
+}
+
+// deleteKeys deletes one batch of items by key, resending the requests DynamoDB leaves
+// unprocessed when throttled
+func (s *DynamoDBConversationHistoryStore) deleteKeys(ctx context.Context, keys []map[string]types.AttributeValue) error {
+	requests := make([]types.WriteRequest, 0, len(keys))
+	for _, key := range keys {
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+	}
+
+	for attempt := 0; len(requests) > 0; attempt++ {
+		if attempt == 5 {
+			return errors.NewAWSServiceError("failed to delete conversation history",
+				fmt.Errorf("%d turns left unprocessed", len(requests)))
+		}
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+
+		output, err := s.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{s.tableName: requests},
+		})
+		if err != nil {
+			return errors.NewAWSServiceError("failed to delete conversation history", err)
+		}
+		requests = output.UnprocessedItems[s.tableName]
+	}
+	return nil
+}
+
+// MemoryConversationHistoryStore keeps turns in the instance's memory, for tests
+type MemoryConversationHistoryStore struct {
+	mu    sync.Mutex
+	turns map[string][]ConversationTurn // By user, in insertion order
+}
+
+func NewMemoryConversationHistoryStore() *MemoryConversationHistoryStore {
+	return &MemoryConversationHistoryStore{
+		turns: map[string][]ConversationTurn{},
+	}
+}
+
+func (s *MemoryConversationHistoryStore) AddTurn(ctx context.Context, turn *ConversationTurn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.turns[turn.UserId] = append(s.turns[turn.UserId], *turn)
+	return nil
+}
+
+func (s *MemoryConversationHistoryStore) ListTurns(ctx context.Context, userId string, before string, limit int) ([]ConversationTurn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().Unix()
+	turns := []ConversationTurn{}
+	for _, turn := range s.turns[userId] {
+		if turn.ExpiresAt > now && (before == "" || turn.TurnId < before) {
+			turns = append(turns, turn)
+		}
+	}
+	sort.Slice(turns, func(i, j int) bool { return turns[i].TurnId > turns[j].TurnId })
+	if len(turns) > limit {
+		turns = turns[:limit]
+	}
+	return turns, nil
+}
+
+func (s *MemoryConversationHistoryStore) DeleteTurns(ctx context.Context, userId string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := len(s.turns[userId])
+	delete(s.turns, userId)
+	return deleted, nil
+}
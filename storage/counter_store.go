@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	stdErrors "errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CounterStore keeps expiring counters, e.g. for per-session rate limits
+type CounterStore interface {
+	// Increment adds amount to the counter and returns the new value. A new counter expires at
+	// expiresAt; incrementing an existing counter does not extend it.
+	Increment(ctx context.Context, key string, amount int64, expiresAt time.Time) (int64, error)
+}
+
+// DynamoDBCounterStore shares counters between instances. Expired items are removed by the
+// table's TTL on expiresAt, which can lag, so expiry is also checked on read.
+type DynamoDBCounterStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBCounterStore(cfg aws.Config, tableName string) *DynamoDBCounterStore {
+	return &DynamoDBCounterStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBCounterStore) Increment(ctx context.Context, key string, amount int64, expiresAt time.Time) (int64, error) {
+	now := time.Now()
+	output, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"key": &types.AttributeValueMemberS{Value: key},
+		},
+		UpdateExpression: aws.String("ADD #count :amount SET expiresAt = if_not_exists(expiresAt, :expiresAt)"),
+		// Restart counters whose TTL deletion is still pending
+		ConditionExpression:      aws.String("attribute_not_exists(expiresAt) OR expiresAt > :now"),
+		ExpressionAttributeNames: map[string]string{"#count": "count"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":amount":    &types.AttributeValueMemberN{Value: strconv.FormatInt(amount, 10)},
+			":expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+			":now":       &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if stdErrors.As(err, &conditionFailed) {
+			return s.reset(ctx, key, amount, expiresAt)
+		}
+		return 0, errors.NewAWSServiceError("failed to increment counter", err)
+	}
+
+	count, ok := output.Attributes["count"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, errors.NewAWSServiceError("counter update returned no count", nil)
+	}
+	return strconv.ParseInt(count.Value, 10, 64)
+}
+
+func (s *DynamoDBCounterStore) reset(ctx context.Context, key string, amount int64, expiresAt time.Time) (int64, error) {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]types.AttributeValue{
+			"key":       &types.AttributeValueMemberS{Value: key},
+			"count":     &types.AttributeValueMemberN{Value: strconv.FormatInt(amount, 10)},
+			"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return 0, errors.NewAWSServiceError("failed to reset counter", err)
+	}
+	return amount, nil
+}
+
+type memoryCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// MemoryCounterStore keeps counters in the instance's memory, so limits apply per instance
+type MemoryCounterStore struct {
+	mu        sync.Mutex
+	counters  map[string]*memoryCounter
+	lastSweep time.Time
+}
+
+func NewMemoryCounterStore() *MemoryCounterStore {
+	return &MemoryCounterStore{
+		counters: map[string]*memoryCounter{},
+	}
+}
+
+func (s *MemoryCounterStore) Increment(ctx context.Context, key string, amount int64, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, counter := range s.counters {
+			if !now.Before(counter.expiresAt) {
+				delete(s.counters, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	counter, ok := s.counters[key]
+	if !ok || !now.Before(counter.expiresAt) {
+		counter = &memoryCounter{expiresAt: expiresAt}
+		s.counters[key] = counter
+	}
+	counter.count += amount
+	return counter.count, nil
+}
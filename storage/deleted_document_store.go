@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	stdErrors "errors"
+	"strconv"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DeletedDocument marks a soft-deleted document. The source object stays in S3 until
+// PurgeAfter so the document can be restored without re-uploading and re-syncing. Purged
+// records are kept until ExpiresAt (DynamoDB TTL) so the document stays excluded until the
+// next knowledge base sync drops its chunks.
+type DeletedDocument struct {
+	SourceUri  string     `dynamodbav:"sourceUri" json:"sourceUri"`
+	Link       string     `dynamodbav:"link" json:"link"`
+	DeletedAt  time.Time  `dynamodbav:"deletedAt" json:"deletedAt"`
+	PurgeAfter time.Time  `dynamodbav:"purgeAfter" json:"purgeAfter"`
+	PurgedAt   *time.Time `dynamodbav:"purgedAt,omitempty" json:"purgedAt,omitempty"`
+	ExpiresAt  int64      `dynamodbav:"expiresAt,omitempty" json:"-"`
+}
+
+type DeletedDocumentStore interface {
+	ListDeleted(ctx context.Context) ([]DeletedDocument, error)
+	// GetDeleted returns nil without an error when the document is not deleted
+	GetDeleted(ctx context.Context, sourceUri string) (*DeletedDocument, error)
+	// MarkDeleted returns false without an error when the document is already deleted
+	MarkDeleted(ctx context.Context, document *DeletedDocument) (bool, error)
+	// Restore removes the mark of a document that has not been purged, returning false
+	// when there is no such mark
+	Restore(ctx context.Context, sourceUri string) (bool, error)
+	MarkPurged(ctx context.Context, sourceUri string, purgedAt time.Time, expiresAt time.Time) error
+}
+
+type DynamoDBDeletedDocumentStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBDeletedDocumentStore(cfg aws.Config, tableName string) *DynamoDBDeletedDocumentStore {
+	return &DynamoDBDeletedDocumentStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBDeletedDocumentStore) ListDeleted(ctx context.Context) ([]DeletedDocument, error) {
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:      aws.String(s.tableName),
+		ConsistentRead: aws.Bool(true),
+	})
+
+	var documents []DeletedDocument
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to scan deleted documents", err)
+		}
+
+		var pageDocuments []DeletedDocument
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageDocuments); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse deleted documents", err)
+		}
+		documents = append(documents, pageDocuments...)
+	}
+	return documents, nil
+}
+
+func (s *DynamoDBDeletedDocumentStore) GetDeleted(ctx context.Context, sourceUri string) (*DeletedDocument, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"sourceUri": &types.AttributeValueMemberS{Value: sourceUri},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read deleted document", err)
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	var document DeletedDocument
+	if err := attributevalue.UnmarshalMap(output.Item, &document); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse deleted document", err)
+	}
+	return &document, nil
+}
+
+func (s *DynamoDBDeletedDocumentStore) MarkDeleted(ctx context.Context, document *DeletedDocument) (bool, error) {
+	item, err := attributevalue.MarshalMap(document)
+	if err != nil {
+		return false, errors.NewAWSServiceError("failed to marshal deleted document", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(sourceUri)"),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if stdErrors.As(err, &conditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.NewAWSServiceError("failed to mark document deleted", err)
+	}
+	return true, nil
+}
+
+func (s *DynamoDBDeletedDocumentStore) Restore(ctx context.Context, sourceUri string) (bool, error) {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"sourceUri": &types.AttributeValueMemberS{Value: sourceUri},
+		},
+		ConditionExpression: aws.String("attribute_exists(sourceUri) AND attribute_not_exists(purgedAt)"),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if stdErrors.As(err, &conditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.NewAWSServiceError("failed to restore document", err)
+	}
+	return true, nil
+}
+
+func (s *DynamoDBDeletedDocumentStore) MarkPurged(ctx context.Context, sourceUri string, purgedAt time.Time, expiresAt time.Time) error {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"sourceUri": &types.AttributeValueMemberS{Value: sourceUri},
+		},
+		UpdateExpression:    aws.String("SET purgedAt = :purgedAt, expiresAt = :expiresAt"),
+		ConditionExpression: aws.String("attribute_exists(sourceUri)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":purgedAt":  &types.AttributeValueMemberS{Value: purgedAt.UTC().Format(time.RFC3339)},
+			":expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to mark document purged", err)
+	}
+	return nil
+}
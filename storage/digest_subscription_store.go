@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	stdErrors "errors"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	DigestChannelEmail = "email" // Target is an email address, sent through SES
+	DigestChannelSNS   = "sns"   // Target is an SNS topic ARN
+)
+
+// DigestSubscription is a team receiving the document change digest
+type DigestSubscription struct {
+	Id         string     `dynamodbav:"id" json:"id"`
+	Team       string     `dynamodbav:"team" json:"team"`
+	Channel    string     `dynamodbav:"channel" json:"channel"`
+	Target     string     `dynamodbav:"target" json:"target"`
+	CreatedAt  time.Time  `dynamodbav:"createdAt" json:"createdAt"`
+	LastSentAt *time.Time `dynamodbav:"lastSentAt,omitempty" json:"lastSentAt,omitempty"`
+}
+
+type DigestSubscriptionStore interface {
+	ListSubscriptions(ctx context.Context) ([]DigestSubscription, error)
+	PutSubscription(ctx context.Context, subscription *DigestSubscription) error
+	// DeleteSubscription returns false without an error when the subscription does not exist
+	DeleteSubscription(ctx context.Context, id string) (bool, error)
+	// MarkSent records a delivered digest, deleted subscriptions are skipped
+	MarkSent(ctx context.Context, id string, sentAt time.Time) error
+}
+
+type DynamoDBDigestSubscriptionStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBDigestSubscriptionStore(cfg aws.Config, tableName string) *DynamoDBDigestSubscriptionStore {
+	return &DynamoDBDigestSubscriptionStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBDigestSubscriptionStore) ListSubscriptions(ctx context.Context) ([]DigestSubscription, error) {
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:      aws.String(s.tableName),
+		ConsistentRead: aws.Bool(true),
+	})
+
+	var subscriptions []DigestSubscription
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to scan digest subscriptions", err)
+		}
+
+		var pageSubscriptions []DigestSubscription
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageSubscriptions); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse digest subscriptions", err)
+		}
+		subscriptions = append(subscriptions, pageSubscriptions...)
+	}
+	return subscriptions, nil
+}
+
+func (s *DynamoDBDigestSubscriptionStore) PutSubscription(ctx context.Context, subscription *DigestSubscription) error {
+	item, err := attributevalue.MarshalMap(subscription)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal digest subscription", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write digest subscription", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBDigestSubscriptionStore) DeleteSubscription(ctx context.Context, id string) (bool, error) {
+	output, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	if err != nil {
+		return false, errors.NewAWSServiceError("failed to delete digest subscription", err)
+	}
+	return len(output.Attributes) > 0, nil
+}
+
+func (s *DynamoDBDigestSubscriptionStore) MarkSent(ctx context.Context, id string, sentAt time.Time) error {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:    aws.String("SET lastSentAt = :sentAt"),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sentAt": &types.AttributeValueMemberS{Value: sentAt.UTC().Format(time.RFC3339)},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if stdErrors.As(err, &conditionFailed) {
+		return nil
+	}
+	if err != nil {
+		return errors.NewAWSServiceError("failed to record digest delivery", err)
+	}
+	return nil
+}
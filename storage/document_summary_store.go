@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DocumentSummaryRecord is a precomputed summary for a single document, keyed by its public link.
+// ChangeDetectedAt is set when the document was first found as a newer version of its topic.
+type DocumentSummaryRecord struct {
+	Link             string     `dynamodbav:"link" json:"link"`
+	Topic            string     `dynamodbav:"topic" json:"topic"`
+	Version          int        `dynamodbav:"version" json:"version"`
+	Summary          string     `dynamodbav:"summary" json:"summary"`
+	ChangeSummary    string     `dynamodbav:"changeSummary" json:"changeSummary"`
+	PreviousLink     string     `dynamodbav:"previousLink,omitempty" json:"previousLink,omitempty"`
+	ChangeDetectedAt *time.Time `dynamodbav:"changeDetectedAt,omitempty" json:"changeDetectedAt,omitempty"`
+	UpdatedAt        time.Time  `dynamodbav:"updatedAt" json:"updatedAt"`
+}
+
+type DocumentSummaryStore interface {
+	// GetSummary returns nil without an error when no summary has been stored for the link
+	GetSummary(ctx context.Context, link string) (*DocumentSummaryRecord, error)
+	PutSummary(ctx context.Context, record *DocumentSummaryRecord) error
+	// ListChanges returns the documents detected as a new version since the given time
+	ListChanges(ctx context.Context, since time.Time) ([]DocumentSummaryRecord, error)
+}
+
+type DynamoDBDocumentSummaryStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBDocumentSummaryStore(cfg aws.Config, tableName string) *DynamoDBDocumentSummaryStore {
+	return &DynamoDBDocumentSummaryStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBDocumentSummaryStore) GetSummary(ctx context.Context, link string) (*DocumentSummaryRecord, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"link": &types.AttributeValueMemberS{Value: link},
+		},
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read document summary", err)
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	var record DocumentSummaryRecord
+	if err := attributevalue.UnmarshalMap(output.Item, &record); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse document summary", err)
+	}
+	return &record, nil
+}
+
+func (s *DynamoDBDocumentSummaryStore) PutSummary(ctx context.Context, record *DocumentSummaryRecord) error {
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal document summary", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write document summary", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBDocumentSummaryStore) ListChanges(ctx context.Context, since time.Time) ([]DocumentSummaryRecord, error) {
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:        aws.String(s.tableName),
+		FilterExpression: aws.String("changeDetectedAt >= :since"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":since": &types.AttributeValueMemberS{Value: since.UTC().Format(time.RFC3339)},
+		},
+	})
+
+	var records []DocumentSummaryRecord
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to scan document changes", err)
+		}
+
+		var pageRecords []DocumentSummaryRecord
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageRecords); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse document changes", err)
+		}
+		records = append(records, pageRecords...)
+	}
+	return records, nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"teletubpax-api/cache"
+)
+
+// embeddingKeyPrefix keeps cached embeddings apart from the other values of a shared cache.Store
+const embeddingKeyPrefix = "embedding:"
+
+// EmbeddingCache keeps embedding vectors by text key for a limited time
+type EmbeddingCache interface {
+	// Get returns the cached embedding, nil when there is none or it expired
+	Get(ctx context.Context, key string) ([]float64, error)
+	Set(ctx context.Context, key string, embedding []float64, ttl time.Duration) error
+}
+
+// StoreEmbeddingCache keeps embeddings in a cache.Store, under "embedding:" keys. Vectors
+// are stored as little-endian float64s rather than JSON, which would be about three times
+// the size.
+type StoreEmbeddingCache struct {
+	store cache.Store
+}
+
+func NewStoreEmbeddingCache(store cache.Store) *StoreEmbeddingCache {
+	return &StoreEmbeddingCache{
+		store: store,
+	}
+}
+
+func (c *StoreEmbeddingCache) Get(ctx context.Context, key string) ([]float64, error) {
+	data, err := c.store.Get(ctx, embeddingKeyPrefix+key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached embedding: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	if len(data)%8 != 0 {
+		return nil, fmt.Errorf("failed to parse cached embedding: %d bytes", len(data))
+	}
+
+	embedding := make([]float64, len(data)/8)
+	for i := range embedding {
+		embedding[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[i*8:]))
+	}
+	return embedding, nil
+}
+
+func (c *StoreEmbeddingCache) Set(ctx context.Context, key string, embedding []float64, ttl time.Duration) error {
+	data := make([]byte, 0, len(embedding)*8)
+	for _, value := range embedding {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(value))
+	}
+	if err := c.store.Set(ctx, embeddingKeyPrefix+key, data, ttl); err != nil {
+		return fmt.Errorf("failed to cache embedding: %w", err)
+	}
+	return nil
+}
+
+// NewMemoryEmbeddingCache keeps embeddings in the instance's memory, evicting the least
+// recently used embedding beyond maxEntries
+func NewMemoryEmbeddingCache(maxEntries int) *StoreEmbeddingCache {
+	return NewStoreEmbeddingCache(cache.NewMemoryStore(maxEntries))
+}
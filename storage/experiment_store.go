@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ExperimentRecord counts the answers and feedback of one experiment variant in a day
+type ExperimentRecord struct {
+	Id           string `dynamodbav:"id" json:"-"`      // Date, experiment and variant
+	Date         string `dynamodbav:"date" json:"date"` // YYYY-MM-DD, UTC
+	Experiment   string `dynamodbav:"experiment" json:"experiment"`
+	Variant      string `dynamodbav:"variant" json:"variant"`
+	Answers      int64  `dynamodbav:"answers" json:"answers"`
+	NoAnswers    int64  `dynamodbav:"noAnswers" json:"noAnswers"` // Answers that found nothing, counted in Answers
+	Errors       int64  `dynamodbav:"errors" json:"errors"`
+	DurationMs   int64  `dynamodbav:"durationMs" json:"durationMs"` // Total time of the answers
+	FeedbackUp   int64  `dynamodbav:"feedbackUp" json:"feedbackUp"`
+	FeedbackDown int64  `dynamodbav:"feedbackDown" json:"feedbackDown"`
+	ExpiresAt    int64  `dynamodbav:"expiresAt" json:"-"` // DynamoDB TTL, epoch seconds
+}
+
+// ExperimentId returns the ID of the aggregate of a day, experiment and variant
+func ExperimentId(date string, experiment string, variant string) string {
+	return date + "#" + experiment + "#" + variant
+}
+
+type ExperimentStore interface {
+	// AddExperimentCounts adds the counts of record to the aggregate with its ID, created
+	// with the record's expiry when there is none
+	AddExperimentCounts(ctx context.Context, record *ExperimentRecord) error
+	// ListExperimentCounts returns the aggregates of the days from from to to, inclusive
+	ListExperimentCounts(ctx context.Context, from string, to string) ([]ExperimentRecord, error)
+}
+
+// DynamoDBExperimentStore shares experiment counts between instances, with atomic counters
+// per aggregate
+type DynamoDBExperimentStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBExperimentStore(cfg aws.Config, tableName string) *DynamoDBExperimentStore {
+	return &DynamoDBExperimentStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBExperimentStore) AddExperimentCounts(ctx context.Context, record *ExperimentRecord) error {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: record.Id},
+		},
+		UpdateExpression: aws.String("ADD answers :answers, noAnswers :noAnswers, errors :errors, durationMs :durationMs, feedbackUp :feedbackUp, feedbackDown :feedbackDown " +
+			"SET #date = :date, experiment = :experiment, variant = :variant, expiresAt = if_not_exists(expiresAt, :expiresAt)"),
+		ExpressionAttributeNames: map[string]string{"#date": "date"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":answers":      &types.AttributeValueMemberN{Value: strconv.FormatInt(record.Answers, 10)},
+			":noAnswers":    &types.AttributeValueMemberN{Value: strconv.FormatInt(record.NoAnswers, 10)},
+			":errors":       &types.AttributeValueMemberN{Value: strconv.FormatInt(record.Errors, 10)},
+			":durationMs":   &types.AttributeValueMemberN{Value: strconv.FormatInt(record.DurationMs, 10)},
+			":feedbackUp":   &types.AttributeValueMemberN{Value: strconv.FormatInt(record.FeedbackUp, 10)},
+			":feedbackDown": &types.AttributeValueMemberN{Value: strconv.FormatInt(record.FeedbackDown, 10)},
+			":date":         &types.AttributeValueMemberS{Value: record.Date},
+			":experiment":   &types.AttributeValueMemberS{Value: record.Experiment},
+			":variant":      &types.AttributeValueMemberS{Value: record.Variant},
+			":expiresAt":    &types.AttributeValueMemberN{Value: strconv.FormatInt(record.ExpiresAt, 10)},
+		},
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to add experiment counts", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBExperimentStore) ListExperimentCounts(ctx context.Context, from string, to string) ([]ExperimentRecord, error) {
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:                aws.String(s.tableName),
+		FilterExpression:         aws.String("#date BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{"#date": "date"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":from": &types.AttributeValueMemberS{Value: from},
+			":to":   &types.AttributeValueMemberS{Value: to},
+		},
+	})
+
+	var records []ExperimentRecord
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to scan experiment counts", err)
+		}
+
+		var pageRecords []ExperimentRecord
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageRecords); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse experiment counts", err)
+		}
+		records = append(records, pageRecords...)
+	}
+	return records, nil
+}
+
+// MemoryExperimentStore keeps experiment counts in the instance's memory, so each instance
+// reports its own answers since it started
+type MemoryExperimentStore struct {
+	mu      sync.Mutex
+	records map[string]*ExperimentRecord
+}
+
+func NewMemoryExperimentStore() *MemoryExperimentStore {
+	return &MemoryExperimentStore{
+		records: map[string]*ExperimentRecord{},
+	}
+}
+
+func (s *MemoryExperimentStore) AddExperimentCounts(ctx context.Context, record *ExperimentRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.records[record.Id]
+	if !ok {
+		added := *record
+		s.records[record.Id] = &added
+		return nil
+	}
+	existing.Answers += record.Answers
+	existing.NoAnswers += record.NoAnswers
+	existing.Errors += record.Errors
+	existing.DurationMs += record.DurationMs
+	existing.FeedbackUp += record.FeedbackUp
+	existing.FeedbackDown += record.FeedbackDown
+	return nil
+}
+
+func (s *MemoryExperimentStore) ListExperimentCounts(ctx context.Context, from string, to string) ([]ExperimentRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []ExperimentRecord
+	for _, record := range s.records {
+		if record.Date >= from && record.Date <= to {
+			records = append(records, *record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Id < records[j].Id })
+	return records, nil
+}
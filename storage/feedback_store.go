@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	stdErrors "errors"
+	"strconv"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// AnswerFeedback is an answer given by question-search, with the rating and comment of the
+// caller once feedback on it was sent
+type AnswerFeedback struct {
+	Id               string     `dynamodbav:"id" json:"answerId"`
+	TenantId         string     `dynamodbav:"tenantId,omitempty" json:"tenantId,omitempty"`
+	Question         string     `dynamodbav:"question" json:"question"`
+	Answer           string     `dynamodbav:"answer" json:"answer"`
+	RelatedDocuments []string   `dynamodbav:"relatedDocuments" json:"relatedDocuments"`
+	Experiment       string     `dynamodbav:"experiment,omitempty" json:"experiment,omitempty"` // Experiment and variant the answer was given by, if any
+	Variant          string     `dynamodbav:"variant,omitempty" json:"variant,omitempty"`
+	AnsweredAt       time.Time  `dynamodbav:"answeredAt" json:"answeredAt"`
+	Rating           string     `dynamodbav:"rating,omitempty" json:"rating,omitempty"` // "up" or "down", empty until feedback is sent
+	Comment          string     `dynamodbav:"comment,omitempty" json:"comment,omitempty"`
+	FeedbackAt       *time.Time `dynamodbav:"feedbackAt,omitempty" json:"feedbackAt,omitempty"`
+	ExpiresAt        int64      `dynamodbav:"expiresAt" json:"-"` // DynamoDB TTL, epoch seconds
+}
+
+type FeedbackStore interface {
+	PutAnswer(ctx context.Context, answer *AnswerFeedback) error
+	// SetFeedback saves the rating, comment, feedback time and expiry of feedback on the
+	// answer with its ID and returns the answer with its feedback. It returns nil without an
+	// error when no such answer was given to the feedback's question.
+	SetFeedback(ctx context.Context, feedback *AnswerFeedback) (*AnswerFeedback, error)
+}
+
+type DynamoDBFeedbackStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBFeedbackStore(cfg aws.Config, tableName string) *DynamoDBFeedbackStore {
+	return &DynamoDBFeedbackStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBFeedbackStore) PutAnswer(ctx context.Context, answer *AnswerFeedback) error {
+	item, err := attributevalue.MarshalMap(answer)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal answer", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write answer", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBFeedbackStore) SetFeedback(ctx context.Context, feedback *AnswerFeedback) (*AnswerFeedback, error) {
+	updateExpression := "SET rating = :rating, feedbackAt = :feedbackAt, expiresAt = :expiresAt REMOVE #comment"
+	values := map[string]types.AttributeValue{
+		":question":   &types.AttributeValueMemberS{Value: feedback.Question},
+		":rating":     &types.AttributeValueMemberS{Value: feedback.Rating},
+		":feedbackAt": &types.AttributeValueMemberS{Value: feedback.FeedbackAt.UTC().Format(time.RFC3339)},
+		":expiresAt":  &types.AttributeValueMemberN{Value: strconv.FormatInt(feedback.ExpiresAt, 10)},
+	}
+	if feedback.Comment != "" {
+		updateExpression = "SET rating = :rating, feedbackAt = :feedbackAt, expiresAt = :expiresAt, #comment = :comment"
+		values[":comment"] = &types.AttributeValueMemberS{Value: feedback.Comment}
+	}
+
+	output, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: feedback.Id},
+		},
+		UpdateExpression:    aws.String(updateExpression),
+		ConditionExpression: aws.String("attribute_exists(id) AND question = :question"),
+		// COMMENT is a DynamoDB reserved word
+		ExpressionAttributeNames:  map[string]string{"#comment": "comment"},
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if stdErrors.As(err, &conditionFailed) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to write feedback", err)
+	}
+
+	var answer AnswerFeedback
+	if err := attributevalue.UnmarshalMap(output.Attributes, &answer); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse answer", err)
+	}
+	return &answer, nil
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"teletubpax-api/cache"
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// IdempotencyRecord is the response to a request with an Idempotency-Key, replayed to
+// retries of the request
+type IdempotencyRecord struct {
+	Key         string `dynamodbav:"key" json:"key"`
+	Fingerprint string `dynamodbav:"fingerprint" json:"fingerprint"`                     // Hex SHA-256 of the request body
+	StatusCode  int    `dynamodbav:"statusCode,omitempty" json:"statusCode,omitempty"`   // 0 while the first request is in progress
+	ContentType string `dynamodbav:"contentType,omitempty" json:"contentType,omitempty"` // Content-Type of the response
+	Body        []byte `dynamodbav:"body,omitempty" json:"body,omitempty"`
+	ExpiresAt   int64  `dynamodbav:"expiresAt" json:"expiresAt"` // Unix seconds
+}
+
+// InProgress reports whether the first request with the key has not answered yet
+func (r *IdempotencyRecord) InProgress() bool {
+	return r.StatusCode == 0
+}
+
+type IdempotencyStore interface {
+	// Reserve stores the record, without a response, when the key is new or expired and
+	// returns nil. Otherwise it returns the existing record and stores nothing.
+	Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// Complete stores the response of a reserved key
+	Complete(ctx context.Context, record *IdempotencyRecord) error
+	// Release removes a reservation, so the next request with the key runs again
+	Release(ctx context.Context, key string) error
+}
+
+// DynamoDBIdempotencyStore shares records between instances. Expired items are removed by
+// the table's TTL on expiresAt, which can lag, so expiry is also checked on reserve.
+type DynamoDBIdempotencyStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBIdempotencyStore(cfg aws.Config, tableName string) *DynamoDBIdempotencyStore {
+	return &DynamoDBIdempotencyStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBIdempotencyStore) Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to marshal idempotency record", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR expiresAt <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#key": "key",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if stdErrors.As(err, &conditionFailed) {
+		var existing IdempotencyRecord
+		if err := attributevalue.UnmarshalMap(conditionFailed.Item, &existing); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse idempotency record", err)
+		}
+		return &existing, nil
+	}
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to reserve idempotency key", err)
+	}
+	return nil, nil
+}
+
+func (s *DynamoDBIdempotencyStore) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal idempotency record", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to store idempotent response", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBIdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"key": &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to release idempotency key", err)
+	}
+	return nil
+}
+
+// idempotencyKeyPrefix keeps idempotency records apart from the other values of a shared cache.Store
+const idempotencyKeyPrefix = "idempotency:"
+
+// CacheIdempotencyStore keeps records as JSON in a cache.Store, which expires them on their
+// ExpiresAt, for deployments sharing a Redis instead of a table
+type CacheIdempotencyStore struct {
+	store cache.Store
+}
+
+func NewCacheIdempotencyStore(store cache.Store) *CacheIdempotencyStore {
+	return &CacheIdempotencyStore{
+		store: store,
+	}
+}
+
+func (s *CacheIdempotencyStore) Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to marshal idempotency record", err)
+	}
+
+	// The existing record can expire between the two calls, so a miss reserves again
+	for attempt := 0; attempt < 2; attempt++ {
+		stored, err := s.store.SetIfAbsent(ctx, idempotencyKeyPrefix+record.Key, data, time.Until(time.Unix(record.ExpiresAt, 0)))
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to reserve idempotency key", err)
+		}
+		if stored {
+			return nil, nil
+		}
+
+		existing, err := s.store.Get(ctx, idempotencyKeyPrefix+record.Key)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to read idempotency record", err)
+		}
+		if existing != nil {
+			var existingRecord IdempotencyRecord
+			if err := json.Unmarshal(existing, &existingRecord); err != nil {
+				return nil, errors.NewAWSServiceError("failed to parse idempotency record", err)
+			}
+			return &existingRecord, nil
+		}
+	}
+	return nil, errors.NewAWSServiceError("failed to reserve idempotency key", stdErrors.New("the key changed during the reservation"))
+}
+
+func (s *CacheIdempotencyStore) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal idempotency record", err)
+	}
+	if err := s.store.Set(ctx, idempotencyKeyPrefix+record.Key, data, time.Until(time.Unix(record.ExpiresAt, 0))); err != nil {
+		return errors.NewAWSServiceError("failed to store idempotent response", err)
+	}
+	return nil
+}
+
+func (s *CacheIdempotencyStore) Release(ctx context.Context, key string) error {
+	if err := s.store.Delete(ctx, idempotencyKeyPrefix+key); err != nil {
+		return errors.NewAWSServiceError("failed to release idempotency key", err)
+	}
+	return nil
+}
+
+// MemoryIdempotencyStore keeps records in the instance's memory, so retries reaching another
+// instance run again
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]*IdempotencyRecord
+	lastSweep time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records: map[string]*IdempotencyRecord{},
+	}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().Unix()
+	if time.Since(s.lastSweep) > time.Minute {
+		for key, existing := range s.records {
+			if existing.ExpiresAt <= now {
+				delete(s.records, key)
+			}
+		}
+		s.lastSweep = time.Now()
+	}
+
+	if existing, ok := s.records[record.Key]; ok && existing.ExpiresAt > now {
+		copied := *existing
+		return &copied, nil
+	}
+	copied := *record
+	s.records[record.Key] = &copied
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *record
+	s.records[record.Key] = &copied
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teletubpax-api/cache"
+)
+
+func TestCacheIdempotencyStore_ReserveCompleteRelease(t *testing.T) {
+	store := NewCacheIdempotencyStore(cache.NewMemoryStore(0))
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Minute).Unix()
+
+	existing, err := store.Reserve(ctx, &IdempotencyRecord{Key: "k", Fingerprint: "f", ExpiresAt: expiresAt})
+	if err != nil || existing != nil {
+		t.Fatalf("expected the new key to be reserved, got %+v %v", existing, err)
+	}
+	existing, _ = store.Reserve(ctx, &IdempotencyRecord{Key: "k", Fingerprint: "g", ExpiresAt: expiresAt})
+	if existing == nil || existing.Fingerprint != "f" || !existing.InProgress() {
+		t.Fatalf("expected the reservation in progress, got %+v", existing)
+	}
+
+	store.Complete(ctx, &IdempotencyRecord{Key: "k", Fingerprint: "f", StatusCode: 200, Body: []byte(`{"ok":true}`), ExpiresAt: expiresAt})
+	existing, _ = store.Reserve(ctx, &IdempotencyRecord{Key: "k", Fingerprint: "f", ExpiresAt: expiresAt})
+	if existing == nil || existing.StatusCode != 200 || string(existing.Body) != `{"ok":true}` {
+		t.Fatalf("expected the completed response, got %+v", existing)
+	}
+
+	store.Release(ctx, "k")
+	if existing, _ := store.Reserve(ctx, &IdempotencyRecord{Key: "k", Fingerprint: "f", ExpiresAt: expiresAt}); existing != nil {
+		t.Errorf("expected the released key to be reserved again, got %+v", existing)
+	}
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+)
+
+// JobCheckpoint records how far a batch job has progressed so it can resume after
+// an interruption (Lambda timeout, redeploy, manual cancel)
+type JobCheckpoint struct {
+	JobName     string    `dynamodbav:"jobName" json:"jobName"`
+	Status      string    `dynamodbav:"status" json:"status"`
+	Cursor      string    `dynamodbav:"cursor" json:"cursor"` // Last processed item key, items are processed in key order
+	Total       int       `dynamodbav:"total" json:"total"`
+	Succeeded   int       `dynamodbav:"succeeded" json:"succeeded"`
+	Failed      int       `dynamodbav:"failed" json:"failed"`
+	FailedItems []string  `dynamodbav:"failedItems" json:"failedItems"`
+	StartedAt   time.Time `dynamodbav:"startedAt" json:"startedAt"`
+	UpdatedAt   time.Time `dynamodbav:"updatedAt" json:"updatedAt"`
+}
+
+type JobCheckpointStore interface {
+	// GetCheckpoint returns nil without an error when the job has never run
+	GetCheckpoint(ctx context.Context, jobName string) (*JobCheckpoint, error)
+	SaveCheckpoint(ctx context.Context, checkpoint *JobCheckpoint) error
+}
+
+type DynamoDBJobCheckpointStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBJobCheckpointStore(cfg aws.Config, tableName string) *DynamoDBJobCheckpointStore {
+	return &DynamoDBJobCheckpointStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBJobCheckpointStore) GetCheckpoint(ctx context.Context, jobName string) (*JobCheckpoint, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"jobName": &types.AttributeValueMemberS{Value: jobName},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read job checkpoint", err)
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	var checkpoint JobCheckpoint
+	if err := attributevalue.UnmarshalMap(output.Item, &checkpoint); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse job checkpoint", err)
+	}
+	return &checkpoint, nil
+}
+
+func (s *DynamoDBJobCheckpointStore) SaveCheckpoint(ctx context.Context, checkpoint *JobCheckpoint) error {
+	item, err := attributevalue.MarshalMap(checkpoint)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal job checkpoint", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write job checkpoint", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobPartial   = "partial" // Finished, but part of its work failed
+)
+
+// Types of the long-running operations tracked as jobs
+const (
+	JobTypeQuestion  = "question"  // A question-search request answered by the queue worker
+	JobTypeSummary   = "summary"   // A bulk summary run by the Step Functions workflow
+	JobTypeIngestion = "ingestion" // A data source sync run by Bedrock
+)
+
+// Job is a long-running operation, polled by its ID at /jobs/{id} until it is finished
+type Job struct {
+	JobId          string    `dynamodbav:"jobId"`
+	Type           string    `dynamodbav:"type"`
+	Owner          string    `dynamodbav:"owner"` // Hash of the caller who started it, the only one who can read it
+	Status         string    `dynamodbav:"status"`
+	StatusCode     int       `dynamodbav:"statusCode,omitempty"`     // Of the question-search response, for question jobs
+	Result         []byte    `dynamodbav:"result,omitempty"`         // JSON, once finished or as progress is known
+	Error          string    `dynamodbav:"error,omitempty"`          // Shown to the caller, without internal causes
+	CallbackUrl    string    `dynamodbav:"callbackUrl,omitempty"`    // Posted the job once finished
+	CallbackSecret string    `dynamodbav:"callbackSecret,omitempty"` // Signs the callback, returned once on submission
+	CreatedAt      time.Time `dynamodbav:"createdAt"`
+	UpdatedAt      time.Time `dynamodbav:"updatedAt"`
+	ExpiresAt      int64     `dynamodbav:"expiresAt"` // Unix seconds
+}
+
+// Finished reports whether the job has its outcome
+func (j *Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobPartial
+}
+
+type JobStore interface {
+	// GetJob returns nil without an error for unknown and expired jobs
+	GetJob(ctx context.Context, jobId string) (*Job, error)
+	SaveJob(ctx context.Context, job *Job) error
+}
+
+// DynamoDBJobStore shares jobs between the API, the queue worker and the workflow tasks.
+// Expired items are removed by the table's TTL on expiresAt, which can lag, so expiry is
+// also checked on read.
+type DynamoDBJobStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBJobStore(cfg aws.Config, tableName string) *DynamoDBJobStore {
+	return &DynamoDBJobStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBJobStore) GetJob(ctx context.Context, jobId string) (*Job, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"jobId": &types.AttributeValueMemberS{Value: jobId},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read job", err)
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	var job Job
+	if err := attributevalue.UnmarshalMap(output.Item, &job); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse job", err)
+	}
+	if job.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (s *DynamoDBJobStore) SaveJob(ctx context.Context, job *Job) error {
+	item, err := attributevalue.MarshalMap(job)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal job", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write job", err)
+	}
+	return nil
+}
+
+// MemoryJobStore keeps jobs in the instance's memory, for tests
+type MemoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{
+		jobs: map[string]*Job{},
+	}
+}
+
+func (s *MemoryJobStore) GetJob(ctx context.Context, jobId string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[jobId]
+	if !ok || job.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (s *MemoryJobStore) SaveJob(ctx context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *job
+	s.jobs[job.JobId] = &copied
+	return nil
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	stdErrors "errors"
+	"strconv"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// NormalizationTerm maps a jargon term or misspelling to the canonical wording used in the
+// documents. MatchCount is how often the term has been rewritten in questions.
+type NormalizationTerm struct {
+	Term          string     `dynamodbav:"term" json:"term"`
+	Canonical     string     `dynamodbav:"canonical" json:"canonical"`
+	MatchCount    int64      `dynamodbav:"matchCount" json:"matchCount"`
+	LastMatchedAt *time.Time `dynamodbav:"lastMatchedAt,omitempty" json:"lastMatchedAt,omitempty"`
+	UpdatedAt     time.Time  `dynamodbav:"updatedAt" json:"updatedAt"`
+}
+
+type NormalizationStore interface {
+	ListTerms(ctx context.Context) ([]NormalizationTerm, error)
+	// PutTerm creates or replaces the mapping, keeping the match statistics of an existing term
+	PutTerm(ctx context.Context, term string, canonical string) (*NormalizationTerm, error)
+	// DeleteTerm returns false without an error when the term does not exist
+	DeleteTerm(ctx context.Context, term string) (bool, error)
+	// AddMatchCounts adds to the match counts of existing terms, deleted terms are skipped
+	AddMatchCounts(ctx context.Context, counts map[string]int64, matchedAt time.Time) error
+}
+
+type DynamoDBNormalizationStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBNormalizationStore(cfg aws.Config, tableName string) *DynamoDBNormalizationStore {
+	return &DynamoDBNormalizationStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBNormalizationStore) ListTerms(ctx context.Context) ([]NormalizationTerm, error) {
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:      aws.String(s.tableName),
+		ConsistentRead: aws.Bool(true),
+	})
+
+	var terms []NormalizationTerm
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to scan normalization terms", err)
+		}
+
+		var pageTerms []NormalizationTerm
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageTerms); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse normalization terms", err)
+		}
+		terms = append(terms, pageTerms...)
+	}
+	return terms, nil
+}
+
+func (s *DynamoDBNormalizationStore) PutTerm(ctx context.Context, term string, canonical string) (*NormalizationTerm, error) {
+	output, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"term": &types.AttributeValueMemberS{Value: term},
+		},
+		UpdateExpression: aws.String("SET canonical = :canonical, updatedAt = :updatedAt, matchCount = if_not_exists(matchCount, :zero)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":canonical": &types.AttributeValueMemberS{Value: canonical},
+			":updatedAt": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+			":zero":      &types.AttributeValueMemberN{Value: "0"},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to write normalization term", err)
+	}
+
+	var record NormalizationTerm
+	if err := attributevalue.UnmarshalMap(output.Attributes, &record); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse normalization term", err)
+	}
+	return &record, nil
+}
+
+func (s *DynamoDBNormalizationStore) DeleteTerm(ctx context.Context, term string) (bool, error) {
+	output, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"term": &types.AttributeValueMemberS{Value: term},
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	if err != nil {
+		return false, errors.NewAWSServiceError("failed to delete normalization term", err)
+	}
+	return len(output.Attributes) > 0, nil
+}
+
+func (s *DynamoDBNormalizationStore) AddMatchCounts(ctx context.Context, counts map[string]int64, matchedAt time.Time) error {
+	for term, count := range counts {
+		_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(s.tableName),
+			Key: map[string]types.AttributeValue{
+				"term": &types.AttributeValueMemberS{Value: term},
+			},
+			UpdateExpression:    aws.String("ADD matchCount :count SET lastMatchedAt = :matchedAt"),
+			ConditionExpression: aws.String("attribute_exists(term)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":count":     &types.AttributeValueMemberN{Value: strconv.FormatInt(count, 10)},
+				":matchedAt": &types.AttributeValueMemberS{Value: matchedAt.UTC().Format(time.RFC3339)},
+			},
+		})
+		var conditionFailed *types.ConditionalCheckFailedException
+		if stdErrors.As(err, &conditionFailed) {
+			continue
+		}
+		if err != nil {
+			return errors.NewAWSServiceError("failed to update normalization match count", err)
+		}
+	}
+	return nil
+}
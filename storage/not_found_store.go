@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// NotFoundScore is the best retrieval score a knowledge base returned for an unanswered question
+type NotFoundScore struct {
+	KnowledgeBaseId string  `dynamodbav:"knowledgeBaseId" json:"knowledgeBaseId"`
+	Score           float64 `dynamodbav:"score" json:"score"`
+	SourceUrl       string  `dynamodbav:"sourceUrl" json:"sourceUrl,omitempty"`
+}
+
+// NotFoundEvent records a question search that produced no answer
+type NotFoundEvent struct {
+	Id        string          `dynamodbav:"id" json:"id"`
+	Question  string          `dynamodbav:"question" json:"question"`
+	TopScore  float64         `dynamodbav:"topScore" json:"topScore"`
+	Scores    []NotFoundScore `dynamodbav:"scores" json:"scores"`
+	AskedAt   time.Time       `dynamodbav:"askedAt" json:"askedAt"` // UTC, truncated to seconds so it sorts as a string
+	ExpiresAt int64           `dynamodbav:"expiresAt" json:"-"`     // DynamoDB TTL, epoch seconds
+}
+
+type NotFoundStore interface {
+	RecordNotFound(ctx context.Context, event *NotFoundEvent) error
+	// ListNotFound returns the events recorded at or after since, in no particular order
+	ListNotFound(ctx context.Context, since time.Time) ([]NotFoundEvent, error)
+}
+
+type DynamoDBNotFoundStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBNotFoundStore(cfg aws.Config, tableName string) *DynamoDBNotFoundStore {
+	return &DynamoDBNotFoundStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBNotFoundStore) RecordNotFound(ctx context.Context, event *NotFoundEvent) error {
+	item, err := attributevalue.MarshalMap(event)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal not-found event", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write not-found event", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBNotFoundStore) ListNotFound(ctx context.Context, since time.Time) ([]NotFoundEvent, error) {
+	sinceValue, err := attributevalue.Marshal(since.UTC().Truncate(time.Second))
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to marshal report window", err)
+	}
+
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:                 aws.String(s.tableName),
+		FilterExpression:          aws.String("askedAt >= :since"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":since": sinceValue},
+	})
+
+	var events []NotFoundEvent
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to scan not-found events", err)
+		}
+
+		var pageEvents []NotFoundEvent
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageEvents); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse not-found events", err)
+		}
+		events = append(events, pageEvents...)
+	}
+	return events, nil
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	stdErrors "errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// PromptVersion is one saved text of a prompt. Versions start at 1 and every save adds one.
+type PromptVersion struct {
+	Name      string    `json:"name"` // Env var of the prompt, e.g. QUESTION_SEARCH_INSTRUCTIONS
+	Version   int64     `json:"version"`
+	Text      string    `json:"text"`
+	Note      string    `json:"note,omitempty"` // Why the prompt was changed
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type PromptStore interface {
+	// ListPromptVersions returns the saved versions of a prompt, newest first, none when it
+	// was never saved
+	ListPromptVersions(ctx context.Context, name string) ([]PromptVersion, error)
+	// PutPrompt saves text as the next version of a prompt
+	PutPrompt(ctx context.Context, name string, text string, note string) (*PromptVersion, error)
+}
+
+// SSMPromptStore saves prompts as the CONFIG_SSM_PREFIX parameters named after their env
+// vars, so the configuration reload picks them up. Parameter Store keeps the last 100
+// versions of each parameter; prompts over 4 KB are saved in the advanced tier.
+type SSMPromptStore struct {
+	client *ssm.Client
+	prefix string
+}
+
+func NewSSMPromptStore(cfg aws.Config, prefix string) *SSMPromptStore {
+	return &SSMPromptStore{
+		client: ssm.NewFromConfig(cfg),
+		prefix: "/" + strings.Trim(prefix, "/") + "/",
+	}
+}
+
+func (s *SSMPromptStore) ListPromptVersions(ctx context.Context, name string) ([]PromptVersion, error) {
+	paginator := ssm.NewGetParameterHistoryPaginator(s.client, &ssm.GetParameterHistoryInput{
+		Name:           aws.String(s.prefix + name),
+		WithDecryption: aws.Bool(true),
+	})
+
+	versions := []PromptVersion{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		var notFound *types.ParameterNotFound
+		if stdErrors.As(err, &notFound) {
+			return versions, nil
+		}
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to read prompt versions", err)
+		}
+		for _, parameter := range page.Parameters {
+			versions = append(versions, PromptVersion{
+				Name:      name,
+				Version:   parameter.Version,
+				Text:      aws.ToString(parameter.Value),
+				Note:      aws.ToString(parameter.Description),
+				UpdatedAt: aws.ToTime(parameter.LastModifiedDate),
+			})
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	return versions, nil
+}
+
+func (s *SSMPromptStore) PutPrompt(ctx context.Context, name string, text string, note string) (*PromptVersion, error) {
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(s.prefix + name),
+		Value:     aws.String(text),
+		Type:      types.ParameterTypeString,
+		Tier:      types.ParameterTierIntelligentTiering,
+		Overwrite: aws.Bool(true),
+	}
+	if note != "" {
+		input.Description = aws.String(note)
+	}
+	output, err := s.client.PutParameter(ctx, input)
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to save prompt", err)
+	}
+	return &PromptVersion{Name: name, Version: output.Version, Text: text, Note: note, UpdatedAt: time.Now().UTC()}, nil
+}
+
+// MemoryPromptStore keeps prompt versions in the instance's memory
+type MemoryPromptStore struct {
+	mu       sync.Mutex
+	versions map[string][]PromptVersion
+}
+
+func NewMemoryPromptStore() *MemoryPromptStore {
+	return &MemoryPromptStore{
+		versions: map[string][]PromptVersion{},
+	}
+}
+
+func (s *MemoryPromptStore) ListPromptVersions(ctx context.Context, name string) ([]PromptVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := []PromptVersion{}
+	for i := len(s.versions[name]) - 1; i >= 0; i-- {
+		versions = append(versions, s.versions[name][i])
+	}
+	return versions, nil
+}
+
+func (s *MemoryPromptStore) PutPrompt(ctx context.Context, name string, text string, note string) (*PromptVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version := PromptVersion{
+		Name:      name,
+		Version:   int64(len(s.versions[name]) + 1),
+		Text:      text,
+		Note:      note,
+		UpdatedAt: time.Now().UTC(),
+	}
+	s.versions[name] = append(s.versions[name], version)
+	return &version, nil
+}
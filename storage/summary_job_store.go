@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	SummaryJobQueued    = "queued"
+	SummaryJobRunning   = "running"
+	SummaryJobCompleted = "completed"
+	SummaryJobFailed    = "failed"
+)
+
+// SummaryJob is a bulk document summarization run by the Step Functions workflow, polled
+// by its ID until its result is stored
+type SummaryJob struct {
+	JobId          string              `dynamodbav:"id"`
+	Owner          string              `dynamodbav:"owner"` // Hash of the caller who submitted it, the only one who can read it
+	Status         string              `dynamodbav:"status"`
+	Documents      []string            `dynamodbav:"documents"`        // Valid requested links, in request order
+	Rejected       []byte              `dynamodbav:"rejected"`         // JSON of the links rejected on submission
+	Access         map[string][]string `dynamodbav:"access,omitempty"` // Metadata values denied to the submitter
+	Chunks         int                 `dynamodbav:"chunks"`           // Set once the documents are chunked
+	Result         []byte              `dynamodbav:"result,omitempty"` // JSON of the summaries, once completed
+	Error          string              `dynamodbav:"error,omitempty"`
+	CallbackUrl    string              `dynamodbav:"callbackUrl,omitempty"`    // Posted the result once finished
+	CallbackSecret string              `dynamodbav:"callbackSecret,omitempty"` // Signs the callback, returned once on submission
+	CreatedAt      time.Time           `dynamodbav:"createdAt"`
+	UpdatedAt      time.Time           `dynamodbav:"updatedAt"`
+	ExpiresAt      int64               `dynamodbav:"expiresAt"` // Unix seconds
+}
+
+// Finished reports whether the job has its result or error
+func (j *SummaryJob) Finished() bool {
+	return j.Status == SummaryJobCompleted || j.Status == SummaryJobFailed
+}
+
+// SummaryJobChunk is the working state of one chunk of a job's documents, kept out of the
+// state machine's payload, which is limited to 256 KB
+type SummaryJobChunk struct {
+	Key       string `dynamodbav:"id"` // SummaryJobChunkKey(jobId, index)
+	JobId     string `dynamodbav:"jobId"`
+	Index     int    `dynamodbav:"index"`
+	Documents []byte `dynamodbav:"documents"` // JSON, rewritten by every task of the chunk
+	ExpiresAt int64  `dynamodbav:"expiresAt"`
+}
+
+// SummaryJobChunkKey keys chunks in the same table as their jobs
+func SummaryJobChunkKey(jobId string, index int) string {
+	return fmt.Sprintf("%s#chunk-%d", jobId, index)
+}
+
+type SummaryJobStore interface {
+	// GetJob returns nil without an error for unknown and expired jobs
+	GetJob(ctx context.Context, jobId string) (*SummaryJob, error)
+	SaveJob(ctx context.Context, job *SummaryJob) error
+	// GetChunk returns nil without an error for unknown and expired chunks
+	GetChunk(ctx context.Context, jobId string, index int) (*SummaryJobChunk, error)
+	SaveChunk(ctx context.Context, chunk *SummaryJobChunk) error
+}
+
+// DynamoDBSummaryJobStore shares jobs and their chunks between the API and the workflow
+// tasks. Expired items are removed by the table's TTL on expiresAt, which can lag, so
+// expiry is also checked on read.
+type DynamoDBSummaryJobStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBSummaryJobStore(cfg aws.Config, tableName string) *DynamoDBSummaryJobStore {
+	return &DynamoDBSummaryJobStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBSummaryJobStore) GetJob(ctx context.Context, jobId string) (*SummaryJob, error) {
+	var job SummaryJob
+	found, err := s.getItem(ctx, jobId, &job)
+	if err != nil || !found || job.ExpiresAt <= time.Now().Unix() {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *DynamoDBSummaryJobStore) SaveJob(ctx context.Context, job *SummaryJob) error {
+	return s.putItem(ctx, job)
+}
+
+func (s *DynamoDBSummaryJobStore) GetChunk(ctx context.Context, jobId string, index int) (*SummaryJobChunk, error) {
+	var chunk SummaryJobChunk
+	found, err := s.getItem(ctx, SummaryJobChunkKey(jobId, index), &chunk)
+	if err != nil || !found || chunk.ExpiresAt <= time.Now().Unix() {
+		return nil, err
+	}
+	return &chunk, nil
+}
+
+func (s *DynamoDBSummaryJobStore) SaveChunk(ctx context.Context, chunk *SummaryJobChunk) error {
+	saved := *chunk
+	saved.Key = SummaryJobChunkKey(chunk.JobId, chunk.Index)
+	return s.putItem(ctx, &saved)
+}
+
+func (s *DynamoDBSummaryJobStore) getItem(ctx context.Context, id string, out interface{}) (bool, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, errors.NewAWSServiceError("failed to read summary job", err)
+	}
+	if output.Item == nil {
+		return false, nil
+	}
+	if err := attributevalue.UnmarshalMap(output.Item, out); err != nil {
+		return false, errors.NewAWSServiceError("failed to parse summary job", err)
+	}
+	return true, nil
+}
+
+func (s *DynamoDBSummaryJobStore) putItem(ctx context.Context, in interface{}) error {
+	item, err := attributevalue.MarshalMap(in)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal summary job", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write summary job", err)
+	}
+	return nil
+}
+
+// MemorySummaryJobStore keeps jobs in the instance's memory, for tests
+type MemorySummaryJobStore struct {
+	mu     sync.Mutex
+	jobs   map[string]*SummaryJob
+	chunks map[string]*SummaryJobChunk
+}
+
+func NewMemorySummaryJobStore() *MemorySummaryJobStore {
+	return &MemorySummaryJobStore{
+		jobs:   map[string]*SummaryJob{},
+		chunks: map[string]*SummaryJobChunk{},
+	}
+}
+
+func (s *MemorySummaryJobStore) GetJob(ctx context.Context, jobId string) (*SummaryJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[jobId]
+	if !ok || job.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (s *MemorySummaryJobStore) SaveJob(ctx context.Context, job *SummaryJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *job
+	s.jobs[job.JobId] = &copied
+	return nil
+}
+
+func (s *MemorySummaryJobStore) GetChunk(ctx context.Context, jobId string, index int) (*SummaryJobChunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chunk, ok := s.chunks[SummaryJobChunkKey(jobId, index)]
+	if !ok || chunk.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+	copied := *chunk
+	return &copied, nil
+}
+
+func (s *MemorySummaryJobStore) SaveChunk(ctx context.Context, chunk *SummaryJobChunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *chunk
+	copied.Key = SummaryJobChunkKey(chunk.JobId, chunk.Index)
+	s.chunks[copied.Key] = &copied
+	return nil
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// UsageRecord is the token usage and cost of one tenant on one endpoint and model in a day
+type UsageRecord struct {
+	Id             string  `dynamodbav:"id" json:"-"`      // Date, tenant, endpoint and model
+	Date           string  `dynamodbav:"date" json:"date"` // YYYY-MM-DD, UTC
+	TenantId       string  `dynamodbav:"tenantId" json:"tenantId"`
+	Endpoint       string  `dynamodbav:"endpoint" json:"endpoint"`
+	ModelId        string  `dynamodbav:"modelId" json:"modelId"`
+	Calls          int64   `dynamodbav:"calls" json:"calls"`
+	InputTokens    int64   `dynamodbav:"inputTokens" json:"inputTokens"`
+	OutputTokens   int64   `dynamodbav:"outputTokens" json:"outputTokens"`
+	EstimatedCalls int64   `dynamodbav:"estimatedCalls" json:"estimatedCalls"`
+	Cost           float64 `dynamodbav:"cost" json:"cost"`   // USD, at the prices when the tokens were used
+	ExpiresAt      int64   `dynamodbav:"expiresAt" json:"-"` // DynamoDB TTL, epoch seconds
+}
+
+// UsageId returns the ID of the aggregate of a day, tenant, endpoint and model
+func UsageId(date string, tenantId string, endpoint string, modelId string) string {
+	return date + "#" + tenantId + "#" + endpoint + "#" + modelId
+}
+
+type UsageStore interface {
+	// AddUsage adds the calls, tokens and cost of record to the aggregate with its ID, created
+	// with the record's expiry when there is none
+	AddUsage(ctx context.Context, record *UsageRecord) error
+	// ListUsage returns the aggregates of the days from from to to, inclusive
+	ListUsage(ctx context.Context, from string, to string) ([]UsageRecord, error)
+}
+
+// DynamoDBUsageStore shares usage between instances, with atomic counters per aggregate
+type DynamoDBUsageStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBUsageStore(cfg aws.Config, tableName string) *DynamoDBUsageStore {
+	return &DynamoDBUsageStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBUsageStore) AddUsage(ctx context.Context, record *UsageRecord) error {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: record.Id},
+		},
+		UpdateExpression: aws.String("ADD calls :calls, inputTokens :inputTokens, outputTokens :outputTokens, estimatedCalls :estimatedCalls, cost :cost " +
+			"SET #date = :date, tenantId = :tenantId, endpoint = :endpoint, modelId = :modelId, expiresAt = if_not_exists(expiresAt, :expiresAt)"),
+		ExpressionAttributeNames: map[string]string{"#date": "date"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":calls":          &types.AttributeValueMemberN{Value: strconv.FormatInt(record.Calls, 10)},
+			":inputTokens":    &types.AttributeValueMemberN{Value: strconv.FormatInt(record.InputTokens, 10)},
+			":outputTokens":   &types.AttributeValueMemberN{Value: strconv.FormatInt(record.OutputTokens, 10)},
+			":estimatedCalls": &types.AttributeValueMemberN{Value: strconv.FormatInt(record.EstimatedCalls, 10)},
+			":cost":           &types.AttributeValueMemberN{Value: strconv.FormatFloat(record.Cost, 'f', -1, 64)},
+			":date":           &types.AttributeValueMemberS{Value: record.Date},
+			":tenantId":       &types.AttributeValueMemberS{Value: record.TenantId},
+			":endpoint":       &types.AttributeValueMemberS{Value: record.Endpoint},
+			":modelId":        &types.AttributeValueMemberS{Value: record.ModelId},
+			":expiresAt":      &types.AttributeValueMemberN{Value: strconv.FormatInt(record.ExpiresAt, 10)},
+		},
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to add token usage", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBUsageStore) ListUsage(ctx context.Context, from string, to string) ([]UsageRecord, error) {
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:                aws.String(s.tableName),
+		FilterExpression:         aws.String("#date BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{"#date": "date"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":from": &types.AttributeValueMemberS{Value: from},
+			":to":   &types.AttributeValueMemberS{Value: to},
+		},
+	})
+
+	var records []UsageRecord
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to scan token usage", err)
+		}
+
+		var pageRecords []UsageRecord
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageRecords); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse token usage", err)
+		}
+		records = append(records, pageRecords...)
+	}
+	return records, nil
+}
+
+// MemoryUsageStore keeps usage in the instance's memory, so each instance reports its own
+// usage since it started
+type MemoryUsageStore struct {
+	mu      sync.Mutex
+	records map[string]*UsageRecord
+}
+
+func NewMemoryUsageStore() *MemoryUsageStore {
+	return &MemoryUsageStore{
+		records: map[string]*UsageRecord{},
+	}
+}
+
+func (s *MemoryUsageStore) AddUsage(ctx context.Context, record *UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.records[record.Id]
+	if !ok {
+		added := *record
+		s.records[record.Id] = &added
+		return nil
+	}
+	existing.Calls += record.Calls
+	existing.InputTokens += record.InputTokens
+	existing.OutputTokens += record.OutputTokens
+	existing.EstimatedCalls += record.EstimatedCalls
+	existing.Cost += record.Cost
+	return nil
+}
+
+func (s *MemoryUsageStore) ListUsage(ctx context.Context, from string, to string) ([]UsageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []UsageRecord
+	for _, record := range s.records {
+		if record.Date >= from && record.Date <= to {
+			records = append(records, *record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Id < records[j].Id })
+	return records, nil
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"teletubpax-api/cache"
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// VersionComparisonRecord is the change summary generated for a pair of document versions.
+// ContentHash identifies the compared contents, so a document replaced under the same link
+// is compared again.
+type VersionComparisonRecord struct {
+	Key           string    `dynamodbav:"key" json:"key"` // VersionComparisonKey of the links
+	OlderLink     string    `dynamodbav:"olderLink" json:"olderLink"`
+	NewerLink     string    `dynamodbav:"newerLink" json:"newerLink"`
+	ContentHash   string    `dynamodbav:"contentHash" json:"contentHash"`
+	ChangeSummary string    `dynamodbav:"changeSummary" json:"changeSummary"`
+	ComparedAt    time.Time `dynamodbav:"comparedAt" json:"comparedAt"`
+	ExpiresAt     int64     `dynamodbav:"expiresAt" json:"-"` // DynamoDB TTL, epoch seconds
+}
+
+// VersionComparisonKey identifies the comparison of an older with a newer document version
+func VersionComparisonKey(olderLink, newerLink string) string {
+	return olderLink + " -> " + newerLink
+}
+
+type VersionComparisonStore interface {
+	// GetComparison returns nil without an error when the versions have not been compared
+	GetComparison(ctx context.Context, olderLink, newerLink string) (*VersionComparisonRecord, error)
+	PutComparison(ctx context.Context, record *VersionComparisonRecord) error
+}
+
+type DynamoDBVersionComparisonStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBVersionComparisonStore(cfg aws.Config, tableName string) *DynamoDBVersionComparisonStore {
+	return &DynamoDBVersionComparisonStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBVersionComparisonStore) GetComparison(ctx context.Context, olderLink, newerLink string) (*VersionComparisonRecord, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"key": &types.AttributeValueMemberS{Value: VersionComparisonKey(olderLink, newerLink)},
+		},
+	})
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read version comparison", err)
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	var record VersionComparisonRecord
+	if err := attributevalue.UnmarshalMap(output.Item, &record); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse version comparison", err)
+	}
+	return &record, nil
+}
+
+func (s *DynamoDBVersionComparisonStore) PutComparison(ctx context.Context, record *VersionComparisonRecord) error {
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal version comparison", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write version comparison", err)
+	}
+	return nil
+}
+
+// comparisonKeyPrefix keeps cached comparisons apart from the other values of a shared cache.Store
+const comparisonKeyPrefix = "comparison:"
+
+// CacheVersionComparisonStore keeps comparisons as JSON in a cache.Store until their
+// ExpiresAt, for deployments sharing a Redis instead of a table
+type CacheVersionComparisonStore struct {
+	store cache.Store
+}
+
+func NewCacheVersionComparisonStore(store cache.Store) *CacheVersionComparisonStore {
+	return &CacheVersionComparisonStore{
+		store: store,
+	}
+}
+
+func (s *CacheVersionComparisonStore) GetComparison(ctx context.Context, olderLink, newerLink string) (*VersionComparisonRecord, error) {
+	data, err := s.store.Get(ctx, comparisonKeyPrefix+VersionComparisonKey(olderLink, newerLink))
+	if err != nil {
+		return nil, errors.NewAWSServiceError("failed to read version comparison", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var record VersionComparisonRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, errors.NewAWSServiceError("failed to parse version comparison", err)
+	}
+	return &record, nil
+}
+
+func (s *CacheVersionComparisonStore) PutComparison(ctx context.Context, record *VersionComparisonRecord) error {
+	ttl := time.Until(time.Unix(record.ExpiresAt, 0))
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal version comparison", err)
+	}
+	if err := s.store.Set(ctx, comparisonKeyPrefix+record.Key, data, ttl); err != nil {
+		return errors.NewAWSServiceError("failed to write version comparison", err)
+	}
+	return nil
+}
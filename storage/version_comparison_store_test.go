@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teletubpax-api/cache"
+)
+
+func TestCacheVersionComparisonStore_GetAndPut(t *testing.T) {
+	store := NewCacheVersionComparisonStore(cache.NewMemoryStore(0))
+	ctx := context.Background()
+
+	if record, err := store.GetComparison(ctx, "v1.pdf", "v2.pdf"); err != nil || record != nil {
+		t.Fatalf("expected no comparison, got %+v %v", record, err)
+	}
+
+	store.PutComparison(ctx, &VersionComparisonRecord{
+		Key:           VersionComparisonKey("v1.pdf", "v2.pdf"),
+		OlderLink:     "v1.pdf",
+		NewerLink:     "v2.pdf",
+		ChangeSummary: "ค่าธรรมเนียมเพิ่มขึ้น",
+		ExpiresAt:     time.Now().Add(time.Hour).Unix(),
+	})
+	record, err := store.GetComparison(ctx, "v1.pdf", "v2.pdf")
+	if err != nil || record == nil || record.ChangeSummary != "ค่าธรรมเนียมเพิ่มขึ้น" {
+		t.Errorf("expected the stored comparison, got %+v %v", record, err)
+	}
+	if record, _ := store.GetComparison(ctx, "v2.pdf", "v1.pdf"); record != nil {
+		t.Errorf("expected the reversed pair to be a different comparison, got %+v", record)
+	}
+}
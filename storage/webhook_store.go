@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	stdErrors "errors"
+	"strconv"
+	"time"
+
+	"teletubpax-api/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Webhook is a consumer URL notified about new document versions. Payloads are signed with
+// Secret, which is only returned when the webhook is registered.
+type Webhook struct {
+	Id                  string     `dynamodbav:"id" json:"id"`
+	Url                 string     `dynamodbav:"url" json:"url"`
+	Secret              string     `dynamodbav:"secret" json:"-"`
+	Description         string     `dynamodbav:"description" json:"description,omitempty"`
+	CreatedAt           time.Time  `dynamodbav:"createdAt" json:"createdAt"`
+	LastDeliveryAt      *time.Time `dynamodbav:"lastDeliveryAt,omitempty" json:"lastDeliveryAt,omitempty"`
+	LastDeliveryStatus  int        `dynamodbav:"lastDeliveryStatus" json:"lastDeliveryStatus,omitempty"` // HTTP status, 0 when the request failed
+	ConsecutiveFailures int        `dynamodbav:"consecutiveFailures" json:"consecutiveFailures"`
+}
+
+type WebhookStore interface {
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	PutWebhook(ctx context.Context, webhook *Webhook) error
+	// DeleteWebhook returns false without an error when the webhook does not exist
+	DeleteWebhook(ctx context.Context, id string) (bool, error)
+	// RecordDelivery saves the outcome of the latest delivery, deleted webhooks are skipped
+	RecordDelivery(ctx context.Context, id string, status int, succeeded bool, deliveredAt time.Time) error
+}
+
+type DynamoDBWebhookStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoDBWebhookStore(cfg aws.Config, tableName string) *DynamoDBWebhookStore {
+	return &DynamoDBWebhookStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBWebhookStore) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:      aws.String(s.tableName),
+		ConsistentRead: aws.Bool(true),
+	})
+
+	var webhooks []Webhook
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.NewAWSServiceError("failed to scan webhooks", err)
+		}
+
+		var pageWebhooks []Webhook
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageWebhooks); err != nil {
+			return nil, errors.NewAWSServiceError("failed to parse webhooks", err)
+		}
+		webhooks = append(webhooks, pageWebhooks...)
+	}
+	return webhooks, nil
+}
+
+func (s *DynamoDBWebhookStore) PutWebhook(ctx context.Context, webhook *Webhook) error {
+	item, err := attributevalue.MarshalMap(webhook)
+	if err != nil {
+		return errors.NewAWSServiceError("failed to marshal webhook", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errors.NewAWSServiceError("failed to write webhook", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBWebhookStore) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	output, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	if err != nil {
+		return false, errors.NewAWSServiceError("failed to delete webhook", err)
+	}
+	return len(output.Attributes) > 0, nil
+}
+
+func (s *DynamoDBWebhookStore) RecordDelivery(ctx context.Context, id string, status int, succeeded bool, deliveredAt time.Time) error {
+	updateExpression := "SET lastDeliveryAt = :deliveredAt, lastDeliveryStatus = :status, consecutiveFailures = :zero"
+	values := map[string]types.AttributeValue{
+		":deliveredAt": &types.AttributeValueMemberS{Value: deliveredAt.UTC().Format(time.RFC3339)},
+		":status":      &types.AttributeValueMemberN{Value: strconv.Itoa(status)},
+		":zero":        &types.AttributeValueMemberN{Value: "0"},
+	}
+	if !succeeded {
+		updateExpression = "SET lastDeliveryAt = :deliveredAt, lastDeliveryStatus = :status ADD consecutiveFailures :one"
+		delete(values, ":zero")
+		values[":one"] = &types.AttributeValueMemberN{Value: "1"}
+	}
+
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: values,
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if stdErrors.As(err, &conditionFailed) {
+		return nil
+	}
+	if err != nil {
+		return errors.NewAWSServiceError("failed to record webhook delivery", err)
+	}
+	return nil
+}
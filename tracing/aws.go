@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AddTo traces every call of the AWS clients created from the config afterwards: one client
+// span per operation, covering its retries, as a child of the span in the call's context.
+// Runs after the clients' own initialize middlewares, which name the service and operation,
+// and must be added before fault injection, so injected faults show up in the traces.
+func AddTo(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Tracing", handleInitialize), middleware.After)
+	})
+}
+
+func handleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	service := awsmiddleware.GetServiceID(ctx)
+	operation := awsmiddleware.GetOperationName(ctx)
+	ctx, span := otel.Tracer(tracerName).Start(ctx, service+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "aws-api"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", operation),
+			attribute.String("cloud.region", awsmiddleware.GetRegion(ctx)),
+		),
+	)
+
+	out, metadata, err := next.HandleInitialize(ctx, in)
+
+	if requestId, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+		span.SetAttributes(attribute.String("aws.request_id", requestId))
+	}
+	if attempts, ok := retry.GetAttemptResults(metadata); ok {
+		span.SetAttributes(attribute.Int("aws.attempts", len(attempts.Results)))
+	}
+	if response, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok {
+		span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode))
+	}
+	End(span, err)
+	return out, metadata, err
+}
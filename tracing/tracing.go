@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"teletubpax-api/config"
+)
+
+const (
+	ExporterOTLP = "otlp" // OTLP/HTTP to a collector or tracing backend
+	ExporterXRay = "xray" // OTLP/HTTP to an ADOT collector forwarding to X-Ray, with X-Ray trace IDs and headers
+)
+
+// tracerName is the instrumentation scope of the service's spans
+const tracerName = "teletubpax-api"
+
+// Span attributes of the service
+const (
+	AttrKnowledgeBaseId = attribute.Key("aws.bedrock.knowledge_base.id")
+	AttrModelId         = attribute.Key("gen_ai.request.model")
+	AttrAnswerBackend   = attribute.Key("teletubpax.answer_backend")
+)
+
+// Setup installs the tracer provider and propagators for TRACING_EXPORTER. Without an
+// exporter it does nothing: spans are then no-ops and cost close to nothing. The returned
+// provider must be flushed before the process, or a Lambda invocation, ends; it is nil when
+// tracing is off.
+func Setup(ctx context.Context, cfg *config.Config) (*sdktrace.TracerProvider, error) {
+	if cfg.TracingExporter == "" {
+		return nil, nil
+	}
+
+	var exporterOptions []otlptracehttp.Option
+	if cfg.TracingEndpoint != "" {
+		exporterOptions = append(exporterOptions, otlptracehttp.WithEndpointURL(cfg.TracingEndpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", tracerName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	// Callers that already sampled a trace decide for its spans, e.g. an X-Ray traced client
+	providerOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(float64(cfg.TracingSamplePercent) / 100))),
+	}
+	propagators := []propagation.TextMapPropagator{propagation.TraceContext{}, propagation.Baggage{}}
+
+	switch cfg.TracingExporter {
+	case ExporterOTLP:
+	case ExporterXRay:
+		providerOptions = append(providerOptions, sdktrace.WithIDGenerator(xray.NewIDGenerator()))
+		propagators = append([]propagation.TextMapPropagator{xray.Propagator{}}, propagators...)
+	default:
+		return nil, fmt.Errorf("unknown TRACING_EXPORTER %q", cfg.TracingExporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(providerOptions...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagators...))
+	return provider, nil
+}
+
+// Start starts a span as child of the span in the context, if any
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// StartServer starts the span of an incoming request
+func StartServer(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// End ends the span, marking it failed when err is set
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
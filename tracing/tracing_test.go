@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"teletubpax-api/config"
+)
+
+// recordSpans makes the global tracer provider record ended spans for the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// spanAttribute returns the value of a span attribute, empty when the span does not have it
+func spanAttribute(span sdktrace.ReadOnlySpan, key string) string {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestAddTo_TracesAWSCalls(t *testing.T) {
+	recorder := recordSpans(t)
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Header().Set("X-Amzn-RequestId", "request-1")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"Table": {"TableName": "test"}}`))
+		} else {
+			w.Write([]byte(`{"__type": "com.amazonaws.dynamodb.v20120810#ResourceNotFoundException", "message": "missing"}`))
+		}
+	}))
+	defer server.Close()
+
+	cfg := aws.Config{
+		Region:           "ap-southeast-1",
+		Credentials:      aws.AnonymousCredentials{},
+		BaseEndpoint:     aws.String(server.URL),
+		RetryMaxAttempts: 1,
+	}
+	AddTo(&cfg)
+	client := dynamodb.NewFromConfig(cfg)
+	input := &dynamodb.DescribeTableInput{TableName: aws.String("test")}
+
+	ctx, parent := Start(context.Background(), "parent")
+	if _, err := client.DescribeTable(ctx, input); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status = http.StatusBadRequest
+	client.DescribeTable(ctx, input)
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected two call spans and the parent, got %d", len(spans))
+	}
+	call := spans[0]
+	if call.Name() != "DynamoDB.DescribeTable" || call.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("expected a DescribeTable span below the parent, got %s", call.Name())
+	}
+	if spanAttribute(call, "aws.request_id") != "request-1" || spanAttribute(call, "cloud.region") != "ap-southeast-1" || spanAttribute(call, "http.response.status_code") != "200" {
+		t.Errorf("unexpected attributes %v", call.Attributes())
+	}
+	if failed := spans[1]; failed.Status().Code != codes.Error || spanAttribute(failed, "http.response.status_code") != "400" {
+		t.Errorf("expected the failed call marked as an error, got %+v", failed.Status())
+	}
+}
+
+func TestSetup(t *testing.T) {
+	if provider, err := Setup(context.Background(), &config.Config{}); provider != nil || err != nil {
+		t.Errorf("expected tracing off without an exporter, got %v %v", provider, err)
+	}
+	if _, err := Setup(context.Background(), &config.Config{TracingExporter: "zipkin"}); err == nil {
+		t.Error("expected an unknown exporter to be rejected")
+	}
+
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+	provider, err := Setup(context.Background(), &config.Config{
+		TracingExporter:      ExporterXRay,
+		TracingEndpoint:      "http://127.0.0.1:4318/v1/traces",
+		TracingSamplePercent: 100,
+	})
+	if err != nil || provider == nil {
+		t.Fatalf("expected a provider, got %v", err)
+	}
+	defer provider.Shutdown(context.Background())
+
+	_, span := Start(context.Background(), "test")
+	defer span.End()
+	// X-Ray trace IDs start with the epoch seconds of the trace
+	traceId := span.SpanContext().TraceID().String()
+	if seconds, err := strconv.ParseInt(traceId[:8], 16, 64); err != nil || time.Since(time.Unix(seconds, 0)).Abs() > time.Minute {
+		t.Errorf("expected an X-Ray trace ID, got %s", traceId)
+	}
+}
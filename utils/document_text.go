@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ExtractDocumentText returns the plain text of a source document, chosen by the file
+// extension of its key. PDFs are parsed page by page, text formats are returned as is.
+func ExtractDocumentText(key string, data []byte) (string, error) {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".pdf":
+		return extractPdfText(data)
+	case ".txt", ".md", ".csv", ".html", ".htm":
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("document %s is not valid UTF-8", key)
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return "", fmt.Errorf("unsupported document type: %s", key)
+	}
+}
+
+func extractPdfText(data []byte) (text string, err error) {
+	// The parser panics on some malformed files instead of returning an error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to parse pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open pdf: %w", err)
+	}
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("failed to extract pdf text: %w", err)
+	}
+	content, err := io.ReadAll(plain)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract pdf text: %w", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
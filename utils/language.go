@@ -0,0 +1,36 @@
+package utils
+
+import "unicode"
+
+const (
+	LanguageThai    = "th"
+	LanguageEnglish = "en"
+)
+
+// thaiLetterRatio is the share of Thai letters above which text counts as Thai. Thai answers
+// routinely contain English product names, so any clear share of Thai script wins.
+const thaiLetterRatio = 0.3
+
+// DetectLanguage classifies text as Thai or English by its share of Thai letters. Text
+// without letters is reported as English.
+func DetectLanguage(text string) string {
+	letters, thai := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Thai, r) {
+			thai++
+		}
+	}
+	if letters > 0 && float64(thai)/float64(letters) >= thaiLetterRatio {
+		return LanguageThai
+	}
+	return LanguageEnglish
+}
+
+// IsSupportedLanguage reports whether answers can be translated to the language
+func IsSupportedLanguage(language string) bool {
+	return language == LanguageThai || language == LanguageEnglish
+}
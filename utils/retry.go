@@ -1,127 +1,192 @@
-package utils
-
-import (
-	"context"
-	"log"
-	"time"
-	"teletubpax-api/errors"
-)
-
-type RetryConfig struct {
-	MaxAttempts     int
-	InitialBackoff  time.Duration
-	BackoffMultiplier float64
-	MaxBackoff      time.Duration
-}
-
-func DefaultRetryConfig() RetryConfig {
-	return RetryConfig{
-		MaxAttempts:       3,
-		InitialBackoff:    100 * time.Millisecond,
-		BackoffMultiplier: 2.0,
-		MaxBackoff:        2 * time.Second,
-	}
-}
-
-func RetryWithBackoff(ctx context.Context, config RetryConfig, operation func() error) error {
-	var lastErr error
-	backoff := config.InitialBackoff
-
-	for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
-		lastErr = operation()
-
-		if lastErr == nil {
-			return nil
-		}
-
-		// Check if error is retryable
-		if !isRetryable(lastErr) {
-			return lastErr
-		}
-
-		// Don't sleep after the last attempt
-		if attempt == config.MaxAttempts {
-			break
-		}
-
-		// Log retry attempt
-		log.Printf("Retry attempt %d/%d after error: %v. Waiting %v before retry", 
-			attempt, config.MaxAttempts, lastErr, backoff)
-
-		// Wait with exponential backoff
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(backoff):
-		}
-
-		// Calculate next backoff duration
-		backoff = time.Duration(float64(backoff) * config.BackoffMultiplier)
-		if backoff > config.MaxBackoff {
-			backoff = config.MaxBackoff
-		}
-	}
-
-	log.Printf("All %d retry attempts exhausted. Last error: %v", config.MaxAttempts, lastErr)
-	return lastErr
-}
-
-func isRetryable(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	// Check if it's a BedrockError
-	if bedrockErr, ok := err.(*errors.BedrockError); ok {
-		switch bedrockErr.Code {
-		case errors.ErrCodeThrottling:
-			return true
-		case errors.ErrCodeAWSService:
-			// Retry AWS service errors (timeouts, 5xx errors)
-			return true
-		case errors.ErrCodeValidation:
-			// Don't retry validation errors
-			return false
-		case errors.ErrCodeEmbedding, errors.ErrCodeKnowledgeBase:
-			// Retry if the underlying cause is retryable
-			if bedrockErr.Cause != nil {
-				return isRetryable(bedrockErr.Cause)
-			}
-			return false
-		}
-	}
-
-	// Check error message for common retryable patterns
-	errMsg := err.Error()
-	retryablePatterns := []string{
-		"timeout",
-		"Timeout",
-		"ServiceUnavailable",
-		"InternalServer",
-		"TooManyRequests",
-		"Throttling",
-	}
-
-	for _, pattern := range retryablePatterns {
-		if contains(errMsg, pattern) {
-			return true
-		}
-	}
-
-	return false
-}
-
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && 
-		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || 
-		findSubstring(s, substr)))
-}
-
-func findSubstring(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
+package utils
+
+import (
+	"context"
+	stdErrors "errors"
+	"log"
+	"math/rand"
+	"time"
+	"teletubpax-api/errors"
+)
+
+type RetryConfig struct {
+	MaxAttempts     int
+	InitialBackoff  time.Duration
+	BackoffMultiplier float64
+	MaxBackoff      time.Duration
+	// Jitter waits a random duration up to the backoff ("full jitter"), so retries of
+	// concurrent invocations throttled together do not hit Bedrock again in lockstep
+	Jitter bool
+	// AttemptTimeout bounds each attempt, 0 means attempts only end with ctx. An attempt
+	// that runs out of time is retried while ctx is still live.
+	AttemptTimeout time.Duration
+	// SkipThrottling does not retry throttled attempts, for operations whose AWS calls the
+	// SDK already retried at the pace of the adaptive throttle
+	SkipThrottling bool
+}
+
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:       3,
+		InitialBackoff:    100 * time.Millisecond,
+		BackoffMultiplier: 2.0,
+		MaxBackoff:        2 * time.Second,
+		Jitter:            true,
+	}
+}
+
+// RetryWithBackoff runs operation until it succeeds, fails with an error that is not
+// retryable or runs out of attempts. A Retry-After hint of a throttled AWS call replaces a
+// shorter backoff. Retrying stops early when the wait would outlast the deadline of ctx.
+func RetryWithBackoff(ctx context.Context, config RetryConfig, operation func(ctx context.Context) error) error {
+	var lastErr error
+	backoff := config.InitialBackoff
+
+	for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
+		lastErr = runAttempt(ctx, config.AttemptTimeout, operation)
+
+		if lastErr == nil {
+			return nil
+		}
+
+		// Check if error is retryable
+		if (!isRetryable(lastErr) || config.SkipThrottling && isThrottled(lastErr)) && !attemptTimedOut(ctx, lastErr) {
+			return lastErr
+		}
+
+		// Don't sleep after the last attempt
+		if attempt == config.MaxAttempts {
+			break
+		}
+
+		wait := config.wait(backoff, lastErr)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			log.Printf("Retry attempt %d/%d after error: %v. Not retrying, waiting %v would pass the deadline",
+				attempt, config.MaxAttempts, lastErr, wait)
+			return lastErr
+		}
+
+		// Log retry attempt
+		log.Printf("Retry attempt %d/%d after error: %v. Waiting %v before retry", 
+			attempt, config.MaxAttempts, lastErr, wait)
+
+		// Wait with exponential backoff
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		// Calculate next backoff duration
+		backoff = time.Duration(float64(backoff) * config.BackoffMultiplier)
+		if backoff > config.MaxBackoff {
+			backoff = config.MaxBackoff
+		}
+	}
+
+	log.Printf("All %d retry attempts exhausted. Last error: %v", config.MaxAttempts, lastErr)
+	return lastErr
+}
+
+func runAttempt(ctx context.Context, timeout time.Duration, operation func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return operation(ctx)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return operation(attemptCtx)
+}
+
+// attemptTimedOut reports whether err comes from the attempt timeout rather than from ctx
+func attemptTimedOut(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && stdErrors.Is(err, context.DeadlineExceeded)
+}
+
+// wait returns how long to wait before the next attempt
+func (c RetryConfig) wait(backoff time.Duration, err error) time.Duration {
+	wait := backoff
+	if c.Jitter && backoff > 0 {
+		wait = time.Duration(rand.Int63n(int64(backoff) + 1))
+	}
+	if retryAfter, ok := errors.RetryAfter(err); ok && retryAfter > wait {
+		wait = retryAfter
+	}
+	return wait
+}
+
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Errors the SDK was told not to retry are not retried here either, e.g. a call the
+	// adaptive throttle could not pace before the deadline
+	var retryable interface{ RetryableError() bool }
+	if stdErrors.As(err, &retryable) && !retryable.RetryableError() {
+		return false
+	}
+
+	// Check if it's a BedrockError
+	if bedrockErr, ok := err.(*errors.BedrockError); ok {
+		switch bedrockErr.Code {
+		case errors.ErrCodeThrottling:
+			return true
+		case errors.ErrCodeAWSService:
+			// Retry AWS service errors (timeouts, 5xx errors)
+			return true
+		case errors.ErrCodeValidation:
+			// Don't retry validation errors
+			return false
+		case errors.ErrCodeEmbedding, errors.ErrCodeKnowledgeBase:
+			// Retry if the underlying cause is retryable
+			if bedrockErr.Cause != nil {
+				return isRetryable(bedrockErr.Cause)
+			}
+			return false
+		}
+	}
+
+	// Check error message for common retryable patterns
+	errMsg := err.Error()
+	retryablePatterns := []string{
+		"timeout",
+		"Timeout",
+		"ServiceUnavailable",
+		"InternalServer",
+		"TooManyRequests",
+		"Throttling",
+	}
+
+	for _, pattern := range retryablePatterns {
+		if contains(errMsg, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isThrottled reports whether err is AWS throttling a call
+func isThrottled(err error) bool {
+	var bedrockErr *errors.BedrockError
+	if stdErrors.As(err, &bedrockErr) && bedrockErr.Code == errors.ErrCodeThrottling {
+		return true
+	}
+	errMsg := err.Error()
+	return contains(errMsg, "Throttling") || contains(errMsg, "TooManyRequests")
+}
+
+func contains(s, substr string) bool {
+	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && 
+		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || 
+		findSubstring(s, substr)))
+}
+
+func findSubstring(s, substr string) bool {
+	for i := 0; i <= len(s)-len(substr); i++ {
+		if s[i:i+len(substr)] == substr {
+			return true
+		}
+	}
+	return false
+}
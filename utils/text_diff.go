@@ -0,0 +1,181 @@
+package utils
+
+import "strings"
+
+// maxDiffEdits bounds the edit distance the diff searches for. Texts that differ more are
+// reported as one hunk replacing the whole changed middle.
+const maxDiffEdits = 2000
+
+// DiffLine is a line of a diff hunk: ' ' unchanged, '-' removed or '+' added
+type DiffLine struct {
+	Kind byte
+	Text string
+}
+
+// DiffHunk is a run of changed lines, with unchanged lines around it for context
+type DiffHunk []DiffLine
+
+// String formats the hunk like a unified diff without line numbers
+func (h DiffHunk) String() string {
+	var b strings.Builder
+	b.WriteString("@@\n")
+	for _, line := range h {
+		b.WriteByte(line.Kind)
+		b.WriteByte(' ')
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// DiffText compares two texts line by line and returns the changed sections, each with up
+// to contextLines unchanged lines before and after it. Whitespace within a line and blank
+// lines are ignored, so reflowed or re-extracted text does not show up as a change.
+func DiffText(older string, newer string, contextLines int) []DiffHunk {
+	return diffHunks(diffLines(normalizedLines(older), normalizedLines(newer)), contextLines)
+}
+
+// normalizedLines splits text into lines with their whitespace collapsed, dropping blank ones
+func normalizedLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script from a to b, found with Myers' algorithm on
+// the lines between their common prefix and suffix
+func diffLines(a []string, b []string) []DiffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	script := make([]DiffLine, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		script = append(script, DiffLine{' ', line})
+	}
+	script = append(script, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		script = append(script, DiffLine{' ', line})
+	}
+	return script
+}
+
+func myersDiff(a []string, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit > maxDiffEdits {
+		limit = maxDiffEdits
+	}
+
+	// v[offset+k] is the furthest x reached on diagonal k. trace[d] keeps diagonals -d-1 to
+	// d+1 of v as they were before step d, for the backtrack.
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+
+	script := make([]DiffLine, 0, n+m)
+	for _, line := range a {
+		script = append(script, DiffLine{'-', line})
+	}
+	for _, line := range b {
+		script = append(script, DiffLine{'+', line})
+	}
+	return script
+}
+
+// backtrack walks the trace back from the end of both texts to recover the edit script
+func backtrack(a []string, b []string, trace [][]int) []DiffLine {
+	var reversed []DiffLine
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, DiffLine{' ', a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, DiffLine{'+', b[prevY]})
+			} else {
+				reversed = append(reversed, DiffLine{'-', a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	script := make([]DiffLine, len(reversed))
+	for i, line := range reversed {
+		script[len(reversed)-1-i] = line
+	}
+	return script
+}
+
+// diffHunks groups the changes of an edit script, merging changes that share context
+func diffHunks(script []DiffLine, contextLines int) []DiffHunk {
+	var hunks []DiffHunk
+	start, end := -1, -1 // Of the current hunk in script, end exclusive
+	for i, line := range script {
+		if line.Kind == ' ' {
+			continue
+		}
+		from := i - contextLines
+		if from < 0 {
+			from = 0
+		}
+		if start >= 0 && from > end {
+			hunks = append(hunks, DiffHunk(script[start:end]))
+			start = -1
+		}
+		if start < 0 {
+			start = from
+		}
+		end = i + contextLines + 1
+		if end > len(script) {
+			end = len(script)
+		}
+	}
+	if start >= 0 {
+		hunks = append(hunks, DiffHunk(script[start:end]))
+	}
+	return hunks
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffText_Hunks(t *testing.T) {
+	older := "ค่าธรรมเนียมบัตรเดบิต\nค่าธรรมเนียมรายปี 150 บาท\nยกเว้นปีแรก\n\nช่องทางการสมัคร\nสาขา\nแอปพลิเคชัน\nเงื่อนไข\nอายุ 20 ปีขึ้นไป\nมีบัญชีออมทรัพย์"
+	newer := "ค่าธรรมเนียมบัตรเดบิต\nค่าธรรมเนียมรายปี   200 บาท\nยกเว้นปีแรก\nช่องทางการสมัคร\nสาขา\nแอปพลิเคชัน\nเงื่อนไข\nอายุ 20 ปีขึ้นไป\nมีบัญชีออมทรัพย์\nมีรายได้ขั้นต่ำ 15,000 บาท"
+
+	hunks := DiffText(older, newer, 1)
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d: %v", len(hunks), hunks)
+	}
+	if got := hunks[0].String(); got != "@@\n  ค่าธรรมเนียมบัตรเดบิต\n- ค่าธรรมเนียมรายปี 150 บาท\n+ ค่าธรรมเนียมรายปี 200 บาท\n  ยกเว้นปีแรก\n" {
+		t.Errorf("unexpected first hunk %q", got)
+	}
+	if got := hunks[1].String(); got != "@@\n  มีบัญชีออมทรัพย์\n+ มีรายได้ขั้นต่ำ 15,000 บาท\n" {
+		t.Errorf("unexpected second hunk %q", got)
+	}
+}
+
+func TestDiffText_IgnoresWhitespace(t *testing.T) {
+	if hunks := DiffText("a  b\n\nc\n", "a b\nc", 2); len(hunks) != 0 {
+		t.Errorf("expected no changes, got %v", hunks)
+	}
+}
+
+func TestDiffText_MergesNearbyChanges(t *testing.T) {
+	hunks := DiffText("a\nb\nc\nd\ne", "a\nB\nc\nD\ne", 1)
+	if len(hunks) != 1 || len(hunks[0]) != 7 {
+		t.Errorf("expected changes sharing context in one hunk, got %v", hunks)
+	}
+}
+
+func TestDiffText_ShortestEdit(t *testing.T) {
+	older := strings.Split("a b c a b b a", " ")
+	newer := strings.Split("c b a b a c", " ")
+	script := diffLines(older, newer)
+
+	var removed, added []string
+	for _, line := range script {
+		switch line.Kind {
+		case '-':
+			removed = append(removed, line.Text)
+		case '+':
+			added = append(added, line.Text)
+		}
+	}
+	// The edit distance of Myers' example is 5
+	if len(removed)+len(added) != 5 {
+		t.Errorf("expected 5 edits, got -%v +%v", removed, added)
+	}
+	if got := applyScript(script); got != strings.Join(newer, " ") {
+		t.Errorf("script does not produce the newer text: %q", got)
+	}
+}
+
+// applyScript rebuilds the newer text from an edit script
+func applyScript(script []DiffLine) string {
+	var lines []string
+	for _, line := range script {
+		if line.Kind != '-' {
+			lines = append(lines, line.Text)
+		}
+	}
+	return strings.Join(lines, " ")
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// zeroWidthReplacer strips characters that are invisible in a question but split the words
+// around them for retrieval: zero-width space, non-joiner and joiner, word joiner, byte
+// order mark and soft hyphen
+var zeroWidthReplacer = strings.NewReplacer(
+	"\u200b", "",
+	"\u200c", "",
+	"\u200d", "",
+	"\u2060", "",
+	"\ufeff", "",
+	"\u00ad", "",
+)
+
+// Thai typing mistakes that render like the intended text but are different code points
+var (
+	// SARA E typed twice for SARA AE: เเ -> แ
+	doubleSaraE = regexp.MustCompile(`\x{0E40}\x{0E40}`)
+	// NIKHAHIT and SARA AA for SARA AM, also with a tone mark typed between them:
+	// ทํา -> ทำ, นํ้า -> น้ำ
+	splitSaraAm = regexp.MustCompile(`\x{0E4D}([\x{0E48}-\x{0E4B}]?)\x{0E32}`)
+	// A tone mark or thanthakhat typed before the vowel above or below the same consonant:
+	// ก่ิ -> กิ่
+	toneBeforeVowel = regexp.MustCompile(`([\x{0E48}-\x{0E4C}])([\x{0E31}\x{0E34}-\x{0E3A}\x{0E47}])`)
+	whitespace      = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeThaiText cleans up a question before it is validated and searched: it removes
+// zero-width characters, composes Unicode (NFC), fixes Thai vowels and tone marks typed as
+// look-alike sequences and collapses whitespace. English text is only affected by the
+// Unicode and whitespace steps.
+func NormalizeThaiText(text string) string {
+	text = zeroWidthReplacer.Replace(text)
+	text = norm.NFC.String(text)
+	text = doubleSaraE.ReplaceAllString(text, "แ")
+	text = splitSaraAm.ReplaceAllString(text, "${1}ำ")
+	text = toneBeforeVowel.ReplaceAllString(text, "$2$1")
+	text = removeRepeatedMarks(text)
+	return strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+}
+
+// removeRepeatedMarks drops a Thai vowel or tone mark that repeats the one before it: ก่่ -> ก่
+func removeRepeatedMarks(text string) string {
+	var b strings.Builder
+	var previous rune
+	for _, r := range text {
+		if r == previous && isThaiMark(r) {
+			continue
+		}
+		b.WriteRune(r)
+		previous = r
+	}
+	return b.String()
+}
+
+// isThaiMark reports whether r is a Thai vowel or tone mark written above or below a consonant
+func isThaiMark(r rune) bool {
+	return r == '\u0E31' || (r >= '\u0E34' && r <= '\u0E3A') || (r >= '\u0E47' && r <= '\u0E4E')
+}
@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+func TestNormalizeThaiText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"clean Thai", "บัตรเครดิตทำอย่างไร", "บัตรเครดิตทำอย่างไร"},
+		{"zero-width space", "บัตร\u200bเครดิต\ufeff", "บัตรเครดิต"},
+		{"double SARA E", "\u0E40\u0E40ก้ไข", "แก้ไข"},
+		{"NIKHAHIT and SARA AA", "ท\u0E4D\u0E32", "ทำ"},
+		{"tone mark between NIKHAHIT and SARA AA", "น\u0E4D\u0E49\u0E32", "น้ำ"},
+		{"tone mark before NIKHAHIT", "น\u0E49\u0E4D\u0E32", "น้ำ"},
+		{"tone mark before vowel", "ก\u0E48\u0E34", "กิ่"},
+		{"repeated tone mark", "ที\u0E48\u0E48", "ที่"},
+		{"decomposed Latin", "cafe\u0301", "café"},
+		{"whitespace", "  ค่าธรรมเนียม \t\n รายปี ", "ค่าธรรมเนียม รายปี"},
+		{"English", "How do I  close my account?", "How do I close my account?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeThaiText(tt.text); got != tt.want {
+				t.Errorf("NormalizeThaiText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
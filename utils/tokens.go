@@ -0,0 +1,9 @@
+package utils
+
+import "unicode/utf8"
+
+// EstimateTokens approximates the model tokens of a text. Thai averages about three
+// characters per token, so the estimate is on the high side for English.
+func EstimateTokens(text string) int {
+	return utf8.RuneCountInString(text)/3 + 1
+}
@@ -0,0 +1,69 @@
+package warnings
+
+import (
+	"context"
+	"sync"
+)
+
+// Codes of the degraded-mode warnings returned to clients
+const (
+	CodeSafeMode              = "safe_mode"               // Safe mode reduced the response
+	CodeKnowledgeBaseSkipped  = "knowledge_base_skipped"  // A knowledge base failed and the answer comes from the others
+	CodeSynthesisSkipped      = "synthesis_skipped"       // Answers of several knowledge bases were joined without synthesis
+	CodeTranslationFailed     = "translation_failed"      // The text is returned in its original language
+	CodeComparisonFailed      = "comparison_failed"       // A version change summary could not be generated
+	CodeSourceContentFallback = "source_content_fallback" // A source document was read from retrieved excerpts
+	CodeContentUnavailable    = "content_unavailable"     // Document contents could not be retrieved
+	CodeSessionExpired        = "session_expired"         // The conversation expired and the answer lacks its earlier context
+	CodeModelFallback         = "model_fallback"          // The generative model failed and a fallback model answered
+)
+
+// Warning tells a client that the response is complete but degraded, so the chat widget and
+// portals can show it instead of silently presenting a partial answer
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Collector gathers the warnings raised while serving one request
+type Collector struct {
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+type contextKey struct{}
+
+// WithCollector attaches a new collector to the context of a request
+func WithCollector(ctx context.Context) (context.Context, *Collector) {
+	collector := &Collector{}
+	return context.WithValue(ctx, contextKey{}, collector), collector
+}
+
+// Add records a warning on the request's collector. It does nothing when the context has no
+// collector, so the pipeline can raise warnings without knowing which endpoint serves it.
+// Repeated warnings are recorded once.
+func Add(ctx context.Context, code string, message string) {
+	collector, ok := ctx.Value(contextKey{}).(*Collector)
+	if !ok {
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	for _, existing := range collector.warnings {
+		if existing.Code == code && existing.Message == message {
+			return
+		}
+	}
+	collector.warnings = append(collector.warnings, Warning{Code: code, Message: message})
+}
+
+// List returns the recorded warnings in the order they were raised, nil when there are none
+func (c *Collector) List() []Warning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.warnings) == 0 {
+		return nil
+	}
+	return append([]Warning(nil), c.warnings...)
+}